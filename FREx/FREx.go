@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/FRECNET/FRExDAO"
 	"github.com/FRECNET/consensus"
 	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/event"
 	"github.com/FRECNET/p2p"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 
@@ -52,7 +54,7 @@ var DefaultConfig = Config{
 type FREX struct {
 	// Order related
 	db         FRExDAO.FREXDAO
-	mongodb    FRExDAO.FREXDAO
+	sdkDB      FRExDAO.FREXDAO       // Add-on database of SDK nodes, nil on masternodes
 	Triegc     *prque.Prque          // Priority queue mapping block numbers to tries to gc
 	StateCache tradingstate.Database // State database to reuse between imports (contains state cache)    *FREx_state.TradingStateDB
 
@@ -62,6 +64,11 @@ type FREX struct {
 	settings          syncmap.Map // holds configuration settings that can be dynamically changed
 	tokenDecimalCache *lru.Cache
 	orderCache        *lru.Cache
+
+	orderBookFeed event.Feed
+	tradeFeed     event.Feed
+	orderFeed     event.Feed
+	scope         event.SubscriptionScope
 }
 
 func (FREx *FREX) Protocols() []p2p.Protocol {
@@ -75,6 +82,7 @@ func (FREx *FREX) Start(server *p2p.Server) error {
 func (FREx *FREX) SaveData() {
 }
 func (FREx *FREX) Stop() error {
+	FREx.scope.Close()
 	return nil
 }
 
@@ -94,6 +102,16 @@ func NewMongoDBEngine(cfg *Config) *FRExDAO.MongoDatabase {
 	return mongoDB
 }
 
+// NewSQLDBEngine opens the sqlite3 database of an SDK node, kept in the FREX
+// data directory.
+func NewSQLDBEngine(cfg *Config) *FRExDAO.SQLDatabase {
+	sqlDB, err := FRExDAO.NewSQLDatabase(cfg.DBEngine, filepath.Join(cfg.DataDir, cfg.DBName+".sqlite"), 0)
+	if err != nil {
+		log.Crit("Failed to init sql engine", "engine", cfg.DBEngine, "err", err)
+	}
+	return sqlDB
+}
+
 func New(cfg *Config) *FREX {
 	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
 	orderCache, _ := lru.New(tradingstate.OrderCacheLimit)
@@ -108,8 +126,12 @@ func New(cfg *Config) *FREX {
 	FREX.db = NewLDBEngine(cfg)
 	FREX.sdkNode = false
 
-	if cfg.DBEngine == "mongodb" { // this is an add-on DBEngine for SDK nodes
-		FREX.mongodb = NewMongoDBEngine(cfg)
+	switch cfg.DBEngine { // these are add-on DBEngines for SDK nodes
+	case "mongodb":
+		FREX.sdkDB = NewMongoDBEngine(cfg)
+		FREX.sdkNode = true
+	case "sqlite3":
+		FREX.sdkDB = NewSQLDBEngine(cfg)
 		FREX.sdkNode = true
 	}
 
@@ -133,8 +155,10 @@ func (FREx *FREX) GetLevelDB() FRExDAO.FREXDAO {
 	return FREx.db
 }
 
-func (FREx *FREX) GetMongoDB() FRExDAO.FREXDAO {
-	return FREx.mongodb
+// GetSDKDB returns the database SDK nodes keep the orders, trades and candles
+// in, mongodb or sqlite3.
+func (FREx *FREX) GetSDKDB() FRExDAO.FREXDAO {
+	return FREx.sdkDB
 }
 
 // APIs returns the RPC descriptors the FREX implementation offers
@@ -192,6 +216,11 @@ func (FREx *FREX) ProcessOrderPending(header *types.Header, coinbase common.Addr
 			Type:            tx.Type(),
 			Hash:            tx.OrderHash(),
 			OrderID:         tx.OrderID(),
+			TriggerPrice:    tx.TriggerPrice(),
+			TimeInForce:     tx.TimeInForce(),
+			ExpireAt:        tx.ExpireAt(),
+			SelfTradeMode:   tx.SelfTradeMode(),
+			Batch:           tx.Batch(),
 			Signature: &tradingstate.Signature{
 				V: byte(n),
 				R: common.BigToHash(R),
@@ -309,6 +338,33 @@ func (FREx *FREX) ConvertFREToToken(chain consensus.ChainContext, statedb *state
 	return tokenQuantity, tokenPriceInFRE, nil
 }
 
+// splitTriggeredTrades separates the trades of the taker order from the trades
+// of the trigger orders it activated. The latter are grouped by trigger order
+// hash, the hashes are returned in activation order.
+func splitTriggeredTrades(takerHash common.Hash, trades []map[string]string) ([]map[string]string, []common.Hash, map[common.Hash][]map[string]string) {
+	var (
+		takerTrades     []map[string]string
+		triggeredHashes []common.Hash
+		triggeredTrades = make(map[common.Hash][]map[string]string)
+	)
+	for _, trade := range trades {
+		if trade == nil || trade[tradingstate.TradeTakerOrderHash] == "" {
+			takerTrades = append(takerTrades, trade)
+			continue
+		}
+		hash := common.HexToHash(trade[tradingstate.TradeTakerOrderHash])
+		if hash == takerHash {
+			takerTrades = append(takerTrades, trade)
+			continue
+		}
+		if _, ok := triggeredTrades[hash]; !ok {
+			triggeredHashes = append(triggeredHashes, hash)
+		}
+		triggeredTrades[hash] = append(triggeredTrades[hash], trade)
+	}
+	return takerTrades, triggeredHashes, triggeredTrades
+}
+
 // there are 3 tasks need to complete to update data in SDK nodes after matching
 // 1. txMatchData.Order: order has been processed. This order should be put to `orders` collection with status sdktypes.OrderStatusOpen
 // 2. txMatchData.Trades: includes information of matched orders.
@@ -324,7 +380,33 @@ func (FREx *FREX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHa
 		makerDirtyFilledAmount              map[string]*big.Int
 		err                                 error
 	)
-	db := FREx.GetMongoDB()
+	if takerOrderInTx.IsBatchOrder() {
+		// the orders of a batch order are synced one by one
+		match := tradingstate.OrderMatch{
+			TxHash:         txHash,
+			Order:          takerOrderInTx,
+			MatchingResult: tradingstate.MatchingResult{Trades: trades, Rejects: rejectedOrders},
+		}
+		for _, item := range splitBatchOrder(match) {
+			if err := FREx.SyncDataToSDKNode(item.Order, txHash, txMatchTime, statedb, item.Trades, item.Rejects, dirtyOrderCount); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	db := FREx.GetSDKDB()
+	// trigger orders activated by the taker order are synced as takers of their own trades
+	trades, triggeredHashes, triggeredTrades := splitTriggeredTrades(takerOrderInTx.Hash, trades)
+	for _, hash := range triggeredHashes {
+		val, err := db.GetObject(hash, &tradingstate.OrderItem{})
+		if err != nil || val == nil {
+			log.Error("SDKNode: triggered order not found", "hash", hash.Hex(), "err", err)
+			continue
+		}
+		if err := FREx.SyncDataToSDKNode(val.(*tradingstate.OrderItem), txHash, txMatchTime, statedb, triggeredTrades[hash], nil, dirtyOrderCount); err != nil {
+			return err
+		}
+	}
 	db.InitBulk()
 	if takerOrderInTx.Status == tradingstate.OrderStatusCancelled && len(rejectedOrders) > 0 {
 		// cancel order is rejected -> nothing change
@@ -371,6 +453,11 @@ func (FREx *FREX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHa
 
 	// 2. put trades to db and update status to FILLED
 	log.Debug("Got trades", "number", len(trades), "txhash", txHash.Hex())
+	// an activated trigger order is filled as the market or limit order it became
+	takerOrderType := updatedTakerOrder.Type
+	if len(trades) > 0 {
+		takerOrderType = updatedTakerOrder.ActivatedType()
+	}
 	makerDirtyFilledAmount = make(map[string]*big.Int)
 	for _, trade := range trades {
 		// 2.a. put to trades
@@ -416,6 +503,9 @@ func (FREx *FREX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHa
 		if err := db.PutObject(tradeRecord.Hash, tradeRecord); err != nil {
 			return fmt.Errorf("SDKNode: failed to store tradeRecord %s", err.Error())
 		}
+		if err := FREx.updateCandles(db, tradeRecord); err != nil {
+			return fmt.Errorf("SDKNode: failed to update candles %s", err.Error())
+		}
 
 		// 2.b. update status and filledAmount
 		filledAmount := quantity
@@ -431,7 +521,7 @@ func (FREx *FREX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHa
 		//updatedTakerOrder = FREx.updateMatchedOrder(updatedTakerOrder, filledAmount, txMatchTime, txHash)
 		//  update filledAmount, status of takerOrder
 		updatedTakerOrder.FilledAmount = new(big.Int).Add(updatedTakerOrder.FilledAmount, filledAmount)
-		if updatedTakerOrder.FilledAmount.Cmp(updatedTakerOrder.Quantity) < 0 && updatedTakerOrder.Type == tradingstate.Limit {
+		if updatedTakerOrder.FilledAmount.Cmp(updatedTakerOrder.Quantity) < 0 && takerOrderType == tradingstate.Limit {
 			updatedTakerOrder.Status = tradingstate.OrderStatusPartialFilled
 		} else {
 			updatedTakerOrder.Status = tradingstate.OrderStatusFilled
@@ -441,7 +531,7 @@ func (FREx *FREX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHa
 	// for Market orders
 	// filledAmount > 0 : FILLED
 	// otherwise: REJECTED
-	if updatedTakerOrder.Type == tradingstate.Market {
+	if takerOrderType == tradingstate.Market {
 		if updatedTakerOrder.FilledAmount.Sign() > 0 {
 			updatedTakerOrder.Status = tradingstate.OrderStatusFilled
 		} else {
@@ -623,7 +713,7 @@ func (FREx *FREX) UpdateOrderCache(baseToken, quoteToken common.Address, orderHa
 }
 
 func (FREx *FREX) RollbackReorgTxMatch(txhash common.Hash) error {
-	db := FREx.GetMongoDB()
+	db := FREx.GetSDKDB()
 	db.InitBulk()
 
 	items := db.GetListItemByTxHash(txhash, &tradingstate.OrderItem{})
@@ -657,10 +747,17 @@ func (FREx *FREX) RollbackReorgTxMatch(txhash common.Hash) error {
 			}
 		}
 	}
+	var trades []*tradingstate.Trade
+	if items := db.GetListItemByTxHash(txhash, &tradingstate.Trade{}); items != nil {
+		trades = items.([]*tradingstate.Trade)
+	}
 	log.Debug("FREx reorg: DeleteTradeByTxHash", "txhash", txhash.Hex())
 	db.DeleteItemByTxHash(txhash, &tradingstate.Trade{})
 	if err := db.CommitBulk(); err != nil {
 		return fmt.Errorf("failed to RollbackTradingData. %v", err)
 	}
+	if err := FREx.rollbackCandles(db, trades); err != nil {
+		return fmt.Errorf("failed to rollback candles. %v", err)
+	}
 	return nil
 }
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/rpc"
 )
 
 const (
@@ -40,3 +44,131 @@ func NewPublicFREXAPI(t *FREX) *PublicFREXAPI {
 func (api *PublicFREXAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetCandles returns the OHLCV candles of a pair for an interval (1m, 5m, 1h
+// or 1d) opened between the from and to unix timestamps. Only SDK nodes keep
+// the candles.
+func (api *PublicFREXAPI) GetCandles(ctx context.Context, baseToken, quoteToken common.Address, interval string, from, to uint64) ([]*tradingstate.Candle, error) {
+	return api.t.GetCandles(baseToken, quoteToken, interval, time.Unix(int64(from), 0), time.Unix(int64(to), 0))
+}
+
+// GetTicker returns the price change and volume of a pair in the last 24
+// hours. Only SDK nodes keep the candles it is computed from.
+func (api *PublicFREXAPI) GetTicker(ctx context.Context, baseToken, quoteToken common.Address) (*Ticker, error) {
+	return api.t.GetTicker(baseToken, quoteToken, time.Now())
+}
+
+// OrderBook creates a subscription that fires with the price levels of an
+// order book changed by each new block.
+func (api *PublicFREXAPI) OrderBook(ctx context.Context, baseToken, quoteToken common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan OrderBookEvent)
+		eventsSub := api.t.SubscribeOrderBookEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				if ev.BaseToken == baseToken && ev.QuoteToken == quoteToken {
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Trades creates a subscription that fires with the trades of a pair executed
+// by each new block. Trades of blocks dropped by a reorg are sent again with
+// removed set.
+func (api *PublicFREXAPI) Trades(ctx context.Context, baseToken, quoteToken common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan TradeEvent)
+		eventsSub := api.t.SubscribeTradeEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				var trades []*tradingstate.Trade
+				for _, trade := range ev.Trades {
+					if trade.BaseToken == baseToken && trade.QuoteToken == quoteToken {
+						trades = append(trades, trade)
+					}
+				}
+				if len(trades) > 0 {
+					ev.Trades = trades
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Orders creates a subscription that fires with the status changes of the
+// orders of a user in each new block. Changes of blocks dropped by a reorg
+// are sent again with removed set.
+func (api *PublicFREXAPI) Orders(ctx context.Context, userAddress common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan OrderEvent)
+		eventsSub := api.t.SubscribeOrderEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				var orders []*OrderStatus
+				for _, order := range ev.Orders {
+					if order.UserAddress == userAddress {
+						orders = append(orders, order)
+					}
+				}
+				if len(orders) > 0 {
+					ev.Orders = orders
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -0,0 +1,157 @@
+package FREx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/log"
+)
+
+// batchOrderResult is the order id and extra data an order of a batch order
+// got when the batch was applied. They are recorded in the extra data of the
+// batch order for the SDK nodes.
+type batchOrderResult struct {
+	OrderID   uint64
+	ExtraData string
+}
+
+// processBatchOrder applies the orders of a batch order in order. The batch is
+// atomic: if an order fails or is rejected, including by its time in force or
+// self-trade prevention mode, the state is reverted and the batch order is
+// rejected as a whole. The makers rejected while matching don't fail it.
+func (FREx *FREX) processBatchOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem) {
+	var (
+		trades  []map[string]string
+		rejects []*tradingstate.OrderItem
+		results []batchOrderResult
+	)
+	FRExSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+	for _, item := range order.BatchOrders() {
+		newTrades, newRejects, err := FREx.applyBatchItem(header, coinbase, chain, statedb, tradingStateDB, orderBook, item)
+		if err == nil && containsOrder(newRejects, item) {
+			err = fmt.Errorf("order rejected. Hash: %s", item.Hash.Hex())
+		}
+		if err != nil {
+			log.Debug("Reject batch order", "hash", order.Hash.Hex(), "order", item.Hash.Hex(), "err", err)
+			tradingStateDB.RevertToSnapshot(FRExSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			return nil, []*tradingstate.OrderItem{order}
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		results = append(results, batchOrderResult{OrderID: item.OrderID, ExtraData: item.ExtraData})
+	}
+	extraData, _ := json.Marshal(results)
+	order.ExtraData = string(extraData)
+
+	if chain.Config().IsTIPFREXTriggerOrder(header.Number) {
+		triggeredTrades, triggeredRejects := FREx.processTriggeredOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook)
+		trades = append(trades, triggeredTrades...)
+		rejects = append(rejects, triggeredRejects...)
+	}
+	return trades, rejects
+}
+
+// applyBatchItem cancels or places an order of a batch order. The order an
+// amend operation replaces must be on the side of the replacing order.
+func (FREx *FREX) applyBatchItem(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, item *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	if item.Status == tradingstate.OrderStatusCancelled {
+		originOrder := tradingStateDB.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(item.OrderID)))
+		if item.Side != "" && originOrder != tradingstate.EmptyOrder && originOrder.Side != item.Side {
+			return nil, nil, fmt.Errorf("order side mismatch. OrderId: %v. Got: %s. Expect: %s", item.OrderID, item.Side, originOrder.Side)
+		}
+		err, reject := FREx.ProcessCancelOrder(header, tradingStateDB, statedb, chain, coinbase, orderBook, item)
+		if err == nil && reject {
+			err = fmt.Errorf("cancel order rejected. OrderId: %v", item.OrderID)
+		}
+		return nil, nil, err
+	}
+	if item.IsExpired(header.Time.Uint64()) {
+		return nil, nil, fmt.Errorf("order expired. ExpireAt: %v", item.ExpireAt)
+	}
+	if common.BigToHash(item.Price).Big().Cmp(item.Price) != 0 || common.BigToHash(item.Quantity).Big().Cmp(item.Quantity) != 0 {
+		return nil, nil, fmt.Errorf("order price or quantity invalid. Price: %v. Quantity: %v", item.Price, item.Quantity)
+	}
+	return FREx.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, item)
+}
+
+// splitBatchOrder splits the matching result of a batch order into the results
+// of its orders. The orders of a rejected batch are all rejected. The trades
+// and rejects which don't belong to an order of the batch, those of the
+// triggered orders and the rejected makers, go with its last limit order.
+func splitBatchOrder(match tradingstate.OrderMatch) []tradingstate.OrderMatch {
+	var (
+		order   = match.Order
+		orders  = order.BatchOrders()
+		results []batchOrderResult
+		matches = make([]tradingstate.OrderMatch, len(orders))
+		placed  = make(map[common.Hash]int)
+		last    = -1
+		failed  = false
+	)
+	if order.ExtraData != "" {
+		if err := json.Unmarshal([]byte(order.ExtraData), &results); err != nil || len(results) != len(orders) {
+			log.Debug("Invalid batch order results", "hash", order.Hash.Hex(), "extraData", order.ExtraData, "err", err)
+			results = nil
+		}
+	}
+	for _, reject := range match.Rejects {
+		if reject.Hash == order.Hash {
+			failed = true
+		}
+	}
+	for i, item := range orders {
+		if results != nil {
+			item.OrderID = results[i].OrderID
+			item.ExtraData = results[i].ExtraData
+		}
+		matches[i] = tradingstate.OrderMatch{TxHash: match.TxHash, Order: item}
+		if failed {
+			matches[i].Rejects = []*tradingstate.OrderItem{item}
+			continue
+		}
+		if item.Status != tradingstate.OrderStatusCancelled {
+			placed[item.Hash] = i
+			last = i
+		}
+	}
+	if failed || last < 0 {
+		return matches
+	}
+	for _, trade := range match.Trades {
+		i, ok := placed[common.HexToHash(trade[tradingstate.TradeTakerOrderHash])]
+		if trade == nil || !ok {
+			i = last
+		}
+		matches[i].Trades = append(matches[i].Trades, trade)
+	}
+	for _, reject := range match.Rejects {
+		i, ok := placed[reject.Hash]
+		if !ok {
+			i = last
+		}
+		matches[i].Rejects = append(matches[i].Rejects, reject)
+	}
+	return matches
+}
+
+// expandBatchOrders replaces the batch orders of matched orders by their
+// orders.
+func expandBatchOrders(matches []tradingstate.OrderMatch) []tradingstate.OrderMatch {
+	expanded := make([]tradingstate.OrderMatch, 0, len(matches))
+	for _, match := range matches {
+		if match.Order.IsBatchOrder() {
+			expanded = append(expanded, splitBatchOrder(match)...)
+			continue
+		}
+		expanded = append(expanded, match)
+	}
+	return expanded
+}
@@ -0,0 +1,196 @@
+package FREx
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/params"
+)
+
+// testChain is the chain context of the orders applied by the tests.
+type testChain struct {
+	config *params.ChainConfig
+}
+
+func (c *testChain) Engine() consensus.Engine                    { return nil }
+func (c *testChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c *testChain) CurrentHeader() *types.Header                { return nil }
+func (c *testChain) Config() *params.ChainConfig                 { return c.config }
+
+// registerRelayer registers the relayer with a single pair and a deposit
+// covering the fee of one order.
+func registerRelayer(statedb *state.StateDB, relayer, baseToken, quoteToken common.Address) {
+	registration := common.HexToAddress(common.RelayerRegistrationSMC)
+	relayerLoc := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	setRelayerState := func(field string, value common.Hash) common.Hash {
+		loc := common.BigToHash(new(big.Int).Add(relayerLoc, tradingstate.RelayerStructMappingSlot[field]))
+		statedb.SetState(registration, loc, value)
+		return loc
+	}
+	deposit := new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, common.Big1))
+	setRelayerState("_deposit", common.BigToHash(deposit))
+	fromTokens := setRelayerState("_fromTokens", common.BigToHash(common.Big1))
+	statedb.SetState(registration, state.GetLocDynamicArrAtElement(fromTokens, 0, 1), baseToken.Hash())
+	toTokens := setRelayerState("_toTokens", common.BigToHash(common.Big1))
+	statedb.SetState(registration, state.GetLocDynamicArrAtElement(toTokens, 0, 1), quoteToken.Hash())
+}
+
+// Tests that the matching result of a batch order is split between its orders,
+// the trades and rejects of other orders going with its last limit order, and
+// that all the orders of a rejected batch are rejected.
+func TestSplitBatchOrder(t *testing.T) {
+	ops := types.BatchOrderOps{
+		{Action: types.OrderBatchNew, Side: tradingstate.Bid, Quantity: big.NewInt(5), Price: big.NewInt(10)},
+		{Action: types.OrderBatchCancel, OrderID: 7, OrderHash: common.HexToHash("0x7")},
+		{Action: types.OrderBatchAmend, OrderID: 8, OrderHash: common.HexToHash("0x8"), Side: tradingstate.Ask, Quantity: big.NewInt(3), Price: big.NewInt(12)},
+	}
+	batch := &tradingstate.OrderItem{
+		Nonce:       big.NewInt(1),
+		UserAddress: common.HexToAddress("0x3"),
+		Status:      tradingstate.OrderNew,
+		Type:        tradingstate.BatchOrder,
+		Hash:        common.HexToHash("0x20"),
+		Batch:       &ops,
+		ExtraData:   `[{"OrderID":21},{"OrderID":7,"ExtraData":"fee"},{"OrderID":8},{"OrderID":22}]`,
+	}
+	orders := batch.BatchOrders()
+	var (
+		bidHash       = orders[0].Hash
+		askHash       = orders[3].Hash
+		triggeredHash = common.HexToHash("0x30")
+		makerReject   = &tradingstate.OrderItem{Hash: common.HexToHash("0x31")}
+	)
+	match := tradingstate.OrderMatch{
+		Order: batch,
+		MatchingResult: tradingstate.MatchingResult{
+			Trades: []map[string]string{
+				{tradingstate.TradeTakerOrderHash: bidHash.Hex()},
+				{tradingstate.TradeTakerOrderHash: triggeredHash.Hex()},
+			},
+			Rejects: []*tradingstate.OrderItem{{Hash: askHash}, makerReject},
+		},
+	}
+	matches := splitBatchOrder(match)
+	if len(matches) != 4 {
+		t.Fatalf("batch orders mismatch: have %d, want 4", len(matches))
+	}
+	want := []struct {
+		orderID uint64
+		trades  int
+		rejects int
+	}{
+		{21, 1, 0}, {7, 0, 0}, {8, 0, 0}, {22, 1, 2},
+	}
+	for i, w := range want {
+		if m := matches[i]; m.Order.OrderID != w.orderID || len(m.Trades) != w.trades || len(m.Rejects) != w.rejects {
+			t.Errorf("order %d mismatch: have id %d, %d trades, %d rejects, want id %d, %d trades, %d rejects", i, m.Order.OrderID, len(m.Trades), len(m.Rejects), w.orderID, w.trades, w.rejects)
+		}
+	}
+	if matches[1].Order.ExtraData != "fee" {
+		t.Errorf("cancel order extra data mismatch: have %q, want %q", matches[1].Order.ExtraData, "fee")
+	}
+
+	// a rejected batch order rejects all its orders
+	match.Rejects = []*tradingstate.OrderItem{batch}
+	match.Trades = nil
+	for i, m := range splitBatchOrder(match) {
+		if len(m.Rejects) != 1 || m.Rejects[0] != m.Order {
+			t.Errorf("order %d of rejected batch not rejected", i)
+		}
+	}
+}
+
+// Tests that a batch order is applied atomically: when one of its orders is
+// rejected, the orders placed before it are reverted and the batch order is
+// rejected.
+func TestApplyBatchOrderAtomic(t *testing.T) {
+	var (
+		key, _     = crypto.GenerateKey()
+		user       = crypto.PubkeyToAddress(key.PublicKey)
+		relayer    = common.HexToAddress("0x0000000000000000000000000000000000000042")
+		baseToken  = common.HexToAddress("0x0000000000000000000000000000000000000043")
+		quoteToken = common.HexToAddress(common.FRENativeAddress)
+		orderBook  = tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		chain      = &testChain{config: params.TestChainConfig}
+		header     = &types.Header{Number: new(big.Int).Set(params.MainnetForks().TIPFREXBatchOrderBlock), Time: big.NewInt(1000)}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	registerRelayer(statedb, relayer, baseToken, quoteToken)
+
+	nonce := uint64(0)
+	newBatchOrder := func(ops types.BatchOrderOps) *tradingstate.OrderItem {
+		tx := types.NewOrderTransaction(nonce, new(big.Int), new(big.Int), relayer, user, baseToken, quoteToken, tradingstate.OrderNew, "", tradingstate.BatchOrder, common.Hash{}, 0)
+		tx.SetBatch(ops)
+		signer := types.OrderTxSigner{}
+		tx.SetOrderHash(signer.Hash(tx))
+		tx, err := types.OrderSignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign batch order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		nonce++
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(tx.Nonce()),
+			Quantity:        tx.Quantity(),
+			Price:           tx.Price(),
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Type:            tradingstate.BatchOrder,
+			Hash:            tx.OrderHash(),
+			Batch:           &ops,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	FREx := &FREX{}
+
+	// The post only ask would take the liquidity of the bid placed before it
+	order := newBatchOrder(types.BatchOrderOps{
+		{Action: types.OrderBatchNew, Side: tradingstate.Bid, Quantity: big.NewInt(5), Price: big.NewInt(10)},
+		{Action: types.OrderBatchNew, Side: tradingstate.Ask, Quantity: big.NewInt(5), Price: big.NewInt(10), TimeInForce: tradingstate.PostOnly},
+	})
+	_, rejects, err := FREx.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order)
+	if err != nil {
+		t.Fatalf("failed to apply batch order: %v", err)
+	}
+	if len(rejects) != 1 || rejects[0] != order {
+		t.Fatalf("batch order not rejected: have %d rejects", len(rejects))
+	}
+	if bid, _ := tradingStateDb.GetBestBidPrice(orderBook); bid.Sign() != 0 {
+		t.Errorf("bid of the rejected batch order not reverted: best bid %v", bid)
+	}
+	if id := tradingStateDb.GetNonce(orderBook); id != 0 {
+		t.Errorf("order id of the rejected batch order not reverted: have %d, want 0", id)
+	}
+	if have := tradingStateDb.GetNonce(user.Hash()); have != nonce {
+		t.Errorf("user nonce mismatch: have %d, want %d", have, nonce)
+	}
+
+	// Without the crossing price both orders are placed
+	order = newBatchOrder(types.BatchOrderOps{
+		{Action: types.OrderBatchNew, Side: tradingstate.Bid, Quantity: big.NewInt(5), Price: big.NewInt(9)},
+		{Action: types.OrderBatchNew, Side: tradingstate.Ask, Quantity: big.NewInt(5), Price: big.NewInt(12), TimeInForce: tradingstate.PostOnly},
+	})
+	if _, rejects, err = FREx.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order); err != nil || len(rejects) != 0 {
+		t.Fatalf("batch order rejected: %d rejects, err %v", len(rejects), err)
+	}
+	bid, _ := tradingStateDb.GetBestBidPrice(orderBook)
+	ask, _ := tradingStateDb.GetBestAskPrice(orderBook)
+	if bid.Cmp(big.NewInt(9)) != 0 || ask.Cmp(big.NewInt(12)) != 0 {
+		t.Errorf("book mismatch: have bid %v and ask %v, want 9 and 12", bid, ask)
+	}
+	if id := tradingStateDb.GetNonce(orderBook); id != 2 {
+		t.Errorf("order id mismatch: have %d, want 2", id)
+	}
+}
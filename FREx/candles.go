@@ -0,0 +1,176 @@
+package FREx
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExDAO"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/log"
+)
+
+const maxCandlesPerQuery = 1000 // maximum number of candles returned by a query
+
+var (
+	ErrNotSDKNode         = errors.New("market data is only available on SDK nodes")
+	ErrUnknownInterval    = errors.New("unknown candle interval")
+	ErrInvalidCandleRange = errors.New("invalid candle range")
+)
+
+// Ticker is the summary of the trades of a pair in the last 24 hours.
+type Ticker struct {
+	BaseToken   common.Address `json:"baseToken"`
+	QuoteToken  common.Address `json:"quoteToken"`
+	Open        *big.Int       `json:"open"`
+	High        *big.Int       `json:"high"`
+	Low         *big.Int       `json:"low"`
+	Close       *big.Int       `json:"close"`
+	Change      *big.Int       `json:"change"` // close - open
+	Volume      *big.Int       `json:"volume"`
+	QuoteVolume *big.Int       `json:"quoteVolume"`
+	Count       uint64         `json:"count"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+}
+
+// updateCandles adds a trade stored by the SDK node to the candles of its
+// pair.
+func (FREx *FREX) updateCandles(db FRExDAO.FREXDAO, trade *tradingstate.Trade) error {
+	quoteQuantity := FREx.tradeQuoteQuantity(trade)
+	for interval := range tradingstate.CandleIntervals {
+		candle := tradingstate.NewCandle(trade.BaseToken, trade.QuoteToken, interval, trade.CreatedAt)
+		if val, err := db.GetObject(candle.Hash, &tradingstate.Candle{}); err == nil && val != nil {
+			candle = val.(*tradingstate.Candle)
+		}
+		candle.AddTrade(trade.PricePoint, trade.Amount, quoteQuantity)
+		if err := db.PutObject(candle.Hash, candle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackCandles rebuilds the candles the given trades belonged to from the
+// trades left in the SDK node. It must be called once the trades are deleted.
+func (FREx *FREX) rollbackCandles(db FRExDAO.FREXDAO, trades []*tradingstate.Trade) error {
+	dirty := make(map[common.Hash]*tradingstate.Candle)
+	for _, trade := range trades {
+		for interval := range tradingstate.CandleIntervals {
+			candle := tradingstate.NewCandle(trade.BaseToken, trade.QuoteToken, interval, trade.CreatedAt)
+			dirty[candle.Hash] = candle
+		}
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+	db.InitBulk()
+	for hash, candle := range dirty {
+		closeTime := candle.OpenTime.Add(tradingstate.CandleIntervals[candle.Interval])
+		items := db.GetListItemByPair(candle.BaseToken, candle.QuoteToken, candle.OpenTime, closeTime, &tradingstate.Trade{})
+		if items != nil {
+			for _, trade := range items.([]*tradingstate.Trade) {
+				candle.AddTrade(trade.PricePoint, trade.Amount, FREx.tradeQuoteQuantity(trade))
+			}
+		}
+		if candle.Count == 0 {
+			log.Debug("FREx reorg: remove empty candle", "baseToken", candle.BaseToken.Hex(), "quoteToken", candle.QuoteToken.Hex(), "interval", candle.Interval, "openTime", candle.OpenTime)
+			if err := db.DeleteObject(hash, candle); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := db.PutObject(hash, candle); err != nil {
+			return err
+		}
+	}
+	return db.CommitBulk()
+}
+
+// tradeQuoteQuantity returns the quote token quantity of a trade, the base
+// token decimal is known as the trade was matched by this node.
+func (FREx *FREX) tradeQuoteQuantity(trade *tradingstate.Trade) *big.Int {
+	decimal := common.BasePrice
+	if trade.BaseToken.String() != common.FRENativeAddress {
+		cached, ok := FREx.tokenDecimalCache.Get(trade.BaseToken)
+		if !ok {
+			log.Warn("SDKNode: unknown base token decimal, quote volume not counted", "baseToken", trade.BaseToken.Hex(), "trade", trade.Hash.Hex())
+			return new(big.Int)
+		}
+		decimal = cached.(*big.Int)
+	}
+	return tradingstate.Div(tradingstate.Mul(trade.Amount, trade.PricePoint), decimal)
+}
+
+// GetCandles returns the candles of a pair opened in [from, to), at most
+// maxCandlesPerQuery candles are returned starting from the oldest one.
+func (FREx *FREX) GetCandles(baseToken, quoteToken common.Address, interval string, from, to time.Time) ([]*tradingstate.Candle, error) {
+	if !FREx.IsSDKNode() {
+		return nil, ErrNotSDKNode
+	}
+	duration, ok := tradingstate.CandleIntervals[interval]
+	if !ok {
+		return nil, ErrUnknownInterval
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidCandleRange
+	}
+	from = tradingstate.CandleOpenTime(interval, from)
+	if limit := from.Add(maxCandlesPerQuery * duration); to.After(limit) {
+		to = limit
+	}
+	items := FREx.GetSDKDB().GetListItemByPair(baseToken, quoteToken, from, to, &tradingstate.Candle{Interval: interval})
+	if items == nil {
+		return []*tradingstate.Candle{}, nil
+	}
+	return items.([]*tradingstate.Candle), nil
+}
+
+// GetTicker returns the summary of the trades of a pair in the 24 hours
+// before the given time, aggregated from the 1m candles.
+func (FREx *FREX) GetTicker(baseToken, quoteToken common.Address, now time.Time) (*Ticker, error) {
+	if !FREx.IsSDKNode() {
+		return nil, ErrNotSDKNode
+	}
+	ticker := &Ticker{
+		BaseToken:   baseToken,
+		QuoteToken:  quoteToken,
+		Volume:      new(big.Int),
+		QuoteVolume: new(big.Int),
+		From:        now.UTC().Add(-24 * time.Hour),
+		To:          now.UTC(),
+	}
+	items := FREx.GetSDKDB().GetListItemByPair(baseToken, quoteToken, ticker.From, ticker.To, &tradingstate.Candle{Interval: "1m"})
+	if items == nil {
+		return ticker, nil
+	}
+	for _, candle := range items.([]*tradingstate.Candle) {
+		addCandleToTicker(ticker, candle)
+	}
+	return ticker, nil
+}
+
+// addCandleToTicker adds a candle to a ticker, the candles must be added in
+// open time order.
+func addCandleToTicker(ticker *Ticker, candle *tradingstate.Candle) {
+	if candle.Count == 0 {
+		return
+	}
+	if ticker.Count == 0 {
+		ticker.Open = candle.Open
+		ticker.High = candle.High
+		ticker.Low = candle.Low
+	}
+	if candle.High.Cmp(ticker.High) > 0 {
+		ticker.High = candle.High
+	}
+	if candle.Low.Cmp(ticker.Low) < 0 {
+		ticker.Low = candle.Low
+	}
+	ticker.Close = candle.Close
+	ticker.Change = tradingstate.Sub(ticker.Close, ticker.Open)
+	ticker.Volume = tradingstate.Add(ticker.Volume, candle.Volume)
+	ticker.QuoteVolume = tradingstate.Add(ticker.QuoteVolume, candle.QuoteVolume)
+	ticker.Count += candle.Count
+}
@@ -0,0 +1,321 @@
+package FREx
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/event"
+	"github.com/FRECNET/log"
+)
+
+// PriceLevel is the volume resting at a price of an order book.
+type PriceLevel struct {
+	Price  *big.Int `json:"price"`
+	Volume *big.Int `json:"volume"`
+}
+
+// OrderBookEvent is posted when a block changes price levels of an order book.
+// The volumes are read at the chain head, a zero volume means the level is
+// now empty.
+type OrderBookEvent struct {
+	BaseToken   common.Address `json:"baseToken"`
+	QuoteToken  common.Address `json:"quoteToken"`
+	Bids        []PriceLevel   `json:"bids"`
+	Asks        []PriceLevel   `json:"asks"`
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+}
+
+// TradeEvent is posted for the trades executed by a block. Removed is set when
+// the block left the canonical chain in a reorg.
+type TradeEvent struct {
+	Trades      []*tradingstate.Trade `json:"trades"`
+	BlockNumber uint64                `json:"blockNumber"`
+	BlockHash   common.Hash           `json:"blockHash"`
+	Removed     bool                  `json:"removed"`
+}
+
+// OrderStatus is the status an order reached in a trading transaction.
+type OrderStatus struct {
+	Hash            common.Hash    `json:"hash"`
+	UserAddress     common.Address `json:"userAddress"`
+	ExchangeAddress common.Address `json:"exchangeAddress"`
+	BaseToken       common.Address `json:"baseToken"`
+	QuoteToken      common.Address `json:"quoteToken"`
+	Side            string         `json:"side"`
+	Status          string         `json:"status"`
+	FilledAmount    *big.Int       `json:"filledAmount"` // amount filled by the transaction
+	TxHash          common.Hash    `json:"txHash"`
+}
+
+// OrderEvent is posted for the orders whose status changed in a block. Removed
+// is set when the block left the canonical chain in a reorg.
+type OrderEvent struct {
+	Orders      []*OrderStatus `json:"orders"`
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Removed     bool           `json:"removed"`
+}
+
+// SubscribeOrderBookEvent registers a subscription of OrderBookEvent.
+func (FREx *FREX) SubscribeOrderBookEvent(ch chan<- OrderBookEvent) event.Subscription {
+	return FREx.scope.Track(FREx.orderBookFeed.Subscribe(ch))
+}
+
+// SubscribeTradeEvent registers a subscription of TradeEvent.
+func (FREx *FREX) SubscribeTradeEvent(ch chan<- TradeEvent) event.Subscription {
+	return FREx.scope.Track(FREx.tradeFeed.Subscribe(ch))
+}
+
+// SubscribeOrderEvent registers a subscription of OrderEvent.
+func (FREx *FREX) SubscribeOrderEvent(ch chan<- OrderEvent) event.Subscription {
+	return FREx.scope.Track(FREx.orderFeed.Subscribe(ch))
+}
+
+// PostMatchingEvents posts the order book, trade and order events of the
+// orders matched by a block, as returned by ApplyOrder when the block was
+// processed. The order book volumes are read from the trading state of the
+// chain head, removed is set when the block left the canonical chain.
+func (FREx *FREX) PostMatchingEvents(block *types.Block, tradingState *tradingstate.TradingStateDB, matches []tradingstate.OrderMatch, removed bool) {
+	if len(matches) == 0 {
+		return
+	}
+	var (
+		trades      []*tradingstate.Trade
+		orders      []*OrderStatus
+		levels      = make(map[common.Hash]*orderBookLevels)
+		levelsOrder []common.Hash
+		matchTime   = time.Unix(block.Time().Int64(), 0).UTC()
+	)
+	touch := func(baseToken, quoteToken common.Address, side string, price *big.Int) {
+		if price == nil || price.Sign() <= 0 {
+			return
+		}
+		orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		if _, ok := levels[orderBook]; !ok {
+			levels[orderBook] = newOrderBookLevels(baseToken, quoteToken)
+			levelsOrder = append(levelsOrder, orderBook)
+		}
+		levels[orderBook].touch(side, price)
+	}
+	for _, match := range expandBatchOrders(matches) {
+		order := match.Order
+		if order.Status == tradingstate.OrderStatusCancelled {
+			if len(match.Rejects) > 0 {
+				// rejected cancel order, nothing changed
+				continue
+			}
+			touch(order.BaseToken, order.QuoteToken, order.Side, order.Price)
+			orders = append(orders, newOrderStatus(order, tradingstate.OrderStatusCancelled, new(big.Int), match.TxHash))
+			continue
+		}
+		if order.ActivatedType() == tradingstate.Limit {
+			touch(order.BaseToken, order.QuoteToken, order.Side, order.Price)
+		}
+		rejected := make(map[common.Hash]*tradingstate.OrderItem)
+		for _, reject := range match.Rejects {
+			rejected[reject.Hash] = reject
+		}
+		var (
+			filled     = make(map[common.Hash]*big.Int)
+			lastTrade  = make(map[common.Hash]map[string]string)
+			takerOrder []common.Hash
+		)
+		for _, trade := range match.Trades {
+			if trade == nil {
+				continue
+			}
+			record := newTradeRecord(trade, match.TxHash, matchTime)
+			if record == nil {
+				continue
+			}
+			trades = append(trades, record)
+			touch(record.BaseToken, record.QuoteToken, oppositeSide(record.TakerOrderSide), record.PricePoint)
+
+			// the makers are updated as soon as they trade
+			makerStatus := tradingstate.OrderStatusPartialFilled
+			if tradingstate.ToBigInt(trade[tradingstate.TradeMakerRemaining]).Sign() == 0 {
+				makerStatus = tradingstate.OrderStatusFilled
+			}
+			orders = append(orders, &OrderStatus{
+				Hash:            record.MakerOrderHash,
+				UserAddress:     record.Maker,
+				ExchangeAddress: record.MakerExchange,
+				BaseToken:       record.BaseToken,
+				QuoteToken:      record.QuoteToken,
+				Side:            oppositeSide(record.TakerOrderSide),
+				Status:          makerStatus,
+				FilledAmount:    record.Amount,
+				TxHash:          match.TxHash,
+			})
+			// the takers, including the triggered orders, once all trades are known
+			if _, ok := filled[record.TakerOrderHash]; !ok {
+				filled[record.TakerOrderHash] = new(big.Int)
+				takerOrder = append(takerOrder, record.TakerOrderHash)
+			}
+			filled[record.TakerOrderHash].Add(filled[record.TakerOrderHash], record.Amount)
+			lastTrade[record.TakerOrderHash] = trade
+		}
+		for _, hash := range takerOrder {
+			trade := lastTrade[hash]
+			status := tradingstate.OrderStatusFilled
+			if _, ok := rejected[hash]; !ok && trade[tradingstate.TakerOrderType] != tradingstate.Market && tradingstate.ToBigInt(trade[tradingstate.TradeTakerRemaining]).Sign() > 0 {
+				status = tradingstate.OrderStatusPartialFilled
+			}
+			orders = append(orders, &OrderStatus{
+				Hash:            hash,
+				UserAddress:     common.HexToAddress(trade[tradingstate.TradeTaker]),
+				ExchangeAddress: common.HexToAddress(trade[tradingstate.TradeTakerExchange]),
+				BaseToken:       common.HexToAddress(trade[tradingstate.TradeBaseToken]),
+				QuoteToken:      common.HexToAddress(trade[tradingstate.TradeQuoteToken]),
+				Side:            trade[tradingstate.TradeTakerSide],
+				Status:          status,
+				FilledAmount:    filled[hash],
+				TxHash:          match.TxHash,
+			})
+		}
+		if _, ok := filled[order.Hash]; !ok {
+			if _, ok := rejected[order.Hash]; !ok {
+				// untraded market orders are dropped, other orders rest in the order book
+				status := tradingstate.OrderStatusOpen
+				if order.Type == tradingstate.Market {
+					status = tradingstate.OrderStatusRejected
+				}
+				orders = append(orders, newOrderStatus(order, status, new(big.Int), match.TxHash))
+			}
+		}
+		for _, reject := range match.Rejects {
+			if _, ok := filled[reject.Hash]; ok {
+				continue
+			}
+			if reject.Hash != order.Hash {
+				// rejected makers and expired orders leave the order book
+				touch(reject.BaseToken, reject.QuoteToken, reject.Side, reject.Price)
+			}
+			orders = append(orders, newOrderStatus(reject, tradingstate.OrderStatusRejected, new(big.Int), match.TxHash))
+		}
+	}
+	if len(trades) > 0 {
+		FREx.tradeFeed.Send(TradeEvent{Trades: trades, BlockNumber: block.NumberU64(), BlockHash: block.Hash(), Removed: removed})
+	}
+	if len(orders) > 0 {
+		FREx.orderFeed.Send(OrderEvent{Orders: orders, BlockNumber: block.NumberU64(), BlockHash: block.Hash(), Removed: removed})
+	}
+	if tradingState == nil {
+		return
+	}
+	for _, orderBook := range levelsOrder {
+		FREx.orderBookFeed.Send(levels[orderBook].event(tradingState, orderBook, block))
+	}
+}
+
+// orderBookLevels collects the price levels of an order book changed by a
+// block.
+type orderBookLevels struct {
+	baseToken  common.Address
+	quoteToken common.Address
+	bids       map[string]*big.Int
+	asks       map[string]*big.Int
+}
+
+func newOrderBookLevels(baseToken, quoteToken common.Address) *orderBookLevels {
+	return &orderBookLevels{
+		baseToken:  baseToken,
+		quoteToken: quoteToken,
+		bids:       make(map[string]*big.Int),
+		asks:       make(map[string]*big.Int),
+	}
+}
+
+func (l *orderBookLevels) touch(side string, price *big.Int) {
+	switch side {
+	case tradingstate.Bid:
+		l.bids[price.String()] = price
+	case tradingstate.Ask:
+		l.asks[price.String()] = price
+	}
+}
+
+// event reads the volumes of the changed price levels, bids are sorted by
+// descending price and asks by ascending price.
+func (l *orderBookLevels) event(tradingState *tradingstate.TradingStateDB, orderBook common.Hash, block *types.Block) OrderBookEvent {
+	readLevels := func(prices map[string]*big.Int, side string) []PriceLevel {
+		result := make([]PriceLevel, 0, len(prices))
+		for _, price := range prices {
+			result = append(result, PriceLevel{Price: price, Volume: tradingState.GetVolume(orderBook, price, side)})
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if side == tradingstate.Bid {
+				return result[i].Price.Cmp(result[j].Price) > 0
+			}
+			return result[i].Price.Cmp(result[j].Price) < 0
+		})
+		return result
+	}
+	return OrderBookEvent{
+		BaseToken:   l.baseToken,
+		QuoteToken:  l.quoteToken,
+		Bids:        readLevels(l.bids, tradingstate.Bid),
+		Asks:        readLevels(l.asks, tradingstate.Ask),
+		BlockNumber: block.NumberU64(),
+		BlockHash:   block.Hash(),
+	}
+}
+
+func newOrderStatus(order *tradingstate.OrderItem, status string, filledAmount *big.Int, txHash common.Hash) *OrderStatus {
+	return &OrderStatus{
+		Hash:            order.Hash,
+		UserAddress:     order.UserAddress,
+		ExchangeAddress: order.ExchangeAddress,
+		BaseToken:       order.BaseToken,
+		QuoteToken:      order.QuoteToken,
+		Side:            order.Side,
+		Status:          status,
+		FilledAmount:    filledAmount,
+		TxHash:          txHash,
+	}
+}
+
+// newTradeRecord converts a trade produced by ApplyOrder into a trade record.
+func newTradeRecord(trade map[string]string, txHash common.Hash, matchTime time.Time) *tradingstate.Trade {
+	quantity := tradingstate.ToBigInt(trade[tradingstate.TradeQuantity])
+	price := tradingstate.ToBigInt(trade[tradingstate.TradePrice])
+	if price.Sign() <= 0 || quantity.Sign() <= 0 {
+		log.Debug("Trade misses important information", "tradedPrice", price, "tradedQuantity", quantity)
+		return nil
+	}
+	record := &tradingstate.Trade{
+		Taker:          common.HexToAddress(trade[tradingstate.TradeTaker]),
+		Maker:          common.HexToAddress(trade[tradingstate.TradeMaker]),
+		BaseToken:      common.HexToAddress(trade[tradingstate.TradeBaseToken]),
+		QuoteToken:     common.HexToAddress(trade[tradingstate.TradeQuoteToken]),
+		MakerOrderHash: common.HexToHash(trade[tradingstate.TradeMakerOrderHash]),
+		TakerOrderHash: common.HexToHash(trade[tradingstate.TradeTakerOrderHash]),
+		MakerExchange:  common.HexToAddress(trade[tradingstate.TradeMakerExchange]),
+		TakerExchange:  common.HexToAddress(trade[tradingstate.TradeTakerExchange]),
+		TxHash:         txHash,
+		PricePoint:     price,
+		Amount:         quantity,
+		Status:         tradingstate.TradeStatusSuccess,
+		CreatedAt:      matchTime,
+		UpdatedAt:      matchTime,
+		TakerOrderSide: trade[tradingstate.TradeTakerSide],
+		TakerOrderType: trade[tradingstate.TakerOrderType],
+		MakerOrderType: trade[tradingstate.MakerOrderType],
+	}
+	record.MakeFee, _ = new(big.Int).SetString(trade[tradingstate.MakerFee], 10)
+	record.TakeFee, _ = new(big.Int).SetString(trade[tradingstate.TakerFee], 10)
+	record.Hash = record.ComputeHash()
+	return record
+}
+
+func oppositeSide(side string) string {
+	if side == tradingstate.Bid {
+		return tradingstate.Ask
+	}
+	return tradingstate.Bid
+}
@@ -0,0 +1,75 @@
+package FREx
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+)
+
+// Tests that the order statuses of a partially filled limit taker, a filled
+// maker and a rejected maker are posted along with the trade.
+func TestPostMatchingEvents(t *testing.T) {
+	FREx := &FREX{}
+	trades := make(chan TradeEvent, 1)
+	orders := make(chan OrderEvent, 1)
+	defer FREx.SubscribeTradeEvent(trades).Unsubscribe()
+	defer FREx.SubscribeOrderEvent(orders).Unsubscribe()
+
+	var (
+		baseToken   = common.HexToAddress("0x1")
+		quoteToken  = common.HexToAddress("0x2")
+		taker       = common.HexToAddress("0x3")
+		maker       = common.HexToAddress("0x4")
+		takerHash   = common.HexToHash("0x10")
+		makerHash   = common.HexToHash("0x11")
+		rejectHash  = common.HexToHash("0x12")
+		txHash      = common.HexToHash("0x20")
+		block       = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Time: big.NewInt(100)})
+		takerOrder  = &tradingstate.OrderItem{Hash: takerHash, UserAddress: taker, BaseToken: baseToken, QuoteToken: quoteToken, Side: tradingstate.Bid, Type: tradingstate.Limit, Price: big.NewInt(10), Quantity: big.NewInt(5), Status: tradingstate.OrderStatusOpen}
+		rejectOrder = &tradingstate.OrderItem{Hash: rejectHash, UserAddress: maker, BaseToken: baseToken, QuoteToken: quoteToken, Side: tradingstate.Ask, Type: tradingstate.Limit, Price: big.NewInt(9)}
+	)
+	match := tradingstate.OrderMatch{
+		TxHash: txHash,
+		Order:  takerOrder,
+		MatchingResult: tradingstate.MatchingResult{
+			Trades: []map[string]string{{
+				tradingstate.TradeTakerOrderHash: takerHash.Hex(),
+				tradingstate.TradeMakerOrderHash: makerHash.Hex(),
+				tradingstate.TradeTaker:          taker.Hex(),
+				tradingstate.TradeMaker:          maker.Hex(),
+				tradingstate.TradeBaseToken:      baseToken.Hex(),
+				tradingstate.TradeQuoteToken:     quoteToken.Hex(),
+				tradingstate.TradeTakerSide:      tradingstate.Bid,
+				tradingstate.TakerOrderType:      tradingstate.Limit,
+				tradingstate.TradeQuantity:       "3",
+				tradingstate.TradePrice:          "10",
+				tradingstate.TradeMakerRemaining: "0",
+				tradingstate.TradeTakerRemaining: "2",
+			}},
+			Rejects: []*tradingstate.OrderItem{rejectOrder},
+		},
+	}
+	FREx.PostMatchingEvents(block, nil, []tradingstate.OrderMatch{match}, false)
+
+	tradeEvent := <-trades
+	if len(tradeEvent.Trades) != 1 || tradeEvent.Trades[0].Amount.Int64() != 3 || tradeEvent.Trades[0].TxHash != txHash {
+		t.Fatalf("trade event mismatch: %+v", tradeEvent)
+	}
+	want := map[common.Hash]string{
+		makerHash:  tradingstate.OrderStatusFilled,
+		takerHash:  tradingstate.OrderStatusPartialFilled,
+		rejectHash: tradingstate.OrderStatusRejected,
+	}
+	orderEvent := <-orders
+	if len(orderEvent.Orders) != len(want) {
+		t.Fatalf("order statuses mismatch: have %d, want %d", len(orderEvent.Orders), len(want))
+	}
+	for _, order := range orderEvent.Orders {
+		if order.Status != want[order.Hash] {
+			t.Errorf("order %x: status %s, want %s", order.Hash, order.Status, want[order.Hash])
+		}
+	}
+}
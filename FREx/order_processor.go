@@ -35,6 +35,7 @@ func (FREx *FREX) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		trades  []map[string]string
 		err     error
 	)
+	tradingStateDB.SetUserIndex(chain.Config().IsTIPFREXPortfolio(header.Number))
 	nonce := tradingStateDB.GetNonce(order.UserAddress.Hash())
 	log.Debug("ApplyOrder", "addr", order.UserAddress, "statenonce", nonce, "ordernonce", order.Nonce)
 	if big.NewInt(int64(nonce)).Cmp(order.Nonce) == -1 {
@@ -68,7 +69,36 @@ func (FREx *FREX) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 		return trades, rejects, nil
 	}
-	if order.Type != tradingstate.Market {
+	if order.IsTriggerOrder() && !chain.Config().IsTIPFREXTriggerOrder(header.Number) {
+		log.Debug("Reject trigger order before hardfork", "type", order.Type)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.TimeInForce != "" && !chain.Config().IsTIPFREXTimeInForce(header.Number) {
+		log.Debug("Reject time in force order before hardfork", "timeInForce", order.TimeInForce)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.SelfTradeMode != "" && !chain.Config().IsTIPFREXSelfTrade(header.Number) {
+		log.Debug("Reject self-trade prevention order before hardfork", "selfTradeMode", order.SelfTradeMode)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.IsBatchOrder() {
+		if !chain.Config().IsTIPFREXBatchOrder(header.Number) {
+			log.Debug("Reject batch order before hardfork", "hash", order.Hash.Hex())
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		trades, rejects = FREx.processBatchOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		return trades, rejects, nil
+	}
+	if order.IsExpired(header.Time.Uint64()) {
+		log.Debug("Reject expired order", "expireAt", order.ExpireAt, "blockTime", header.Time)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.ActivatedType() != tradingstate.Market {
 		if order.Price.Sign() == 0 || common.BigToHash(order.Price).Big().Cmp(order.Price) != 0 {
 			log.Debug("Reject order price invalid", "price", order.Price)
 			rejects = append(rejects, order)
@@ -82,9 +112,16 @@ func (FREx *FREX) ApplyOrder(header *types.Header, coinbase common.Address, chai
 	}
 	orderType := order.Type
 	// if we do not use auto-increment orderid, we must set price slot to avoid conflict
-	if orderType == tradingstate.Market {
+	if order.IsTriggerOrder() {
+		log.Debug("Process trigger order", "type", orderType, "side", order.Side, "quantity", order.Quantity, "triggerPrice", order.TriggerPrice)
+		if err := FREx.insertTriggerOrder(chain, statedb, tradingStateDB, orderBook, order); err != nil {
+			log.Debug("Reject trigger order", "err", err, "order", tradingstate.ToJSON(order))
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+	} else if orderType == tradingstate.Market {
 		log.Debug("Process maket order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = FREx.processMarketOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = FREx.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject market order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
@@ -92,24 +129,114 @@ func (FREx *FREX) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 	} else {
 		log.Debug("Process limit order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = FREx.processLimitOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = FREx.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject limit order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
 			rejects = append(rejects, order)
 		}
 	}
+	if chain.Config().IsTIPFREXTriggerOrder(header.Number) {
+		triggeredTrades, triggeredRejects := FREx.processTriggeredOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook)
+		trades = append(trades, triggeredTrades...)
+		rejects = append(rejects, triggeredRejects...)
+	}
 
 	return trades, rejects, nil
 }
 
+// insertTriggerOrder puts a stop or take profit order into the trigger trie of
+// the order book, it gets its order id on arrival like a limit order. The
+// relayer must afford the matching fee and the user hold the tokens the order
+// spends once activated.
+func (FREx *FREX) insertTriggerOrder(chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) error {
+	if err := tradingstate.CheckRelayerFee(order.ExchangeAddress, common.RelayerFee, statedb); err != nil {
+		return err
+	}
+	baseTokenDecimal, err := FREx.GetTokenDecimal(chain, statedb, order.BaseToken)
+	if err != nil || baseTokenDecimal.Sign() == 0 {
+		return fmt.Errorf("Fail to get tokenDecimal. Token: %v . Err: %v", order.BaseToken.String(), err)
+	}
+	quoteTokenDecimal, err := FREx.GetTokenDecimal(chain, statedb, order.QuoteToken)
+	if err != nil || quoteTokenDecimal.Sign() == 0 {
+		return fmt.Errorf("Fail to get tokenDecimal. Token: %v . Err: %v", order.QuoteToken.String(), err)
+	}
+	if err := tradingstate.VerifyTriggerOrderBalance(statedb, tradingStateDB, order, baseTokenDecimal, quoteTokenDecimal); err != nil {
+		return err
+	}
+	orderId := tradingStateDB.GetNonce(orderBook)
+	order.OrderID = orderId + 1
+	tradingStateDB.SetNonce(orderBook, orderId+1)
+	orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
+	tradingStateDB.InsertTriggerOrder(orderBook, orderIdHash, *order)
+	return nil
+}
+
+// processTriggeredOrders activates the trigger orders crossed by the last price
+// of the order book and matches them as market or limit orders. The trades of
+// an activated order move the last price again, so orders are activated until
+// the last price doesn't cross any trigger price.
+func (FREx *FREX) processTriggeredOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash) ([]map[string]string, []*tradingstate.OrderItem) {
+	var (
+		trades  []map[string]string
+		rejects []*tradingstate.OrderItem
+	)
+	for {
+		lastPrice := tradingStateDB.GetLastPrice(orderBook)
+		triggeredOrder := tradingStateDB.GetTriggeredOrder(orderBook, lastPrice)
+		if triggeredOrder == tradingstate.EmptyOrder {
+			break
+		}
+		order := &triggeredOrder
+		orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
+		if err := tradingStateDB.RemoveTriggerOrder(orderBook, orderIdHash); err != nil {
+			log.Error("Failed to remove triggered order", "orderbook", orderBook.Hex(), "orderId", order.OrderID, "err", err)
+			break
+		}
+		log.Debug("Process triggered order", "type", order.Type, "side", order.Side, "quantity", order.Quantity, "triggerPrice", order.TriggerPrice, "lastPrice", lastPrice)
+		if order.IsExpired(header.Time.Uint64()) {
+			log.Debug("Reject expired triggered order", "expireAt", order.ExpireAt, "blockTime", header.Time)
+			rejects = append(rejects, order)
+			continue
+		}
+		order.Type = order.ActivatedType()
+		FRExSnap := tradingStateDB.Snapshot()
+		dbSnap := statedb.Snapshot()
+		var (
+			newTrades  []map[string]string
+			newRejects []*tradingstate.OrderItem
+			err        error
+		)
+		if order.Type == tradingstate.Market {
+			newTrades, newRejects, err = FREx.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		} else {
+			newTrades, newRejects, err = FREx.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		}
+		if err != nil {
+			log.Debug("Reject triggered order", "err", err, "order", tradingstate.ToJSON(order))
+			tradingStateDB.RevertToSnapshot(FRExSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			rejects = append(rejects, order)
+			continue
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		if order.Type == tradingstate.Market && len(newTrades) == 0 {
+			// nothing to match the activated market order against
+			rejects = append(rejects, order)
+		}
+	}
+	return trades, rejects
+}
+
 // processMarketOrder : process the market order
-func (FREx *FREX) processMarketOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (FREx *FREX) processMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
 		rejects    []*tradingstate.OrderItem
 		newRejects []*tradingstate.OrderItem
+		expired    []*tradingstate.OrderItem
 		err        error
 	)
 	quantityToTrade := order.Quantity
@@ -117,28 +244,42 @@ func (FREx *FREX) processMarketOrder(coinbase common.Address, chain consensus.Ch
 	// speedup the comparison, do not assign because it is pointer
 	zero := tradingstate.Zero
 	if side == tradingstate.Bid {
+		if rejects, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Ask); err != nil {
+			return nil, nil, err
+		}
 		bestPrice, volume := tradingStateDB.GetBestAskPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
+			if expired, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Ask); err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, expired...)
 			bestPrice, volume = tradingStateDB.GetBestAskPrice(orderBook)
 			log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		}
 	} else {
+		if rejects, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Bid); err != nil {
+			return nil, nil, err
+		}
 		bestPrice, volume := tradingStateDB.GetBestBidPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
+			if expired, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Bid); err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, expired...)
 			bestPrice, volume = tradingStateDB.GetBestBidPrice(orderBook)
 			log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		}
@@ -148,33 +289,54 @@ func (FREx *FREX) processMarketOrder(coinbase common.Address, chain consensus.Ch
 
 // processLimitOrder : process the limit order, can change the quote
 // If not care for performance, we should make a copy of quote to prevent further reference problem
-func (FREx *FREX) processLimitOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (FREx *FREX) processLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
 		rejects    []*tradingstate.OrderItem
 		newRejects []*tradingstate.OrderItem
+		expired    []*tradingstate.OrderItem
 		err        error
 	)
 	quantityToTrade := order.Quantity
 	side := order.Side
 	price := order.Price
+	oppositeSide := tradingstate.Ask
+	if side == tradingstate.Ask {
+		oppositeSide = tradingstate.Bid
+	}
 
 	// speedup the comparison, do not assign because it is pointer
 	zero := tradingstate.Zero
 
+	// expired orders leave the top of the book before the post only check
+	if expired, err = removeExpiredOrders(header, tradingStateDB, orderBook, oppositeSide); err != nil {
+		return nil, nil, err
+	}
+	if order.TimeInForce == tradingstate.PostOnly && isTakingLiquidity(tradingStateDB, orderBook, order) {
+		log.Debug("Reject post only order taking liquidity", "side", side, "price", price)
+		return nil, append(expired, order), nil
+	}
+	// fill or kill orders are matched atomically
+	FRExSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+
 	if side == tradingstate.Bid {
 		minPrice, volume := tradingStateDB.GetBestAskPrice(orderBook)
 		log.Debug("processLimitOrder ", "side", side, "minPrice", minPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(minPrice) >= 0 && minPrice.Cmp(zero) > 0 {
 			log.Debug("Min price in asks tree", "price", minPrice.String())
-			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
 			log.Debug("New trade found", "newTrades", newTrades, "quantityToTrade", quantityToTrade)
+			if newRejects, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Ask); err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, newRejects...)
 			minPrice, volume = tradingStateDB.GetBestAskPrice(orderBook)
 			log.Debug("processLimitOrder ", "side", side, "minPrice", minPrice, "orderPrice", price, "volume", volume)
 		}
@@ -183,17 +345,44 @@ func (FREx *FREX) processLimitOrder(coinbase common.Address, chain consensus.Cha
 		log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(maxPrice) <= 0 && maxPrice.Cmp(zero) > 0 {
 			log.Debug("Max price in bids tree", "price", maxPrice.String())
-			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = FREx.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
 			log.Debug("New trade found", "newTrades", newTrades, "quantityToTrade", quantityToTrade)
+			if newRejects, err = removeExpiredOrders(header, tradingStateDB, orderBook, tradingstate.Bid); err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, newRejects...)
 			maxPrice, volume = tradingStateDB.GetBestBidPrice(orderBook)
 			log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		}
 	}
+	if order.TimeInForce == tradingstate.FillOrKill && (quantityToTrade.Cmp(zero) > 0 || containsOrder(rejects, order)) {
+		log.Debug("Kill fill or kill order not entirely filled", "quantity", order.Quantity, "remaining", quantityToTrade)
+		tradingStateDB.RevertToSnapshot(FRExSnap)
+		statedb.RevertToSnapshot(dbSnap)
+		// the expired makers met while matching stay out of the book
+		for _, reject := range rejects {
+			if reject != order && reject.IsExpired(header.Time.Uint64()) {
+				if err := tradingStateDB.CancelOrder(orderBook, reject); err != nil {
+					return nil, nil, err
+				}
+				expired = append(expired, reject)
+			}
+		}
+		return nil, append(expired, order), nil
+	}
+	rejects = append(expired, rejects...)
+	if order.TimeInForce == tradingstate.ImmediateOrCancel && quantityToTrade.Cmp(zero) > 0 {
+		log.Debug("Cancel unmatched part of immediate or cancel order", "quantity", order.Quantity, "remaining", quantityToTrade)
+		if !containsOrder(rejects, order) {
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
 	if quantityToTrade.Cmp(zero) > 0 {
 		orderId := tradingStateDB.GetNonce(orderBook)
 		order.OrderID = orderId + 1
@@ -206,8 +395,79 @@ func (FREx *FREX) processLimitOrder(coinbase common.Address, chain consensus.Cha
 	return trades, rejects, nil
 }
 
+// ProcessLiquidationOrder matches a sell order of liquidated lending collateral
+// against the bids of the order book, best price first, down to the limit price
+// of the order. The unfilled part is cancelled, the order never rests in the book.
+func (FREx *FREX) ProcessLiquidationOrder(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	order.Side = tradingstate.Ask
+	order.Type = tradingstate.Limit
+	order.TimeInForce = tradingstate.ImmediateOrCancel
+	tradingStateDB.SetUserIndex(chain.Config().IsTIPFREXPortfolio(header.Number))
+
+	FRExSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+	trades, rejects, err := FREx.processLimitOrder(header, header.Coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	if err != nil {
+		tradingStateDB.RevertToSnapshot(FRExSnap)
+		statedb.RevertToSnapshot(dbSnap)
+		return nil, nil, err
+	}
+	return trades, rejects, nil
+}
+
+// isTakingLiquidity returns whether a limit order would match the opposite
+// side of the order book.
+func isTakingLiquidity(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) bool {
+	if order.Side == tradingstate.Bid {
+		minPrice, _ := tradingStateDB.GetBestAskPrice(orderBook)
+		return minPrice.Sign() > 0 && order.Price.Cmp(minPrice) >= 0
+	}
+	maxPrice, _ := tradingStateDB.GetBestBidPrice(orderBook)
+	return maxPrice.Sign() > 0 && order.Price.Cmp(maxPrice) <= 0
+}
+
+// removeExpiredOrders removes the expired good till time orders on top of a
+// side of the order book, so that its best price is the one of a live order.
+// The removed orders are returned as rejects.
+func removeExpiredOrders(header *types.Header, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, side string) ([]*tradingstate.OrderItem, error) {
+	var rejects []*tradingstate.OrderItem
+	for {
+		var price *big.Int
+		if side == tradingstate.Ask {
+			price, _ = tradingStateDB.GetBestAskPrice(orderBook)
+		} else {
+			price, _ = tradingStateDB.GetBestBidPrice(orderBook)
+		}
+		if price.Sign() == 0 {
+			return rejects, nil
+		}
+		orderId, amount, err := tradingStateDB.GetBestOrderIdAndAmount(orderBook, price, side)
+		if err != nil || amount.Sign() == 0 {
+			return rejects, nil
+		}
+		oldestOrder := tradingStateDB.GetOrder(orderBook, orderId)
+		if !oldestOrder.IsExpired(header.Time.Uint64()) {
+			return rejects, nil
+		}
+		log.Debug("Remove expired order", "orderId", orderId, "expireAt", oldestOrder.ExpireAt, "blockTime", header.Time)
+		if err := tradingStateDB.CancelOrder(orderBook, &oldestOrder); err != nil {
+			return nil, err
+		}
+		rejects = append(rejects, &oldestOrder)
+	}
+}
+
+func containsOrder(orders []*tradingstate.OrderItem, order *tradingstate.OrderItem) bool {
+	for _, o := range orders {
+		if o == order {
+			return true
+		}
+	}
+	return false
+}
+
 // processOrderList : process the order list
-func (FREx *FREX) processOrderList(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
+func (FREx *FREX) processOrderList(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	quantityToTrade := tradingstate.CloneBigInt(quantityStillToTrade)
 	log.Debug("Process matching between order and orderlist", "quantityToTrade", quantityToTrade)
 	var (
@@ -225,6 +485,27 @@ func (FREx *FREX) processOrderList(coinbase common.Address, chain consensus.Chai
 		if oldestOrder.Quantity == nil || oldestOrder.Quantity.Sign() == 0 && amount.Sign() == 0 {
 			break
 		}
+		if oldestOrder.IsExpired(header.Time.Uint64()) {
+			// good till time orders leave the book once expired
+			log.Debug("Remove expired order", "orderId", orderId, "expireAt", oldestOrder.ExpireAt, "blockTime", header.Time)
+			rejects = append(rejects, &oldestOrder)
+			if err := tradingStateDB.CancelOrder(orderBook, &oldestOrder); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		if oldestOrder.UserAddress == order.UserAddress && chain.Config().IsTIPFREXSelfTrade(header.Number) {
+			var (
+				selfTradeRejects []*tradingstate.OrderItem
+				err              error
+			)
+			quantityToTrade, selfTradeRejects, err = preventSelfTrade(tradingStateDB, side, orderBook, price, orderId, amount, quantityToTrade, order, &oldestOrder)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			rejects = append(rejects, selfTradeRejects...)
+			continue
+		}
 		var (
 			tradedQuantity    *big.Int
 			maxTradedQuantity *big.Int
@@ -254,7 +535,7 @@ func (FREx *FREX) processOrderList(coinbase common.Address, chain consensus.Chai
 		} else {
 			quotePrice = common.BasePrice
 		}
-		tradedQuantity, rejectMaker, settleBalanceResult, err := FREx.getTradeQuantity(quotePrice, coinbase, chain, statedb, order, &oldestOrder, maxTradedQuantity)
+		tradedQuantity, rejectMaker, settleBalanceResult, err := FREx.getTradeQuantity(header, quotePrice, coinbase, chain, statedb, tradingStateDB, order, &oldestOrder, maxTradedQuantity)
 		if err != nil && err == tradingstate.ErrQuantityTradeTooSmall {
 			if tradedQuantity.Cmp(maxTradedQuantity) == 0 {
 				if quantityToTrade.Cmp(amount) == 0 { // reject Taker & maker
@@ -317,6 +598,12 @@ func (FREx *FREX) processOrderList(coinbase common.Address, chain consensus.Chai
 			tradeRecord[tradingstate.TradeMaker] = oldestOrder.UserAddress.String()
 			tradeRecord[tradingstate.TradeBaseToken] = oldestOrder.BaseToken.String()
 			tradeRecord[tradingstate.TradeQuoteToken] = oldestOrder.QuoteToken.String()
+			tradeRecord[tradingstate.TradeTaker] = order.UserAddress.String()
+			tradeRecord[tradingstate.TradeTakerExchange] = order.ExchangeAddress.String()
+			tradeRecord[tradingstate.TradeTakerSide] = order.Side
+			tradeRecord[tradingstate.TakerOrderType] = order.Type
+			tradeRecord[tradingstate.TradeMakerRemaining] = tradingstate.Sub(amount, tradedQuantity).String()
+			tradeRecord[tradingstate.TradeTakerRemaining] = quantityToTrade.String()
 			if settleBalanceResult != nil {
 				tradeRecord[tradingstate.MakerFee] = settleBalanceResult.Maker.Fee.Text(10)
 				tradeRecord[tradingstate.TakerFee] = settleBalanceResult.Taker.Fee.Text(10)
@@ -361,7 +648,45 @@ func (FREx *FREX) processOrderList(coinbase common.Address, chain consensus.Chai
 	return quantityToTrade, trades, rejects, nil
 }
 
-func (FREx *FREX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
+// preventSelfTrade applies the self-trade prevention mode of a taker order
+// meeting a maker order of the same user, instead of matching them. It returns
+// the quantity left to trade by the taker order and the cancelled orders.
+// With DecrementAndCancel the smaller order is cancelled and the larger one is
+// decremented by its quantity.
+func preventSelfTrade(tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, orderId common.Hash, amount *big.Int, quantityToTrade *big.Int, order *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem) (*big.Int, []*tradingstate.OrderItem, error) {
+	mode := order.SelfTradePrevention()
+	log.Debug("Prevent self trade", "mode", mode, "user", order.UserAddress.Hex(), "taker", order.Hash.Hex(), "maker", makerOrder.Hash.Hex())
+	var rejects []*tradingstate.OrderItem
+	cancelTaker := mode == tradingstate.CancelNewest || mode == tradingstate.CancelBoth
+	cancelMaker := mode == tradingstate.CancelOldest || mode == tradingstate.CancelBoth
+	if mode == tradingstate.DecrementAndCancel {
+		decrement := tradingstate.CloneBigInt(quantityToTrade)
+		if amount.Cmp(decrement) < 0 {
+			decrement = tradingstate.CloneBigInt(amount)
+		}
+		quantityToTrade = tradingstate.Sub(quantityToTrade, decrement)
+		cancelTaker = quantityToTrade.Sign() == 0
+		cancelMaker = amount.Cmp(decrement) == 0
+		if !cancelMaker {
+			if err := tradingStateDB.SubAmountOrderItem(orderBook, orderId, price, decrement, side); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if cancelMaker {
+		rejects = append(rejects, makerOrder)
+		if err := tradingStateDB.CancelOrder(orderBook, makerOrder); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cancelTaker {
+		rejects = append(rejects, order)
+		quantityToTrade = tradingstate.Zero
+	}
+	return quantityToTrade, rejects, nil
+}
+
+func (FREx *FREX) getTradeQuantity(header *types.Header, quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
 	baseTokenDecimal, err := FREx.GetTokenDecimal(chain, statedb, makerOrder.BaseToken)
 	if err != nil || baseTokenDecimal.Sign() == 0 {
 		return tradingstate.Zero, false, nil, fmt.Errorf("Fail to get tokenDecimal. Token: %v . Err: %v", makerOrder.BaseToken.String(), err)
@@ -388,8 +713,18 @@ func (FREx *FREX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address,
 			return tradingstate.Zero, true, nil, nil
 		}
 	}
-	takerFeeRate := tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
-	makerFeeRate := tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	var (
+		takerFeeRate, makerFeeRate *big.Int
+		epoch                      uint64
+	)
+	feeSchedule := chain.Config().IsTIPFREXFeeSchedule(header.Number)
+	if feeSchedule {
+		epoch = header.Number.Uint64() / chain.Config().S2PoS.Epoch
+		takerFeeRate, makerFeeRate = getFeeRates(epoch, statedb, tradingStateDB, takerOrder, makerOrder, baseTokenDecimal, quantityToTrade)
+	} else {
+		takerFeeRate = tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
+		makerFeeRate = tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	}
 	var takerBalance, makerBalance *big.Int
 	switch takerOrder.Side {
 	case tradingstate.Bid:
@@ -412,11 +747,49 @@ func (FREx *FREX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address,
 		if err == nil {
 			err = DoSettleBalance(coinbase, takerOrder, makerOrder, settleBalanceResult, statedb)
 		}
+		if err == nil && feeSchedule && quotePrice != nil && quotePrice.Sign() > 0 {
+			// volume in FRE = quantity * makerPrice / baseTokenDecimal * quotePrice / quoteTokenDecimal
+			volume := new(big.Int).Mul(quantity, makerOrder.Price)
+			volume = new(big.Int).Div(volume, baseTokenDecimal)
+			volume = new(big.Int).Mul(volume, quotePrice)
+			volume = new(big.Int).Div(volume, quoteTokenDecimal)
+			tradingStateDB.AddTradedVolume(takerOrder.UserAddress, epoch, volume)
+			tradingStateDB.AddTradedVolume(makerOrder.UserAddress, epoch, volume)
+		}
 		return quantity, rejectMaker, settleBalanceResult, err
 	}
 	return quantity, rejectMaker, settleBalanceResult, nil
 }
 
+// getFeeRates returns the taker and maker fee rates of a trade from the fee
+// schedules of their relayers, in the tier of the volume each user traded
+// during the last epochs. A relayer without a valid schedule charges its
+// registration fee on both sides. A maker rebate is only granted if the owner
+// of the maker relayer can pay it for the whole quantity to trade.
+func getFeeRates(epoch uint64, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, baseTokenDecimal *big.Int, quantityToTrade *big.Int) (*big.Int, *big.Int) {
+	takerFeeRate := tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
+	if tiers := tradingstate.GetRelayerFeeSchedule(takerOrder.ExchangeAddress, statedb); tiers != nil {
+		takerFeeRate = tradingstate.GetFeeTier(tiers, tradingStateDB.GetTradedVolume(takerOrder.UserAddress, epoch)).TakerFee
+	}
+	makerFeeRate := tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	if tiers := tradingstate.GetRelayerFeeSchedule(makerOrder.ExchangeAddress, statedb); tiers != nil {
+		makerFeeRate = tradingstate.GetFeeTier(tiers, tradingStateDB.GetTradedVolume(makerOrder.UserAddress, epoch)).MakerFee
+	}
+	if makerFeeRate.Sign() < 0 {
+		// rebate = quantityToTrade * makerPrice / baseTokenDecimal * -makerFeeRate / baseFee
+		rebate := new(big.Int).Mul(quantityToTrade, makerOrder.Price)
+		rebate = new(big.Int).Div(rebate, baseTokenDecimal)
+		rebate = new(big.Int).Mul(rebate, new(big.Int).Neg(makerFeeRate))
+		rebate = new(big.Int).Div(rebate, common.FREXBaseFee)
+		makerExOwner := tradingstate.GetRelayerOwner(makerOrder.ExchangeAddress, statedb)
+		if tradingstate.GetTokenBalance(makerExOwner, makerOrder.QuoteToken, statedb).Cmp(rebate) < 0 {
+			log.Debug("Relayer owner can't pay the maker rebate", "relayer", makerOrder.ExchangeAddress.Hex(), "owner", makerExOwner.Hex(), "rebate", rebate)
+			makerFeeRate = big.NewInt(0)
+		}
+	}
+	return takerFeeRate, makerFeeRate
+}
+
 func GetTradeQuantity(takerSide string, takerFeeRate *big.Int, takerBalance *big.Int, makerPrice *big.Int, makerFeeRate *big.Int, makerBalance *big.Int, baseTokenDecimal *big.Int, quantityToTrade *big.Int) (*big.Int, bool) {
 	if takerSide == tradingstate.Bid {
 		// maker InQuantity quoteTokenQuantity=(quantityToTrade*maker.Price/baseTokenDecimal)
@@ -474,7 +847,7 @@ func GetTradeQuantity(takerSide string, takerFeeRate *big.Int, takerBalance *big
 		// makerFee = quoteTokenQuantity * makerFeeRate / baseFee = quantityToTrade * makerPrice / baseTokenDecimal * makerFeeRate / baseFee
 		// charge on the token he/she has before the trade, in this case: quoteToken
 		makerFee := new(big.Int).Mul(quoteTokenQuantity, makerFeeRate)
-		makerFee = new(big.Int).Div(makerFee, common.FREXBaseFee)
+		makerFee = new(big.Int).Quo(makerFee, common.FREXBaseFee)
 
 		takerOutTotal := quantityToTrade
 		// makerOutTotal = quoteTokenQuantity + makerFee  = quantityToTrade * makerPrice / baseTokenDecimal + quantityToTrade * makerPrice / baseTokenDecimal * makerFeeRate / baseFee
@@ -566,7 +939,13 @@ func DoSettleBalance(coinbase common.Address, takerOrder, makerOrder *tradingsta
 		mapBalances[makerOrder.QuoteToken] = map[common.Address]*big.Int{}
 	}
 	mapBalances[makerOrder.QuoteToken][takerExOwner] = newTakerFee
-	newMakerFee, err := tradingstate.CheckAddTokenBalance(makerExOwner, settleBalance.Maker.Fee, makerOrder.QuoteToken, statedb, mapBalances)
+	var newMakerFee *big.Int
+	if settleBalance.Maker.Fee.Sign() < 0 {
+		// the maker relayer owner pays the maker rebate
+		newMakerFee, err = tradingstate.CheckSubTokenBalance(makerExOwner, new(big.Int).Neg(settleBalance.Maker.Fee), makerOrder.QuoteToken, statedb, mapBalances)
+	} else {
+		newMakerFee, err = tradingstate.CheckAddTokenBalance(makerExOwner, settleBalance.Maker.Fee, makerOrder.QuoteToken, statedb, mapBalances)
+	}
 	if err != nil {
 		return err
 	}
@@ -726,7 +1105,10 @@ func (FREx *FREX) getCancelFee(chain consensus.ChainContext, statedb *state.Stat
 	return cancelFee, tokenPriceInFRE
 }
 
-func (FREx *FREX) UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error {
+// UpdateMediumPriceBeforeEpoch closes the epoch average price of every pair.
+// With keepEpochPrices the average is also pushed to the epoch price history
+// of the order book, zero if the pair was not traded during the epoch.
+func (FREx *FREX) UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB, keepEpochPrices bool) error {
 	mapPairs, err := tradingstate.GetAllTradingPairs(statedb)
 	log.Debug("UpdateMediumPriceBeforeEpoch", "len(mapPairs)", len(mapPairs))
 
@@ -745,6 +1127,9 @@ func (FREx *FREX) UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateD
 			tradingStateDB.SetMediumPriceBeforeEpoch(orderbook, mediumPriceCurrent)
 			epochPriceResult[orderbook] = mediumPriceCurrent
 		}
+		if keepEpochPrices {
+			tradingStateDB.SetEpochPrices(orderbook, pushEpochPrice(tradingStateDB.GetEpochPrices(orderbook), mediumPriceCurrent))
+		}
 		tradingStateDB.SetMediumPrice(orderbook, tradingstate.Zero, tradingstate.Zero)
 	}
 	if FREx.IsSDKNode() {
@@ -755,11 +1140,23 @@ func (FREx *FREX) UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateD
 	return nil
 }
 
-// put average price of epoch to mongodb for tracking liquidation trades
+// pushEpochPrice returns a new epoch price history starting with the given
+// price, at most common.EpochPriceHistory prices are kept.
+func pushEpochPrice(prices []*big.Int, price *big.Int) []*big.Int {
+	n := len(prices) + 1
+	if n > common.EpochPriceHistory {
+		n = common.EpochPriceHistory
+	}
+	history := make([]*big.Int, 0, n)
+	history = append(history, tradingstate.CloneBigInt(price))
+	return append(history, prices[:n-1]...)
+}
+
+// put average price of epoch to the SDK database for tracking liquidation trades
 // epochPriceResult: a map of epoch average price, key is orderbook hash , value is epoch average price
 // orderbook hash genereted from baseToken, quoteToken at FRECNET/FREx/tradingstate/common.go:214
 func (FREx *FREX) LogEpochPrice(epochNumber uint64, epochPriceResult map[common.Hash]*big.Int) error {
-	db := FREx.GetMongoDB()
+	db := FREx.GetSDKDB()
 	db.InitBulk()
 
 	for orderbook, price := range epochPriceResult {
@@ -2,10 +2,19 @@ package FREx
 
 import (
 	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/accounts/abi"
 	"github.com/FRECNET/common"
+	"github.com/FRECNET/contracts/FREx/feeschedule"
 	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/core/vm"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/params"
+	lru "github.com/hashicorp/golang-lru"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -572,3 +581,354 @@ func TestGetTradeQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestPushEpochPrice(t *testing.T) {
+	var history []*big.Int
+	for i := 1; i <= common.EpochPriceHistory+2; i++ {
+		history = pushEpochPrice(history, big.NewInt(int64(i)))
+	}
+	if len(history) != common.EpochPriceHistory {
+		t.Fatalf("history length mismatch: have %d, want %d", len(history), common.EpochPriceHistory)
+	}
+	for i, price := range history {
+		if want := int64(common.EpochPriceHistory + 2 - i); price.Int64() != want {
+			t.Errorf("price %d mismatch: have %v, want %d", i, price, want)
+		}
+	}
+}
+
+func TestPreventSelfTrade(t *testing.T) {
+	user := common.HexToAddress("0x0000000000000000000000000000000000000099")
+	orderBook := common.StringToHash("BTC/FRE")
+	price := big.NewInt(5)
+	orderId := common.BigToHash(big.NewInt(1))
+	tests := []struct {
+		mode      string
+		quantity  int64
+		remaining int64
+		makerLeft int64
+		rejects   []string
+	}{
+		{"", 4, 0, 10, []string{"taker"}},
+		{tradingstate.CancelNewest, 4, 0, 10, []string{"taker"}},
+		{tradingstate.CancelOldest, 4, 4, 0, []string{"maker"}},
+		{tradingstate.CancelBoth, 4, 0, 0, []string{"maker", "taker"}},
+		{tradingstate.DecrementAndCancel, 4, 0, 6, []string{"taker"}},
+		{tradingstate.DecrementAndCancel, 15, 5, 0, []string{"maker"}},
+		{tradingstate.DecrementAndCancel, 10, 0, 0, []string{"maker", "taker"}},
+	}
+	for _, tt := range tests {
+		tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+		maker := tradingstate.OrderItem{OrderID: 1, UserAddress: user, Quantity: big.NewInt(10), Price: price, Side: tradingstate.Ask}
+		tradingStateDb.InsertOrderItem(orderBook, orderId, maker)
+		taker := &tradingstate.OrderItem{UserAddress: user, Quantity: big.NewInt(tt.quantity), Price: price, Side: tradingstate.Bid, SelfTradeMode: tt.mode}
+
+		remaining, rejects, err := preventSelfTrade(tradingStateDb, tradingstate.Ask, orderBook, price, orderId, big.NewInt(10), big.NewInt(tt.quantity), taker, &maker)
+		if err != nil {
+			t.Fatalf("%s/%d: failed to prevent self trade: %v", tt.mode, tt.quantity, err)
+		}
+		if remaining.Cmp(big.NewInt(tt.remaining)) != 0 {
+			t.Errorf("%s/%d: remaining quantity mismatch: have %v, want %d", tt.mode, tt.quantity, remaining, tt.remaining)
+		}
+		names := []string{}
+		for _, reject := range rejects {
+			if reject == taker {
+				names = append(names, "taker")
+			} else {
+				names = append(names, "maker")
+			}
+		}
+		if !reflect.DeepEqual(names, tt.rejects) {
+			t.Errorf("%s/%d: cancelled orders mismatch: have %v, want %v", tt.mode, tt.quantity, names, tt.rejects)
+		}
+		_, makerLeft, _ := tradingStateDb.GetBestOrderIdAndAmount(orderBook, price, tradingstate.Ask)
+		if makerLeft.Cmp(big.NewInt(tt.makerLeft)) != 0 {
+			t.Errorf("%s/%d: maker quantity mismatch: have %v, want %d", tt.mode, tt.quantity, makerLeft, tt.makerLeft)
+		}
+	}
+}
+
+// Tests that the expired good till time orders leave the book when met by a
+// limit order, before the post only check, and stay out of it when a fill or
+// kill order is killed.
+func TestRemoveExpiredOrders(t *testing.T) {
+	var (
+		maker     = common.HexToAddress("0x0000000000000000000000000000000000000098")
+		taker     = common.HexToAddress("0x0000000000000000000000000000000000000099")
+		relayer   = common.HexToAddress("0x0000000000000000000000000000000000000042")
+		orderBook = common.StringToHash("BTC/FRE")
+		chain     = &testChain{config: params.TestChainConfig}
+		header    = &types.Header{Number: new(big.Int).Set(params.MainnetForks().TIPFREXSelfTradeBlock), Time: big.NewInt(1000)}
+		signature = &tradingstate.Signature{V: 1, R: common.HexToHash("111111"), S: common.HexToHash("222222222222")}
+	)
+	newOrder := func(id uint64, user common.Address, side string, price int64, expireAt uint64) tradingstate.OrderItem {
+		order := tradingstate.OrderItem{
+			OrderID:         id,
+			UserAddress:     user,
+			ExchangeAddress: relayer,
+			Quantity:        big.NewInt(10),
+			Price:           big.NewInt(price),
+			Side:            side,
+			Type:            tradingstate.Limit,
+			Hash:            common.BigToHash(new(big.Int).SetUint64(id)),
+			Signature:       signature,
+		}
+		if expireAt > 0 {
+			order.TimeInForce = tradingstate.GoodTillTime
+			order.ExpireAt = expireAt
+		}
+		return order
+	}
+	insert := func(tradingStateDb *tradingstate.TradingStateDB, orders ...tradingstate.OrderItem) {
+		for _, order := range orders {
+			tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+			tradingStateDb.SetNonce(orderBook, order.OrderID)
+		}
+	}
+	FREx := &FREX{}
+	tests := []struct {
+		name        string
+		book        []tradingstate.OrderItem
+		timeInForce string
+		price       int64
+		expired     int
+		bestAsk     int64
+		askVolume   int64
+		bestBid     int64
+	}{
+		// expired asks on top of the book are removed and the bid rests
+		{"limit", []tradingstate.OrderItem{newOrder(1, maker, tradingstate.Ask, 5, 999), newOrder(2, maker, tradingstate.Ask, 6, 1000)}, "", 6, 2, 0, 0, 6},
+		// the crossing ask being expired, the post only bid rests
+		{"post only", []tradingstate.OrderItem{newOrder(1, maker, tradingstate.Ask, 5, 999), newOrder(2, maker, tradingstate.Ask, 7, 0)}, tradingstate.PostOnly, 6, 1, 7, 10, 6},
+		// the expired ask behind the self trade is removed while matching,
+		// the kill reverts the cancellation of the self trade only
+		{"fill or kill", []tradingstate.OrderItem{newOrder(1, taker, tradingstate.Ask, 5, 0), newOrder(2, maker, tradingstate.Ask, 5, 999)}, tradingstate.FillOrKill, 5, 1, 5, 10, 0},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+		tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+		insert(tradingStateDb, tt.book...)
+
+		order := newOrder(0, taker, tradingstate.Bid, tt.price, 0)
+		order.TimeInForce = tt.timeInForce
+		order.SelfTradeMode = tradingstate.CancelOldest
+		trades, rejects, err := FREx.processLimitOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, &order)
+		if err != nil {
+			t.Fatalf("%s: failed to process order: %v", tt.name, err)
+		}
+		if len(trades) != 0 {
+			t.Errorf("%s: trades mismatch: have %d, want 0", tt.name, len(trades))
+		}
+		// price levels emptied and restored by a kill are back in the trie
+		tradingStateDb.Finalise()
+		expired := 0
+		for _, reject := range rejects {
+			if reject.IsExpired(header.Time.Uint64()) {
+				expired++
+			}
+		}
+		if expired != tt.expired {
+			t.Errorf("%s: expired rejects mismatch: have %d, want %d", tt.name, expired, tt.expired)
+		}
+		if bestAsk, volume := tradingStateDb.GetBestAskPrice(orderBook); bestAsk.Cmp(big.NewInt(tt.bestAsk)) != 0 || volume.Cmp(big.NewInt(tt.askVolume)) != 0 {
+			t.Errorf("%s: best ask mismatch: have %v for %v, want %d for %d", tt.name, bestAsk, volume, tt.bestAsk, tt.askVolume)
+		}
+		if bestBid, _ := tradingStateDb.GetBestBidPrice(orderBook); bestBid.Cmp(big.NewInt(tt.bestBid)) != 0 {
+			t.Errorf("%s: best bid mismatch: have %v, want %d", tt.name, bestBid, tt.bestBid)
+		}
+	}
+}
+
+// Tests that a trigger order is rejected when its user doesn't hold the tokens
+// it spends once activated, a stop market order being valued at its trigger
+// price.
+func TestApplyTriggerOrderBalance(t *testing.T) {
+	var (
+		key, _     = crypto.GenerateKey()
+		user       = crypto.PubkeyToAddress(key.PublicKey)
+		relayer    = common.HexToAddress("0x0000000000000000000000000000000000000042")
+		baseToken  = common.HexToAddress("0x0000000000000000000000000000000000000043")
+		quoteToken = common.HexToAddress(common.FRENativeAddress)
+		orderBook  = tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		chain      = &testChain{config: params.TestChainConfig}
+		header     = &types.Header{Number: new(big.Int).Set(params.MainnetForks().TIPFREXTriggerOrderBlock), Time: big.NewInt(1000)}
+		quantity   = new(big.Int).Mul(big.NewInt(5), common.BasePrice)
+		trigger    = new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	registerRelayer(statedb, relayer, baseToken, quoteToken)
+
+	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
+	FREx := &FREX{tokenDecimalCache: tokenDecimalCache}
+	FREx.SetTokenDecimal(baseToken, common.BasePrice)
+	nonce := uint64(0)
+	newStopOrder := func() *tradingstate.OrderItem {
+		tx := types.NewOrderTransaction(nonce, quantity, new(big.Int), relayer, user, baseToken, quoteToken, tradingstate.OrderNew, tradingstate.Bid, tradingstate.StopMarket, common.Hash{}, 0)
+		tx.SetTriggerPrice(trigger)
+		signer := types.OrderTxSigner{}
+		tx.SetOrderHash(signer.Hash(tx))
+		tx, err := types.OrderSignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign stop order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		nonce++
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(tx.Nonce()),
+			Quantity:        tx.Quantity(),
+			Price:           tx.Price(),
+			TriggerPrice:    tx.TriggerPrice(),
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            tradingstate.Bid,
+			Type:            tradingstate.StopMarket,
+			Hash:            tx.OrderHash(),
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+
+	order := newStopOrder()
+	_, rejects, err := FREx.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order)
+	if err != nil {
+		t.Fatalf("failed to apply stop order: %v", err)
+	}
+	if len(rejects) != 1 || rejects[0] != order {
+		t.Fatalf("stop order without balance not rejected: have %d rejects", len(rejects))
+	}
+	if id := tradingStateDb.GetNonce(orderBook); id != 0 {
+		t.Errorf("order id of the rejected stop order mismatch: have %d, want 0", id)
+	}
+
+	// With the quote tokens of the quantity at the trigger price and the fee
+	statedb.AddBalance(user, new(big.Int).Mul(big.NewInt(51), common.BasePrice))
+	order = newStopOrder()
+	if _, rejects, err = FREx.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order); err != nil || len(rejects) != 0 {
+		t.Fatalf("stop order rejected: %d rejects, err %v", len(rejects), err)
+	}
+	if id := tradingStateDb.GetNonce(orderBook); id != 1 {
+		t.Errorf("order id mismatch: have %d, want 1", id)
+	}
+}
+
+// Tests that a relayer owner sets a fee schedule through the contract installed
+// at the hardfork, and that matching charges its rates.
+func TestFeeScheduleContract(t *testing.T) {
+	var (
+		relayer  = common.HexToAddress("0x0000000000000000000000000000000000000042")
+		owner    = common.HexToAddress("0x0000000000000000000000000000000000000043")
+		taker    = common.HexToAddress("0x0000000000000000000000000000000000000044")
+		contract = common.HexToAddress(common.RelayerFeeScheduleSMC)
+	)
+	feeScheduleABI, err := abi.JSON(strings.NewReader(feeschedule.RelayerFeeScheduleABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingstate.ApplyFeeScheduleHardFork(statedb)
+
+	// The relayer registration returns the owner of every coinbase and the
+	// relayer charges a registration fee of 20
+	registration := common.HexToAddress(common.RelayerRegistrationSMC)
+	statedb.SetCode(registration, common.Hex2Bytes("73"+common.Bytes2Hex(owner.Bytes())+"60205260406000f3"))
+	loc := new(big.Int).Add(tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"]), tradingstate.RelayerStructMappingSlot["_fee"])
+	statedb.SetState(registration, common.BigToHash(loc), common.BigToHash(big.NewInt(20)))
+
+	call := func(from common.Address, method string, args ...interface{}) ([]byte, error) {
+		input, err := feeScheduleABI.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("failed to pack %s: %v", method, err)
+		}
+		context := vm.Context{
+			CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+			Origin:      from,
+			GasPrice:    new(big.Int),
+			BlockNumber: big.NewInt(1),
+			Time:        new(big.Int),
+			Difficulty:  new(big.Int),
+			GasLimit:    10000000,
+		}
+		evm := vm.NewEVM(context, statedb, nil, params.TestChainConfig, vm.Config{})
+		ret, _, err := evm.Call(vm.AccountRef(from), contract, input, 1000000, new(big.Int))
+		return ret, err
+	}
+	// The abi packs the negative fees in place, the tiers are compared to
+	// their int64 values
+	tiersWant := [][3]int64{{0, 5, 10}, {1000, -2, 4}}
+	volumes := []*big.Int{big.NewInt(0), big.NewInt(1000)}
+	makerFees := []*big.Int{big.NewInt(5), big.NewInt(-2)}
+	takerFees := []*big.Int{big.NewInt(10), big.NewInt(4)}
+	if _, err := call(taker, "setFeeSchedule", relayer, volumes, makerFees, takerFees); err == nil {
+		t.Fatalf("schedule set by another account than the relayer owner")
+	}
+	invalid := []*big.Int{big.NewInt(5), big.NewInt(-5)}
+	if _, err := call(owner, "setFeeSchedule", relayer, volumes, invalid, takerFees); err == nil {
+		t.Fatalf("schedule with a maker rebate above the taker fee accepted")
+	}
+	unsorted := []*big.Int{big.NewInt(0), big.NewInt(0)}
+	if _, err := call(owner, "setFeeSchedule", relayer, unsorted, makerFees, takerFees); err == nil {
+		t.Fatalf("schedule with unsorted tiers accepted")
+	}
+	if _, err := call(owner, "setFeeSchedule", relayer, volumes, makerFees, takerFees); err != nil {
+		t.Fatalf("failed to set fee schedule: %v", err)
+	}
+	tiers := tradingstate.GetRelayerFeeSchedule(relayer, statedb)
+	if len(tiers) != len(tiersWant) {
+		t.Fatalf("tier count mismatch: have %d, want %d", len(tiers), len(tiersWant))
+	}
+	for i, tier := range tiers {
+		if have := [3]int64{tier.Volume.Int64(), tier.MakerFee.Int64(), tier.TakerFee.Int64()}; have != tiersWant[i] {
+			t.Errorf("tier %d mismatch: have %v, want %v", i, have, tiersWant[i])
+		}
+	}
+	ret, err := call(taker, "getFeeSchedule", relayer)
+	if err != nil {
+		t.Fatalf("failed to get fee schedule: %v", err)
+	}
+	var schedule struct {
+		Volumes   []*big.Int
+		MakerFees []*big.Int
+		TakerFees []*big.Int
+	}
+	if err := feeScheduleABI.Unpack(&[]interface{}{&schedule.Volumes, &schedule.MakerFees, &schedule.TakerFees}, "getFeeSchedule", ret); err != nil {
+		t.Fatalf("failed to unpack fee schedule: %v", err)
+	}
+	if len(schedule.Volumes) != 2 || schedule.Volumes[1].Int64() != 1000 || schedule.MakerFees[1].Int64() != -2 || schedule.TakerFees[0].Int64() != 10 {
+		t.Errorf("fee schedule mismatch: have %v/%v/%v", schedule.Volumes, schedule.MakerFees, schedule.TakerFees)
+	}
+
+	// The first tier applies to new users, the second one to users who
+	// traded its volume in the previous epochs, the maker rebate only if the
+	// owner can pay it
+	quoteToken := common.HexToAddress("0x0000000000000000000000000000000000000045")
+	takerOrder := &tradingstate.OrderItem{UserAddress: taker, ExchangeAddress: relayer, QuoteToken: quoteToken, Price: common.BasePrice}
+	makerOrder := &tradingstate.OrderItem{UserAddress: owner, ExchangeAddress: relayer, QuoteToken: quoteToken, Price: common.BasePrice}
+	rates := func(epoch uint64) (int64, int64) {
+		takerRate, makerRate := getFeeRates(epoch, statedb, tradingStateDb, takerOrder, makerOrder, common.BasePrice, common.BasePrice)
+		return takerRate.Int64(), makerRate.Int64()
+	}
+	if takerRate, makerRate := rates(0); takerRate != 10 || makerRate != 5 {
+		t.Errorf("first tier rates mismatch: have %d/%d, want 10/5", takerRate, makerRate)
+	}
+	tradingStateDb.AddTradedVolume(taker, 0, big.NewInt(1000))
+	tradingStateDb.AddTradedVolume(owner, 0, big.NewInt(1000))
+	if takerRate, makerRate := rates(1); takerRate != 4 || makerRate != 0 {
+		t.Errorf("second tier rates without rebate funds mismatch: have %d/%d, want 4/0", takerRate, makerRate)
+	}
+
+	// Clearing the schedule restores the registration fee
+	if _, err := call(owner, "clearFeeSchedule", relayer); err != nil {
+		t.Fatalf("failed to clear fee schedule: %v", err)
+	}
+	if tiers := tradingstate.GetRelayerFeeSchedule(relayer, statedb); tiers != nil {
+		t.Fatalf("fee schedule not cleared: have %d tiers", len(tiers))
+	}
+	if takerRate, makerRate := rates(1); takerRate != 20 || makerRate != 20 {
+		t.Errorf("registration fee rates mismatch: have %d/%d, want 20/20", takerRate, makerRate)
+	}
+}
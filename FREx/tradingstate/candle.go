@@ -0,0 +1,133 @@
+package tradingstate
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/crypto"
+	"github.com/globalsign/mgo/bson"
+)
+
+// CandleIntervals are the candle intervals maintained by SDK nodes.
+var CandleIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// Candle is the OHLCV summary of the trades of a pair in an interval. Open
+// and close are the prices of the first and last trades in matching order.
+type Candle struct {
+	Hash        common.Hash    `json:"hash" bson:"hash"`
+	BaseToken   common.Address `json:"baseToken" bson:"baseToken"`
+	QuoteToken  common.Address `json:"quoteToken" bson:"quoteToken"`
+	Interval    string         `json:"interval" bson:"interval"`
+	OpenTime    time.Time      `json:"openTime" bson:"openTime"`
+	Open        *big.Int       `json:"open" bson:"open"`
+	High        *big.Int       `json:"high" bson:"high"`
+	Low         *big.Int       `json:"low" bson:"low"`
+	Close       *big.Int       `json:"close" bson:"close"`
+	Volume      *big.Int       `json:"volume" bson:"volume"`           // traded base token quantity
+	QuoteVolume *big.Int       `json:"quoteVolume" bson:"quoteVolume"` // traded quote token quantity
+	Count       uint64         `json:"count" bson:"count"`
+}
+
+type CandleBSON struct {
+	Hash        string    `json:"hash" bson:"hash"`
+	BaseToken   string    `json:"baseToken" bson:"baseToken"`
+	QuoteToken  string    `json:"quoteToken" bson:"quoteToken"`
+	Interval    string    `json:"interval" bson:"interval"`
+	OpenTime    time.Time `json:"openTime" bson:"openTime"`
+	Open        string    `json:"open" bson:"open"`
+	High        string    `json:"high" bson:"high"`
+	Low         string    `json:"low" bson:"low"`
+	Close       string    `json:"close" bson:"close"`
+	Volume      string    `json:"volume" bson:"volume"`
+	QuoteVolume string    `json:"quoteVolume" bson:"quoteVolume"`
+	Count       int64     `json:"count" bson:"count"`
+}
+
+// NewCandle returns an empty candle of a pair, the open time is the start of
+// the interval containing the given time.
+func NewCandle(baseToken, quoteToken common.Address, interval string, t time.Time) *Candle {
+	c := &Candle{
+		BaseToken:   baseToken,
+		QuoteToken:  quoteToken,
+		Interval:    interval,
+		OpenTime:    CandleOpenTime(interval, t),
+		Volume:      new(big.Int),
+		QuoteVolume: new(big.Int),
+	}
+	c.Hash = c.ComputeHash()
+	return c
+}
+
+// CandleOpenTime returns the start of the interval containing the given time.
+func CandleOpenTime(interval string, t time.Time) time.Time {
+	return t.UTC().Truncate(CandleIntervals[interval])
+}
+
+// AddTrade updates the candle with a trade of the given price, base and quote
+// quantities. The trades must be added in matching order.
+func (c *Candle) AddTrade(price, quantity, quoteQuantity *big.Int) {
+	if c.Count == 0 {
+		c.Open = CloneBigInt(price)
+		c.High = CloneBigInt(price)
+		c.Low = CloneBigInt(price)
+	}
+	if price.Cmp(c.High) > 0 {
+		c.High = CloneBigInt(price)
+	}
+	if price.Cmp(c.Low) < 0 {
+		c.Low = CloneBigInt(price)
+	}
+	c.Close = CloneBigInt(price)
+	c.Volume = Add(c.Volume, quantity)
+	c.QuoteVolume = Add(c.QuoteVolume, quoteQuantity)
+	c.Count++
+}
+
+func (c *Candle) GetBSON() (interface{}, error) {
+	return CandleBSON{
+		Hash:        c.Hash.Hex(),
+		BaseToken:   c.BaseToken.Hex(),
+		QuoteToken:  c.QuoteToken.Hex(),
+		Interval:    c.Interval,
+		OpenTime:    c.OpenTime,
+		Open:        c.Open.String(),
+		High:        c.High.String(),
+		Low:         c.Low.String(),
+		Close:       c.Close.String(),
+		Volume:      c.Volume.String(),
+		QuoteVolume: c.QuoteVolume.String(),
+		Count:       int64(c.Count),
+	}, nil
+}
+
+func (c *Candle) SetBSON(raw bson.Raw) error {
+	decoded := &CandleBSON{}
+	if err := raw.Unmarshal(decoded); err != nil {
+		return err
+	}
+	c.Hash = common.HexToHash(decoded.Hash)
+	c.BaseToken = common.HexToAddress(decoded.BaseToken)
+	c.QuoteToken = common.HexToAddress(decoded.QuoteToken)
+	c.Interval = decoded.Interval
+	c.OpenTime = decoded.OpenTime.UTC()
+	c.Open = ToBigInt(decoded.Open)
+	c.High = ToBigInt(decoded.High)
+	c.Low = ToBigInt(decoded.Low)
+	c.Close = ToBigInt(decoded.Close)
+	c.Volume = ToBigInt(decoded.Volume)
+	c.QuoteVolume = ToBigInt(decoded.QuoteVolume)
+	c.Count = uint64(decoded.Count)
+	return nil
+}
+
+// ComputeHash returns the hash identifying the candle of a pair, interval and
+// open time.
+func (c *Candle) ComputeHash() common.Hash {
+	return crypto.Keccak256Hash(c.BaseToken.Bytes(), c.QuoteToken.Bytes(), []byte(c.Interval), new(big.Int).SetInt64(c.OpenTime.Unix()).Bytes())
+}
@@ -0,0 +1,49 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/FRECNET/common"
+)
+
+func TestCandleAddTrade(t *testing.T) {
+	var (
+		baseToken  = common.HexToAddress("0x1")
+		quoteToken = common.HexToAddress("0x2")
+		tradeTime  = time.Date(2021, 3, 4, 10, 17, 42, 0, time.UTC)
+	)
+	candle := NewCandle(baseToken, quoteToken, "5m", tradeTime)
+	if want := time.Date(2021, 3, 4, 10, 15, 0, 0, time.UTC); !candle.OpenTime.Equal(want) {
+		t.Fatalf("open time mismatch: have %v, want %v", candle.OpenTime, want)
+	}
+	if other := NewCandle(baseToken, quoteToken, "1m", tradeTime); other.Hash == candle.Hash {
+		t.Fatalf("candles of different intervals share hash %x", candle.Hash)
+	}
+	if day := NewCandle(baseToken, quoteToken, "1d", tradeTime); !day.OpenTime.Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("daily candle open time mismatch: have %v", day.OpenTime)
+	}
+	for _, price := range []int64{10, 14, 7, 9} {
+		candle.AddTrade(big.NewInt(price), big.NewInt(2), big.NewInt(2*price))
+	}
+	tests := []struct {
+		name       string
+		have, want *big.Int
+	}{
+		{"open", candle.Open, big.NewInt(10)},
+		{"high", candle.High, big.NewInt(14)},
+		{"low", candle.Low, big.NewInt(7)},
+		{"close", candle.Close, big.NewInt(9)},
+		{"volume", candle.Volume, big.NewInt(8)},
+		{"quoteVolume", candle.QuoteVolume, big.NewInt(80)},
+	}
+	for _, tt := range tests {
+		if tt.have.Cmp(tt.want) != 0 {
+			t.Errorf("%s mismatch: have %v, want %v", tt.name, tt.have, tt.want)
+		}
+	}
+	if candle.Count != 4 {
+		t.Errorf("count mismatch: have %d, want 4", candle.Count)
+	}
+}
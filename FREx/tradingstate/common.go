@@ -16,16 +16,38 @@ const (
 )
 
 var (
-	EmptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
-	Ask       = "SELL"
-	Bid       = "BUY"
-	Market    = "MO"
-	Limit     = "LO"
-	Cancel    = "CANCELLED"
-	OrderNew  = "NEW"
+	EmptyRoot  = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	Ask        = "SELL"
+	Bid        = "BUY"
+	Market     = "MO"
+	Limit      = "LO"
+	StopMarket = "SMO"
+	StopLimit  = "SLO"
+	TakeProfit = "TPO"
+	Cancel     = "CANCELLED"
+	OrderNew   = "NEW"
+	BatchOrder = "BATCH"
+
+	GoodTillCancel    = "GTC"
+	ImmediateOrCancel = "IOC"
+	FillOrKill        = "FOK"
+	PostOnly          = "PO"
+	GoodTillTime      = "GTT"
+
+	CancelNewest       = "CN"
+	CancelOldest       = "CO"
+	CancelBoth         = "CB"
+	DecrementAndCancel = "DC"
 )
 
 var EmptyHash = common.Hash{}
+
+const (
+	triggerOnRise      = byte(0) // trigger orders activated by a price rising to their trigger price
+	triggerOnFall      = byte(1) // trigger orders activated by a price falling to their trigger price
+	triggerPriceLength = 23      // maximum length of a trigger price in the trigger trie key
+)
+
 var Zero = big.NewInt(0)
 var One = big.NewInt(1)
 
@@ -43,19 +65,48 @@ var EmptyOrder = OrderItem{
 }
 
 var (
-	ErrInvalidSignature = errors.New("verify order: invalid signature")
-	ErrInvalidPrice     = errors.New("verify order: invalid price")
-	ErrInvalidQuantity  = errors.New("verify order: invalid quantity")
-	ErrInvalidRelayer   = errors.New("verify order: invalid relayer")
-	ErrInvalidOrderType = errors.New("verify order: unsupported order type")
-	ErrInvalidOrderSide = errors.New("verify order: invalid order side")
-	ErrInvalidStatus    = errors.New("verify order: invalid status")
+	ErrInvalidSignature    = errors.New("verify order: invalid signature")
+	ErrInvalidPrice        = errors.New("verify order: invalid price")
+	ErrInvalidQuantity     = errors.New("verify order: invalid quantity")
+	ErrInvalidRelayer      = errors.New("verify order: invalid relayer")
+	ErrInvalidOrderType    = errors.New("verify order: unsupported order type")
+	ErrInvalidOrderSide    = errors.New("verify order: invalid order side")
+	ErrInvalidStatus       = errors.New("verify order: invalid status")
+	ErrInvalidTriggerPrice = errors.New("verify order: invalid trigger price")
+	ErrInvalidTimeInForce  = errors.New("verify order: invalid time in force")
+	ErrInvalidSelfTrade    = errors.New("verify order: invalid self-trade prevention mode")
+	ErrInvalidBatchOrder   = errors.New("verify order: invalid batch order")
 
 	// supported order types
 	MatchingOrderType = map[string]bool{
 		Market: true,
 		Limit:  true,
 	}
+
+	// supported time in force of limit orders
+	TimeInForceType = map[string]bool{
+		GoodTillCancel:    true,
+		ImmediateOrCancel: true,
+		FillOrKill:        true,
+		PostOnly:          true,
+		GoodTillTime:      true,
+	}
+
+	// supported self-trade prevention modes
+	SelfTradePreventionMode = map[string]bool{
+		CancelNewest:       true,
+		CancelOldest:       true,
+		CancelBoth:         true,
+		DecrementAndCancel: true,
+	}
+
+	// order types resting in the trigger trie until the last price crosses
+	// their trigger price, mapped to the order type they are matched as
+	TriggerOrderType = map[string]string{
+		StopMarket: Market,
+		StopLimit:  Limit,
+		TakeProfit: Market,
+	}
 )
 
 // tradingExchangeObject is the Ethereum consensus representation of exchanges.
@@ -78,6 +129,11 @@ type tradingExchangeObject struct {
 	BidRoot                common.Hash // merkle root of the storage trie
 	OrderRoot              common.Hash
 	LiquidationPriceRoot   common.Hash
+	TriggerOrderRoot       common.Hash `rlp:"optional"` // zero until the first trigger order of the book
+	EpochPrices            []*big.Int  `rlp:"optional"` // average prices of the last epochs, newest first, zero for epochs without trades
+	UserOrderRoot          common.Hash `rlp:"optional"` // open orders of a user address, zero until the first indexed order
+	VolumeEpoch            uint64      `rlp:"optional"` // epoch of the newest traded volume of a user address
+	EpochVolumes           []*big.Int  `rlp:"optional"` // FRE volumes traded by a user address during the last epochs, newest first
 }
 
 var (
@@ -103,6 +159,15 @@ var (
 		"_index":      big.NewInt(4),
 		"_owner":      big.NewInt(5),
 	}
+	FeeScheduleMappingSlot = map[string]uint64{
+		"RelayerRegistration": 0,
+		"FEE_SCHEDULES":       1,
+	}
+	FeeTierStructMappingSlot = map[string]*big.Int{
+		"_volume":   big.NewInt(0),
+		"_makerFee": big.NewInt(1),
+		"_takerFee": big.NewInt(2),
+	}
 )
 
 type TxDataMatch struct {
@@ -120,6 +185,14 @@ type MatchingResult struct {
 	Rejects []*OrderItem
 }
 
+// OrderMatch is an order processed by a trading transaction along with the
+// trades and rejects it produced.
+type OrderMatch struct {
+	TxHash common.Hash
+	Order  *OrderItem
+	MatchingResult
+}
+
 func EncodeTxMatchesBatch(txMatchBatch TxMatchBatch) ([]byte, error) {
 	data, err := json.Marshal(txMatchBatch)
 	if err != nil || data == nil {
@@ -218,3 +291,9 @@ func GetTradingOrderBookHash(baseToken common.Address, quoteToken common.Address
 func GetMatchingResultCacheKey(order *OrderItem) common.Hash {
 	return crypto.Keccak256Hash(order.UserAddress.Bytes(), order.Nonce.Bytes())
 }
+
+// userOrderKey returns the key of an order in the open orders trie of its
+// user, the orders of a user are grouped by order book.
+func userOrderKey(orderBook common.Hash, orderId common.Hash) []byte {
+	return append(orderBook.Bytes(), orderId.Bytes()...)
+}
@@ -1,8 +1,13 @@
 package tradingstate
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
 )
 
 // Testing scenario:
@@ -41,3 +46,114 @@ func TestTxMatchesBatch(t *testing.T) {
 		t.Error("txMatchesBatch is different from originalTxMatchesBatch", "txMatchesBatch", txMatchesBatch, "originalTxMatchesBatch", originalTxMatchesBatch)
 	}
 }
+
+func TestVerifyTimeInForce(t *testing.T) {
+	tests := []struct {
+		order *OrderItem
+		valid bool
+	}{
+		{&OrderItem{Type: Limit}, true},
+		{&OrderItem{Type: Limit, TimeInForce: ImmediateOrCancel}, true},
+		{&OrderItem{Type: Limit, TimeInForce: FillOrKill}, true},
+		{&OrderItem{Type: Limit, TimeInForce: PostOnly}, true},
+		{&OrderItem{Type: StopLimit, TimeInForce: PostOnly}, true},
+		{&OrderItem{Type: Limit, TimeInForce: GoodTillTime, ExpireAt: 100}, true},
+		{&OrderItem{Type: Limit, TimeInForce: GoodTillTime}, false},
+		{&OrderItem{Type: Limit, TimeInForce: ImmediateOrCancel, ExpireAt: 100}, false},
+		{&OrderItem{Type: Market, TimeInForce: FillOrKill}, false},
+		{&OrderItem{Type: StopMarket, TimeInForce: ImmediateOrCancel}, false},
+		{&OrderItem{Type: Limit, TimeInForce: "XYZ"}, false},
+	}
+	for i, test := range tests {
+		if err := test.order.VerifyTimeInForce(); (err == nil) != test.valid {
+			t.Errorf("test %d: time in force %q expiry %d, valid %v, got error %v", i, test.order.TimeInForce, test.order.ExpireAt, test.valid, err)
+		}
+	}
+
+	order := &OrderItem{Type: Limit, TimeInForce: GoodTillTime, ExpireAt: 100}
+	if order.IsExpired(99) {
+		t.Error("order expired before its expiry time")
+	}
+	if !order.IsExpired(100) {
+		t.Error("order not expired at its expiry time")
+	}
+	if (&OrderItem{Type: Limit, TimeInForce: ImmediateOrCancel}).IsExpired(1000) {
+		t.Error("order without expiry time expired")
+	}
+}
+
+// Tests that a batch order signed once is verified after an rlp round trip,
+// that its amend operation is expanded into a cancel and a limit order and
+// that altering an operation invalidates the signature.
+func TestBatchOrders(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	ops := types.BatchOrderOps{
+		{Action: types.OrderBatchNew, Side: Bid, Quantity: big.NewInt(5), Price: big.NewInt(10)},
+		{Action: types.OrderBatchCancel, OrderID: 7, OrderHash: common.HexToHash("0x7")},
+		{Action: types.OrderBatchAmend, OrderID: 8, OrderHash: common.HexToHash("0x8"), Side: Ask, Quantity: big.NewInt(3), Price: big.NewInt(12), TimeInForce: PostOnly},
+	}
+	tx := types.NewOrderTransaction(4, nil, nil, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"), OrderNew, "", BatchOrder, common.Hash{}, 0)
+	tx.SetBatch(ops)
+	tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	V, R, S := tx.Signature()
+	order := &OrderItem{
+		Nonce:           big.NewInt(int64(tx.Nonce())),
+		Quantity:        tx.Quantity(),
+		Price:           tx.Price(),
+		ExchangeAddress: tx.ExchangeAddress(),
+		UserAddress:     tx.UserAddress(),
+		BaseToken:       tx.BaseToken(),
+		QuoteToken:      tx.QuoteToken(),
+		Status:          tx.Status(),
+		Type:            tx.Type(),
+		Batch:           tx.Batch(),
+		Signature:       &Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+	}
+	encoded, err := EncodeBytesItem(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &OrderItem{}
+	if err := DecodeBytesItem(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.VerifyBasicOrderInfo(); err != nil {
+		t.Fatalf("failed to verify batch order: %v", err)
+	}
+
+	orders := decoded.BatchOrders()
+	want := []struct {
+		status  string
+		orderID uint64
+		hash    common.Hash
+	}{
+		{OrderNew, 0, types.OrderTxSigner{}.BatchOrderOpHash(tx, 0)},
+		{Cancel, 7, common.HexToHash("0x7")},
+		{Cancel, 8, common.HexToHash("0x8")},
+		{OrderNew, 0, types.OrderTxSigner{}.BatchOrderOpHash(tx, 2)},
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("batch orders mismatch: have %d, want %d", len(orders), len(want))
+	}
+	for i, w := range want {
+		if orders[i].Status != w.status || orders[i].OrderID != w.orderID || orders[i].Hash != w.hash {
+			t.Errorf("order %d mismatch: have %s %d %x, want %s %d %x", i, orders[i].Status, orders[i].OrderID, orders[i].Hash, w.status, w.orderID, w.hash)
+		}
+	}
+	if amend := orders[3]; amend.Side != Ask || amend.Price.Int64() != 12 || amend.Quantity.Int64() != 3 || amend.TimeInForce != PostOnly {
+		t.Errorf("amending order mismatch: %+v", amend)
+	}
+
+	(*decoded.Batch)[2].Price = big.NewInt(11)
+	if err := decoded.VerifyBasicOrderInfo(); err != ErrInvalidSignature {
+		t.Errorf("altered batch order error mismatch: have %v, want %v", err, ErrInvalidSignature)
+	}
+	(*decoded.Batch)[2].Action = "REPLACE"
+	if err := decoded.VerifyBatch(); err != ErrInvalidBatchOrder {
+		t.Errorf("unknown operation error mismatch: have %v, want %v", err, ErrInvalidBatchOrder)
+	}
+}
@@ -40,6 +40,7 @@ type DumpOrderBookInfo struct {
 	LendingCount           *big.Int
 	MediumPrice            *big.Int
 	MediumPriceBeforeEpoch *big.Int
+	EpochPrices            []*big.Int
 	Nonce                  uint64
 	TotalQuantity          *big.Int
 	BestAsk                *big.Int
@@ -262,6 +263,7 @@ func (self *TradingStateDB) DumpOrderBookInfo(orderBook common.Hash) (*DumpOrder
 	result.LendingCount = exhangeObject.data.LendingCount
 	result.MediumPrice = exhangeObject.data.MediumPrice
 	result.MediumPriceBeforeEpoch = exhangeObject.data.MediumPriceBeforeEpoch
+	result.EpochPrices = exhangeObject.data.EpochPrices
 	result.Nonce = exhangeObject.data.Nonce
 	result.TotalQuantity = exhangeObject.data.TotalQuantity
 	result.BestAsk = new(big.Int).SetBytes(exhangeObject.getBestPriceAsksTrie(self.db).Bytes())
@@ -382,3 +384,37 @@ func (self *TradingStateDB) DumpLiquidationPriceTrie(orderBook common.Hash) (map
 	}
 	return mapResult, nil
 }
+
+// GetUserOrders returns the open orders of a user by order book, from the
+// given order book only unless orderBook is empty.
+func (self *TradingStateDB) GetUserOrders(user common.Address, orderBook common.Hash) (map[common.Hash][]OrderItem, error) {
+	result := map[common.Hash][]OrderItem{}
+	stateObject := self.getStateExchangeObject(user.Hash())
+	if stateObject == nil {
+		return result, nil
+	}
+	var start []byte
+	if !common.EmptyHash(orderBook) {
+		start = orderBook.Bytes()
+	}
+	it := trie.NewIterator(stateObject.getUserOrdersTrie(self.db).NodeIterator(start))
+	for it.Next() {
+		if len(it.Key) != 2*common.HashLength {
+			continue
+		}
+		book := common.BytesToHash(it.Key[:common.HashLength])
+		if !common.EmptyHash(orderBook) && book != orderBook {
+			break
+		}
+		orderId := common.BytesToHash(it.Key[common.HashLength:])
+		order := self.GetOrder(book, orderId)
+		if order.Quantity == nil || order.Quantity.Sign() <= 0 {
+			return nil, fmt.Errorf("indexed order not found orderBook : %v , orderId : %v ", book.Hex(), orderId.Hex())
+		}
+		result[book] = append(result[book], order)
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package tradingstate
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/contracts/FREx/feeschedule"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/log"
+)
+
+var ErrInvalidFeeSchedule = errors.New("invalid relayer fee schedule")
+
+// feeTierSize is the number of storage slots of a FeeTier of the fee schedule
+// contract.
+const feeTierSize = uint64(3)
+
+// FeeTier is the maker and taker fee rates a relayer charges the users who
+// traded at least Volume FRE during the last common.RelayerFeeVolumeEpochs
+// epochs. The rates are in 1 / common.FREXBaseFee of the traded quote token
+// quantity, a negative maker rate is a rebate paid to the maker.
+type FeeTier struct {
+	Volume   *big.Int `json:"volume"`
+	MakerFee *big.Int `json:"makerFee"`
+	TakerFee *big.Int `json:"takerFee"`
+}
+
+// ApplyFeeScheduleHardFork installs the code of the fee schedule contract, which
+// takes the relayer owners from the relayer registration, at the fee schedule
+// hardfork block.
+func ApplyFeeScheduleHardFork(statedb *state.StateDB) {
+	contract := common.HexToAddress(common.RelayerFeeScheduleSMC)
+	statedb.SetCode(contract, common.FromHex(feeschedule.RelayerFeeScheduleRuntimeBin))
+	slot := common.BigToHash(new(big.Int).SetUint64(FeeScheduleMappingSlot["RelayerRegistration"]))
+	statedb.SetState(contract, slot, common.HexToAddress(common.RelayerRegistrationSMC).Hash())
+}
+
+// GetRelayerFeeSchedule reads the fee schedule of a relayer from the fee
+// schedule contract. It returns nil if the relayer has no schedule or the
+// schedule is invalid, the relayer then charges its registration fee.
+func GetRelayerFeeSchedule(relayer common.Address, statedb *state.StateDB) []FeeTier {
+	contract := common.HexToAddress(common.RelayerFeeScheduleSMC)
+	slot := FeeScheduleMappingSlot["FEE_SCHEDULES"]
+	locHash := common.BigToHash(GetLocMappingAtKey(relayer.Hash(), slot))
+	length := statedb.GetState(contract, locHash).Big()
+	if length.Sign() == 0 || length.Cmp(new(big.Int).SetUint64(common.RelayerFeeTiers)) > 0 {
+		return nil
+	}
+	tiers := make([]FeeTier, 0, length.Uint64())
+	for i := uint64(0); i < length.Uint64(); i++ {
+		loc := state.GetLocDynamicArrAtElement(locHash, i, feeTierSize).Big()
+		get := func(field string) *big.Int {
+			fieldLoc := common.BigToHash(new(big.Int).Add(loc, FeeTierStructMappingSlot[field]))
+			return statedb.GetState(contract, fieldLoc).Big()
+		}
+		tiers = append(tiers, FeeTier{
+			Volume:   get("_volume"),
+			MakerFee: toInt256(get("_makerFee")),
+			TakerFee: get("_takerFee"),
+		})
+	}
+	if err := ValidateFeeSchedule(tiers); err != nil {
+		log.Debug("Invalid relayer fee schedule", "relayer", relayer.Hex(), "err", err)
+		return nil
+	}
+	return tiers
+}
+
+// ValidateFeeSchedule checks that the tiers are sorted by volume from a first
+// tier at 0, that the rates are below 100% and that no maker rebate exceeds
+// the taker fee of its tier, so that a relayer matching its own orders never
+// pays more rebates than it collects.
+func ValidateFeeSchedule(tiers []FeeTier) error {
+	if len(tiers) == 0 {
+		return ErrInvalidFeeSchedule
+	}
+	for i, tier := range tiers {
+		if i == 0 && tier.Volume.Sign() != 0 || i > 0 && tier.Volume.Cmp(tiers[i-1].Volume) <= 0 {
+			return ErrInvalidFeeSchedule
+		}
+		if tier.TakerFee.Sign() < 0 || tier.TakerFee.Cmp(common.FREXBaseFee) >= 0 {
+			return ErrInvalidFeeSchedule
+		}
+		if tier.MakerFee.Cmp(common.FREXBaseFee) >= 0 || new(big.Int).Neg(tier.MakerFee).Cmp(tier.TakerFee) > 0 {
+			return ErrInvalidFeeSchedule
+		}
+	}
+	return nil
+}
+
+// GetFeeTier returns the tier of a fee schedule applying to a user who traded
+// the given volume, the tier with the highest volume not above it.
+func GetFeeTier(tiers []FeeTier, volume *big.Int) FeeTier {
+	tier := tiers[0]
+	for _, t := range tiers[1:] {
+		if t.Volume.Cmp(volume) > 0 {
+			break
+		}
+		tier = t
+	}
+	return tier
+}
+
+// toInt256 interprets a storage word as a two's complement int256.
+func toInt256(value *big.Int) *big.Int {
+	if value.Bit(255) == 0 {
+		return value
+	}
+	return new(big.Int).Sub(value, new(big.Int).Lsh(common.Big1, 256))
+}
@@ -0,0 +1,50 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFeeSchedule(t *testing.T) {
+	tier := func(volume, makerFee, takerFee int64) FeeTier {
+		return FeeTier{Volume: big.NewInt(volume), MakerFee: big.NewInt(makerFee), TakerFee: big.NewInt(takerFee)}
+	}
+	tests := []struct {
+		name  string
+		tiers []FeeTier
+		valid bool
+	}{
+		{"empty", nil, false},
+		{"single tier", []FeeTier{tier(0, 10, 20)}, true},
+		{"rebates", []FeeTier{tier(0, 0, 20), tier(1000, -5, 15), tier(5000, -10, 10)}, true},
+		{"first tier above 0", []FeeTier{tier(10, 10, 20)}, false},
+		{"unsorted tiers", []FeeTier{tier(0, 10, 20), tier(5000, 5, 15), tier(1000, 0, 10)}, false},
+		{"rebate above taker fee", []FeeTier{tier(0, -25, 20)}, false},
+		{"taker fee of 100%", []FeeTier{tier(0, 10, 10000)}, false},
+		{"maker fee of 100%", []FeeTier{tier(0, 10000, 20)}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateFeeSchedule(tt.tiers); (err == nil) != tt.valid {
+			t.Errorf("%s: validation mismatch: have %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+
+	tiers := []FeeTier{tier(0, 0, 20), tier(1000, -5, 15), tier(5000, -10, 10)}
+	for _, tt := range []struct {
+		volume int64
+		want   int64
+	}{{0, 20}, {999, 20}, {1000, 15}, {4999, 15}, {5000, 10}, {100000, 10}} {
+		if fee := GetFeeTier(tiers, big.NewInt(tt.volume)).TakerFee; fee.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("taker fee mismatch at volume %d: have %v, want %d", tt.volume, fee, tt.want)
+		}
+	}
+
+	// int256 storage words
+	minusOne := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	if value := toInt256(minusOne); value.Cmp(big.NewInt(-1)) != 0 {
+		t.Errorf("int256 mismatch: have %v, want -1", value)
+	}
+	if value := toInt256(big.NewInt(7)); value.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("int256 mismatch: have %v, want 7", value)
+	}
+}
@@ -40,6 +40,22 @@ type (
 		orderId   common.Hash
 		order     OrderItem
 	}
+	insertTriggerOrder struct {
+		orderBook common.Hash
+		orderId   common.Hash
+		order     OrderItem
+	}
+	removeTriggerOrder struct {
+		orderBook common.Hash
+		orderId   common.Hash
+		order     OrderItem
+	}
+	userOrderChange struct {
+		user      common.Address
+		orderBook common.Hash
+		orderId   common.Hash
+		prev      bool
+	}
 	subAmountOrder struct {
 		orderBook common.Hash
 		orderId   common.Hash
@@ -63,6 +79,15 @@ type (
 		hash      common.Hash
 		prevPrice *big.Int
 	}
+	epochPricesChange struct {
+		hash common.Hash
+		prev []*big.Int
+	}
+	epochVolumesChange struct {
+		hash        common.Hash
+		prevEpoch   uint64
+		prevVolumes []*big.Int
+	}
 	insertLiquidationPrice struct {
 		orderBook   common.Hash
 		price       *big.Int
@@ -83,6 +108,17 @@ func (ch insertOrder) undo(s *TradingStateDB) {
 func (ch cancelOrder) undo(s *TradingStateDB) {
 	s.InsertOrderItem(ch.orderBook, ch.orderId, ch.order)
 }
+func (ch insertTriggerOrder) undo(s *TradingStateDB) {
+	s.RemoveTriggerOrder(ch.orderBook, ch.orderId)
+}
+func (ch removeTriggerOrder) undo(s *TradingStateDB) {
+	s.InsertTriggerOrder(ch.orderBook, ch.orderId, ch.order)
+}
+func (ch userOrderChange) undo(s *TradingStateDB) {
+	if stateObject := s.getStateExchangeObject(ch.user.Hash()); stateObject != nil {
+		stateObject.setUserOrder(s.db, ch.orderBook, ch.orderId, ch.prev)
+	}
+}
 func (ch insertLiquidationPrice) undo(s *TradingStateDB) {
 	s.RemoveLiquidationPrice(ch.orderBook, ch.price, ch.lendingBook, ch.tradeId)
 }
@@ -119,3 +155,11 @@ func (ch mediumPriceChange) undo(s *TradingStateDB) {
 func (ch mediumPriceBeforeEpochChange) undo(s *TradingStateDB) {
 	s.SetMediumPriceBeforeEpoch(ch.hash, ch.prevPrice)
 }
+func (ch epochPricesChange) undo(s *TradingStateDB) {
+	s.SetEpochPrices(ch.hash, ch.prev)
+}
+func (ch epochVolumesChange) undo(s *TradingStateDB) {
+	if stateObject := s.getStateExchangeObject(ch.hash); stateObject != nil {
+		stateObject.setEpochVolumes(ch.prevEpoch, ch.prevVolumes)
+	}
+}
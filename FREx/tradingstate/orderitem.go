@@ -1,6 +1,7 @@
 package tradingstate
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math/big"
 	"strconv"
@@ -43,6 +44,14 @@ type OrderItem struct {
 	UpdatedAt       time.Time      `json:"updatedAt,omitempty"`
 	OrderID         uint64         `json:"orderID,omitempty"`
 	ExtraData       string         `json:"extraData,omitempty"`
+	TriggerPrice    *big.Int       `json:"triggerPrice,omitempty" rlp:"optional"`
+	TimeInForce     string         `json:"timeInForce,omitempty" rlp:"optional"`
+	ExpireAt        uint64         `json:"expireAt,omitempty" rlp:"optional"`
+	SelfTradeMode   string         `json:"selfTradeMode,omitempty" rlp:"optional"`
+
+	// Operations of a batch order, see BatchOrders. The pointer keeps orders
+	// comparable.
+	Batch *types.BatchOrderOps `json:"batch,omitempty" rlp:"optional"`
 }
 
 // Signature struct
@@ -77,6 +86,10 @@ type OrderItemBSON struct {
 	UpdatedAt       time.Time        `json:"updatedAt,omitempty" bson:"updatedAt"`
 	OrderID         string           `json:"orderID,omitempty" bson:"orderID"`
 	ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+	TriggerPrice    string           `json:"triggerPrice,omitempty" bson:"triggerPrice,omitempty"`
+	TimeInForce     string           `json:"timeInForce,omitempty" bson:"timeInForce,omitempty"`
+	ExpireAt        uint64           `json:"expireAt,omitempty" bson:"expireAt,omitempty"`
+	SelfTradeMode   string           `json:"selfTradeMode,omitempty" bson:"selfTradeMode,omitempty"`
 }
 
 func (o *OrderItem) GetBSON() (interface{}, error) {
@@ -97,12 +110,19 @@ func (o *OrderItem) GetBSON() (interface{}, error) {
 		UpdatedAt:       o.UpdatedAt,
 		OrderID:         strconv.FormatUint(o.OrderID, 10),
 		ExtraData:       o.ExtraData,
+		TimeInForce:     o.TimeInForce,
+		ExpireAt:        o.ExpireAt,
+		SelfTradeMode:   o.SelfTradeMode,
 	}
 
 	if o.FilledAmount != nil {
 		or.FilledAmount = o.FilledAmount.String()
 	}
 
+	if o.TriggerPrice != nil {
+		or.TriggerPrice = o.TriggerPrice.String()
+	}
+
 	if o.Signature != nil {
 		or.Signature = &SignatureRecord{
 			V: o.Signature.V,
@@ -137,6 +157,10 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 		UpdatedAt       time.Time        `json:"updatedAt" bson:"updatedAt"`
 		OrderID         string           `json:"orderID" bson:"orderID"`
 		ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+		TriggerPrice    string           `json:"triggerPrice,omitempty" bson:"triggerPrice"`
+		TimeInForce     string           `json:"timeInForce,omitempty" bson:"timeInForce"`
+		ExpireAt        uint64           `json:"expireAt,omitempty" bson:"expireAt"`
+		SelfTradeMode   string           `json:"selfTradeMode,omitempty" bson:"selfTradeMode"`
 	})
 
 	err := raw.Unmarshal(decoded)
@@ -168,6 +192,10 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 		o.Price = ToBigInt(decoded.Price)
 	}
 
+	if decoded.TriggerPrice != "" {
+		o.TriggerPrice = ToBigInt(decoded.TriggerPrice)
+	}
+
 	if decoded.Signature != nil {
 		o.Signature = &Signature{
 			V: byte(decoded.Signature.V),
@@ -184,6 +212,9 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 	}
 	o.OrderID = uint64(orderID)
 	o.ExtraData = decoded.ExtraData
+	o.TimeInForce = decoded.TimeInForce
+	o.ExpireAt = decoded.ExpireAt
+	o.SelfTradeMode = decoded.SelfTradeMode
 	return nil
 }
 
@@ -205,13 +236,33 @@ func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
 
 // VerifyBasicOrderInfo verify basic info
 func (o *OrderItem) VerifyBasicOrderInfo() error {
+	if o.IsBatchOrder() {
+		if o.Status != OrderNew {
+			return ErrInvalidStatus
+		}
+		if err := o.VerifyBatch(); err != nil {
+			return err
+		}
+		return o.verifySignature()
+	}
 
 	if o.Status == OrderNew {
-		if o.Type == Limit {
+		if o.Type == Limit || o.Type == StopLimit {
 			if err := o.verifyPrice(); err != nil {
 				return err
 			}
 		}
+		if o.IsTriggerOrder() {
+			if err := o.VerifyTriggerPrice(); err != nil {
+				return err
+			}
+		}
+		if err := o.VerifyTimeInForce(); err != nil {
+			return err
+		}
+		if err := o.VerifySelfTradeMode(); err != nil {
+			return err
+		}
 		if err := o.verifyQuantity(); err != nil {
 			return err
 		}
@@ -239,7 +290,7 @@ func (o *OrderItem) verifyRelayer(state *state.StateDB) error {
 	return nil
 }
 
-//verify signatures
+// verify signatures
 func (o *OrderItem) verifySignature() error {
 	bigstr := o.Nonce.String()
 	n, err := strconv.ParseInt(bigstr, 10, 64)
@@ -250,8 +301,7 @@ func (o *OrderItem) verifySignature() error {
 	R := o.Signature.R.Big()
 	S := o.Signature.S.Big()
 
-	tx := types.NewOrderTransaction(uint64(n), o.Quantity, o.Price, o.ExchangeAddress, o.UserAddress,
-		o.BaseToken, o.QuoteToken, o.Status, o.Side, o.Type, o.Hash, o.OrderID)
+	tx := o.orderTransaction(uint64(n))
 	tx.ImportSignature(V, R, S)
 	from, _ := types.OrderSender(types.OrderTxSigner{}, tx)
 	if from != tx.UserAddress() {
@@ -260,8 +310,127 @@ func (o *OrderItem) verifySignature() error {
 	return nil
 }
 
+// orderTransaction rebuilds the order transaction signed by the user
+func (o *OrderItem) orderTransaction(nonce uint64) *types.OrderTransaction {
+	tx := types.NewOrderTransaction(nonce, o.Quantity, o.Price, o.ExchangeAddress, o.UserAddress,
+		o.BaseToken, o.QuoteToken, o.Status, o.Side, o.Type, o.Hash, o.OrderID)
+	tx.SetTriggerPrice(o.TriggerPrice)
+	tx.SetTimeInForce(o.TimeInForce, o.ExpireAt)
+	tx.SetSelfTradeMode(o.SelfTradeMode)
+	if o.Batch != nil {
+		tx.SetBatch(*o.Batch)
+	}
+	return tx
+}
+
+// IsBatchOrder returns whether the order is a batch of operations signed once
+func (o *OrderItem) IsBatchOrder() bool {
+	return o.Type == BatchOrder
+}
+
+func (o *OrderItem) batchOps() types.BatchOrderOps {
+	if o.Batch == nil {
+		return nil
+	}
+	return *o.Batch
+}
+
+// BatchOrders returns the orders of a batch order, in the order they are
+// applied: a cancel order for a cancel operation, a limit order for a new
+// operation, and both for an amend operation. The placed orders get the hash
+// of their operation, the cancel orders the hash of the order they cancel.
+func (o *OrderItem) BatchOrders() []*OrderItem {
+	var (
+		orders []*OrderItem
+		tx     = o.orderTransaction(o.Nonce.Uint64())
+		signer = types.OrderTxSigner{}
+	)
+	for i, op := range o.batchOps() {
+		item := OrderItem{
+			ExchangeAddress: o.ExchangeAddress,
+			UserAddress:     o.UserAddress,
+			BaseToken:       o.BaseToken,
+			QuoteToken:      o.QuoteToken,
+			Nonce:           o.Nonce,
+			Signature:       o.Signature,
+			Side:            op.Side,
+		}
+		if op.Action == types.OrderBatchCancel || op.Action == types.OrderBatchAmend {
+			cancel := item
+			cancel.Status = Cancel
+			cancel.Hash = op.OrderHash
+			cancel.OrderID = op.OrderID
+			cancel.Quantity = new(big.Int)
+			cancel.Price = new(big.Int)
+			orders = append(orders, &cancel)
+		}
+		if op.Action == types.OrderBatchNew || op.Action == types.OrderBatchAmend {
+			order := item
+			order.Status = OrderNew
+			order.Type = Limit
+			order.Hash = signer.BatchOrderOpHash(tx, i)
+			order.Quantity = new(big.Int)
+			if op.Quantity != nil {
+				order.Quantity.Set(op.Quantity)
+			}
+			order.Price = new(big.Int)
+			if op.Price != nil {
+				order.Price.Set(op.Price)
+			}
+			order.TimeInForce = op.TimeInForce
+			order.ExpireAt = op.ExpireAt
+			order.SelfTradeMode = op.SelfTradeMode
+			orders = append(orders, &order)
+		}
+	}
+	return orders
+}
+
+// VerifyBatch make sure the batch order has between 1 and
+// common.BatchOrderMaxOps supported operations and that its orders are valid
+func (o *OrderItem) VerifyBatch() error {
+	ops := o.batchOps()
+	if len(ops) == 0 || len(ops) > common.BatchOrderMaxOps {
+		log.Debug("Invalid number of batch operations", "len", len(ops))
+		return ErrInvalidBatchOrder
+	}
+	for _, op := range ops {
+		if op == nil || op.Action != types.OrderBatchNew && op.Action != types.OrderBatchCancel && op.Action != types.OrderBatchAmend {
+			log.Debug("Invalid batch operation", "op", op)
+			return ErrInvalidBatchOrder
+		}
+	}
+	for _, order := range o.BatchOrders() {
+		if order.Status == Cancel {
+			if order.OrderID == 0 {
+				return ErrInvalidBatchOrder
+			}
+			continue
+		}
+		if err := order.verifyPrice(); err != nil {
+			return err
+		}
+		if err := order.VerifyTimeInForce(); err != nil {
+			return err
+		}
+		if err := order.VerifySelfTradeMode(); err != nil {
+			return err
+		}
+		if err := order.verifyQuantity(); err != nil {
+			return err
+		}
+		if err := order.verifyOrderSide(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // verify order type
 func (o *OrderItem) verifyOrderType() error {
+	if _, ok := TriggerOrderType[o.Type]; ok {
+		return nil
+	}
 	if _, ok := MatchingOrderType[o.Type]; !ok {
 		log.Debug("Invalid order type", "type", o.Type)
 		return ErrInvalidOrderType
@@ -269,7 +438,7 @@ func (o *OrderItem) verifyOrderType() error {
 	return nil
 }
 
-//verify order side
+// verify order side
 func (o *OrderItem) verifyOrderSide() error {
 
 	if o.Side != Bid && o.Side != Ask {
@@ -295,6 +464,110 @@ func (o *OrderItem) verifyPrice() error {
 	return nil
 }
 
+// VerifyTriggerPrice make sure trigger price is a positive number fitting in
+// the trigger trie key
+func (o *OrderItem) VerifyTriggerPrice() error {
+	if o.TriggerPrice == nil || o.TriggerPrice.Sign() <= 0 || len(o.TriggerPrice.Bytes()) > triggerPriceLength {
+		log.Debug("Invalid trigger price", "triggerPrice", o.TriggerPrice)
+		return ErrInvalidTriggerPrice
+	}
+	return nil
+}
+
+// VerifyTimeInForce make sure the time in force is supported and only set on
+// limit orders, good till time orders must have an expiry time
+func (o *OrderItem) VerifyTimeInForce() error {
+	if o.TimeInForce == "" {
+		return nil
+	}
+	if !TimeInForceType[o.TimeInForce] || o.ActivatedType() != Limit {
+		log.Debug("Invalid time in force", "type", o.Type, "timeInForce", o.TimeInForce)
+		return ErrInvalidTimeInForce
+	}
+	if (o.TimeInForce == GoodTillTime) != (o.ExpireAt > 0) {
+		log.Debug("Invalid expiry time", "timeInForce", o.TimeInForce, "expireAt", o.ExpireAt)
+		return ErrInvalidTimeInForce
+	}
+	return nil
+}
+
+// VerifySelfTradeMode make sure the self-trade prevention mode is supported
+func (o *OrderItem) VerifySelfTradeMode() error {
+	if o.SelfTradeMode != "" && !SelfTradePreventionMode[o.SelfTradeMode] {
+		log.Debug("Invalid self-trade prevention mode", "selfTradeMode", o.SelfTradeMode)
+		return ErrInvalidSelfTrade
+	}
+	return nil
+}
+
+// SelfTradePrevention returns what happens when the order meets an order of
+// the same user, the newest order is cancelled by default
+func (o *OrderItem) SelfTradePrevention() string {
+	if o.SelfTradeMode == "" {
+		return CancelNewest
+	}
+	return o.SelfTradeMode
+}
+
+// IsExpired returns whether a good till time order is expired at the given
+// block time
+func (o *OrderItem) IsExpired(blockTime uint64) bool {
+	return o.TimeInForce == GoodTillTime && o.ExpireAt <= blockTime
+}
+
+// IsTriggerOrder returns whether the order waits in the trigger trie for the
+// last price to cross its trigger price
+func (o *OrderItem) IsTriggerOrder() bool {
+	_, ok := TriggerOrderType[o.Type]
+	return ok
+}
+
+// ActivatedType returns the type the order is matched as: market or limit for
+// a trigger order, its own type otherwise
+func (o *OrderItem) ActivatedType() string {
+	if orderType, ok := TriggerOrderType[o.Type]; ok {
+		return orderType
+	}
+	return o.Type
+}
+
+// IsTriggered returns whether the given last price activates the trigger order.
+// Stop buy and take profit sell orders are activated by a price rising to the
+// trigger price, stop sell and take profit buy orders by a price falling to it.
+func (o *OrderItem) IsTriggered(lastPrice *big.Int) bool {
+	if lastPrice == nil || lastPrice.Sign() <= 0 || o.TriggerPrice == nil {
+		return false
+	}
+	if o.triggerDirection() == triggerOnRise {
+		return lastPrice.Cmp(o.TriggerPrice) >= 0
+	}
+	return lastPrice.Cmp(o.TriggerPrice) <= 0
+}
+
+func (o *OrderItem) triggerDirection() byte {
+	if (o.Type == TakeProfit) == (o.Side == Bid) {
+		return triggerOnFall
+	}
+	return triggerOnRise
+}
+
+// triggerKey returns the key of the order in the trigger trie: the trigger
+// direction, the trigger price and the order id. The order id of orders
+// activated by a falling price is inverted so that orders sharing a trigger
+// price are activated in arrival order from both ends of the trie.
+func (o *OrderItem) triggerKey() common.Hash {
+	var key common.Hash
+	key[0] = o.triggerDirection()
+	price := o.TriggerPrice.Bytes()
+	copy(key[1+triggerPriceLength-len(price):], price)
+	orderId := o.OrderID
+	if key[0] == triggerOnFall {
+		orderId = ^orderId
+	}
+	binary.BigEndian.PutUint64(key[common.HashLength-8:], orderId)
+	return key
+}
+
 // verifyQuantity make sure quantity is a positive number
 func (o *OrderItem) verifyQuantity() error {
 	if o.Quantity == nil || o.Quantity.Cmp(big.NewInt(0)) <= 0 {
@@ -355,35 +628,92 @@ func VerifyPair(statedb *state.StateDB, exchangeAddress, baseToken, quoteToken c
 }
 
 func VerifyBalance(statedb *state.StateDB, FRExStateDb *TradingStateDB, order *types.OrderTransaction, baseDecimal, quoteDecimal *big.Int) error {
-	var quotePrice *big.Int
-	if order.QuoteToken().String() != common.FRENativeAddress {
-		quotePrice = FRExStateDb.GetLastPrice(GetTradingOrderBookHash(order.QuoteToken(), common.HexToAddress(common.FRENativeAddress)))
-		log.Debug("TryGet quotePrice QuoteToken/FRE", "quotePrice", quotePrice)
-		if quotePrice == nil || quotePrice.Sign() == 0 {
-			inversePrice := FRExStateDb.GetLastPrice(GetTradingOrderBookHash(common.HexToAddress(common.FRENativeAddress), order.QuoteToken()))
-			log.Debug("TryGet inversePrice FRE/QuoteToken", "inversePrice", inversePrice)
-			if inversePrice != nil && inversePrice.Sign() > 0 {
-				quotePrice = new(big.Int).Mul(common.BasePrice, quoteDecimal)
-				quotePrice = new(big.Int).Div(quotePrice, inversePrice)
-				log.Debug("TryGet quotePrice after get inversePrice FRE/QuoteToken", "quotePrice", quotePrice, "quoteTokenDecimal", quoteDecimal)
-			}
-		}
-	} else {
-		quotePrice = common.BasePrice
+	price := order.Price()
+	if order.IsTriggerTypeOrder() && (price == nil || price.Sign() == 0) {
+		price = order.TriggerPrice()
 	}
-	feeRate := GetExRelayerFee(order.ExchangeAddress(), statedb)
-	balanceResult, err := GetSettleBalance(quotePrice, order.Side(), feeRate, order.BaseToken(), order.QuoteToken(), order.Price(), feeRate, baseDecimal, quoteDecimal, order.Quantity())
+	return verifyBalance(statedb, FRExStateDb, order.ExchangeAddress(), order.UserAddress(), order.Side(), order.BaseToken(), order.QuoteToken(), price, order.Quantity(), baseDecimal, quoteDecimal)
+}
+
+// VerifyTriggerOrderBalance checks the user of a trigger order holds the tokens
+// it spends once activated. The stop market and take profit orders have no
+// price, they are valued at their trigger price.
+func VerifyTriggerOrderBalance(statedb *state.StateDB, FRExStateDb *TradingStateDB, order *OrderItem, baseDecimal, quoteDecimal *big.Int) error {
+	price := order.Price
+	if price == nil || price.Sign() == 0 {
+		price = order.TriggerPrice
+	}
+	return verifyBalance(statedb, FRExStateDb, order.ExchangeAddress, order.UserAddress, order.Side, order.BaseToken, order.QuoteToken, price, order.Quantity, baseDecimal, quoteDecimal)
+}
+
+func verifyBalance(statedb *state.StateDB, FRExStateDb *TradingStateDB, exchangeAddress, userAddress common.Address, side string, baseToken, quoteToken common.Address, price, quantity, baseDecimal, quoteDecimal *big.Int) error {
+	quotePrice := getQuotePrice(FRExStateDb, quoteToken, quoteDecimal)
+	feeRate := GetExRelayerFee(exchangeAddress, statedb)
+	balanceResult, err := GetSettleBalance(quotePrice, side, feeRate, baseToken, quoteToken, price, feeRate, baseDecimal, quoteDecimal, quantity)
 	if err != nil {
 		return err
 	}
 	expectedBalance := balanceResult.Taker.OutTotal
-	actualBalance := GetTokenBalance(order.UserAddress(), balanceResult.Taker.OutToken, statedb)
+	actualBalance := GetTokenBalance(userAddress, balanceResult.Taker.OutToken, statedb)
 	if actualBalance.Cmp(expectedBalance) < 0 {
 		return fmt.Errorf("token: %s . ExpectedBalance: %s . ActualBalance: %s", balanceResult.Taker.OutToken.Hex(), expectedBalance.String(), actualBalance.String())
 	}
 	return nil
 }
 
+// VerifyBatchBalance checks the user of a batch order holds the tokens its new
+// orders spend, summed by token as the orders of the batch are applied
+// together.
+func VerifyBatchBalance(statedb *state.StateDB, FRExStateDb *TradingStateDB, order *OrderItem, baseDecimal, quoteDecimal *big.Int) error {
+	quotePrice := getQuotePrice(FRExStateDb, order.QuoteToken, quoteDecimal)
+	feeRate := GetExRelayerFee(order.ExchangeAddress, statedb)
+	expectedBalances := make(map[common.Address]*big.Int)
+	var tokens []common.Address
+	for _, item := range order.BatchOrders() {
+		if item.Status == Cancel {
+			continue
+		}
+		balanceResult, err := GetSettleBalance(quotePrice, item.Side, feeRate, item.BaseToken, item.QuoteToken, item.Price, feeRate, baseDecimal, quoteDecimal, item.Quantity)
+		if err != nil {
+			return err
+		}
+		token := balanceResult.Taker.OutToken
+		if _, ok := expectedBalances[token]; !ok {
+			expectedBalances[token] = new(big.Int)
+			tokens = append(tokens, token)
+		}
+		expectedBalances[token].Add(expectedBalances[token], balanceResult.Taker.OutTotal)
+	}
+	for _, token := range tokens {
+		expectedBalance := expectedBalances[token]
+		actualBalance := GetTokenBalance(order.UserAddress, token, statedb)
+		if actualBalance.Cmp(expectedBalance) < 0 {
+			return fmt.Errorf("token: %s . ExpectedBalance: %s . ActualBalance: %s", token.Hex(), expectedBalance.String(), actualBalance.String())
+		}
+	}
+	return nil
+}
+
+// getQuotePrice returns the price of the quote token in FRE, from the
+// QuoteToken/FRE order book or else the inverse of the FRE/QuoteToken one.
+func getQuotePrice(FRExStateDb *TradingStateDB, quoteToken common.Address, quoteDecimal *big.Int) *big.Int {
+	if quoteToken.String() == common.FRENativeAddress {
+		return common.BasePrice
+	}
+	quotePrice := FRExStateDb.GetLastPrice(GetTradingOrderBookHash(quoteToken, common.HexToAddress(common.FRENativeAddress)))
+	log.Debug("TryGet quotePrice QuoteToken/FRE", "quotePrice", quotePrice)
+	if quotePrice == nil || quotePrice.Sign() == 0 {
+		inversePrice := FRExStateDb.GetLastPrice(GetTradingOrderBookHash(common.HexToAddress(common.FRENativeAddress), quoteToken))
+		log.Debug("TryGet inversePrice FRE/QuoteToken", "inversePrice", inversePrice)
+		if inversePrice != nil && inversePrice.Sign() > 0 {
+			quotePrice = new(big.Int).Mul(common.BasePrice, quoteDecimal)
+			quotePrice = new(big.Int).Div(quotePrice, inversePrice)
+			log.Debug("TryGet quotePrice after get inversePrice FRE/QuoteToken", "quotePrice", quotePrice, "quoteTokenDecimal", quoteDecimal)
+		}
+	}
+	return quotePrice
+}
+
 // MarshalSignature marshals the signature struct to []byte
 func (s *Signature) MarshalSignature() ([]byte, error) {
 	sigBytes1 := s.R.Bytes()
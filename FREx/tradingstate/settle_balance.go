@@ -38,8 +38,9 @@ func GetSettleBalance(quotePrice *big.Int, takerSide string, takerFeeRate *big.I
 	quoteTokenQuantity := new(big.Int).Mul(quantityToTrade, makerPrice)
 	quoteTokenQuantity = new(big.Int).Div(quoteTokenQuantity, baseTokenDecimal)
 
+	// a negative maker fee is a rebate, rounded towards zero
 	makerFee := new(big.Int).Mul(quoteTokenQuantity, makerFeeRate)
-	makerFee = new(big.Int).Div(makerFee, common.FREXBaseFee)
+	makerFee = new(big.Int).Quo(makerFee, common.FREXBaseFee)
 	takerFee := new(big.Int).Mul(quoteTokenQuantity, takerFeeRate)
 	takerFee = new(big.Int).Div(takerFee, common.FREXBaseFee)
 
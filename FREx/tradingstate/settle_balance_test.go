@@ -13,6 +13,9 @@ func TestGetSettleBalance(t *testing.T) {
 	tradeQuantity, _ := new(big.Int).SetString("1000000000000000000000", 10)
 	tradeQuantityIncludedFee, _ := new(big.Int).SetString("1001000000000000000000", 10)
 	tradeQuantityExcludedFee, _ := new(big.Int).SetString("999000000000000000000", 10)
+	testRebate, _ := new(big.Int).SetString("-500000000000000000", 10)
+	tradeQuantityIncludedRebate, _ := new(big.Int).SetString("1000500000000000000000", 10)
+	tradeQuantityExcludedRebate, _ := new(big.Int).SetString("999500000000000000000", 10)
 	type GetSettleBalanceArg struct {
 		quotePrice        *big.Int
 		takerSide         string
@@ -244,6 +247,46 @@ func TestGetSettleBalance(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"BUY, maker rebate",
+			GetSettleBalanceArg{
+				quotePrice:        common.BasePrice,
+				takerSide:         Bid,
+				takerFeeRate:      big.NewInt(10), // feeRate 0.1%
+				baseToken:         testToken,
+				quoteToken:        common.HexToAddress(common.FRENativeAddress),
+				makerPrice:        common.BasePrice,
+				makerFeeRate:      big.NewInt(-5), // rebate 0.05%
+				baseTokenDecimal:  common.BasePrice,
+				quoteTokenDecimal: common.BasePrice,
+				quantityToTrade:   new(big.Int).Mul(big.NewInt(1000), common.BasePrice),
+			},
+			&SettleBalance{
+				Taker: TradeResult{Fee: testFee, InToken: testToken, InTotal: tradeQuantity, OutToken: common.HexToAddress(common.FRENativeAddress), OutTotal: tradeQuantityIncludedFee},
+				Maker: TradeResult{Fee: testRebate, InToken: common.HexToAddress(common.FRENativeAddress), InTotal: tradeQuantityIncludedRebate, OutToken: testToken, OutTotal: tradeQuantity},
+			},
+			false,
+		},
+		{
+			"SELL, maker rebate",
+			GetSettleBalanceArg{
+				quotePrice:        common.BasePrice,
+				takerSide:         Ask,
+				takerFeeRate:      big.NewInt(10), // feeRate 0.1%
+				baseToken:         testToken,
+				quoteToken:        common.HexToAddress(common.FRENativeAddress),
+				makerPrice:        common.BasePrice,
+				makerFeeRate:      big.NewInt(-5), // rebate 0.05%
+				baseTokenDecimal:  common.BasePrice,
+				quoteTokenDecimal: common.BasePrice,
+				quantityToTrade:   new(big.Int).Mul(big.NewInt(1000), common.BasePrice),
+			},
+			&SettleBalance{
+				Maker: TradeResult{Fee: testRebate, InToken: testToken, InTotal: tradeQuantity, OutToken: common.HexToAddress(common.FRENativeAddress), OutTotal: tradeQuantityExcludedRebate},
+				Taker: TradeResult{Fee: testFee, InToken: common.HexToAddress(common.FRENativeAddress), InTotal: tradeQuantityExcludedFee, OutToken: testToken, OutTotal: tradeQuantity},
+			},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
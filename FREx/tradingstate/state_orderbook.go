@@ -49,6 +49,8 @@ type tradingExchanges struct {
 	bidsTrie             Trie // storage trie, which becomes non-nil on first access
 	ordersTrie           Trie // storage trie, which becomes non-nil on first access
 	liquidationPriceTrie Trie
+	triggerOrdersTrie    Trie // trigger key -> order id of the stop and take profit orders
+	userOrdersTrie       Trie // order book + order id -> order id of the open orders of a user
 
 	stateAskObjects      map[common.Hash]*stateOrderList
 	stateAskObjectsDirty map[common.Hash]struct{}
@@ -97,6 +99,18 @@ func (s *tradingExchanges) empty() bool {
 	if !common.EmptyHash(s.data.LiquidationPriceRoot) {
 		return false
 	}
+	if !common.EmptyHash(s.data.TriggerOrderRoot) {
+		return false
+	}
+	if len(s.data.EpochPrices) > 0 {
+		return false
+	}
+	if !common.EmptyHash(s.data.UserOrderRoot) {
+		return false
+	}
+	if len(s.data.EpochVolumes) > 0 {
+		return false
+	}
 	return true
 }
 
@@ -327,6 +341,12 @@ func (self *tradingExchanges) deepCopy(db *TradingStateDB, onDirty func(hash com
 	if self.ordersTrie != nil {
 		stateExchanges.ordersTrie = db.db.CopyTrie(self.ordersTrie)
 	}
+	if self.triggerOrdersTrie != nil {
+		stateExchanges.triggerOrdersTrie = db.db.CopyTrie(self.triggerOrdersTrie)
+	}
+	if self.userOrdersTrie != nil {
+		stateExchanges.userOrdersTrie = db.db.CopyTrie(self.userOrdersTrie)
+	}
 	for price, bidObject := range self.stateBidObjects {
 		stateExchanges.stateBidObjects[price] = bidObject.deepCopy(db, self.MarkStateBidObjectDirty)
 	}
@@ -391,6 +411,23 @@ func (self *tradingExchanges) setMediumPriceBeforeEpoch(price *big.Int) {
 	}
 }
 
+func (self *tradingExchanges) setEpochPrices(prices []*big.Int) {
+	self.data.EpochPrices = prices
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+func (self *tradingExchanges) setEpochVolumes(epoch uint64, volumes []*big.Int) {
+	self.data.VolumeEpoch = epoch
+	self.data.EpochVolumes = volumes
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
 func (self *tradingExchanges) setMediumPrice(price *big.Int, quantity *big.Int) {
 	self.data.MediumPrice = price
 	self.data.TotalQuantity = quantity
@@ -788,6 +825,157 @@ func (self *tradingExchanges) CommitLiquidationPriceTrie(db Database) error {
 	return err
 }
 
+func (self *tradingExchanges) getTriggerOrdersTrie(db Database) Trie {
+	if self.triggerOrdersTrie == nil {
+		var err error
+		self.triggerOrdersTrie, err = db.OpenStorageTrie(self.orderBookHash, self.data.TriggerOrderRoot)
+		if err != nil {
+			self.triggerOrdersTrie, _ = db.OpenStorageTrie(self.orderBookHash, EmptyHash)
+			self.setError(fmt.Errorf("can't create trigger orders trie: %v", err))
+		}
+	}
+	return self.triggerOrdersTrie
+}
+
+func (self *tradingExchanges) insertTriggerOrder(db Database, order *OrderItem) {
+	orderId := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
+	key := order.triggerKey()
+	self.setError(self.getTriggerOrdersTrie(db).TryUpdate(key[:], orderId[:]))
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+func (self *tradingExchanges) removeTriggerOrder(db Database, order *OrderItem) {
+	key := order.triggerKey()
+	self.setError(self.getTriggerOrdersTrie(db).TryDelete(key[:]))
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// getFirstTriggerOrderId returns the id of the trigger order activated first
+// in the given direction: the lowest trigger price for a rising price, the
+// highest one for a falling price.
+func (self *tradingExchanges) getFirstTriggerOrderId(db Database, direction byte) (common.Hash, bool) {
+	var (
+		encKey, encValue []byte
+		err              error
+	)
+	trie := self.getTriggerOrdersTrie(db)
+	if direction == triggerOnRise {
+		encKey, encValue, err = trie.TryGetBestLeftKeyAndValue()
+	} else {
+		encKey, encValue, err = trie.TryGetBestRightKeyAndValue()
+	}
+	if err != nil {
+		log.Error("Failed find first trigger order", "orderbook", self.orderBookHash.Hex(), "direction", direction, "err", err)
+		return EmptyHash, false
+	}
+	if len(encKey) != common.HashLength || len(encValue) == 0 || encKey[0] != direction {
+		return EmptyHash, false
+	}
+	return common.BytesToHash(encValue), true
+}
+
+// updateTriggerOrdersRoot sets the root of the trigger orders trie, it is left
+// zero as long as the order book never had any trigger order so that the
+// encoding of the order book doesn't change.
+func (self *tradingExchanges) updateTriggerOrdersRoot(db Database) {
+	if self.triggerOrdersTrie == nil {
+		return
+	}
+	self.data.TriggerOrderRoot = self.triggerOrdersTrie.Hash()
+	if self.data.TriggerOrderRoot == EmptyRoot {
+		self.data.TriggerOrderRoot = EmptyHash
+	}
+}
+
+func (self *tradingExchanges) CommitTriggerOrdersTrie(db Database) error {
+	if self.triggerOrdersTrie == nil {
+		return nil
+	}
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	root, err := self.triggerOrdersTrie.Commit(nil)
+	if err == nil {
+		if root == EmptyRoot {
+			root = EmptyHash
+		}
+		self.data.TriggerOrderRoot = root
+	}
+	return err
+}
+
+func (self *tradingExchanges) getUserOrdersTrie(db Database) Trie {
+	if self.userOrdersTrie == nil {
+		var err error
+		self.userOrdersTrie, err = db.OpenStorageTrie(self.orderBookHash, self.data.UserOrderRoot)
+		if err != nil {
+			self.userOrdersTrie, _ = db.OpenStorageTrie(self.orderBookHash, EmptyHash)
+			self.setError(fmt.Errorf("can't create user orders trie: %v", err))
+		}
+	}
+	return self.userOrdersTrie
+}
+
+// hasUserOrder reports whether the order is indexed as an open order of the
+// user owning this object.
+func (self *tradingExchanges) hasUserOrder(db Database, orderBook common.Hash, orderId common.Hash) bool {
+	value, err := self.getUserOrdersTrie(db).TryGet(userOrderKey(orderBook, orderId))
+	if err != nil {
+		self.setError(err)
+		return false
+	}
+	return len(value) > 0
+}
+
+func (self *tradingExchanges) setUserOrder(db Database, orderBook common.Hash, orderId common.Hash, open bool) {
+	key := userOrderKey(orderBook, orderId)
+	if open {
+		self.setError(self.getUserOrdersTrie(db).TryUpdate(key, orderId[:]))
+	} else {
+		self.setError(self.getUserOrdersTrie(db).TryDelete(key))
+	}
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// updateUserOrdersRoot sets the root of the user orders trie, it is left zero
+// as long as no order of the user was indexed so that the encoding of the
+// object doesn't change.
+func (self *tradingExchanges) updateUserOrdersRoot(db Database) {
+	if self.userOrdersTrie == nil {
+		return
+	}
+	self.data.UserOrderRoot = self.userOrdersTrie.Hash()
+	if self.data.UserOrderRoot == EmptyRoot {
+		self.data.UserOrderRoot = EmptyHash
+	}
+}
+
+func (self *tradingExchanges) CommitUserOrdersTrie(db Database) error {
+	if self.userOrdersTrie == nil {
+		return nil
+	}
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	root, err := self.userOrdersTrie.Commit(nil)
+	if err == nil {
+		if root == EmptyRoot {
+			root = EmptyHash
+		}
+		self.data.UserOrderRoot = root
+	}
+	return err
+}
+
 func (c *tradingExchanges) addLendingCount(amount *big.Int) {
 	c.setLendingCount(new(big.Int).Add(c.data.LendingCount, amount))
 }
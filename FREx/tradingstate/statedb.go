@@ -59,6 +59,10 @@ type TradingStateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// userIndex enables the index of the open orders of every user, it is
+	// switched on by the order processor once the index is part of consensus.
+	userIndex bool
+
 	lock sync.Mutex
 }
 
@@ -125,6 +129,16 @@ func (self *TradingStateDB) GetMediumPriceBeforeEpoch(addr common.Hash) *big.Int
 	return Zero
 }
 
+// GetEpochPrices returns the average prices of the last epochs of an order
+// book, newest first.
+func (self *TradingStateDB) GetEpochPrices(addr common.Hash) []*big.Int {
+	stateObject := self.getStateExchangeObject(addr)
+	if stateObject != nil {
+		return stateObject.data.EpochPrices
+	}
+	return nil
+}
+
 func (self *TradingStateDB) GetMediumPriceAndTotalAmount(addr common.Hash) (*big.Int, *big.Int) {
 	stateObject := self.getStateExchangeObject(addr)
 	if stateObject != nil {
@@ -183,6 +197,77 @@ func (self *TradingStateDB) SetMediumPriceBeforeEpoch(addr common.Hash, price *b
 	}
 }
 
+// SetEpochPrices replaces the epoch average price history of an order book.
+// The given slice must not be modified afterwards.
+func (self *TradingStateDB) SetEpochPrices(addr common.Hash, prices []*big.Int) {
+	stateObject := self.GetOrNewStateExchangeObject(addr)
+	if stateObject != nil {
+		self.journal = append(self.journal, epochPricesChange{
+			hash: addr,
+			prev: stateObject.data.EpochPrices,
+		})
+		stateObject.setEpochPrices(prices)
+	}
+}
+
+// GetTradedVolume returns the FRE volume traded by a user during the
+// common.RelayerFeeVolumeEpochs epochs completed before the given epoch.
+func (self *TradingStateDB) GetTradedVolume(user common.Address, epoch uint64) *big.Int {
+	total := new(big.Int)
+	stateObject := self.getStateExchangeObject(user.Hash())
+	if stateObject == nil {
+		return total
+	}
+	for i, volume := range stateObject.data.EpochVolumes {
+		if uint64(i) > stateObject.data.VolumeEpoch {
+			break
+		}
+		// the volumes are kept newest first, one per epoch
+		traded := stateObject.data.VolumeEpoch - uint64(i)
+		if traded >= epoch {
+			continue
+		}
+		if epoch-traded > uint64(common.RelayerFeeVolumeEpochs) {
+			break
+		}
+		total.Add(total, volume)
+	}
+	return total
+}
+
+// AddTradedVolume adds a FRE volume traded by a user during the given epoch to
+// its volume history, at most common.RelayerFeeVolumeEpochs completed epochs
+// are kept after the current one.
+func (self *TradingStateDB) AddTradedVolume(user common.Address, epoch uint64, volume *big.Int) {
+	stateObject := self.GetOrNewStateExchangeObject(user.Hash())
+	if stateObject == nil {
+		return
+	}
+	prevEpoch, prevVolumes := stateObject.data.VolumeEpoch, stateObject.data.EpochVolumes
+	volumes := []*big.Int{}
+	if len(prevVolumes) > 0 && prevEpoch == epoch {
+		volumes = append(volumes, new(big.Int).Add(prevVolumes[0], volume))
+		volumes = append(volumes, prevVolumes[1:]...)
+	} else {
+		volumes = append(volumes, CloneBigInt(volume))
+		if len(prevVolumes) > 0 && epoch > prevEpoch {
+			for gap := epoch - prevEpoch - 1; gap > 0 && len(volumes) <= common.RelayerFeeVolumeEpochs; gap-- {
+				volumes = append(volumes, new(big.Int))
+			}
+			volumes = append(volumes, prevVolumes...)
+		}
+	}
+	if len(volumes) > common.RelayerFeeVolumeEpochs+1 {
+		volumes = volumes[:common.RelayerFeeVolumeEpochs+1]
+	}
+	self.journal = append(self.journal, epochVolumesChange{
+		hash:        user.Hash(),
+		prevEpoch:   prevEpoch,
+		prevVolumes: prevVolumes,
+	})
+	stateObject.setEpochVolumes(epoch, volumes)
+}
+
 func (self *TradingStateDB) InsertOrderItem(orderBook common.Hash, orderId common.Hash, order OrderItem) {
 	priceHash := common.BigToHash(order.Price)
 	stateExchange := self.getStateExchangeObject(orderBook)
@@ -212,6 +297,7 @@ func (self *TradingStateDB) InsertOrderItem(orderBook common.Hash, orderId commo
 	stateExchange.createStateOrderObject(self.db, orderId, order)
 	stateOrderList.insertOrderItem(self.db, orderId, common.BigToHash(order.Quantity))
 	stateOrderList.AddVolume(order.Quantity)
+	self.setUserOrder(order.UserAddress, orderBook, orderId, true)
 }
 
 func (self *TradingStateDB) GetOrder(orderBook common.Hash, orderId common.Hash) OrderItem {
@@ -263,6 +349,7 @@ func (self *TradingStateDB) SubAmountOrderItem(orderBook common.Hash, orderId co
 	stateOrderItem.setVolume(newAmount)
 	if newAmount.Sign() == 0 {
 		stateOrderList.removeOrderItem(self.db, orderId)
+		self.setUserOrder(stateOrderItem.data.UserAddress, orderBook, orderId, false)
 	} else {
 		stateOrderList.setOrderItem(orderId, common.BigToHash(newAmount))
 	}
@@ -288,6 +375,18 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 	if stateOrderItem == nil || stateOrderItem.empty() {
 		return fmt.Errorf("Order item empty  order book : %s , order id  : %s ", orderBook, orderIdHash.Hex())
 	}
+	if stateOrderItem.data.UserAddress != order.UserAddress {
+		return fmt.Errorf("Error Order User Address mismatch when cancel order book : %s , order id  : %s , got : %s , expect : %s ", orderBook, orderIdHash.Hex(), stateOrderItem.data.UserAddress.Hex(), order.UserAddress.Hex())
+	}
+	if stateOrderItem.data.Hash != order.Hash {
+		return fmt.Errorf("Invalid order hash :  got : %s , expect : %s ", order.Hash.Hex(), stateOrderItem.data.Hash.Hex())
+	}
+	if stateOrderItem.data.ExchangeAddress != order.ExchangeAddress {
+		return fmt.Errorf("Exchange Address mismatch when cancel. order book : %s , order id  : %s , got : %s , expect : %s ", orderBook, orderIdHash.Hex(), order.ExchangeAddress.Hex(), stateOrderItem.data.ExchangeAddress.Hex())
+	}
+	if stateOrderItem.data.IsTriggerOrder() {
+		return self.RemoveTriggerOrder(orderBook, orderIdHash)
+	}
 	priceHash := common.BigToHash(stateOrderItem.data.Price)
 	var stateOrderList *stateOrderList
 	switch stateOrderItem.data.Side {
@@ -302,15 +401,6 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 		return fmt.Errorf("Order list empty  order book : %s , order id  : %s , price  : %s ", orderBook, orderIdHash.Hex(), priceHash.Hex())
 	}
 
-	if stateOrderItem.data.UserAddress != order.UserAddress {
-		return fmt.Errorf("Error Order User Address mismatch when cancel order book : %s , order id  : %s , got : %s , expect : %s ", orderBook, orderIdHash.Hex(), stateOrderItem.data.UserAddress.Hex(), order.UserAddress.Hex())
-	}
-	if stateOrderItem.data.Hash != order.Hash {
-		return fmt.Errorf("Invalid order hash :  got : %s , expect : %s ", order.Hash.Hex(), stateOrderItem.data.Hash.Hex())
-	}
-	if stateOrderItem.data.ExchangeAddress != order.ExchangeAddress {
-		return fmt.Errorf("Exchange Address mismatch when cancel. order book : %s , order id  : %s , got : %s , expect : %s ", orderBook, orderIdHash.Hex(), order.ExchangeAddress.Hex(), stateOrderItem.data.ExchangeAddress.Hex())
-	}
 	self.journal = append(self.journal, cancelOrder{
 		orderBook: orderBook,
 		orderId:   orderIdHash,
@@ -320,6 +410,7 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 	stateOrderItem.setVolume(big.NewInt(0))
 	stateOrderList.subVolume(currentAmount)
 	stateOrderList.removeOrderItem(self.db, orderIdHash)
+	self.setUserOrder(stateOrderItem.data.UserAddress, orderBook, orderIdHash, false)
 	if stateOrderList.empty() {
 		switch stateOrderItem.data.Side {
 		case Ask:
@@ -332,6 +423,98 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 	return nil
 }
 
+// InsertTriggerOrder stores a stop or take profit order until the last price
+// of the order book crosses its trigger price.
+func (self *TradingStateDB) InsertTriggerOrder(orderBook common.Hash, orderId common.Hash, order OrderItem) {
+	stateExchange := self.getStateExchangeObject(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createExchangeObject(orderBook)
+	}
+	self.journal = append(self.journal, insertTriggerOrder{
+		orderBook: orderBook,
+		orderId:   orderId,
+		order:     order,
+	})
+	stateExchange.createStateOrderObject(self.db, orderId, order)
+	stateExchange.insertTriggerOrder(self.db, &order)
+	self.setUserOrder(order.UserAddress, orderBook, orderId, true)
+}
+
+// RemoveTriggerOrder removes a trigger order from the order book once it is
+// activated or cancelled.
+func (self *TradingStateDB) RemoveTriggerOrder(orderBook common.Hash, orderId common.Hash) error {
+	stateExchange := self.getStateExchangeObject(orderBook)
+	if stateExchange == nil {
+		return fmt.Errorf("Order book not found : %s ", orderBook.Hex())
+	}
+	stateOrderItem := stateExchange.getStateOrderObject(self.db, orderId)
+	if stateOrderItem == nil || stateOrderItem.empty() || !stateOrderItem.data.IsTriggerOrder() {
+		return fmt.Errorf("Trigger order not found  order book : %s , order id  : %s ", orderBook.Hex(), orderId.Hex())
+	}
+	self.journal = append(self.journal, removeTriggerOrder{
+		orderBook: orderBook,
+		orderId:   orderId,
+		order:     stateOrderItem.data,
+	})
+	stateExchange.removeTriggerOrder(self.db, &stateOrderItem.data)
+	stateOrderItem.setVolume(big.NewInt(0))
+	self.setUserOrder(stateOrderItem.data.UserAddress, orderBook, orderId, false)
+	return nil
+}
+
+// SetUserIndex switches the index of the open orders of every user on or off.
+func (self *TradingStateDB) SetUserIndex(enabled bool) {
+	self.userIndex = enabled
+}
+
+// setUserOrder adds an order to or removes it from the open orders of its
+// user if the user index is enabled.
+func (self *TradingStateDB) setUserOrder(user common.Address, orderBook common.Hash, orderId common.Hash, open bool) {
+	if !self.userIndex {
+		return
+	}
+	stateObject := self.GetOrNewStateExchangeObject(user.Hash())
+	if stateObject == nil {
+		return
+	}
+	prev := stateObject.hasUserOrder(self.db, orderBook, orderId)
+	if prev == open {
+		return
+	}
+	self.journal = append(self.journal, userOrderChange{
+		user:      user,
+		orderBook: orderBook,
+		orderId:   orderId,
+		prev:      prev,
+	})
+	stateObject.setUserOrder(self.db, orderBook, orderId, open)
+}
+
+// GetTriggeredOrder returns the first trigger order of the order book activated
+// by the last price, orders activated by a rising price come first. It returns
+// EmptyOrder if the last price doesn't activate any order.
+func (self *TradingStateDB) GetTriggeredOrder(orderBook common.Hash, lastPrice *big.Int) OrderItem {
+	stateExchange := self.getStateExchangeObject(orderBook)
+	if stateExchange == nil || lastPrice == nil || lastPrice.Sign() <= 0 {
+		return EmptyOrder
+	}
+	for _, direction := range []byte{triggerOnRise, triggerOnFall} {
+		orderId, ok := stateExchange.getFirstTriggerOrderId(self.db, direction)
+		if !ok {
+			continue
+		}
+		stateOrderItem := stateExchange.getStateOrderObject(self.db, orderId)
+		if stateOrderItem == nil || stateOrderItem.empty() {
+			log.Error("Trigger order not found", "orderbook", orderBook.Hex(), "orderId", orderId.Hex())
+			continue
+		}
+		if stateOrderItem.data.IsTriggered(lastPrice) {
+			return stateOrderItem.data
+		}
+	}
+	return EmptyOrder
+}
+
 func (self *TradingStateDB) GetVolume(orderBook common.Hash, price *big.Int, orderType string) *big.Int {
 	stateObject := self.GetOrNewStateExchangeObject(orderBook)
 	var volume *big.Int = nil
@@ -486,6 +669,7 @@ func (self *TradingStateDB) Copy() *TradingStateDB {
 		trie:                     self.db.CopyTrie(self.trie),
 		stateExhangeObjects:      make(map[common.Hash]*tradingExchanges, len(self.stateExhangeObjectsDirty)),
 		stateExhangeObjectsDirty: make(map[common.Hash]struct{}, len(self.stateExhangeObjectsDirty)),
+		userIndex:                self.userIndex,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateExhangeObjectsDirty {
@@ -543,6 +727,8 @@ func (s *TradingStateDB) Finalise() {
 			stateObject.updateBidsRoot(s.db)
 			stateObject.updateOrdersRoot(s.db)
 			stateObject.updateLiquidationPriceRoot(s.db)
+			stateObject.updateTriggerOrdersRoot(s.db)
+			stateObject.updateUserOrdersRoot(s.db)
 			// Update the object in the main orderId trie.
 			s.updateStateExchangeObject(stateObject)
 			//delete(s.stateExhangeObjectsDirty, addr)
@@ -578,6 +764,12 @@ func (s *TradingStateDB) Commit() (root common.Hash, err error) {
 			if err := stateObject.CommitLiquidationPriceTrie(s.db); err != nil {
 				return EmptyHash, err
 			}
+			if err := stateObject.CommitTriggerOrdersTrie(s.db); err != nil {
+				return EmptyHash, err
+			}
+			if err := stateObject.CommitUserOrdersTrie(s.db); err != nil {
+				return EmptyHash, err
+			}
 			// Update the object in the main orderId trie.
 			s.updateStateExchangeObject(stateObject)
 			delete(s.stateExhangeObjectsDirty, addr)
@@ -601,6 +793,12 @@ func (s *TradingStateDB) Commit() (root common.Hash, err error) {
 		if exchange.LiquidationPriceRoot != EmptyRoot {
 			s.db.TrieDB().Reference(exchange.LiquidationPriceRoot, parent)
 		}
+		if exchange.TriggerOrderRoot != EmptyRoot && exchange.TriggerOrderRoot != EmptyHash {
+			s.db.TrieDB().Reference(exchange.TriggerOrderRoot, parent)
+		}
+		if exchange.UserOrderRoot != EmptyRoot && exchange.UserOrderRoot != EmptyHash {
+			s.db.TrieDB().Reference(exchange.UserOrderRoot, parent)
+		}
 		return nil
 	})
 	log.Debug("Trading State Trie cache stats after commit", "root", root.Hex())
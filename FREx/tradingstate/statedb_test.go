@@ -22,6 +22,7 @@ import (
 	"github.com/FRECNET/common/math"
 	"github.com/FRECNET/core/rawdb"
 	"math/big"
+	"reflect"
 	"testing"
 )
 
@@ -300,3 +301,198 @@ func TestDumpState(t *testing.T) {
 	fmt.Println("bidTrie", bidTrie)
 	db.Close()
 }
+
+func TestTriggerOrders(t *testing.T) {
+	orderBook := common.StringToHash("BTC/FRE")
+	newTriggerOrder := func(id uint64, orderType string, side string, triggerPrice int64) OrderItem {
+		return OrderItem{
+			OrderID:      id,
+			Quantity:     big.NewInt(10),
+			Price:        big.NewInt(0),
+			Side:         side,
+			Type:         orderType,
+			TriggerPrice: big.NewInt(triggerPrice),
+			Hash:         common.BigToHash(new(big.Int).SetUint64(id)),
+			Signature:    &Signature{V: 1, R: common.HexToHash("111111"), S: common.HexToHash("222222222222")},
+		}
+	}
+	orders := []OrderItem{
+		newTriggerOrder(1, StopMarket, Bid, 100),
+		newTriggerOrder(2, StopMarket, Bid, 100),
+		newTriggerOrder(3, StopLimit, Ask, 80),
+		newTriggerOrder(4, TakeProfit, Ask, 120),
+		newTriggerOrder(5, StopMarket, Ask, 80),
+	}
+	db := rawdb.NewMemoryDatabase()
+	stateCache := NewDatabase(db)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for _, order := range orders {
+		statedb.InsertTriggerOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+	}
+	checkTriggered := func(lastPrice int64, want uint64) {
+		t.Helper()
+		order := statedb.GetTriggeredOrder(orderBook, big.NewInt(lastPrice))
+		if want == 0 {
+			if order != EmptyOrder {
+				t.Fatalf("last price %d: triggered order %d, want none", lastPrice, order.OrderID)
+			}
+			return
+		}
+		if order.OrderID != want {
+			t.Fatalf("last price %d: triggered order %d, want %d", lastPrice, order.OrderID, want)
+		}
+	}
+	checkTriggered(90, 0)
+	checkTriggered(100, 1)
+	checkTriggered(80, 3)
+
+	// Trigger orders survive a commit
+	root := statedb.IntermediateRoot()
+	if _, err := statedb.Commit(); err != nil {
+		t.Fatalf("Error when commit trading state: %v", err)
+	}
+	if err := stateCache.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("Error when commit into database: %v", err)
+	}
+	statedb, err := New(root, stateCache)
+	if err != nil {
+		t.Fatalf("Error when get trie in database: %s , err: %v", root.Hex(), err)
+	}
+	checkTriggered(130, 1)
+
+	// Reverting a removal restores the trigger order
+	snap := statedb.Snapshot()
+	if err := statedb.RemoveTriggerOrder(orderBook, common.BigToHash(big.NewInt(1))); err != nil {
+		t.Fatalf("Error when remove trigger order: %v", err)
+	}
+	checkTriggered(130, 2)
+	statedb.RevertToSnapshot(snap)
+	checkTriggered(130, 1)
+
+	// Cancelled and activated orders aren't triggered anymore
+	for _, id := range []int64{1, 2} {
+		if err := statedb.RemoveTriggerOrder(orderBook, common.BigToHash(big.NewInt(id))); err != nil {
+			t.Fatalf("Error when remove trigger order %d: %v", id, err)
+		}
+	}
+	checkTriggered(130, 4)
+	if err := statedb.CancelOrder(orderBook, &orders[2]); err != nil {
+		t.Fatalf("Error when cancel trigger order: %v", err)
+	}
+	if order := statedb.GetOrder(orderBook, common.BigToHash(big.NewInt(3))); order != EmptyOrder && order.Quantity.Sign() != 0 {
+		t.Fatalf("Cancelled trigger order still open: %v", order)
+	}
+	checkTriggered(10, 5)
+}
+
+func TestTriggerOrderRootOmitted(t *testing.T) {
+	orderBook := common.StringToHash("BTC/FRE")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), OrderItem{OrderID: 1, Quantity: big.NewInt(1), Price: big.NewInt(1), Side: Ask})
+	statedb.GetTriggeredOrder(orderBook, big.NewInt(1))
+	statedb.Finalise()
+	if root := statedb.getStateExchangeObject(orderBook).data.TriggerOrderRoot; root != EmptyHash {
+		t.Fatalf("Trigger order root set without trigger orders: %s", root.Hex())
+	}
+}
+
+func TestUserOrders(t *testing.T) {
+	user := common.HexToAddress("0x0000000000000000000000000000000000000099")
+	bookA, bookB := common.StringToHash("BTC/FRE"), common.StringToHash("ETH/FRE")
+	newOrder := func(id uint64, side string) OrderItem {
+		return OrderItem{OrderID: id, UserAddress: user, Quantity: big.NewInt(10), Price: big.NewInt(5), Side: side, Signature: &Signature{V: 1, R: common.HexToHash("111111"), S: common.HexToHash("222222222222")}}
+	}
+	orderId := func(id int64) common.Hash {
+		return common.BigToHash(big.NewInt(id))
+	}
+	db := rawdb.NewMemoryDatabase()
+	stateCache := NewDatabase(db)
+	statedb, _ := New(common.Hash{}, stateCache)
+	checkOrders := func(orderBook common.Hash, want map[common.Hash][]uint64) {
+		t.Helper()
+		orders, err := statedb.GetUserOrders(user, orderBook)
+		if err != nil {
+			t.Fatalf("Error when get user orders: %v", err)
+		}
+		have := map[common.Hash][]uint64{}
+		for book, items := range orders {
+			for _, order := range items {
+				have[book] = append(have[book], order.OrderID)
+			}
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("User orders mismatch: have %v, want %v", have, want)
+		}
+	}
+
+	// Orders aren't indexed before the index is enabled
+	statedb.InsertOrderItem(bookA, orderId(1), newOrder(1, Ask))
+	checkOrders(common.Hash{}, map[common.Hash][]uint64{})
+
+	statedb.SetUserIndex(true)
+	statedb.InsertOrderItem(bookA, orderId(2), newOrder(2, Ask))
+	statedb.InsertOrderItem(bookA, orderId(3), newOrder(3, Bid))
+	statedb.InsertOrderItem(bookB, orderId(4), newOrder(4, Bid))
+	checkOrders(bookA, map[common.Hash][]uint64{bookA: {2, 3}})
+	checkOrders(common.Hash{}, map[common.Hash][]uint64{bookA: {2, 3}, bookB: {4}})
+
+	// The index survives a commit
+	root := statedb.IntermediateRoot()
+	if _, err := statedb.Commit(); err != nil {
+		t.Fatalf("Error when commit trading state: %v", err)
+	}
+	if err := stateCache.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("Error when commit into database: %v", err)
+	}
+	statedb, err := New(root, stateCache)
+	if err != nil {
+		t.Fatalf("Error when get trie in database: %s , err: %v", root.Hex(), err)
+	}
+	statedb.SetUserIndex(true)
+	checkOrders(bookB, map[common.Hash][]uint64{bookB: {4}})
+
+	// Filled and cancelled orders leave the index, reverting restores them
+	snap := statedb.Snapshot()
+	if err := statedb.SubAmountOrderItem(bookA, orderId(2), big.NewInt(5), big.NewInt(10), Ask); err != nil {
+		t.Fatalf("Error when fill order: %v", err)
+	}
+	order := newOrder(4, Bid)
+	if err := statedb.CancelOrder(bookB, &order); err != nil {
+		t.Fatalf("Error when cancel order: %v", err)
+	}
+	checkOrders(common.Hash{}, map[common.Hash][]uint64{bookA: {3}})
+	statedb.RevertToSnapshot(snap)
+	checkOrders(common.Hash{}, map[common.Hash][]uint64{bookA: {2, 3}, bookB: {4}})
+}
+
+func TestTradedVolume(t *testing.T) {
+	user := common.HexToAddress("0x0000000000000000000000000000000000000099")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	checkVolume := func(epoch uint64, want int64) {
+		t.Helper()
+		if volume := statedb.GetTradedVolume(user, epoch); volume.Cmp(big.NewInt(want)) != 0 {
+			t.Fatalf("Traded volume mismatch at epoch %d: have %v, want %d", epoch, volume, want)
+		}
+	}
+	statedb.AddTradedVolume(user, 5, big.NewInt(10))
+	statedb.AddTradedVolume(user, 5, big.NewInt(5))
+	// the volume of the current epoch doesn't count
+	checkVolume(5, 0)
+	checkVolume(6, 15)
+
+	snap := statedb.Snapshot()
+	statedb.AddTradedVolume(user, 7, big.NewInt(7))
+	checkVolume(7, 15)
+	checkVolume(8, 22)
+	checkVolume(9, 22)
+	// only the last common.RelayerFeeVolumeEpochs epochs count
+	checkVolume(10, 7)
+	statedb.RevertToSnapshot(snap)
+	checkVolume(8, 15)
+
+	statedb.AddTradedVolume(user, 100, big.NewInt(3))
+	checkVolume(101, 3)
+	if volumes := statedb.getStateExchangeObject(user.Hash()).data.EpochVolumes; len(volumes) > common.RelayerFeeVolumeEpochs+1 {
+		t.Fatalf("Too many traded volumes kept: %d", len(volumes))
+	}
+}
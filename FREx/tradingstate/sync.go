@@ -0,0 +1,48 @@
+package tradingstate
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/rlp"
+	"github.com/FRECNET/trie"
+)
+
+// NewStateSync creates a new trading state trie download scheduler. The
+// exchange objects of the trie are followed into their ask, bid, order,
+// liquidation price, trigger order and user order tries, the order lists of
+// which are followed into the tries of their orders and lending books.
+func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+	addSubTrie := func(root common.Hash, depth int, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyRoot && root != EmptyHash {
+			syncer.AddSubTrie(root, depth, parent, callback)
+		}
+	}
+	// orderLists returns the callback of a trie of order lists, which adds the
+	// trie of each list, synced with the given callback.
+	orderLists := func(depth int, callback trie.LeafCallback) trie.LeafCallback {
+		return func(leaf []byte, parent common.Hash) error {
+			var list orderList
+			if err := rlp.DecodeBytes(leaf, &list); err != nil {
+				return err
+			}
+			addSubTrie(list.Root, depth, parent, callback)
+			return nil
+		}
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		var exchange tradingExchangeObject
+		if err := rlp.DecodeBytes(leaf, &exchange); err != nil {
+			return err
+		}
+		addSubTrie(exchange.AskRoot, 64, parent, orderLists(128, nil))
+		addSubTrie(exchange.BidRoot, 64, parent, orderLists(128, nil))
+		addSubTrie(exchange.OrderRoot, 64, parent, nil)
+		addSubTrie(exchange.LiquidationPriceRoot, 64, parent, orderLists(128, orderLists(192, nil)))
+		addSubTrie(exchange.TriggerOrderRoot, 64, parent, nil)
+		addSubTrie(exchange.UserOrderRoot, 64, parent, nil)
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
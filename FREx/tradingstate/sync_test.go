@@ -0,0 +1,95 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/ethdb/memorydb"
+	"github.com/FRECNET/trie"
+)
+
+// Tests that a trading state is fully reconstructed by the state sync, the
+// order books along with their order lists, liquidation prices, trigger orders
+// and the user order index.
+func TestTradingStateSync(t *testing.T) {
+	var (
+		orderBook = common.StringToHash("BTC/FRE")
+		user      = common.HexToAddress("0x0000000000000000000000000000000000000099")
+		orderId   = func(id uint64) common.Hash { return common.BigToHash(new(big.Int).SetUint64(id)) }
+		signature = &Signature{V: 1, R: common.HexToHash("111111"), S: common.HexToHash("222222222222")}
+	)
+	srcCache := NewDatabase(rawdb.NewMemoryDatabase())
+	srcState, _ := New(common.Hash{}, srcCache)
+	srcState.SetUserIndex(true)
+	for i := uint64(1); i <= 20; i++ {
+		side := Ask
+		if i%2 == 0 {
+			side = Bid
+		}
+		srcState.InsertOrderItem(orderBook, orderId(i), OrderItem{OrderID: i, UserAddress: user, Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(100 + i)), Side: side, Signature: signature})
+	}
+	srcState.InsertTriggerOrder(orderBook, orderId(21), OrderItem{OrderID: 21, Quantity: big.NewInt(1), Price: big.NewInt(0), Side: Bid, Type: StopMarket, TriggerPrice: big.NewInt(150), Signature: signature})
+	srcState.InsertLiquidationPrice(orderBook, big.NewInt(90), orderBook, 1)
+	srcState.InsertLiquidationPrice(orderBook, big.NewInt(90), orderBook, 2)
+	srcState.SetLastPrice(orderBook, big.NewInt(110))
+	srcRoot := srcState.IntermediateRoot()
+	if _, err := srcState.Commit(); err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+
+	// Sync the state into an empty database
+	dstDb := rawdb.NewMemoryDatabase()
+	sched := NewStateSync(srcRoot, dstDb, trie.NewSyncBloom(1, memorydb.New()))
+	queue := append([]common.Hash{}, sched.Missing(100)...)
+	for len(queue) > 0 {
+		results := make([]trie.SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcCache.TrieDB().Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x", hash)
+			}
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		batch := dstDb.NewBatch()
+		if err := sched.Commit(batch); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		batch.Write()
+		queue = append(queue[:0], sched.Missing(100)...)
+	}
+
+	// Cross check the synced state
+	dstState, err := New(srcRoot, NewDatabase(dstDb))
+	if err != nil {
+		t.Fatalf("failed to open synced trading state: %v", err)
+	}
+	dstState.SetUserIndex(true)
+	if price := dstState.GetLastPrice(orderBook); price.Cmp(big.NewInt(110)) != 0 {
+		t.Errorf("last price mismatch: have %v, want 110", price)
+	}
+	for i := uint64(1); i <= 20; i++ {
+		if order := dstState.GetOrder(orderBook, orderId(i)); order.Quantity == nil || order.Quantity.Uint64() != i {
+			t.Errorf("order %d mismatch: have %v", i, order.Quantity)
+		}
+	}
+	if price, volume := dstState.GetBestAskPrice(orderBook); price.Cmp(big.NewInt(101)) != 0 || volume.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("best ask mismatch: have %v (volume %v), want 101 (volume 1)", price, volume)
+	}
+	if id, _, err := dstState.GetBestOrderIdAndAmount(orderBook, big.NewInt(120), Bid); err != nil || id != orderId(20) {
+		t.Errorf("best bid order mismatch: have %x, want %x, err %v", id, orderId(20), err)
+	}
+	if order := dstState.GetTriggeredOrder(orderBook, big.NewInt(150)); order.OrderID != 21 {
+		t.Errorf("triggered order mismatch: have %d, want 21", order.OrderID)
+	}
+	if _, data := dstState.GetHighestLiquidationPriceData(orderBook, big.NewInt(80)); len(data[orderBook]) != 2 {
+		t.Errorf("liquidation data mismatch: have %v, want 2 trades", data)
+	}
+	if orders, err := dstState.GetUserOrders(user, orderBook); err != nil || len(orders[orderBook]) != 20 {
+		t.Errorf("user orders mismatch: have %d orders, want 20, err %v", len(orders[orderBook]), err)
+	}
+}
@@ -24,6 +24,12 @@ const (
 	MakerOrderType      = "makerOrderType"
 	MakerFee            = "makerFee"
 	TakerFee            = "takerFee"
+	TradeTaker          = "takerAddr"
+	TradeTakerExchange  = "takerExAddr"
+	TradeTakerSide      = "takerSide"
+	TakerOrderType      = "takerOrderType"
+	TradeMakerRemaining = "makerRemaining" // quantity of the maker order left in the order book
+	TradeTakerRemaining = "takerRemaining" // quantity of the taker order still to trade
 )
 
 type Trade struct {
@@ -0,0 +1,374 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package daotest provides the conformance tests every FREXDAO backend used by
+// SDK nodes must pass.
+package daotest
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExDAO"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+)
+
+var (
+	baseToken  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	quoteToken = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	otherToken = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	user       = common.HexToAddress("0x4000000000000000000000000000000000000004")
+	txHash1    = common.HexToHash("0xa1")
+	txHash2    = common.HexToHash("0xa2")
+	matchTime  = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// TestObjectStoreSuite runs a suite of tests against the object methods of a
+// FREXDAO implementation. New must return an empty database.
+func TestObjectStoreSuite(t *testing.T, New func() FRExDAO.FREXDAO) {
+	t.Run("Orders", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		open := newOrder(common.HexToHash("0x01"), txHash1, tradingstate.OrderStatusOpen)
+		db.InitBulk()
+		if err := db.PutObject(open.Hash, open); err != nil {
+			t.Fatalf("failed to put order: %v", err)
+		}
+		if err := db.CommitBulk(); err != nil {
+			t.Fatalf("failed to commit orders: %v", err)
+		}
+		if found, err := db.HasObject(open.Hash, &tradingstate.OrderItem{}); err != nil || !found {
+			t.Fatalf("order not found: %v", err)
+		}
+		// a filled order replaces the open one
+		filled := newOrder(open.Hash, txHash2, tradingstate.OrderStatusFilled)
+		filled.FilledAmount = big.NewInt(10)
+		db.InitBulk()
+		if err := db.PutObject(filled.Hash, filled); err != nil {
+			t.Fatalf("failed to put order: %v", err)
+		}
+		if err := db.CommitBulk(); err != nil {
+			t.Fatalf("failed to commit orders: %v", err)
+		}
+		orders := db.GetListItemByHashes([]string{open.Hash.Hex()}, &tradingstate.OrderItem{}).([]*tradingstate.OrderItem)
+		if len(orders) != 1 {
+			t.Fatalf("orders mismatch: have %d, want 1", len(orders))
+		}
+		if orders[0].Status != tradingstate.OrderStatusFilled || orders[0].FilledAmount.Cmp(big.NewInt(10)) != 0 || orders[0].TxHash != txHash2 {
+			t.Fatalf("order not updated: status %s, filled %v, txHash %x", orders[0].Status, orders[0].FilledAmount, orders[0].TxHash)
+		}
+		if orders[0].Quantity.Cmp(open.Quantity) != 0 || orders[0].UserAddress != user || !orders[0].CreatedAt.Equal(matchTime) {
+			t.Fatalf("order fields mismatch: %+v", orders[0])
+		}
+		if orders[0].Signature == nil || *orders[0].Signature != *open.Signature || orders[0].TriggerPrice != nil {
+			t.Fatalf("order signature or trigger price mismatch: %+v", orders[0])
+		}
+		if orders := db.GetListItemByTxHash(txHash1, &tradingstate.OrderItem{}).([]*tradingstate.OrderItem); len(orders) != 0 {
+			t.Fatalf("order still listed at its previous transaction")
+		}
+		if orders := db.GetListItemByTxHash(txHash2, &tradingstate.OrderItem{}).([]*tradingstate.OrderItem); len(orders) != 1 {
+			t.Fatalf("order not listed at its transaction")
+		}
+		if err := db.DeleteObject(open.Hash, &tradingstate.OrderItem{}); err != nil {
+			t.Fatalf("failed to delete order: %v", err)
+		}
+		if found, _ := db.HasObject(open.Hash, &tradingstate.OrderItem{}); found {
+			t.Fatalf("deleted order found")
+		}
+		// deleting a missing object is not an error
+		if err := db.DeleteObject(open.Hash, &tradingstate.OrderItem{}); err != nil {
+			t.Fatalf("failed to delete missing order: %v", err)
+		}
+	})
+
+	t.Run("TradesRollback", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		trades := []*tradingstate.Trade{
+			newTrade(common.HexToHash("0x11"), txHash1, baseToken, 0),
+			newTrade(common.HexToHash("0x12"), txHash1, baseToken, time.Minute),
+			newTrade(common.HexToHash("0x13"), txHash2, baseToken, 2*time.Minute),
+			newTrade(common.HexToHash("0x14"), txHash2, otherToken, 2*time.Minute),
+		}
+		db.InitBulk()
+		for _, trade := range trades {
+			if err := db.PutObject(trade.Hash, trade); err != nil {
+				t.Fatalf("failed to put trade: %v", err)
+			}
+		}
+		if err := db.CommitBulk(); err != nil {
+			t.Fatalf("failed to commit trades: %v", err)
+		}
+		if have := tradeHashes(db.GetListItemByTxHash(txHash1, &tradingstate.Trade{})); !equalHashes(have, trades[0].Hash, trades[1].Hash) {
+			t.Fatalf("trades of tx mismatch: have %x", have)
+		}
+		pair := db.GetListItemByPair(baseToken, quoteToken, matchTime, matchTime.Add(time.Hour), &tradingstate.Trade{}).([]*tradingstate.Trade)
+		if len(pair) != 3 || pair[0].Hash != trades[0].Hash || pair[2].Hash != trades[2].Hash {
+			t.Fatalf("trades of pair mismatch: have %d", len(pair))
+		}
+		if pair[1].Amount.Cmp(trades[1].Amount) != 0 || pair[1].PricePoint.Cmp(trades[1].PricePoint) != 0 {
+			t.Fatalf("trade fields mismatch: %+v", pair[1])
+		}
+		if pair := db.GetListItemByPair(baseToken, quoteToken, matchTime.Add(time.Minute), matchTime.Add(2*time.Minute), &tradingstate.Trade{}).([]*tradingstate.Trade); len(pair) != 1 || pair[0].Hash != trades[1].Hash {
+			t.Fatalf("trades of range mismatch: have %d", len(pair))
+		}
+		// reorg rollback drops the trades of the transaction only
+		db.DeleteItemByTxHash(txHash1, &tradingstate.Trade{})
+		if have := tradeHashes(db.GetListItemByTxHash(txHash1, &tradingstate.Trade{})); len(have) != 0 {
+			t.Fatalf("trades of rolled back tx still listed: %x", have)
+		}
+		if have := tradeHashes(db.GetListItemByTxHash(txHash2, &tradingstate.Trade{})); !equalHashes(have, trades[2].Hash, trades[3].Hash) {
+			t.Fatalf("trades of other tx mismatch: have %x", have)
+		}
+	})
+
+	t.Run("Candles", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		db.InitBulk()
+		for i := 0; i < 3; i++ {
+			candle := tradingstate.NewCandle(baseToken, quoteToken, "1m", matchTime.Add(time.Duration(i)*time.Minute))
+			candle.AddTrade(big.NewInt(int64(100+i)), big.NewInt(1), big.NewInt(100))
+			if err := db.PutObject(candle.Hash, candle); err != nil {
+				t.Fatalf("failed to put candle: %v", err)
+			}
+		}
+		hourly := tradingstate.NewCandle(baseToken, quoteToken, "1h", matchTime)
+		hourly.AddTrade(big.NewInt(100), big.NewInt(3), big.NewInt(300))
+		if err := db.PutObject(hourly.Hash, hourly); err != nil {
+			t.Fatalf("failed to put candle: %v", err)
+		}
+		if err := db.CommitBulk(); err != nil {
+			t.Fatalf("failed to commit candles: %v", err)
+		}
+		// a candle updated by a later trade is replaced
+		update := tradingstate.NewCandle(baseToken, quoteToken, "1m", matchTime)
+		update.AddTrade(big.NewInt(100), big.NewInt(1), big.NewInt(100))
+		update.AddTrade(big.NewInt(90), big.NewInt(1), big.NewInt(90))
+		db.InitBulk()
+		if err := db.PutObject(update.Hash, update); err != nil {
+			t.Fatalf("failed to put candle: %v", err)
+		}
+		if err := db.CommitBulk(); err != nil {
+			t.Fatalf("failed to commit candles: %v", err)
+		}
+		candles := db.GetListItemByPair(baseToken, quoteToken, matchTime, matchTime.Add(time.Hour), &tradingstate.Candle{Interval: "1m"}).([]*tradingstate.Candle)
+		if len(candles) != 3 {
+			t.Fatalf("candles mismatch: have %d, want 3", len(candles))
+		}
+		for i, candle := range candles {
+			if want := matchTime.Add(time.Duration(i) * time.Minute); !candle.OpenTime.Equal(want) {
+				t.Errorf("candle %d: open time %v, want %v", i, candle.OpenTime, want)
+			}
+		}
+		if candles[0].Count != 2 || candles[0].Low.Cmp(big.NewInt(90)) != 0 || candles[0].Close.Cmp(big.NewInt(90)) != 0 {
+			t.Fatalf("candle not updated: %+v", candles[0])
+		}
+		if err := db.DeleteObject(update.Hash, &tradingstate.Candle{}); err != nil {
+			t.Fatalf("failed to delete candle: %v", err)
+		}
+		if candles := db.GetListItemByPair(baseToken, quoteToken, matchTime, matchTime.Add(time.Hour), &tradingstate.Candle{Interval: "1h"}).([]*tradingstate.Candle); len(candles) != 1 {
+			t.Fatalf("hourly candles mismatch: have %d, want 1", len(candles))
+		}
+	})
+
+	t.Run("LendingItems", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		item := newLendingItem(common.HexToHash("0x21"), txHash1, lendingstate.Limit)
+		repay := newLendingItem(common.HexToHash("0x22"), txHash1, lendingstate.Repay)
+		db.InitLendingBulk()
+		for _, li := range []*lendingstate.LendingItem{item, repay} {
+			if err := db.PutObject(li.Hash, li); err != nil {
+				t.Fatalf("failed to put lending item: %v", err)
+			}
+		}
+		if err := db.CommitLendingBulk(); err != nil {
+			t.Fatalf("failed to commit lending items: %v", err)
+		}
+		items := db.GetListItemByTxHash(txHash1, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem)
+		if len(items) != 1 || items[0].Hash != item.Hash {
+			t.Fatalf("lending items mismatch: have %d, want 1", len(items))
+		}
+		repays := db.GetListItemByTxHash(txHash1, &lendingstate.LendingItem{Type: lendingstate.Repay}).([]*lendingstate.LendingItem)
+		if len(repays) != 1 || repays[0].Hash != repay.Hash || repays[0].Status != lendingstate.Repay {
+			t.Fatalf("repay items mismatch: %+v", repays)
+		}
+		if repays[0].Quantity.Cmp(repay.Quantity) != 0 || repays[0].Term != repay.Term || repays[0].LendingToken != repay.LendingToken {
+			t.Fatalf("repay fields mismatch: %+v", repays[0])
+		}
+		db.DeleteItemByTxHash(txHash1, &lendingstate.LendingItem{})
+		if items := db.GetListItemByTxHash(txHash1, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 0 {
+			t.Fatalf("rolled back lending items still listed")
+		}
+		if repays := db.GetListItemByTxHash(txHash1, &lendingstate.LendingItem{Type: lendingstate.Repay}).([]*lendingstate.LendingItem); len(repays) != 1 {
+			t.Fatalf("repay items deleted along with lending items")
+		}
+	})
+
+	t.Run("LendingTrades", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		trade := newLendingTrade(common.HexToHash("0x31"), txHash1)
+		db.InitLendingBulk()
+		if err := db.PutObject(trade.Hash, trade); err != nil {
+			t.Fatalf("failed to put lending trade: %v", err)
+		}
+		if err := db.CommitLendingBulk(); err != nil {
+			t.Fatalf("failed to commit lending trades: %v", err)
+		}
+		// top ups and liquidations update the stored trade
+		updated := newLendingTrade(trade.Hash, txHash2)
+		updated.Status = lendingstate.TradeStatusLiquidated
+		updated.CollateralLockedAmount = big.NewInt(0)
+		db.InitLendingBulk()
+		if err := db.PutObject(updated.Hash, updated); err != nil {
+			t.Fatalf("failed to put lending trade: %v", err)
+		}
+		if err := db.CommitLendingBulk(); err != nil {
+			t.Fatalf("failed to commit lending trades: %v", err)
+		}
+		trades := db.GetListItemByHashes([]string{trade.Hash.Hex()}, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade)
+		if len(trades) != 1 {
+			t.Fatalf("lending trades mismatch: have %d, want 1", len(trades))
+		}
+		if trades[0].Status != lendingstate.TradeStatusLiquidated || trades[0].CollateralLockedAmount.Sign() != 0 || trades[0].TxHash != txHash2 {
+			t.Fatalf("lending trade not updated: %+v", trades[0])
+		}
+		if trades[0].Amount.Cmp(trade.Amount) != 0 || trades[0].Term != trade.Term || trades[0].Borrower != user {
+			t.Fatalf("lending trade fields mismatch: %+v", trades[0])
+		}
+		db.DeleteItemByTxHash(txHash2, &lendingstate.LendingTrade{})
+		if trades := db.GetListItemByHashes([]string{trade.Hash.Hex()}, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade); len(trades) != 0 {
+			t.Fatalf("rolled back lending trade still listed")
+		}
+	})
+}
+
+func newOrder(hash common.Hash, txHash common.Hash, status string) *tradingstate.OrderItem {
+	return &tradingstate.OrderItem{
+		Hash:            hash,
+		TxHash:          txHash,
+		Quantity:        big.NewInt(10),
+		Price:           big.NewInt(100),
+		FilledAmount:    big.NewInt(0),
+		Nonce:           big.NewInt(1),
+		ExchangeAddress: user,
+		UserAddress:     user,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+		Status:          status,
+		Side:            tradingstate.Bid,
+		Type:            tradingstate.Limit,
+		Signature:       &tradingstate.Signature{V: 27, R: common.HexToHash("0x1"), S: common.HexToHash("0x2")},
+		CreatedAt:       matchTime,
+		UpdatedAt:       matchTime,
+	}
+}
+
+func newTrade(hash common.Hash, txHash common.Hash, base common.Address, offset time.Duration) *tradingstate.Trade {
+	return &tradingstate.Trade{
+		Hash:       hash,
+		TxHash:     txHash,
+		Taker:      user,
+		Maker:      user,
+		BaseToken:  base,
+		QuoteToken: quoteToken,
+		PricePoint: big.NewInt(100 + int64(offset/time.Minute)),
+		Amount:     big.NewInt(5),
+		MakeFee:    big.NewInt(1),
+		TakeFee:    big.NewInt(1),
+		Status:     tradingstate.TradeStatusSuccess,
+		CreatedAt:  matchTime.Add(offset),
+		UpdatedAt:  matchTime.Add(offset),
+	}
+}
+
+func newLendingItem(hash common.Hash, txHash common.Hash, itemType string) *lendingstate.LendingItem {
+	return &lendingstate.LendingItem{
+		Hash:            hash,
+		TxHash:          txHash,
+		Quantity:        big.NewInt(10),
+		Interest:        big.NewInt(5),
+		FilledAmount:    big.NewInt(0),
+		Nonce:           big.NewInt(1),
+		Side:            lendingstate.Investing,
+		Type:            itemType,
+		Status:          lendingstate.LendingStatusOpen,
+		LendingToken:    baseToken,
+		CollateralToken: quoteToken,
+		Relayer:         user,
+		UserAddress:     user,
+		Term:            86400,
+		Signature:       &lendingstate.Signature{V: 27, R: common.HexToHash("0x1"), S: common.HexToHash("0x2")},
+		CreatedAt:       matchTime,
+		UpdatedAt:       matchTime,
+	}
+}
+
+func newLendingTrade(hash common.Hash, txHash common.Hash) *lendingstate.LendingTrade {
+	return &lendingstate.LendingTrade{
+		Hash:                   hash,
+		TxHash:                 txHash,
+		Borrower:               user,
+		Investor:               user,
+		LendingToken:           baseToken,
+		CollateralToken:        quoteToken,
+		Term:                   86400,
+		Interest:               5,
+		CollateralPrice:        big.NewInt(100),
+		LiquidationPrice:       big.NewInt(80),
+		CollateralLockedAmount: big.NewInt(20),
+		DepositRate:            big.NewInt(150),
+		LiquidationRate:        big.NewInt(110),
+		RecallRate:             big.NewInt(200),
+		Amount:                 big.NewInt(10),
+		BorrowingFee:           big.NewInt(1),
+		InvestingFee:           big.NewInt(1),
+		Status:                 lendingstate.TradeStatusOpen,
+		CreatedAt:              matchTime,
+		UpdatedAt:              matchTime,
+	}
+}
+
+func tradeHashes(items interface{}) []common.Hash {
+	var hashes []common.Hash
+	for _, trade := range items.([]*tradingstate.Trade) {
+		hashes = append(hashes, trade.Hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Big().Cmp(hashes[j].Big()) < 0 })
+	return hashes
+}
+
+func equalHashes(have []common.Hash, want ...common.Hash) bool {
+	if len(have) != len(want) {
+		return false
+	}
+	for i := range have {
+		if have[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -5,6 +5,8 @@
 package FRExDAO
 
 import (
+	"time"
+
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/ethdb"
 )
@@ -24,6 +26,7 @@ type FREXDAO interface {
 	GetListItemByTxHash(txhash common.Hash, val interface{}) interface{}
 	GetListItemByHashes(hashes []string, val interface{}) interface{}
 	DeleteItemByTxHash(txhash common.Hash, val interface{})
+	GetListItemByPair(baseToken, quoteToken common.Address, from, to time.Time, val interface{}) interface{} // items of a pair in [from, to), in matching order
 
 	// basic FREx
 	InitBulk()
@@ -7,6 +7,7 @@ import (
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/core/rawdb"
 	"sync"
+	"time"
 
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/log"
@@ -119,6 +120,10 @@ func (db *BatchDatabase) GetListItemByHashes(hashes []string, val interface{}) i
 	return []interface{}{}
 }
 
+func (db *BatchDatabase) GetListItemByPair(baseToken, quoteToken common.Address, from, to time.Time, val interface{}) interface{} {
+	return []interface{}{}
+}
+
 func (db *BatchDatabase) InitBulk() {
 }
 
@@ -171,13 +176,13 @@ func (db *BatchDatabase) Sync() error {
 }
 
 func (db *BatchDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
-	return db.NewIterator(prefix, start)
+	return db.db.NewIterator(prefix, start)
 }
 
 func (db *BatchDatabase) Stat(property string) (string, error) {
-	return db.Stat(property)
+	return db.db.Stat(property)
 }
 
 func (db *BatchDatabase) Compact(start []byte, limit []byte) error {
-	return db.Compact(start, limit)
+	return db.db.Compact(start, limit)
 }
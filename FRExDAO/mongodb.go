@@ -25,6 +25,7 @@ const (
 	lendingRepayCollection  = "lending_repays"
 	lendingRecallCollection = "lending_recalls"
 	epochPriceCollection    = "epoch_prices"
+	candlesCollection       = "candles"
 )
 
 type MongoDatabase struct {
@@ -35,6 +36,7 @@ type MongoDatabase struct {
 	orderBulk        *mgo.Bulk
 	tradeBulk        *mgo.Bulk
 	epochPriceBulk   *mgo.Bulk
+	candleBulk       *mgo.Bulk
 	lendingItemBulk  *mgo.Bulk
 	topUpBulk        *mgo.Bulk
 	recallBulk       *mgo.Bulk
@@ -152,6 +154,16 @@ func (db *MongoDatabase) HasObject(hash common.Hash, val interface{}) (bool, err
 			return false, err
 		}
 
+		if count == 1 {
+			return true, nil
+		}
+	case *tradingstate.Candle:
+		count, err = sc.DB(db.dbName).C(candlesCollection).Find(query).Limit(1).Count()
+
+		if err != nil {
+			return false, err
+		}
+
 		if count == 1 {
 			return true, nil
 		}
@@ -219,6 +231,14 @@ func (db *MongoDatabase) GetObject(hash common.Hash, val interface{}) (interface
 			}
 			db.cacheItems.Add(cacheKey, t)
 			return t, nil
+		case *tradingstate.Candle:
+			var c *tradingstate.Candle
+			err := sc.DB(db.dbName).C(candlesCollection).Find(query).One(&c)
+			if err != nil {
+				return nil, err
+			}
+			db.cacheItems.Add(cacheKey, c)
+			return c, nil
 		default:
 			return nil, nil
 		}
@@ -248,6 +268,11 @@ func (db *MongoDatabase) PutObject(hash common.Hash, val interface{}) error {
 		query := bson.M{"hash": item.Hash.Hex()}
 		db.epochPriceBulk.Upsert(query, item)
 		return nil
+	case *tradingstate.Candle:
+		c := val.(*tradingstate.Candle)
+		query := bson.M{"hash": c.Hash.Hex()}
+		db.candleBulk.Upsert(query, c)
+		return nil
 	case *lendingstate.LendingTrade:
 		lt := val.(*lendingstate.LendingTrade)
 		// PutObject LendingTrade into tradesCollection collection
@@ -343,6 +368,11 @@ func (db *MongoDatabase) DeleteObject(hash common.Hash, val interface{}) error {
 			if err != nil && err != mgo.ErrNotFound {
 				return fmt.Errorf("failed to delete lendingTrade. Err: %v", err)
 			}
+		case *tradingstate.Candle:
+			err = sc.DB(db.dbName).C(candlesCollection).Remove(query)
+			if err != nil && err != mgo.ErrNotFound {
+				return fmt.Errorf("failed to delete candle. Err: %v", err)
+			}
 
 		}
 	}
@@ -355,6 +385,7 @@ func (db *MongoDatabase) InitBulk() {
 	db.orderBulk = sc.DB(db.dbName).C(ordersCollection).Bulk()
 	db.tradeBulk = sc.DB(db.dbName).C(tradesCollection).Bulk()
 	db.epochPriceBulk = sc.DB(db.dbName).C(epochPriceCollection).Bulk()
+	db.candleBulk = sc.DB(db.dbName).C(candlesCollection).Bulk()
 }
 
 func (db *MongoDatabase) InitLendingBulk() {
@@ -376,6 +407,9 @@ func (db *MongoDatabase) CommitBulk() error {
 	if _, err := db.epochPriceBulk.Run(); err != nil && !mgo.IsDup(err) {
 		return err
 	}
+	if _, err := db.candleBulk.Run(); err != nil && !mgo.IsDup(err) {
+		return err
+	}
 	return nil
 }
 
@@ -578,6 +612,34 @@ func (db *MongoDatabase) GetListItemByHashes(hashes []string, val interface{}) i
 	return nil
 }
 
+// GetListItemByPair returns the trades of a pair created in [from, to) or the
+// candles of a pair opened in [from, to), both in matching order.
+func (db *MongoDatabase) GetListItemByPair(baseToken, quoteToken common.Address, from, to time.Time, val interface{}) interface{} {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	switch val.(type) {
+	case *tradingstate.Trade:
+		query := bson.M{"baseToken": baseToken.Hex(), "quoteToken": quoteToken.Hex(), "createdAt": bson.M{"$gte": from, "$lt": to}}
+		result := []*tradingstate.Trade{}
+		if err := sc.DB(db.dbName).C(tradesCollection).Find(query).Sort("createdAt", "_id").All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetListItemByPair (trades)", "err", err, "baseToken", baseToken, "quoteToken", quoteToken)
+		}
+		return result
+	case *tradingstate.Candle:
+		interval := val.(*tradingstate.Candle).Interval
+		query := bson.M{"baseToken": baseToken.Hex(), "quoteToken": quoteToken.Hex(), "interval": interval, "openTime": bson.M{"$gte": from, "$lt": to}}
+		result := []*tradingstate.Candle{}
+		if err := sc.DB(db.dbName).C(candlesCollection).Find(query).Sort("openTime").All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetListItemByPair (candles)", "err", err, "baseToken", baseToken, "quoteToken", quoteToken, "interval", interval)
+		}
+		return result
+	default:
+		log.Error("GetListItemByPair: Unknown object type", "baseToken", baseToken, "quoteToken", quoteToken, "object", val)
+	}
+	return nil
+}
+
 func (db *MongoDatabase) EnsureIndexes() error {
 	orderHashIndex := mgo.Index{
 		Key:        []string{"hash"},
@@ -719,6 +781,24 @@ func (db *MongoDatabase) EnsureIndexes() error {
 		Sparse:     true,
 		Name:       "index_epoch_price",
 	}
+	tradePairIndex := mgo.Index{
+		Key:        []string{"baseToken", "quoteToken", "createdAt"},
+		Background: true,
+		Name:       "index_trade_pair",
+	}
+	candleHashIndex := mgo.Index{
+		Key:        []string{"hash"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+		Sparse:     true,
+		Name:       "index_candle_hash",
+	}
+	candlePairIndex := mgo.Index{
+		Key:        []string{"baseToken", "quoteToken", "interval", "openTime"},
+		Background: true,
+		Name:       "index_candle_pair",
+	}
 
 	sc := db.Session.Copy()
 	defer sc.Close()
@@ -746,6 +826,11 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", tradeTxHashIndex.Name, err)
 		}
 	}
+	if !existingIndex(tradePairIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(tradesCollection).EnsureIndex(tradePairIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", tradePairIndex.Name, err)
+		}
+	}
 
 	indexes, _ = sc.DB(db.dbName).C(lendingItemsCollection).Indexes()
 	if !existingIndex(lendingItemHashIndex.Name, indexes) {
@@ -830,11 +915,24 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", epochPriceIndex.Name, err)
 		}
 	}
+
+	indexes, _ = sc.DB(db.dbName).C(candlesCollection).Indexes()
+	if !existingIndex(candleHashIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(candlesCollection).EnsureIndex(candleHashIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", candleHashIndex.Name, err)
+		}
+	}
+	if !existingIndex(candlePairIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(candlesCollection).EnsureIndex(candlePairIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", candlePairIndex.Name, err)
+		}
+	}
 	return nil
 }
 
 func (db *MongoDatabase) Close() error {
-	return db.Close()
+	db.Session.Close()
+	return nil
 }
 
 // HasAncient returns an error as we don't have a backing chain freezer.
@@ -0,0 +1,33 @@
+package FRExDAO_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FRECNET/FRExDAO"
+	"github.com/FRECNET/FRExDAO/daotest"
+)
+
+// TestMongoDatabase runs the conformance suite against the mongodb instance
+// given by the FREX_MONGODB_URL environment variable.
+func TestMongoDatabase(t *testing.T) {
+	url := os.Getenv("FREX_MONGODB_URL")
+	if url == "" {
+		t.Skip("FREX_MONGODB_URL not set")
+	}
+	prefix := fmt.Sprintf("frexdao_test_%d", time.Now().UnixNano())
+
+	var count int
+	t.Run("ObjectStoreSuite", func(t *testing.T) {
+		daotest.TestObjectStoreSuite(t, func() FRExDAO.FREXDAO {
+			count++
+			db, err := FRExDAO.NewMongoDatabase(nil, fmt.Sprintf("%s_%d", prefix, count), url, "", 0)
+			if err != nil {
+				t.Fatalf("failed to open mongodb: %v", err)
+			}
+			return db
+		})
+	})
+}
@@ -0,0 +1,567 @@
+package FRExDAO
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// sqlRow is an object along with the columns it is stored in.
+type sqlRow struct {
+	table   string
+	hash    string
+	columns []string
+	values  []interface{}
+	upsert  bool // replace the stored object, otherwise an existing one is kept
+	lending bool // part of the lending bulk
+}
+
+// SQLDatabase is a FREXDAO backed by a relational database through
+// database/sql. The driver must be registered by the binary, the queries are
+// written for the sqlite3 and postgres drivers, placeholders are rewritten for
+// the latter.
+type SQLDatabase struct {
+	db          *sql.DB
+	driver      string
+	emptyKey    []byte
+	cacheItems  *lru.Cache // Cache for reading
+	lock        sync.Mutex
+	bulk        []*sqlRow
+	lendingBulk []*sqlRow
+	seq         int64
+}
+
+// NewSQLDatabase opens the database of the given driver and creates the
+// tables if needed.
+func NewSQLDatabase(driver string, dsn string, cacheLimit int) (*SQLDatabase, error) {
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	itemCacheLimit := defaultCacheLimit
+	if cacheLimit > 0 {
+		itemCacheLimit = cacheLimit
+	}
+	cacheItems, _ := lru.New(itemCacheLimit)
+
+	db := &SQLDatabase{
+		db:         conn,
+		driver:     driver,
+		emptyKey:   EmptyKey(),
+		cacheItems: cacheItems,
+	}
+	if err := db.ensureSchema(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *SQLDatabase) ensureSchema() error {
+	for _, table := range sqlTables {
+		for _, stmt := range table.schema() {
+			if _, err := db.db.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to create table %s. Err: %v", table.name, err)
+			}
+		}
+		var seq sql.NullInt64
+		if err := db.db.QueryRow(fmt.Sprintf("SELECT MAX(seq) FROM %s", table.name)).Scan(&seq); err != nil {
+			return err
+		}
+		if seq.Valid && seq.Int64 > db.seq {
+			db.seq = seq.Int64
+		}
+	}
+	return nil
+}
+
+// rebind rewrites the ? placeholders of a query for the driver.
+func (db *SQLDatabase) rebind(query string) string {
+	if db.driver != "postgres" {
+		return query
+	}
+	var (
+		buf bytes.Buffer
+		n   int
+	)
+	for _, c := range query {
+		if c == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+func (db *SQLDatabase) IsEmptyKey(key []byte) bool {
+	return key == nil || len(key) == 0 || bytes.Equal(key, db.emptyKey)
+}
+
+func (db *SQLDatabase) getCacheKey(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+// sqlTableName returns the table objects of the type of val are stored in.
+func sqlTableName(val interface{}) (string, error) {
+	switch item := val.(type) {
+	case *tradingstate.OrderItem:
+		return ordersCollection, nil
+	case *tradingstate.Trade:
+		return tradesCollection, nil
+	case *tradingstate.EpochPriceItem:
+		return epochPriceCollection, nil
+	case *tradingstate.Candle:
+		return candlesCollection, nil
+	case *lendingstate.LendingTrade:
+		return lendingTradesCollection, nil
+	case *lendingstate.LendingItem:
+		switch item.Type {
+		case lendingstate.Repay:
+			return lendingRepayCollection, nil
+		case lendingstate.TopUp:
+			return lendingTopUpCollection, nil
+		case lendingstate.Recall:
+			return lendingRecallCollection, nil
+		default:
+			return lendingItemsCollection, nil
+		}
+	}
+	return "", fmt.Errorf("unknown type of object %T", val)
+}
+
+// newSQLRow returns the row storing an object, following the insert and
+// upsert rules of the mongodb backend.
+func newSQLRow(val interface{}) (*sqlRow, error) {
+	table, err := sqlTableName(val)
+	if err != nil {
+		return nil, err
+	}
+	row := &sqlRow{table: table}
+	switch item := val.(type) {
+	case *tradingstate.OrderItem:
+		row.hash = item.Hash.Hex()
+		row.upsert = item.Status != tradingstate.OrderStatusOpen
+	case *tradingstate.Trade:
+		row.hash = item.Hash.Hex()
+	case *tradingstate.EpochPriceItem:
+		row.hash = item.Hash.Hex()
+		row.upsert = true
+		if err := row.setJSON(val, common.Address{}, common.Address{}, "", 0); err != nil {
+			return nil, err
+		}
+	case *tradingstate.Candle:
+		row.hash = item.Hash.Hex()
+		row.upsert = true
+		if err := row.setJSON(val, item.BaseToken, item.QuoteToken, item.Interval, item.OpenTime.UnixNano()); err != nil {
+			return nil, err
+		}
+	case *lendingstate.LendingTrade:
+		row.hash = item.Hash.Hex()
+		row.upsert, row.lending = true, true
+	case *lendingstate.LendingItem:
+		switch item.Type {
+		case lendingstate.Repay, lendingstate.TopUp, lendingstate.Recall:
+			if item.Status != lendingstate.LendingStatusReject {
+				item.Status = item.Type
+			}
+		default:
+			row.upsert = item.Status != lendingstate.LendingStatusOpen
+		}
+		row.hash = item.Hash.Hex()
+		row.lending = true
+	}
+	if fields := sqlFields(val); fields != nil {
+		row.columns = sqlColumns(fields)
+		for _, field := range fields {
+			row.values = append(row.values, field.value())
+		}
+	}
+	return row, nil
+}
+
+// setJSON sets the columns of an object stored as JSON.
+func (row *sqlRow) setJSON(val interface{}, base, quote common.Address, interval string, itemTime int64) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	row.columns = []string{"hash", "pair_base", "pair_quote", "item_interval", "item_time", "data"}
+	row.values = []interface{}{row.hash, base.Hex(), quote.Hex(), interval, itemTime, string(data)}
+	return nil
+}
+
+// sqlSelect returns the columns objects of the type of val are read from.
+func sqlSelect(val interface{}) string {
+	if fields := sqlFields(val); fields != nil {
+		return strings.Join(sqlColumns(fields), ", ")
+	}
+	return "data"
+}
+
+// decodeSQLItems decodes the rows of a query into a slice of the type of val,
+// as returned by the mongodb backend.
+func decodeSQLItems(rows *sql.Rows, val interface{}) (interface{}, error) {
+	defer rows.Close()
+
+	typ := reflect.TypeOf(val)
+	items := reflect.MakeSlice(reflect.SliceOf(typ), 0, 0)
+	for rows.Next() {
+		item := reflect.New(typ.Elem()).Interface()
+		if fields := sqlFields(item); fields != nil {
+			dest := make([]interface{}, len(fields))
+			for i, field := range fields {
+				dest[i] = field.dest
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			for _, field := range fields {
+				if err := field.set(); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			var data []byte
+			if err := rows.Scan(&data); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+		}
+		items = reflect.Append(items, reflect.ValueOf(item))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items.Interface(), nil
+}
+
+func (db *SQLDatabase) HasObject(hash common.Hash, val interface{}) (bool, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return false, nil
+	}
+	if db.cacheItems.Contains(db.getCacheKey(hash.Bytes())) {
+		return true, nil
+	}
+	table, err := sqlTableName(val)
+	if err != nil {
+		return false, nil
+	}
+	var count int
+	query := db.rebind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE hash = ?", table))
+	if err := db.db.QueryRow(query, hash.Hex()).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (db *SQLDatabase) GetObject(hash common.Hash, val interface{}) (interface{}, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return nil, nil
+	}
+	cacheKey := db.getCacheKey(hash.Bytes())
+	if cached, ok := db.cacheItems.Get(cacheKey); ok {
+		return cached, nil
+	}
+	table, err := sqlTableName(val)
+	if err != nil {
+		return nil, nil
+	}
+	rows, err := db.db.Query(db.rebind(fmt.Sprintf("SELECT %s FROM %s WHERE hash = ?", sqlSelect(val), table)), hash.Hex())
+	if err != nil {
+		return nil, err
+	}
+	items, err := decodeSQLItems(rows, val)
+	if err != nil {
+		return nil, err
+	}
+	list := reflect.ValueOf(items)
+	if list.Len() == 0 {
+		return nil, sql.ErrNoRows
+	}
+	item := list.Index(0).Interface()
+	db.cacheItems.Add(cacheKey, item)
+	return item, nil
+}
+
+// PutObject queues an object in the bulk of its kind, it is readable from the
+// cache right away and stored on commit.
+func (db *SQLDatabase) PutObject(hash common.Hash, val interface{}) error {
+	row, err := newSQLRow(val)
+	if err != nil {
+		log.Error("PutObject: unknown type of object", "val", val)
+		return nil
+	}
+	db.cacheItems.Add(db.getCacheKey(hash.Bytes()), val)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if row.lending {
+		db.lendingBulk = append(db.lendingBulk, row)
+	} else {
+		db.bulk = append(db.bulk, row)
+	}
+	return nil
+}
+
+func (db *SQLDatabase) DeleteObject(hash common.Hash, val interface{}) error {
+	db.cacheItems.Remove(db.getCacheKey(hash.Bytes()))
+
+	table, err := sqlTableName(val)
+	if err != nil {
+		return nil
+	}
+	if _, err := db.db.Exec(db.rebind(fmt.Sprintf("DELETE FROM %s WHERE hash = ?", table)), hash.Hex()); err != nil {
+		return fmt.Errorf("failed to delete object from %s. Err: %v", table, err)
+	}
+	return nil
+}
+
+func (db *SQLDatabase) GetListItemByTxHash(txhash common.Hash, val interface{}) interface{} {
+	table, err := sqlTableName(val)
+	if err != nil {
+		log.Error("GetListItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return nil
+	}
+	rows, err := db.db.Query(db.rebind(fmt.Sprintf("SELECT %s FROM %s WHERE tx_hash = ? ORDER BY seq", sqlSelect(val), table)), txhash.Hex())
+	if err == nil {
+		var items interface{}
+		if items, err = decodeSQLItems(rows, val); err == nil {
+			return items
+		}
+	}
+	log.Error("failed to GetListItemByTxHash", "table", table, "err", err, "txhash", txhash)
+	return nil
+}
+
+func (db *SQLDatabase) GetListItemByHashes(hashes []string, val interface{}) interface{} {
+	table, err := sqlTableName(val)
+	if err != nil {
+		log.Error("GetListItemByHashes: Unknown object type", "hashes", hashes, "object", val)
+		return nil
+	}
+	if len(hashes) == 0 {
+		return reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(val)), 0, 0).Interface()
+	}
+	args := make([]interface{}, len(hashes))
+	for i, hash := range hashes {
+		args[i] = common.HexToHash(hash).Hex()
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(hashes)), ",")
+	rows, err := db.db.Query(db.rebind(fmt.Sprintf("SELECT %s FROM %s WHERE hash IN (%s) ORDER BY seq", sqlSelect(val), table, placeholders)), args...)
+	if err == nil {
+		var items interface{}
+		if items, err = decodeSQLItems(rows, val); err == nil {
+			return items
+		}
+	}
+	log.Error("failed to GetListItemByHashes", "table", table, "err", err, "hashes", hashes)
+	return nil
+}
+
+func (db *SQLDatabase) GetListItemByPair(baseToken, quoteToken common.Address, from, to time.Time, val interface{}) interface{} {
+	var (
+		query string
+		args  = []interface{}{baseToken.Hex(), quoteToken.Hex()}
+	)
+	switch item := val.(type) {
+	case *tradingstate.Trade:
+		query = fmt.Sprintf("SELECT %s FROM %s WHERE base_token = ? AND quote_token = ? AND created_at >= ? AND created_at < ? ORDER BY created_at, seq", sqlSelect(val), tradesCollection)
+	case *tradingstate.Candle:
+		query = fmt.Sprintf("SELECT data FROM %s WHERE pair_base = ? AND pair_quote = ? AND item_interval = ? AND item_time >= ? AND item_time < ? ORDER BY item_time", candlesCollection)
+		args = append(args, item.Interval)
+	default:
+		log.Error("GetListItemByPair: Unknown object type", "baseToken", baseToken, "quoteToken", quoteToken, "object", val)
+		return nil
+	}
+	args = append(args, from.UnixNano(), to.UnixNano())
+	rows, err := db.db.Query(db.rebind(query), args...)
+	if err == nil {
+		var items interface{}
+		if items, err = decodeSQLItems(rows, val); err == nil {
+			return items
+		}
+	}
+	log.Error("failed to GetListItemByPair", "err", err, "baseToken", baseToken, "quoteToken", quoteToken)
+	return nil
+}
+
+func (db *SQLDatabase) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	table, err := sqlTableName(val)
+	if err != nil {
+		log.Error("DeleteItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return
+	}
+	// drop the deleted objects from the cache as well
+	rows, err := db.db.Query(db.rebind(fmt.Sprintf("SELECT hash FROM %s WHERE tx_hash = ?", table)), txhash.Hex())
+	if err == nil {
+		for rows.Next() {
+			var hash string
+			if rows.Scan(&hash) == nil {
+				db.cacheItems.Remove(db.getCacheKey(common.HexToHash(hash).Bytes()))
+			}
+		}
+		rows.Close()
+	}
+	if _, err := db.db.Exec(db.rebind(fmt.Sprintf("DELETE FROM %s WHERE tx_hash = ?", table)), txhash.Hex()); err != nil {
+		log.Error("DeleteItemByTxHash: failed to delete objects", "table", table, "txhash", txhash, "err", err)
+	}
+}
+
+func (db *SQLDatabase) InitBulk() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.bulk = nil
+}
+
+func (db *SQLDatabase) InitLendingBulk() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.lendingBulk = nil
+}
+
+func (db *SQLDatabase) CommitBulk() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if err := db.commit(db.bulk); err != nil {
+		return err
+	}
+	db.bulk = nil
+	return nil
+}
+
+func (db *SQLDatabase) CommitLendingBulk() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if err := db.commit(db.lendingBulk); err != nil {
+		return err
+	}
+	db.lendingBulk = nil
+	return nil
+}
+
+// commit writes the queued rows in a single transaction, in queue order.
+func (db *SQLDatabase) commit(rows []*sqlRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.upsert {
+			if _, err := tx.Exec(db.rebind(fmt.Sprintf("DELETE FROM %s WHERE hash = ?", row.table)), row.hash); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		db.seq++
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(row.columns)+1), ", ")
+		insert := fmt.Sprintf("INSERT INTO %s (%s, seq) VALUES (%s) ON CONFLICT (hash) DO NOTHING", row.table, strings.Join(row.columns, ", "), placeholders)
+		if _, err := tx.Exec(db.rebind(insert), append(row.values, db.seq)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *SQLDatabase) Put(key []byte, val []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Delete(key []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Has(key []byte) (bool, error) {
+	// for levelDB only
+	return false, nil
+}
+
+func (db *SQLDatabase) Get(key []byte) ([]byte, error) {
+	// for levelDB only
+	return nil, nil
+}
+
+func (db *SQLDatabase) Close() error {
+	return db.db.Close()
+}
+
+// HasAncient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	return false, errNotSupported
+}
+
+// Ancient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// Ancients returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Ancients() (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AncientSize returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) AncientSize(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AppendAncient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	return errNotSupported
+}
+
+// TruncateAncients returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) TruncateAncients(items uint64) error {
+	return errNotSupported
+}
+
+// Sync returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Sync() error {
+	return errNotSupported
+}
+
+func (db *SQLDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Stat(property string) (string, error) {
+	return "", errNotSupported
+}
+
+func (db *SQLDatabase) Compact(start []byte, limit []byte) error {
+	return errNotSupported
+}
+
+func (db *SQLDatabase) NewBatch() ethdb.Batch {
+	// for levelDB only
+	return nil
+}
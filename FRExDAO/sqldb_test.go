@@ -0,0 +1,35 @@
+// +build sqlite
+
+package FRExDAO_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FRECNET/FRExDAO"
+	"github.com/FRECNET/FRExDAO/daotest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frexdao-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var count int
+	t.Run("ObjectStoreSuite", func(t *testing.T) {
+		daotest.TestObjectStoreSuite(t, func() FRExDAO.FREXDAO {
+			count++
+			db, err := FRExDAO.NewSQLDatabase("sqlite3", filepath.Join(dir, fmt.Sprintf("sdk%d.sqlite", count)), 0)
+			if err != nil {
+				t.Fatalf("failed to open sql database: %v", err)
+			}
+			return db
+		})
+	})
+}
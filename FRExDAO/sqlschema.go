@@ -0,0 +1,412 @@
+package FRExDAO
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+)
+
+// sqlTable is a table of the SQL backend, named after the collection of the
+// mongodb backend. Orders, trades, lending items and lending trades are stored
+// in typed columns, one per field, the other objects as JSON in a generic
+// table.
+type sqlTable struct {
+	name    string
+	object  interface{} // prototype of the objects stored in the table
+	indexes [][]string  // columns of the secondary indexes, the hash is the primary key
+}
+
+var sqlTables = []sqlTable{
+	{ordersCollection, &tradingstate.OrderItem{}, [][]string{{"tx_hash"}, {"user_address"}, {"base_token", "quote_token"}}},
+	{tradesCollection, &tradingstate.Trade{}, [][]string{{"tx_hash"}, {"base_token", "quote_token", "created_at"}}},
+	{lendingItemsCollection, &lendingstate.LendingItem{}, [][]string{{"tx_hash"}, {"user_address"}, {"lending_token", "collateral_token"}}},
+	{lendingTradesCollection, &lendingstate.LendingTrade{}, [][]string{{"tx_hash"}, {"borrower"}, {"investor"}, {"lending_token", "collateral_token"}}},
+	{lendingTopUpCollection, &lendingstate.LendingItem{Type: lendingstate.TopUp}, [][]string{{"tx_hash"}}},
+	{lendingRepayCollection, &lendingstate.LendingItem{Type: lendingstate.Repay}, [][]string{{"tx_hash"}}},
+	{lendingRecallCollection, &lendingstate.LendingItem{Type: lendingstate.Recall}, [][]string{{"tx_hash"}}},
+	{epochPriceCollection, &tradingstate.EpochPriceItem{}, nil},
+	{candlesCollection, &tradingstate.Candle{}, nil},
+}
+
+// sqlJSONSchema is the schema of the tables storing their objects as JSON in
+// data, next to the columns they are looked up by. item_time is the open time
+// of candles in nanoseconds.
+const sqlJSONSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	hash          VARCHAR(66) NOT NULL PRIMARY KEY,
+	pair_base     VARCHAR(45) NOT NULL,
+	pair_quote    VARCHAR(45) NOT NULL,
+	item_interval VARCHAR(8)  NOT NULL,
+	item_time     BIGINT      NOT NULL,
+	seq           BIGINT      NOT NULL,
+	data          TEXT        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_%[1]s_pair ON %[1]s (pair_base, pair_quote, item_interval, item_time)`
+
+// schema returns the statements creating the table and its indexes.
+func (t *sqlTable) schema() []string {
+	fields := sqlFields(t.object)
+	if fields == nil {
+		return strings.Split(fmt.Sprintf(sqlJSONSchema, t.name), ";\n")
+	}
+	columns := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		column := field.column + " " + field.sqlType
+		if field.column == "hash" {
+			column += " PRIMARY KEY"
+		}
+		columns = append(columns, column)
+	}
+	// seq keeps the objects in matching order
+	columns = append(columns, "seq BIGINT NOT NULL")
+
+	stmts := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", t.name, strings.Join(columns, ",\n\t"))}
+	for _, index := range t.indexes {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_%s ON %s (%s)", t.name, strings.Join(index, "_"), t.name, strings.Join(index, ", ")))
+	}
+	return stmts
+}
+
+// sqlField binds a typed column to a field of an object.
+type sqlField struct {
+	column  string
+	sqlType string
+	value   func() interface{} // value the field is stored as
+	dest    interface{}        // destination the column is scanned into
+	set     func() error       // copies the scanned column to the field
+}
+
+// sqlColumns returns the names of the columns of the fields.
+func sqlColumns(fields []sqlField) []string {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+	return columns
+}
+
+// sqlFields returns the column bindings of an object stored in a typed table,
+// or nil if the object is stored as JSON.
+func sqlFields(val interface{}) []sqlField {
+	switch item := val.(type) {
+	case *tradingstate.OrderItem:
+		return orderFields(item)
+	case *tradingstate.Trade:
+		return tradeFields(item)
+	case *lendingstate.LendingItem:
+		return lendingItemFields(item)
+	case *lendingstate.LendingTrade:
+		return lendingTradeFields(item)
+	}
+	return nil
+}
+
+func orderFields(item *tradingstate.OrderItem) []sqlField {
+	return []sqlField{
+		hashField("hash", &item.Hash),
+		hashField("tx_hash", &item.TxHash),
+		addressField("exchange_address", &item.ExchangeAddress),
+		addressField("user_address", &item.UserAddress),
+		addressField("base_token", &item.BaseToken),
+		addressField("quote_token", &item.QuoteToken),
+		bigIntField("quantity", &item.Quantity),
+		bigIntField("price", &item.Price),
+		bigIntField("filled_amount", &item.FilledAmount),
+		bigIntField("nonce", &item.Nonce),
+		bigIntField("trigger_price", &item.TriggerPrice),
+		stringField("status", "VARCHAR(32)", &item.Status),
+		stringField("side", "VARCHAR(16)", &item.Side),
+		stringField("type", "VARCHAR(16)", &item.Type),
+		stringField("time_in_force", "VARCHAR(16)", &item.TimeInForce),
+		stringField("self_trade_mode", "VARCHAR(32)", &item.SelfTradeMode),
+		orderSignatureField("signature", &item.Signature),
+		timeField("created_at", &item.CreatedAt),
+		timeField("updated_at", &item.UpdatedAt),
+		uint64Field("order_id", &item.OrderID),
+		uint64Field("expire_at", &item.ExpireAt),
+		stringField("extra_data", "TEXT", &item.ExtraData),
+		batchField("batch", &item.Batch),
+	}
+}
+
+func tradeFields(item *tradingstate.Trade) []sqlField {
+	return []sqlField{
+		hashField("hash", &item.Hash),
+		hashField("tx_hash", &item.TxHash),
+		addressField("taker", &item.Taker),
+		addressField("maker", &item.Maker),
+		addressField("base_token", &item.BaseToken),
+		addressField("quote_token", &item.QuoteToken),
+		hashField("maker_order_hash", &item.MakerOrderHash),
+		hashField("taker_order_hash", &item.TakerOrderHash),
+		addressField("maker_exchange", &item.MakerExchange),
+		addressField("taker_exchange", &item.TakerExchange),
+		bigIntField("price_point", &item.PricePoint),
+		bigIntField("amount", &item.Amount),
+		bigIntField("make_fee", &item.MakeFee),
+		bigIntField("take_fee", &item.TakeFee),
+		stringField("status", "VARCHAR(32)", &item.Status),
+		stringField("taker_order_side", "VARCHAR(16)", &item.TakerOrderSide),
+		stringField("taker_order_type", "VARCHAR(16)", &item.TakerOrderType),
+		stringField("maker_order_type", "VARCHAR(16)", &item.MakerOrderType),
+		timeField("created_at", &item.CreatedAt),
+		timeField("updated_at", &item.UpdatedAt),
+	}
+}
+
+func lendingItemFields(item *lendingstate.LendingItem) []sqlField {
+	return []sqlField{
+		hashField("hash", &item.Hash),
+		hashField("tx_hash", &item.TxHash),
+		addressField("user_address", &item.UserAddress),
+		addressField("relayer", &item.Relayer),
+		addressField("lending_token", &item.LendingToken),
+		addressField("collateral_token", &item.CollateralToken),
+		bigIntField("quantity", &item.Quantity),
+		bigIntField("interest", &item.Interest),
+		bigIntField("filled_amount", &item.FilledAmount),
+		bigIntField("nonce", &item.Nonce),
+		stringField("side", "VARCHAR(16)", &item.Side),
+		stringField("type", "VARCHAR(16)", &item.Type),
+		stringField("status", "VARCHAR(32)", &item.Status),
+		boolField("auto_top_up", &item.AutoTopUp),
+		uint64Field("term", &item.Term),
+		lendingSignatureField("signature", &item.Signature),
+		timeField("created_at", &item.CreatedAt),
+		timeField("updated_at", &item.UpdatedAt),
+		uint64Field("lending_id", &item.LendingId),
+		uint64Field("trade_id", &item.LendingTradeId),
+		stringField("extra_data", "TEXT", &item.ExtraData),
+	}
+}
+
+func lendingTradeFields(item *lendingstate.LendingTrade) []sqlField {
+	return []sqlField{
+		hashField("hash", &item.Hash),
+		hashField("tx_hash", &item.TxHash),
+		addressField("borrower", &item.Borrower),
+		addressField("investor", &item.Investor),
+		addressField("lending_token", &item.LendingToken),
+		addressField("collateral_token", &item.CollateralToken),
+		hashField("borrowing_order_hash", &item.BorrowingOrderHash),
+		hashField("investing_order_hash", &item.InvestingOrderHash),
+		addressField("borrowing_relayer", &item.BorrowingRelayer),
+		addressField("investing_relayer", &item.InvestingRelayer),
+		uint64Field("term", &item.Term),
+		uint64Field("interest", &item.Interest),
+		bigIntField("collateral_price", &item.CollateralPrice),
+		bigIntField("liquidation_price", &item.LiquidationPrice),
+		bigIntField("collateral_locked_amount", &item.CollateralLockedAmount),
+		boolField("auto_top_up", &item.AutoTopUp),
+		uint64Field("liquidation_time", &item.LiquidationTime),
+		bigIntField("deposit_rate", &item.DepositRate),
+		bigIntField("liquidation_rate", &item.LiquidationRate),
+		bigIntField("recall_rate", &item.RecallRate),
+		bigIntField("amount", &item.Amount),
+		bigIntField("borrowing_fee", &item.BorrowingFee),
+		bigIntField("investing_fee", &item.InvestingFee),
+		stringField("status", "VARCHAR(32)", &item.Status),
+		stringField("taker_order_side", "VARCHAR(16)", &item.TakerOrderSide),
+		stringField("taker_order_type", "VARCHAR(16)", &item.TakerOrderType),
+		stringField("maker_order_type", "VARCHAR(16)", &item.MakerOrderType),
+		uint64Field("trade_id", &item.TradeId),
+		stringField("extra_data", "TEXT", &item.ExtraData),
+		timeField("created_at", &item.CreatedAt),
+		timeField("updated_at", &item.UpdatedAt),
+		bigIntField("interest_index", &item.InterestIndex),
+		uint64Field("auction_round", &item.AuctionRound),
+		uint64Field("auction_block", &item.AuctionBlock),
+	}
+}
+
+func hashField(column string, hash *common.Hash) sqlField {
+	var s sql.NullString
+	return sqlField{column, "VARCHAR(66) NOT NULL",
+		func() interface{} { return hash.Hex() },
+		&s,
+		func() error { *hash = common.HexToHash(s.String); return nil },
+	}
+}
+
+func addressField(column string, addr *common.Address) sqlField {
+	var s sql.NullString
+	return sqlField{column, "VARCHAR(45) NOT NULL",
+		func() interface{} { return addr.Hex() },
+		&s,
+		func() error { *addr = common.HexToAddress(s.String); return nil },
+	}
+}
+
+// bigIntField stores an integer in decimal, as the amounts overflow the integer
+// column types. A nil integer is stored as NULL.
+func bigIntField(column string, n **big.Int) sqlField {
+	var s sql.NullString
+	return sqlField{column, "VARCHAR(80)",
+		func() interface{} {
+			if *n == nil {
+				return nil
+			}
+			return (*n).String()
+		},
+		&s,
+		func() error {
+			*n = nil
+			if !s.Valid {
+				return nil
+			}
+			v, ok := new(big.Int).SetString(s.String, 10)
+			if !ok {
+				return fmt.Errorf("invalid integer %q in column %s", s.String, column)
+			}
+			*n = v
+			return nil
+		},
+	}
+}
+
+func stringField(column string, sqlType string, str *string) sqlField {
+	var s sql.NullString
+	return sqlField{column, sqlType + " NOT NULL",
+		func() interface{} { return *str },
+		&s,
+		func() error { *str = s.String; return nil },
+	}
+}
+
+func boolField(column string, b *bool) sqlField {
+	var v sql.NullBool
+	return sqlField{column, "BOOLEAN NOT NULL",
+		func() interface{} { return *b },
+		&v,
+		func() error { *b = v.Bool; return nil },
+	}
+}
+
+// uint64Field stores an integer in a BIGINT column, the values past the signed
+// range wrap around and are restored as is.
+func uint64Field(column string, n *uint64) sqlField {
+	var v sql.NullInt64
+	return sqlField{column, "BIGINT NOT NULL",
+		func() interface{} { return int64(*n) },
+		&v,
+		func() error { *n = uint64(v.Int64); return nil },
+	}
+}
+
+// timeField stores a time in nanoseconds since the epoch, NULL for the zero
+// time which doesn't fit.
+func timeField(column string, t *time.Time) sqlField {
+	var v sql.NullInt64
+	return sqlField{column, "BIGINT",
+		func() interface{} {
+			if t.IsZero() {
+				return nil
+			}
+			return t.UnixNano()
+		},
+		&v,
+		func() error {
+			*t = time.Time{}
+			if v.Valid {
+				*t = time.Unix(0, v.Int64).UTC()
+			}
+			return nil
+		},
+	}
+}
+
+// signatureField stores a signature as the hex encoding of R, S and V.
+func signatureField(column string, value func() []byte, set func([]byte)) sqlField {
+	var s sql.NullString
+	return sqlField{column, "VARCHAR(130)",
+		func() interface{} {
+			if sig := value(); sig != nil {
+				return hex.EncodeToString(sig)
+			}
+			return nil
+		},
+		&s,
+		func() error {
+			if !s.Valid {
+				set(nil)
+				return nil
+			}
+			sig, err := hex.DecodeString(s.String)
+			if err != nil || len(sig) != 2*common.HashLength+1 {
+				return fmt.Errorf("invalid signature %q in column %s", s.String, column)
+			}
+			set(sig)
+			return nil
+		},
+	}
+}
+
+func orderSignatureField(column string, signature **tradingstate.Signature) sqlField {
+	return signatureField(column,
+		func() []byte {
+			if *signature == nil {
+				return nil
+			}
+			return append(append((*signature).R.Bytes(), (*signature).S.Bytes()...), (*signature).V)
+		},
+		func(sig []byte) {
+			*signature = nil
+			if sig != nil {
+				*signature = &tradingstate.Signature{R: common.BytesToHash(sig[:32]), S: common.BytesToHash(sig[32:64]), V: sig[64]}
+			}
+		},
+	)
+}
+
+func lendingSignatureField(column string, signature **lendingstate.Signature) sqlField {
+	return signatureField(column,
+		func() []byte {
+			if *signature == nil {
+				return nil
+			}
+			return append(append((*signature).R.Bytes(), (*signature).S.Bytes()...), (*signature).V)
+		},
+		func(sig []byte) {
+			*signature = nil
+			if sig != nil {
+				*signature = &lendingstate.Signature{R: common.BytesToHash(sig[:32]), S: common.BytesToHash(sig[32:64]), V: sig[64]}
+			}
+		},
+	)
+}
+
+// batchField stores the operations of a batch order as JSON, NULL for the
+// other orders.
+func batchField(column string, batch **types.BatchOrderOps) sqlField {
+	var s sql.NullString
+	return sqlField{column, "TEXT",
+		func() interface{} {
+			if *batch == nil {
+				return nil
+			}
+			data, _ := json.Marshal(*batch)
+			return string(data)
+		},
+		&s,
+		func() error {
+			*batch = nil
+			if !s.Valid {
+				return nil
+			}
+			ops := new(types.BatchOrderOps)
+			if err := json.Unmarshal([]byte(s.String), ops); err != nil {
+				return err
+			}
+			*batch = ops
+			return nil
+		},
+	}
+}
@@ -1,10 +1,12 @@
 package FRExlending
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/FRECNET/FRExlending/lendingstate"
 	"github.com/FRECNET/consensus"
 	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/event"
 	"github.com/FRECNET/p2p"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 
@@ -32,8 +35,10 @@ const (
 )
 
 var (
-	ErrNonceTooHigh = errors.New("nonce too high")
-	ErrNonceTooLow  = errors.New("nonce too low")
+	ErrNonceTooHigh         = errors.New("nonce too high")
+	ErrNonceTooLow          = errors.New("nonce too low")
+	ErrLendingTradeNotFound = errors.New("lending trade not found")
+	ErrTradeNotLiquidated   = errors.New("lending trade not liquidated")
 )
 
 type Lending struct {
@@ -45,6 +50,11 @@ type Lending struct {
 	FREx                *FREx.FREX
 	lendingItemHistory  *lru.Cache
 	lendingTradeHistory *lru.Cache
+	priceSources        []PriceSource
+
+	lendingTradeFeed event.Feed
+	liquidationFeed  event.Feed
+	scope            event.SubscriptionScope
 }
 
 func (l *Lending) Protocols() []p2p.Protocol {
@@ -59,6 +69,7 @@ func (l *Lending) SaveData() {
 }
 
 func (l *Lending) Stop() error {
+	l.scope.Close()
 	return nil
 }
 
@@ -73,6 +84,7 @@ func New(FREx *FREx.FREX) *Lending {
 	}
 	lending.StateCache = lendingstate.NewDatabase(FREx.GetLevelDB())
 	lending.FREx = FREx
+	lending.priceSources = defaultPriceSources(lending)
 	return lending
 }
 
@@ -80,8 +92,8 @@ func (l *Lending) GetLevelDB() FRExDAO.FREXDAO {
 	return l.FREx.GetLevelDB()
 }
 
-func (l *Lending) GetMongoDB() FRExDAO.FREXDAO {
-	return l.FREx.GetMongoDB()
+func (l *Lending) GetSDKDB() FRExDAO.FREXDAO {
+	return l.FREx.GetSDKDB()
 }
 
 // APIs returns the RPC descriptors the Lending implementation offers
@@ -213,7 +225,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		makerDirtyFilledAmount                          map[string]*big.Int
 		err                                             error
 	)
-	db := l.GetMongoDB()
+	db := l.GetSDKDB()
 	db.InitLendingBulk()
 	if takerLendingItem.Status == lendingstate.LendingStatusCancelled && len(rejectedItems) > 0 {
 		// cancel order is rejected -> nothing change
@@ -289,7 +301,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Repay:
 				updatedTakerLendingItem.Status = lendingstate.Repay
-				paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
+				paymentBalance := lendingstate.CalculateRepaidValue(block.Time().Uint64(), tradeRecord)
 				updatedTakerLendingItem.Quantity = paymentBalance
 				updatedTakerLendingItem.FilledAmount = paymentBalance
 				// manual repay item
@@ -478,7 +490,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 }
 
 func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error {
-	db := l.GetMongoDB()
+	db := l.GetSDKDB()
 	db.InitLendingBulk()
 
 	txhash := result.TxHash
@@ -494,7 +506,7 @@ func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.Fi
 			if trade == nil {
 				continue
 			}
-			paymentBalance := lendingstate.CalculateTotalRepayValue(blockTime, trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+			paymentBalance := lendingstate.CalculateRepaidValue(blockTime, trade)
 			repayItem := &lendingstate.LendingItem{
 				Quantity:        paymentBalance,
 				Interest:        big.NewInt(int64(trade.Interest)),
@@ -623,8 +635,36 @@ func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.Fi
 	return nil
 }
 
+// GetLiquidationData returns the liquidation details of a trade stored by the
+// SDK node, including the oracle sources of the collateral price, for fully
+// and partially liquidated trades.
+func (l *Lending) GetLiquidationData(tradeHash common.Hash) (*lendingstate.LiquidationData, error) {
+	if !l.FREx.IsSDKNode() {
+		return nil, FREx.ErrNotSDKNode
+	}
+	val, err := l.GetSDKDB().GetObject(tradeHash, &lendingstate.LendingTrade{})
+	if err != nil || val == nil {
+		return nil, ErrLendingTradeNotFound
+	}
+	trade := val.(*lendingstate.LendingTrade)
+	if trade.ExtraData == "" {
+		return nil, ErrTradeNotLiquidated
+	}
+	liquidationData := &lendingstate.LiquidationData{}
+	if err := json.Unmarshal([]byte(trade.ExtraData), liquidationData); err != nil {
+		if trade.Status != lendingstate.TradeStatusLiquidated {
+			return nil, ErrTradeNotLiquidated
+		}
+		return nil, err
+	}
+	if trade.Status != lendingstate.TradeStatusLiquidated && !liquidationData.Partial {
+		return nil, ErrTradeNotLiquidated
+	}
+	return liquidationData, nil
+}
+
 func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.LendingTrade, txhash common.Hash, txTime time.Time) error {
-	db := l.GetMongoDB()
+	db := l.GetSDKDB()
 	hashQuery := []string{}
 	if len(trades) == 0 {
 		return nil
@@ -639,6 +679,7 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 				TxHash:                 trade.TxHash,
 				CollateralLockedAmount: trade.CollateralLockedAmount,
 				LiquidationPrice:       trade.LiquidationPrice,
+				LiquidationTime:        trade.LiquidationTime,
 				Status:                 trade.Status,
 				UpdatedAt:              trade.UpdatedAt,
 			}
@@ -650,7 +691,13 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 			trade.CollateralLockedAmount = newTrade.CollateralLockedAmount
 			trade.Status = newTrade.Status
 			trade.LiquidationPrice = newTrade.LiquidationPrice
+			// recalls of open-term trades set their liquidation time
+			trade.LiquidationTime = newTrade.LiquidationTime
 			trade.ExtraData = newTrade.ExtraData
+			if newTrade.Amount != nil && newTrade.Amount.Sign() > 0 {
+				// partial liquidations repay a part of the debt
+				trade.Amount = newTrade.Amount
+			}
 
 			if err := db.PutObject(trade.Hash, trade); err != nil {
 				return err
@@ -747,7 +794,7 @@ func (l *Lending) UpdateLendingTradeCache(hash common.Hash, txhash common.Hash,
 }
 
 func (l *Lending) RollbackLendingData(txhash common.Hash) error {
-	db := l.GetMongoDB()
+	db := l.GetSDKDB()
 	db.InitLendingBulk()
 
 	// rollback lendingItem
@@ -809,6 +856,7 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 			trade.Status = lendingTradeHistoryItem.Status
 			trade.CollateralLockedAmount = lendingstate.CloneBigInt(lendingTradeHistoryItem.CollateralLockedAmount)
 			trade.LiquidationPrice = lendingstate.CloneBigInt(lendingTradeHistoryItem.LiquidationPrice)
+			trade.LiquidationTime = lendingTradeHistoryItem.LiquidationTime
 			trade.UpdatedAt = lendingTradeHistoryItem.UpdatedAt
 			log.Debug("FRExlending reorg: update trade to the last lendingTradeHistoryItem", "trade", lendingstate.ToJSON(trade), "lendingTradeHistoryItem", lendingTradeHistoryItem)
 			if err := db.PutObject(trade.Hash, trade); err != nil {
@@ -835,6 +883,9 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 	autoRepayTrades = []*lendingstate.LendingTrade{}
 	autoTopUpTrades = []*lendingstate.LendingTrade{}
 	autoRecallTrades = []*lendingstate.LendingTrade{}
+	userIndex := chain.Config().IsTIPFREXPortfolio(header.Number)
+	tradingState.SetUserIndex(userIndex)
+	lendingState.SetUserIndex(userIndex)
 
 	allPairs, err := lendingstate.GetAllLendingPairs(statedb)
 	if err != nil {
@@ -847,6 +898,23 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
 	}
 
+	partialLiquidation := chain.Config().IsTIPFREXPartialLiquidation(header.Number)
+	partiallyLiquidated := map[common.Hash]bool{}
+	lendingParams := chain.Config().LendingParams()
+
+	if chain.Config().IsTIPFREXOpenTermLending(header.Number) {
+		// reprice the open-term lending books, their recalled trades are
+		// liquidated by time as well
+		for _, lendingToken := range lendingstate.GetSupportedBaseToken(statedb) {
+			lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, lendingstate.OpenTerm)
+			if err := l.updateOpenTermBook(header, lendingState, lendingBook); err != nil {
+				log.Error("Fail when update open-term lending book", "lendingBook", lendingBook.Hex(), "error", err)
+				return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+			}
+			allLendingBooks[lendingBook] = true
+		}
+	}
+
 	// liquidate trades by time
 	for lendingBook := range allLendingBooks {
 		lowestTime, tradingIds := lendingState.GetLowestLiquidationTime(lendingBook, time)
@@ -874,16 +942,28 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 
 	for _, lendingPair := range allPairs {
 		orderbook := tradingstate.GetTradingOrderBookHash(lendingPair.CollateralToken, lendingPair.LendingToken)
-		_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingState, lendingPair.CollateralToken, lendingPair.LendingToken)
+		collateralPrice, priceSources, err := l.getLiquidationPrice(header, chain, statedb, tradingState, lendingPair.CollateralToken, lendingPair.LendingToken)
 		if err != nil || collateralPrice == nil || collateralPrice.Sign() == 0 {
 			log.Error("Fail when get price collateral/lending ", "CollateralToken", lendingPair.CollateralToken.Hex(), "LendingToken", lendingPair.LendingToken.Hex(), "error", err)
 			// ignore this pair, do not throw error
 			continue
 		}
 		// liquidate trades
+		var auctions []auctionedTrade
 		highestLiquidatePrice, liquidationData := tradingState.GetHighestLiquidationPriceData(orderbook, collateralPrice)
 		for highestLiquidatePrice.Sign() > 0 && collateralPrice.Cmp(highestLiquidatePrice) < 0 {
-			for lendingBook, tradingIds := range liquidationData {
+			lendingBooks := make([]common.Hash, 0, len(liquidationData))
+			for lendingBook := range liquidationData {
+				lendingBooks = append(lendingBooks, lendingBook)
+			}
+			if partialLiquidation {
+				// keep the liquidated trades in the same order on every node
+				sort.Slice(lendingBooks, func(i, j int) bool {
+					return bytes.Compare(lendingBooks[i].Bytes(), lendingBooks[j].Bytes()) < 0
+				})
+			}
+			for _, lendingBook := range lendingBooks {
+				tradingIds := liquidationData[lendingBook]
 				for _, tradingIdHash := range tradingIds {
 					trade := lendingState.GetLendingTrade(lendingBook, tradingIdHash)
 					if trade.AutoTopUp {
@@ -895,6 +975,36 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 							continue
 						}
 					}
+					// a trade still unhealthy after its partial liquidation is liquidated entirely
+					if partialLiquidation && !partiallyLiquidated[trade.Hash] {
+						partiallyLiquidated[trade.Hash] = true
+						log.Debug("PartialLiquidationTrade", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "auction", lendingParams.Auction)
+						var (
+							newTrade             *lendingstate.LendingTrade
+							tradeLiquidationData *lendingstate.LiquidationData
+							running              bool
+						)
+						if lendingParams.Auction {
+							newTrade, tradeLiquidationData, running, err = l.AuctionLiquidationTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64(), collateralPrice)
+						} else {
+							newTrade, tradeLiquidationData, err = l.PartialLiquidationTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64(), collateralPrice)
+						}
+						if err != nil {
+							log.Error("Fail when partially liquidate trade", "time", time, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "error", err)
+							return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+						}
+						if newTrade != nil && newTrade.Hash != (common.Hash{}) {
+							tradeLiquidationData.PriceSources = priceSources
+							extraData, _ := json.Marshal(tradeLiquidationData)
+							newTrade.ExtraData = string(extraData)
+							liquidatedTrades = append(liquidatedTrades, newTrade)
+							updatedTrades[newTrade.Hash] = newTrade
+						}
+						if running {
+							auctions = append(auctions, auctionedTrade{lendingBook, newTrade})
+						}
+						continue
+					}
 					log.Debug("LiquidationTrade", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex())
 					newTrade, err := l.LiquidationTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64())
 					if err != nil {
@@ -903,13 +1013,14 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 					}
 					if newTrade != nil && newTrade.Hash != (common.Hash{}) {
 						newTrade.Status = lendingstate.TradeStatusLiquidated
-						liquidationData := lendingstate.LiquidationData{
+						tradeLiquidationData := lendingstate.LiquidationData{
 							RecallAmount:      common.Big0,
 							LiquidationAmount: newTrade.CollateralLockedAmount,
 							CollateralPrice:   collateralPrice,
 							Reason:            lendingstate.LiquidatedByPrice,
+							PriceSources:      priceSources,
 						}
-						extraData, _ := json.Marshal(liquidationData)
+						extraData, _ := json.Marshal(tradeLiquidationData)
 						newTrade.ExtraData = string(extraData)
 						liquidatedTrades = append(liquidatedTrades, newTrade)
 						updatedTrades[newTrade.Hash] = newTrade
@@ -918,6 +1029,10 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 			}
 			highestLiquidatePrice, liquidationData = tradingState.GetHighestLiquidationPriceData(orderbook, collateralPrice)
 		}
+		// the trades whose auction goes on are liquidated again at the next round
+		for _, auction := range auctions {
+			tradingState.InsertLiquidationPrice(orderbook, auction.trade.LiquidationPrice, auction.lendingBook, auction.trade.TradeId)
+		}
 		// recall trades
 		depositRate, liquidationRate, recallRate := lendingstate.GetCollateralDetail(statedb, lendingPair.CollateralToken)
 		recalLiquidatePrice := new(big.Int).Mul(collateralPrice, common.BaseRecall)
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/rpc"
 )
 
 // List of errors
@@ -35,3 +39,91 @@ func NewPublicFREXLendingAPI(t *Lending) *PublicFREXLendingAPI {
 func (api *PublicFREXLendingAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetLiquidation returns the liquidation details of a liquidated lending
+// trade, the collateral price and the oracle sources it was aggregated from.
+// It is only served by SDK nodes.
+func (api *PublicFREXLendingAPI) GetLiquidation(ctx context.Context, tradeHash common.Hash) (*lendingstate.LiquidationData, error) {
+	return api.t.GetLiquidationData(tradeHash)
+}
+
+// LendingTrades creates a subscription that fires with the lending trades of a
+// lending book opened by each new block. Trades of blocks dropped by a reorg
+// are sent again with removed set.
+func (api *PublicFREXLendingAPI) LendingTrades(ctx context.Context, lendingToken common.Address, term uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan LendingTradeEvent)
+		eventsSub := api.t.SubscribeLendingTradeEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				if trades := filterLendingTrades(ev.Trades, lendingToken, term); len(trades) > 0 {
+					ev.Trades = trades
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Liquidations creates a subscription that fires with the lending trades of a
+// lending book liquidated by each new block.
+func (api *PublicFREXLendingAPI) Liquidations(ctx context.Context, lendingToken common.Address, term uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan LiquidationEvent)
+		eventsSub := api.t.SubscribeLiquidationEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				if trades := filterLendingTrades(ev.Trades, lendingToken, term); len(trades) > 0 {
+					ev.Trades = trades
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// filterLendingTrades returns the trades of the lending book of a token and
+// term.
+func filterLendingTrades(trades []*lendingstate.LendingTrade, lendingToken common.Address, term uint64) []*lendingstate.LendingTrade {
+	var filtered []*lendingstate.LendingTrade
+	for _, trade := range trades {
+		if trade.LendingToken == lendingToken && trade.Term == term {
+			filtered = append(filtered, trade)
+		}
+	}
+	return filtered
+}
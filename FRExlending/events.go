@@ -0,0 +1,55 @@
+package FRExlending
+
+import (
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/event"
+)
+
+// LendingTradeEvent is posted for the lending trades opened by a block. Removed
+// is set when the block left the canonical chain in a reorg.
+type LendingTradeEvent struct {
+	Trades      []*lendingstate.LendingTrade `json:"trades"`
+	BlockNumber uint64                       `json:"blockNumber"`
+	BlockHash   common.Hash                  `json:"blockHash"`
+	Removed     bool                         `json:"removed"`
+}
+
+// LiquidationEvent is posted for the lending trades liquidated by a block.
+// Removed is set when the block left the canonical chain in a reorg.
+type LiquidationEvent struct {
+	Trades      []*lendingstate.LendingTrade `json:"trades"`
+	BlockNumber uint64                       `json:"blockNumber"`
+	BlockHash   common.Hash                  `json:"blockHash"`
+	Removed     bool                         `json:"removed"`
+}
+
+// SubscribeLendingTradeEvent registers a subscription of LendingTradeEvent.
+func (l *Lending) SubscribeLendingTradeEvent(ch chan<- LendingTradeEvent) event.Subscription {
+	return l.scope.Track(l.lendingTradeFeed.Subscribe(ch))
+}
+
+// SubscribeLiquidationEvent registers a subscription of LiquidationEvent.
+func (l *Lending) SubscribeLiquidationEvent(ch chan<- LiquidationEvent) event.Subscription {
+	return l.scope.Track(l.liquidationFeed.Subscribe(ch))
+}
+
+// PostLendingEvents posts the lending trade and liquidation events of a block.
+// The trades are the ones returned by ApplyOrder when the block was processed,
+// the liquidated trades are looked up in the finalized trades of the block.
+// Removed is set when the block left the canonical chain.
+func (l *Lending) PostLendingEvents(block *types.Block, trades []*lendingstate.LendingTrade, finalized lendingstate.FinalizedResult, finalizedTrades map[common.Hash]*lendingstate.LendingTrade, removed bool) {
+	if len(trades) > 0 {
+		l.lendingTradeFeed.Send(LendingTradeEvent{Trades: trades, BlockNumber: block.NumberU64(), BlockHash: block.Hash(), Removed: removed})
+	}
+	var liquidated []*lendingstate.LendingTrade
+	for _, hash := range finalized.Liquidated {
+		if trade := finalizedTrades[hash]; trade != nil {
+			liquidated = append(liquidated, trade)
+		}
+	}
+	if len(liquidated) > 0 {
+		l.liquidationFeed.Send(LiquidationEvent{Trades: liquidated, BlockNumber: block.NumberU64(), BlockHash: block.Hash(), Removed: removed})
+	}
+}
@@ -38,8 +38,18 @@ type lendingObject struct {
 	LiquidationTimeRoot common.Hash
 	LendingItemRoot     common.Hash
 	LendingTradeRoot    common.Hash
+	InterestIndex       *big.Int    `rlp:"optional"` // interest accrued by open-term trades, from common.BasePrice
+	InterestRate        uint64      `rlp:"optional"` // variable rate of open-term trades
+	AccrualTime         uint64      `rlp:"optional"` // time the interest index was last accrued
+	UserIndexRoot       common.Hash `rlp:"optional"` // lending items and trades of a user address, zero until the first indexed one
 }
 
+// kinds of the entries of the user index
+const (
+	userLendingItem  = byte(0)
+	userLendingTrade = byte(1)
+)
+
 // liquidation reasons
 const (
 	LiquidatedByTime  = uint64(0)
@@ -51,6 +61,15 @@ type LiquidationData struct {
 	LiquidationAmount *big.Int
 	CollateralPrice   *big.Int
 	Reason            uint64
+	PriceSources      []string `json:",omitempty"` // oracle sources of the collateral price
+	Partial           bool     `json:",omitempty"` // the trade stays open with the remaining collateral
+	RepaidAmount      *big.Int `json:",omitempty"` // debt repaid by the liquidated collateral
+	Penalty           *big.Int `json:",omitempty"` // liquidation penalty paid in collateral
+	KeeperFee         *big.Int `json:",omitempty"` // part of the penalty paid to the masternode creating the block
+	AuctionRound      uint64   `json:",omitempty"` // round of the liquidation auction of the collateral
+	AuctionPrice      *big.Int `json:",omitempty"` // limit price of the collateral sold in the auction round
+	AuctionSold       *big.Int `json:",omitempty"` // collateral sold to the bids of the order book
+	AuctionProceeds   *big.Int `json:",omitempty"` // lending tokens received for the sold collateral
 }
 
 var (
@@ -115,6 +134,7 @@ type LendingTradeHistoryItem struct {
 	TxHash                 common.Hash
 	CollateralLockedAmount *big.Int
 	LiquidationPrice       *big.Int
+	LiquidationTime        uint64
 	Status                 string
 	UpdatedAt              time.Time
 }
@@ -249,3 +269,10 @@ func DecodeFinalizedResult(data []byte) (result FinalizedResult, err error) {
 func GetLendingCacheKey(item *LendingItem) common.Hash {
 	return crypto.Keccak256Hash(item.UserAddress.Bytes(), item.Nonce.Bytes())
 }
+
+// userIndexKey returns the key of a lending item or trade in the index of its
+// user, the entries of a user are grouped by kind then lending book.
+func userIndexKey(kind byte, lendingBook common.Hash, id common.Hash) []byte {
+	key := append([]byte{kind}, lendingBook.Bytes()...)
+	return append(key, id.Bytes()...)
+}
@@ -37,6 +37,9 @@ type DumpOrderBookInfo struct {
 	BestInvesting         *big.Int
 	BestBorrowing         *big.Int
 	LowestLiquidationTime *big.Int
+	InterestIndex         *big.Int `json:",omitempty"`
+	InterestRate          uint64   `json:",omitempty"`
+	AccrualTime           uint64   `json:",omitempty"`
 }
 
 func (self *LendingStateDB) DumpInvestingTrie(orderBook common.Hash) (map[*big.Int]DumpOrderList, error) {
@@ -257,6 +260,11 @@ func (self *LendingStateDB) DumpOrderBookInfo(orderBook common.Hash) (*DumpOrder
 	result.BestBorrowing = new(big.Int).SetBytes(exhangeObject.getBestBorrowingInterest(self.db).Bytes())
 	lowestLiquidationTime, _ := exhangeObject.getLowestLiquidationTime(self.db)
 	result.LowestLiquidationTime = new(big.Int).SetBytes(lowestLiquidationTime.Bytes())
+	if exhangeObject.data.InterestIndex != nil {
+		result.InterestIndex = exhangeObject.data.InterestIndex
+		result.InterestRate = exhangeObject.data.InterestRate
+		result.AccrualTime = exhangeObject.data.AccrualTime
+	}
 	return result, nil
 }
 
@@ -415,3 +423,68 @@ func (self *LendingStateDB) DumpLendingTradeTrie(orderBook common.Hash) (map[*bi
 	}
 	return result, nil
 }
+
+// userIndexEntries returns the ids of the lending items or trades indexed for
+// a user, by lending book.
+func (self *LendingStateDB) userIndexEntries(user common.Address, kind byte) (map[common.Hash][]common.Hash, error) {
+	result := map[common.Hash][]common.Hash{}
+	stateObject := self.getLendingExchange(user.Hash())
+	if stateObject == nil {
+		return result, nil
+	}
+	it := trie.NewIterator(stateObject.getUserIndexTrie(self.db).NodeIterator([]byte{kind}))
+	for it.Next() {
+		if len(it.Key) != 1+2*common.HashLength {
+			continue
+		}
+		if it.Key[0] != kind {
+			break
+		}
+		lendingBook := common.BytesToHash(it.Key[1 : 1+common.HashLength])
+		result[lendingBook] = append(result[lendingBook], common.BytesToHash(it.Key[1+common.HashLength:]))
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return result, nil
+}
+
+// GetUserLendingItems returns the open lending items of a user by lending
+// book.
+func (self *LendingStateDB) GetUserLendingItems(user common.Address) (map[common.Hash][]LendingItem, error) {
+	entries, err := self.userIndexEntries(user, userLendingItem)
+	if err != nil {
+		return nil, err
+	}
+	result := map[common.Hash][]LendingItem{}
+	for lendingBook, ids := range entries {
+		for _, id := range ids {
+			item := self.GetLendingOrder(lendingBook, id)
+			if item.Quantity == nil || item.Quantity.Sign() <= 0 {
+				return nil, fmt.Errorf("indexed lending item not found lendingBook : %v , orderId : %v ", lendingBook.Hex(), id.Hex())
+			}
+			result[lendingBook] = append(result[lendingBook], item)
+		}
+	}
+	return result, nil
+}
+
+// GetUserLendingTrades returns the active lending trades of a user, as
+// borrower or investor, by lending book.
+func (self *LendingStateDB) GetUserLendingTrades(user common.Address) (map[common.Hash][]LendingTrade, error) {
+	entries, err := self.userIndexEntries(user, userLendingTrade)
+	if err != nil {
+		return nil, err
+	}
+	result := map[common.Hash][]LendingTrade{}
+	for lendingBook, ids := range entries {
+		for _, id := range ids {
+			trade := self.GetLendingTrade(lendingBook, id)
+			if trade.Amount == nil || trade.Amount.Sign() <= 0 {
+				return nil, fmt.Errorf("indexed lending trade not found lendingBook : %v , tradeId : %v ", lendingBook.Hex(), id.Hex())
+			}
+			result[lendingBook] = append(result[lendingBook], trade)
+		}
+	}
+	return result, nil
+}
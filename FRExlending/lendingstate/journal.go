@@ -63,6 +63,12 @@ type (
 		hash common.Hash
 		prev uint64
 	}
+	interestIndexChange struct {
+		hash        common.Hash
+		prevIndex   *big.Int
+		prevRate    uint64
+		prevAccrual uint64
+	}
 	liquidationPriceChange struct {
 		orderBook common.Hash
 		tradeId   common.Hash
@@ -73,6 +79,29 @@ type (
 		tradeId   common.Hash
 		prev      *big.Int
 	}
+	lendingTradeAmountChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      *big.Int
+	}
+	liquidationTimeChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      uint64
+	}
+	auctionChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prevRound uint64
+		prevBlock uint64
+	}
+	userIndexChange struct {
+		user      common.Address
+		kind      byte
+		orderBook common.Hash
+		id        common.Hash
+		prev      bool
+	}
 )
 
 func (ch insertOrder) undo(s *LendingStateDB) {
@@ -106,6 +135,10 @@ func (ch nonceChange) undo(s *LendingStateDB) {
 func (ch tradeNonceChange) undo(s *LendingStateDB) {
 	s.SetTradeNonce(ch.hash, ch.prev)
 }
+
+func (ch interestIndexChange) undo(s *LendingStateDB) {
+	s.SetInterestIndex(ch.hash, ch.prevIndex, ch.prevRate, ch.prevAccrual)
+}
 func (ch cancelTrading) undo(s *LendingStateDB) {
 	s.InsertTradingItem(ch.orderBook, ch.tradeId, ch.order)
 }
@@ -136,3 +169,45 @@ func (ch collateralLockedAmount) undo(s *LendingStateDB) {
 	}
 	stateLendingTrade.SetCollateralLockedAmount(ch.prev)
 }
+
+func (ch lendingTradeAmountChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetAmount(ch.prev)
+}
+
+func (ch liquidationTimeChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetLiquidationTime(ch.prev)
+}
+
+func (ch auctionChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetAuction(ch.prevRound, ch.prevBlock)
+}
+
+func (ch userIndexChange) undo(s *LendingStateDB) {
+	if stateObject := s.getLendingExchange(ch.user.Hash()); stateObject != nil {
+		stateObject.setUserEntry(s.db, ch.kind, ch.orderBook, ch.id, ch.prev)
+	}
+}
@@ -118,6 +118,7 @@ func GetTerms(statedb *state.StateDB, coinbase common.Address) []uint64 {
 // @param baseToken: address of baseToken
 // @param terms: term
 // @return: TRUE if the given baseToken, term organize a valid pair
+//		- the open-term book of a baseToken is valid for the relayers listing the baseToken
 func IsValidPair(statedb *state.StateDB, coinbase common.Address, baseToken common.Address, term uint64) (valid bool, pairIndex uint64) {
 	baseTokenList := GetBaseList(statedb, coinbase)
 	terms := GetTerms(statedb, coinbase)
@@ -127,6 +128,9 @@ func IsValidPair(statedb *state.StateDB, coinbase common.Address, baseToken comm
 			baseIndexes = append(baseIndexes, i)
 		}
 	}
+	if term == OpenTerm && len(baseIndexes) > 0 {
+		return true, baseIndexes[0]
+	}
 	for _, index := range baseIndexes {
 		if terms[index] == term {
 			pairIndex = index
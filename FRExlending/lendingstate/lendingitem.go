@@ -26,6 +26,10 @@ const (
 	LendingStatusCancelled     = "CANCELLED"
 	Market                     = "MO"
 	Limit                      = "LO"
+
+	// OpenTerm is the term of the variable-rate lending book of a lending
+	// token, whose trades stay open until repaid or recalled.
+	OpenTerm = uint64(0)
 )
 
 var ValidInputLendingStatus = map[string]bool{
@@ -205,7 +209,7 @@ func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
 		if err := l.VerifyLendingType(); err != nil {
 			return err
 		}
-		if l.Type != Repay {
+		if l.Type != Repay && l.Type != Recall {
 			if err := l.VerifyLendingQuantity(); err != nil {
 				return err
 			}
@@ -371,7 +375,7 @@ func VerifyBalance(isFREXLendingFork bool, statedb *state.StateDB, lendingStateD
 			return fmt.Errorf("VerifyBalance: process payment for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
 		}
 		tokenBalance := GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
-		paymentBalance := CalculateTotalRepayValue(uint64(time.Now().Unix()), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		paymentBalance := lendingStateDb.CalculateRepayValue(lendingBook, &lendingTrade, uint64(time.Now().Unix()), lendingTrade.Amount)
 
 		if tokenBalance.Cmp(paymentBalance) < 0 {
 			return fmt.Errorf("VerifyBalance: not enough balance to process payment for lendingTrade."+
@@ -254,3 +254,41 @@ func CalculateTotalRepayValue(finalizeTime, liquidationTime, term uint64, apr ui
 	paymentBalance = new(big.Int).Div(paymentBalance, baseInterestDecimal)
 	return paymentBalance
 }
+
+// AccrueInterestIndex returns the interest index grown at the given yearly
+// rate, in common.BaseLendingInterest percents, between two times.
+func AccrueInterestIndex(index *big.Int, rate uint64, from, to uint64) *big.Int {
+	if to <= from || rate == 0 {
+		return new(big.Int).Set(index)
+	}
+	interest := new(big.Int).Mul(index, new(big.Int).SetUint64(rate))
+	interest = new(big.Int).Mul(interest, new(big.Int).SetUint64(to-from))
+	baseInterestDecimal := new(big.Int).Mul(common.BaseLendingInterest, new(big.Int).SetUint64(100))
+	interest = new(big.Int).Div(interest, new(big.Int).Mul(baseInterestDecimal, new(big.Int).SetUint64(common.OneYear)))
+	return new(big.Int).Add(index, interest)
+}
+
+// CalculateOpenTermRepayValue returns the amount of an open-term trade grown by
+// the interest index of its lending book since the trade was opened.
+func CalculateOpenTermRepayValue(interestIndex, tradeInterestIndex *big.Int, tradeAmount *big.Int) *big.Int {
+	if tradeInterestIndex == nil || tradeInterestIndex.Sign() <= 0 {
+		return new(big.Int).Set(tradeAmount)
+	}
+	paymentBalance := new(big.Int).Mul(tradeAmount, interestIndex)
+	return paymentBalance.Div(paymentBalance, tradeInterestIndex)
+}
+
+// CalculateRepaidValue returns the value paid to close a repaid trade. The
+// interest of an open-term trade is the profit recorded in its extra data.
+func CalculateRepaidValue(finalizeTime uint64, trade *LendingTrade) *big.Int {
+	if trade.Term != OpenTerm {
+		return CalculateTotalRepayValue(finalizeTime, trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+	}
+	var extraData struct {
+		Profit *big.Int
+	}
+	if err := json.Unmarshal([]byte(trade.ExtraData), &extraData); err != nil || extraData.Profit == nil {
+		return new(big.Int).Set(trade.Amount)
+	}
+	return new(big.Int).Add(trade.Amount, extraData.Profit)
+}
@@ -459,3 +459,26 @@ func TestCalculateTotalRepayValue(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateOpenTermRepayValue(t *testing.T) {
+	tradeAmount := new(big.Int).Mul(big.NewInt(1000), common.BasePrice)
+	// 10% a year for half a year then 20% a year for the other half
+	index := AccrueInterestIndex(common.BasePrice, 10*1e8, 0, common.OneYear/2)
+	tradeIndex := new(big.Int).Set(index)
+	index = AccrueInterestIndex(index, 20*1e8, common.OneYear/2, common.OneYear)
+
+	// opened at the start: 1000 * 1.05 * 1.1 = 1155
+	want, _ := new(big.Int).SetString("1155000000000000000000", 10)
+	if got := CalculateOpenTermRepayValue(index, common.BasePrice, tradeAmount); got.Cmp(want) != 0 {
+		t.Errorf("repay value mismatch: have %v, want %v", got, want)
+	}
+	// opened after half a year: 1000 * 1.1 = 1100
+	want, _ = new(big.Int).SetString("1100000000000000000000", 10)
+	if got := CalculateOpenTermRepayValue(index, tradeIndex, tradeAmount); got.Cmp(want) != 0 {
+		t.Errorf("repay value mismatch: have %v, want %v", got, want)
+	}
+	// no time elapsed
+	if got := AccrueInterestIndex(index, 20*1e8, common.OneYear, common.OneYear); got.Cmp(index) != 0 {
+		t.Errorf("index accrued without time: have %v, want %v", got, index)
+	}
+}
@@ -42,6 +42,7 @@ type lendingExchangeState struct {
 	lendingItemTrie     Trie
 	lendingTradeTrie    Trie
 	liquidationTimeTrie Trie
+	userIndexTrie       Trie // kind + lending book + id -> id of the lending items and trades of a user
 
 	liquidationTimeStates      map[common.Hash]*liquidationTimeState
 	liquidationTimestatesDirty map[common.Hash]struct{}
@@ -84,6 +85,12 @@ func (s *lendingExchangeState) empty() bool {
 	if !common.EmptyHash(s.data.LiquidationTimeRoot) {
 		return false
 	}
+	if s.data.InterestIndex != nil {
+		return false
+	}
+	if !common.EmptyHash(s.data.UserIndexRoot) {
+		return false
+	}
 	return true
 }
 
@@ -605,6 +612,9 @@ func (self *lendingExchangeState) deepCopy(db *LendingStateDB, onDirty func(hash
 	if self.lendingItemTrie != nil {
 		stateExchanges.lendingItemTrie = db.db.CopyTrie(self.lendingItemTrie)
 	}
+	if self.userIndexTrie != nil {
+		stateExchanges.userIndexTrie = db.db.CopyTrie(self.userIndexTrie)
+	}
 	for key, value := range self.borrowingStates {
 		stateExchanges.borrowingStates[key] = value.deepCopy(db, self.MarkBorrowingDirty)
 	}
@@ -666,6 +676,16 @@ func (self *lendingExchangeState) TradeNonce() uint64 {
 	return self.data.TradeNonce
 }
 
+func (self *lendingExchangeState) setInterestIndex(index *big.Int, rate uint64, accrualTime uint64) {
+	self.data.InterestIndex = index
+	self.data.InterestRate = rate
+	self.data.AccrualTime = accrualTime
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
 func (self *lendingExchangeState) removeInvestingOrderList(db Database, stateOrderList *itemListState) {
 	self.setError(self.investingTrie.TryDelete(stateOrderList.key[:]))
 }
@@ -784,3 +804,69 @@ func (self *lendingExchangeState) insertLendingTrade(tradeId common.Hash, order
 	}
 	return newobj
 }
+
+func (self *lendingExchangeState) getUserIndexTrie(db Database) Trie {
+	if self.userIndexTrie == nil {
+		var err error
+		self.userIndexTrie, err = db.OpenStorageTrie(self.lendingBook, self.data.UserIndexRoot)
+		if err != nil {
+			self.userIndexTrie, _ = db.OpenStorageTrie(self.lendingBook, EmptyHash)
+			self.setError(fmt.Errorf("can't create user index trie: %v", err))
+		}
+	}
+	return self.userIndexTrie
+}
+
+// hasUserEntry reports whether the lending item or trade is indexed for the
+// user owning this object.
+func (self *lendingExchangeState) hasUserEntry(db Database, kind byte, lendingBook common.Hash, id common.Hash) bool {
+	value, err := self.getUserIndexTrie(db).TryGet(userIndexKey(kind, lendingBook, id))
+	if err != nil {
+		self.setError(err)
+		return false
+	}
+	return len(value) > 0
+}
+
+func (self *lendingExchangeState) setUserEntry(db Database, kind byte, lendingBook common.Hash, id common.Hash, open bool) {
+	key := userIndexKey(kind, lendingBook, id)
+	if open {
+		self.setError(self.getUserIndexTrie(db).TryUpdate(key, id[:]))
+	} else {
+		self.setError(self.getUserIndexTrie(db).TryDelete(key))
+	}
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// updateUserIndexRoot sets the root of the user index trie, it is left zero
+// as long as nothing was indexed for the user so that the encoding of the
+// object doesn't change.
+func (self *lendingExchangeState) updateUserIndexRoot(db Database) {
+	if self.userIndexTrie == nil {
+		return
+	}
+	self.data.UserIndexRoot = self.userIndexTrie.Hash()
+	if self.data.UserIndexRoot == EmptyRoot {
+		self.data.UserIndexRoot = EmptyHash
+	}
+}
+
+func (self *lendingExchangeState) CommitUserIndexTrie(db Database) error {
+	if self.userIndexTrie == nil {
+		return nil
+	}
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	root, err := self.userIndexTrie.Commit(nil)
+	if err == nil {
+		if root == EmptyRoot {
+			root = EmptyHash
+		}
+		self.data.UserIndexRoot = root
+	}
+	return err
+}
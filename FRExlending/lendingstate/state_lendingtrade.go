@@ -76,3 +76,20 @@ func (self *lendingTradeState) SetAmount(amount *big.Int) {
 		self.onDirty = nil
 	}
 }
+
+func (self *lendingTradeState) SetLiquidationTime(time uint64) {
+	self.data.LiquidationTime = time
+	if self.onDirty != nil {
+		self.onDirty(self.tradeId)
+		self.onDirty = nil
+	}
+}
+
+func (self *lendingTradeState) SetAuction(round uint64, block uint64) {
+	self.data.AuctionRound = round
+	self.data.AuctionBlock = block
+	if self.onDirty != nil {
+		self.onDirty(self.tradeId)
+		self.onDirty = nil
+	}
+}
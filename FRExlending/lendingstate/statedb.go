@@ -54,6 +54,11 @@ type LendingStateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// userIndex enables the index of the lending items and trades of every
+	// user, it is switched on by the order processor once the index is part
+	// of consensus.
+	userIndex bool
+
 	lock sync.Mutex
 }
 
@@ -112,6 +117,25 @@ func (self *LendingStateDB) GetTradeNonce(addr common.Hash) uint64 {
 	return 0
 }
 
+// GetInterestIndex returns the interest index of an open-term lending book
+// accrued until the given time at the current variable rate of the book.
+func (self *LendingStateDB) GetInterestIndex(addr common.Hash, time uint64) *big.Int {
+	stateObject := self.getLendingExchange(addr)
+	if stateObject == nil || stateObject.data.InterestIndex == nil {
+		return new(big.Int).Set(common.BasePrice)
+	}
+	return AccrueInterestIndex(stateObject.data.InterestIndex, stateObject.data.InterestRate, stateObject.data.AccrualTime, time)
+}
+
+// GetInterestRate returns the variable rate of an open-term lending book.
+func (self *LendingStateDB) GetInterestRate(addr common.Hash) uint64 {
+	stateObject := self.getLendingExchange(addr)
+	if stateObject != nil {
+		return stateObject.data.InterestRate
+	}
+	return 0
+}
+
 // Database retrieves the low level database supporting the lower level trie ops.
 func (self *LendingStateDB) Database() Database {
 	return self.db
@@ -139,6 +163,28 @@ func (self *LendingStateDB) SetTradeNonce(addr common.Hash, nonce uint64) {
 	}
 }
 
+// CalculateRepayValue returns an amount lent in a trade grown by the interest
+// of the trade at the given time.
+func (self *LendingStateDB) CalculateRepayValue(lendingBook common.Hash, trade *LendingTrade, time uint64, amount *big.Int) *big.Int {
+	if trade.Term == OpenTerm {
+		return CalculateOpenTermRepayValue(self.GetInterestIndex(lendingBook, time), trade.InterestIndex, amount)
+	}
+	return CalculateTotalRepayValue(time, trade.LiquidationTime, trade.Term, trade.Interest, amount)
+}
+
+func (self *LendingStateDB) SetInterestIndex(addr common.Hash, index *big.Int, rate uint64, accrualTime uint64) {
+	stateObject := self.GetOrNewLendingExchangeObject(addr)
+	if stateObject != nil {
+		self.journal = append(self.journal, interestIndexChange{
+			hash:        addr,
+			prevIndex:   stateObject.data.InterestIndex,
+			prevRate:    stateObject.data.InterestRate,
+			prevAccrual: stateObject.data.AccrualTime,
+		})
+		stateObject.setInterestIndex(index, rate, accrualTime)
+	}
+}
+
 func (self *LendingStateDB) InsertLendingItem(orderBook common.Hash, orderId common.Hash, order LendingItem) {
 	interestHash := common.BigToHash(order.Interest)
 	stateExchange := self.getLendingExchange(orderBook)
@@ -168,6 +214,7 @@ func (self *LendingStateDB) InsertLendingItem(orderBook common.Hash, orderId com
 	stateExchange.createLendingItem(self.db, orderId, order)
 	stateOrderList.insertLendingItem(self.db, orderId, common.BigToHash(order.Quantity))
 	stateOrderList.AddVolume(order.Quantity)
+	self.setUserEntry(order.UserAddress, userLendingItem, orderBook, orderId, true)
 }
 
 func (self *LendingStateDB) InsertTradingItem(orderBook common.Hash, tradeId uint64, order LendingTrade) {
@@ -183,6 +230,13 @@ func (self *LendingStateDB) InsertTradingItem(orderBook common.Hash, tradeId uin
 		prvTrade:  &prvTrade,
 	})
 	stateExchange.insertLendingTrade(tradeIdHash, order)
+	open := order.Amount != nil && order.Amount.Sign() > 0
+	if order.Borrower != (common.Address{}) {
+		self.setUserEntry(order.Borrower, userLendingTrade, orderBook, tradeIdHash, open)
+	}
+	if order.Investor != (common.Address{}) {
+		self.setUserEntry(order.Investor, userLendingTrade, orderBook, tradeIdHash, open)
+	}
 }
 
 func (self *LendingStateDB) UpdateLiquidationPrice(orderBook common.Hash, tradeId uint64, price *big.Int) {
@@ -213,6 +267,52 @@ func (self *LendingStateDB) UpdateCollateralLockedAmount(orderBook common.Hash,
 	})
 	stateLendingTrade.SetCollateralLockedAmount(amount)
 }
+func (self *LendingStateDB) UpdateLendingTradeAmount(orderBook common.Hash, tradeId uint64, amount *big.Int) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, lendingTradeAmountChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.Amount,
+	})
+	stateLendingTrade.SetAmount(amount)
+}
+func (self *LendingStateDB) UpdateLiquidationTime(orderBook common.Hash, tradeId uint64, time uint64) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, liquidationTimeChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.LiquidationTime,
+	})
+	stateLendingTrade.SetLiquidationTime(time)
+}
+
+// UpdateLendingTradeAuction records the round and the block of the running
+// liquidation auction of a trade, a zero round ends the auction.
+func (self *LendingStateDB) UpdateLendingTradeAuction(orderBook common.Hash, tradeId uint64, round uint64, block uint64) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, auctionChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prevRound: stateLendingTrade.data.AuctionRound,
+		prevBlock: stateLendingTrade.data.AuctionBlock,
+	})
+	stateLendingTrade.SetAuction(round, block)
+}
 func (self *LendingStateDB) GetLendingOrder(orderBook common.Hash, orderId common.Hash) LendingItem {
 	stateObject := self.GetOrNewLendingExchangeObject(orderBook)
 	if stateObject == nil {
@@ -275,6 +375,7 @@ func (self *LendingStateDB) SubAmountLendingItem(orderBook common.Hash, orderId
 	orderList.subVolume(amount)
 	if newAmount.Sign() == 0 {
 		orderList.removeOrderItem(self.db, orderId)
+		self.setUserEntry(lendingItem.data.UserAddress, userLendingItem, orderBook, orderId, false)
 	} else {
 		orderList.setOrderItem(orderId, common.BigToHash(newAmount))
 	}
@@ -325,6 +426,7 @@ func (self *LendingStateDB) CancelLendingOrder(orderBook common.Hash, order *Len
 	currentAmount := new(big.Int).SetBytes(orderList.GetOrderAmount(self.db, orderIdHash).Bytes()[:])
 	orderList.subVolume(currentAmount)
 	orderList.removeOrderItem(self.db, orderIdHash)
+	self.setUserEntry(lendingItem.data.UserAddress, userLendingItem, orderBook, orderIdHash, false)
 	if orderList.empty() {
 		switch order.Side {
 		case Investing:
@@ -471,6 +573,7 @@ func (self *LendingStateDB) Copy() *LendingStateDB {
 		trie:                       self.db.CopyTrie(self.trie),
 		lendingExchangeStates:      make(map[common.Hash]*lendingExchangeState, len(self.lendingExchangeStatesDirty)),
 		lendingExchangeStatesDirty: make(map[common.Hash]struct{}, len(self.lendingExchangeStatesDirty)),
+		userIndex:                  self.userIndex,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.lendingExchangeStatesDirty {
@@ -529,6 +632,7 @@ func (s *LendingStateDB) Finalise() {
 			stateObject.updateOrderRoot(s.db)
 			stateObject.updateLendingTradeRoot(s.db)
 			stateObject.updateLiquidationTimeRoot(s.db)
+			stateObject.updateUserIndexRoot(s.db)
 			// Update the object in the main tradeId trie.
 			s.updateLendingExchange(stateObject)
 			//delete(s.investingStatesDirty, addr)
@@ -567,6 +671,9 @@ func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 			if err := stateObject.CommitLiquidationTimeTrie(s.db); err != nil {
 				return EmptyHash, err
 			}
+			if err := stateObject.CommitUserIndexTrie(s.db); err != nil {
+				return EmptyHash, err
+			}
 			// Update the object in the main tradeId trie.
 			s.updateLendingExchange(stateObject)
 			delete(s.lendingExchangeStatesDirty, addr)
@@ -593,6 +700,9 @@ func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 		if exchange.LiquidationTimeRoot != EmptyRoot {
 			s.db.TrieDB().Reference(exchange.LiquidationTimeRoot, parent)
 		}
+		if exchange.UserIndexRoot != EmptyRoot && exchange.UserIndexRoot != EmptyHash {
+			s.db.TrieDB().Reference(exchange.UserIndexRoot, parent)
+		}
 		return nil
 	})
 	log.Debug("Lending State Trie cache stats after commit", "root", root.Hex())
@@ -661,8 +771,41 @@ func (self *LendingStateDB) CancelLendingTrade(orderBook common.Hash, tradeId ui
 	}
 	self.journal = append(self.journal, cancelTrading{
 		orderBook: orderBook,
+		tradeId:   tradeId,
 		order:     self.GetLendingTrade(orderBook, tradeIdHash),
 	})
 	lendingTrade.SetAmount(Zero)
+	self.setUserEntry(lendingTrade.data.Borrower, userLendingTrade, orderBook, tradeIdHash, false)
+	self.setUserEntry(lendingTrade.data.Investor, userLendingTrade, orderBook, tradeIdHash, false)
 	return nil
 }
+
+// SetUserIndex switches the index of the lending items and trades of every
+// user on or off.
+func (self *LendingStateDB) SetUserIndex(enabled bool) {
+	self.userIndex = enabled
+}
+
+// setUserEntry adds a lending item or trade to or removes it from the index
+// of a user if the user index is enabled.
+func (self *LendingStateDB) setUserEntry(user common.Address, kind byte, orderBook common.Hash, id common.Hash, open bool) {
+	if !self.userIndex {
+		return
+	}
+	stateObject := self.GetOrNewLendingExchangeObject(user.Hash())
+	if stateObject == nil {
+		return
+	}
+	prev := stateObject.hasUserEntry(self.db, kind, orderBook, id)
+	if prev == open {
+		return
+	}
+	self.journal = append(self.journal, userIndexChange{
+		user:      user,
+		kind:      kind,
+		orderBook: orderBook,
+		id:        id,
+		prev:      prev,
+	})
+	stateObject.setUserEntry(self.db, kind, orderBook, id, open)
+}
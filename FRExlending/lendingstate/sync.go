@@ -0,0 +1,44 @@
+package lendingstate
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/rlp"
+	"github.com/FRECNET/trie"
+)
+
+// NewStateSync creates a new lending state trie download scheduler. The
+// lending books of the trie are followed into their investing, borrowing,
+// liquidation time, lending item, lending trade and user index tries, the item
+// lists of which are followed into the tries of their ids.
+func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+	addSubTrie := func(root common.Hash, depth int, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyRoot && root != EmptyHash {
+			syncer.AddSubTrie(root, depth, parent, callback)
+		}
+	}
+	itemLists := func(leaf []byte, parent common.Hash) error {
+		var list itemList
+		if err := rlp.DecodeBytes(leaf, &list); err != nil {
+			return err
+		}
+		addSubTrie(list.Root, 128, parent, nil)
+		return nil
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		var lendingBook lendingObject
+		if err := rlp.DecodeBytes(leaf, &lendingBook); err != nil {
+			return err
+		}
+		addSubTrie(lendingBook.InvestingRoot, 64, parent, itemLists)
+		addSubTrie(lendingBook.BorrowingRoot, 64, parent, itemLists)
+		addSubTrie(lendingBook.LiquidationTimeRoot, 64, parent, itemLists)
+		addSubTrie(lendingBook.LendingItemRoot, 64, parent, nil)
+		addSubTrie(lendingBook.LendingTradeRoot, 64, parent, nil)
+		addSubTrie(lendingBook.UserIndexRoot, 64, parent, nil)
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
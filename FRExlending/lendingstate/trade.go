@@ -50,6 +50,9 @@ type LendingTrade struct {
 	ExtraData              string         `bson:"extraData" json:"extraData"`
 	CreatedAt              time.Time      `bson:"createdAt" json:"createdAt"`
 	UpdatedAt              time.Time      `bson:"updatedAt" json:"updatedAt"`
+	InterestIndex          *big.Int       `bson:"interestIndex" json:"interestIndex,omitempty" rlp:"optional"` // interest index of the lending book when an open-term trade was opened
+	AuctionRound           uint64         `bson:"auctionRound" json:"auctionRound,omitempty" rlp:"optional"`   // rounds of the running liquidation auction of the collateral
+	AuctionBlock           uint64         `bson:"auctionBlock" json:"auctionBlock,omitempty" rlp:"optional"`   // block of the last round of the running liquidation auction
 }
 
 type LendingTradeBSON struct {
@@ -83,45 +86,56 @@ type LendingTradeBSON struct {
 	TxHash                 string    `bson:"txHash" json:"txHash"`
 	ExtraData              string    `bson:"extraData" json:"extraData"`
 	UpdatedAt              time.Time `bson:"updatedAt" json:"updatedAt"`
+	InterestIndex          string    `bson:"interestIndex,omitempty" json:"interestIndex,omitempty"`
+	AuctionRound           string    `bson:"auctionRound,omitempty" json:"auctionRound,omitempty"`
+	AuctionBlock           string    `bson:"auctionBlock,omitempty" json:"auctionBlock,omitempty"`
 }
 
 func (t *LendingTrade) GetBSON() (interface{}, error) {
+	tr := LendingTradeBSON{
+		Borrower:               t.Borrower.Hex(),
+		Investor:               t.Investor.Hex(),
+		LendingToken:           t.LendingToken.Hex(),
+		CollateralToken:        t.CollateralToken.Hex(),
+		BorrowingOrderHash:     t.BorrowingOrderHash.Hex(),
+		InvestingOrderHash:     t.InvestingOrderHash.Hex(),
+		BorrowingRelayer:       t.BorrowingRelayer.Hex(),
+		InvestingRelayer:       t.InvestingRelayer.Hex(),
+		Term:                   strconv.FormatUint(t.Term, 10),
+		Interest:               strconv.FormatUint(t.Interest, 10),
+		CollateralPrice:        t.CollateralPrice.String(),
+		LiquidationPrice:       t.LiquidationPrice.String(),
+		LiquidationTime:        strconv.FormatUint(t.LiquidationTime, 10),
+		CollateralLockedAmount: t.CollateralLockedAmount.String(),
+		AutoTopUp:              t.AutoTopUp,
+		DepositRate:            t.DepositRate.String(),
+		LiquidationRate:        t.LiquidationRate.String(),
+		RecallRate:             t.RecallRate.String(),
+		Amount:                 t.Amount.String(),
+		BorrowingFee:           t.BorrowingFee.String(),
+		InvestingFee:           t.InvestingFee.String(),
+		Status:                 t.Status,
+		TakerOrderSide:         t.TakerOrderSide,
+		TakerOrderType:         t.TakerOrderType,
+		MakerOrderType:         t.MakerOrderType,
+		TradeId:                strconv.FormatUint(t.TradeId, 10),
+		Hash:                   t.Hash.Hex(),
+		TxHash:                 t.TxHash.Hex(),
+		ExtraData:              t.ExtraData,
+		UpdatedAt:              t.UpdatedAt,
+	}
+	if t.InterestIndex != nil {
+		tr.InterestIndex = t.InterestIndex.String()
+	}
+	if t.AuctionRound != 0 {
+		tr.AuctionRound = strconv.FormatUint(t.AuctionRound, 10)
+		tr.AuctionBlock = strconv.FormatUint(t.AuctionBlock, 10)
+	}
 	return bson.M{
 		"$setOnInsert": bson.M{
 			"createdAt": t.CreatedAt,
 		},
-		"$set": LendingTradeBSON{
-			Borrower:               t.Borrower.Hex(),
-			Investor:               t.Investor.Hex(),
-			LendingToken:           t.LendingToken.Hex(),
-			CollateralToken:        t.CollateralToken.Hex(),
-			BorrowingOrderHash:     t.BorrowingOrderHash.Hex(),
-			InvestingOrderHash:     t.InvestingOrderHash.Hex(),
-			BorrowingRelayer:       t.BorrowingRelayer.Hex(),
-			InvestingRelayer:       t.InvestingRelayer.Hex(),
-			Term:                   strconv.FormatUint(t.Term, 10),
-			Interest:               strconv.FormatUint(t.Interest, 10),
-			CollateralPrice:        t.CollateralPrice.String(),
-			LiquidationPrice:       t.LiquidationPrice.String(),
-			LiquidationTime:        strconv.FormatUint(t.LiquidationTime, 10),
-			CollateralLockedAmount: t.CollateralLockedAmount.String(),
-			AutoTopUp:              t.AutoTopUp,
-			DepositRate:            t.DepositRate.String(),
-			LiquidationRate:        t.LiquidationRate.String(),
-			RecallRate:             t.RecallRate.String(),
-			Amount:                 t.Amount.String(),
-			BorrowingFee:           t.BorrowingFee.String(),
-			InvestingFee:           t.InvestingFee.String(),
-			Status:                 t.Status,
-			TakerOrderSide:         t.TakerOrderSide,
-			TakerOrderType:         t.TakerOrderType,
-			MakerOrderType:         t.MakerOrderType,
-			TradeId:                strconv.FormatUint(t.TradeId, 10),
-			Hash:                   t.Hash.Hex(),
-			TxHash:                 t.TxHash.Hex(),
-			ExtraData:              t.ExtraData,
-			UpdatedAt:              t.UpdatedAt,
-		},
+		"$set": tr,
 	}, nil
 }
 
@@ -178,6 +192,17 @@ func (t *LendingTrade) SetBSON(raw bson.Raw) error {
 	t.Hash = common.HexToHash(decoded.Hash)
 	t.TxHash = common.HexToHash(decoded.TxHash)
 	t.UpdatedAt = decoded.UpdatedAt
+	if decoded.InterestIndex != "" {
+		t.InterestIndex = ToBigInt(decoded.InterestIndex)
+	}
+	if decoded.AuctionRound != "" {
+		if t.AuctionRound, err = strconv.ParseUint(decoded.AuctionRound, 10, 64); err != nil {
+			return fmt.Errorf("failed to parse lendingItem.AuctionRound. Err: %v", err)
+		}
+		if t.AuctionBlock, err = strconv.ParseUint(decoded.AuctionBlock, 10, 64); err != nil {
+			return fmt.Errorf("failed to parse lendingItem.AuctionBlock. Err: %v", err)
+		}
+	}
 
 	return nil
 }
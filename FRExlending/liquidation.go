@@ -0,0 +1,395 @@
+package FRExlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/log"
+	"github.com/FRECNET/params"
+)
+
+// auctionedTrade is a trade whose liquidation auction goes on at the next
+// liquidation round.
+type auctionedTrade struct {
+	lendingBook common.Hash
+	trade       *lendingstate.LendingTrade
+}
+
+// PartialLiquidationTrade liquidates the part of the collateral of a trade
+// needed to bring it back to its deposit rate at the given collateral price.
+// A penalty of LiquidationPenalty percent of the liquidated collateral, from
+// the lending parameters of the chain config, is split between the masternode
+// creating the block, which keeps the trades liquidated, and the owner of the
+// investing relayer. The rest goes to the investor and repays the debt at the
+// collateral price. A trade whose deposit rate can't be restored by a partial
+// liquidation is liquidated entirely.
+func (l *Lending) PartialLiquidationTrade(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, collateralPrice *big.Int) (*lendingstate.LendingTrade, *lendingstate.LiquidationData, error) {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade.TradeId != lendingTradeId {
+		return nil, nil, fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	liquidationData := &lendingstate.LiquidationData{
+		RecallAmount:    common.Big0,
+		CollateralPrice: collateralPrice,
+		Reason:          lendingstate.LiquidatedByPrice,
+	}
+	params := chain.Config().LendingParams()
+	penaltyRate := new(big.Int).SetUint64(params.LiquidationPenalty)
+	liquidatedAmount := partialLiquidationAmount(&lendingTrade, collateralPrice, penaltyRate)
+	if liquidatedAmount == nil {
+		newTrade, err := l.LiquidationTrade(lendingStateDB, statedb, tradingStateDB, lendingBook, lendingTradeId)
+		if err != nil {
+			return nil, nil, err
+		}
+		newTrade.Status = lendingstate.TradeStatusLiquidated
+		liquidationData.LiquidationAmount = newTrade.CollateralLockedAmount
+		return newTrade, liquidationData, nil
+	}
+	lockAddress := common.HexToAddress(common.LendingLockAddress)
+
+	// the penalty is paid in collateral
+	penalty := new(big.Int).Mul(liquidatedAmount, penaltyRate)
+	penalty.Div(penalty, big.NewInt(100))
+	keeperFee := payLiquidationPenalty(header, statedb, &lendingTrade, penalty, params.KeeperShare)
+	settleAmount := new(big.Int).Sub(liquidatedAmount, penalty)
+	repaidAmount := new(big.Int)
+	if settleAmount.Sign() > 0 {
+		lendingstate.SubTokenBalance(lockAddress, settleAmount, lendingTrade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Investor, settleAmount, lendingTrade.CollateralToken, statedb)
+		repaidAmount = collateralValue(&lendingTrade, settleAmount, collateralPrice)
+	}
+	liquidationData.LiquidationAmount = liquidatedAmount
+	liquidationData.RepaidAmount = repaidAmount
+	liquidationData.Penalty = penalty
+	liquidationData.KeeperFee = keeperFee
+
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err := tradingStateDB.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		log.Debug("PartialLiquidationTrade RemoveLiquidationPrice", "err", err)
+		return nil, nil, err
+	}
+	newLockedAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, liquidatedAmount)
+	newAmount := new(big.Int).Sub(lendingTrade.Amount, repaidAmount)
+	newTrade := lendingTrade
+	if newAmount.Sign() <= 0 {
+		// the liquidated collateral repaid the whole debt, the borrower gets
+		// the remaining collateral back
+		lendingstate.SubTokenBalance(lockAddress, newLockedAmount, lendingTrade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Borrower, newLockedAmount, lendingTrade.CollateralToken, statedb)
+		if err := removeLiquidationTime(lendingStateDB, lendingBook, &lendingTrade); err != nil {
+			log.Debug("PartialLiquidationTrade RemoveLiquidationTime", "err", err)
+			return nil, nil, err
+		}
+		if err := lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId); err != nil {
+			log.Debug("PartialLiquidationTrade CancelLendingTrade", "err", err)
+			return nil, nil, err
+		}
+		newTrade.Status = lendingstate.TradeStatusLiquidated
+		return &newTrade, liquidationData, nil
+	}
+	// the liquidation price is proportional to the debt per locked collateral
+	newLiquidationPrice := new(big.Int).Mul(lendingTrade.LiquidationPrice, lendingTrade.CollateralLockedAmount)
+	newLiquidationPrice.Mul(newLiquidationPrice, newAmount)
+	newLiquidationPrice.Div(newLiquidationPrice, new(big.Int).Mul(lendingTrade.Amount, newLockedAmount))
+
+	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTradeId, newLockedAmount)
+	lendingStateDB.UpdateLendingTradeAmount(lendingBook, lendingTradeId, newAmount)
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	tradingStateDB.InsertLiquidationPrice(orderbook, newLiquidationPrice, lendingBook, lendingTradeId)
+
+	newTrade.CollateralLockedAmount = newLockedAmount
+	newTrade.Amount = newAmount
+	newTrade.LiquidationPrice = newLiquidationPrice
+	liquidationData.Partial = true
+	log.Debug("PartialLiquidationTrade", "tradeId", lendingTradeId, "liquidated", liquidatedAmount, "penalty", penalty, "repaid", repaidAmount, "lockAmount", newLockedAmount, "amount", newAmount, "price", newLiquidationPrice)
+	return &newTrade, liquidationData, nil
+}
+
+// AuctionLiquidationTrade runs a round of the descending price auction of the
+// collateral of an unhealthy trade, used instead of PartialLiquidationTrade when
+// the Auction switch of the lending parameters is on. The collateral needed to
+// bring the trade back to its deposit rate, or all of it when a partial
+// liquidation can't, is sold to the bids of the collateral/lending order book
+// down to the collateral price less AuctionStepDiscount percent per round
+// already run, at most AuctionMaxDiscount percent. The proceeds repay the debt
+// to the investor, the excess goes to the borrower, and the penalty is charged
+// on the sold collateral as for a partial liquidation. A trade still unhealthy
+// after its round at the maximum discount is liquidated entirely. Otherwise the
+// auction goes on at the next liquidation round, the returned bool is then true
+// and the trade is left out of the liquidation price index, the caller inserts
+// it back at its new liquidation price once the pair is processed.
+func (l *Lending) AuctionLiquidationTrade(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, collateralPrice *big.Int) (*lendingstate.LendingTrade, *lendingstate.LiquidationData, bool, error) {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade.TradeId != lendingTradeId {
+		return nil, nil, false, fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	params := chain.Config().LendingParams()
+	epoch := uint64(0)
+	if chain.Config().S2PoS != nil {
+		epoch = chain.Config().S2PoS.Epoch
+	}
+	round := auctionRound(&lendingTrade, header.Number.Uint64(), epoch)
+	price, lastRound := auctionPrice(collateralPrice, round, params)
+	liquidationData := &lendingstate.LiquidationData{
+		RecallAmount:    common.Big0,
+		CollateralPrice: collateralPrice,
+		Reason:          lendingstate.LiquidatedByPrice,
+		AuctionRound:    round,
+		AuctionPrice:    price,
+	}
+	lockAddress := common.HexToAddress(common.LendingLockAddress)
+
+	penaltyRate := new(big.Int).SetUint64(params.LiquidationPenalty)
+	offered := partialLiquidationAmount(&lendingTrade, collateralPrice, penaltyRate)
+	if offered == nil {
+		offered = new(big.Int).Set(lendingTrade.CollateralLockedAmount)
+	}
+	penalty := new(big.Int).Mul(offered, penaltyRate)
+	penalty.Div(penalty, big.NewInt(100))
+	quantity := new(big.Int).Sub(offered, penalty)
+
+	sold, proceeds := l.auctionCollateral(header, chain, statedb, tradingStateDB, &lendingTrade, quantity, price)
+	liquidationData.AuctionSold = sold
+	liquidationData.AuctionProceeds = proceeds
+
+	// the penalty is charged on the sold collateral only
+	if quantity.Sign() > 0 {
+		penalty.Mul(penalty, sold)
+		penalty.Div(penalty, quantity)
+	}
+	keeperFee := payLiquidationPenalty(header, statedb, &lendingTrade, penalty, params.KeeperShare)
+	repaidAmount := new(big.Int).Set(proceeds)
+	if repaidAmount.Cmp(lendingTrade.Amount) > 0 {
+		repaidAmount.Set(lendingTrade.Amount)
+	}
+	if repaidAmount.Sign() > 0 {
+		lendingstate.SubTokenBalance(lockAddress, repaidAmount, lendingTrade.LendingToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Investor, repaidAmount, lendingTrade.LendingToken, statedb)
+	}
+	if excess := new(big.Int).Sub(proceeds, repaidAmount); excess.Sign() > 0 {
+		lendingstate.SubTokenBalance(lockAddress, excess, lendingTrade.LendingToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Borrower, excess, lendingTrade.LendingToken, statedb)
+	}
+	liquidationData.LiquidationAmount = new(big.Int).Add(sold, penalty)
+	liquidationData.RepaidAmount = repaidAmount
+	liquidationData.Penalty = penalty
+	liquidationData.KeeperFee = keeperFee
+
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err := tradingStateDB.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		log.Debug("AuctionLiquidationTrade RemoveLiquidationPrice", "err", err)
+		return nil, nil, false, err
+	}
+	newLockedAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, liquidationData.LiquidationAmount)
+	newAmount := new(big.Int).Sub(lendingTrade.Amount, repaidAmount)
+	newTrade := lendingTrade
+	if newAmount.Sign() <= 0 {
+		// the proceeds repaid the whole debt, the borrower gets the
+		// remaining collateral back
+		lendingstate.SubTokenBalance(lockAddress, newLockedAmount, lendingTrade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Borrower, newLockedAmount, lendingTrade.CollateralToken, statedb)
+		if err := removeLiquidationTime(lendingStateDB, lendingBook, &lendingTrade); err != nil {
+			log.Debug("AuctionLiquidationTrade RemoveLiquidationTime", "err", err)
+			return nil, nil, false, err
+		}
+		if err := lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId); err != nil {
+			log.Debug("AuctionLiquidationTrade CancelLendingTrade", "err", err)
+			return nil, nil, false, err
+		}
+		newTrade.Status = lendingstate.TradeStatusLiquidated
+		return &newTrade, liquidationData, false, nil
+	}
+	newLiquidationPrice := lendingTrade.LiquidationPrice
+	if sold.Sign() > 0 && newLockedAmount.Sign() > 0 {
+		// the liquidation price is proportional to the debt per locked collateral
+		newLiquidationPrice = new(big.Int).Mul(lendingTrade.LiquidationPrice, lendingTrade.CollateralLockedAmount)
+		newLiquidationPrice.Mul(newLiquidationPrice, newAmount)
+		newLiquidationPrice.Div(newLiquidationPrice, new(big.Int).Mul(lendingTrade.Amount, newLockedAmount))
+	}
+	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTradeId, newLockedAmount)
+	lendingStateDB.UpdateLendingTradeAmount(lendingBook, lendingTradeId, newAmount)
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	newTrade.CollateralLockedAmount = newLockedAmount
+	newTrade.Amount = newAmount
+	newTrade.LiquidationPrice = newLiquidationPrice
+
+	healthy := newLockedAmount.Sign() > 0 && collateralPrice.Cmp(newLiquidationPrice) >= 0
+	if healthy || lastRound || newLockedAmount.Sign() <= 0 {
+		tradingStateDB.InsertLiquidationPrice(orderbook, newLiquidationPrice, lendingBook, lendingTradeId)
+		if lendingTrade.AuctionRound != 0 {
+			lendingStateDB.UpdateLendingTradeAuction(lendingBook, lendingTradeId, 0, 0)
+			newTrade.AuctionRound, newTrade.AuctionBlock = 0, 0
+		}
+	}
+	if !healthy && (lastRound || newLockedAmount.Sign() <= 0) {
+		// the auction ended without restoring the deposit rate, the investor
+		// gets the remaining collateral
+		liquidatedTrade, err := l.LiquidationTrade(lendingStateDB, statedb, tradingStateDB, lendingBook, lendingTradeId)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		liquidatedTrade.Status = lendingstate.TradeStatusLiquidated
+		liquidationData.LiquidationAmount.Add(liquidationData.LiquidationAmount, liquidatedTrade.CollateralLockedAmount)
+		log.Debug("AuctionLiquidationTrade", "tradeId", lendingTradeId, "round", round, "price", price, "sold", sold, "proceeds", proceeds, "liquidated", liquidatedTrade.CollateralLockedAmount)
+		return liquidatedTrade, liquidationData, false, nil
+	}
+	liquidationData.Partial = true
+	running := !healthy
+	if running {
+		lendingStateDB.UpdateLendingTradeAuction(lendingBook, lendingTradeId, round+1, header.Number.Uint64())
+		newTrade.AuctionRound, newTrade.AuctionBlock = round+1, header.Number.Uint64()
+	}
+	log.Debug("AuctionLiquidationTrade", "tradeId", lendingTradeId, "round", round, "price", price, "sold", sold, "proceeds", proceeds, "penalty", penalty, "repaid", repaidAmount, "lockAmount", newLockedAmount, "amount", newAmount, "liquidationPrice", newLiquidationPrice, "running", running)
+	return &newTrade, liquidationData, running, nil
+}
+
+// auctionCollateral sells collateral locked for a trade to the bids of the
+// collateral/lending order book down to the given price and returns the
+// collateral sold and the lending tokens received for it, net of the trading
+// fees. Nothing is sold if the order book rejects the order.
+// Note: the SDK node doesn't record the matched orders of the auction.
+func (l *Lending) auctionCollateral(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, lendingTrade *lendingstate.LendingTrade, quantity *big.Int, price *big.Int) (*big.Int, *big.Int) {
+	if quantity.Sign() <= 0 || price.Sign() <= 0 {
+		return new(big.Int), new(big.Int)
+	}
+	lockAddress := common.HexToAddress(common.LendingLockAddress)
+	collateralBalance := lendingstate.GetTokenBalance(lockAddress, lendingTrade.CollateralToken, statedb)
+	lendingBalance := lendingstate.GetTokenBalance(lockAddress, lendingTrade.LendingToken, statedb)
+
+	order := &tradingstate.OrderItem{
+		Quantity:        new(big.Int).Set(quantity),
+		Price:           price,
+		ExchangeAddress: lendingTrade.InvestingRelayer,
+		UserAddress:     lockAddress,
+		BaseToken:       lendingTrade.CollateralToken,
+		QuoteToken:      lendingTrade.LendingToken,
+		Status:          tradingstate.OrderStatusNew,
+		Hash:            crypto.Keccak256Hash(lendingTrade.Hash.Bytes(), header.Number.Bytes()),
+		FilledAmount:    new(big.Int),
+		Nonce:           new(big.Int),
+	}
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	trades, _, err := l.FREx.ProcessLiquidationOrder(header, chain, statedb, tradingStateDB, orderbook, order)
+	if err != nil {
+		log.Debug("auctionCollateral ProcessLiquidationOrder", "tradeHash", lendingTrade.Hash.Hex(), "err", err)
+		return new(big.Int), new(big.Int)
+	}
+	sold := new(big.Int).Sub(collateralBalance, lendingstate.GetTokenBalance(lockAddress, lendingTrade.CollateralToken, statedb))
+	proceeds := new(big.Int).Sub(lendingstate.GetTokenBalance(lockAddress, lendingTrade.LendingToken, statedb), lendingBalance)
+	log.Debug("auctionCollateral", "tradeHash", lendingTrade.Hash.Hex(), "price", price, "trades", len(trades), "sold", sold, "proceeds", proceeds)
+	return sold, proceeds
+}
+
+// auctionRound returns the round of the liquidation auction of a trade at the
+// given block. An auction whose last round didn't run at the previous
+// liquidation round, an epoch earlier, starts over.
+func auctionRound(trade *lendingstate.LendingTrade, number uint64, epoch uint64) uint64 {
+	if trade.AuctionRound == 0 || epoch == 0 || trade.AuctionBlock+epoch != number {
+		return 0
+	}
+	return trade.AuctionRound
+}
+
+// auctionPrice returns the lowest price at which the collateral is sold in an
+// auction round and whether it is the last round of the auction.
+func auctionPrice(collateralPrice *big.Int, round uint64, config *params.LendingConfig) (*big.Int, bool) {
+	discount := config.AuctionMaxDiscount
+	if config.AuctionStepDiscount > 0 && round < config.AuctionMaxDiscount/config.AuctionStepDiscount {
+		discount = round * config.AuctionStepDiscount
+	}
+	if discount > 100 {
+		discount = 100
+	}
+	price := new(big.Int).Mul(collateralPrice, new(big.Int).SetUint64(100-discount))
+	price.Div(price, big.NewInt(100))
+	return price, discount == config.AuctionMaxDiscount || discount == 100
+}
+
+// payLiquidationPenalty pays a liquidation penalty in the collateral of a trade,
+// keeperShare percent to the masternode creating the block and the rest to the
+// owner of the investing relayer, and returns the part paid to the masternode.
+func payLiquidationPenalty(header *types.Header, statedb *state.StateDB, lendingTrade *lendingstate.LendingTrade, penalty *big.Int, keeperShare uint64) *big.Int {
+	lockAddress := common.HexToAddress(common.LendingLockAddress)
+	keeperFee := liquidationKeeperFee(penalty, keeperShare)
+	if keeperFee.Sign() > 0 {
+		lendingstate.SubTokenBalance(lockAddress, keeperFee, lendingTrade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(header.Coinbase, keeperFee, lendingTrade.CollateralToken, statedb)
+	}
+	if relayerFee := new(big.Int).Sub(penalty, keeperFee); relayerFee.Sign() > 0 {
+		lendingstate.SubTokenBalance(lockAddress, relayerFee, lendingTrade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(lendingstate.GetRelayerOwner(lendingTrade.InvestingRelayer, statedb), relayerFee, lendingTrade.CollateralToken, statedb)
+	}
+	return keeperFee
+}
+
+// liquidationKeeperFee returns the share of a liquidation penalty paid to the
+// masternode creating the block, keeperShare being in percent.
+func liquidationKeeperFee(penalty *big.Int, keeperShare uint64) *big.Int {
+	if keeperShare >= 100 {
+		return new(big.Int).Set(penalty)
+	}
+	fee := new(big.Int).Mul(penalty, new(big.Int).SetUint64(keeperShare))
+	return fee.Div(fee, big.NewInt(100))
+}
+
+// partialLiquidationAmount returns the collateral to liquidate to bring a trade
+// back to its deposit rate at the given collateral price, when penalty percent
+// of the liquidated collateral is paid to the relayer. It returns nil if a
+// partial liquidation can't restore the deposit rate.
+func partialLiquidationAmount(trade *lendingstate.LendingTrade, collateralPrice *big.Int, penalty *big.Int) *big.Int {
+	if trade.DepositRate == nil || trade.LiquidationRate == nil || trade.LiquidationPrice == nil || trade.CollateralLockedAmount == nil || trade.Amount == nil {
+		return nil
+	}
+	if collateralPrice.Sign() <= 0 || trade.LiquidationRate.Sign() <= 0 || trade.LiquidationPrice.Sign() <= 0 || trade.CollateralLockedAmount.Sign() <= 0 {
+		return nil
+	}
+	// With C the locked collateral, L the liquidation price, P the collateral
+	// price, T the deposit rate and lr the liquidation rate, liquidating S and
+	// repaying the debt with S*(100-penalty)/100 restores the deposit rate for
+	// S = 10000*C*(T*L - P*lr) / (P*lr*(T*(100-penalty) - 10000))
+	denominator := new(big.Int).Sub(big.NewInt(100), penalty)
+	denominator.Mul(denominator, trade.DepositRate)
+	denominator.Sub(denominator, big.NewInt(10000))
+	if denominator.Sign() <= 0 {
+		return nil
+	}
+	denominator.Mul(denominator, collateralPrice)
+	denominator.Mul(denominator, trade.LiquidationRate)
+
+	numerator := new(big.Int).Mul(trade.DepositRate, trade.LiquidationPrice)
+	numerator.Sub(numerator, new(big.Int).Mul(collateralPrice, trade.LiquidationRate))
+	if numerator.Sign() <= 0 {
+		return nil
+	}
+	numerator.Mul(numerator, trade.CollateralLockedAmount)
+	numerator.Mul(numerator, big.NewInt(10000))
+
+	// round up, the trade must end up healthy
+	amount := numerator.Add(numerator, new(big.Int).Sub(denominator, common.Big1))
+	amount.Div(amount, denominator)
+	if amount.Cmp(trade.CollateralLockedAmount) >= 0 {
+		return nil
+	}
+	return amount
+}
+
+// collateralValue returns the value in lending tokens of an amount of the
+// collateral of a trade at the given collateral price.
+func collateralValue(trade *lendingstate.LendingTrade, amount *big.Int, collateralPrice *big.Int) *big.Int {
+	// the liquidation price L is the price at which the locked collateral C is
+	// worth lr percent of the debt D, a collateral unit is worth P*D*lr/(100*C*L)
+	value := new(big.Int).Mul(amount, collateralPrice)
+	value.Mul(value, trade.Amount)
+	value.Mul(value, trade.LiquidationRate)
+	value.Div(value, new(big.Int).Mul(big.NewInt(100), new(big.Int).Mul(trade.CollateralLockedAmount, trade.LiquidationPrice)))
+	return value
+}
@@ -0,0 +1,205 @@
+package FRExlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/FREx"
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/params"
+)
+
+func TestPartialLiquidationAmount(t *testing.T) {
+	// 1000000 lent against 1000000 collateral units of 6 decimals, deposit
+	// rate 150% and liquidation rate 110%, liquidated below 1.1
+	newTrade := func(depositRate int64) *lendingstate.LendingTrade {
+		return &lendingstate.LendingTrade{
+			Amount:                 big.NewInt(1000000),
+			CollateralLockedAmount: big.NewInt(1000000),
+			LiquidationPrice:       big.NewInt(1100000),
+			DepositRate:            big.NewInt(depositRate),
+			LiquidationRate:        big.NewInt(110),
+		}
+	}
+	tests := []struct {
+		name        string
+		depositRate int64
+		price       int64
+		want        *big.Int
+	}{
+		{"partial", 150, 1090000, big.NewInt(885052)},
+		{"too much collateral needed", 150, 1050000, nil},
+		{"trade at its deposit rate", 150, 1500000, nil},
+		{"deposit rate too low to cover the penalty", 105, 1090000, nil},
+	}
+	for _, tt := range tests {
+		amount := partialLiquidationAmount(newTrade(tt.depositRate), big.NewInt(tt.price), big.NewInt(5))
+		if (amount == nil) != (tt.want == nil) || amount != nil && amount.Cmp(tt.want) != 0 {
+			t.Errorf("%s: liquidated amount mismatch: have %v, want %v", tt.name, amount, tt.want)
+		}
+	}
+
+	// repaying the debt with the liquidated collateral restores the deposit rate
+	trade := newTrade(150)
+	price := big.NewInt(1090000)
+	amount := partialLiquidationAmount(trade, price, big.NewInt(5))
+	penalty := new(big.Int).Div(new(big.Int).Mul(amount, big.NewInt(5)), big.NewInt(100))
+	repaid := collateralValue(trade, new(big.Int).Sub(amount, penalty), price)
+	if repaid.Cmp(big.NewInt(916472)) != 0 {
+		t.Fatalf("repaid amount mismatch: have %v, want 916472", repaid)
+	}
+	collateral := new(big.Int).Sub(trade.CollateralLockedAmount, amount)
+	debt := new(big.Int).Sub(trade.Amount, repaid)
+	// collateral * price / decimals >= debt * 150%
+	value := new(big.Int).Mul(collateral, price)
+	if value.Mul(value, big.NewInt(100)).Cmp(new(big.Int).Mul(debt, big.NewInt(150*1000000))) < 0 {
+		t.Errorf("trade below deposit rate after partial liquidation: collateral %v, debt %v", collateral, debt)
+	}
+}
+
+func TestLiquidationKeeperFee(t *testing.T) {
+	tests := []struct {
+		penalty int64
+		share   uint64
+		want    int64
+	}{
+		{1000, 50, 500},
+		{1001, 50, 500},
+		{1000, 0, 0},
+		{1000, 100, 1000},
+		{1000, 150, 1000},
+	}
+	for _, tt := range tests {
+		if fee := liquidationKeeperFee(big.NewInt(tt.penalty), tt.share); fee.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("penalty %d, share %d: keeper fee mismatch: have %v, want %d", tt.penalty, tt.share, fee, tt.want)
+		}
+	}
+}
+
+func TestAuctionPrice(t *testing.T) {
+	config := &params.LendingConfig{AuctionStepDiscount: 5, AuctionMaxDiscount: 20}
+	tests := []struct {
+		round uint64
+		price int64
+		last  bool
+	}{
+		{0, 1000, false},
+		{1, 950, false},
+		{3, 850, false},
+		{4, 800, true},
+		{10, 800, true},
+	}
+	for _, tt := range tests {
+		price, last := auctionPrice(big.NewInt(1000), tt.round, config)
+		if price.Cmp(big.NewInt(tt.price)) != 0 || last != tt.last {
+			t.Errorf("round %d: price mismatch: have %v (last %v), want %d (last %v)", tt.round, price, last, tt.price, tt.last)
+		}
+	}
+	// without steps the auction has a single round at the maximum discount
+	if price, last := auctionPrice(big.NewInt(1000), 0, &params.LendingConfig{AuctionMaxDiscount: 20}); price.Cmp(big.NewInt(800)) != 0 || !last {
+		t.Errorf("single round price mismatch: have %v (last %v), want 800 (last true)", price, last)
+	}
+}
+
+func TestAuctionRound(t *testing.T) {
+	tests := []struct {
+		round, block, number uint64
+		want                 uint64
+	}{
+		{0, 0, 1000, 0},
+		{2, 100, 1000, 2},
+		{2, 100, 1900, 0}, // a liquidation round was missed
+		{2, 1000, 1000, 0},
+	}
+	for _, tt := range tests {
+		trade := &lendingstate.LendingTrade{AuctionRound: tt.round, AuctionBlock: tt.block}
+		if round := auctionRound(trade, tt.number, 900); round != tt.want {
+			t.Errorf("round %d at block %d, block %d: round mismatch: have %d, want %d", tt.round, tt.block, tt.number, round, tt.want)
+		}
+	}
+}
+
+type testChainContext struct {
+	config *params.ChainConfig
+}
+
+func (c *testChainContext) Engine() consensus.Engine                    { return nil }
+func (c *testChainContext) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c *testChainContext) CurrentHeader() *types.Header                { return nil }
+func (c *testChainContext) Config() *params.ChainConfig                 { return c.config }
+
+func TestAuctionLiquidationTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	chain := &testChainContext{&params.ChainConfig{
+		S2PoS:   &params.S2PoSConfig{Epoch: 900},
+		Lending: &params.LendingConfig{LiquidationPenalty: 5, KeeperShare: 50, Auction: true, AuctionStepDiscount: 5, AuctionMaxDiscount: 20},
+	}}
+	l := &Lending{FREx: &FREx.FREX{}}
+
+	var (
+		collateralToken = common.HexToAddress(common.FRENativeAddress)
+		lendingToken    = common.HexToAddress("0x1100000000000000000000000000000000000003")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000011")
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, lendingstate.OpenTerm)
+		orderbook       = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		collateralPrice = big.NewInt(1090000)
+	)
+	statedb.AddBalance(lockAddress, big.NewInt(1000000))
+	lendingStateDb.InsertTradingItem(lendingBook, 1, lendingstate.LendingTrade{
+		TradeId:                1,
+		Term:                   lendingstate.OpenTerm,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Amount:                 big.NewInt(1000000),
+		CollateralLockedAmount: big.NewInt(1000000),
+		LiquidationPrice:       big.NewInt(1100000),
+		DepositRate:            big.NewInt(150),
+		LiquidationRate:        big.NewInt(110),
+		Investor:               investor,
+		Hash:                   common.HexToHash("0x01"),
+	})
+	tradingStateDb.InsertLiquidationPrice(orderbook, big.NewInt(1100000), lendingBook, 1)
+
+	// without bids nothing is sold, the auction goes on at a growing discount
+	for round := uint64(0); round < 4; round++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(100 + round*900), Time: big.NewInt(1000)}
+		trade, data, running, err := l.AuctionLiquidationTrade(header, chain, lendingStateDb, statedb, tradingStateDb, lendingBook, 1, collateralPrice)
+		if err != nil {
+			t.Fatalf("round %d: failed to liquidate trade: %v", round, err)
+		}
+		if !running || data.AuctionRound != round || data.AuctionSold.Sign() != 0 {
+			t.Fatalf("round %d: unexpected auction: running %v, round %d, sold %v", round, running, data.AuctionRound, data.AuctionSold)
+		}
+		want := new(big.Int).Mul(collateralPrice, new(big.Int).SetUint64(100-5*round))
+		if want.Div(want, big.NewInt(100)); data.AuctionPrice.Cmp(want) != 0 {
+			t.Errorf("round %d: auction price mismatch: have %v, want %v", round, data.AuctionPrice, want)
+		}
+		stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1))
+		if stored.AuctionRound != round+1 || stored.AuctionBlock != header.Number.Uint64() {
+			t.Errorf("round %d: stored auction mismatch: round %d, block %d", round, stored.AuctionRound, stored.AuctionBlock)
+		}
+		tradingStateDb.InsertLiquidationPrice(orderbook, trade.LiquidationPrice, lendingBook, 1)
+	}
+	// the round at the maximum discount liquidates the trade entirely
+	header := &types.Header{Number: big.NewInt(100 + 4*900), Time: big.NewInt(1000)}
+	trade, data, running, err := l.AuctionLiquidationTrade(header, chain, lendingStateDb, statedb, tradingStateDb, lendingBook, 1, collateralPrice)
+	if err != nil {
+		t.Fatalf("failed to liquidate trade: %v", err)
+	}
+	if running || trade.Status != lendingstate.TradeStatusLiquidated || data.LiquidationAmount.Cmp(big.NewInt(1000000)) != 0 {
+		t.Fatalf("trade not liquidated: running %v, status %s, liquidated %v", running, trade.Status, data.LiquidationAmount)
+	}
+	if balance := statedb.GetBalance(investor); balance.Cmp(big.NewInt(1000000)) != 0 {
+		t.Errorf("investor balance mismatch: have %v, want 1000000", balance)
+	}
+}
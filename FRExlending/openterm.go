@@ -0,0 +1,108 @@
+package FRExlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/log"
+)
+
+// variableInterestRate returns the yearly rate of an open-term lending book,
+// in common.BaseLendingInterest percents. The rate grows linearly with the
+// share of the borrowing volume in the liquidity of the book, from
+// common.LendingOpenTermBaseRate for a book without borrowers to the base
+// rate plus common.LendingOpenTermRateSlope for a book without investors.
+func variableInterestRate(borrowing, investing *big.Int) uint64 {
+	liquidity := new(big.Int).Add(borrowing, investing)
+	if liquidity.Sign() <= 0 {
+		return common.LendingOpenTermBaseRate
+	}
+	rate := new(big.Int).Mul(new(big.Int).SetUint64(common.LendingOpenTermRateSlope), borrowing)
+	rate = new(big.Int).Div(rate, liquidity)
+	return common.LendingOpenTermBaseRate + rate.Uint64()
+}
+
+// sumVolume returns the total amount of the orders of a lending tree.
+func sumVolume(volumes map[*big.Int]*big.Int) *big.Int {
+	total := new(big.Int)
+	for _, volume := range volumes {
+		total.Add(total, volume)
+	}
+	return total
+}
+
+// updateOpenTermBook reprices an open-term lending book from the liquidity
+// left in its investing and borrowing trees. The interest index is accrued up
+// to the block time at the previous rate before the new rate applies, it
+// accrues lazily between rate changes.
+func (l *Lending) updateOpenTermBook(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash) error {
+	if !lendingStateDB.Exist(lendingBook) {
+		return nil
+	}
+	investing, err := lendingStateDB.GetInvestings(lendingBook)
+	if err != nil {
+		return err
+	}
+	borrowing, err := lendingStateDB.GetBorrowings(lendingBook)
+	if err != nil {
+		return err
+	}
+	rate := variableInterestRate(sumVolume(borrowing), sumVolume(investing))
+	if rate == lendingStateDB.GetInterestRate(lendingBook) {
+		return nil
+	}
+	time := header.Time.Uint64()
+	index := lendingStateDB.GetInterestIndex(lendingBook, time)
+	log.Debug("Update open-term lending book", "lendingBook", lendingBook.Hex(), "interestIndex", index, "rate", rate)
+	lendingStateDB.SetInterestIndex(lendingBook, index, rate, time)
+	return nil
+}
+
+// ProcessOpenTermRecall starts the recall of an open-term trade by its
+// investor: the borrower has common.LendingOpenTermRecallPeriod seconds to
+// repay it, then the trade is repaid or liquidated like an expired trade.
+func (l *Lending) ProcessOpenTermRecall(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	lendingTradeId := order.LendingTradeId
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade || lendingTrade.TradeId != lendingTradeId {
+		return nil, fmt.Errorf("ProcessOpenTermRecall for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	if lendingTrade.Term != lendingstate.OpenTerm {
+		return nil, fmt.Errorf("ProcessOpenTermRecall: trade is not open-term. lendingTradeId: %v. Term: %v", lendingTradeId, lendingTrade.Term)
+	}
+	if order.UserAddress.String() != lendingTrade.Investor.String() {
+		return nil, fmt.Errorf("ProcessOpenTermRecall: invalid userAddress . UserAddress: %s . Investor: %s", order.UserAddress.Hex(), lendingTrade.Investor.Hex())
+	}
+	if order.Relayer.String() != lendingTrade.InvestingRelayer.String() {
+		return nil, fmt.Errorf("ProcessOpenTermRecall: invalid relayerAddress . Got: %s . Expect: %s", order.Relayer.Hex(), lendingTrade.InvestingRelayer.Hex())
+	}
+	if lendingTrade.LiquidationTime != 0 {
+		return nil, fmt.Errorf("ProcessOpenTermRecall: trade already recalled. lendingTradeId: %v. LiquidationTime: %v", lendingTradeId, lendingTrade.LiquidationTime)
+	}
+	liquidationTime := header.Time.Uint64() + common.LendingOpenTermRecallPeriod
+	lendingStateDB.UpdateLiquidationTime(lendingBook, lendingTradeId, liquidationTime)
+	lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(liquidationTime), lendingTradeId)
+	lendingTrade.LiquidationTime = liquidationTime
+	return &lendingTrade, nil
+}
+
+// isDue reports whether a trade must be closed at the given time. Open-term
+// trades are only due once recalled.
+func isDue(lendingTrade *lendingstate.LendingTrade, time uint64) bool {
+	if lendingTrade.Term == lendingstate.OpenTerm && lendingTrade.LiquidationTime == 0 {
+		return false
+	}
+	return lendingTrade.LiquidationTime <= time
+}
+
+// removeLiquidationTime removes a trade from the liquidation time trie of its
+// lending book, where open-term trades are only inserted once recalled.
+func removeLiquidationTime(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, lendingTrade *lendingstate.LendingTrade) error {
+	if lendingTrade.Term == lendingstate.OpenTerm && lendingTrade.LiquidationTime == 0 {
+		return nil
+	}
+	return lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTrade.TradeId, lendingTrade.LiquidationTime)
+}
@@ -0,0 +1,73 @@
+package FRExlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+)
+
+func TestVariableInterestRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		borrowing int64
+		investing int64
+		want      uint64
+	}{
+		{"empty book", 0, 0, common.LendingOpenTermBaseRate},
+		{"no borrowing", 0, 100, common.LendingOpenTermBaseRate},
+		{"half borrowing", 100, 100, common.LendingOpenTermBaseRate + common.LendingOpenTermRateSlope/2},
+		{"no investing", 100, 0, common.LendingOpenTermBaseRate + common.LendingOpenTermRateSlope},
+	}
+	for _, tt := range tests {
+		if rate := variableInterestRate(big.NewInt(tt.borrowing), big.NewInt(tt.investing)); rate != tt.want {
+			t.Errorf("%s: rate mismatch: have %d, want %d", tt.name, rate, tt.want)
+		}
+	}
+}
+
+func TestProcessOpenTermRecall(t *testing.T) {
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingToken := common.HexToAddress("0x1100000000000000000000000000000000000003")
+	investor := common.HexToAddress("0x0000000000000000000000000000000000000011")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000022")
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, lendingstate.OpenTerm)
+	lendingStateDb.InsertTradingItem(lendingBook, 1, lendingstate.LendingTrade{
+		TradeId:          1,
+		Term:             lendingstate.OpenTerm,
+		LendingToken:     lendingToken,
+		Amount:           big.NewInt(1000),
+		Investor:         investor,
+		InvestingRelayer: relayer,
+		InterestIndex:    common.BasePrice,
+	})
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1000)}
+	recall := &lendingstate.LendingItem{UserAddress: investor, Relayer: relayer, LendingTradeId: 1}
+	l := &Lending{}
+
+	if _, err := l.ProcessOpenTermRecall(header, lendingStateDb, lendingBook, &lendingstate.LendingItem{UserAddress: relayer, Relayer: relayer, LendingTradeId: 1}); err == nil {
+		t.Fatalf("recall by a borrower succeeded")
+	}
+	snap := lendingStateDb.Snapshot()
+	trade, err := l.ProcessOpenTermRecall(header, lendingStateDb, lendingBook, recall)
+	if err != nil {
+		t.Fatalf("failed to recall trade: %v", err)
+	}
+	want := header.Time.Uint64() + common.LendingOpenTermRecallPeriod
+	if trade.LiquidationTime != want {
+		t.Errorf("liquidation time mismatch: have %d, want %d", trade.LiquidationTime, want)
+	}
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.LiquidationTime != want {
+		t.Errorf("stored liquidation time mismatch: have %d, want %d", stored.LiquidationTime, want)
+	}
+	if _, err := l.ProcessOpenTermRecall(header, lendingStateDb, lendingBook, recall); err == nil {
+		t.Errorf("second recall succeeded")
+	}
+	lendingStateDb.RevertToSnapshot(snap)
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.LiquidationTime != 0 {
+		t.Errorf("recall not reverted: liquidation time %d", stored.LiquidationTime)
+	}
+}
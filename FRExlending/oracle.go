@@ -0,0 +1,272 @@
+package FRExlending
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/log"
+)
+
+// PriceRequest is a query of the price of a collateral token in a lending
+// token against the state of a block.
+type PriceRequest struct {
+	Header          *types.Header
+	Chain           consensus.ChainContext
+	Statedb         *state.StateDB
+	TradingStateDb  *tradingstate.TradingStateDB
+	CollateralToken common.Address
+	LendingToken    common.Address
+}
+
+// PriceSource is a collateral price feed aggregated by the price oracle. The
+// sources take part in consensus, every node must use the same sources.
+type PriceSource interface {
+	// Name identifies the source in the oracle results.
+	Name() string
+
+	// Price returns the price of the collateral token in the lending token, or
+	// nil if the source has no fresh price for the pair.
+	Price(req *PriceRequest) (*big.Int, error)
+}
+
+// OracleQuote is the price given by a source, accepted unless it was rejected
+// as an outlier.
+type OracleQuote struct {
+	Source   string   `json:"source"`
+	Price    *big.Int `json:"price"`
+	Accepted bool     `json:"accepted"`
+}
+
+// OraclePrice is the collateral price aggregated from the quotes of the price
+// sources, zero if no source has a price or the sources disagree.
+type OraclePrice struct {
+	CollateralToken common.Address `json:"collateralToken"`
+	LendingToken    common.Address `json:"lendingToken"`
+	Price           *big.Int       `json:"price"`
+	Quotes          []OracleQuote  `json:"quotes"`
+}
+
+// Sources returns the names of the sources the price was aggregated from.
+func (p *OraclePrice) Sources() []string {
+	sources := []string{}
+	for _, quote := range p.Quotes {
+		if quote.Accepted {
+			sources = append(sources, quote.Source)
+		}
+	}
+	return sources
+}
+
+// defaultPriceSources returns the price sources of the lending oracle.
+func defaultPriceSources(l *Lending) []PriceSource {
+	return []PriceSource{
+		&relayerPriceSource{lending: l},
+		&twapPriceSource{lending: l},
+		&crossPriceSource{lending: l},
+	}
+}
+
+// GetOraclePrice asks every price source for the collateral price of a pair
+// and returns the median of the quotes. With at least common.LendingOracleQuorum
+// quotes, the quotes deviating from the median by more than
+// common.LendingOracleMaxDeviation percent are rejected and the price is the
+// median of the remaining ones.
+func (l *Lending) GetOraclePrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*OraclePrice, error) {
+	req := &PriceRequest{
+		Header:          header,
+		Chain:           chain,
+		Statedb:         statedb,
+		TradingStateDb:  tradingStateDb,
+		CollateralToken: collateralToken,
+		LendingToken:    lendingToken,
+	}
+	result := &OraclePrice{
+		CollateralToken: collateralToken,
+		LendingToken:    lendingToken,
+		Quotes:          []OracleQuote{},
+	}
+	for _, source := range l.priceSources {
+		price, err := source.Price(req)
+		if err != nil {
+			log.Debug("Price oracle: source failed", "source", source.Name(), "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "err", err)
+			continue
+		}
+		if price == nil || price.Sign() <= 0 {
+			continue
+		}
+		result.Quotes = append(result.Quotes, OracleQuote{Source: source.Name(), Price: price})
+	}
+	result.Price = aggregateQuotes(result.Quotes)
+	log.Debug("Price oracle", "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "price", result.Price, "sources", result.Sources())
+	return result, nil
+}
+
+// aggregateQuotes marks the accepted quotes and returns their median.
+func aggregateQuotes(quotes []OracleQuote) *big.Int {
+	prices := make([]*big.Int, 0, len(quotes))
+	for _, quote := range quotes {
+		prices = append(prices, quote.Price)
+	}
+	median := medianPrice(prices)
+	if len(quotes) < common.LendingOracleQuorum {
+		for i := range quotes {
+			quotes[i].Accepted = true
+		}
+		return median
+	}
+	accepted := []*big.Int{}
+	for i := range quotes {
+		if withinDeviation(quotes[i].Price, median) {
+			quotes[i].Accepted = true
+			accepted = append(accepted, quotes[i].Price)
+		}
+	}
+	return medianPrice(accepted)
+}
+
+// medianPrice returns the median of the given prices, the mean of the two
+// middle prices for an even count and zero for no price.
+func medianPrice(prices []*big.Int) *big.Int {
+	if len(prices) == 0 {
+		return new(big.Int)
+	}
+	sorted := make([]*big.Int, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Int).Set(sorted[middle])
+	}
+	median := new(big.Int).Add(sorted[middle-1], sorted[middle])
+	return median.Div(median, big.NewInt(2))
+}
+
+// withinDeviation reports whether price is within common.LendingOracleMaxDeviation
+// percent of the median.
+func withinDeviation(price, median *big.Int) bool {
+	deviation := new(big.Int).Sub(price, median)
+	deviation.Abs(deviation)
+	deviation.Mul(deviation, big.NewInt(100))
+	return deviation.Cmp(new(big.Int).Mul(median, common.LendingOracleMaxDeviation)) <= 0
+}
+
+// relayerPriceSource quotes the prices posted to the lending registration
+// contract, which stay fresh for common.LendingOracleMaxPriceAge epochs.
+type relayerPriceSource struct {
+	lending *Lending
+}
+
+func (s *relayerPriceSource) Name() string { return "relayer" }
+
+func (s *relayerPriceSource) Price(req *PriceRequest) (*big.Int, error) {
+	price, updatedBlock := lendingstate.GetCollateralPrice(req.Statedb, req.CollateralToken, req.LendingToken)
+	if s.fresh(req, updatedBlock) && price.Sign() > 0 {
+		return price, nil
+	}
+	inversePrice, updatedBlock := lendingstate.GetCollateralPrice(req.Statedb, req.LendingToken, req.CollateralToken)
+	if s.fresh(req, updatedBlock) && inversePrice.Sign() > 0 {
+		return s.lending.invertPrice(req, req.CollateralToken, req.LendingToken, inversePrice)
+	}
+	return nil, nil
+}
+
+func (s *relayerPriceSource) fresh(req *PriceRequest, updatedBlock *big.Int) bool {
+	if updatedBlock.Sign() == 0 {
+		return false
+	}
+	epoch := req.Chain.Config().S2PoS.Epoch
+	return updatedBlock.Uint64()/epoch+common.LendingOracleMaxPriceAge >= req.Header.Number.Uint64()/epoch
+}
+
+// twapPriceSource quotes the average price of the collateral/lending pair
+// over the epochs of its epoch price history with trades.
+type twapPriceSource struct {
+	lending *Lending
+}
+
+func (s *twapPriceSource) Name() string { return "twap" }
+
+func (s *twapPriceSource) Price(req *PriceRequest) (*big.Int, error) {
+	return s.lending.twapPrice(req, req.CollateralToken, req.LendingToken)
+}
+
+// crossPriceSource quotes the collateral price routed through the FRE pairs
+// of both tokens, from their epoch average prices.
+type crossPriceSource struct {
+	lending *Lending
+}
+
+func (s *crossPriceSource) Name() string { return "cross" }
+
+func (s *crossPriceSource) Price(req *PriceRequest) (*big.Int, error) {
+	native := common.HexToAddress(common.FRENativeAddress)
+	if req.CollateralToken == native || req.LendingToken == native {
+		// the route is the direct pair already quoted by the twap source
+		return nil, nil
+	}
+	collateralFREPrice, err := s.lending.twapPrice(req, req.CollateralToken, native)
+	if err != nil || collateralFREPrice == nil {
+		return nil, err
+	}
+	lendTokenFREPrice, err := s.lending.twapPrice(req, req.LendingToken, native)
+	if err != nil || lendTokenFREPrice == nil {
+		return nil, err
+	}
+	lendingTokenDecimal, err := s.lending.FREx.GetTokenDecimal(req.Chain, req.Statedb, req.LendingToken)
+	if err != nil {
+		return nil, err
+	}
+	price := new(big.Int).Mul(collateralFREPrice, lendingTokenDecimal)
+	return price.Div(price, lendTokenFREPrice), nil
+}
+
+// twapPrice returns the average epoch price of the baseToken/quoteToken pair,
+// from the inverse pair if the pair has no traded epoch in its history.
+func (l *Lending) twapPrice(req *PriceRequest, baseToken, quoteToken common.Address) (*big.Int, error) {
+	if price := averageEpochPrice(req.TradingStateDb.GetEpochPrices(tradingstate.GetTradingOrderBookHash(baseToken, quoteToken))); price != nil {
+		return price, nil
+	}
+	if inversePrice := averageEpochPrice(req.TradingStateDb.GetEpochPrices(tradingstate.GetTradingOrderBookHash(quoteToken, baseToken))); inversePrice != nil {
+		return l.invertPrice(req, baseToken, quoteToken, inversePrice)
+	}
+	return nil, nil
+}
+
+// averageEpochPrice returns the average of the epoch prices of the epochs with
+// trades, nil if there is none.
+func averageEpochPrice(prices []*big.Int) *big.Int {
+	sum, count := new(big.Int), int64(0)
+	for _, price := range prices {
+		if price != nil && price.Sign() > 0 {
+			sum.Add(sum, price)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return sum.Div(sum, big.NewInt(count))
+}
+
+// invertPrice converts the price of quoteToken/baseToken into the price of
+// baseToken/quoteToken.
+func (l *Lending) invertPrice(req *PriceRequest, baseToken, quoteToken common.Address, inversePrice *big.Int) (*big.Int, error) {
+	baseTokenDecimal, err := l.FREx.GetTokenDecimal(req.Chain, req.Statedb, baseToken)
+	if err != nil {
+		return nil, err
+	}
+	quoteTokenDecimal, err := l.FREx.GetTokenDecimal(req.Chain, req.Statedb, quoteToken)
+	if err != nil {
+		return nil, err
+	}
+	price := new(big.Int).Mul(baseTokenDecimal, quoteTokenDecimal)
+	return price.Div(price, inversePrice), nil
+}
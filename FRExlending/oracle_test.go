@@ -0,0 +1,68 @@
+package FRExlending
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/rawdb"
+)
+
+func TestAggregateQuotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		prices   []int64
+		want     int64
+		accepted []bool
+	}{
+		{"no quote", nil, 0, []bool{}},
+		{"single quote", []int64{100}, 100, []bool{true}},
+		{"two quotes are averaged", []int64{100, 300}, 200, []bool{true, true}},
+		{"median of three", []int64{105, 100, 98}, 100, []bool{true, true, true}},
+		{"outlier rejected", []int64{100, 104, 30}, 102, []bool{true, true, false}},
+		{"no agreement", []int64{10, 10, 1000, 1000}, 0, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		quotes := []OracleQuote{}
+		for i, price := range tt.prices {
+			quotes = append(quotes, OracleQuote{Source: string(rune('a' + i)), Price: big.NewInt(price)})
+		}
+		if price := aggregateQuotes(quotes); price.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("%s: price mismatch: have %v, want %d", tt.name, price, tt.want)
+		}
+		accepted := []bool{}
+		for _, quote := range quotes {
+			accepted = append(accepted, quote.Accepted)
+		}
+		if !reflect.DeepEqual(accepted, tt.accepted) {
+			t.Errorf("%s: accepted quotes mismatch: have %v, want %v", tt.name, accepted, tt.accepted)
+		}
+	}
+}
+
+func TestTWAPPriceSource(t *testing.T) {
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	collateralToken := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	lendingToken := common.HexToAddress("0x1100000000000000000000000000000000000003")
+	req := &PriceRequest{
+		TradingStateDb:  tradingStateDb,
+		CollateralToken: collateralToken,
+		LendingToken:    lendingToken,
+	}
+	source := &twapPriceSource{lending: &Lending{}}
+
+	if price, err := source.Price(req); err != nil || price != nil {
+		t.Fatalf("price without history: have %v, %v, want nil", price, err)
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+	tradingStateDb.SetEpochPrices(orderBook, []*big.Int{big.NewInt(120), big.NewInt(0), big.NewInt(100), big.NewInt(110)})
+	price, err := source.Price(req)
+	if err != nil {
+		t.Fatalf("failed to get twap price: %v", err)
+	}
+	if price.Cmp(big.NewInt(110)) != 0 {
+		t.Errorf("twap price mismatch: have %v, want 110", price)
+	}
+}
@@ -33,6 +33,9 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		trades  []*lendingstate.LendingTrade
 		err     error
 	)
+	userIndex := chain.Config().IsTIPFREXPortfolio(header.Number)
+	tradingStateDb.SetUserIndex(userIndex)
+	lendingStateDB.SetUserIndex(userIndex)
 	nonce := lendingStateDB.GetNonce(order.UserAddress.Hash())
 	log.Debug("ApplyOrder", "addr", order.UserAddress, "statenonce", nonce, "ordernonce", order.Nonce)
 	if big.NewInt(int64(nonce)).Cmp(order.Nonce) == -1 {
@@ -56,6 +59,12 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 	}()
 
+	openTerm := chain.Config().IsTIPFREXOpenTermLending(header.Number)
+	if order.Term == lendingstate.OpenTerm && !openTerm {
+		log.Debug("open-term lending is not enabled", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
 	if err := order.VerifyLendingItem(statedb); err != nil {
 		log.Debug("invalid lending order", "order", lendingstate.ToJSON(order), "err", err)
 		rejects = append(rejects, order)
@@ -78,6 +87,16 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 		trades = append(trades, lendingTrade)
 		return trades, rejects, nil
+	case lendingstate.Recall:
+		if openTerm {
+			lendingTrade, err := l.ProcessOpenTermRecall(header, lendingStateDB, lendingOrderBook, order)
+			if err != nil {
+				log.Debug("Can not process recall", "err", err)
+				rejects = append(rejects, order)
+			}
+			trades = append(trades, lendingTrade)
+			return trades, rejects, nil
+		}
 	default:
 	}
 
@@ -118,6 +137,11 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 			rejects = append(rejects, order)
 		}
 	}
+	if order.Term == lendingstate.OpenTerm {
+		if err = l.updateOpenTermBook(header, lendingStateDB, lendingOrderBook); err != nil {
+			return nil, nil, err
+		}
+	}
 	return trades, rejects, nil
 }
 
@@ -344,6 +368,10 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 			log.Debug("LEND", "lendingOrderBook", lendingOrderBook.Hex(), "Taker Interest", Interest, "maker Interest", order.Interest, "Amount", tradedQuantity, "orderId", orderId, "side", side)
 			tradingId := lendingStateDB.GetTradeNonce(lendingOrderBook) + 1
 			liquidationTime := header.Time.Uint64() + order.Term
+			if order.Term == lendingstate.OpenTerm {
+				// open-term trades have no liquidation time until recalled
+				liquidationTime = 0
+			}
 			liquidationPrice := new(big.Int).Mul(collateralPrice, liquidationRate)
 			liquidationPrice = new(big.Int).Div(liquidationPrice, depositRate)
 			lendingTrade := lendingstate.LendingTrade{
@@ -394,12 +422,17 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 					lendingTrade.BorrowingFee = settleBalanceResult.Maker.Fee
 				}
 			}
+			if order.Term == lendingstate.OpenTerm {
+				lendingTrade.InterestIndex = lendingStateDB.GetInterestIndex(lendingOrderBook, header.Time.Uint64())
+			}
 			lendingTrade.Hash = lendingTrade.ComputeHash()
 
 			log.Debug("InsertTradingItem", "lendingOrderBook", lendingOrderBook.Hex(), "tradingId", tradingId, "lendingTrade", lendingTrade.Amount)
 			lendingStateDB.InsertTradingItem(lendingOrderBook, tradingId, lendingTrade)
-			log.Debug("InsertLiquidationTime", "lendingOrderBook", lendingOrderBook.Hex(), "tradingId", tradingId, "liquidationTime", liquidationTime)
-			lendingStateDB.InsertLiquidationTime(lendingOrderBook, new(big.Int).SetUint64(liquidationTime), tradingId)
+			if liquidationTime > 0 {
+				log.Debug("InsertLiquidationTime", "lendingOrderBook", lendingOrderBook.Hex(), "tradingId", tradingId, "liquidationTime", liquidationTime)
+				lendingStateDB.InsertLiquidationTime(lendingOrderBook, new(big.Int).SetUint64(liquidationTime), tradingId)
+			}
 			log.Debug("SetTradeNonce", "lendingOrderBook", lendingOrderBook.Hex(), "nonce", tradingId+1)
 			lendingStateDB.SetTradeNonce(lendingOrderBook, tradingId)
 			log.Debug("InsertLiquidationPrice", "TradingOrderBookHash", tradingstate.GetTradingOrderBookHash(collateralToken, order.LendingToken).Hex(), "tradingId", tradingId, "lendingOrderBook", lendingOrderBook.Hex(), "liquidationPrice", liquidationPrice)
@@ -810,7 +843,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	}
 	repayAmount := lendingTrade.CollateralLockedAmount
 
-	_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	collateralPrice, priceSources, err := l.getLiquidationPrice(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
 	if err != nil || collateralPrice == nil || collateralPrice.Sign() <= 0 {
 		// if cannot get collateralPrice, liquidate all collateral
 		log.Error("LiquidationExpiredTrade: cannot get collateralPrice", "err", err)
@@ -821,7 +854,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 		_, liquidationRate, _ := lendingstate.GetCollateralDetail(statedb, lendingTrade.CollateralToken)
 		collateralAmount := new(big.Int).Mul(repayAmount, big.NewInt(100))
 		collateralAmount = new(big.Int).Div(collateralAmount, liquidationRate)
-		totalCollateralAmount := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, collateralAmount)
+		totalCollateralAmount := lendingStateDB.CalculateRepayValue(lendingBook, &lendingTrade, header.Time.Uint64(), collateralAmount)
 		interestAmount := new(big.Int).Sub(totalCollateralAmount, collateralAmount)
 		repayAmount = new(big.Int).Add(repayAmount, interestAmount)
 	}
@@ -836,7 +869,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 	lendingstate.AddTokenBalance(lendingTrade.Investor, repayAmount, lendingTrade.CollateralToken, statedb)
 
-	err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	err = removeLiquidationTime(lendingStateDB, lendingBook, &lendingTrade)
 	if err != nil {
 		log.Debug("LiquidationTrade RemoveLiquidationTime", "err", err)
 		return nil, err
@@ -857,6 +890,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 		LiquidationAmount: repayAmount,
 		CollateralPrice:   collateralPrice,
 		Reason:            lendingstate.LiquidatedByTime,
+		PriceSources:      priceSources,
 	}
 	extraData, _ := json.Marshal(liquidationData)
 	lendingTrade.ExtraData = string(extraData)
@@ -873,7 +907,7 @@ func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB,
 	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 	lendingstate.AddTokenBalance(lendingTrade.Investor, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 
-	err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	err := removeLiquidationTime(lendingStateDB, lendingBook, &lendingTrade)
 	if err != nil {
 		log.Debug("LiquidationTrade RemoveLiquidationTime", "err", err)
 		return nil, err
@@ -958,6 +992,7 @@ func (l *Lending) GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, s
 // - Have pairs with FRE:
 // -  lendToken/FRE and CollateralToken/FRE
 // -  FRE/lendToken and FRE/CollateralToken
+// Since TIPFREXPriceOracle the collateral price is given by GetOraclePrice.
 func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error) {
 	// lendTokenFREPrice: price of ticker lendToken/FRE
 	// collateralFREPrice: price of ticker collateralToken/FRE
@@ -970,6 +1005,13 @@ func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.Chai
 	if err != nil {
 		return nil, nil, err
 	}
+	if chain.Config().IsTIPFREXPriceOracle(header.Number) {
+		oraclePrice, err := l.GetOraclePrice(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lendTokenFREPrice, oraclePrice.Price, nil
+	}
 	if collateralPriceUpdatedFromContract {
 		log.Debug("Getting collateral/lending token price from contract", "price", collateralPriceFromContract)
 		return lendTokenFREPrice, collateralPriceFromContract, nil
@@ -1018,6 +1060,21 @@ func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.Chai
 	return lendTokenFREPrice, collateralPrice, nil
 }
 
+// getLiquidationPrice returns the collateral price trades of a pair are
+// liquidated at and the oracle sources it was aggregated from. No source is
+// reported before the price oracle hardfork.
+func (l *Lending) getLiquidationPrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, []string, error) {
+	if !chain.Config().IsTIPFREXPriceOracle(header.Number) {
+		_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+		return collateralPrice, nil, err
+	}
+	oraclePrice, err := l.GetOraclePrice(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oraclePrice.Price, oraclePrice.Sources(), nil
+}
+
 func (l *Lending) GetFREBasePrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, token common.Address) (*big.Int, error) {
 
 	tokenFREPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, token, common.HexToAddress(common.FRENativeAddress))
@@ -1121,11 +1178,11 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 	}
 	time := header.Time.Uint64()
 	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
-	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	paymentBalance := lendingStateDB.CalculateRepayValue(lendingBook, &lendingTrade, time, lendingTrade.Amount)
 	log.Debug("ProcessRepay", "totalInterest", new(big.Int).Sub(paymentBalance, lendingTrade.Amount), "totalRepayValue", paymentBalance, "token", lendingTrade.LendingToken.Hex())
 
 	if tokenBalance.Cmp(paymentBalance) < 0 {
-		if lendingTrade.LiquidationTime > time {
+		if !isDue(&lendingTrade, time) {
 			return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", paymentBalance, tokenBalance)
 		}
 		newLendingTrade := &lendingstate.LendingTrade{}
@@ -1159,7 +1216,7 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 		lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 		lendingstate.AddTokenBalance(lendingTrade.Borrower, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 
-		err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+		err = removeLiquidationTime(lendingStateDB, lendingBook, &lendingTrade)
 		if err != nil {
 			log.Debug("ProcessRepay RemoveLiquidationTime", "err", err, "lendingHash", lendingTrade.Hash, "trade", lendingstate.ToJSON(lendingTrade))
 			return nil, err
@@ -113,8 +113,14 @@ func NewFRESimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64, chainConfi
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
 func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
+	return NewSimulatedBackendWithConfig(alloc, params.AllEthashProtocolChanges)
+}
+
+// NewSimulatedBackendWithConfig creates a new binding backend using a simulated
+// blockchain of the given chain config for testing purposes.
+func NewSimulatedBackendWithConfig(alloc core.GenesisAlloc, chainConfig *params.ChainConfig) *SimulatedBackend {
 	database := rawdb.NewMemoryDatabase()
-	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, Alloc: alloc, GasLimit: 42000000}
+	genesis := core.Genesis{Config: chainConfig, Alloc: alloc, GasLimit: 42000000}
 	genesis.MustCommit(database)
 	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vm.Config{})
 
@@ -156,13 +156,11 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, FREConfig) {
 
 	// Check testnet is enable.
 	if ctx.GlobalBool(utils.FRETestnetFlag.Name) {
-		common.IsTestnet = true
+		cfg.Eth.Testnet = true
 		common.TRC21IssuerSMC = common.TRC21IssuerSMCTestNet
+		common.FREXListingSMC = common.FREXListingSMCTestNet
 		cfg.Eth.NetworkId = 51
 		common.RelayerRegistrationSMC = common.RelayerRegistrationSMCTestnet
-		common.TIPTRC21Fee = common.TIPFREXTestnet
-		common.TIPTRC21Fee = common.TIPTRC21FeeTestnet
-		common.TIPFREXCancellationFee = common.TIPFREXCancellationFeeTestnet
 	}
 
 	if ctx.GlobalBool(utils.Enable0xPrefixFlag.Name) {
@@ -27,7 +27,6 @@ import (
 	"github.com/FRECNET/accounts"
 	"github.com/FRECNET/accounts/keystore"
 	"github.com/FRECNET/cmd/utils"
-	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus/S2PoS"
 	"github.com/FRECNET/console"
 	"github.com/FRECNET/core"
@@ -72,6 +71,8 @@ var (
 		utils.FREXDBConnectionUrlFlag,
 		utils.FREXDBReplicaSetNameFlag,
 		utils.FREXDBNameFlag,
+		utils.FREXPruneEpochsFlag,
+		utils.FREXPruneCheckpointsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
@@ -301,7 +302,7 @@ func startNode(ctx *cli.Context, stack *node.Node, cfg FREConfig) {
 			ok := false
 			slaveMode := ctx.GlobalIsSet(utils.FRESlaveModeFlag.Name)
 			var err error
-			if common.IsTestnet {
+			if ctx.GlobalBool(utils.FRETestnetFlag.Name) {
 				ok, err = ethereum.ValidateMasternodeTestnet()
 				fmt.Println("ValidateMasterNodeTest")
 				if err != nil {
@@ -342,7 +343,7 @@ func startNode(ctx *cli.Context, stack *node.Node, cfg FREConfig) {
 			defer close(core.CheckpointCh)
 			for range core.CheckpointCh {
 				log.Info("Checkpoint!!! It's time to reconcile node's state...")
-				if common.IsTestnet {
+				if ctx.GlobalBool(utils.FRETestnetFlag.Name) {
 					ok, err = ethereum.ValidateMasternodeTestnet()
 					if err != nil {
 						utils.Fatalf("Can't verify masternode permission: %v", err)
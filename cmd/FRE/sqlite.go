@@ -0,0 +1,7 @@
+// +build sqlite
+
+package main
+
+// The sqlite3 FREx.dbengine of SDK nodes needs cgo, its driver is only linked
+// into the builds with the sqlite tag.
+import _ "github.com/mattn/go-sqlite3"
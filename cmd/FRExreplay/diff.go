@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+)
+
+// levelChange is the change of the volume of a price or interest level of a
+// book, zero volumes are levels that don't exist.
+type levelChange struct {
+	Key    *big.Int `json:"key"`
+	Before *big.Int `json:"before"`
+	After  *big.Int `json:"after"`
+}
+
+// bookDiff is the change of the levels of an order book or lending book.
+type bookDiff struct {
+	Book       common.Hash   `json:"book"`
+	Bids       []levelChange `json:"bids,omitempty"`
+	Asks       []levelChange `json:"asks,omitempty"`
+	Investings []levelChange `json:"investings,omitempty"`
+	Borrowings []levelChange `json:"borrowings,omitempty"`
+}
+
+func (d *bookDiff) empty() bool {
+	return len(d.Bids) == 0 && len(d.Asks) == 0 && len(d.Investings) == 0 && len(d.Borrowings) == 0
+}
+
+// bookLevels are the volumes by level of the two sides of a book.
+type bookLevels [2]map[*big.Int]*big.Int
+
+func tradingLevels(tradingState *tradingstate.TradingStateDB, orderBook common.Hash) bookLevels {
+	// the dumps fail for books that don't exist yet, which have no level
+	bids, _ := tradingState.GetBids(orderBook)
+	asks, _ := tradingState.GetAsks(orderBook)
+	return bookLevels{bids, asks}
+}
+
+func lendingLevels(lendingState *lendingstate.LendingStateDB, lendingBook common.Hash) bookLevels {
+	investings, _ := lendingState.GetInvestings(lendingBook)
+	borrowings, _ := lendingState.GetBorrowings(lendingBook)
+	return bookLevels{investings, borrowings}
+}
+
+// diffLevels returns the levels whose volume changed, by increasing key.
+func diffLevels(before, after map[*big.Int]*big.Int) []levelChange {
+	changes := map[string]*levelChange{}
+	get := func(key *big.Int) *levelChange {
+		change, ok := changes[key.String()]
+		if !ok {
+			change = &levelChange{Key: key, Before: new(big.Int), After: new(big.Int)}
+			changes[key.String()] = change
+		}
+		return change
+	}
+	for key, volume := range before {
+		get(key).Before = volume
+	}
+	for key, volume := range after {
+		get(key).After = volume
+	}
+	result := []levelChange{}
+	for _, change := range changes {
+		if change.Before.Cmp(change.After) != 0 {
+			result = append(result, *change)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key.Cmp(result[j].Key) < 0
+	})
+	return result
+}
+
+// diffTradingBook returns the change of an order book.
+func diffTradingBook(orderBook common.Hash, before, after bookLevels) bookDiff {
+	return bookDiff{
+		Book: orderBook,
+		Bids: diffLevels(before[0], after[0]),
+		Asks: diffLevels(before[1], after[1]),
+	}
+}
+
+// diffLendingBook returns the change of a lending book.
+func diffLendingBook(lendingBook common.Hash, before, after bookLevels) bookDiff {
+	return bookDiff{
+		Book:       lendingBook,
+		Investings: diffLevels(before[0], after[0]),
+		Borrowings: diffLevels(before[1], after[1]),
+	}
+}
+
+// bookTracker records the levels of the books touched by a run of orders, to
+// report their changes once the run is over.
+type bookTracker struct {
+	tradingState *tradingstate.TradingStateDB
+	lendingState *lendingstate.LendingStateDB
+
+	orderBooks   []common.Hash
+	lendingBooks []common.Hash
+	before       map[common.Hash]bookLevels
+}
+
+func newBookTracker(tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) *bookTracker {
+	return &bookTracker{
+		tradingState: tradingState,
+		lendingState: lendingState,
+		before:       make(map[common.Hash]bookLevels),
+	}
+}
+
+// touchOrderBook records the levels of an order book before its first order.
+func (t *bookTracker) touchOrderBook(orderBook common.Hash) {
+	if _, ok := t.before[orderBook]; ok {
+		return
+	}
+	t.before[orderBook] = tradingLevels(t.tradingState, orderBook)
+	t.orderBooks = append(t.orderBooks, orderBook)
+}
+
+// touchLendingBook records the levels of a lending book before its first
+// lending item.
+func (t *bookTracker) touchLendingBook(lendingBook common.Hash) {
+	if _, ok := t.before[lendingBook]; ok {
+		return
+	}
+	t.before[lendingBook] = lendingLevels(t.lendingState, lendingBook)
+	t.lendingBooks = append(t.lendingBooks, lendingBook)
+}
+
+// diffs returns the changes of the touched books, in the order they were
+// first touched.
+func (t *bookTracker) diffs() []bookDiff {
+	result := []bookDiff{}
+	for _, orderBook := range t.orderBooks {
+		if diff := diffTradingBook(orderBook, t.before[orderBook], tradingLevels(t.tradingState, orderBook)); !diff.empty() {
+			result = append(result, diff)
+		}
+	}
+	for _, lendingBook := range t.lendingBooks {
+		if diff := diffLendingBook(lendingBook, t.before[lendingBook], lendingLevels(t.lendingState, lendingBook)); !diff.empty() {
+			result = append(result, diff)
+		}
+	}
+	return result
+}
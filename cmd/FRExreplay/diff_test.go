@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestDiffLevels(t *testing.T) {
+	before := map[*big.Int]*big.Int{
+		big.NewInt(100): big.NewInt(5),
+		big.NewInt(110): big.NewInt(3),
+		big.NewInt(120): big.NewInt(1),
+	}
+	after := map[*big.Int]*big.Int{
+		big.NewInt(90):  big.NewInt(2),
+		big.NewInt(100): big.NewInt(5),
+		big.NewInt(110): big.NewInt(1),
+	}
+	have := []string{}
+	for _, change := range diffLevels(before, after) {
+		have = append(have, change.Key.String()+":"+change.Before.String()+"->"+change.After.String())
+	}
+	want := []string{"90:0->2", "110:3->1", "120:1->0"}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("level changes mismatch: have %v, want %v", have, want)
+	}
+	if changes := diffLevels(nil, nil); len(changes) != 0 {
+		t.Fatalf("changes without levels: %v", changes)
+	}
+}
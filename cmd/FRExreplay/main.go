@@ -0,0 +1,162 @@
+// FRExreplay replays the FREX matching engine offline, from the chain and
+// FREX databases of a stopped node.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/FRECNET/FREx"
+	"github.com/FRECNET/FRExlending"
+	"github.com/FRECNET/cmd/utils"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/core"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/eth"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/ethdb/leveldb"
+	"github.com/FRECNET/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "the FREX matching engine replay and simulation tool")
+	app.Flags = []cli.Flag{
+		chainDataFlag,
+		FRExDataFlag,
+		outputFlag,
+	}
+	app.Commands = []cli.Command{
+		commandReplay,
+		commandSimulate,
+	}
+}
+
+// Commonly used command line flags.
+var (
+	chainDataFlag = cli.StringFlag{
+		Name:  "chaindata",
+		Usage: "directory of the chain database, the state of the replayed blocks must not be pruned",
+	}
+	FRExDataFlag = cli.StringFlag{
+		Name:  "FRExdata",
+		Usage: "directory of the FREX database holding the trading and lending tries",
+	}
+	outputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "file the JSON results are written to, stdout if not set",
+	}
+)
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// replayEnv holds the databases and services of the replayed node. It serves
+// as chain context of the FREX services.
+type replayEnv struct {
+	db      ethdb.Database
+	config  *params.ChainConfig
+	engine  *S2PoS.S2PoS
+	FREx    *FREx.FREX
+	lending *FRExlending.Lending
+	head    *types.Header
+}
+
+// openEnv opens the databases given on the command line. Nothing is ever
+// committed, the tries are only read and modified in memory.
+func openEnv(ctx *cli.Context) (*replayEnv, error) {
+	chainData, FRExData := ctx.GlobalString(chainDataFlag.Name), ctx.GlobalString(FRExDataFlag.Name)
+	if chainData == "" || FRExData == "" {
+		return nil, fmt.Errorf("--%s and --%s are required", chainDataFlag.Name, FRExDataFlag.Name)
+	}
+	kvdb, err := leveldb.New(chainData, eth.DefaultConfig.DatabaseCache, utils.MakeDatabaseHandles(), "")
+	if err != nil {
+		return nil, err
+	}
+	db := rawdb.NewDatabase(kvdb)
+	genesis := core.GetCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil, fmt.Errorf("no chain found in %s", chainData)
+	}
+	config, err := core.GetChainConfig(db, genesis)
+	if err != nil {
+		return nil, err
+	}
+	if config.S2PoS == nil {
+		return nil, fmt.Errorf("not a S2PoS chain")
+	}
+	head := core.GetHeadBlockHash(db)
+	FREXService := FREx.New(&FREx.Config{DataDir: FRExData})
+	return &replayEnv{
+		db:      db,
+		config:  config,
+		engine:  S2PoS.New(config.S2PoS, db),
+		FREx:    FREXService,
+		lending: FRExlending.New(FREXService),
+		head:    core.GetHeader(db, head, core.GetBlockNumber(db, head)),
+	}, nil
+}
+
+func (env *replayEnv) Engine() consensus.Engine {
+	return env.engine
+}
+
+func (env *replayEnv) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return core.GetHeader(env.db, hash, number)
+}
+
+func (env *replayEnv) CurrentHeader() *types.Header {
+	return env.head
+}
+
+func (env *replayEnv) Config() *params.ChainConfig {
+	return env.config
+}
+
+// getBlock returns the canonical block of the given number.
+func (env *replayEnv) getBlock(number uint64) (*types.Block, error) {
+	hash := core.GetCanonicalHash(env.db, number)
+	block := core.GetBlock(env.db, hash, number)
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return block, nil
+}
+
+// openOutput returns the writer of the JSON results and the function closing
+// it.
+func openOutput(ctx *cli.Context) (io.Writer, func(), error) {
+	path := ctx.GlobalString(outputFlag.Name)
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fh, func() { fh.Close() }, nil
+}
+
+// writeJSON writes one JSON document per line.
+func writeJSON(w io.Writer, object interface{}) error {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
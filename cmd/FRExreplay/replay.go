@@ -0,0 +1,244 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandReplay = cli.Command{
+	Name:      "replay",
+	Usage:     "replay the FREX matching of a range of blocks",
+	ArgsUsage: "<from> <to> | <exportfile>",
+	Description: `
+Replays the trading and lending orders of the given canonical blocks, or of
+the blocks of a chain export, against a copy of the trading and lending tries
+of their parent. Every block starts from the tries recorded for its parent, as
+during block validation.
+
+One JSON result is written per block, with the trades, the rejected orders,
+the finalized lending trades, the computed and recorded roots of the tries and
+the changes of the books touched by the block.`,
+	Action: replay,
+}
+
+// blockResult is the outcome of the replay of a block.
+type blockResult struct {
+	Number              uint64                       `json:"number"`
+	Hash                common.Hash                  `json:"hash"`
+	Trades              []map[string]string          `json:"trades"`
+	Rejects             []*tradingstate.OrderItem    `json:"rejects"`
+	LendingTrades       []*lendingstate.LendingTrade `json:"lendingTrades"`
+	LendingRejects      []*lendingstate.LendingItem  `json:"lendingRejects"`
+	FinalizedTrades     []*lendingstate.LendingTrade `json:"finalizedTrades"`
+	TradingRoot         common.Hash                  `json:"tradingRoot"`
+	ExpectedTradingRoot common.Hash                  `json:"expectedTradingRoot"`
+	LendingRoot         common.Hash                  `json:"lendingRoot"`
+	ExpectedLendingRoot common.Hash                  `json:"expectedLendingRoot"`
+	Mismatch            bool                         `json:"mismatch"`
+	Diff                []bookDiff                   `json:"diff"`
+}
+
+func replay(ctx *cli.Context) error {
+	env, err := openEnv(ctx)
+	if err != nil {
+		return err
+	}
+	next, err := blockSource(ctx, env)
+	if err != nil {
+		return err
+	}
+	out, closeOutput, err := openOutput(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	var parent *types.Block
+	for {
+		block, err := next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if block.NumberU64() == 0 {
+			continue
+		}
+		if parent == nil || parent.Hash() != block.ParentHash() {
+			if parent = core.GetBlock(env.db, block.ParentHash(), block.NumberU64()-1); parent == nil {
+				return fmt.Errorf("parent of block %d not found", block.NumberU64())
+			}
+		}
+		result, err := replayBlock(env, block, parent)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", block.NumberU64(), err)
+		}
+		if err := writeJSON(out, result); err != nil {
+			return err
+		}
+		parent = block
+	}
+}
+
+// blockSource returns the iterator of the replayed blocks, it returns io.EOF
+// after the last block.
+func blockSource(ctx *cli.Context, env *replayEnv) (func() (*types.Block, error), error) {
+	switch ctx.NArg() {
+	case 1:
+		fn := ctx.Args().First()
+		fh, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		var reader io.Reader = fh
+		if strings.HasSuffix(fn, ".gz") {
+			if reader, err = gzip.NewReader(reader); err != nil {
+				return nil, err
+			}
+		}
+		stream := rlp.NewStream(reader, 0)
+		return func() (*types.Block, error) {
+			var block types.Block
+			if err := stream.Decode(&block); err != nil {
+				return nil, err
+			}
+			return &block, nil
+		}, nil
+	case 2:
+		from, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid first block: %v", err)
+		}
+		to, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last block: %v", err)
+		}
+		number := from
+		return func() (*types.Block, error) {
+			if number > to {
+				return nil, io.EOF
+			}
+			number++
+			return env.getBlock(number - 1)
+		}, nil
+	default:
+		return nil, fmt.Errorf("expected a block range or an export file")
+	}
+}
+
+// replayBlock matches the orders of a block on top of the tries of its
+// parent, the same way the block is validated.
+func replayBlock(env *replayEnv, block, parent *types.Block) (*blockResult, error) {
+	header := block.Header()
+	author, err := env.engine.Author(header)
+	if err != nil {
+		return nil, err
+	}
+	parentAuthor, err := env.engine.Author(parent.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := state.New(parent.Root(), state.NewDatabase(env.db))
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := env.FREx.GetTradingState(parent, parentAuthor)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := env.lending.GetLendingState(parent, parentAuthor)
+	if err != nil {
+		return nil, err
+	}
+	result := &blockResult{
+		Number:          block.NumberU64(),
+		Hash:            block.Hash(),
+		Trades:          []map[string]string{},
+		Rejects:         []*tradingstate.OrderItem{},
+		LendingTrades:   []*lendingstate.LendingTrade{},
+		LendingRejects:  []*lendingstate.LendingItem{},
+		FinalizedTrades: []*lendingstate.LendingTrade{},
+	}
+	tracker := newBookTracker(tradingState, lendingState)
+
+	epoch := env.config.S2PoS.Epoch
+	if block.NumberU64()%epoch == 0 {
+		if err := env.FREx.UpdateMediumPriceBeforeEpoch(block.NumberU64()/epoch, tradingState, statedb, env.config.IsTIPFREXPriceOracle(block.Number())); err != nil {
+			return nil, err
+		}
+	} else {
+		batches, err := core.ExtractTradingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range batches {
+			for _, txMatch := range batch.Data {
+				order, err := txMatch.DecodeOrder()
+				if err != nil {
+					continue
+				}
+				orderBook := tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken)
+				tracker.touchOrderBook(orderBook)
+				trades, rejects, err := env.FREx.ApplyOrder(header, author, env, statedb, tradingState, orderBook, order)
+				if err != nil {
+					return nil, err
+				}
+				result.Trades = append(result.Trades, trades...)
+				result.Rejects = append(result.Rejects, rejects...)
+			}
+		}
+		lendingBatches, err := core.ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range lendingBatches {
+			for _, item := range batch.Data {
+				lendingBook := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+				tracker.touchLendingBook(lendingBook)
+				trades, rejects, err := env.lending.ApplyOrder(header, author, env, statedb, lendingState, tradingState, lendingBook, item)
+				if err != nil {
+					return nil, err
+				}
+				result.LendingTrades = append(result.LendingTrades, trades...)
+				result.LendingRejects = append(result.LendingRejects, rejects...)
+			}
+		}
+		if block.NumberU64()%epoch == common.LiquidateLendingTradeBlock {
+			finalizedTrades, _, _, _, _, err := env.lending.ProcessLiquidationData(header, env, statedb, tradingState, lendingState)
+			if err != nil {
+				return nil, err
+			}
+			for _, trade := range finalizedTrades {
+				result.FinalizedTrades = append(result.FinalizedTrades, trade)
+			}
+			sort.Slice(result.FinalizedTrades, func(i, j int) bool {
+				return result.FinalizedTrades[i].TradeId < result.FinalizedTrades[j].TradeId
+			})
+		}
+	}
+	result.TradingRoot = tradingState.IntermediateRoot()
+	if result.ExpectedTradingRoot, err = env.FREx.GetTradingStateRoot(block, author); err != nil {
+		return nil, err
+	}
+	result.LendingRoot = lendingState.IntermediateRoot()
+	if result.ExpectedLendingRoot, err = env.lending.GetLendingStateRoot(block, author); err != nil {
+		return nil, err
+	}
+	result.Mismatch = result.TradingRoot != result.ExpectedTradingRoot || result.LendingRoot != result.ExpectedLendingRoot
+	result.Diff = tracker.diffs()
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var coinbaseFlag = cli.StringFlag{
+	Name:  "coinbase",
+	Usage: "masternode matching the orders, the author of the given block if not set",
+}
+
+var commandSimulate = cli.Command{
+	Name:      "simulate",
+	Usage:     "match a hypothetical order stream against the books of a block",
+	ArgsUsage: "<block> <ordersfile>",
+	Description: `
+Matches the orders of the given file, in order, in a block following the given
+block, against a copy of the trading and lending tries of that block. Orders
+aren't signed, their nonce is taken from the state when left empty.
+
+The orders file is a JSON array of {"order": <order>} and
+{"lendingItem": <lending item>} entries. One JSON result is written per
+entry, with its trades and rejected orders, followed by the roots of the tries
+and the changes of the touched books.`,
+	Flags: []cli.Flag{
+		coinbaseFlag,
+	},
+	Action: simulate,
+}
+
+// simulationInput is an entry of an order stream, a trading order or a
+// lending item.
+type simulationInput struct {
+	Order       *tradingstate.OrderItem   `json:"order,omitempty"`
+	LendingItem *lendingstate.LendingItem `json:"lendingItem,omitempty"`
+}
+
+// simulationResult is the outcome of an entry of an order stream.
+type simulationResult struct {
+	Order          *tradingstate.OrderItem      `json:"order,omitempty"`
+	Trades         []map[string]string          `json:"trades,omitempty"`
+	Rejects        []*tradingstate.OrderItem    `json:"rejects,omitempty"`
+	LendingItem    *lendingstate.LendingItem    `json:"lendingItem,omitempty"`
+	LendingTrades  []*lendingstate.LendingTrade `json:"lendingTrades,omitempty"`
+	LendingRejects []*lendingstate.LendingItem  `json:"lendingRejects,omitempty"`
+	Error          string                       `json:"error,omitempty"`
+}
+
+// simulationSummary closes the results of an order stream.
+type simulationSummary struct {
+	TradingRoot common.Hash `json:"tradingRoot"`
+	LendingRoot common.Hash `json:"lendingRoot"`
+	Diff        []bookDiff  `json:"diff"`
+}
+
+func simulate(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("expected a block number and an orders file")
+	}
+	number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	data, err := ioutil.ReadFile(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	var inputs []simulationInput
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return fmt.Errorf("invalid orders file: %v", err)
+	}
+	env, err := openEnv(ctx)
+	if err != nil {
+		return err
+	}
+	block, err := env.getBlock(number)
+	if err != nil {
+		return err
+	}
+	author, err := env.engine.Author(block.Header())
+	if err != nil {
+		return err
+	}
+	coinbase := author
+	if ctx.IsSet(coinbaseFlag.Name) {
+		coinbase = common.HexToAddress(ctx.String(coinbaseFlag.Name))
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(env.db))
+	if err != nil {
+		return err
+	}
+	tradingState, err := env.FREx.GetTradingState(block, author)
+	if err != nil {
+		return err
+	}
+	lendingState, err := env.lending.GetLendingState(block, author)
+	if err != nil {
+		return err
+	}
+	header := &types.Header{
+		ParentHash: block.Hash(),
+		Coinbase:   coinbase,
+		Number:     new(big.Int).SetUint64(number + 1),
+		Time:       new(big.Int).Add(block.Time(), new(big.Int).SetUint64(env.config.S2PoS.Period)),
+		Difficulty: block.Difficulty(),
+		GasLimit:   block.GasLimit(),
+	}
+	out, closeOutput, err := openOutput(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	tracker := newBookTracker(tradingState, lendingState)
+	for _, input := range inputs {
+		result := simulationResult{}
+		switch {
+		case input.Order != nil:
+			order := input.Order
+			if order.Nonce == nil {
+				order.Nonce = new(big.Int).SetUint64(tradingState.GetNonce(order.UserAddress.Hash()))
+			}
+			orderBook := tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken)
+			tracker.touchOrderBook(orderBook)
+			result.Order = order
+			result.Trades, result.Rejects, err = env.FREx.ApplyOrder(header, coinbase, env, statedb, tradingState, orderBook, order)
+		case input.LendingItem != nil:
+			item := input.LendingItem
+			if item.Nonce == nil {
+				item.Nonce = new(big.Int).SetUint64(lendingState.GetNonce(item.UserAddress.Hash()))
+			}
+			lendingBook := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+			tracker.touchLendingBook(lendingBook)
+			result.LendingItem = item
+			result.LendingTrades, result.LendingRejects, err = env.lending.ApplyOrder(header, coinbase, env, statedb, lendingState, tradingState, lendingBook, item)
+		default:
+			err = fmt.Errorf("empty entry")
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if err := writeJSON(out, result); err != nil {
+			return err
+		}
+	}
+	return writeJSON(out, simulationSummary{
+		TradingRoot: tradingState.IntermediateRoot(),
+		LendingRoot: lendingState.IntermediateRoot(),
+		Diff:        tracker.diffs(),
+	})
+}
@@ -35,6 +35,12 @@ var (
 	stateRoots   = make(chan TrieRoot)
 )
 
+var (
+	frexDir         = flag.String("FREx.dir", "", "directory to FRECNET FREx data, its trading and lending tries are pruned if set")
+	frexEpochs      = flag.Uint64("FREx.epochs", common.FRExPruneEpochs, "number of recent epochs whose trading and lending states are kept")
+	frexCheckpoints = flag.Uint64("FREx.checkpoints", common.FRExPruneCheckpoints, "number of older epochs whose checkpoint trading and lending states are kept")
+)
+
 type TrieRoot struct {
 	trie   *trie.SecureTrie
 	number uint64
@@ -59,6 +65,9 @@ func main() {
 	tridb := trie.NewDatabase(lddb)
 	catchEventInterupt(db)
 	cache, _ = lru.New(*cacheSize)
+	if *frexDir != "" {
+		pruneFRExStates(lddb, currentHeader.Number.Uint64())
+	}
 	go func() {
 		for i := uint64(1); i <= currentHeader.Number.Uint64(); i++ {
 			hash := core.GetCanonicalHash(lddb, i)
@@ -116,6 +125,45 @@ func main() {
 	fmt.Println(time.Now(), "end")
 }
 
+// pruneFRExStates deletes from the FREx database the trading and lending trie
+// nodes unreachable from the states of the last epochs before the head and
+// from the checkpoint states of the older epochs.
+func pruneFRExStates(lddb ethdb.Database, head uint64) {
+	config, err := core.GetChainConfig(lddb, core.GetCanonicalHash(lddb, 0))
+	if err != nil || config.S2PoS == nil || config.S2PoS.Epoch == 0 {
+		fmt.Println(time.Now().Format(time.RFC3339), "Not found S2PoS chain config, skip pruning FREx states", err)
+		return
+	}
+	frexdb, err := rawdb.NewLevelDBDatabase(*frexDir, eth.DefaultConfig.DatabaseCache, utils.MakeDatabaseHandles(), "")
+	if err != nil {
+		fmt.Println(time.Now().Format(time.RFC3339), "Open FREx database error", err)
+		os.Exit(1)
+	}
+	defer frexdb.Close()
+
+	triedb := trie.NewDatabase(frexdb)
+	pruner := core.NewFRExPruner(frexdb, triedb, triedb)
+	fmt.Println(time.Now().Format(time.RFC3339), "Start mark FREx states at block ", head)
+	for _, number := range core.FRExRetainedBlocks(head, config.S2PoS.Epoch, *frexEpochs, *frexCheckpoints) {
+		block := core.GetBlock(lddb, core.GetCanonicalHash(lddb, number), number)
+		if block == nil {
+			continue
+		}
+		if err := pruner.MarkBlock(block); err != nil {
+			fmt.Println(time.Now().Format(time.RFC3339), "Mark FREx states error at block ", number, err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println(time.Now().Format(time.RFC3339), "Finish mark FREx states, keys ", pruner.Marked())
+	deleted, err := pruner.Sweep(nil)
+	if err != nil {
+		fmt.Println(time.Now().Format(time.RFC3339), "Sweep FREx states error", err)
+		os.Exit(1)
+	}
+	fmt.Println(time.Now().Format(time.RFC3339), "Finish sweep FREx states, deleted keys ", deleted)
+	frexdb.Compact(nil, nil)
+}
+
 func removeNodesNil(list [][17]*StateNode, length int) []*StateNode {
 	results := make([]*StateNode, length)
 	index := 0
@@ -57,6 +57,7 @@ func (w *wizard) makeGenesis() {
 			EIP155Block:    big.NewInt(3),
 			EIP158Block:    big.NewInt(3),
 			ByzantiumBlock: big.NewInt(4),
+			Forks:          params.MainnetForks(),
 		},
 	}
 	// Figure out which consensus engine to choose
@@ -216,7 +217,7 @@ func (w *wizard) makeGenesis() {
 			Storage: storage,
 		}
 
-		settingAddress, _, err := settingContract.DeploySetting(transactOpts, contractBackend, common.MaxMasternodes, epochNumber, reward, common.TIPIncreaseMasternodes.Uint64())
+		settingAddress, _, err := settingContract.DeploySetting(transactOpts, contractBackend, common.MaxMasternodes, epochNumber, reward, genesis.Config.ForkBlocks().TIPIncreaseMasternodesBlock.Uint64())
 		contractBackend.Commit()
 		fmt.Println("settingAddress:::", settingAddress)
 		code, _ = contractBackend.CodeAt(ctx, settingAddress, nil)
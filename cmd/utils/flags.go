@@ -126,7 +126,7 @@ var (
 	}
 	StoreRewardFlag = cli.BoolFlag{
 		Name:  "store-reward",
-		Usage: "Store reward to file",
+		Usage: "Serve the reward files stored by previous releases, rewards are now kept in the chain database",
 	}
 	DataDirFlag = DirectoryFlag{
 		Name:  "datadir",
@@ -543,7 +543,7 @@ var (
 	}
 	FREXDBEngineFlag = cli.StringFlag{
 		Name:  "FREx.dbengine",
-		Usage: "Database engine for FREX (leveldb, mongodb)",
+		Usage: "Database engine for FREX (leveldb, mongodb, sqlite3 in builds with the sqlite tag)",
 		Value: "leveldb",
 	}
 	FREXDBNameFlag = cli.StringFlag{
@@ -560,6 +560,15 @@ var (
 		Name:  "FREx.dbReplicaSetName",
 		Usage: "ReplicaSetName if Master-Slave is setup",
 	}
+	FREXPruneEpochsFlag = cli.Uint64Flag{
+		Name:  "FREx.prune.epochs",
+		Usage: "Number of recent epochs whose FREX trading and lending states are kept on disk, the others being pruned at every checkpoint (0 = no pruning)",
+	}
+	FREXPruneCheckpointsFlag = cli.Uint64Flag{
+		Name:  "FREx.prune.checkpoints",
+		Usage: "Number of older epochs whose checkpoint FREX trading and lending states are kept when pruning",
+		Value: common.FRExPruneCheckpoints,
+	}
 	FRESlaveModeFlag = cli.BoolFlag{
 		Name:  "slave",
 		Usage: "Enable slave mode",
@@ -1125,6 +1134,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	if ctx.GlobalIsSet(FREXPruneEpochsFlag.Name) {
+		if cfg.NoPruning {
+			Fatalf("--%s can't be used with --%s archive", FREXPruneEpochsFlag.Name, GCModeFlag.Name)
+		}
+		cfg.FRExPruneEpochs = ctx.GlobalUint64(FREXPruneEpochsFlag.Name)
+	}
+	cfg.FRExPruneCheckpoints = ctx.GlobalUint64(FREXPruneCheckpointsFlag.Name)
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -1235,6 +1251,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
+	if ctx.GlobalBool(FRETestnetFlag.Name) {
+		config = config.WithTestnetDefaults()
+	}
 	var engine consensus.Engine
 	if config.S2PoS != nil {
 		engine = S2PoS.New(config.S2PoS, chainDb)
@@ -33,20 +33,6 @@ const (
 
 var Rewound = uint64(0)
 
-var TIP2019Block = big.NewInt(1)
-var TIPSigning = big.NewInt(30000000)
-var TIPRandomize = big.NewInt(3464000)
-
-var TIPIncreaseMasternodes = big.NewInt(1000)   // Upgrade MN Count at Block.
-var TIPNoHalvingMNReward = big.NewInt(38383838) // hardfork no halving masternodes reward
-var BlackListHFNumber = uint64(38383838)
-var TIPFREX = big.NewInt(38383838)
-var TIPFREXLending = big.NewInt(38383838)
-var TIPFREXCancellationFee = big.NewInt(38383838)
-var TIPFREXCancellationFeeTestnet = big.NewInt(38383838)
-
-var TIPFREXTestnet = big.NewInt(38383838)
-var IsTestnet bool = false
 var Enable0xPrefix bool = false
 var StoreRewardFolder string
 var RollbackHash Hash
@@ -69,16 +55,31 @@ var TRC21IssuerSMCTestNet = HexToAddress("0x0E2C88753131CE01c7551B726b28BFD04e44
 var TRC21IssuerSMC = HexToAddress("0x8c0faeb5C6bEd2129b8674F262Fd45c4e9468bee")
 var FREXListingSMC = HexToAddress("0xDE34dD0f536170993E8CFF639DdFfCF1A85D3E53")
 var FREXListingSMCTestNet = HexToAddress("0x14B2Bf043b9c31827A472CE4F94294fE9a6277e0")
+var RelayerFeeScheduleSMC = "0x0000000000000000000000000000000000000095"
+var BlacklistSMC = "0x0000000000000000000000000000000000000096"
 var TRC21GasPriceBefore = big.NewInt(2500)
 var TRC21GasPrice = big.NewInt(250000000)
 var RateTopUp = big.NewInt(90) // 90%
 var BaseTopUp = big.NewInt(100)
 var BaseRecall = big.NewInt(100)
-var TIPTRC21Fee = big.NewInt(38383838)
-var TIPTRC21FeeTestnet = big.NewInt(38383838)
 
-// changeit-GASUPDATE
-var BlockNumberGas50x = big.NewInt(TIPTRC21Fee.Int64() + 40000000)
+var EpochPriceHistory = 6                      // number of epoch average prices kept per order book for the TWAP
+var LendingOracleMaxPriceAge = uint64(1)       // epochs a contract-set collateral price stays fresh
+var LendingOracleQuorum = 3                    // minimum number of price sources needed to reject outliers
+var LendingOracleMaxDeviation = big.NewInt(20) // maximum deviation from the median in percent
+
+var LendingOpenTermBaseRate = uint64(200000000)   // 2% a year, variable rate of an open-term lending book without borrowing demand
+var LendingOpenTermRateSlope = uint64(2000000000) // 20% a year, added to the variable rate at full utilisation
+var LendingOpenTermRecallPeriod = uint64(86400)   // seconds given to a borrower to repay a recalled open-term trade
+
+var RelayerFeeTiers = uint64(10) // maximum number of tiers of a relayer fee schedule
+var RelayerFeeVolumeEpochs = 4   // number of completed epochs of traded volume selecting a fee tier
+
+var BatchOrderMaxOps = 50 // maximum number of operations of a batch order transaction
+
+var FRExPruneEpochs = uint64(2)       // default number of recent epochs whose trading and lending states the offline pruner keeps
+var FRExPruneCheckpoints = uint64(24) // default number of older epochs whose checkpoint trading and lending states are kept
+
 var LimitTimeFinality = uint64(30) // limit in 30 block
 
 var IgnoreSignerCheckBlockArray = map[uint64]bool{
@@ -87,6 +88,9 @@ var IgnoreSignerCheckBlockArray = map[uint64]bool{
 	uint64(27307800): true,
 	uint64(28270800): true,
 }
+
+// Blacklist is the blacklist up to the blacklist contract hardfork, the block
+// of which copies it into the blacklist contract.
 var Blacklist = map[Address]bool{
 	HexToAddress("0x5248bfb72fd4f234e062d3e9bb76f08643004fcd"): true,
 	HexToAddress("0x5ac26105b35ea8935be382863a70281ec7a985e9"): true,
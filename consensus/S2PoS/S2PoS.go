@@ -17,6 +17,7 @@
 package S2PoS
 
 import (
+	"encoding/json"
 	"math/big"
 
 	"github.com/FRECNET/common"
@@ -26,6 +27,7 @@ import (
 	"github.com/FRECNET/consensus/S2PoS/utils"
 
 	"github.com/FRECNET/consensus/clique"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/ethdb"
@@ -131,6 +133,8 @@ func (x *S2PoS) Author(header *types.Header) (common.Address, error) {
 // VerifyHeader checks whether a header conforms to the consensus rules.
 func (x *S2PoS) VerifyHeader(chain consensus.ChainReader, header *types.Header, fullVerify bool) error {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.VerifyHeader(chain, header, fullVerify)
 	default: // Default "v1"
 		return x.EngineV1.VerifyHeader(chain, header, fullVerify)
 	}
@@ -140,14 +144,60 @@ func (x *S2PoS) VerifyHeader(chain consensus.ChainReader, header *types.Header,
 // method returns a quit channel to abort the operations and a results channel to
 // retrieve the async verifications (the order is that of the input slice).
 func (x *S2PoS) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, fullVerifies []bool) (chan<- struct{}, <-chan error) {
-	// TODO: (Hashlab) This funciton is a special case
-	return x.EngineV1.VerifyHeaders(chain, headers, fullVerifies)
+	if len(headers) == 0 {
+		return x.EngineV1.VerifyHeaders(chain, headers, fullVerifies)
+	}
+	first, last := headers[0].Number, headers[len(headers)-1].Number
+	if x.config.BlockConsensusVersion(last) == params.ConsensusEngineVersion1 {
+		return x.EngineV1.VerifyHeaders(chain, headers, fullVerifies)
+	}
+	if x.config.BlockConsensusVersion(first) == params.ConsensusEngineVersion2 {
+		return x.EngineV2.VerifyHeaders(chain, headers, fullVerifies)
+	}
+	// The batch crosses the switch block, the v1 engine verifies the headers up to
+	// it while the v2 engine uses them as parents of the following ones
+	var split int
+	for split < len(headers) && x.config.BlockConsensusVersion(headers[split].Number) == params.ConsensusEngineVersion1 {
+		split++
+	}
+	abortV1, resultsV1 := x.EngineV1.VerifyHeaders(chain, headers[:split], fullVerifies[:split])
+	abortV2, resultsV2 := x.EngineV2.VerifyHeaders(chain, headers, fullVerifies)
+
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+	go func() {
+		defer close(abortV1)
+		defer close(abortV2)
+		for i := range headers {
+			var err error
+			select {
+			case <-abort:
+				return
+			case err = <-resultsV2:
+			}
+			if i < split {
+				select {
+				case <-abort:
+					return
+				case err = <-resultsV1:
+				}
+			}
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
 }
 
 // VerifyUncles implements consensus.Engine, always returning an error for any
 // uncles as this consensus mechanism doesn't permit uncles.
 func (x *S2PoS) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
 	switch x.config.BlockConsensusVersion(block.Number()) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.VerifyUncles(chain, block)
 	default: // Default "v1"
 		return x.EngineV1.VerifyUncles(chain, block)
 	}
@@ -157,6 +207,8 @@ func (x *S2PoS) VerifyUncles(chain consensus.ChainReader, block *types.Block) er
 // in the header satisfies the consensus protocol requirements.
 func (x *S2PoS) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.VerifySeal(chain, header)
 	default: // Default "v1"
 		return x.EngineV1.VerifySeal(chain, header)
 	}
@@ -166,6 +218,8 @@ func (x *S2PoS) VerifySeal(chain consensus.ChainReader, header *types.Header) er
 // header for running the transactions on top.
 func (x *S2PoS) Prepare(chain consensus.ChainReader, header *types.Header) error {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.Prepare(chain, header)
 	default: // Default "v1"
 		return x.EngineV1.Prepare(chain, header)
 	}
@@ -175,15 +229,30 @@ func (x *S2PoS) Prepare(chain consensus.ChainReader, header *types.Header) error
 // rewards given, and returns the final block.
 func (x *S2PoS) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, parentState *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.Finalize(chain, header, state, parentState, txs, uncles, receipts)
 	default: // Default "v1"
 		return x.EngineV1.Finalize(chain, header, state, parentState, txs, uncles, receipts)
 	}
 }
 
+// GetRewards returns the rewards distributed while finalizing the block, or nil
+// if it isn't a reward checkpoint.
+func (x *S2PoS) GetRewards(header *types.Header) map[string]interface{} {
+	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.GetRewards(header)
+	default: // Default "v1"
+		return x.EngineV1.GetRewards(header)
+	}
+}
+
 // Seal implements consensus.Engine, attempting to create a sealed block using
 // the local signing credentials.
 func (x *S2PoS) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
 	switch x.config.BlockConsensusVersion(block.Number()) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.Seal(chain, block, stop)
 	default: // Default "v1"
 		return x.EngineV1.Seal(chain, block, stop)
 	}
@@ -193,7 +262,9 @@ func (x *S2PoS) Seal(chain consensus.ChainReader, block *types.Block, stop <-cha
 // that a new block should have based on the previous blocks in the chain and the
 // current signer.
 func (x *S2PoS) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
-	switch x.config.BlockConsensusVersion(parent.Number) {
+	switch x.config.BlockConsensusVersion(new(big.Int).Add(parent.Number, common.Big1)) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.CalcDifficulty(chain, time, parent)
 	default: // Default "v1"
 		return x.EngineV1.CalcDifficulty(chain, time, parent)
 	}
@@ -208,6 +279,7 @@ func (x *S2PoS) CalcDifficulty(chain consensus.ChainReader, time uint64, parent
 func (x *S2PoS) Authorize(signer common.Address, signFn clique.SignerFn) {
 	// Authorize each consensus individually
 	x.EngineV1.Authorize(signer, signFn)
+	x.EngineV2.Authorize(signer, signFn)
 }
 
 func (x *S2PoS) GetPeriod() uint64 {
@@ -216,6 +288,8 @@ func (x *S2PoS) GetPeriod() uint64 {
 
 func (x *S2PoS) IsAuthorisedAddress(header *types.Header, chain consensus.ChainReader, address common.Address) bool {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.IsAuthorisedAddress(header, chain, address)
 	default: // Default "v1"
 		return x.EngineV1.IsAuthorisedAddress(header, chain, address)
 	}
@@ -223,13 +297,17 @@ func (x *S2PoS) IsAuthorisedAddress(header *types.Header, chain consensus.ChainR
 
 func (x *S2PoS) GetMasternodes(chain consensus.ChainReader, header *types.Header) []common.Address {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.GetMasternodes(chain, header)
 	default: // Default "v1"
 		return x.EngineV1.GetMasternodes(chain, header)
 	}
 }
 
 func (x *S2PoS) YourTurn(chain consensus.ChainReader, parent *types.Header, signer common.Address) (int, int, int, bool, error) {
-	switch x.config.BlockConsensusVersion(parent.Number) {
+	switch x.config.BlockConsensusVersion(new(big.Int).Add(parent.Number, common.Big1)) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.YourTurn(chain, parent, signer)
 	default: // Default "v1"
 		return x.EngineV1.YourTurn(chain, parent, signer)
 	}
@@ -237,13 +315,26 @@ func (x *S2PoS) YourTurn(chain consensus.ChainReader, parent *types.Header, sign
 
 func (x *S2PoS) GetValidator(creator common.Address, chain consensus.ChainReader, header *types.Header) (common.Address, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		// v2 blocks are only signed by their creator
+		return creator, nil
 	default: // Default "v1"
 		return x.EngineV1.GetValidator(creator, chain, header)
 	}
 }
 
 func (x *S2PoS) UpdateMasternodes(chain consensus.ChainReader, header *types.Header, ms []utils.Masternode) error {
+	// The candidates picked at the gap block are used by the next checkpoint,
+	// which may already be handled by the v2 engine
+	nextCheckpoint := new(big.Int).Add(header.Number, new(big.Int).SetUint64(x.config.Gap))
+	if x.config.BlockConsensusVersion(nextCheckpoint) == params.ConsensusEngineVersion2 {
+		if err := x.EngineV2.UpdateMasternodes(chain, header, ms); err != nil {
+			return err
+		}
+	}
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return nil
 	default: // Default "v1"
 		return x.EngineV1.UpdateMasternodes(chain, header, ms)
 	}
@@ -251,6 +342,8 @@ func (x *S2PoS) UpdateMasternodes(chain consensus.ChainReader, header *types.Hea
 
 func (x *S2PoS) RecoverSigner(header *types.Header) (common.Address, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.RecoverSigner(header)
 	default: // Default "v1"
 		return x.EngineV1.RecoverSigner(header)
 	}
@@ -258,6 +351,8 @@ func (x *S2PoS) RecoverSigner(header *types.Header) (common.Address, error) {
 
 func (x *S2PoS) RecoverValidator(header *types.Header) (common.Address, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.RecoverValidator(header)
 	default: // Default "v1"
 		return x.EngineV1.RecoverValidator(header)
 	}
@@ -266,6 +361,8 @@ func (x *S2PoS) RecoverValidator(header *types.Header) (common.Address, error) {
 // Get master nodes over extra data of previous checkpoint block.
 func (x *S2PoS) GetMasternodesFromCheckpointHeader(preCheckpointHeader *types.Header, n, e uint64) []common.Address {
 	switch x.config.BlockConsensusVersion(preCheckpointHeader.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.GetMasternodesFromCheckpointHeader(preCheckpointHeader, n, e)
 	default: // Default "v1"
 		return x.EngineV1.GetMasternodesFromCheckpointHeader(preCheckpointHeader, n, e)
 	}
@@ -278,6 +375,16 @@ func (x *S2PoS) GetDb() ethdb.Database {
 
 func (x *S2PoS) GetSnapshot(chain consensus.ChainReader, header *types.Header) (*utils.PublicApiSnapshot, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		signers := make(map[common.Address]struct{})
+		for _, masternode := range x.EngineV2.GetMasternodes(chain, header) {
+			signers[masternode] = struct{}{}
+		}
+		return &utils.PublicApiSnapshot{
+			Number:  header.Number.Uint64(),
+			Hash:    header.Hash(),
+			Signers: signers,
+		}, nil
 	default: // Default "v1"
 		sp, err := x.EngineV1.GetSnapshot(chain, header)
 		// Convert to a standard PublicApiSnapshot type, otherwise it's a breaking change to API
@@ -294,11 +401,149 @@ func (x *S2PoS) GetSnapshot(chain consensus.ChainReader, header *types.Header) (
 
 func (x *S2PoS) GetAuthorisedSignersFromSnapshot(chain consensus.ChainReader, header *types.Header) ([]common.Address, error) {
 	switch x.config.BlockConsensusVersion(header.Number) {
+	case params.ConsensusEngineVersion2:
+		return x.EngineV2.GetMasternodes(chain, header), nil
 	default: // Default "v1"
 		return x.EngineV1.GetAuthorisedSignersFromSnapshot(chain, header)
 	}
 }
 
+/*
+	S2PoS v2 BFT messages
+*/
+
+// ProposalParentHash returns the block the next v2 block has to be built on.
+func (x *S2PoS) ProposalParentHash() (common.Hash, bool) {
+	return x.EngineV2.ProposalParentHash()
+}
+
+// GetRoundInfo returns the round state of the v2 engine.
+func (x *S2PoS) GetRoundInfo() *utils.PublicApiRoundInfo {
+	return x.EngineV2.GetRoundInfo()
+}
+
+// GetCommittedQuorumCert returns the quorum certificate recorded when the v2
+// engine committed the block.
+func (x *S2PoS) GetCommittedQuorumCert(hash common.Hash) (*utils.QuorumCert, error) {
+	return x.EngineV2.GetCommittedQuorumCert(hash)
+}
+
+// StorePenaltyJournal records why the masternodes are penalized at the
+// checkpoint block built on top of the given parent.
+func (x *S2PoS) StorePenaltyJournal(number uint64, parentHash common.Hash, penalties []*utils.PenaltyRecord) error {
+	if penalties == nil {
+		penalties = []*utils.PenaltyRecord{}
+	}
+	data, err := json.Marshal(penalties)
+	if err != nil {
+		return err
+	}
+	return rawdb.WritePenaltyJournal(x.db, number, parentHash, data)
+}
+
+// GetPenaltyReport retrieves the penalty journal of the canonical checkpoint
+// block of an epoch.
+func (x *S2PoS) GetPenaltyReport(chain consensus.ChainReader, epoch uint64) (*utils.PenaltyReport, error) {
+	number := epoch * x.config.Epoch
+	header := chain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, utils.ErrUnknownBlock
+	}
+	data := rawdb.ReadPenaltyJournal(x.db, number, header.ParentHash)
+	if len(data) == 0 {
+		return nil, utils.ErrNoPenaltyJournal
+	}
+	report := &utils.PenaltyReport{
+		Epoch:  epoch,
+		Number: number,
+		Hash:   header.Hash(),
+	}
+	if err := json.Unmarshal(data, &report.Penalties); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// StoreRandomizeJournal records the participation of the masternodes in the
+// randomization of the checkpoint block built on top of the given parent.
+func (x *S2PoS) StoreRandomizeJournal(number uint64, parentHash common.Hash, records []*utils.RandomizeRecord) error {
+	if records == nil {
+		records = []*utils.RandomizeRecord{}
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return rawdb.WriteRandomizeJournal(x.db, number, parentHash, data)
+}
+
+// GetRandomizeReport retrieves the randomize journal of the canonical
+// checkpoint block of an epoch, along with the quality of its randomness.
+func (x *S2PoS) GetRandomizeReport(chain consensus.ChainReader, epoch uint64) (*utils.RandomizeReport, error) {
+	number := epoch * x.config.Epoch
+	header := chain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, utils.ErrUnknownBlock
+	}
+	data := rawdb.ReadRandomizeJournal(x.db, number, header.ParentHash)
+	if len(data) == 0 {
+		return nil, utils.ErrNoRandomizeJournal
+	}
+	report := &utils.RandomizeReport{
+		Epoch:  epoch,
+		Number: number,
+		Hash:   header.Hash(),
+	}
+	if err := json.Unmarshal(data, &report.Records); err != nil {
+		return nil, err
+	}
+	for _, record := range report.Records {
+		if record.Revealed {
+			report.Revealed++
+		}
+		if record.Fallback {
+			report.Fallbacks++
+		}
+	}
+	if len(report.Records) > 0 {
+		report.Quality = float64(report.Revealed) / float64(len(report.Records))
+	}
+	return report, nil
+}
+
+// IsV2Block reports whether the block is produced by the v2 engine.
+func (x *S2PoS) IsV2Block(number *big.Int) bool {
+	return x.config.BlockConsensusVersion(number) == params.ConsensusEngineVersion2
+}
+
+func (x *S2PoS) HandleProposedBlock(chain consensus.ChainReader, header *types.Header) error {
+	return x.EngineV2.ProposedBlockHandler(chain, header)
+}
+
+func (x *S2PoS) VerifyVoteMessage(chain consensus.ChainReader, vote *utils.Vote) (bool, error) {
+	return x.EngineV2.VerifyVoteMessage(chain, vote)
+}
+
+func (x *S2PoS) HandleVote(chain consensus.ChainReader, vote *utils.Vote) error {
+	return x.EngineV2.VoteHandler(chain, vote)
+}
+
+func (x *S2PoS) VerifyTimeoutMessage(chain consensus.ChainReader, timeout *utils.Timeout) (bool, error) {
+	return x.EngineV2.VerifyTimeoutMessage(chain, timeout)
+}
+
+func (x *S2PoS) HandleTimeout(chain consensus.ChainReader, timeout *utils.Timeout) error {
+	return x.EngineV2.TimeoutHandler(chain, timeout)
+}
+
+func (x *S2PoS) VerifySyncInfoMessage(chain consensus.ChainReader, syncInfo *utils.SyncInfo) (bool, error) {
+	return x.EngineV2.VerifySyncInfoMessage(chain, syncInfo)
+}
+
+func (x *S2PoS) HandleSyncInfo(chain consensus.ChainReader, syncInfo *utils.SyncInfo) error {
+	return x.EngineV2.SyncInfoHandler(chain, syncInfo)
+}
+
 /**
 Caching
 */
@@ -1,13 +1,33 @@
 package S2PoS
 
 import (
+	"math/big"
 	"testing"
 
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS/utils"
 	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/params"
 	"github.com/stretchr/testify/assert"
 )
 
+// checkpointChain is a chain reader serving canonical headers by number only.
+type checkpointChain struct {
+	headers map[uint64]*types.Header
+}
+
+func (c *checkpointChain) Config() *params.ChainConfig  { return params.TestS2PoSMockChainConfig }
+func (c *checkpointChain) CurrentHeader() *types.Header { return nil }
+func (c *checkpointChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return nil
+}
+func (c *checkpointChain) GetHeaderByNumber(number uint64) *types.Header  { return c.headers[number] }
+func (c *checkpointChain) GetHeaderByHash(hash common.Hash) *types.Header { return nil }
+func (c *checkpointChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
 func TestAdaptorShouldShareDbWithV1Engine(t *testing.T) {
 	database := rawdb.NewMemoryDatabase()
 	config := params.TestS2PoSMockChainConfig.S2PoS
@@ -16,3 +36,69 @@ func TestAdaptorShouldShareDbWithV1Engine(t *testing.T) {
 	assert := assert.New(t)
 	assert.Equal(engine.EngineV1.GetDb(), engine.GetDb())
 }
+
+func TestPenaltyReport(t *testing.T) {
+	database := rawdb.NewMemoryDatabase()
+	config := params.TestS2PoSMockChainConfig.S2PoS
+	engine := New(config, database)
+
+	assert := assert.New(t)
+	number := 2 * config.Epoch
+	checkpoint := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: common.HexToHash("0x01")}
+	chain := &checkpointChain{headers: map[uint64]*types.Header{number: checkpoint}}
+
+	_, err := engine.GetPenaltyReport(chain, 2)
+	assert.Equal(utils.ErrNoPenaltyJournal, err)
+	_, err = engine.GetPenaltyReport(chain, 3)
+	assert.Equal(utils.ErrUnknownBlock, err)
+
+	penalty := &utils.PenaltyRecord{
+		Address:        common.HexToAddress("0x02"),
+		Reason:         utils.PenaltyNoBlock,
+		BlocksRequired: common.MinimunMinerBlockPerEpoch,
+		ComebackEpoch:  2 + common.LimitPenaltyEpoch + 1,
+	}
+	// A journal computed on top of a side chain parent isn't reported
+	assert.Nil(engine.StorePenaltyJournal(number, common.HexToHash("0x03"), nil))
+	assert.Nil(engine.StorePenaltyJournal(number, checkpoint.ParentHash, []*utils.PenaltyRecord{penalty}))
+
+	report, err := engine.GetPenaltyReport(chain, 2)
+	assert.Nil(err)
+	assert.Equal(uint64(2), report.Epoch)
+	assert.Equal(number, report.Number)
+	assert.Equal(checkpoint.Hash(), report.Hash)
+	assert.Equal([]*utils.PenaltyRecord{penalty}, report.Penalties)
+}
+
+func TestRandomizeReport(t *testing.T) {
+	database := rawdb.NewMemoryDatabase()
+	config := params.TestS2PoSMockChainConfig.S2PoS
+	engine := New(config, database)
+
+	assert := assert.New(t)
+	number := 2 * config.Epoch
+	checkpoint := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: common.HexToHash("0x01")}
+	chain := &checkpointChain{headers: map[uint64]*types.Header{number: checkpoint}}
+
+	_, err := engine.GetRandomizeReport(chain, 2)
+	assert.Equal(utils.ErrNoRandomizeJournal, err)
+	_, err = engine.GetRandomizeReport(chain, 3)
+	assert.Equal(utils.ErrUnknownBlock, err)
+
+	records := []*utils.RandomizeRecord{
+		{Address: common.HexToAddress("0x02"), Secret: true, Opening: true, Revealed: true},
+		{Address: common.HexToAddress("0x03"), Secret: true, Fallback: true},
+		{Address: common.HexToAddress("0x04"), Secret: true, Opening: true, Revealed: true},
+		{Address: common.HexToAddress("0x05"), Fallback: true},
+	}
+	assert.Nil(engine.StoreRandomizeJournal(number, checkpoint.ParentHash, records))
+
+	report, err := engine.GetRandomizeReport(chain, 2)
+	assert.Nil(err)
+	assert.Equal(number, report.Number)
+	assert.Equal(checkpoint.Hash(), report.Hash)
+	assert.Equal(2, report.Revealed)
+	assert.Equal(2, report.Fallbacks)
+	assert.Equal(0.5, report.Quality)
+	assert.Equal(records, report.Records)
+}
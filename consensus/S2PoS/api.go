@@ -95,7 +95,7 @@ func (api *API) NetworkInformation() NetworkInformation {
 	info := NetworkInformation{}
 	info.NetworkId = api.chain.Config().ChainId
 	info.FREValidatorAddress = common.HexToAddress(common.MasternodeVotingSMC)
-	if common.IsTestnet {
+	if api.chain.Config().S2PoS.Testnet {
 		info.LendingAddress = common.HexToAddress(common.LendingRegistrationSMCTestnet)
 		info.RelayerRegistrationAddress = common.HexToAddress(common.RelayerRegistrationSMCTestnet)
 		info.FREXListingAddress = common.FREXListingSMCTestNet
@@ -108,3 +108,31 @@ func (api *API) NetworkInformation() NetworkInformation {
 	}
 	return info
 }
+
+// GetRoundInfo retrieves the round state of the v2 consensus engine.
+func (api *API) GetRoundInfo() *utils.PublicApiRoundInfo {
+	return api.S2PoS.GetRoundInfo()
+}
+
+// GetCommittedQC retrieves the quorum certificate which made the block final.
+func (api *API) GetCommittedQC(hash common.Hash) (*utils.QuorumCert, error) {
+	return api.S2PoS.GetCommittedQuorumCert(hash)
+}
+
+// GetPenaltyReport retrieves the reasons the masternodes were penalized at the
+// checkpoint of an epoch.
+func (api *API) GetPenaltyReport(epoch rpc.EpochNumber) (*utils.PenaltyReport, error) {
+	if epoch == rpc.LatestEpochNumber {
+		return api.S2PoS.GetPenaltyReport(api.chain, api.chain.CurrentHeader().Number.Uint64()/api.chain.Config().S2PoS.Epoch)
+	}
+	return api.S2PoS.GetPenaltyReport(api.chain, uint64(epoch.Int64()))
+}
+
+// GetRandomizeReport retrieves the participation of the masternodes in the
+// randomization of the M2 assignment at the checkpoint of an epoch.
+func (api *API) GetRandomizeReport(epoch rpc.EpochNumber) (*utils.RandomizeReport, error) {
+	if epoch == rpc.LatestEpochNumber {
+		return api.S2PoS.GetRandomizeReport(api.chain, api.chain.CurrentHeader().Number.Uint64()/api.chain.Config().S2PoS.Epoch)
+	}
+	return api.S2PoS.GetRandomizeReport(api.chain, uint64(epoch.Int64()))
+}
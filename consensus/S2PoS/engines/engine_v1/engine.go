@@ -2,13 +2,10 @@ package engine_v1
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"math/rand"
-	"path/filepath"
 	"sync"
 	"time"
 
@@ -63,6 +60,9 @@ type S2PoS_v1 struct {
 	signatures          *lru.ARCCache // Signatures of recent blocks to speed up mining
 	validatorSignatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 	verifiedHeaders     *lru.ARCCache
+	rewards             *lru.ARCCache           // Rewards of recently finalized blocks, keyed by hash without validator signature
+	proposerSchedules   *lru.ARCCache           // Stake-weighted proposer schedules of recent epochs, keyed by checkpoint hash
+	epochCheckpoints    *lru.ARCCache           // Epoch checkpoints of recent blocks, keyed by block hash
 	proposals           map[common.Address]bool // Current list of proposals we are pushing
 
 	signer common.Address  // Ethereum address of the signing key
@@ -70,12 +70,13 @@ type S2PoS_v1 struct {
 	lock   sync.RWMutex    // Protects the signer fields
 
 	HookReward            func(chain consensus.ChainReader, state *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{})
-	HookPenalty           func(chain consensus.ChainReader, blockNumberEpoc uint64) ([]common.Address, error)
+	HookPenalty           func(chain consensus.ChainReader, blockNumberEpoc uint64, parentHash common.Hash) ([]common.Address, error)
 	HookPenaltyTIPSigning func(chain consensus.ChainReader, header *types.Header, candidate []common.Address) ([]common.Address, error)
 	HookValidator         func(header *types.Header, signers []common.Address) ([]byte, error)
 	HookVerifyMNs         func(header *types.Header, signers []common.Address) error
 
 	HookGetSignersFromContract func(blockHash common.Hash) ([]common.Address, error)
+	HookGetMasternodesStakes   func(header *types.Header, masternodes []common.Address) ([]*big.Int, error)
 }
 
 // New creates a S2PoS solid-state-proof-of-stake consensus engine with the initial
@@ -91,6 +92,9 @@ func New(config *params.S2PoSConfig, db ethdb.Database) *S2PoS_v1 {
 	signatures, _ := lru.NewARC(utils.InmemorySnapshots)
 	validatorSignatures, _ := lru.NewARC(utils.InmemorySnapshots)
 	verifiedHeaders, _ := lru.NewARC(utils.InmemorySnapshots)
+	rewards, _ := lru.NewARC(utils.InmemoryRewards)
+	proposerSchedules, _ := lru.NewARC(utils.InmemorySnapshots)
+	epochCheckpoints, _ := lru.NewARC(utils.InmemorySnapshots)
 	return &S2PoS_v1{
 		config: &conf,
 		db:     db,
@@ -99,6 +103,9 @@ func New(config *params.S2PoSConfig, db ethdb.Database) *S2PoS_v1 {
 		signatures:          signatures,
 		verifiedHeaders:     verifiedHeaders,
 		validatorSignatures: validatorSignatures,
+		rewards:             rewards,
+		proposerSchedules:   proposerSchedules,
+		epochCheckpoints:    epochCheckpoints,
 		proposals:           make(map[common.Address]bool),
 	}
 }
@@ -156,7 +163,7 @@ func (x *S2PoS_v1) verifyHeader(chain consensus.ChainReader, header *types.Heade
 	if x.config.SkipValidation {
 		return nil
 	}
-	if common.IsTestnet {
+	if x.config.Testnet {
 		fullVerify = false
 	}
 	if header.Number == nil {
@@ -284,7 +291,7 @@ func (x *S2PoS_v1) checkSignersOnCheckpoint(chain consensus.ChainReader, header
 		if chain.Config().IsTIPSigning(header.Number) {
 			penPenalties, err = x.HookPenaltyTIPSigning(chain, header, signers)
 		} else {
-			penPenalties, err = x.HookPenalty(chain, number)
+			penPenalties, err = x.HookPenalty(chain, number, header.ParentHash)
 		}
 		if err != nil {
 			return err
@@ -317,10 +324,43 @@ func (x *S2PoS_v1) checkSignersOnCheckpoint(chain consensus.ChainReader, header
 			return err
 		}
 	}
+	if x.HookGetMasternodesStakes != nil && chain.Config().IsTIPStakeWeightedProposer(header.Number) {
+		// The stakes follow the order of the masternodes in the header
+		stakes, err := x.checkpointStakes(chain, header, utils.GetMasternodesFromCheckpointHeader(header))
+		if err != nil {
+			return err
+		}
+		committed, err := utils.GetStakesFromCheckpointHeader(header)
+		if err != nil {
+			return err
+		}
+		for i := range stakes {
+			if stakes[i].Cmp(committed[i]) != 0 {
+				return utils.ErrInvalidCheckpointStakes
+			}
+		}
+	}
 
 	return nil
 }
 
+// checkpointStakes returns the stakes of the masternodes of a checkpoint at the
+// gap block they were picked at, in the order of the masternodes.
+func (x *S2PoS_v1) checkpointStakes(chain consensus.ChainReader, checkpoint *types.Header, masternodes []common.Address) ([]*big.Int, error) {
+	gapHeader, err := x.gapHeader(chain, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	stakes, err := x.HookGetMasternodesStakes(gapHeader, masternodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(stakes) != len(masternodes) {
+		return nil, utils.ErrInvalidCheckpointStakes
+	}
+	return stakes, nil
+}
+
 func (x *S2PoS_v1) IsAuthorisedAddress(header *types.Header, chain consensus.ChainReader, address common.Address) bool {
 	snap, err := x.GetSnapshot(chain, header)
 	if err != nil {
@@ -392,7 +432,22 @@ func whoIsCreator(snap *SnapshotV1, header *types.Header) (common.Address, error
 }
 
 func (x *S2PoS_v1) YourTurn(chain consensus.ChainReader, parent *types.Header, signer common.Address) (int, int, int, bool, error) {
-	masternodes := x.GetMasternodes(chain, parent)
+	return x.yourTurn(chain, parent, nil, signer)
+}
+
+// yourTurn is YourTurn for a parent that may not be in the database yet, in
+// which case its ancestors not yet written are passed in ascending order.
+func (x *S2PoS_v1) yourTurn(chain consensus.ChainReader, parent *types.Header, parents []*types.Header, signer common.Address) (int, int, int, bool, error) {
+	proposer, checkpoint, err := x.scheduledProposer(chain, parent, parents)
+	if err != nil {
+		return 0, -1, -1, false, err
+	}
+	var masternodes []common.Address
+	if checkpoint != nil {
+		masternodes = x.GetMasternodesFromCheckpointHeader(checkpoint, parent.Number.Uint64(), x.config.Epoch)
+	} else {
+		masternodes = x.GetMasternodes(chain, parent)
+	}
 
 	// if common.IsTestnet {
 	// 	// Only three mns hard code for FRE testnet.
@@ -403,7 +458,7 @@ func (x *S2PoS_v1) YourTurn(chain consensus.ChainReader, parent *types.Header, s
 	// 	}
 	// }
 
-	snap, err := x.GetSnapshot(chain, parent)
+	snap, err := x.snapshot(chain, parent.Number.Uint64(), parent.Hash(), parents, parent)
 	if err != nil {
 		log.Warn("Failed when trying to commit new work", "err", err)
 		return 0, -1, -1, false, err
@@ -421,6 +476,10 @@ func (x *S2PoS_v1) YourTurn(chain consensus.ChainReader, parent *types.Header, s
 		}
 		preIndex = position(masternodes, pre)
 	}
+	if checkpoint != nil {
+		// Turns start over from the scheduled proposer, followed by its backups
+		preIndex = (position(masternodes, proposer) + len(masternodes) - 1) % len(masternodes)
+	}
 	curIndex := position(masternodes, signer)
 	if signer == x.signer {
 		log.Debug("Masternodes cycle info", "number of masternodes", len(masternodes), "previous", pre, "position", preIndex, "current", signer, "position", curIndex)
@@ -434,6 +493,95 @@ func (x *S2PoS_v1) YourTurn(chain consensus.ChainReader, parent *types.Header, s
 	return len(masternodes), preIndex, curIndex, false, nil
 }
 
+// ancestor returns the header with the given hash and number, looking it up in
+// the ascending batch of parents being verified before the database.
+func ancestor(chain consensus.ChainReader, parents []*types.Header, hash common.Hash, number uint64) *types.Header {
+	if len(parents) > 0 {
+		if first := parents[0].Number.Uint64(); number >= first && number-first < uint64(len(parents)) {
+			if header := parents[number-first]; header.Hash() == hash {
+				return header
+			}
+		}
+	}
+	return chain.GetHeader(hash, number)
+}
+
+// epochCheckpoint returns the checkpoint of the epoch of header, found through
+// its ancestry rather than the canonical chain, so that side chains and batches
+// of headers not yet written resolve their own checkpoint.
+func (x *S2PoS_v1) epochCheckpoint(chain consensus.ChainReader, header *types.Header, parents []*types.Header) (*types.Header, error) {
+	var (
+		walked     []common.Hash
+		checkpoint = header
+	)
+	for checkpoint.Number.Uint64()%x.config.Epoch != 0 {
+		hash := checkpoint.Hash()
+		if cached, ok := x.epochCheckpoints.Get(hash); ok {
+			checkpoint = cached.(*types.Header)
+			break
+		}
+		walked = append(walked, hash)
+		if checkpoint = ancestor(chain, parents, checkpoint.ParentHash, checkpoint.Number.Uint64()-1); checkpoint == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+	}
+	for _, hash := range walked {
+		x.epochCheckpoints.Add(hash, checkpoint)
+	}
+	return checkpoint, nil
+}
+
+// scheduledProposer returns the masternode drawn to seal the block after parent
+// out of the proposer schedule of the epoch of parent, along with the epoch
+// checkpoint, or a nil checkpoint if the epoch takes turns instead. The schedule
+// is seeded by the randomizes the masternodes revealed for the checkpoint, so
+// that it can't be known beforehand nor ground by its proposer, and weighted
+// by the stakes the checkpoint commits to.
+func (x *S2PoS_v1) scheduledProposer(chain consensus.ChainReader, parent *types.Header, parents []*types.Header) (common.Address, *types.Header, error) {
+	if !chain.Config().IsTIPStakeWeightedProposer(new(big.Int).Add(parent.Number, common.Big1)) {
+		return common.Address{}, nil, nil
+	}
+	checkpoint, err := x.epochCheckpoint(chain, parent, parents)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	// Epochs opened before the fork don't commit to stakes
+	if checkpoint.Number.Sign() == 0 || !chain.Config().IsTIPStakeWeightedProposer(checkpoint.Number) {
+		return common.Address{}, nil, nil
+	}
+	n, e := parent.Number.Uint64(), x.config.Epoch
+	if schedule, ok := x.proposerSchedules.Get(checkpoint.Hash()); ok {
+		return schedule.([]common.Address)[n%e], checkpoint, nil
+	}
+	masternodes := utils.GetMasternodesFromCheckpointHeader(checkpoint)
+	if len(masternodes) == 0 {
+		return common.Address{}, nil, errors.New("Masternodes not found")
+	}
+	stakes, err := utils.GetStakesFromCheckpointHeader(checkpoint)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	ms := make([]utils.Masternode, len(masternodes))
+	for i, m := range masternodes {
+		ms[i] = utils.Masternode{Address: m, Stake: stakes[i]}
+	}
+	schedule := utils.ProposerSchedule(ms, utils.RandomizeSeed(checkpoint), int(e))
+	x.proposerSchedules.Add(checkpoint.Hash(), schedule)
+	return schedule[n%e], checkpoint, nil
+}
+
+// gapHeader returns the block the masternodes of a checkpoint were picked at,
+// Gap blocks before it on its ancestry.
+func (x *S2PoS_v1) gapHeader(chain consensus.ChainReader, checkpoint *types.Header) (*types.Header, error) {
+	header := checkpoint
+	for step := uint64(1); step <= x.config.Gap && header.Number.Uint64() > 0; step++ {
+		if header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+	}
+	return header, nil
+}
+
 // snapshot retrieves the authorization snapshot at a given point in time.
 func (x *S2PoS_v1) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header, selfHeader *types.Header) (*SnapshotV1, error) {
 	// Search for a SnapshotV1 in memory or on disk for checkpoints
@@ -559,7 +707,10 @@ func (x *S2PoS_v1) verifySeal(chain consensus.ChainReader, header *types.Header,
 	} else {
 		parent = chain.GetHeader(header.ParentHash, number-1)
 	}
-	difficulty := x.calcDifficulty(chain, parent, creator)
+	difficulty, err := x.calcDifficulty(chain, parent, parents, creator)
+	if err != nil {
+		return err
+	}
 	log.Debug("verify seal block", "number", header.Number, "hash", header.Hash(), "block difficulty", header.Difficulty, "calc difficulty", difficulty, "creator", creator)
 	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
 	if number > 0 {
@@ -689,7 +840,10 @@ func (x *S2PoS_v1) Prepare(chain consensus.ChainReader, header *types.Header) er
 		return consensus.ErrUnknownAncestor
 	}
 	// Set the correct difficulty
-	header.Difficulty = x.calcDifficulty(chain, parent, x.signer)
+	header.Difficulty, err = x.calcDifficulty(chain, parent, nil, x.signer)
+	if err != nil {
+		return err
+	}
 	log.Debug("CalcDifficulty ", "number", header.Number, "difficulty", header.Difficulty)
 	// Ensure the extra data has all it's components
 	if len(header.Extra) < utils.ExtraVanity {
@@ -704,7 +858,7 @@ func (x *S2PoS_v1) Prepare(chain consensus.ChainReader, header *types.Header) er
 			if chain.Config().IsTIPSigning(header.Number) {
 				penMasternodes, err = x.HookPenaltyTIPSigning(chain, header, masternodes)
 			} else {
-				penMasternodes, err = x.HookPenalty(chain, number)
+				penMasternodes, err = x.HookPenalty(chain, number, header.ParentHash)
 			}
 			if err != nil {
 				return err
@@ -734,6 +888,13 @@ func (x *S2PoS_v1) Prepare(chain consensus.ChainReader, header *types.Header) er
 			}
 			header.Validators = validators
 		}
+		if x.HookGetMasternodesStakes != nil && chain.Config().IsTIPStakeWeightedProposer(header.Number) {
+			stakes, err := x.checkpointStakes(chain, header, masternodes)
+			if err != nil {
+				return err
+			}
+			header.Validators = append(header.Validators, utils.EncodeCheckpointStakes(stakes)...)
+		}
 	}
 	header.Extra = append(header.Extra, make([]byte, utils.ExtraSeal)...)
 
@@ -780,20 +941,12 @@ func (x *S2PoS_v1) Finalize(chain consensus.ChainReader, header *types.Header, s
 
 	// _ = c.CacheData(header, txs, receipts)
 
+	var rewards map[string]interface{}
 	if x.HookReward != nil && number%rCheckpoint == 0 {
-		err, rewards := x.HookReward(chain, state, parentState, header)
-		if err != nil {
+		var err error
+		if err, rewards = x.HookReward(chain, state, parentState, header); err != nil {
 			return nil, err
 		}
-		if len(common.StoreRewardFolder) > 0 {
-			data, err := json.Marshal(rewards)
-			if err == nil {
-				err = ioutil.WriteFile(filepath.Join(common.StoreRewardFolder, header.Number.String()+"."+header.Hash().Hex()), data, 0644)
-			}
-			if err != nil {
-				log.Error("Error when save reward info ", "number", header.Number, "hash", header.Hash().Hex(), "err", err)
-			}
-		}
 	}
 
 	// the state remains as is and uncles are dropped
@@ -801,7 +954,25 @@ func (x *S2PoS_v1) Finalize(chain consensus.ChainReader, header *types.Header, s
 	header.UncleHash = types.CalcUncleHash(nil)
 
 	// Assemble and return the final block for sealing
-	return types.NewBlock(header, txs, nil, receipts), nil
+	block := types.NewBlock(header, txs, nil, receipts)
+
+	// Keep the rewards until the chain writes the block. They are keyed by the
+	// hash without the validator signature, the fetcher finalizes the blocks
+	// missing it before the signature is added. The seal changes that hash of
+	// a block being mined, Seal moves its rewards
+	if rewards != nil {
+		x.rewards.Add(block.HashNoValidator(), rewards)
+	}
+	return block, nil
+}
+
+// GetRewards returns the rewards distributed by the finalized block, or nil if
+// it isn't a reward checkpoint.
+func (x *S2PoS_v1) GetRewards(header *types.Header) map[string]interface{} {
+	if rewards, ok := x.rewards.Get(header.HashNoValidator()); ok {
+		return rewards.(map[string]interface{})
+	}
+	return nil
 }
 
 // Authorize injects a private key into the consensus engine to mint new blocks
@@ -888,26 +1059,49 @@ func (x *S2PoS_v1) Seal(chain consensus.ChainReader, block *types.Block, stop <-
 	if m2 == signer {
 		header.Validator = sighash
 	}
-	return block.WithSeal(header), nil
+	sealed := block.WithSeal(header)
+	x.moveRewards(block.HashNoValidator(), sealed.HashNoValidator())
+	return sealed, nil
+}
+
+// moveRewards keys the rewards of a block finalized before sealing by the hash
+// of the sealed block.
+func (x *S2PoS_v1) moveRewards(unsealed, sealed common.Hash) {
+	if rewards, ok := x.rewards.Get(unsealed); ok {
+		x.rewards.Remove(unsealed)
+		x.rewards.Add(sealed, rewards)
+	}
 }
 
 // CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
 // that a new block should have based on the previous blocks in the chain and the
 // current signer.
 func (x *S2PoS_v1) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
-	return x.calcDifficulty(chain, parent, x.signer)
+	difficulty, err := x.calcDifficulty(chain, parent, nil, x.signer)
+	if err != nil {
+		log.Warn("Failed to calculate difficulty", "number", parent.Number.Uint64()+1, "err", err)
+		return new(big.Int)
+	}
+	return difficulty
 }
 
-func (x *S2PoS_v1) calcDifficulty(chain consensus.ChainReader, parent *types.Header, signer common.Address) *big.Int {
+// calcDifficulty returns the difficulty of the block the signer seals on top of
+// parent. The ancestors of parent not yet in the database, if any, are passed in
+// ascending order.
+func (x *S2PoS_v1) calcDifficulty(chain consensus.ChainReader, parent *types.Header, parents []*types.Header, signer common.Address) (*big.Int, error) {
 	// If we're running a engine faking, skip calculation
 	if x.config.SkipValidation {
-		return big.NewInt(1)
+		return big.NewInt(1), nil
+	}
+	// The fallback below predates the proposer schedule, whose errors are real
+	if _, _, err := x.scheduledProposer(chain, parent, parents); err != nil {
+		return nil, err
 	}
-	len, preIndex, curIndex, _, err := x.YourTurn(chain, parent, signer)
+	len, preIndex, curIndex, _, err := x.yourTurn(chain, parent, parents, signer)
 	if err != nil {
-		return big.NewInt(int64(len + curIndex - preIndex))
+		return big.NewInt(int64(len + curIndex - preIndex)), nil
 	}
-	return big.NewInt(int64(len - utils.Hop(len, preIndex, curIndex)))
+	return big.NewInt(int64(len - utils.Hop(len, preIndex, curIndex))), nil
 }
 
 func (x *S2PoS_v1) RecoverSigner(header *types.Header) (common.Address, error) {
@@ -986,7 +1180,8 @@ func GetM1M2FromCheckpointHeader(checkpointHeader *types.Header, currentHeader *
 	}
 	// Get signers from this block.
 	masternodes := GetMasternodesFromCheckpointHeader(checkpointHeader)
-	validators := utils.ExtractValidatorsFromBytes(checkpointHeader.Validators)
+	m2, _ := utils.SplitCheckpointValidators(checkpointHeader.Validators, len(masternodes))
+	validators := utils.ExtractValidatorsFromBytes(m2)
 	m1m2, _, err := utils.GetM1M2(masternodes, validators, currentHeader, config)
 	if err != nil {
 		return map[common.Address]common.Address{}, err
@@ -1017,6 +1212,9 @@ func NewFaker(db ethdb.Database, config *params.S2PoSConfig) *S2PoS_v1 {
 	signatures, _ := lru.NewARC(utils.InmemorySnapshots)
 	validatorSignatures, _ := lru.NewARC(utils.InmemorySnapshots)
 	verifiedHeaders, _ := lru.NewARC(utils.InmemorySnapshots)
+	proposerSchedules, _ := lru.NewARC(utils.InmemorySnapshots)
+	epochCheckpoints, _ := lru.NewARC(utils.InmemorySnapshots)
+	rewards, _ := lru.NewARC(utils.InmemoryRewards)
 	fakeEngine = &S2PoS_v1{
 		config:              conf,
 		db:                  db,
@@ -1024,6 +1222,9 @@ func NewFaker(db ethdb.Database, config *params.S2PoSConfig) *S2PoS_v1 {
 		signatures:          signatures,
 		verifiedHeaders:     verifiedHeaders,
 		validatorSignatures: validatorSignatures,
+		proposerSchedules:   proposerSchedules,
+		epochCheckpoints:    epochCheckpoints,
+		rewards:             rewards,
 		proposals:           make(map[common.Address]bool),
 	}
 	return fakeEngine
@@ -0,0 +1,311 @@
+package engine_v2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/log"
+	"github.com/FRECNET/rlp"
+)
+
+// ProposedBlockHandler is called once a v2 block has been inserted into the
+// chain. It catches up with the certificate carried by the block and votes for
+// it when the voting rule allows it.
+func (x *S2PoS_v2) ProposedBlockHandler(chain consensus.ChainReader, header *types.Header) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err := x.initial(chain); err != nil {
+		return err
+	}
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	if err := x.processQC(chain, extra.QuorumCert); err != nil {
+		return err
+	}
+	blockInfo := &utils.BlockInfo{
+		Hash:   header.Hash(),
+		Round:  extra.Round,
+		Number: header.Number,
+	}
+	if !x.verifyVotingRule(chain, header, blockInfo, extra.QuorumCert) {
+		return nil
+	}
+	return x.sendVote(chain, blockInfo)
+}
+
+// processQC moves the engine forward with a verified quorum certificate: it
+// updates the highest and the locked certificates, commits the blocks that
+// form a 3-chain and starts the next round. The certified block must be known
+// for the certificates to move and canonical for the safe and finalized blocks
+// to move. It must be called with x.lock held.
+func (x *S2PoS_v2) processQC(chain consensus.ChainReader, qc *utils.QuorumCert) error {
+	number := qc.ProposedBlockInfo.Number.Uint64()
+	proposedHeader := chain.GetHeader(qc.ProposedBlockInfo.Hash, number)
+	if proposedHeader == nil {
+		log.Warn("[processQC] certified block not found, skip updating the certificates", "number", number, "hash", qc.ProposedBlockInfo.Hash)
+	} else {
+		if x.highestQuorumCert == nil || qc.ProposedBlockInfo.Round > x.highestQuorumCert.ProposedBlockInfo.Round {
+			x.highestQuorumCert = qc
+			if isCanonical(chain, qc.ProposedBlockInfo.Hash, number) {
+				if err := rawdb.WriteSafeBlockHash(x.db, qc.ProposedBlockInfo.Hash); err != nil {
+					return err
+				}
+			}
+		}
+		if x.isV2Block(number) {
+			extra, err := decodeExtra(proposedHeader)
+			if err != nil {
+				return err
+			}
+			if x.lockQuorumCert == nil || extra.QuorumCert.ProposedBlockInfo.Round > x.lockQuorumCert.ProposedBlockInfo.Round {
+				x.lockQuorumCert = extra.QuorumCert
+			}
+			if err := x.commitBlocks(chain, proposedHeader, extra); err != nil {
+				return err
+			}
+		}
+	}
+	if qc.ProposedBlockInfo.Round >= x.currentRound {
+		x.setNewRound(qc.ProposedBlockInfo.Round + 1)
+	}
+	return nil
+}
+
+// commitBlocks commits the grandparent of a certified block when the block, its
+// parent and its grandparent have consecutive rounds. The committed block is
+// final: its quorum certificate is recorded and, if it's canonical, it becomes
+// the finalized block.
+func (x *S2PoS_v2) commitBlocks(chain consensus.ChainReader, proposedHeader *types.Header, extra *utils.ExtraFields_v2) error {
+	parentInfo := extra.QuorumCert.ProposedBlockInfo
+	if !x.isV2Block(parentInfo.Number.Uint64()) || parentInfo.Round+1 != extra.Round {
+		return nil
+	}
+	parentHeader := chain.GetHeader(parentInfo.Hash, parentInfo.Number.Uint64())
+	if parentHeader == nil {
+		return fmt.Errorf("parent block %d of certified block %d not found", parentInfo.Number, proposedHeader.Number)
+	}
+	parentExtra, err := decodeExtra(parentHeader)
+	if err != nil {
+		return err
+	}
+	grandParentInfo := parentExtra.QuorumCert.ProposedBlockInfo
+	if grandParentInfo.Round+1 != parentInfo.Round {
+		return nil
+	}
+	if x.highestCommitBlock != nil && grandParentInfo.Number.Cmp(x.highestCommitBlock.Number) <= 0 {
+		return nil
+	}
+	if chain.GetHeader(grandParentInfo.Hash, grandParentInfo.Number.Uint64()) == nil {
+		return fmt.Errorf("grandparent block %d of certified block %d not found", grandParentInfo.Number, proposedHeader.Number)
+	}
+	qc, err := rlp.EncodeToBytes(parentExtra.QuorumCert)
+	if err != nil {
+		return err
+	}
+	if err := rawdb.WriteCommittedQCRLP(x.db, grandParentInfo.Hash, qc); err != nil {
+		return err
+	}
+	// Only a canonical block becomes the finalized one, the chain refuses to
+	// reorg below it
+	if isCanonical(chain, grandParentInfo.Hash, grandParentInfo.Number.Uint64()) {
+		if err := rawdb.WriteFinalizedBlockHash(x.db, grandParentInfo.Hash); err != nil {
+			return err
+		}
+	} else {
+		log.Warn("Committed block not in the canonical chain, finalized block not moved", "number", grandParentInfo.Number, "hash", grandParentInfo.Hash)
+	}
+	x.highestCommitBlock = grandParentInfo
+	log.Info("Committed block", "number", grandParentInfo.Number, "round", grandParentInfo.Round, "hash", grandParentInfo.Hash)
+	return nil
+}
+
+// isCanonical reports whether a block is part of the local canonical chain.
+func isCanonical(chain consensus.ChainReader, hash common.Hash, number uint64) bool {
+	header := chain.GetHeaderByNumber(number)
+	return header != nil && header.Hash() == hash
+}
+
+// GetCommittedQuorumCert retrieves the quorum certificate recorded when the
+// block was committed.
+func (x *S2PoS_v2) GetCommittedQuorumCert(hash common.Hash) (*utils.QuorumCert, error) {
+	data := rawdb.ReadCommittedQCRLP(x.db, hash)
+	if len(data) == 0 {
+		return nil, utils.ErrNotCommitted
+	}
+	qc := new(utils.QuorumCert)
+	if err := rlp.DecodeBytes(data, qc); err != nil {
+		return nil, err
+	}
+	return qc, nil
+}
+
+// processTC starts the round following a verified timeout certificate. It must
+// be called with x.lock held.
+func (x *S2PoS_v2) processTC(tc *utils.TimeoutCert) {
+	if x.highestTimeoutCert == nil || tc.Round > x.highestTimeoutCert.Round {
+		x.highestTimeoutCert = tc
+	}
+	if tc.Round >= x.currentRound {
+		x.setNewRound(tc.Round + 1)
+	}
+}
+
+// setNewRound switches the engine to the round, restarts its countdown and lets
+// the miner know it may have to propose. It must be called with x.lock held.
+func (x *S2PoS_v2) setNewRound(round utils.Round) {
+	log.Info("[setNewRound] new round", "round", round)
+	x.currentRound = round
+	x.timeoutCount = 0
+	x.timeoutWorker.Reset()
+	x.hygienePool()
+
+	select {
+	case x.NewRoundCh <- round:
+	default:
+	}
+}
+
+// hygienePool drops the votes and the timeouts of rounds far behind the current
+// one, they can't produce a useful certificate anymore.
+func (x *S2PoS_v2) hygienePool() {
+	if x.currentRound <= utils.PoolHygieneRound {
+		return
+	}
+	limit := x.currentRound - utils.PoolHygieneRound
+	for _, pool := range []*utils.Pool{x.votePool, x.timeoutPool} {
+		for _, key := range pool.PoolObjKeysList() {
+			round, err := strconv.ParseUint(strings.Split(key, ":")[0], 10, 64)
+			if err != nil || utils.Round(round) < limit {
+				pool.ClearByPoolKey(key)
+			}
+		}
+	}
+}
+
+// verifyQC checks that enough masternodes of the certified block epoch signed
+// the quorum certificate.
+func (x *S2PoS_v2) verifyQC(chain consensus.ChainReader, qc *utils.QuorumCert, parents []*types.Header) error {
+	if qc == nil || qc.ProposedBlockInfo == nil || qc.ProposedBlockInfo.Number == nil {
+		return utils.ErrInvalidQC
+	}
+	number := qc.ProposedBlockInfo.Number.Uint64()
+	if !x.isV2Block(number) {
+		// Only the switch block is certified without votes
+		if qc.ProposedBlockInfo.Round != 0 || number != x.config.V2ConsensusBlockNumber.Uint64() || len(qc.Signatures) != 0 {
+			return utils.ErrInvalidQC
+		}
+		header := findHeaderByNumber(chain, number, parents)
+		if header == nil || header.Hash() != qc.ProposedBlockInfo.Hash {
+			return utils.ErrInvalidQC
+		}
+		return nil
+	}
+	if qc.GapNumber != x.voteGapNumber(number) {
+		return fmt.Errorf("%v: gap number %d doesn't match block %d", utils.ErrInvalidQC, qc.GapNumber, number)
+	}
+	masternodes, err := x.getMasternodesByGapNumber(chain, qc.GapNumber, parents)
+	if err != nil {
+		return err
+	}
+	signedHash := utils.VoteSigHash(&utils.VoteForSign{
+		ProposedBlockInfo: qc.ProposedBlockInfo,
+		GapNumber:         qc.GapNumber,
+	})
+	return x.verifyCertSignatures(signedHash, qc.Signatures, masternodes, utils.ErrInvalidQC)
+}
+
+// verifyTC checks that enough masternodes signed the timeout certificate.
+func (x *S2PoS_v2) verifyTC(chain consensus.ChainReader, tc *utils.TimeoutCert) error {
+	masternodes, err := x.getMasternodesByGapNumber(chain, tc.GapNumber, nil)
+	if err != nil {
+		return err
+	}
+	signedHash := utils.TimeoutSigHash(&utils.TimeoutForSign{
+		Round:     tc.Round,
+		GapNumber: tc.GapNumber,
+	})
+	return x.verifyCertSignatures(signedHash, tc.Signatures, masternodes, utils.ErrInvalidTC)
+}
+
+func (x *S2PoS_v2) verifyCertSignatures(signedHash common.Hash, signatures []utils.Signature, masternodes []common.Address, errInvalid error) error {
+	signers := make(map[common.Address]bool)
+	for _, signature := range signatures {
+		verified, signer, err := verifyMsgSignature(signedHash, signature, masternodes)
+		if err != nil {
+			return fmt.Errorf("%v: %v", errInvalid, err)
+		}
+		if !verified {
+			return fmt.Errorf("%v: signer %v isn't a masternode", errInvalid, signer.Hex())
+		}
+		signers[signer] = true
+	}
+	if !x.certThreshold(len(signers), len(masternodes)) {
+		return fmt.Errorf("%v: %d signers out of %d masternodes", errInvalid, len(signers), len(masternodes))
+	}
+	return nil
+}
+
+// broadcastToBftChannel hands the message over to the protocol manager without
+// blocking the engine.
+func (x *S2PoS_v2) broadcastToBftChannel(msg interface{}) {
+	go func() {
+		x.BroadcastCh <- msg
+	}()
+}
+
+// getSyncInfo returns the highest certificates known by the engine. It must be
+// called with x.lock held.
+func (x *S2PoS_v2) getSyncInfo() *utils.SyncInfo {
+	return &utils.SyncInfo{
+		HighestQuorumCert:  x.highestQuorumCert,
+		HighestTimeoutCert: x.highestTimeoutCert,
+	}
+}
+
+// VerifySyncInfoMessage checks the certificates of the sync info. It returns
+// false without error when they don't bring anything new.
+func (x *S2PoS_v2) VerifySyncInfoMessage(chain consensus.ChainReader, syncInfo *utils.SyncInfo) (bool, error) {
+	if syncInfo.HighestQuorumCert == nil || syncInfo.HighestQuorumCert.ProposedBlockInfo == nil {
+		return false, utils.ErrInvalidQC
+	}
+	x.lock.RLock()
+	currentRound := x.currentRound
+	x.lock.RUnlock()
+	qcRound := syncInfo.HighestQuorumCert.ProposedBlockInfo.Round
+	if qcRound < currentRound && (syncInfo.HighestTimeoutCert == nil || syncInfo.HighestTimeoutCert.Round < currentRound) {
+		return false, nil
+	}
+	if err := x.verifyQC(chain, syncInfo.HighestQuorumCert, nil); err != nil {
+		return false, err
+	}
+	if syncInfo.HighestTimeoutCert != nil {
+		if err := x.verifyTC(chain, syncInfo.HighestTimeoutCert); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// SyncInfoHandler catches up with the certificates of a verified sync info.
+func (x *S2PoS_v2) SyncInfoHandler(chain consensus.ChainReader, syncInfo *utils.SyncInfo) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err := x.initial(chain); err != nil {
+		return err
+	}
+	if err := x.processQC(chain, syncInfo.HighestQuorumCert); err != nil {
+		return err
+	}
+	if syncInfo.HighestTimeoutCert != nil {
+		x.processTC(syncInfo.HighestTimeoutCert)
+	}
+	return nil
+}
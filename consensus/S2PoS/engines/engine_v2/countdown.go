@@ -0,0 +1,80 @@
+package engine_v2
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FRECNET/log"
+)
+
+// countdownTimer fires OnTimeoutFn every time the duration elapses without
+// being reset. It's used to trigger the timeout of a round.
+type countdownTimer struct {
+	lock            sync.Mutex
+	resetc          chan struct{}
+	quitc           chan struct{} // Closed to stop the running timer, nil when stopped
+	timeoutDuration time.Duration
+	// Triggered when the countdown reaches zero
+	OnTimeoutFn func(time.Time) error
+}
+
+func newCountdownTimer(duration time.Duration) *countdownTimer {
+	return &countdownTimer{
+		resetc:          make(chan struct{}, 1),
+		timeoutDuration: duration,
+	}
+}
+
+// StopTimer stops the countdown, it can be started again with Reset.
+func (t *countdownTimer) StopTimer() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.quitc != nil {
+		close(t.quitc)
+		t.quitc = nil
+	}
+}
+
+// Reset restarts the countdown from the full duration, starting the timer if
+// it isn't running yet. It never blocks, so it's safe to call from OnTimeoutFn.
+func (t *countdownTimer) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.quitc == nil {
+		t.quitc = make(chan struct{})
+		go t.startTimer(t.quitc)
+		return
+	}
+	select {
+	case t.resetc <- struct{}{}:
+	default:
+	}
+}
+
+func (t *countdownTimer) startTimer(quitc chan struct{}) {
+	timer := time.NewTimer(t.timeoutDuration)
+	defer timer.Stop()
+	for {
+		select {
+		case <-quitc:
+			log.Debug("Quit countdown timer")
+			return
+		case <-timer.C:
+			log.Debug("Countdown time reached!")
+			if t.OnTimeoutFn != nil {
+				if err := t.OnTimeoutFn(time.Now()); err != nil {
+					log.Error("OnTimeoutFn error", "error", err)
+				}
+			}
+			timer.Reset(t.timeoutDuration)
+		case <-t.resetc:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(t.timeoutDuration)
+		}
+	}
+}
@@ -1,42 +1,861 @@
 package engine_v2
 
 import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/FRECNET/accounts"
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/consensus/clique"
+	"github.com/FRECNET/consensus/misc"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
 	"github.com/FRECNET/params"
+	lru "github.com/hashicorp/golang-lru"
 )
 
+// S2PoS_v2 is the BFT flavour of the solid-state-proof-of-stake engine. Every
+// round has a leader picked among the masternodes which proposes a block on
+// top of the highest quorum certificate, the masternodes vote for it and a
+// block is committed once it's followed by two certified blocks of consecutive
+// rounds. Rounds without a certificate are skipped with timeout certificates.
 type S2PoS_v2 struct {
 	config *params.S2PoSConfig // Consensus engine configuration parameters
 	db     ethdb.Database      // Database to store and retrieve snapshot checkpoints
+	chain  consensus.ChainReader
+
+	snapshots        *lru.ARCCache // Masternode snapshots of the recent gap blocks
+	signatures       *lru.ARCCache // Signatures of recent blocks to speed up mining
+	verifiedHeaders  *lru.ARCCache
+	epochMasternodes *lru.ARCCache // Masternodes and penalties computed for the recent checkpoints
+	rewards          *lru.ARCCache // Rewards of recently finalized blocks, keyed by hash without validator signature
+	epochLeaders     *lru.ARCCache // Stake-weighted leader draws of recent epochs, keyed by checkpoint hash
+
+	signer   common.Address  // Ethereum address of the signing key
+	signFn   clique.SignerFn // Signer function to authorize hashes with
+	signLock sync.RWMutex    // Protects the signer fields
+
+	lock          sync.RWMutex // Protects the round state below
+	isInitialised bool
+
+	BroadcastCh chan interface{} // Votes, timeouts and sync infos to send to the peers
+	NewRoundCh  chan utils.Round // Notified when a new round starts, so the miner can propose
+
+	timeoutWorker *countdownTimer
+	timeoutCount  int
+	timeoutPool   *utils.Pool
+	votePool      *utils.Pool
+
+	currentRound          utils.Round
+	highestSelfMinedRound utils.Round
+	highestVotedRound     utils.Round
+	highestQuorumCert     *utils.QuorumCert
+	lockQuorumCert        *utils.QuorumCert
+	highestTimeoutCert    *utils.TimeoutCert
+	highestCommitBlock    *utils.BlockInfo
+
+	HookReward  func(chain consensus.ChainReader, state *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{})
+	HookPenalty func(chain consensus.ChainReader, number *big.Int, parentHash common.Hash, candidates []common.Address) ([]common.Address, error)
+}
+
+// epochMasternodes is the result of calcMasternodes kept in cache.
+type epochMasternodes struct {
+	masternodes []common.Address
+	penalties   []common.Address
 }
 
 func New(config *params.S2PoSConfig, db ethdb.Database) *S2PoS_v2 {
+	snapshots, _ := lru.NewARC(utils.InmemorySnapshots)
+	signatures, _ := lru.NewARC(utils.InmemorySnapshots)
+	verifiedHeaders, _ := lru.NewARC(utils.InmemorySnapshots)
+	epochMasternodes, _ := lru.NewARC(utils.InmemorySnapshots)
+	rewards, _ := lru.NewARC(utils.InmemoryRewards)
+	epochLeaders, _ := lru.NewARC(utils.InmemorySnapshots)
+
+	timeoutPeriod := time.Duration(config.V2Params().TimeoutPeriod) * time.Second
 	return &S2PoS_v2{
 		config: config,
 		db:     db,
+
+		snapshots:        snapshots,
+		signatures:       signatures,
+		verifiedHeaders:  verifiedHeaders,
+		epochMasternodes: epochMasternodes,
+		rewards:          rewards,
+		epochLeaders:     epochLeaders,
+
+		BroadcastCh: make(chan interface{}),
+		NewRoundCh:  make(chan utils.Round, 1),
+
+		timeoutWorker: newCountdownTimer(timeoutPeriod),
+		timeoutPool:   utils.NewPool(),
+		votePool:      utils.NewPool(),
 	}
 }
 
 func NewFaker(db ethdb.Database, config *params.S2PoSConfig) *S2PoS_v2 {
-	var fakeEngine *S2PoS_v2
 	// Set any missing consensus parameters to their defaults
 	conf := config
 
 	// Allocate the snapshot caches and create the engine
-	fakeEngine = &S2PoS_v2{
-		config: conf,
-		db:     db,
+	return New(conf, db)
+}
+
+// initial sets up the round state from the chain head the first time the v2
+// engine is needed. It must be called with x.lock held.
+func (x *S2PoS_v2) initial(chain consensus.ChainReader) error {
+	if x.isInitialised {
+		return nil
+	}
+	head := chain.CurrentHeader()
+	switchNumber := x.config.V2ConsensusBlockNumber
+	if switchNumber == nil || head.Number.Cmp(switchNumber) < 0 {
+		return fmt.Errorf("v2 engine can't start before the switch block %v, head at %v", switchNumber, head.Number)
+	}
+	log.Info("Initialising S2PoS v2 consensus engine", "number", head.Number, "hash", head.Hash())
+
+	x.chain = chain
+	x.timeoutWorker.OnTimeoutFn = x.onCountdownTimeout
+	if err := x.loadCommitBlock(chain); err != nil {
+		return err
+	}
+	if head.Number.Cmp(switchNumber) == 0 {
+		// The switch block is certified without votes, it's the genesis of the v2 chain
+		qc := &utils.QuorumCert{
+			ProposedBlockInfo: &utils.BlockInfo{
+				Hash:   head.Hash(),
+				Round:  0,
+				Number: head.Number,
+			},
+			Signatures: []utils.Signature{},
+			GapNumber:  x.gapNumber(head.Number.Uint64()),
+		}
+		x.highestQuorumCert = qc
+		x.lockQuorumCert = qc
+		x.setNewRound(1)
+	} else {
+		extra, err := decodeExtra(head)
+		if err != nil {
+			return err
+		}
+		if err := x.processQC(chain, extra.QuorumCert); err != nil {
+			return err
+		}
+	}
+	x.isInitialised = true
+	return nil
+}
+
+// loadCommitBlock restores the highest committed block recorded in the
+// database, so a restarted node never finalizes a lower block.
+func (x *S2PoS_v2) loadCommitBlock(chain consensus.ChainReader) error {
+	hash := rawdb.ReadFinalizedBlockHash(x.db)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	header := chain.GetHeaderByHash(hash)
+	if header == nil {
+		log.Warn("Finalized block not found in the chain", "hash", hash)
+		return nil
+	}
+	round, err := x.roundOf(header)
+	if err != nil {
+		return err
+	}
+	x.highestCommitBlock = &utils.BlockInfo{
+		Hash:   hash,
+		Round:  round,
+		Number: header.Number,
+	}
+	return nil
+}
+
+// Author implements consensus.Engine, returning the Ethereum address recovered
+// from the signature in the header's validator field.
+func (x *S2PoS_v2) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, x.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (x *S2PoS_v2) VerifyHeader(chain consensus.ChainReader, header *types.Header, fullVerify bool) error {
+	return x.verifyHeaderWithCache(chain, header, nil, fullVerify)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+// Headers up to the switch block belong to the v1 engine, they are only used as
+// parents here and reported as valid.
+func (x *S2PoS_v2) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, fullVerifies []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			var err error
+			if x.isV2Block(header.Number.Uint64()) {
+				err = x.verifyHeaderWithCache(chain, header, headers[:i], fullVerifies[i])
+			}
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+func (x *S2PoS_v2) verifyHeaderWithCache(chain consensus.ChainReader, header *types.Header, parents []*types.Header, fullVerify bool) error {
+	_, check := x.verifiedHeaders.Get(header.Hash())
+	if check {
+		return nil
+	}
+	err := x.verifyHeader(chain, header, parents, fullVerify)
+	if err == nil {
+		x.verifiedHeaders.Add(header.Hash(), true)
+	}
+	return err
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database.
+func (x *S2PoS_v2) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, fullVerify bool) error {
+	// If we're running a engine faking, accept any block as valid
+	if x.config.SkipValidation {
+		return nil
+	}
+	if header.Number == nil {
+		return utils.ErrUnknownBlock
+	}
+	number := header.Number.Uint64()
+	if fullVerify {
+		// Don't waste time checking blocks from the future
+		if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+			return consensus.ErrFutureBlock
+		}
+	}
+	if len(header.Validator) == 0 {
+		return consensus.ErrNoValidatorSignature
+	}
+	// Ensure that the mix digest is zero as we don't have fork protection currently
+	if header.MixDigest != (common.Hash{}) {
+		return utils.ErrInvalidMixDigest
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in S2PoS_v2
+	if header.UncleHash != utils.UncleHash {
+		return utils.ErrInvalidUncleHash
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	parent := findHeader(chain, header.ParentHash, number-1, parents)
+	if parent == nil || parent.Number.Uint64() != number-1 {
+		return consensus.ErrUnknownAncestor
 	}
-	return fakeEngine
+	// The block must be built on top of the certified parent, with a higher round
+	qc := extra.QuorumCert
+	if qc.ProposedBlockInfo.Hash != header.ParentHash || qc.ProposedBlockInfo.Number.Uint64() != number-1 {
+		return utils.ErrInvalidQC
+	}
+	if extra.Round <= qc.ProposedBlockInfo.Round {
+		return utils.ErrInvalidRound
+	}
+	if parent.Time.Uint64()+uint64(x.config.V2Params().MinePeriod) > header.Time.Uint64() {
+		return utils.ErrInvalidTimestamp
+	}
+	parentRound, err := x.roundOf(parent)
+	if err != nil {
+		return err
+	}
+	if header.Difficulty == nil || header.Difficulty.Cmp(calcDifficulty(parentRound, extra.Round)) != 0 {
+		return utils.ErrInvalidDifficulty
+	}
+
+	var masternodes []common.Address
+	if number%x.config.Epoch == 0 {
+		var penalties []common.Address
+		masternodes, penalties, err = x.calcMasternodes(chain, header.Number, header.ParentHash, parents)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(header.Validators, common.ExtractAddressToBytes(masternodes)) {
+			log.Error("Masternodes lists are different in checkpoint header and snapshot", "number", number, "masternodes_from_checkpoint_header", common.ExtractAddressFromBytes(header.Validators), "masternodes_in_snapshot", masternodes, "penList", penalties)
+			return utils.ErrInvalidCheckpointMasternodes
+		}
+		if !bytes.Equal(header.Penalties, common.ExtractAddressToBytes(penalties)) {
+			return utils.ErrInvalidCheckpointPenalties
+		}
+	} else {
+		if len(header.Validators) != 0 || len(header.Penalties) != 0 {
+			return utils.ErrInvalidCheckpointMasternodes
+		}
+		checkpointHeader := findHeaderByNumber(chain, number-number%x.config.Epoch, parents)
+		masternodes = x.GetMasternodesFromCheckpointHeader(checkpointHeader, number, x.config.Epoch)
+	}
+	if err := x.verifyQC(chain, qc, parents); err != nil {
+		return err
+	}
+	leader, err := x.roundLeader(chain, number, extra.Round, masternodes, parents)
+	if err != nil {
+		return err
+	}
+	return x.verifySeal(header, extra.Round, leader, masternodes)
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (x *S2PoS_v2) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return fmt.Errorf("uncles not allowed")
+	}
+	return nil
 }
 
-func (consensus *S2PoS_v2) Author(header *types.Header) (common.Address, error) {
-	return common.Address{}, nil
+// VerifySeal implements consensus.Engine, checking that the header is sealed by
+// the leader of its round.
+func (x *S2PoS_v2) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if header.Number.Uint64() == 0 {
+		return utils.ErrUnknownBlock
+	}
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	masternodes := x.GetMasternodes(chain, header)
+	leader, err := x.roundLeader(chain, header.Number.Uint64(), extra.Round, masternodes, nil)
+	if err != nil {
+		return err
+	}
+	return x.verifySeal(header, extra.Round, leader, masternodes)
 }
 
-func (consensus *S2PoS_v2) VerifyHeader(chain consensus.ChainReader, header *types.Header, fullVerify bool) error {
+func (x *S2PoS_v2) verifySeal(header *types.Header, round utils.Round, leader common.Address, masternodes []common.Address) error {
+	creator, err := ecrecover(header, x.signatures)
+	if err != nil {
+		return err
+	}
+	if len(masternodes) == 0 {
+		return utils.ErrUnauthorized
+	}
+	if creator != leader {
+		if !isMasternode(creator, masternodes) {
+			log.Debug("Unauthorized creator found", "block number", header.Number, "creator", creator.String(), "masternodes", masternodes)
+			return utils.ErrUnauthorized
+		}
+		log.Debug("Block creator isn't the leader of the round", "block number", header.Number, "round", round, "creator", creator.String(), "leader", leader.String())
+		return utils.ErrNotItsTurn
+	}
 	return nil
 }
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (x *S2PoS_v2) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	x.lock.RLock()
+	currentRound := x.currentRound
+	highestQC := x.highestQuorumCert
+	x.lock.RUnlock()
+
+	if highestQC == nil || header.ParentHash != highestQC.ProposedBlockInfo.Hash {
+		return utils.ErrNotReadyToPropose
+	}
+	extra := utils.ExtraFields_v2{
+		Round:      currentRound,
+		QuorumCert: highestQC,
+	}
+	extraBytes, err := extra.EncodeToBytes()
+	if err != nil {
+		return err
+	}
+	header.Extra = extraBytes
+	header.Nonce = types.BlockNonce{}
+
+	x.signLock.RLock()
+	header.Coinbase = x.signer
+	x.signLock.RUnlock()
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	parentRound, err := x.roundOf(parent)
+	if err != nil {
+		return err
+	}
+	header.Difficulty = calcDifficulty(parentRound, currentRound)
+	log.Debug("CalcDifficulty ", "number", header.Number, "difficulty", header.Difficulty)
+
+	if number%x.config.Epoch == 0 {
+		masternodes, penalties, err := x.calcMasternodes(chain, header.Number, header.ParentHash, nil)
+		if err != nil {
+			return err
+		}
+		for _, address := range penalties {
+			log.Debug("Penalty status", "address", address, "number", number)
+		}
+		header.Validators = common.ExtractAddressToBytes(masternodes)
+		header.Penalties = common.ExtractAddressToBytes(penalties)
+	}
+	// Mix digest is reserved for now, set to empty
+	header.MixDigest = common.Hash{}
+
+	// Ensure the timestamp has the correct delay
+	header.Time = new(big.Int).Add(parent.Time, big.NewInt(int64(x.config.V2Params().MinePeriod)))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, and returns the final block.
+func (x *S2PoS_v2) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, parentState *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// set block reward
+	number := header.Number.Uint64()
+	rCheckpoint := chain.Config().S2PoS.RewardCheckpoint
+
+	var rewards map[string]interface{}
+	if x.HookReward != nil && number%rCheckpoint == 0 {
+		var err error
+		if err, rewards = x.HookReward(chain, state, parentState, header); err != nil {
+			return nil, err
+		}
+	}
+
+	// the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	// Assemble and return the final block for sealing
+	block := types.NewBlock(header, txs, nil, receipts)
+
+	// Keep the rewards until the chain writes the block. They are keyed by the
+	// hash without the validator signature, which sealing doesn't change
+	if rewards != nil {
+		x.rewards.Add(block.HashNoValidator(), rewards)
+	}
+	return block, nil
+}
+
+// GetRewards returns the rewards distributed by the finalized block, or nil if
+// it isn't a reward checkpoint.
+func (x *S2PoS_v2) GetRewards(header *types.Header) map[string]interface{} {
+	if rewards, ok := x.rewards.Get(header.HashNoValidator()); ok {
+		return rewards.(map[string]interface{})
+	}
+	return nil
+}
+
+// Authorize injects a private key into the consensus engine to mint new blocks
+// with.
+func (x *S2PoS_v2) Authorize(signer common.Address, signFn clique.SignerFn) {
+	x.signLock.Lock()
+	defer x.signLock.Unlock()
+
+	x.signer = signer
+	x.signFn = signFn
+}
+
+// Seal implements consensus.Engine, attempting to create a sealed block using
+// the local signing credentials.
+func (x *S2PoS_v2) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	if header.Number.Uint64() == 0 {
+		return nil, utils.ErrUnknownBlock
+	}
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	// Don't hold the signer fields for the entire sealing procedure
+	x.signLock.RLock()
+	signer, signFn := x.signer, x.signFn
+	x.signLock.RUnlock()
+	if signFn == nil {
+		return nil, utils.ErrUnauthorized
+	}
+
+	// Only a single block can be proposed per round
+	x.lock.Lock()
+	if extra.Round <= x.highestSelfMinedRound {
+		x.lock.Unlock()
+		return nil, utils.ErrAlreadyMined
+	}
+	x.highestSelfMinedRound = extra.Round
+	x.lock.Unlock()
+
+	// Wait until the block timestamp is reached, peers would reject it otherwise
+	delay := time.Until(time.Unix(header.Time.Int64(), 0))
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	// Sign all the things!
+	signature, err := signFn(accounts.Account{Address: signer}, SigHash(header).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	header.Validator = signature
+	return block.WithSeal(header), nil
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. The difficulty of a v2
+// block is the number of rounds since its parent, so the heaviest chain is the
+// one with the highest round.
+func (x *S2PoS_v2) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	// If we're running a engine faking, skip calculation
+	if x.config.SkipValidation {
+		return big.NewInt(1)
+	}
+	parentRound, err := x.roundOf(parent)
+	if err != nil {
+		return big.NewInt(1)
+	}
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return calcDifficulty(parentRound, x.currentRound)
+}
+
+func calcDifficulty(parentRound, round utils.Round) *big.Int {
+	if round <= parentRound {
+		return big.NewInt(1)
+	}
+	return new(big.Int).SetUint64(uint64(round - parentRound))
+}
+
+// YourTurn reports whether the signer is the leader of the current round and
+// holds a quorum certificate for the parent block.
+func (x *S2PoS_v2) YourTurn(chain consensus.ChainReader, parent *types.Header, signer common.Address) (int, int, int, bool, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err := x.initial(chain); err != nil {
+		return 0, -1, -1, false, err
+	}
+	if x.highestQuorumCert.ProposedBlockInfo.Hash != parent.Hash() {
+		log.Debug("No quorum certificate for the parent block yet", "number", parent.Number, "hash", parent.Hash())
+		return 0, -1, -1, false, nil
+	}
+	round := x.currentRound
+	masternodes, err := x.masternodesForBlock(chain, new(big.Int).Add(parent.Number, common.Big1), parent.Hash())
+	if err != nil {
+		return 0, -1, -1, false, err
+	}
+	if len(masternodes) == 0 {
+		return 0, -1, -1, false, fmt.Errorf("Masternodes not found")
+	}
+	leader, err := x.roundLeader(chain, parent.Number.Uint64()+1, round, masternodes, nil)
+	if err != nil {
+		return 0, -1, -1, false, err
+	}
+	leaderIndex := position(masternodes, leader)
+	curIndex := position(masternodes, signer)
+	if round <= x.highestSelfMinedRound {
+		return len(masternodes), leaderIndex, curIndex, false, nil
+	}
+	log.Debug("Masternodes cycle info", "round", round, "number of masternodes", len(masternodes), "leader", masternodes[leaderIndex], "current", signer, "position", curIndex)
+	return len(masternodes), leaderIndex, curIndex, leaderIndex == curIndex, nil
+}
+
+func position(list []common.Address, x common.Address) int {
+	for i, item := range list {
+		if item == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// ProposalParentHash returns the hash of the block the next v2 block has to be
+// built on, which is the one certified by the highest quorum certificate.
+func (x *S2PoS_v2) ProposalParentHash() (common.Hash, bool) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	if x.highestQuorumCert == nil {
+		return common.Hash{}, false
+	}
+	return x.highestQuorumCert.ProposedBlockInfo.Hash, true
+}
+
+func (x *S2PoS_v2) IsAuthorisedAddress(header *types.Header, chain consensus.ChainReader, address common.Address) bool {
+	return isMasternode(address, x.GetMasternodes(chain, header))
+}
+
+// GetMasternodes returns the masternodes of the epoch the header belongs to.
+func (x *S2PoS_v2) GetMasternodes(chain consensus.ChainReader, header *types.Header) []common.Address {
+	n := header.Number.Uint64()
+	e := x.config.Epoch
+	if n%e == 0 {
+		return x.GetMasternodesFromCheckpointHeader(header, n, e)
+	}
+	h := chain.GetHeaderByNumber(n - (n % e))
+	return x.GetMasternodesFromCheckpointHeader(h, n, e)
+}
+
+// GetMasternodesFromCheckpointHeader returns the masternodes recorded in a
+// checkpoint header. Checkpoints up to the switch block carry them in the v1
+// extra data, v2 checkpoints in the validators field.
+func (x *S2PoS_v2) GetMasternodesFromCheckpointHeader(checkpointHeader *types.Header, n, e uint64) []common.Address {
+	if checkpointHeader == nil {
+		log.Info("Previous checkpoint's header is empty", "block number", n, "epoch", e)
+		return []common.Address{}
+	}
+	if !x.isV2Block(checkpointHeader.Number.Uint64()) {
+		return utils.GetMasternodesFromCheckpointHeader(checkpointHeader)
+	}
+	return common.ExtractAddressFromBytes(checkpointHeader.Validators)
+}
+
+// masternodesForBlock returns the masternodes allowed to propose the block with
+// the given number and parent.
+func (x *S2PoS_v2) masternodesForBlock(chain consensus.ChainReader, number *big.Int, parentHash common.Hash) ([]common.Address, error) {
+	n := number.Uint64()
+	if n%x.config.Epoch == 0 {
+		masternodes, _, err := x.calcMasternodes(chain, number, parentHash, nil)
+		return masternodes, err
+	}
+	checkpointHeader := chain.GetHeaderByNumber(n - n%x.config.Epoch)
+	return x.GetMasternodesFromCheckpointHeader(checkpointHeader, n, x.config.Epoch), nil
+}
+
+// calcMasternodes computes the masternodes of the epoch starting at the given
+// checkpoint: the candidates picked at the gap block minus the penalties of
+// this checkpoint and of the recent ones.
+func (x *S2PoS_v2) calcMasternodes(chain consensus.ChainReader, number *big.Int, parentHash common.Hash, parents []*types.Header) ([]common.Address, []common.Address, error) {
+	n := number.Uint64()
+	if cached, ok := x.epochMasternodes.Get(parentHash); ok {
+		result := cached.(*epochMasternodes)
+		return result.masternodes, result.penalties, nil
+	}
+	gapNumber := x.gapNumber(n)
+	header := findHeader(chain, parentHash, n-1, parents)
+	for header != nil && header.Number.Uint64() > gapNumber {
+		header = findHeader(chain, header.ParentHash, header.Number.Uint64()-1, parents)
+	}
+	if header == nil {
+		return nil, nil, consensus.ErrUnknownAncestor
+	}
+	snap, err := x.getSnapshot(header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't find the masternodes snapshot at gap block %d: %v", gapNumber, err)
+	}
+	candidates := make([]common.Address, len(snap.NextEpochMasterNodes))
+	copy(candidates, snap.NextEpochMasterNodes)
+	// The candidates are ordered by stake, the configured set size caps them
+	if max := x.config.MaxMasternodes; max > 0 && len(candidates) > max && chain.Config().IsTIPDynamicMasternodes(number) {
+		candidates = candidates[:max]
+	}
+
+	penalties := []common.Address{}
+	if x.HookPenalty != nil {
+		penalties, err = x.HookPenalty(chain, number, parentHash, candidates)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	masternodes := common.RemoveItemFromArray(candidates, penalties)
+	// Prevent penalized masternode(s) within 4 recent epochs
+	for i := 1; i <= common.LimitPenaltyEpoch; i++ {
+		if n > uint64(i)*x.config.Epoch {
+			if h := findHeaderByNumber(chain, n-uint64(i)*x.config.Epoch, parents); h != nil {
+				masternodes = common.RemoveItemFromArray(masternodes, common.ExtractAddressFromBytes(h.Penalties))
+			}
+		}
+	}
+	x.epochMasternodes.Add(parentHash, &epochMasternodes{masternodes: masternodes, penalties: penalties})
+	return masternodes, penalties, nil
+}
+
+// epochLeaders holds what the round leaders of an epoch are drawn by.
+type epochLeaders struct {
+	masternodes []utils.Masternode
+	seed        common.Hash
+}
+
+// roundLeader returns the leader of a round proposing the block with the given
+// number. The masternodes take turns, from the TIPStakeWeightedProposer fork on
+// the leaders of the blocks following a checkpoint are drawn by stake instead,
+// seeded by the certificate the checkpoint carries. The checkpoints themselves
+// are still led in turns, as the certificate they will carry is assembled by
+// every masternode on its own.
+func (x *S2PoS_v2) roundLeader(chain consensus.ChainReader, number uint64, round utils.Round, masternodes []common.Address, parents []*types.Header) (common.Address, error) {
+	if len(masternodes) == 0 {
+		return common.Address{}, utils.ErrUnauthorized
+	}
+	turn := masternodes[uint64(round)%uint64(len(masternodes))]
+	checkpointNumber := number - number%x.config.Epoch
+	if number == checkpointNumber || !x.isV2Block(checkpointNumber) || !chain.Config().IsTIPStakeWeightedProposer(new(big.Int).SetUint64(checkpointNumber)) {
+		return turn, nil
+	}
+	checkpoint := findHeaderByNumber(chain, checkpointNumber, parents)
+	if checkpoint == nil {
+		return common.Address{}, consensus.ErrUnknownAncestor
+	}
+	if cached, ok := x.epochLeaders.Get(checkpoint.Hash()); ok {
+		leaders := cached.(*epochLeaders)
+		return utils.RoundLeader(leaders.masternodes, leaders.seed, round), nil
+	}
+	extra, err := decodeExtra(checkpoint)
+	if err != nil {
+		return common.Address{}, err
+	}
+	// The stakes are the ones of the candidates picked at the gap block
+	gapNumber := x.gapNumber(checkpointNumber)
+	header := checkpoint
+	for header != nil && header.Number.Uint64() > gapNumber {
+		header = findHeader(chain, header.ParentHash, header.Number.Uint64()-1, parents)
+	}
+	if header == nil {
+		return common.Address{}, consensus.ErrUnknownAncestor
+	}
+	snap, err := x.getSnapshot(header)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("can't find the masternodes snapshot at gap block %d: %v", gapNumber, err)
+	}
+	stakes := snap.stakes(masternodes)
+	leaders := &epochLeaders{
+		masternodes: make([]utils.Masternode, len(masternodes)),
+		seed:        utils.CertificateSeed(extra.QuorumCert),
+	}
+	for i, m := range masternodes {
+		leaders.masternodes[i] = utils.Masternode{Address: m, Stake: stakes[i]}
+	}
+	x.epochLeaders.Add(checkpoint.Hash(), leaders)
+	return utils.RoundLeader(leaders.masternodes, leaders.seed, round), nil
+}
+
+// UpdateMasternodes stores the masternode candidates picked at the gap block
+// along with their stakes, they are used to compute the masternodes of the next
+// checkpoint and to draw the leaders of its epoch.
+func (x *S2PoS_v2) UpdateMasternodes(chain consensus.ChainReader, header *types.Header, ms []utils.Masternode) error {
+	number := header.Number.Uint64()
+	log.Trace("take snapshot", "number", number, "hash", header.Hash())
+
+	masternodes := make([]common.Address, len(ms))
+	stakes := make([]*big.Int, len(ms))
+	nm := []string{}
+	for i, m := range ms {
+		masternodes[i] = m.Address
+		stakes[i] = m.Stake
+		nm = append(nm, m.Address.String())
+	}
+	snap := newSnapshot(number, header.Hash(), masternodes, stakes)
+	if err := storeSnapshot(snap, x.db); err != nil {
+		log.Error("[UpdateMasternodes] Error while store snapshot", "hash", header.Hash(), "error", err)
+		return err
+	}
+	x.snapshots.Add(snap.Hash, snap)
+	log.Info("New set of masternodes has been updated to snapshot", "number", snap.Number, "hash", snap.Hash, "new masternodes", nm)
+	return nil
+}
+
+func (x *S2PoS_v2) getSnapshot(header *types.Header) (*SnapshotV2, error) {
+	hash := header.Hash()
+	if s, ok := x.snapshots.Get(hash); ok {
+		return s.(*SnapshotV2), nil
+	}
+	snap, err := loadSnapshot(x.db, hash)
+	if err != nil {
+		return nil, err
+	}
+	x.snapshots.Add(hash, snap)
+	return snap, nil
+}
+
+func (x *S2PoS_v2) RecoverSigner(header *types.Header) (common.Address, error) {
+	return ecrecover(header, x.signatures)
+}
+
+// RecoverValidator returns the block creator, v2 blocks don't have a double
+// validation signature.
+func (x *S2PoS_v2) RecoverValidator(header *types.Header) (common.Address, error) {
+	return ecrecover(header, x.signatures)
+}
+
+func (x *S2PoS_v2) GetDb() ethdb.Database {
+	return x.db
+}
+
+// GetRoundInfo returns the current round state of the engine.
+func (x *S2PoS_v2) GetRoundInfo() *utils.PublicApiRoundInfo {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return &utils.PublicApiRoundInfo{
+		CurrentRound:       x.currentRound,
+		HighestQuorumCert:  x.highestQuorumCert,
+		LockQuorumCert:     x.lockQuorumCert,
+		HighestTimeoutCert: x.highestTimeoutCert,
+		HighestCommitBlock: x.highestCommitBlock,
+	}
+}
+
+func (x *S2PoS_v2) isV2Block(number uint64) bool {
+	return x.config.V2ConsensusBlockNumber != nil && number > x.config.V2ConsensusBlockNumber.Uint64()
+}
+
+// gapNumber returns the gap block which picked the masternodes of the epoch the
+// block number belongs to. Timeouts carry it to bind their signature to a
+// masternode set, votes carry the voteGapNumber of their block.
+func (x *S2PoS_v2) gapNumber(number uint64) uint64 {
+	checkpoint := number - number%x.config.Epoch
+	if checkpoint == 0 {
+		return 0
+	}
+	return checkpoint - x.config.Gap
+}
+
+// voteGapNumber returns the gap number binding the votes on a block. A
+// checkpoint is certified by the masternodes of the epoch it closes, so that the
+// outgoing set hands the chain over to the set listed in the checkpoint and
+// light clients can follow the epochs through the certificates alone.
+func (x *S2PoS_v2) voteGapNumber(number uint64) uint64 {
+	if number > 0 && number%x.config.Epoch == 0 {
+		return x.gapNumber(number - 1)
+	}
+	return x.gapNumber(number)
+}
+
+// getMasternodesByGapNumber returns the masternodes of the epoch whose set was
+// picked at the given gap block.
+func (x *S2PoS_v2) getMasternodesByGapNumber(chain consensus.ChainReader, gapNumber uint64, parents []*types.Header) ([]common.Address, error) {
+	checkpoint := uint64(0)
+	if gapNumber != 0 {
+		checkpoint = gapNumber + x.config.Gap
+	}
+	header := findHeaderByNumber(chain, checkpoint, parents)
+	if header == nil {
+		return nil, fmt.Errorf("checkpoint block %d of gap number %d not found", checkpoint, gapNumber)
+	}
+	return x.GetMasternodesFromCheckpointHeader(header, checkpoint, x.config.Epoch), nil
+}
+
+// roundOf returns the round of a block, blocks up to the switch block have
+// round 0.
+func (x *S2PoS_v2) roundOf(header *types.Header) (utils.Round, error) {
+	if !x.isV2Block(header.Number.Uint64()) {
+		return 0, nil
+	}
+	extra, err := decodeExtra(header)
+	if err != nil {
+		return 0, err
+	}
+	return extra.Round, nil
+}
@@ -0,0 +1,563 @@
+package engine_v2
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FRECNET/accounts"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/params"
+)
+
+// testChain is a minimal in-memory chain reader.
+type testChain struct {
+	config  *params.ChainConfig
+	lock    sync.RWMutex
+	headers map[common.Hash]*types.Header
+	numbers map[uint64]*types.Header
+	head    *types.Header
+}
+
+func (c *testChain) Config() *params.ChainConfig { return c.config }
+
+func (c *testChain) CurrentHeader() *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.head
+}
+
+func (c *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if header, ok := c.headers[hash]; ok && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+func (c *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.numbers[number]
+}
+
+func (c *testChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.headers[hash]
+}
+
+func (c *testChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if header := c.GetHeader(hash, number); header != nil {
+		return types.NewBlockWithHeader(header)
+	}
+	return nil
+}
+
+func (c *testChain) insert(header *types.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.headers[header.Hash()] = header
+	c.numbers[header.Number.Uint64()] = header
+	c.head = header
+}
+
+type testNode struct {
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+	engine *S2PoS_v2
+}
+
+var testKeys = []string{
+	"8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a",
+	"49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee",
+	"b71c71a67e1177ad4e901695e1b4b9ee04aefe388d1e14474d32c45c72ce7b7a",
+}
+
+// newTestNetwork builds a v1 chain up to the switch block 10, whose genesis
+// checkpoint elects the masternodes, and one v2 engine per masternode.
+func newTestNetwork(t *testing.T) (*testChain, []*testNode) {
+	config := &params.S2PoSConfig{
+		Period:                 2,
+		Epoch:                  900,
+		Gap:                    450,
+		RewardCheckpoint:       900,
+		V2ConsensusBlockNumber: big.NewInt(10),
+		V2: &params.V2Config{
+			CertThreshold:        0.667,
+			TimeoutPeriod:        100,
+			TimeoutSyncThreshold: 3,
+			MinePeriod:           2,
+		},
+	}
+	chain := &testChain{
+		config:  &params.ChainConfig{ChainId: big.NewInt(1337), S2PoS: config},
+		headers: make(map[common.Hash]*types.Header),
+		numbers: make(map[uint64]*types.Header),
+	}
+	var nodes []*testNode
+	extra := make([]byte, utils.ExtraVanity)
+	for _, hexKey := range testKeys {
+		key, err := crypto.HexToECDSA(hexKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := &testNode{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+		extra = append(extra, node.addr.Bytes()...)
+		nodes = append(nodes, node)
+	}
+	extra = append(extra, make([]byte, utils.ExtraSeal)...)
+
+	parent := &types.Header{Number: big.NewInt(0), Time: big.NewInt(0), Extra: extra, Difficulty: big.NewInt(1)}
+	chain.insert(parent)
+	for i := int64(1); i <= 10; i++ {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     big.NewInt(i),
+			Time:       big.NewInt(i * 2),
+			Difficulty: big.NewInt(1),
+		}
+		chain.insert(header)
+		parent = header
+	}
+	for _, node := range nodes {
+		key := node.key
+		node.engine = New(config, rawdb.NewMemoryDatabase())
+		node.engine.Authorize(node.addr, func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+	}
+	return chain, nodes
+}
+
+// receive returns the next message broadcasted by the engine.
+func receive(t *testing.T, engine *S2PoS_v2) interface{} {
+	select {
+	case msg := <-engine.BroadcastCh:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message broadcasted")
+	}
+	return nil
+}
+
+// relay hands over a message broadcasted by one node to all the other ones.
+func relay(t *testing.T, chain *testChain, nodes []*testNode, from int, msg interface{}) {
+	for i, node := range nodes {
+		if i == from {
+			continue
+		}
+		switch m := msg.(type) {
+		case *utils.Vote:
+			if ok, err := node.engine.VerifyVoteMessage(chain, m); err != nil || !ok {
+				t.Fatalf("node %d: vote not verified: %v", i, err)
+			}
+			if err := node.engine.VoteHandler(chain, m); err != nil {
+				t.Fatalf("node %d: %v", i, err)
+			}
+		case *utils.Timeout:
+			if ok, err := node.engine.VerifyTimeoutMessage(chain, m); err != nil || !ok {
+				t.Fatalf("node %d: timeout not verified: %v", i, err)
+			}
+			if err := node.engine.TimeoutHandler(chain, m); err != nil {
+				t.Fatalf("node %d: %v", i, err)
+			}
+		default:
+			t.Fatalf("unexpected message %T", msg)
+		}
+	}
+}
+
+// proposeBlock lets the leader of the current round propose a block on top of
+// the chain head and returns it once sealed.
+func proposeBlock(t *testing.T, chain *testChain, nodes []*testNode) (*types.Header, int) {
+	parent := chain.CurrentHeader()
+	leader := -1
+	for i, node := range nodes {
+		_, _, _, ok, err := node.engine.YourTurn(chain, parent, node.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if leader != -1 {
+				t.Fatalf("both node %d and %d are leaders", leader, i)
+			}
+			leader = i
+		}
+	}
+	if leader == -1 {
+		t.Fatal("no leader for the round")
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		UncleHash:  utils.UncleHash,
+		GasLimit:   params.TargetGasLimit,
+	}
+	engine := nodes[leader].engine
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatal(err)
+	}
+	block, err := engine.Seal(chain, types.NewBlockWithHeader(header), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Seal(chain, types.NewBlockWithHeader(header), nil); err != utils.ErrAlreadyMined {
+		t.Fatalf("expected %v when sealing twice in a round, got %v", utils.ErrAlreadyMined, err)
+	}
+	return block.Header(), leader
+}
+
+// certify lets every node vote for the header and relays the votes.
+func certify(t *testing.T, chain *testChain, nodes []*testNode, header *types.Header) {
+	votes := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		if err := node.engine.ProposedBlockHandler(chain, header); err != nil {
+			t.Fatal(err)
+		}
+		votes[i] = receive(t, node.engine)
+	}
+	for i, vote := range votes {
+		relay(t, chain, nodes, i, vote)
+	}
+}
+
+func TestProposeVoteAndCommit(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	var headers []*types.Header
+	for round := utils.Round(1); round <= 3; round++ {
+		header, leader := proposeBlock(t, chain, nodes)
+		if header.Coinbase != nodes[leader].addr {
+			t.Fatalf("coinbase mismatch: have %x, want %x", header.Coinbase, nodes[leader].addr)
+		}
+		for i, node := range nodes {
+			if err := node.engine.VerifyHeader(chain, header, true); err != nil {
+				t.Fatalf("node %d rejected block %d: %v", i, header.Number, err)
+			}
+			if author, err := node.engine.Author(header); err != nil || author != nodes[leader].addr {
+				t.Fatalf("node %d: wrong author %x, %v", i, author, err)
+			}
+		}
+		chain.insert(header)
+		certify(t, chain, nodes, header)
+
+		for i, node := range nodes {
+			info := node.engine.GetRoundInfo()
+			if info.CurrentRound != round+1 {
+				t.Fatalf("node %d: current round %d, want %d", i, info.CurrentRound, round+1)
+			}
+			if info.HighestQuorumCert.ProposedBlockInfo.Hash != header.Hash() {
+				t.Fatalf("node %d: highest QC doesn't certify block %d", i, header.Number)
+			}
+		}
+		headers = append(headers, header)
+	}
+	// Blocks 11, 12 and 13 have consecutive rounds, so block 11 is committed
+	for i, node := range nodes {
+		commit := node.engine.GetRoundInfo().HighestCommitBlock
+		if commit == nil || commit.Hash != headers[0].Hash() {
+			t.Fatalf("node %d: block 11 not committed, have %v", i, commit)
+		}
+		if hash := rawdb.ReadFinalizedBlockHash(node.engine.db); hash != headers[0].Hash() {
+			t.Fatalf("node %d: finalized block %x, want %x", i, hash, headers[0].Hash())
+		}
+		if hash := rawdb.ReadSafeBlockHash(node.engine.db); hash != headers[2].Hash() {
+			t.Fatalf("node %d: safe block %x, want %x", i, hash, headers[2].Hash())
+		}
+		qc, err := node.engine.GetCommittedQuorumCert(headers[0].Hash())
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		if qc.ProposedBlockInfo.Hash != headers[0].Hash() {
+			t.Fatalf("node %d: committed QC certifies %x, want %x", i, qc.ProposedBlockInfo.Hash, headers[0].Hash())
+		}
+		if err := node.engine.verifyQC(chain, qc, nil); err != nil {
+			t.Fatalf("node %d: committed QC not verified: %v", i, err)
+		}
+	}
+}
+
+func TestVerifyHeaderNotLeader(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	parent := chain.CurrentHeader()
+	for _, node := range nodes {
+		if _, _, _, _, err := node.engine.YourTurn(chain, parent, node.addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Round 1 belongs to the second masternode, let the first one propose
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     big.NewInt(11),
+		UncleHash:  utils.UncleHash,
+	}
+	if err := nodes[0].engine.Prepare(chain, header); err != nil {
+		t.Fatal(err)
+	}
+	block, err := nodes[0].engine.Seal(chain, types.NewBlockWithHeader(header), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[1].engine.VerifyHeader(chain, block.Header(), true); err != utils.ErrNotItsTurn {
+		t.Fatalf("expected %v, got %v", utils.ErrNotItsTurn, err)
+	}
+}
+
+func TestTimeoutCertificate(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	parent := chain.CurrentHeader()
+	timeouts := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		if _, _, _, _, err := node.engine.YourTurn(chain, parent, node.addr); err != nil {
+			t.Fatal(err)
+		}
+		if err := node.engine.onCountdownTimeout(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		timeouts[i] = receive(t, node.engine)
+	}
+	for i, timeout := range timeouts {
+		relay(t, chain, nodes, i, timeout)
+	}
+	for i, node := range nodes {
+		info := node.engine.GetRoundInfo()
+		if info.CurrentRound != 2 {
+			t.Fatalf("node %d: current round %d, want 2", i, info.CurrentRound)
+		}
+		if info.HighestTimeoutCert == nil || info.HighestTimeoutCert.Round != 1 {
+			t.Fatalf("node %d: no timeout certificate for round 1", i)
+		}
+		if err := node.engine.verifyTC(chain, info.HighestTimeoutCert); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+	// The leader of round 2 proposes on top of the switch block
+	header, _ := proposeBlock(t, chain, nodes)
+	if header.Difficulty.Uint64() != 2 {
+		t.Fatalf("difficulty %v, want 2", header.Difficulty)
+	}
+	for i, node := range nodes {
+		if err := node.engine.VerifyHeader(chain, header, true); err != nil {
+			t.Fatalf("node %d rejected block: %v", i, err)
+		}
+	}
+}
+
+func TestVerifyTimeoutGapNumber(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	// The switch block lies in the first epoch, whose gap number is 0
+	for _, gapNumber := range []uint64{0, 450} {
+		signature, err := nodes[0].engine.signSignature(utils.TimeoutSigHash(&utils.TimeoutForSign{
+			Round:     1,
+			GapNumber: gapNumber,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout := &utils.Timeout{Round: 1, Signature: signature, GapNumber: gapNumber}
+		ok, err := nodes[1].engine.VerifyTimeoutMessage(chain, timeout)
+		if gapNumber == 0 && (err != nil || !ok) {
+			t.Fatalf("timeout of the round epoch not verified: %v", err)
+		}
+		if gapNumber != 0 && err == nil {
+			t.Fatalf("timeout with gap number %d verified", gapNumber)
+		}
+	}
+}
+
+func TestProcessQCUnknownBlock(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	engine := nodes[0].engine
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	if err := engine.initial(chain); err != nil {
+		t.Fatal(err)
+	}
+	highestQC := engine.highestQuorumCert
+	qc := &utils.QuorumCert{
+		ProposedBlockInfo: &utils.BlockInfo{
+			Hash:   common.HexToHash("0x1234"),
+			Round:  5,
+			Number: big.NewInt(11),
+		},
+	}
+	if err := engine.processQC(chain, qc); err != nil {
+		t.Fatal(err)
+	}
+	if engine.highestQuorumCert != highestQC {
+		t.Fatalf("highest QC moved to an unknown block")
+	}
+	if hash := rawdb.ReadSafeBlockHash(engine.db); hash != (common.Hash{}) {
+		t.Fatalf("safe block moved to unknown block %x", hash)
+	}
+	if engine.currentRound != 6 {
+		t.Fatalf("current round %d, want 6", engine.currentRound)
+	}
+}
+
+func TestProcessQCSideBlock(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	// Keep the proposed block out of the canonical chain
+	header, _ := proposeBlock(t, chain, nodes)
+	chain.lock.Lock()
+	chain.headers[header.Hash()] = header
+	chain.lock.Unlock()
+
+	engine := nodes[0].engine
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	qc := &utils.QuorumCert{
+		ProposedBlockInfo: &utils.BlockInfo{
+			Hash:   header.Hash(),
+			Round:  1,
+			Number: header.Number,
+		},
+	}
+	if err := engine.processQC(chain, qc); err != nil {
+		t.Fatal(err)
+	}
+	if engine.highestQuorumCert != qc {
+		t.Fatalf("highest QC not moved to the side block")
+	}
+	if hash := rawdb.ReadSafeBlockHash(engine.db); hash != (common.Hash{}) {
+		t.Fatalf("safe block moved to side block %x", hash)
+	}
+}
+
+func TestVoteGapNumber(t *testing.T) {
+	_, nodes := newTestNetwork(t)
+
+	// A checkpoint is certified by the masternodes of the epoch it closes
+	engine := nodes[0].engine
+	for number, want := range map[uint64]uint64{899: 0, 900: 0, 901: 450, 1799: 450, 1800: 450, 1801: 1350} {
+		if gapNumber := engine.voteGapNumber(number); gapNumber != want {
+			t.Errorf("block %d: vote gap number mismatch: have %d, want %d", number, gapNumber, want)
+		}
+	}
+}
+
+func TestRewardsWithoutValidator(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+
+	engine := nodes[0].engine
+	engine.HookReward = func(chain consensus.ChainReader, state *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{}) {
+		return nil, map[string]interface{}{"rewards": header.Number.Uint64()}
+	}
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{
+		ParentHash: chain.CurrentHeader().Hash(),
+		Number:     big.NewInt(900),
+		Time:       big.NewInt(1800),
+		Difficulty: big.NewInt(1),
+	}
+	block, err := engine.Finalize(chain, header, statedb, statedb, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fetcher finalizes the blocks missing the validator signature
+	signed := block.Header()
+	signed.Validator = []byte{1, 2, 3}
+	if rewards := engine.GetRewards(signed); rewards == nil || rewards["rewards"] != uint64(900) {
+		t.Fatalf("rewards mismatch: have %v, want 900", rewards)
+	}
+}
+
+// extendChain appends headers up to the given number to the chain, the
+// checkpoints carrying a quorum certificate for their parent.
+func extendChain(t *testing.T, chain *testChain, number uint64) {
+	parent := chain.CurrentHeader()
+	for i := parent.Number.Uint64() + 1; i <= number; i++ {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).SetUint64(i),
+			Time:       big.NewInt(int64(i) * 2),
+			Difficulty: big.NewInt(1),
+		}
+		if i%chain.config.S2PoS.Epoch == 0 {
+			extra := utils.ExtraFields_v2{
+				Round: utils.Round(i),
+				QuorumCert: &utils.QuorumCert{
+					ProposedBlockInfo: &utils.BlockInfo{Hash: parent.Hash(), Round: utils.Round(i - 1), Number: parent.Number},
+					Signatures:        []utils.Signature{{1}, {2}, {3}},
+				},
+			}
+			data, err := extra.EncodeToBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			header.Extra = data
+		}
+		chain.insert(header)
+		parent = header
+	}
+}
+
+func TestStakeWeightedLeaders(t *testing.T) {
+	chain, nodes := newTestNetwork(t)
+	chain.config.Forks = &params.ForksConfig{
+		TIPDynamicMasternodesBlock:    big.NewInt(900),
+		TIPStakeWeightedProposerBlock: big.NewInt(900),
+	}
+	engine := nodes[0].engine
+	extendChain(t, chain, 450)
+
+	// The whole stake is on the last candidate
+	ms := make([]utils.Masternode, len(nodes))
+	masternodes := make([]common.Address, len(nodes))
+	for i, node := range nodes {
+		ms[i] = utils.Masternode{Address: node.addr, Stake: new(big.Int)}
+		masternodes[i] = node.addr
+	}
+	ms[len(ms)-1].Stake.SetUint64(100)
+	if err := engine.UpdateMasternodes(chain, chain.CurrentHeader(), ms); err != nil {
+		t.Fatal(err)
+	}
+	extendChain(t, chain, 900)
+
+	for round := utils.Round(0); round < 20; round++ {
+		// The checkpoint is still led in turns
+		leader, err := engine.roundLeader(chain, 900, round, masternodes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := masternodes[uint64(round)%uint64(len(masternodes))]; leader != want {
+			t.Errorf("checkpoint round %d: leader mismatch: have %x, want %x", round, leader, want)
+		}
+		if leader, err = engine.roundLeader(chain, 901, round, masternodes, nil); err != nil {
+			t.Fatal(err)
+		}
+		if want := nodes[len(nodes)-1].addr; leader != want {
+			t.Errorf("round %d: leader mismatch: have %x, want %x", round, leader, want)
+		}
+	}
+
+	// The configured set size caps the candidates, ordered by stake
+	engine.config.MaxMasternodes = 2
+	defer func() { engine.config.MaxMasternodes = 0 }()
+	checkpoint := chain.CurrentHeader()
+	masternodes, _, err := engine.calcMasternodes(chain, checkpoint.Number, checkpoint.ParentHash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(masternodes) != 2 {
+		t.Errorf("masternode set size mismatch: have %d, want 2", len(masternodes))
+	}
+}
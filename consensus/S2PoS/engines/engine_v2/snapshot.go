@@ -1 +1,78 @@
 package engine_v2
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+)
+
+// SnapshotV2 keeps the masternode candidates picked at a gap block, they become
+// the masternodes of the next epoch once the penalties are removed.
+type SnapshotV2 struct {
+	Number uint64      `json:"number"` // Block number where the snapshot was created
+	Hash   common.Hash `json:"hash"`   // Block hash where the snapshot was created
+
+	// MasterNodes will get assigned on updateM1
+	NextEpochMasterNodes []common.Address `json:"masterNodes"`      // Set of authorized master nodes at this moment for next epoch
+	Stakes               []*big.Int       `json:"stakes,omitempty"` // Stakes of the master nodes, the round leaders are drawn by
+}
+
+// newSnapshot creates a new snapshot with the specified startup parameters.
+func newSnapshot(number uint64, hash common.Hash, masternodes []common.Address, stakes []*big.Int) *SnapshotV2 {
+	return &SnapshotV2{
+		Number:               number,
+		Hash:                 hash,
+		NextEpochMasterNodes: masternodes,
+		Stakes:               stakes,
+	}
+}
+
+// loadSnapshot loads an existing snapshot from the database.
+func loadSnapshot(db ethdb.Database, hash common.Hash) (*SnapshotV2, error) {
+	blob, err := db.Get(append([]byte("S2PoS-V2-"), hash[:]...))
+	if err != nil {
+		return nil, err
+	}
+	snap := new(SnapshotV2)
+	if err := json.Unmarshal(blob, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// store inserts the snapshot into the database.
+func storeSnapshot(snap *SnapshotV2, db ethdb.Database) error {
+	blob, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return db.Put(append([]byte("S2PoS-V2-"), snap.Hash[:]...), blob)
+}
+
+// IsMasterNodes reports whether the address is one of the snapshot candidates.
+func (s *SnapshotV2) IsMasterNodes(address common.Address) bool {
+	for _, n := range s.NextEpochMasterNodes {
+		if n == address {
+			return true
+		}
+	}
+	return false
+}
+
+// stakes returns the stakes of the given masternodes, nil for the ones the
+// snapshot doesn't have a stake of.
+func (s *SnapshotV2) stakes(masternodes []common.Address) []*big.Int {
+	stakes := make(map[common.Address]*big.Int)
+	for i, stake := range s.Stakes {
+		if i < len(s.NextEpochMasterNodes) {
+			stakes[s.NextEpochMasterNodes[i]] = stake
+		}
+	}
+	result := make([]*big.Int, len(masternodes))
+	for i, m := range masternodes {
+		result[i] = stakes[m]
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+package engine_v2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/log"
+)
+
+// VerifyTimeoutMessage checks that the timeout is signed by a masternode of the
+// epoch of the round, the one of the highest certified block. It returns false
+// without error for stale timeouts, which don't need to be relayed to the peers.
+func (x *S2PoS_v2) VerifyTimeoutMessage(chain consensus.ChainReader, timeout *utils.Timeout) (bool, error) {
+	x.lock.Lock()
+	if err := x.initial(chain); err != nil {
+		x.lock.Unlock()
+		return false, err
+	}
+	currentRound := x.currentRound
+	gapNumber := x.gapNumber(x.highestQuorumCert.ProposedBlockInfo.Number.Uint64())
+	x.lock.Unlock()
+	if timeout.Round < currentRound {
+		log.Debug("Received a stale timeout", "timeoutRound", timeout.Round, "currentRound", currentRound)
+		return false, nil
+	}
+	if timeout.GapNumber != gapNumber {
+		return false, fmt.Errorf("timeout gap number %d doesn't match the epoch of round %d, expected %d", timeout.GapNumber, timeout.Round, gapNumber)
+	}
+	masternodes, err := x.getMasternodesByGapNumber(chain, timeout.GapNumber, nil)
+	if err != nil {
+		return false, err
+	}
+	signedHash := utils.TimeoutSigHash(&utils.TimeoutForSign{
+		Round:     timeout.Round,
+		GapNumber: timeout.GapNumber,
+	})
+	verified, signer, err := verifyMsgSignature(signedHash, timeout.Signature, masternodes)
+	if err != nil {
+		return false, err
+	}
+	if !verified {
+		return false, fmt.Errorf("timeout signed by %v which isn't a masternode", signer.Hex())
+	}
+	return true, nil
+}
+
+// TimeoutHandler collects a verified timeout and builds the timeout certificate
+// once enough masternodes timed out in the same round.
+func (x *S2PoS_v2) TimeoutHandler(chain consensus.ChainReader, timeout *utils.Timeout) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err := x.initial(chain); err != nil {
+		return err
+	}
+	return x.timeoutHandler(chain, timeout)
+}
+
+func (x *S2PoS_v2) timeoutHandler(chain consensus.ChainReader, timeout *utils.Timeout) error {
+	// The round has already been left
+	if timeout.Round < x.currentRound {
+		return nil
+	}
+	numberOfTimeouts, pooledTimeouts := x.timeoutPool.Add(timeout)
+	log.Debug("[timeoutHandler] collect timeouts", "number", numberOfTimeouts, "round", timeout.Round)
+
+	masternodes, err := x.getMasternodesByGapNumber(chain, timeout.GapNumber, nil)
+	if err != nil {
+		return err
+	}
+	if !x.certThreshold(numberOfTimeouts, len(masternodes)) {
+		return nil
+	}
+	signedHash := utils.TimeoutSigHash(&utils.TimeoutForSign{
+		Round:     timeout.Round,
+		GapNumber: timeout.GapNumber,
+	})
+	var signatures []utils.Signature
+	for _, obj := range pooledTimeouts {
+		signatures = append(signatures, obj.(*utils.Timeout).Signature)
+	}
+	signatures = uniqueSignatures(signedHash, signatures)
+	if !x.certThreshold(len(signatures), len(masternodes)) {
+		return nil
+	}
+	tc := &utils.TimeoutCert{
+		Round:      timeout.Round,
+		Signatures: signatures,
+		GapNumber:  timeout.GapNumber,
+	}
+	x.timeoutPool.ClearPoolKeyByObj(timeout)
+	log.Info("Successfully processed the timeouts and produced TC!", "round", tc.Round, "timeouts", len(signatures))
+	x.processTC(tc)
+	return nil
+}
+
+// onCountdownTimeout is fired by the timeout worker when the current round
+// lasted longer than TimeoutPeriod.
+func (x *S2PoS_v2) onCountdownTimeout(time time.Time) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if err := x.sendTimeout(x.chain); err != nil {
+		return err
+	}
+	x.timeoutCount++
+	if threshold := x.config.V2Params().TimeoutSyncThreshold; threshold > 0 && x.timeoutCount%threshold == 0 {
+		log.Warn("[onCountdownTimeout] timeout sync threshold reached, send syncInfo message", "round", x.currentRound, "timeouts", x.timeoutCount)
+		x.broadcastToBftChannel(x.getSyncInfo())
+	}
+	return nil
+}
+
+// sendTimeout signs a timeout for the current round and hands it over to the
+// peers and to the local timeout pool. It must be called with x.lock held.
+func (x *S2PoS_v2) sendTimeout(chain consensus.ChainReader) error {
+	x.signLock.RLock()
+	signer := x.signer
+	x.signLock.RUnlock()
+
+	gapNumber := x.gapNumber(x.highestQuorumCert.ProposedBlockInfo.Number.Uint64())
+	masternodes, err := x.getMasternodesByGapNumber(chain, gapNumber, nil)
+	if err != nil {
+		return err
+	}
+	if !isMasternode(signer, masternodes) {
+		return nil
+	}
+	signature, err := x.signSignature(utils.TimeoutSigHash(&utils.TimeoutForSign{
+		Round:     x.currentRound,
+		GapNumber: gapNumber,
+	}))
+	if err != nil {
+		return err
+	}
+	timeout := &utils.Timeout{
+		Round:     x.currentRound,
+		Signature: signature,
+		GapNumber: gapNumber,
+	}
+	log.Warn("[sendTimeout] Timeout message generated, ready to send!", "round", timeout.Round, "gapNumber", gapNumber)
+	x.broadcastToBftChannel(timeout)
+	return x.timeoutHandler(chain, timeout)
+}
@@ -0,0 +1,160 @@
+package engine_v2
+
+import (
+	"fmt"
+
+	"github.com/FRECNET/accounts"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/crypto/sha3"
+	"github.com/FRECNET/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// SigHash returns the hash which is signed by the proposer of a v2 block. The
+// seal itself lives in header.Validator, so that's the only field left out.
+func SigHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+
+	rlp.Encode(hasher, []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra,
+		header.MixDigest,
+		header.Nonce,
+		header.Validators,
+		header.Penalties,
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// ecrecover extracts the Ethereum account address from a signed v2 header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	if len(header.Validator) != utils.ExtraSeal {
+		return common.Address{}, utils.ErrMissingSignature
+	}
+	// Recover the public key and the Ethereum address
+	pubkey, err := crypto.Ecrecover(SigHash(header).Bytes(), header.Validator)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// recoverMsgSigner returns the masternode which signed a vote or a timeout.
+func recoverMsgSigner(signedHash common.Hash, signature utils.Signature) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(signedHash.Bytes(), signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error while recovering the message signer: %v", err)
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// verifyMsgSignature checks that the signature has been produced by one of the
+// masternodes and returns its author.
+func verifyMsgSignature(signedHash common.Hash, signature utils.Signature, masternodes []common.Address) (bool, common.Address, error) {
+	if len(masternodes) == 0 {
+		return false, common.Address{}, fmt.Errorf("empty masternode list")
+	}
+	signer, err := recoverMsgSigner(signedHash, signature)
+	if err != nil {
+		return false, common.Address{}, err
+	}
+	for _, mn := range masternodes {
+		if mn == signer {
+			return true, signer, nil
+		}
+	}
+	return false, signer, nil
+}
+
+// signSignature signs the hash with the authorized masternode key.
+func (x *S2PoS_v2) signSignature(signingHash common.Hash) (utils.Signature, error) {
+	x.signLock.RLock()
+	signer, signFn := x.signer, x.signFn
+	x.signLock.RUnlock()
+
+	if signFn == nil {
+		return nil, utils.ErrUnauthorized
+	}
+	signedHash, err := signFn(accounts.Account{Address: signer}, signingHash.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error while signing the hash: %v", err)
+	}
+	return signedHash, nil
+}
+
+// isMasternode reports whether the address belongs to the masternode list.
+func isMasternode(address common.Address, masternodes []common.Address) bool {
+	for _, mn := range masternodes {
+		if mn == address {
+			return true
+		}
+	}
+	return false
+}
+
+// certThreshold reports whether the number of signatures is enough to build a
+// certificate over the given masternode set.
+func (x *S2PoS_v2) certThreshold(signatures int, masternodes int) bool {
+	return masternodes > 0 && float64(signatures) >= float64(masternodes)*x.config.V2Params().CertThreshold
+}
+
+// decodeExtra returns the v2 extra fields of a header.
+func decodeExtra(header *types.Header) (*utils.ExtraFields_v2, error) {
+	var extra utils.ExtraFields_v2
+	if err := utils.DecodeBytesExtraFields(header.Extra, &extra); err != nil {
+		return nil, utils.ErrInvalidV2Extra
+	}
+	if extra.QuorumCert == nil || extra.QuorumCert.ProposedBlockInfo == nil || extra.QuorumCert.ProposedBlockInfo.Number == nil {
+		return nil, utils.ErrInvalidV2Extra
+	}
+	return &extra, nil
+}
+
+// findHeader looks the header up in the batch of not yet imported parents
+// first, then in the chain.
+func findHeader(chain consensus.ChainReader, hash common.Hash, number uint64, parents []*types.Header) *types.Header {
+	for i := len(parents) - 1; i >= 0; i-- {
+		if parents[i].Hash() == hash {
+			return parents[i]
+		}
+	}
+	return chain.GetHeader(hash, number)
+}
+
+// findHeaderByNumber looks the canonical header up in the batch of not yet
+// imported parents first, then in the chain.
+func findHeaderByNumber(chain consensus.ChainReader, number uint64, parents []*types.Header) *types.Header {
+	for i := len(parents) - 1; i >= 0; i-- {
+		if parents[i].Number.Uint64() == number {
+			return parents[i]
+		}
+	}
+	return chain.GetHeaderByNumber(number)
+}
@@ -0,0 +1,168 @@
+package engine_v2
+
+import (
+	"fmt"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/log"
+)
+
+// VerifyVoteMessage checks that the vote is signed by a masternode of the voted
+// block epoch. It returns false without error for stale votes, which don't
+// need to be relayed to the peers.
+func (x *S2PoS_v2) VerifyVoteMessage(chain consensus.ChainReader, vote *utils.Vote) (bool, error) {
+	if vote.ProposedBlockInfo == nil || vote.ProposedBlockInfo.Number == nil {
+		return false, fmt.Errorf("vote without proposed block info")
+	}
+	x.lock.RLock()
+	currentRound := x.currentRound
+	x.lock.RUnlock()
+	if vote.ProposedBlockInfo.Round < currentRound {
+		log.Debug("Received a stale vote", "voteRound", vote.ProposedBlockInfo.Round, "currentRound", currentRound)
+		return false, nil
+	}
+	if vote.GapNumber != x.voteGapNumber(vote.ProposedBlockInfo.Number.Uint64()) {
+		return false, fmt.Errorf("vote gap number %d doesn't match block %v", vote.GapNumber, vote.ProposedBlockInfo.Number)
+	}
+	masternodes, err := x.getMasternodesByGapNumber(chain, vote.GapNumber, nil)
+	if err != nil {
+		return false, err
+	}
+	signedHash := utils.VoteSigHash(&utils.VoteForSign{
+		ProposedBlockInfo: vote.ProposedBlockInfo,
+		GapNumber:         vote.GapNumber,
+	})
+	verified, signer, err := verifyMsgSignature(signedHash, vote.Signature, masternodes)
+	if err != nil {
+		return false, err
+	}
+	if !verified {
+		return false, fmt.Errorf("vote signed by %v which isn't a masternode", signer.Hex())
+	}
+	return true, nil
+}
+
+// VoteHandler collects a verified vote and builds the quorum certificate once
+// enough masternodes voted for the same block.
+func (x *S2PoS_v2) VoteHandler(chain consensus.ChainReader, vote *utils.Vote) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err := x.initial(chain); err != nil {
+		return err
+	}
+	return x.voteHandler(chain, vote)
+}
+
+func (x *S2PoS_v2) voteHandler(chain consensus.ChainReader, vote *utils.Vote) error {
+	// The certificate of this round is already known
+	if vote.ProposedBlockInfo.Round < x.currentRound {
+		return nil
+	}
+	numberOfVotes, pooledVotes := x.votePool.Add(vote)
+	log.Debug("[voteHandler] collect votes", "number", numberOfVotes, "round", vote.ProposedBlockInfo.Round, "hash", vote.ProposedBlockInfo.Hash)
+
+	masternodes, err := x.getMasternodesByGapNumber(chain, vote.GapNumber, nil)
+	if err != nil {
+		return err
+	}
+	if !x.certThreshold(numberOfVotes, len(masternodes)) {
+		return nil
+	}
+	signedHash := utils.VoteSigHash(&utils.VoteForSign{
+		ProposedBlockInfo: vote.ProposedBlockInfo,
+		GapNumber:         vote.GapNumber,
+	})
+	var signatures []utils.Signature
+	for _, obj := range pooledVotes {
+		signatures = append(signatures, obj.(*utils.Vote).Signature)
+	}
+	signatures = uniqueSignatures(signedHash, signatures)
+	if !x.certThreshold(len(signatures), len(masternodes)) {
+		return nil
+	}
+	qc := &utils.QuorumCert{
+		ProposedBlockInfo: vote.ProposedBlockInfo,
+		Signatures:        signatures,
+		GapNumber:         vote.GapNumber,
+	}
+	x.votePool.ClearPoolKeyByObj(vote)
+	log.Info("Successfully processed the vote and produced QC!", "round", qc.ProposedBlockInfo.Round, "number", qc.ProposedBlockInfo.Number, "hash", qc.ProposedBlockInfo.Hash, "votes", len(signatures))
+	return x.processQC(chain, qc)
+}
+
+// sendVote signs a vote for the block and hands it over to the peers and to
+// the local vote pool. It must be called with x.lock held.
+func (x *S2PoS_v2) sendVote(chain consensus.ChainReader, blockInfo *utils.BlockInfo) error {
+	x.signLock.RLock()
+	signer := x.signer
+	x.signLock.RUnlock()
+
+	gapNumber := x.voteGapNumber(blockInfo.Number.Uint64())
+	masternodes, err := x.getMasternodesByGapNumber(chain, gapNumber, nil)
+	if err != nil {
+		return err
+	}
+	if !isMasternode(signer, masternodes) {
+		return nil
+	}
+	signature, err := x.signSignature(utils.VoteSigHash(&utils.VoteForSign{
+		ProposedBlockInfo: blockInfo,
+		GapNumber:         gapNumber,
+	}))
+	if err != nil {
+		return err
+	}
+	vote := &utils.Vote{
+		ProposedBlockInfo: blockInfo,
+		Signature:         signature,
+		GapNumber:         gapNumber,
+	}
+	x.highestVotedRound = blockInfo.Round
+	x.broadcastToBftChannel(vote)
+	return x.voteHandler(chain, vote)
+}
+
+// verifyVotingRule reports whether the local masternode can vote for the block:
+// it must be proposed for the current round, not voted yet and extend the
+// locked block unless its certificate is more recent than the lock.
+func (x *S2PoS_v2) verifyVotingRule(chain consensus.ChainReader, header *types.Header, blockInfo *utils.BlockInfo, qc *utils.QuorumCert) bool {
+	if blockInfo.Round != x.currentRound {
+		log.Debug("[verifyVotingRule] block round isn't the current round", "blockRound", blockInfo.Round, "currentRound", x.currentRound)
+		return false
+	}
+	if blockInfo.Round <= x.highestVotedRound {
+		return false
+	}
+	if x.lockQuorumCert == nil || qc.ProposedBlockInfo.Round > x.lockQuorumCert.ProposedBlockInfo.Round {
+		return true
+	}
+	return isExtendingFrom(chain, header, x.lockQuorumCert.ProposedBlockInfo)
+}
+
+// isExtendingFrom reports whether the ancestor is part of the header's chain.
+func isExtendingFrom(chain consensus.ChainReader, header *types.Header, ancestor *utils.BlockInfo) bool {
+	ancestorNumber := ancestor.Number.Uint64()
+	for header != nil && header.Number.Uint64() > ancestorNumber {
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return header != nil && header.Hash() == ancestor.Hash
+}
+
+// uniqueSignatures drops the signatures produced by the same signer, or which
+// can't be recovered.
+func uniqueSignatures(signedHash common.Hash, signatures []utils.Signature) []utils.Signature {
+	seen := make(map[common.Address]bool)
+	var unique []utils.Signature
+	for _, signature := range signatures {
+		signer, err := recoverMsgSigner(signedHash, signature)
+		if err != nil || seen[signer] {
+			continue
+		}
+		seen[signer] = true
+		unique = append(unique, signature)
+	}
+	return unique
+}
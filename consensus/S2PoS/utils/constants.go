@@ -21,6 +21,9 @@ var (
 
 const (
 	InmemorySnapshots      = 128 // Number of recent vote snapshots to keep in memory
+	InmemoryRewards        = 16  // Number of finalized block rewards to keep in memory until the blocks are written
 	BlockSignersCacheLimit = 9000
 	M2ByteLength           = 4
+	ConsensusVersion2      = byte(2) // Leading byte of header.Extra for blocks sealed by the v2 engine
+	PoolHygieneRound       = 10      // Number of rounds a vote or timeout is kept in the pool
 )
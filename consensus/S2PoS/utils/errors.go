@@ -42,6 +42,14 @@ var (
 
 	ErrInvalidCheckpointPenalties = errors.New("invalid penalty list on checkpoint block")
 
+	// ErrMissingCheckpointStakes is returned if a checkpoint block past the
+	// TIPStakeWeightedProposer fork doesn't commit the stakes of its masternodes.
+	ErrMissingCheckpointStakes = errors.New("missing masternode stakes on checkpoint block")
+
+	// ErrInvalidCheckpointStakes is returned if the stakes committed by a
+	// checkpoint block don't match its masternodes or their stakes.
+	ErrInvalidCheckpointStakes = errors.New("invalid masternode stakes on checkpoint block")
+
 	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
 	ErrInvalidMixDigest = errors.New("non-zero mix digest")
 
@@ -71,4 +79,49 @@ var (
 	ErrWaitTransactions = errors.New("waiting for transactions")
 
 	ErrInvalidCheckpointValidators = errors.New("invalid validators list on checkpoint block")
+
+	// ErrEmptyExtraFields is returned if a v2 header doesn't carry any extra fields.
+	ErrEmptyExtraFields = errors.New("extra field is 0 length")
+
+	// ErrInvalidV2Extra is returned if the extra fields of a v2 header can't be decoded.
+	ErrInvalidV2Extra = errors.New("invalid v2 extra in the block")
+
+	// ErrInvalidQC is returned if a quorum certificate doesn't reach the threshold
+	// or carries signatures from non masternodes.
+	ErrInvalidQC = errors.New("invalid quorum certificate")
+
+	// ErrInvalidTC is returned if a timeout certificate doesn't reach the threshold
+	// or carries signatures from non masternodes.
+	ErrInvalidTC = errors.New("invalid timeout certificate")
+
+	// ErrInvalidRound is returned if a v2 block round isn't higher than its parent's.
+	ErrInvalidRound = errors.New("invalid round number")
+
+	// ErrNotItsTurn is returned if a v2 block is sealed by a masternode that isn't
+	// the leader of the block round.
+	ErrNotItsTurn = errors.New("not its turn to propose the block")
+
+	// ErrAlreadyMined is returned if the local masternode already proposed a block
+	// for the current round.
+	ErrAlreadyMined = errors.New("already mined a block in the current round")
+
+	// ErrInvalidCheckpointMasternodes is returned if a v2 checkpoint block carries an
+	// unexpected masternode list.
+	ErrInvalidCheckpointMasternodes = errors.New("invalid masternode list on v2 checkpoint block")
+
+	// ErrNotReadyToPropose is returned if the local masternode has no quorum
+	// certificate for the chain head yet.
+	ErrNotReadyToPropose = errors.New("not ready to propose a block yet")
+
+	// ErrNotCommitted is returned if no quorum certificate was recorded for a block
+	// because it isn't committed yet.
+	ErrNotCommitted = errors.New("block not committed")
+
+	// ErrNoPenaltyJournal is returned if no penalty journal was recorded for the
+	// checkpoint of an epoch.
+	ErrNoPenaltyJournal = errors.New("no penalty journal for epoch")
+
+	// ErrNoRandomizeJournal is returned if no randomness journal was recorded
+	// for the checkpoint of an epoch.
+	ErrNoRandomizeJournal = errors.New("no randomize journal for epoch")
 )
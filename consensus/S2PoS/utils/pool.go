@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/FRECNET/common"
+)
+
+// PoolObj is a consensus message collected by a Pool, e.g. a vote or a timeout.
+type PoolObj interface {
+	Hash() common.Hash
+	PoolKey() string
+}
+
+// Pool collects consensus messages grouped by their pool key until enough of
+// them are received to build a certificate.
+type Pool struct {
+	objList map[string]map[common.Hash]PoolObj
+	lock    sync.RWMutex
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		objList: make(map[string]map[common.Hash]PoolObj),
+	}
+}
+
+// Add inserts the object in the pool and returns the number of objects sharing
+// its pool key, together with a copy of them.
+func (p *Pool) Add(obj PoolObj) (int, map[common.Hash]PoolObj) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	poolKey := obj.PoolKey()
+	objListKeyed, ok := p.objList[poolKey]
+	if !ok {
+		objListKeyed = make(map[common.Hash]PoolObj)
+		p.objList[poolKey] = objListKeyed
+	}
+	objListKeyed[obj.Hash()] = obj
+	copied := make(map[common.Hash]PoolObj, len(objListKeyed))
+	for k, v := range objListKeyed {
+		copied[k] = v
+	}
+	return len(objListKeyed), copied
+}
+
+// Size returns the number of objects sharing the pool key of obj.
+func (p *Pool) Size(obj PoolObj) int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return len(p.objList[obj.PoolKey()])
+}
+
+// PoolObjKeysList returns all the pool keys currently in use.
+func (p *Pool) PoolObjKeysList() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var keyList []string
+	for key := range p.objList {
+		keyList = append(keyList, key)
+	}
+	return keyList
+}
+
+// ClearPoolKeyByObj drops every object sharing the pool key of obj.
+func (p *Pool) ClearPoolKeyByObj(obj PoolObj) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.objList, obj.PoolKey())
+}
+
+// ClearByPoolKey drops every object stored under poolKey.
+func (p *Pool) ClearByPoolKey(poolKey string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.objList, poolKey)
+}
+
+// Clear drops all the objects of the pool.
+func (p *Pool) Clear() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.objList = make(map[string]map[common.Hash]PoolObj)
+}
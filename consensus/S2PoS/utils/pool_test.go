@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/common"
+)
+
+func TestPoolAddAndClear(t *testing.T) {
+	pool := NewPool()
+	blockInfo := &BlockInfo{Hash: common.StringToHash("block"), Round: 5, Number: big.NewInt(100)}
+	vote1 := &Vote{ProposedBlockInfo: blockInfo, Signature: []byte{1}, GapNumber: 0}
+	vote2 := &Vote{ProposedBlockInfo: blockInfo, Signature: []byte{2}, GapNumber: 0}
+	timeout := &Timeout{Round: 5, Signature: []byte{1}, GapNumber: 0}
+
+	if n, _ := pool.Add(vote1); n != 1 {
+		t.Fatalf("pool size %d, want 1", n)
+	}
+	// Adding the same vote twice doesn't count it twice
+	if n, _ := pool.Add(vote1); n != 1 {
+		t.Fatalf("pool size %d, want 1", n)
+	}
+	n, votes := pool.Add(vote2)
+	if n != 2 || len(votes) != 2 {
+		t.Fatalf("pool size %d with %d votes, want 2", n, len(votes))
+	}
+	pool.Add(timeout)
+	if len(pool.PoolObjKeysList()) != 2 {
+		t.Fatalf("pool keys %v, want 2 keys", pool.PoolObjKeysList())
+	}
+	pool.ClearPoolKeyByObj(vote1)
+	if pool.Size(vote2) != 0 {
+		t.Fatalf("votes not cleared")
+	}
+	if pool.Size(timeout) != 1 {
+		t.Fatalf("timeout cleared with the votes")
+	}
+	pool.Clear()
+	if len(pool.PoolObjKeysList()) != 0 {
+		t.Fatalf("pool not cleared")
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"math/big"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/FRECNET/consensus/clique"
 	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto/sha3"
+	"github.com/FRECNET/rlp"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
@@ -27,10 +30,11 @@ type TradingService interface {
 	GetStateCache() tradingstate.Database
 	GetTriegc() *prque.Prque
 	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, FREXstatedb *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error)
-	UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error
+	UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB, keepEpochPrices bool) error
 	IsSDKNode() bool
 	SyncDataToSDKNode(takerOrder *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error
 	RollbackReorgTxMatch(txhash common.Hash) error
+	PostMatchingEvents(block *types.Block, tradingState *tradingstate.TradingStateDB, matches []tradingstate.OrderMatch, removed bool)
 	GetTokenDecimal(chain consensus.ChainContext, statedb *state.StateDB, tokenAddr common.Address) (*big.Int, error)
 }
 
@@ -47,6 +51,7 @@ type LendingService interface {
 	SyncDataToSDKNode(chain consensus.ChainContext, state *state.StateDB, block *types.Block, takerOrderInTx *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedOrders []*lendingstate.LendingItem, dirtyOrderCount *uint64) error
 	UpdateLiquidatedTrade(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error
 	RollbackLendingData(txhash common.Hash) error
+	PostLendingEvents(block *types.Block, trades []*lendingstate.LendingTrade, finalized lendingstate.FinalizedResult, finalizedTrades map[common.Hash]*lendingstate.LendingTrade, removed bool)
 }
 
 type PublicApiSnapshot struct {
@@ -57,3 +62,198 @@ type PublicApiSnapshot struct {
 	Votes   []*clique.Vote                  `json:"votes"`   // List of votes cast in chronological order
 	Tally   map[common.Address]clique.Tally `json:"tally"`   // Current vote tally to avoid recalculating
 }
+
+// Round number type in S2PoS 2.0
+type Round uint64
+
+// Signature produced by a masternode over a vote or a timeout
+type Signature []byte
+
+// BlockInfo identifies a proposed block in S2PoS 2.0
+type BlockInfo struct {
+	Hash   common.Hash `json:"hash"`
+	Round  Round       `json:"round"`
+	Number *big.Int    `json:"number"`
+}
+
+// Vote message in S2PoS 2.0
+type Vote struct {
+	ProposedBlockInfo *BlockInfo `json:"proposedBlockInfo"`
+	Signature         Signature  `json:"signature"`
+	GapNumber         uint64     `json:"gapNumber"`
+}
+
+// Timeout message in S2PoS 2.0
+type Timeout struct {
+	Round     Round     `json:"round"`
+	Signature Signature `json:"signature"`
+	GapNumber uint64    `json:"gapNumber"`
+}
+
+// SyncInfo is broadcasted by a node that keeps timing out, so lagging peers
+// can catch up with the highest certificates it knows about.
+type SyncInfo struct {
+	HighestQuorumCert  *QuorumCert  `json:"highestQuorumCert"`
+	HighestTimeoutCert *TimeoutCert `json:"highestTimeoutCert" rlp:"nil"`
+}
+
+// QuorumCert is the aggregation of enough votes on a proposed block
+type QuorumCert struct {
+	ProposedBlockInfo *BlockInfo  `json:"proposedBlockInfo"`
+	Signatures        []Signature `json:"signatures"`
+	GapNumber         uint64      `json:"gapNumber"`
+}
+
+// TimeoutCert is the aggregation of enough timeouts for a round
+type TimeoutCert struct {
+	Round      Round       `json:"round"`
+	Signatures []Signature `json:"signatures"`
+	GapNumber  uint64      `json:"gapNumber"`
+}
+
+// ExtraFields_v2 is the content of header.Extra for v2 blocks, prefixed by
+// the ConsensusVersion2 byte.
+type ExtraFields_v2 struct {
+	Round      Round
+	QuorumCert *QuorumCert
+}
+
+// VoteForSign is the part of a vote covered by the masternode signature.
+type VoteForSign struct {
+	ProposedBlockInfo *BlockInfo
+	GapNumber         uint64
+}
+
+// TimeoutForSign is the part of a timeout covered by the masternode signature.
+type TimeoutForSign struct {
+	Round     Round
+	GapNumber uint64
+}
+
+// PublicApiSnapshotV2 is the public view of the v2 masternode snapshot.
+type PublicApiSnapshotV2 struct {
+	Number               uint64           `json:"number"`
+	Hash                 common.Hash      `json:"hash"`
+	NextEpochMasterNodes []common.Address `json:"nextEpochMasterNodes"`
+}
+
+// PublicApiRoundInfo is the public view of the v2 engine round state.
+type PublicApiRoundInfo struct {
+	CurrentRound       Round        `json:"currentRound"`
+	HighestQuorumCert  *QuorumCert  `json:"highestQuorumCert"`
+	LockQuorumCert     *QuorumCert  `json:"lockQuorumCert"`
+	HighestTimeoutCert *TimeoutCert `json:"highestTimeoutCert"`
+	HighestCommitBlock *BlockInfo   `json:"highestCommitBlock"`
+}
+
+// Reasons a masternode is penalized at a checkpoint.
+const (
+	PenaltyMissedSigning         = "missed-signing"          // didn't sign any block of the checked range
+	PenaltyNotEnoughBlocks       = "not-enough-blocks"       // created less blocks than required during the epoch
+	PenaltyNoBlock               = "no-block"                // was a masternode of the epoch but created no block
+	PenaltyComebackMissedSigning = "comeback-missed-signing" // still not signing after its previous penalty expired
+)
+
+// PenaltyRecord explains why a masternode is penalized at a checkpoint.
+type PenaltyRecord struct {
+	Address        common.Address `json:"address"`
+	Reason         string         `json:"reason"`
+	MissedSigns    uint64         `json:"missedSigns"`    // blocks checked for the node's signature without finding it
+	BlocksCreated  uint64         `json:"blocksCreated"`  // blocks created by the node during the epoch
+	BlocksRequired uint64         `json:"blocksRequired"` // blocks a node has to create during an epoch
+	ComebackEpoch  uint64         `json:"comebackEpoch"`  // first epoch the node may be a masternode again
+}
+
+// PenaltyReport is the penalty journal of the checkpoint block of an epoch.
+type PenaltyReport struct {
+	Epoch     uint64           `json:"epoch"`
+	Number    uint64           `json:"number"`
+	Hash      common.Hash      `json:"hash"`
+	Penalties []*PenaltyRecord `json:"penalties"`
+}
+
+// RandomizeRecord is the participation of a masternode in the commit-reveal
+// randomization feeding the M2 assignment of a checkpoint.
+type RandomizeRecord struct {
+	Address  common.Address `json:"address"`
+	Secret   bool           `json:"secret"`   // a secret was committed
+	Opening  bool           `json:"opening"`  // the opening of the secret was revealed
+	Revealed bool           `json:"revealed"` // the opening decrypts the secret
+	Fallback bool           `json:"fallback"` // a fallback value replaced the missing randomize
+}
+
+// RandomizeReport is the randomness journal of the checkpoint block of an
+// epoch. Quality is the share of the masternodes that revealed a randomize.
+type RandomizeReport struct {
+	Epoch     uint64             `json:"epoch"`
+	Number    uint64             `json:"number"`
+	Hash      common.Hash        `json:"hash"`
+	Revealed  int                `json:"revealed"`
+	Fallbacks int                `json:"fallbacks"`
+	Quality   float64            `json:"quality"`
+	Records   []*RandomizeRecord `json:"records"`
+}
+
+func rlpHash(x interface{}) (h common.Hash) {
+	hw := sha3.NewKeccak256()
+	rlp.Encode(hw, x)
+	hw.Sum(h[:0])
+	return h
+}
+
+// Hash returns the hash of the whole vote, used to dedup messages.
+func (m *Vote) Hash() common.Hash {
+	return rlpHash(m)
+}
+
+// PoolKey groups the votes casted for the same block.
+func (m *Vote) PoolKey() string {
+	return fmt.Sprint(m.ProposedBlockInfo.Round, ":", m.GapNumber, ":", m.ProposedBlockInfo.Number, ":", m.ProposedBlockInfo.Hash.Hex())
+}
+
+// Hash returns the hash of the whole timeout, used to dedup messages.
+func (m *Timeout) Hash() common.Hash {
+	return rlpHash(m)
+}
+
+// PoolKey groups the timeouts sent for the same round.
+func (m *Timeout) PoolKey() string {
+	return fmt.Sprint(m.Round, ":", m.GapNumber)
+}
+
+// Hash returns the hash of the sync info, used to dedup messages.
+func (m *SyncInfo) Hash() common.Hash {
+	return rlpHash(m)
+}
+
+// VoteSigHash returns the hash signed by a masternode when voting.
+func VoteSigHash(m *VoteForSign) common.Hash {
+	return rlpHash(m)
+}
+
+// TimeoutSigHash returns the hash signed by a masternode when timing out.
+func TimeoutSigHash(m *TimeoutForSign) common.Hash {
+	return rlpHash(m)
+}
+
+// EncodeToBytes encodes the extra fields of a v2 header, including the version byte.
+func (e *ExtraFields_v2) EncodeToBytes() ([]byte, error) {
+	bytes, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{ConsensusVersion2}, bytes...), nil
+}
+
+// DecodeBytesExtraFields decodes the extra data of a v2 header into val.
+func DecodeBytesExtraFields(b []byte, val interface{}) error {
+	if len(b) == 0 {
+		return ErrEmptyExtraFields
+	}
+	switch b[0] {
+	case ConsensusVersion2:
+		return rlp.DecodeBytes(b[1:], val)
+	default:
+		return fmt.Errorf("consensus version %d is not defined", b[0])
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/FRECNET/common"
+)
+
+func TestExtraFieldsV2EncodeDecode(t *testing.T) {
+	extra := &ExtraFields_v2{
+		Round: 12,
+		QuorumCert: &QuorumCert{
+			ProposedBlockInfo: &BlockInfo{Hash: common.StringToHash("parent"), Round: 11, Number: big.NewInt(911)},
+			Signatures:        []Signature{{1, 2, 3}, {4, 5, 6}},
+			GapNumber:         450,
+		},
+	}
+	encoded, err := extra.EncodeToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded[0] != ConsensusVersion2 {
+		t.Fatalf("version byte %d, want %d", encoded[0], ConsensusVersion2)
+	}
+	var decoded ExtraFields_v2
+	if err := DecodeBytesExtraFields(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(extra, &decoded) {
+		t.Fatalf("decoded extra mismatch: have %+v, want %+v", decoded, extra)
+	}
+	if err := DecodeBytesExtraFields(nil, &decoded); err != ErrEmptyExtraFields {
+		t.Fatalf("expected %v, got %v", ErrEmptyExtraFields, err)
+	}
+	if err := DecodeBytesExtraFields([]byte{1, 2}, &decoded); err == nil {
+		t.Fatal("v1 extra data decoded as v2")
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"math/big"
 	"reflect"
 	"sort"
 	"strconv"
@@ -68,7 +70,8 @@ func GetM1M2FromCheckpointHeader(checkpointHeader *types.Header, currentHeader *
 	}
 	// Get signers from this block.
 	masternodes := GetMasternodesFromCheckpointHeader(checkpointHeader)
-	validators := ExtractValidatorsFromBytes(checkpointHeader.Validators)
+	m2, _ := SplitCheckpointValidators(checkpointHeader.Validators, len(masternodes))
+	validators := ExtractValidatorsFromBytes(m2)
 	m1m2, _, err := GetM1M2(masternodes, validators, currentHeader, config)
 	if err != nil {
 		return map[common.Address]common.Address{}, err
@@ -76,6 +79,41 @@ func GetM1M2FromCheckpointHeader(checkpointHeader *types.Header, currentHeader *
 	return m1m2, nil
 }
 
+// SplitCheckpointValidators splits the Validators field of a v1 checkpoint with
+// the given number of masternodes into the M2 assignment and the encoded stakes
+// of the masternodes, which follow it past the TIPStakeWeightedProposer fork.
+func SplitCheckpointValidators(validators []byte, masternodes int) ([]byte, []byte) {
+	if n := masternodes * M2ByteLength; len(validators) > n {
+		return validators[:n], validators[n:]
+	}
+	return validators, nil
+}
+
+// EncodeCheckpointStakes encodes the stakes of the masternodes of a checkpoint
+// to be committed after its M2 assignment.
+func EncodeCheckpointStakes(stakes []*big.Int) []byte {
+	data, _ := rlp.EncodeToBytes(stakes)
+	return data
+}
+
+// GetStakesFromCheckpointHeader returns the stakes of the masternodes committed
+// by a checkpoint header, in the order of the masternodes.
+func GetStakesFromCheckpointHeader(checkpointHeader *types.Header) ([]*big.Int, error) {
+	masternodes := GetMasternodesFromCheckpointHeader(checkpointHeader)
+	_, data := SplitCheckpointValidators(checkpointHeader.Validators, len(masternodes))
+	if len(data) == 0 {
+		return nil, ErrMissingCheckpointStakes
+	}
+	var stakes []*big.Int
+	if err := rlp.DecodeBytes(data, &stakes); err != nil {
+		return nil, ErrInvalidCheckpointStakes
+	}
+	if len(stakes) != len(masternodes) {
+		return nil, ErrInvalidCheckpointStakes
+	}
+	return stakes, nil
+}
+
 func GetM1M2(masternodes []common.Address, validators []int64, currentHeader *types.Header, config *params.ChainConfig) (map[common.Address]common.Address, uint64, error) {
 	m1m2 := map[common.Address]common.Address{}
 	maxMNs := len(masternodes)
@@ -97,6 +135,140 @@ func GetM1M2(masternodes []common.Address, validators []int64, currentHeader *ty
 	return m1m2, moveM2, nil
 }
 
+// ProposerSchedule returns the proposers of length consecutive blocks, drawn
+// among the masternodes with probabilities proportional to their stakes out of
+// a random stream seeded by seed. The masternodes are drawn evenly if none has
+// a stake. As no signer may seal two consecutive blocks, the proposer of a
+// block is drawn among the masternodes but the one of the previous block.
+func ProposerSchedule(masternodes []Masternode, seed common.Hash, length int) []common.Address {
+	if len(masternodes) == 0 {
+		return nil
+	}
+	stakes, total := proposerStakes(masternodes)
+	var (
+		schedule = make([]common.Address, length)
+		prev     = -1
+	)
+	for i := range schedule {
+		excluded := -1
+		if len(stakes) > 1 {
+			excluded = prev
+		}
+		pick := drawProposer(stakes, total, excluded, seed, uint64(i))
+		schedule[i] = masternodes[pick].Address
+		prev = pick
+	}
+	return schedule
+}
+
+// RoundLeader returns the leader of a v2 round, drawn among the masternodes
+// with probabilities proportional to their stakes out of the random stream
+// seeded by seed, evenly if none has a stake. Unlike the v1 blocks, the rounds
+// of a failed leader may be led by it again.
+func RoundLeader(masternodes []Masternode, seed common.Hash, round Round) common.Address {
+	if len(masternodes) == 0 {
+		return common.Address{}
+	}
+	stakes, total := proposerStakes(masternodes)
+	return masternodes[drawProposer(stakes, total, -1, seed, uint64(round))].Address
+}
+
+// proposerStakes returns the stakes the proposers are drawn by and their sum,
+// a stake of one for every masternode if none has a stake.
+func proposerStakes(masternodes []Masternode) ([]*big.Int, *big.Int) {
+	stakes := make([]*big.Int, len(masternodes))
+	total := new(big.Int)
+	for i, m := range masternodes {
+		stakes[i] = common.Big0
+		if m.Stake != nil && m.Stake.Sign() > 0 {
+			stakes[i] = m.Stake
+		}
+		total.Add(total, stakes[i])
+	}
+	if total.Sign() == 0 {
+		for i := range stakes {
+			stakes[i] = common.Big1
+		}
+		total.SetInt64(int64(len(stakes)))
+	}
+	return stakes, total
+}
+
+// drawProposer returns the index of the masternode drawn for a slot of the
+// random stream seeded by seed, leaving the excluded one out if not -1.
+func drawProposer(stakes []*big.Int, total *big.Int, excluded int, seed common.Hash, slot uint64) int {
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], slot)
+	hasher := sha3.NewKeccak256()
+	hasher.Write(seed[:])
+	hasher.Write(index[:])
+	draw := new(big.Int).SetBytes(hasher.Sum(nil))
+
+	weights := total
+	if excluded >= 0 {
+		weights = new(big.Int).Sub(total, stakes[excluded])
+	}
+	if weights.Sign() == 0 {
+		// Only the excluded masternode has a stake
+		return (excluded + 1) % len(stakes)
+	}
+	draw.Mod(draw, weights)
+	pick := 0
+	for ; pick < len(stakes)-1; pick++ {
+		if pick == excluded {
+			continue
+		}
+		if draw.Cmp(stakes[pick]) < 0 {
+			break
+		}
+		draw.Sub(draw, stakes[pick])
+	}
+	return pick
+}
+
+// RandomizeSeed returns the seed of the proposer schedule of the epoch opened
+// by a v1 checkpoint. It is taken from the M2 assignment of the checkpoint,
+// which the masternodes drew out of the randomizes they committed to and
+// revealed in the randomize contract during the previous epoch, so that
+// neither the proposer of the checkpoint nor anyone else can pick it.
+func RandomizeSeed(checkpointHeader *types.Header) common.Hash {
+	m2, _ := SplitCheckpointValidators(checkpointHeader.Validators, len(GetMasternodesFromCheckpointHeader(checkpointHeader)))
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], checkpointHeader.Number.Uint64())
+	hasher := sha3.NewKeccak256()
+	hasher.Write(number[:])
+	hasher.Write(m2)
+	var seed common.Hash
+	hasher.Sum(seed[:0])
+	return seed
+}
+
+// CertificateSeed returns the seed of the round leaders of the epoch opened by
+// a v2 checkpoint. It is taken from the signatures of the quorum certificate
+// the checkpoint carries: every masternode signs the vote for the parent block
+// deterministically, so the randomness comes from the previous epoch's
+// masternodes as a whole. The signatures are sorted so that only the quorum
+// matters, not the order the proposer received the votes in.
+func CertificateSeed(qc *QuorumCert) common.Hash {
+	signatures := make([][]byte, len(qc.Signatures))
+	for i, signature := range qc.Signatures {
+		signatures[i] = signature
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		return bytes.Compare(signatures[i], signatures[j]) < 0
+	})
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], qc.ProposedBlockInfo.Number.Uint64())
+	hasher := sha3.NewKeccak256()
+	hasher.Write(number[:])
+	for _, signature := range signatures {
+		hasher.Write(signature)
+	}
+	var seed common.Hash
+	hasher.Sum(seed[:0])
+	return seed
+}
+
 // compare 2 signers lists
 // return true if they are same elements, otherwise return false
 func CompareSignersLists(list1 []common.Address, list2 []common.Address) bool {
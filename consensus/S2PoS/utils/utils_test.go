@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/FRECNET/common"
@@ -83,3 +84,175 @@ func TestCompareSignersLists(t *testing.T) {
 		t.Error("Failed with list has only one signer")
 	}
 }
+
+func TestProposerSchedule(t *testing.T) {
+	var (
+		a    = common.StringToAddress("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		b    = common.StringToAddress("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+		c    = common.StringToAddress("cccccccccccccccccccccccccccccccccccccccc")
+		seed = common.HexToHash("0x01")
+	)
+	masternodes := []Masternode{
+		{Address: a, Stake: big.NewInt(10)},
+		{Address: b, Stake: big.NewInt(20)},
+		{Address: c, Stake: big.NewInt(70)},
+	}
+	schedule := ProposerSchedule(masternodes, seed, 9000)
+	counts := make(map[common.Address]int)
+	for i, proposer := range schedule {
+		if i > 0 && proposer == schedule[i-1] {
+			t.Fatalf("proposer %x drawn twice in a row at slot %d", proposer, i)
+		}
+		counts[proposer]++
+	}
+	// c can't propose two blocks in a row, so it gets less than 70% of them
+	if counts[a] >= counts[b] || counts[b] >= counts[c] {
+		t.Errorf("proposals not ordered by stake: %v", counts)
+	}
+	if again := ProposerSchedule(masternodes, seed, 9000); !reflect.DeepEqual(again, schedule) {
+		t.Error("schedule not deterministic")
+	}
+	if other := ProposerSchedule(masternodes, common.HexToHash("0x02"), 9000); reflect.DeepEqual(other, schedule) {
+		t.Error("schedule independent of the seed")
+	}
+
+	// Without any stake, the masternodes are drawn evenly
+	for i := range masternodes {
+		masternodes[i].Stake = nil
+	}
+	counts = make(map[common.Address]int)
+	for _, proposer := range ProposerSchedule(masternodes, seed, 9000) {
+		counts[proposer]++
+	}
+	for _, addr := range []common.Address{a, b, c} {
+		if counts[addr] < 2500 || counts[addr] > 3500 {
+			t.Errorf("proposals of %x not even: %v", addr, counts)
+		}
+	}
+}
+
+func TestRoundLeader(t *testing.T) {
+	var (
+		a    = common.StringToAddress("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		b    = common.StringToAddress("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+		c    = common.StringToAddress("cccccccccccccccccccccccccccccccccccccccc")
+		seed = common.HexToHash("0x01")
+	)
+	masternodes := []Masternode{
+		{Address: a, Stake: big.NewInt(10)},
+		{Address: b, Stake: big.NewInt(20)},
+		{Address: c, Stake: big.NewInt(70)},
+	}
+	counts := make(map[common.Address]int)
+	for round := Round(0); round < 9000; round++ {
+		leader := RoundLeader(masternodes, seed, round)
+		if again := RoundLeader(masternodes, seed, round); again != leader {
+			t.Fatalf("round %d: leader not deterministic: %x and %x", round, leader, again)
+		}
+		counts[leader]++
+	}
+	if counts[c] < 6000 || counts[c] > 6600 || counts[a] >= counts[b] {
+		t.Errorf("rounds not led by stake: %v", counts)
+	}
+}
+
+func TestRandomizeSeed(t *testing.T) {
+	masternode := common.StringToAddress("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	extra := append(make([]byte, ExtraVanity), masternode.Bytes()...)
+	header := &types.Header{
+		Number:     big.NewInt(900),
+		Extra:      append(extra, make([]byte, ExtraSeal)...),
+		Validators: append(common.LeftPadBytes([]byte("1"), M2ByteLength), EncodeCheckpointStakes([]*big.Int{big.NewInt(10)})...),
+	}
+	seed := RandomizeSeed(header)
+
+	// The proposer of the checkpoint can't grind the seed through its seal or
+	// the stakes, only the revealed randomizes count
+	ground := types.CopyHeader(header)
+	ground.Extra[0], ground.Extra[len(ground.Extra)-1] = 1, 1
+	ground.Validators = append(common.LeftPadBytes([]byte("1"), M2ByteLength), EncodeCheckpointStakes([]*big.Int{big.NewInt(20)})...)
+	if RandomizeSeed(ground) != seed {
+		t.Error("seed depends on more than the randomizes")
+	}
+	other := types.CopyHeader(header)
+	other.Validators = common.LeftPadBytes([]byte("2"), M2ByteLength)
+	if RandomizeSeed(other) == seed {
+		t.Error("seed independent of the randomizes")
+	}
+}
+
+func TestCertificateSeed(t *testing.T) {
+	qc := &QuorumCert{
+		ProposedBlockInfo: &BlockInfo{Hash: common.HexToHash("0x01"), Round: 10, Number: big.NewInt(899)},
+		Signatures:        []Signature{{1}, {2}, {3}},
+	}
+	reordered := &QuorumCert{
+		ProposedBlockInfo: qc.ProposedBlockInfo,
+		Signatures:        []Signature{{3}, {1}, {2}},
+	}
+	if CertificateSeed(qc) != CertificateSeed(reordered) {
+		t.Error("seed depends on the order of the signatures")
+	}
+	other := &QuorumCert{
+		ProposedBlockInfo: qc.ProposedBlockInfo,
+		Signatures:        []Signature{{1}, {2}, {4}},
+	}
+	if CertificateSeed(qc) == CertificateSeed(other) {
+		t.Error("seed independent of the signatures")
+	}
+}
+
+func TestCheckpointStakes(t *testing.T) {
+	masternodes := []common.Address{
+		common.StringToAddress("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		common.StringToAddress("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+		common.StringToAddress("cccccccccccccccccccccccccccccccccccccccc"),
+	}
+	extra := make([]byte, ExtraVanity)
+	var m2 []byte
+	for i, masternode := range masternodes {
+		extra = append(extra, masternode.Bytes()...)
+		m2 = append(m2, common.LeftPadBytes([]byte(fmt.Sprintf("%d", 2-i)), M2ByteLength)...)
+	}
+	header := &types.Header{
+		Number:     big.NewInt(int64(common.EpocBlockRandomize)),
+		Extra:      append(extra, make([]byte, ExtraSeal)...),
+		Validators: m2,
+	}
+	config := &params.ChainConfig{S2PoS: &params.S2PoSConfig{Epoch: 900}}
+
+	if _, err := GetStakesFromCheckpointHeader(header); err != ErrMissingCheckpointStakes {
+		t.Fatalf("error mismatch without stakes: have %v, want %v", err, ErrMissingCheckpointStakes)
+	}
+	want, err := GetM1M2FromCheckpointHeader(header, header, config)
+	if err != nil {
+		t.Fatalf("failed to get m1m2: %v", err)
+	}
+	stakes := []*big.Int{big.NewInt(10), big.NewInt(0), big.NewInt(70)}
+	header.Validators = append(m2, EncodeCheckpointStakes(stakes)...)
+
+	have, err := GetStakesFromCheckpointHeader(header)
+	if err != nil {
+		t.Fatalf("failed to get stakes: %v", err)
+	}
+	if len(have) != len(stakes) {
+		t.Fatalf("stake count mismatch: have %d, want %d", len(have), len(stakes))
+	}
+	for i := range stakes {
+		if have[i].Cmp(stakes[i]) != 0 {
+			t.Errorf("stake %d mismatch: have %v, want %v", i, have[i], stakes[i])
+		}
+	}
+	// The stakes don't shift the M2 assignment
+	if m1m2, err := GetM1M2FromCheckpointHeader(header, header, config); err != nil || !reflect.DeepEqual(m1m2, want) {
+		t.Errorf("m1m2 mismatch: have %v (%v), want %v", m1m2, err, want)
+	}
+	header.Validators = append(m2, EncodeCheckpointStakes(stakes[:2])...)
+	if _, err := GetStakesFromCheckpointHeader(header); err != ErrInvalidCheckpointStakes {
+		t.Errorf("error mismatch with missing stake: have %v, want %v", err, ErrInvalidCheckpointStakes)
+	}
+	header.Validators = append(m2, 0xff)
+	if _, err := GetStakesFromCheckpointHeader(header); err != ErrInvalidCheckpointStakes {
+		t.Errorf("error mismatch with malformed stakes: have %v, want %v", err, ErrInvalidCheckpointStakes)
+	}
+}
@@ -0,0 +1,79 @@
+pragma solidity ^0.4.24;
+
+contract AbstractRelayerRegistration {
+    function getRelayerByCoinbase(address coinbase) public view returns (uint, address, uint256, uint16, address[] memory, address[] memory);
+}
+
+/// @dev Maker and taker fee schedules of the relayers, read from the contract
+/// storage by the FREx matching engine. Fees are in 1/10000 of the traded quote
+/// token quantity, a negative maker fee is a rebate paid by the relayer owner.
+///
+/// The contract isn't compiled from this source: the nodes install the runtime
+/// code assembled from contracts/FREx/feeschedule/RelayerFeeSchedule.easm, which
+/// implements it with the same storage layout, at the hardfork block, and set
+/// RelayerRegistration to the relayer registration contract.
+contract RelayerFeeSchedule {
+    /// @dev storage layout is read by FREx/tradingstate/fee_schedule.go
+    AbstractRelayerRegistration private RelayerRegistration;
+
+    /// @dev Data types
+    struct FeeTier {
+        uint256 _volume;
+        int256 _makerFee;
+        uint256 _takerFee;
+    }
+
+    /// @dev coinbase -> tiers sorted by volume, the first tier starts at 0
+    mapping(address => FeeTier[]) public FEE_SCHEDULES;
+
+    uint constant public MaximumTiers = 10;
+    uint constant public BaseFee = 10000;
+
+    /// @dev Events
+    event UpdateFeeScheduleEvent(address coinbase, uint256[] volumes, int256[] makerFees, uint256[] takerFees);
+
+    constructor (address relayerRegistration) public {
+        RelayerRegistration = AbstractRelayerRegistration(relayerRegistration);
+    }
+
+    /// @dev Modifier
+    modifier relayerOwnerOnly(address coinbase) {
+        address owner;
+        (, owner, , , , ) = RelayerRegistration.getRelayerByCoinbase(coinbase);
+        require(msg.sender == owner, "Relayer Owner Only.");
+        _;
+    }
+
+    /// @dev Functionality
+    function setFeeSchedule(address coinbase, uint256[] volumes, int256[] makerFees, uint256[] takerFees) public relayerOwnerOnly(coinbase) {
+        require(volumes.length == makerFees.length && volumes.length == takerFees.length, "Invalid schedule length.");
+        require(volumes.length <= MaximumTiers, "Exceeding tier limit.");
+        delete FEE_SCHEDULES[coinbase];
+        for (uint i = 0; i < volumes.length; i++) {
+            require(i > 0 || volumes[i] == 0, "The first tier must start at 0.");
+            require(i == 0 || volumes[i] > volumes[i - 1], "Tiers must be sorted by volume.");
+            require(takerFees[i] < BaseFee, "Invalid taker fee.");
+            require(makerFees[i] >= -int256(takerFees[i]) && makerFees[i] < int256(BaseFee), "Invalid maker fee.");
+            FEE_SCHEDULES[coinbase].push(FeeTier(volumes[i], makerFees[i], takerFees[i]));
+        }
+        emit UpdateFeeScheduleEvent(coinbase, volumes, makerFees, takerFees);
+    }
+
+    function clearFeeSchedule(address coinbase) public relayerOwnerOnly(coinbase) {
+        delete FEE_SCHEDULES[coinbase];
+        emit UpdateFeeScheduleEvent(coinbase, new uint256[](0), new int256[](0), new uint256[](0));
+    }
+
+    function getFeeSchedule(address coinbase) public view returns (uint256[] memory, int256[] memory, uint256[] memory) {
+        FeeTier[] storage tiers = FEE_SCHEDULES[coinbase];
+        uint256[] memory volumes = new uint256[](tiers.length);
+        int256[] memory makerFees = new int256[](tiers.length);
+        uint256[] memory takerFees = new uint256[](tiers.length);
+        for (uint i = 0; i < tiers.length; i++) {
+            volumes[i] = tiers[i]._volume;
+            makerFees[i] = tiers[i]._makerFee;
+            takerFees[i] = tiers[i]._takerFee;
+        }
+        return (volumes, makerFees, takerFees);
+    }
+}
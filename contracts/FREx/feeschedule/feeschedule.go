@@ -0,0 +1,11 @@
+// Package feeschedule holds the code of the relayer fee schedule contract,
+// contracts/FREx/contract/RelayerFeeSchedule.sol.
+package feeschedule
+
+// RelayerFeeScheduleABI is the ABI of the relayer fee schedule contract.
+const RelayerFeeScheduleABI = "[{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"volumes\",\"type\":\"uint256[]\"},{\"name\":\"makerFees\",\"type\":\"int256[]\"},{\"name\":\"takerFees\",\"type\":\"uint256[]\"}],\"name\":\"setFeeSchedule\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"clearFeeSchedule\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"getFeeSchedule\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256[]\"},{\"name\":\"\",\"type\":\"int256[]\"},{\"name\":\"\",\"type\":\"uint256[]\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"},{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"FEE_SCHEDULES\",\"outputs\":[{\"name\":\"_volume\",\"type\":\"uint256\"},{\"name\":\"_makerFee\",\"type\":\"int256\"},{\"name\":\"_takerFee\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MaximumTiers\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"BaseFee\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"volumes\",\"type\":\"uint256[]\"},{\"indexed\":false,\"name\":\"makerFees\",\"type\":\"int256[]\"},{\"indexed\":false,\"name\":\"takerFees\",\"type\":\"uint256[]\"}],\"name\":\"UpdateFeeScheduleEvent\",\"type\":\"event\"}]"
+
+// RelayerFeeScheduleRuntimeBin is the runtime code of the relayer fee schedule
+// contract, assembled from RelayerFeeSchedule.easm. The nodes install it at the
+// fee schedule hardfork, there is no deployment transaction.
+const RelayerFeeScheduleRuntimeBin = `0x346300000078576000357c010000000000000000000000000000000000000000000000000000000090048063088a427514630000007e578063d8732205146300000213578063643c6eef1463000002f357806360ef06081463000003895780635694004b1463000003cf5780631544e6521463000003d8575b60006000fd5b63540105c77c01000000000000000000000000000000000000000000000000000000000260005260043560045260406000602460006000545afa1563000000785760403d10630000007857602051331415630000007857602435600401358060443560040135141563000000785780606435600401351415630000007857600a8111630000007857600435600052600160205260406000208054816000526020600020906003025b8015630000013f57600190036000818301556300000126565b5082825560005b8381101563000001e25780602002602435016024013581156300000180578160200260243501600401358111156300000078576300000188565b806300000078575b81600302830190815581602002606435016024013561271081101563000000785782602002604435016024013561271081121563000000785781600003811263000000785782600101558160020155506001016300000146565b600436038060046000377fdb1c93dd4fe4254f4f4f9344f4f8e124c695a8e17ac6f38fe1a174a0b5ffa0a2906000a1005b63540105c77c01000000000000000000000000000000000000000000000000000000000260005260043560045260406000602460006000545afa1563000000785760403d1063000000785760205133141563000000785760043560005260016020526040600020805460008255816000526020600020906003025b801563000002a75760019003600081830155630000028e565b600435600052608060205260a060405260c06060526000608052600060a052600060c0527fdb1c93dd4fe4254f4f4f9344f4f8e124c695a8e17ac6f38fe1a174a0b5ffa0a260e06000a1005b6004356000526001602052604060002080549060005260206000206060608052816001016020028060600160a0528060020260600160c0528260e052828160e00152828160020260e0015260005b83811015630000037e5780600302830181602002610100018154815281600101548185015281600201548185600202015250506001016300000341565b506003026060016080f35b6004356000526001602052604060002080546024351015630000007857600052602060002060243560030201805460005280600101546020526002015460405260606000f35b600a63000003e2565b61271063000003e2565b60005260206000f3`
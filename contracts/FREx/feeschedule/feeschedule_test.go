@@ -0,0 +1,26 @@
+package feeschedule_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/FRECNET/contracts/FREx/feeschedule"
+	"github.com/FRECNET/core/asm"
+)
+
+// Tests that the runtime code installed by the nodes is the assembled one.
+func TestRelayerFeeScheduleRuntimeBin(t *testing.T) {
+	src, err := ioutil.ReadFile("RelayerFeeSchedule.easm")
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex("RelayerFeeSchedule.easm", src, false))
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("failed to assemble source: %v", errs)
+	}
+	if "0x"+bin != feeschedule.RelayerFeeScheduleRuntimeBin {
+		t.Errorf("runtime code mismatch, reassemble RelayerFeeSchedule.easm")
+	}
+}
@@ -0,0 +1,118 @@
+pragma solidity ^0.4.24;
+
+/// @dev Blacklist of the addresses whose transactions are rejected, read from
+/// the contract storage by the nodes from the blacklist contract hardfork on.
+/// Addresses are added and removed by the masternodes, a proposal passing once
+/// voted by more than two thirds of them.
+///
+/// The contract isn't compiled from this source: the nodes install the runtime
+/// code assembled from Blacklist.easm, which implements it with the same storage
+/// layout, at the hardfork block, and write the masternodes of every epoch into
+/// masternodeSet, masternodeCount and masternodes at its checkpoint.
+contract Blacklist {
+    /// @dev storage layout is read and written by core/state/blacklist_reader.go
+    address[] public entries;
+    /// @dev address -> number of the block from which it is blacklisted
+    mapping(address => uint256) public effectiveBlocks;
+    /// @dev address -> index in entries + 1, 0 if not blacklisted
+    mapping(address => uint256) private indexes;
+
+    /// @dev id of the current masternode set, written by the nodes
+    uint256 private masternodeSet;
+    uint256 public masternodeCount;
+    /// @dev masternode -> id of the last set it belongs to
+    mapping(address => uint256) private masternodes;
+
+    /// @dev Data types
+    struct Proposal {
+        address target;
+        bool add;
+        bool executed;
+        uint256 votes;
+        mapping(address => bool) voted;
+    }
+
+    Proposal[] public proposals;
+
+    /// @dev Events
+    event ProposeEvent(uint256 id, address proposer, address target, bool add);
+    event VoteEvent(uint256 id, address voter);
+    event AddEvent(address target, uint256 effectiveBlock);
+    event RemoveEvent(address target);
+
+    /// @dev Modifier
+    modifier masternodeOnly() {
+        require(isMasternode(msg.sender), "Masternode Only.");
+        _;
+    }
+
+    /// @dev Functionality
+    function propose(address target, bool add) public masternodeOnly returns (uint256) {
+        require(add != (indexes[target] > 0), "Nothing to change.");
+        uint256 id = proposals.push(Proposal(target, add, false, 0)) - 1;
+        emit ProposeEvent(id, msg.sender, target, add);
+        vote(id);
+        return id;
+    }
+
+    function vote(uint256 id) public masternodeOnly {
+        require(id < proposals.length, "Invalid proposal.");
+        Proposal storage proposal = proposals[id];
+        require(!proposal.executed, "Proposal already executed.");
+        require(!proposal.voted[msg.sender], "Already voted.");
+        proposal.voted[msg.sender] = true;
+        proposal.votes++;
+        emit VoteEvent(id, msg.sender);
+
+        if (proposal.votes * 3 > masternodeCount * 2) {
+            proposal.executed = true;
+            if (proposal.add) {
+                add(proposal.target);
+            } else {
+                remove(proposal.target);
+            }
+        }
+    }
+
+    function hasVoted(uint256 id, address voter) public view returns (bool) {
+        return proposals[id].voted[voter];
+    }
+
+    function isMasternode(address _address) public view returns (bool) {
+        return masternodes[_address] != 0 && masternodes[_address] == masternodeSet;
+    }
+
+    function entryCount() public view returns (uint256) {
+        return entries.length;
+    }
+
+    function proposalCount() public view returns (uint256) {
+        return proposals.length;
+    }
+
+    /// @dev An address added in a block is blacklisted from the next one, the
+    /// nodes reading the blacklist from the state of the parent block.
+    function add(address target) private {
+        if (indexes[target] > 0) {
+            return;
+        }
+        entries.push(target);
+        indexes[target] = entries.length;
+        effectiveBlocks[target] = block.number + 1;
+        emit AddEvent(target, block.number + 1);
+    }
+
+    function remove(address target) private {
+        uint256 index = indexes[target];
+        if (index == 0) {
+            return;
+        }
+        address last = entries[entries.length - 1];
+        entries[index - 1] = last;
+        indexes[last] = index;
+        entries.length--;
+        delete indexes[target];
+        delete effectiveBlocks[target];
+        emit RemoveEvent(target);
+    }
+}
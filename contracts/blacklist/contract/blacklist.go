@@ -0,0 +1,9 @@
+package contract
+
+// BlacklistABI is the ABI of the blacklist contract, see Blacklist.sol.
+const BlacklistABI = "[{\"constant\":false,\"inputs\":[{\"name\":\"target\",\"type\":\"address\"},{\"name\":\"add\",\"type\":\"bool\"}],\"name\":\"propose\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"id\",\"type\":\"uint256\"}],\"name\":\"vote\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"id\",\"type\":\"uint256\"},{\"name\":\"voter\",\"type\":\"address\"}],\"name\":\"hasVoted\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_address\",\"type\":\"address\"}],\"name\":\"isMasternode\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"masternodeCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"entries\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"entryCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"effectiveBlocks\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"proposals\",\"outputs\":[{\"name\":\"target\",\"type\":\"address\"},{\"name\":\"add\",\"type\":\"bool\"},{\"name\":\"executed\",\"type\":\"bool\"},{\"name\":\"votes\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"proposalCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"id\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"proposer\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"target\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"add\",\"type\":\"bool\"}],\"name\":\"ProposeEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"id\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"voter\",\"type\":\"address\"}],\"name\":\"VoteEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"target\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"effectiveBlock\",\"type\":\"uint256\"}],\"name\":\"AddEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"target\",\"type\":\"address\"}],\"name\":\"RemoveEvent\",\"type\":\"event\"}]"
+
+// BlacklistRuntimeBin is the runtime code of the blacklist contract, assembled
+// from Blacklist.easm. The nodes install it at the blacklist contract hardfork,
+// there is no deployment transaction.
+const BlacklistRuntimeBin = `0x3463000000ac576000357c01000000000000000000000000000000000000000000000000000000009004806389b3bc841463000000b25780630121b93f14630000016a578063438596321463000003a2578063e8782fc11463000003da57806386340ba21463000003fb578063b30906d41463000004055780630cbb0f831463000004295780638aaec638146300000433578063013cf08b14630000044b578063da35c6641463000004d1575b60006000fd5b336000526005602052604060002054801563000000ac57600354141563000000ac5760043560243515158160005260026020526040600020541515811463000000ac576006548060010160065560066000526020600020816003020182740100000000000000000000000000000000000000000284179055806000523360205282604052816060527fa5618a20f531b1e4313a3ee36451626a8f2e04ee65db266b8b5fb1ac0824b23f60806000a19150506300000190565b336000526005602052604060002054801563000000ac57600354141563000000ac576004355b8060805260065481101563000000ac576006600052602060002081600302018054807501000000000000000000000000000000000000000000900460011663000000ac5733600052816002016020526040600020805463000000ac576001905581600101805460010180915583600052336020527fb092f5ee3a8c6b979322118d7d1def4527ffd3ad61966423e885a22dc36ce21e60406000a16003026004546002021015630000039c578075010000000000000000000000000000000000000000001782558073ffffffffffffffffffffffffffffffffffffffff1690740100000000000000000000000000000000000000009004600116630000029657630000030f565b80600052600260205260406000208054630000039c576000546000600052602060002081018390556001018060005590554360010181600052600160205260406000208190556020526000527f4523683ddb71b1bdbf9976ba3f8472152e32648f55dd83f28bed133198f695d160406000a1630000039c565b806000526002602052604060002080548015630000039c576000546001900360006000526020600020808201805480600186038401556000526002602052836040600020556000905550600055506000905580600052600160205260006040600020556000527f688e697805354db98f2e13902637040839dd1ffe90406c679b9ddf95e7f1960d60206000a15b60206080f35b600654600435101563000000ac57600660005260206000206004356003020160020160243560005260205260406000205463000004db565b600435600052600560205260406000205480151590600354141663000004db565b60045463000004db565b600054600435101563000000ac5760006000526020600020600435015463000004db565b60005463000004db565b600435600052600160205260406000205463000004db565b600654600435101563000000ac5760066000526020600020600435600302018060010154606052548073ffffffffffffffffffffffffffffffffffffffff16600052807401000000000000000000000000000000000000000090046001166020527501000000000000000000000000000000000000000000900460011660405260806000f35b60065463000004db565b60005260206000f3`
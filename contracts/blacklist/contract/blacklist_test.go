@@ -0,0 +1,25 @@
+package contract
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/FRECNET/core/asm"
+)
+
+// Tests that the runtime code installed by the nodes is the assembled one.
+func TestBlacklistRuntimeBin(t *testing.T) {
+	src, err := ioutil.ReadFile("Blacklist.easm")
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex("Blacklist.easm", src, false))
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("failed to assemble source: %v", errs)
+	}
+	if "0x"+bin != BlacklistRuntimeBin {
+		t.Errorf("runtime code mismatch, reassemble Blacklist.easm")
+	}
+}
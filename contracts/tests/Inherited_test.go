@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"github.com/FRECNET/accounts/abi/bind"
 	"github.com/FRECNET/accounts/abi/bind/backends"
-	"github.com/FRECNET/common"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/crypto"
 	"github.com/FRECNET/log"
+	"github.com/FRECNET/params"
 	"math/big"
 	"os"
 	"testing"
@@ -22,11 +22,13 @@ func TestPriceFeed(t *testing.T) {
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.LvlTrace)
 	log.Root().SetHandler(glogger)
-	common.TIPFREXCancellationFee = big.NewInt(0)
-	// init genesis
-	contractBackend := backends.NewSimulatedBackend(core.GenesisAlloc{
+	// init genesis, with the cancellation fee fork (and the Istanbul EVM) from the start
+	config := *params.AllEthashProtocolChanges
+	config.Forks = params.MainnetForks()
+	config.Forks.TIPFREXCancellationFeeBlock = big.NewInt(0)
+	contractBackend := backends.NewSimulatedBackendWithConfig(core.GenesisAlloc{
 		mainAddr: {Balance: big.NewInt(0).Mul(big.NewInt(10000000000000), big.NewInt(10000000000000))},
-	})
+	}, &config)
 	transactOpts := bind.NewKeyedTransactor(mainKey)
 	// deploy payer swap SMC
 	addr, contract, err := DeployMyInherited(transactOpts, contractBackend)
@@ -10,6 +10,7 @@ import (
 	"github.com/FRECNET/contracts/trc21issuer"
 	"github.com/FRECNET/contracts/trc21issuer/simulation"
 	"github.com/FRECNET/ethclient"
+	"github.com/FRECNET/params"
 	"log"
 	"math/big"
 	"time"
@@ -49,7 +50,7 @@ func airDropTokenToAccountNoFRE() {
 		log.Fatal("can't transaction's receipt ", err, "hash", tx.Hash().Hex())
 	}
 	fee := big.NewInt(0).SetUint64(hexutil.MustDecodeUint64(receipt["gasUsed"].(string)))
-	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > common.TIPTRC21Fee.Uint64() {
+	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > params.MainnetForks().TIPTRC21FeeBlock.Uint64() {
 		fee = fee.Mul(fee, common.TRC21GasPrice)
 	}
 	fmt.Println("fee", fee.Uint64(), "number", hexutil.MustDecodeUint64(receipt["blockNumber"].(string)))
@@ -112,7 +113,7 @@ func testTransferTRC21TokenWithAccountNoFRE() {
 		log.Fatal("can't transaction's receipt ", err, "hash", tx.Hash().Hex())
 	}
 	fee := big.NewInt(0).SetUint64(hexutil.MustDecodeUint64(receipt["gasUsed"].(string)))
-	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > common.TIPTRC21Fee.Uint64() {
+	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > params.MainnetForks().TIPTRC21FeeBlock.Uint64() {
 		fee = fee.Mul(fee, common.TRC21GasPrice)
 	}
 	fmt.Println("fee", fee.Uint64(), "number", hexutil.MustDecodeUint64(receipt["blockNumber"].(string)))
@@ -179,7 +180,7 @@ func testTransferTrc21Fail() {
 		log.Fatal("can't transaction's receipt ", err, "hash", tx.Hash().Hex())
 	}
 	fee := big.NewInt(0).SetUint64(hexutil.MustDecodeUint64(receipt["gasUsed"].(string)))
-	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > common.TIPTRC21Fee.Uint64() {
+	if hexutil.MustDecodeUint64(receipt["blockNumber"].(string)) > params.MainnetForks().TIPTRC21FeeBlock.Uint64() {
 		fee = fee.Mul(fee, common.TRC21GasPrice)
 	}
 	fmt.Println("fee", fee.Uint64(), "number", hexutil.MustDecodeUint64(receipt["blockNumber"].(string)))
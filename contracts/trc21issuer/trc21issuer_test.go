@@ -6,6 +6,7 @@ import (
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/params"
 	"math/big"
 	"testing"
 )
@@ -82,7 +83,7 @@ func TestFeeTxWithTRC21Token(t *testing.T) {
 		t.Fatal("can't transaction's receipt ", err, "hash", tx.Hash())
 	}
 	fee := big.NewInt(0).SetUint64(receipt.GasUsed)
-	if receipt.Logs[0].BlockNumber > common.TIPTRC21Fee.Uint64() {
+	if receipt.Logs[0].BlockNumber > params.AllEthashProtocolChanges.ForkBlocks().TIPTRC21FeeBlock.Uint64() {
 		fee = fee.Mul(fee, common.TRC21GasPrice)
 	}
 	remainFee := big.NewInt(0).Sub(minApply, fee)
@@ -134,7 +135,7 @@ func TestFeeTxWithTRC21Token(t *testing.T) {
 		t.Fatal("can't transaction's receipt ", err, "hash", tx.Hash())
 	}
 	fee = big.NewInt(0).SetUint64(receipt.GasUsed)
-	if receipt.Logs[0].BlockNumber > common.TIPTRC21Fee.Uint64() {
+	if receipt.Logs[0].BlockNumber > params.AllEthashProtocolChanges.ForkBlocks().TIPTRC21FeeBlock.Uint64() {
 		fee = fee.Mul(fee, common.TRC21GasPrice)
 	}
 	remainFee = big.NewInt(0).Sub(remainFee, fee)
@@ -44,6 +44,7 @@ import (
 	"github.com/FRECNET/core/state"
 	stateDatabase "github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/log"
 	"github.com/FRECNET/params"
@@ -240,8 +241,24 @@ func GetSignersByExecutingEVM(addrBlockSigner common.Address, client bind.Contra
 	return addrs, nil
 }
 
+// RandomizeReveal is the participation of a masternode in the commit-reveal
+// randomization of an epoch.
+type RandomizeReveal struct {
+	Secret  bool  // a secret was committed
+	Opening bool  // the opening of the secret was revealed
+	Valid   bool  // the opening decrypts the secret into a randomize
+	Random  int64 // decrypted randomize, zero if no secret decrypts
+}
+
 // Get random from randomize contract.
 func GetRandomizeFromContract(client bind.ContractBackend, addrMasternode common.Address) (int64, error) {
+	reveal, err := GetRandomizeRevealFromContract(client, addrMasternode)
+	return reveal.Random, err
+}
+
+// GetRandomizeRevealFromContract retrieves the secrets and opening a masternode
+// submitted to the randomize contract, and the randomize they decrypt into.
+func GetRandomizeRevealFromContract(client bind.ContractBackend, addrMasternode common.Address) (*RandomizeReveal, error) {
 	randomize, err := randomizeContract.NewFRERandomize(common.HexToAddress(common.RandomizeSMC), client)
 	if err != nil {
 		log.Error("Fail to get instance of randomize", "error", err)
@@ -255,8 +272,23 @@ func GetRandomizeFromContract(client bind.ContractBackend, addrMasternode common
 	if err != nil {
 		log.Error("Fail get opening from randomize", "error", err)
 	}
+	reveal := &RandomizeReveal{
+		Secret:  len(secrets) > 0,
+		Opening: opening != [32]byte{},
+	}
+	var valid bool
+	reveal.Random, valid, err = decryptRandomize(secrets, opening)
+	reveal.Valid = valid && reveal.Secret && reveal.Opening
+	return reveal, err
+}
 
-	return DecryptRandomizeFromSecretsAndOpening(secrets, opening)
+// FallbackRandomize derives the randomize of a masternode that missed a phase
+// of the commit-reveal from the randomness of the previous epoch, so that it
+// keeps weighing on the M2 assignment with a value every node agrees on. Like
+// the committed secrets, the value is below the epoch length.
+func FallbackRandomize(seed common.Hash, addrMasternode common.Address, epoch uint64) int64 {
+	hash := crypto.Keccak256(seed.Bytes(), addrMasternode.Bytes())
+	return int64(new(big.Int).SetBytes(hash[:8]).Uint64() % epoch)
 }
 
 // Generate m2 listing from randomize array.
@@ -310,7 +342,17 @@ func DecodeValidatorsHexData(validatorsStr string) ([]int64, error) {
 
 // Decrypt randomize from secrets and opening.
 func DecryptRandomizeFromSecretsAndOpening(secrets [][32]byte, opening [32]byte) (int64, error) {
-	var random int64
+	random, _, err := decryptRandomize(secrets, opening)
+	return random, err
+}
+
+// decryptRandomize decrypts the randomize from secrets and opening, and reports
+// whether any secret decrypted into a number.
+func decryptRandomize(secrets [][32]byte, opening [32]byte) (int64, bool, error) {
+	var (
+		random int64
+		valid  bool
+	)
 	if len(secrets) > 0 {
 		for _, secret := range secrets {
 			trimSecret := bytes.TrimLeft(secret[:], "\x00")
@@ -319,14 +361,15 @@ func DecryptRandomizeFromSecretsAndOpening(secrets [][32]byte, opening [32]byte)
 				intNumber, err := strconv.Atoi(decryptSecret)
 				if err != nil {
 					log.Error("Can not convert string to integer", "error", err)
-					return -1, err
+					return -1, false, err
 				}
 				random = int64(intNumber)
+				valid = true
 			}
 		}
 	}
 
-	return random, nil
+	return random, valid, nil
 }
 
 // Calculate reward for reward checkpoint.
@@ -373,7 +416,7 @@ func GetRewardForCheckpoint(c *S2PoS.S2PoS, chain consensus.ChainReader, header
 		}
 	}
 	header = chain.GetHeader(header.ParentHash, prevCheckpoint)
-	masternodes := utils.GetMasternodesFromCheckpointHeader(header)
+	masternodes := c.GetMasternodesFromCheckpointHeader(header, header.Number.Uint64(), chain.Config().S2PoS.Epoch)
 
 	// fmt.Println("utils.go:::masternodes::GetRewardForCheckpoint",masternodes)
 	// fmt.Println("utils.go:::masternodes::startBlockNumber",startBlockNumber)
@@ -454,15 +497,15 @@ func GetCandidatesOwnerBySigner(state *state.StateDB, signerAddr common.Address)
 	return owner
 }
 
-func CalculateRewardForHolders(foundationWalletAddr common.Address, state *state.StateDB, signer common.Address, calcReward *big.Int, blockNumber uint64) (error, map[common.Address]*big.Int) {
-	rewards, err := GetRewardBalancesRate(foundationWalletAddr, state, signer, calcReward, blockNumber)
+func CalculateRewardForHolders(config *params.ChainConfig, foundationWalletAddr common.Address, state *state.StateDB, signer common.Address, calcReward *big.Int, blockNumber uint64) (error, map[common.Address]*big.Int) {
+	rewards, err := GetRewardBalancesRate(config, foundationWalletAddr, state, signer, calcReward, blockNumber)
 	if err != nil {
 		return err, nil
 	}
 	return nil, rewards
 }
 
-func GetRewardBalancesRate(foundationWalletAddr common.Address, state *state.StateDB, masterAddr common.Address, totalReward *big.Int, blockNumber uint64) (map[common.Address]*big.Int, error) {
+func GetRewardBalancesRate(config *params.ChainConfig, foundationWalletAddr common.Address, state *state.StateDB, masterAddr common.Address, totalReward *big.Int, blockNumber uint64) (map[common.Address]*big.Int, error) {
 	fmt.Println("masterAddr at GetRewardBalancesRate", masterAddr)
 	owner := GetCandidatesOwnerBySigner(state, masterAddr)
 
@@ -510,7 +553,7 @@ func GetRewardBalancesRate(foundationWalletAddr common.Address, state *state.Sta
 		// Get voters capacities.
 		voterCaps := make(map[common.Address]*big.Int)
 		for _, voteAddr := range voters {
-			if _, ok := voterCaps[voteAddr]; ok && common.TIP2019Block.Uint64() <= blockNumber {
+			if _, ok := voterCaps[voteAddr]; ok && config.IsTIP2019(new(big.Int).SetUint64(blockNumber)) {
 				continue
 			}
 			voterCap := stateDatabase.GetVoterCap(state, masterAddr, voteAddr)
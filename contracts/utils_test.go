@@ -153,6 +153,47 @@ func TestEncryptDecrypt(t *testing.T) {
 	t.Log("Encrypt", encrypt, "Test", string(randomByte), "Decrypt", decrypt, "trim", string(bytes.TrimLeft([]byte(decrypt), "\x00")))
 }
 
+// Unit test for the decryption of randomize reveals.
+func TestDecryptRandomizeReveal(t *testing.T) {
+	key := RandStringByte(32)
+	var secret, opening [32]byte
+	copy(secret[:], common.LeftPadBytes([]byte(Encrypt(key, "42")), 32))
+	copy(opening[:], key)
+
+	random, valid, err := decryptRandomize([][32]byte{secret}, opening)
+	if err != nil || !valid || random != 42 {
+		t.Errorf("Fail to decrypt revealed randomize: %v %v %v", random, valid, err)
+	}
+	random, valid, err = decryptRandomize(nil, opening)
+	if err != nil || valid || random != 0 {
+		t.Errorf("Uncommitted randomize decrypted: %v %v %v", random, valid, err)
+	}
+}
+
+// Unit test for the fallback of missing randomizes.
+func TestFallbackRandomize(t *testing.T) {
+	var (
+		seed  = common.HexToHash("0x01")
+		addr  = common.HexToAddress("0x02")
+		epoch = uint64(900)
+	)
+	random := FallbackRandomize(seed, addr, epoch)
+	if random < 0 || uint64(random) >= epoch {
+		t.Errorf("Fallback randomize out of range: %v", random)
+	}
+	if again := FallbackRandomize(seed, addr, epoch); again != random {
+		t.Errorf("Fallback randomize not deterministic: %v != %v", again, random)
+	}
+	// The fallbacks of an epoch must not all collapse into a single value
+	distinct := make(map[int64]struct{})
+	for i := 0; i < 10; i++ {
+		distinct[FallbackRandomize(common.BigToHash(big.NewInt(int64(i))), addr, epoch)] = struct{}{}
+	}
+	if len(distinct) < 2 {
+		t.Errorf("Fallback randomize ignores the seed: %v", distinct)
+	}
+}
+
 func isArrayEqual(a [][]int64, b [][]int64) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/contracts/blacklist/contract"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/params"
+)
+
+// GetBlacklist returns the addresses blacklisted on top of the state of the
+// block of the given number and root, by the number of the block from which
+// they are blacklisted. Up to the blacklist contract hardfork, the block of
+// which copies it into the contract, the compiled-in common.Blacklist applies.
+func GetBlacklist(config *params.ChainConfig, number *big.Int, root common.Hash, statedb *state.StateDB) map[common.Address]uint64 {
+	if !config.IsTIPBlacklistContract(number) {
+		effective := blacklistEffectiveBlock(config)
+		blacklist := make(map[common.Address]uint64, len(common.Blacklist))
+		for addr := range common.Blacklist {
+			blacklist[addr] = effective
+		}
+		return blacklist
+	}
+	return state.GetBlacklistFromStateWithCache(root, statedb)
+}
+
+// ApplyBlacklistHardFork installs the code of the blacklist contract and copies
+// the compiled-in blacklist into it, at the blacklist contract hardfork block.
+func ApplyBlacklistHardFork(config *params.ChainConfig, statedb *state.StateDB) {
+	statedb.SetCode(common.HexToAddress(common.BlacklistSMC), common.FromHex(contract.BlacklistRuntimeBin))
+	addrs := make([]common.Address, 0, len(common.Blacklist))
+	for addr := range common.Blacklist {
+		addrs = append(addrs, addr)
+	}
+	state.AddBlacklistToState(statedb, addrs, blacklistEffectiveBlock(config))
+}
+
+// ApplyBlacklistMasternodes hands the masternodes of the epoch of header to the
+// blacklist contract, to propose and vote on its entries, at the blacklist
+// contract hardfork block and at every checkpoint past it.
+func ApplyBlacklistMasternodes(config *params.ChainConfig, engine consensus.Engine, chain consensus.ChainReader, header *types.Header, statedb *state.StateDB) {
+	S2PoSEngine, ok := engine.(*S2PoS.S2PoS)
+	if !ok || config.S2PoS == nil {
+		return
+	}
+	if header.Number.Uint64()%config.S2PoS.Epoch != 0 && !config.IsTIPBlacklistContractBlock(header.Number) {
+		return
+	}
+	state.SetBlacklistMasternodes(statedb, S2PoSEngine.GetMasternodes(chain, header))
+}
+
+// blacklistEffectiveBlock returns the block from which the compiled-in
+// blacklist applies, the blacklist hardfork one.
+func blacklistEffectiveBlock(config *params.ChainConfig) uint64 {
+	if block := config.ForkBlocks().BlackListBlock; block != nil {
+		return block.Uint64()
+	}
+	return 0
+}
+
+// IsBlacklisted reports whether addr, which may be nil, is in blacklist.
+func IsBlacklisted(blacklist map[common.Address]uint64, addr *common.Address) bool {
+	if addr == nil {
+		return false
+	}
+	_, ok := blacklist[*addr]
+	return ok
+}
+
+// blacklist returns the addresses blacklisted in a block, processed on top of
+// statedb, which must still be the state of its parent.
+func (p *StateProcessor) blacklist(block *types.Block, statedb *state.StateDB) (map[common.Address]uint64, error) {
+	parent := p.bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	return GetBlacklist(p.config, parent.Number, parent.Root, statedb), nil
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/FRECNET/accounts/abi"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/contracts/blacklist/contract"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/vm"
+	"github.com/FRECNET/params"
+)
+
+// Tests that the masternodes propose and vote on the blacklist in the contract
+// installed at the hardfork, and that the nodes read the passed proposals.
+func TestBlacklistContract(t *testing.T) {
+	var (
+		config      = params.TestChainConfig
+		address     = common.HexToAddress(common.BlacklistSMC)
+		target      = common.HexToAddress("0x1234")
+		outsider    = common.HexToAddress("0x5678")
+		masternodes = []common.Address{common.HexToAddress("0x11"), common.HexToAddress("0x12"), common.HexToAddress("0x13"), common.HexToAddress("0x14")}
+	)
+	blacklistABI, err := abi.JSON(strings.NewReader(contract.BlacklistABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	ApplyBlacklistHardFork(config, statedb)
+	state.SetBlacklistMasternodes(statedb, masternodes)
+
+	number := big.NewInt(100)
+	call := func(from common.Address, method string, args ...interface{}) ([]byte, error) {
+		input, err := blacklistABI.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("failed to pack %s: %v", method, err)
+		}
+		context := vm.Context{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			Origin:      from,
+			GasPrice:    new(big.Int),
+			BlockNumber: number,
+			Time:        new(big.Int),
+			Difficulty:  new(big.Int),
+			GasLimit:    10000000,
+		}
+		evm := vm.NewEVM(context, statedb, nil, config, vm.Config{})
+		ret, _, err := evm.Call(vm.AccountRef(from), address, input, 1000000, new(big.Int))
+		return ret, err
+	}
+	isMasternode := func(addr common.Address) bool {
+		ret, err := call(outsider, "isMasternode", addr)
+		if err != nil {
+			t.Fatalf("failed to call isMasternode: %v", err)
+		}
+		var ok bool
+		if err := blacklistABI.Unpack(&ok, "isMasternode", ret); err != nil {
+			t.Fatalf("failed to unpack isMasternode: %v", err)
+		}
+		return ok
+	}
+	if !isMasternode(masternodes[0]) || isMasternode(outsider) {
+		t.Fatalf("masternode set mismatch")
+	}
+	if _, err := call(outsider, "propose", target, true); err == nil {
+		t.Fatalf("proposal of a non masternode accepted")
+	}
+	if _, err := call(masternodes[0], "propose", target, false); err == nil {
+		t.Fatalf("removal of an address not blacklisted accepted")
+	}
+	// Proposing counts as the first vote, the proposal passes with the third
+	if _, err := call(masternodes[0], "propose", target, true); err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if _, err := call(masternodes[0], "vote", big.NewInt(0)); err == nil {
+		t.Fatalf("second vote of a masternode accepted")
+	}
+	if _, err := call(outsider, "vote", big.NewInt(0)); err == nil {
+		t.Fatalf("vote of a non masternode accepted")
+	}
+	if _, err := call(masternodes[1], "vote", big.NewInt(1)); err == nil {
+		t.Fatalf("vote on a missing proposal accepted")
+	}
+	if _, err := call(masternodes[1], "vote", big.NewInt(0)); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	if blacklist := state.GetBlacklistFromState(statedb); len(blacklist) != len(common.Blacklist) {
+		t.Fatalf("blacklist changed before the proposal passed: have %d entries, want %d", len(blacklist), len(common.Blacklist))
+	}
+	if _, err := call(masternodes[2], "vote", big.NewInt(0)); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	blacklist := state.GetBlacklistFromState(statedb)
+	if len(blacklist) != len(common.Blacklist)+1 || blacklist[target] != number.Uint64()+1 {
+		t.Fatalf("blacklist mismatch: have %d entries and %d for the target, want %d and %d", len(blacklist), blacklist[target], len(common.Blacklist)+1, number.Uint64()+1)
+	}
+	if !IsBlacklisted(blacklist, &target) {
+		t.Errorf("target not blacklisted")
+	}
+	if _, err := call(masternodes[3], "vote", big.NewInt(0)); err == nil {
+		t.Fatalf("vote on an executed proposal accepted")
+	}
+	var voted bool
+	ret, err := call(outsider, "hasVoted", big.NewInt(0), masternodes[1])
+	if err != nil {
+		t.Fatalf("failed to call hasVoted: %v", err)
+	}
+	if err := blacklistABI.Unpack(&voted, "hasVoted", ret); err != nil || !voted {
+		t.Errorf("vote not recorded: %v", err)
+	}
+
+	// The masternodes of the next epoch remove it
+	state.SetBlacklistMasternodes(statedb, masternodes[1:])
+	if isMasternode(masternodes[0]) || !isMasternode(masternodes[1]) {
+		t.Fatalf("masternode set mismatch after the update")
+	}
+	if _, err := call(masternodes[0], "propose", target, false); err == nil {
+		t.Fatalf("proposal of a former masternode accepted")
+	}
+	if _, err := call(masternodes[1], "propose", target, false); err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	for _, voter := range masternodes[2:] {
+		if _, err := call(voter, "vote", big.NewInt(1)); err != nil {
+			t.Fatalf("failed to vote: %v", err)
+		}
+	}
+	blacklist = state.GetBlacklistFromState(statedb)
+	if _, ok := blacklist[target]; ok || len(blacklist) != len(common.Blacklist) {
+		t.Fatalf("target not removed: have %d entries", len(blacklist))
+	}
+	for addr := range common.Blacklist {
+		if _, ok := blacklist[addr]; !ok {
+			t.Errorf("compiled-in entry %x lost by the removal", addr)
+		}
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/FRECNET/consensus/S2PoS/utils"
 	contractSetting "github.com/FRECNET/contracts/setting/contract"
 	contractValidator "github.com/FRECNET/contracts/validator/contract"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/core/vm"
@@ -84,6 +85,9 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	FRExPruneEpochs      uint64 // Number of recent epochs whose trading and lending states are kept on disk (0 = no pruning)
+	FRExPruneCheckpoints uint64 // Number of older epochs whose checkpoint trading and lending states are kept as well
 }
 type ResultProcessBlock struct {
 	logs         []*types.Log
@@ -168,6 +172,8 @@ type BlockChain struct {
 	resultLendingTrade  *lru.Cache
 	rejectedLendingItem *lru.Cache
 	finalizedTrade      *lru.Cache // include both trades which force update to closed/liquidated by the protocol
+
+	frexPruning int32 // Whether the trading and lending tries are being pruned (atomic), their flushes are postponed meanwhile
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -389,6 +395,8 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(hash common.Hash, num uint64) {
 		DeleteBody(bc.db, hash, num)
+		bc.deleteRewardLookups(bc.db, hash, num)
+		rawdb.DeleteBlockRewards(bc.db, hash, num)
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
@@ -443,6 +451,20 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	if _, err := trie.NewSecure(block.Root(), bc.stateCache.TrieDB()); err != nil {
 		return err
 	}
+	// The trading and lending states recorded in the block must exist as well
+	if tradingRoot, lendingRoot, ok := bc.FRExStateRoots(block); ok {
+		engine := bc.Engine().(*S2PoS.S2PoS)
+		if tradingService := engine.GetFREXService(); tradingService.GetStateCache() != nil {
+			if _, err := tradingstate.New(tradingRoot, tradingService.GetStateCache()); err != nil {
+				return fmt.Errorf("missing trading state %x: %v", tradingRoot, err)
+			}
+		}
+		if lendingService := engine.GetLendingService(); lendingService.GetStateCache() != nil {
+			if _, err := lendingstate.New(lendingRoot, lendingService.GetStateCache()); err != nil {
+				return fmt.Errorf("missing lending state %x: %v", lendingRoot, err)
+			}
+		}
+	}
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock.Store(block)
@@ -469,6 +491,24 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the highest block committed by the consensus
+// engine, blocks up to it can't be reorged anymore.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	if header := bc.hc.CurrentFinalizedHeader(); header != nil {
+		return bc.GetBlock(header.Hash(), header.Number.Uint64())
+	}
+	return nil
+}
+
+// CurrentSafeBlock retrieves the highest block certified by a quorum
+// certificate.
+func (bc *BlockChain) CurrentSafeBlock() *types.Block {
+	if header := bc.hc.CurrentSafeHeader(); header != nil {
+		return bc.GetBlock(header.Hash(), header.Number.Uint64())
+	}
+	return nil
+}
+
 // SetProcessor sets the processor required for making state modifications.
 func (bc *BlockChain) SetProcessor(processor Processor) {
 	bc.procmu.Lock()
@@ -866,9 +906,95 @@ func (bc *BlockChain) GetUnclesInChain(block *types.Block, length int) []*types.
 }
 
 // TrieNode retrieves a blob of data associated with a trie node (or code hash)
-// either from ephemeral in-memory cache, or from persistent storage.
+// either from ephemeral in-memory cache, or from persistent storage. The nodes
+// of the trading and lending state tries are retrieved as well, for the peers
+// fast syncing them.
 func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
-	return bc.stateCache.TrieDB().Node(hash)
+	node, err := bc.stateCache.TrieDB().Node(hash)
+	if err == nil {
+		return node, nil
+	}
+	if engine, ok := bc.Engine().(*S2PoS.S2PoS); ok {
+		if tradingService := engine.GetFREXService(); tradingService != nil && tradingService.GetStateCache() != nil {
+			if node, err := tradingService.GetStateCache().TrieDB().Node(hash); err == nil {
+				return node, nil
+			}
+		}
+		if lendingService := engine.GetLendingService(); lendingService != nil && lendingService.GetStateCache() != nil {
+			if node, err := lendingService.GetStateCache().TrieDB().Node(hash); err == nil {
+				return node, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// FRExStateRoots retrieves the trading and lending state roots recorded in a
+// block by its author. ok is false if the block carries no FREx states or the
+// chain doesn't keep them.
+func (bc *BlockChain) FRExStateRoots(block *types.Block) (tradingRoot common.Hash, lendingRoot common.Hash, ok bool) {
+	engine, isS2PoS := bc.Engine().(*S2PoS.S2PoS)
+	if !isS2PoS || bc.FRExDb == nil || !bc.Config().IsTIPFREX(block.Number()) || bc.chainConfig.S2PoS == nil || block.NumberU64() <= bc.chainConfig.S2PoS.Epoch {
+		return common.Hash{}, common.Hash{}, false
+	}
+	tradingService := engine.GetFREXService()
+	lendingService := engine.GetLendingService()
+	if tradingService == nil || lendingService == nil {
+		return common.Hash{}, common.Hash{}, false
+	}
+	author, err := bc.Engine().Author(block.Header())
+	if err != nil {
+		return common.Hash{}, common.Hash{}, false
+	}
+	if tradingRoot, err = tradingService.GetTradingStateRoot(block, author); err != nil {
+		return common.Hash{}, common.Hash{}, false
+	}
+	if lendingRoot, err = lendingService.GetLendingStateRoot(block, author); err != nil {
+		return common.Hash{}, common.Hash{}, false
+	}
+	return tradingRoot, lendingRoot, true
+}
+
+// FRExStateDB retrieves the database the trading and lending state tries are
+// stored in.
+func (bc *BlockChain) FRExStateDB() ethdb.KeyValueStore {
+	return bc.FRExDb
+}
+
+// pruneFRExStates deletes from disk the trading and lending trie nodes which
+// are unreachable from the states of the last epochs before the given head and
+// from the checkpoint states of the older epochs. The tries are read through
+// their trie databases, so that the nodes of the states still in memory are
+// retained as well.
+func (bc *BlockChain) pruneFRExStates(head uint64, tradingTrieDb, lendingTrieDb *trie.Database) {
+	defer bc.wg.Done()
+	defer atomic.StoreInt32(&bc.frexPruning, 0)
+
+	var (
+		start  = time.Now()
+		pruner = NewFRExPruner(bc.FRExDb, tradingTrieDb, lendingTrieDb)
+	)
+	for _, number := range FRExRetainedBlocks(head, bc.chainConfig.S2PoS.Epoch, bc.cacheConfig.FRExPruneEpochs, bc.cacheConfig.FRExPruneCheckpoints) {
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			continue
+		}
+		if err := pruner.MarkBlock(block); err != nil {
+			log.Warn("Failed to mark trading and lending states, pruning aborted", "number", number, "err", err)
+			return
+		}
+	}
+	deleted, err := pruner.Sweep(bc.quit)
+	if err != nil {
+		log.Warn("Failed to prune trading and lending tries", "deleted", deleted, "err", err)
+		return
+	}
+	log.Info("Pruned trading and lending tries", "head", head, "retained", pruner.Marked(), "deleted", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
 }
 
 func (bc *BlockChain) SaveData() {
@@ -1293,7 +1419,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 					triedb.Commit(header.Root, true)
 					lastWrite = chosen
 					bc.gcproc = 0
-					if tradingTrieDb != nil && lendingTrieDb != nil {
+					if tradingTrieDb != nil && lendingTrieDb != nil && atomic.LoadInt32(&bc.frexPruning) == 1 {
+						log.Debug("Trading and lending tries being pruned, commit postponed", "number", chosen)
+					} else if tradingTrieDb != nil && lendingTrieDb != nil {
 						b := bc.GetBlock(header.Hash(), current-triesInMemory)
 						author, _ := bc.Engine().Author(b.Header())
 						oldTradingRoot, _ = tradingService.GetTradingStateRoot(b, author)
@@ -1333,10 +1461,21 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				}
 			}
 		}
+		// Prune the trading and lending tries on disk at every checkpoint
+		if bc.cacheConfig.FRExPruneEpochs > 0 && bc.FRExDb != nil && tradingTrieDb != nil && lendingTrieDb != nil && block.NumberU64()%bc.chainConfig.S2PoS.Epoch == 0 {
+			if atomic.CompareAndSwapInt32(&bc.frexPruning, 0, 1) {
+				bc.wg.Add(1)
+				go bc.pruneFRExStates(block.NumberU64(), tradingTrieDb, lendingTrieDb)
+			}
+		}
 	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
 		return NonStatTy, err
 	}
+	rewards, err := bc.writeBlockRewards(batch, block)
+	if err != nil {
+		return NonStatTy, err
+	}
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
@@ -1375,6 +1514,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		if err := WriteTxLookupEntries(batch, block); err != nil {
 			return NonStatTy, err
 		}
+		// Index the rewards distributed by the block by recipient
+		if err := bc.writeRewardLookups(batch, block.Hash(), block.NumberU64(), rewards); err != nil {
+			return NonStatTy, err
+		}
 		// Write hash preimages
 		if err := WritePreimages(bc.db, block.NumberU64(), state.Preimages()); err != nil {
 			return NonStatTy, err
@@ -1593,7 +1736,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 					return i, events, coalescedLogs, err
 				}
 				if (block.NumberU64() % bc.chainConfig.S2PoS.Epoch) == 0 {
-					if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.S2PoS.Epoch, tradingState, statedb); err != nil {
+					if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.S2PoS.Epoch, tradingState, statedb, bc.chainConfig.IsTIPFREXPriceOracle(block.Number())); err != nil {
 						return i, events, coalescedLogs, err
 					}
 				} else {
@@ -1695,6 +1838,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			if bc.chainConfig.IsTIPFREX(block.Number()) && bc.chainConfig.S2PoS != nil && block.NumberU64() > bc.chainConfig.S2PoS.Epoch {
 				bc.logExchangeData(block)
 				bc.logLendingData(block)
+				bc.postExchangeEvents(block, block, false)
 			}
 		case SideStatTy:
 			log.Debug("Inserted forked block from downloader", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -1858,7 +2002,7 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 				return nil, err
 			}
 			if (block.NumberU64() % bc.chainConfig.S2PoS.Epoch) == 0 {
-				if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.S2PoS.Epoch, tradingState, statedb); err != nil {
+				if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.S2PoS.Epoch, tradingState, statedb, bc.chainConfig.IsTIPFREXPriceOracle(block.Number())); err != nil {
 					return nil, err
 				}
 			} else {
@@ -2015,6 +2159,7 @@ func (bc *BlockChain) insertBlock(block *types.Block) ([]interface{}, []*types.L
 		if bc.chainConfig.IsTIPFREX(block.Number()) && bc.chainConfig.S2PoS != nil && block.NumberU64() > bc.chainConfig.S2PoS.Epoch {
 			bc.logExchangeData(block)
 			bc.logLendingData(block)
+			bc.postExchangeEvents(block, block, false)
 		}
 	case SideStatTy:
 		log.Debug("Inserted forked block from fetcher", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -2142,11 +2287,21 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		return fmt.Errorf("Invalid new chain")
 	}
 
+	finalized, err := bc.hc.finalizedHeader()
+	if err != nil {
+		log.Error("Refused to reorg with an unknown finalized block", "hash", rawdb.ReadFinalizedBlockHash(bc.db), "err", err)
+		return err
+	}
 	for {
 		if oldBlock.Hash() == newBlock.Hash() {
 			commonBlock = oldBlock
 			break
 		}
+		// Blocks up to the finalized one are committed and never replaced
+		if finalized != nil && oldBlock.NumberU64() <= finalized.Number.Uint64() {
+			log.Error("Refused to reorg below the finalized block", "number", oldBlock.NumberU64(), "finalized", finalized.Number, "finalizedHash", finalized.Hash())
+			return ErrReorgBelowFinalized
+		}
 
 		oldChain = append(oldChain, oldBlock)
 		newChain = append(newChain, newBlock)
@@ -2173,6 +2328,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
 	// Insert the new chain, taking care of the proper incremental order
+	// the rewards of the old chain aren't received anymore
+	for _, block := range oldChain {
+		bc.deleteRewardLookups(bc.db, block.Hash(), block.NumberU64())
+	}
 	var addedTxs types.Transactions
 	for i := len(newChain) - 1; i >= 0; i-- {
 		// insert the block in the canonical way, re-writing history
@@ -2181,6 +2340,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		if err := WriteTxLookupEntries(bc.db, newChain[i]); err != nil {
 			return err
 		}
+		// write lookup entries for address based reward searches
+		hash, number := newChain[i].Hash(), newChain[i].NumberU64()
+		if err := bc.writeRewardLookups(bc.db, hash, number, GetBlockRewards(bc.db, hash, number)); err != nil {
+			return err
+		}
 		addedTxs = append(addedTxs, newChain[i].Transactions()...)
 		// prepare set of masternodes for the next epoch
 		if bc.chainConfig.S2PoS != nil && ((newChain[i].NumberU64() % bc.chainConfig.S2PoS.Epoch) == (bc.chainConfig.S2PoS.Epoch - bc.chainConfig.S2PoS.Gap)) {
@@ -2210,6 +2374,16 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	if bc.chainConfig.IsTIPFREX(commonBlock.Number()) && bc.chainConfig.S2PoS != nil && commonBlock.NumberU64() > bc.chainConfig.S2PoS.Epoch {
 		bc.reorgTxMatches(deletedTxs, newChain)
 	}
+	if bc.chainConfig.IsTIPFREX(commonBlock.Number()) && bc.chainConfig.S2PoS != nil && commonBlock.NumberU64() > bc.chainConfig.S2PoS.Epoch && len(newChain) > 0 {
+		// the dropped blocks are announced as removed, the new head is
+		// announced by the caller once written
+		for _, block := range oldChain {
+			bc.postExchangeEvents(block, newChain[0], true)
+		}
+		for i := len(newChain) - 1; i > 0; i-- {
+			bc.postExchangeEvents(newChain[i], newChain[0], false)
+		}
+	}
 	return nil
 }
 
@@ -2485,7 +2659,7 @@ func (bc *BlockChain) UpdateM1() error {
 		}
 	} else {
 		candidates = state.GetCandidates(stateDB)
-		tipincreasevalue = common.TIPIncreaseMasternodes.Uint64()
+		tipincreasevalue = bc.chainConfig.ForkBlocks().TIPIncreaseMasternodesBlock.Uint64()
 		fmt.Println("blockchain.go:::else loop:::", candidates)
 		fmt.Println("blockchain.go::else :tipincreasevalue:::", tipincreasevalue)
 	}
@@ -2522,7 +2696,9 @@ func (bc *BlockChain) UpdateM1() error {
 
 		// check if block number is increase ms checkpoint
 		// if bc.chainConfig.IsTIPIncreaseMasternodes(header.Number) {
-		if isEqualTo(big.NewInt(0).SetUint64(tipincreasevalue), header.Number) {
+		if bc.chainConfig.IsTIPDynamicMasternodes(header.Number) {
+			maxMasternodes = GetMaxMasternodes(bc.chainConfig, stateDB)
+		} else if isEqualTo(big.NewInt(0).SetUint64(tipincreasevalue), header.Number) {
 			fmt.Println("isEqualto:::")
 			maxMasternodeTest, _err1 := setting.GetMaxMasterNode(opts)
 			maxMasternodes = int(maxMasternodeTest)
@@ -412,6 +412,85 @@ func testReorg(t *testing.T, first, second []int64, td int64, full bool) {
 	}
 }
 
+// Tests that a heavier chain isn't allowed to replace the block committed by the
+// consensus engine.
+func TestReorgBelowFinalized(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	easyBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		b.OffsetTime([]int64{0, 0, -9}[i])
+	})
+	diffBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+		b.OffsetTime([]int64{0, 0, 0, -9}[i])
+	})
+	if _, err := blockchain.InsertChain(easyBlocks); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	rawdb.WriteFinalizedBlockHash(db, easyBlocks[1].Hash())
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized == nil || finalized.Hash() != easyBlocks[1].Hash() {
+		t.Fatalf("finalized block mismatch: have %v, want %x", finalized, easyBlocks[1].Hash())
+	}
+	if _, err := blockchain.InsertChain(diffBlocks); err != ErrReorgBelowFinalized {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrReorgBelowFinalized)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != easyBlocks[2].Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head.Hash(), easyBlocks[2].Hash())
+	}
+}
+
+// Tests that a reorg is refused if the block recorded as finalized isn't part of
+// the canonical chain.
+func TestReorgUnknownFinalized(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	easyBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		b.OffsetTime([]int64{0, 0, -9}[i])
+	})
+	diffBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 4, func(i int, b *BlockGen) {
+		b.OffsetTime([]int64{0, 0, 0, -9}[i])
+	})
+	if _, err := blockchain.InsertChain(easyBlocks); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	rawdb.WriteFinalizedBlockHash(db, diffBlocks[2].Hash())
+	if _, err := blockchain.InsertChain(diffBlocks); err != ErrUnknownFinalized {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrUnknownFinalized)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != easyBlocks[2].Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head.Hash(), easyBlocks[2].Hash())
+	}
+}
+
+// Tests that rewinding the chain below the finalized block drops it.
+func TestSetHeadBelowFinalized(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 3, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	rawdb.WriteFinalizedBlockHash(db, blockchain.CurrentBlock().Hash())
+	rawdb.WriteSafeBlockHash(db, blockchain.CurrentBlock().Hash())
+	if err := blockchain.SetHead(1); err != nil {
+		t.Fatalf("failed to rewind the chain: %v", err)
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(db); hash != (common.Hash{}) {
+		t.Errorf("finalized block not dropped: %x", hash)
+	}
+	if hash := rawdb.ReadSafeBlockHash(db); hash != (common.Hash{}) {
+		t.Errorf("safe block not dropped: %x", hash)
+	}
+}
+
 // Tests that the insertion functions detect banned hashes.
 func TestBadHeaderHashes(t *testing.T) { testBadHashes(t, false) }
 func TestBadBlockHashes(t *testing.T)  { testBadHashes(t, true) }
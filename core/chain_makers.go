@@ -116,7 +116,7 @@ func (b *BlockGen) AddTxWithChain(bc *BlockChain, tx *types.Transaction) {
 	// }
 	// changeit-GASUPDATE
 	if tokenFeeUsed {
-		fee := common.GetGasFee(b.header.Number.Uint64(), gas)
+		fee := b.config.GetGasFee(b.header.Number, gas)
 		state.UpdateTRC21Fee(b.statedb, map[common.Address]*big.Int{*tx.To(): new(big.Int).Sub(feeCapacity[*tx.To()], new(big.Int).SetUint64(gas))}, fee)
 	}
 }
@@ -38,4 +38,12 @@ var (
 	ErrNotFoundM1 = errors.New("list M1 not found")
 
 	ErrStopPreparingBlock = errors.New("stop calculating a block not verified by M2")
+
+	// ErrReorgBelowFinalized is returned if a new chain would replace a block
+	// committed by the consensus engine.
+	ErrReorgBelowFinalized = errors.New("reorg below the finalized block")
+
+	// ErrUnknownFinalized is returned if the recorded finalized block isn't part
+	// of the canonical chain, so the blocks it protects can't be told apart.
+	ErrUnknownFinalized = errors.New("finalized block not in the canonical chain")
 )
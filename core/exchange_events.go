@@ -0,0 +1,123 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/log"
+)
+
+// postExchangeEvents posts the trading and lending events of a block from the
+// matching results cached when the block was processed. The order book
+// volumes are read at the given head, removed is set for the blocks leaving
+// the canonical chain.
+func (bc *BlockChain) postExchangeEvents(block *types.Block, head *types.Block, removed bool) {
+	engine, ok := bc.Engine().(*S2PoS.S2PoS)
+	if !ok || engine == nil {
+		return
+	}
+	if FREXService := engine.GetFREXService(); FREXService != nil {
+		matches := bc.orderMatches(block)
+		if len(matches) > 0 {
+			var tradingState *tradingstate.TradingStateDB
+			if author, err := bc.Engine().Author(head.Header()); err == nil {
+				if tradingState, err = FREXService.GetTradingState(head, author); err != nil {
+					log.Debug("Failed to get trading state for order book events", "number", head.NumberU64(), "err", err)
+				}
+			}
+			FREXService.PostMatchingEvents(block, tradingState, matches, removed)
+		}
+	}
+	if lendingService := engine.GetLendingService(); lendingService != nil {
+		trades := bc.lendingTrades(block)
+		var (
+			finalized       lendingstate.FinalizedResult
+			finalizedTrades map[common.Hash]*lendingstate.LendingTrade
+		)
+		if block.NumberU64()%bc.chainConfig.S2PoS.Epoch == common.LiquidateLendingTradeBlock {
+			var err error
+			if finalized, err = ExtractLendingFinalizedTradeTransactions(block.Transactions()); err != nil {
+				log.Error("Failed to extract finalized lending trades", "number", block.NumberU64(), "err", err)
+			}
+			if data, ok := bc.finalizedTrade.Get(finalized.TxHash); ok && data != nil {
+				finalizedTrades = data.(map[common.Hash]*lendingstate.LendingTrade)
+			}
+		}
+		lendingService.PostLendingEvents(block, trades, finalized, finalizedTrades, removed)
+	}
+}
+
+// orderMatches returns the orders matched by the trading transactions of a
+// block along with their cached matching results.
+func (bc *BlockChain) orderMatches(block *types.Block) []tradingstate.OrderMatch {
+	batches, err := ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		log.Error("Failed to extract matching transactions", "number", block.NumberU64(), "err", err)
+		return nil
+	}
+	var matches []tradingstate.OrderMatch
+	for _, batch := range batches {
+		for _, txMatch := range batch.Data {
+			order, err := txMatch.DecodeOrder()
+			if err != nil {
+				log.Error("Failed to decode matched order", "txHash", batch.TxHash, "err", err)
+				continue
+			}
+			match := tradingstate.OrderMatch{TxHash: batch.TxHash, Order: order}
+			cacheKey := crypto.Keccak256Hash(batch.TxHash.Bytes(), tradingstate.GetMatchingResultCacheKey(order).Bytes())
+			if trades, ok := bc.resultTrade.Get(cacheKey); ok && trades != nil {
+				match.Trades = trades.([]map[string]string)
+			}
+			if rejects, ok := bc.rejectedOrders.Get(cacheKey); ok && rejects != nil {
+				match.Rejects = rejects.([]*tradingstate.OrderItem)
+			}
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// lendingTrades returns the lending trades opened by the lending transactions
+// of a block from their cached matching results.
+func (bc *BlockChain) lendingTrades(block *types.Block) []*lendingstate.LendingTrade {
+	batches, err := ExtractLendingTransactions(block.Transactions())
+	if err != nil {
+		log.Error("Failed to extract lending transactions", "number", block.NumberU64(), "err", err)
+		return nil
+	}
+	var trades []*lendingstate.LendingTrade
+	for _, batch := range batches {
+		for _, item := range batch.Data {
+			cached, ok := bc.resultLendingTrade.Get(crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes()))
+			if !ok || cached == nil {
+				continue
+			}
+			for _, trade := range cached.([]*lendingstate.LendingTrade) {
+				// the cached trades are shared with the SDK node, copy them
+				// before setting the transaction
+				trade := *trade
+				trade.TxHash = batch.TxHash
+				trades = append(trades, &trade)
+			}
+		}
+	}
+	return trades
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/ethdb/memorydb"
+	"github.com/FRECNET/trie"
+)
+
+var (
+	errFRExNodeNotMarked = errors.New("trie node not marked")
+	errFRExPruneStopped  = errors.New("pruning stopped")
+)
+
+// FRExRetainedBlocks returns the numbers of the blocks whose trading and
+// lending states a pruning at the given head retains, newest first: the blocks
+// of the last epochs, and the checkpoint blocks of the given number of older
+// epochs.
+func FRExRetainedBlocks(head, epoch, epochs, checkpoints uint64) []uint64 {
+	start := uint64(0)
+	if head > epochs*epoch {
+		start = head - epochs*epoch
+	}
+	numbers := make([]uint64, 0, head-start+1+checkpoints)
+	for number := head; ; number-- {
+		numbers = append(numbers, number)
+		if number == start {
+			break
+		}
+	}
+	for checkpoint, kept := start-start%epoch, uint64(0); kept < checkpoints && checkpoint > 0; checkpoint -= epoch {
+		if checkpoint < start {
+			numbers = append(numbers, checkpoint)
+			kept++
+		}
+	}
+	return numbers
+}
+
+// FRExPruner deletes from the FREx database the trading and lending trie nodes
+// which are unreachable from the states it retains. The retained states are
+// marked first, by running the trie schedulers fast sync uses into a set of
+// nodes, then the unmarked nodes are swept. The trie nodes are told from the
+// other entries of the database by their key, the hash of their value.
+type FRExPruner struct {
+	db        ethdb.KeyValueStore
+	tradingDb *trie.Database // Trie database to read the trading states through
+	lendingDb *trie.Database // Trie database to read the lending states through
+	bloom     *trie.SyncBloom
+	marked    map[common.Hash]struct{}
+}
+
+// NewFRExPruner creates a pruner of the trading and lending tries stored in db,
+// reading the retained states through the given trie databases, which may hold
+// recent states in memory.
+func NewFRExPruner(db ethdb.KeyValueStore, tradingDb, lendingDb *trie.Database) *FRExPruner {
+	// A closed bloom reports every node as possibly known, deferring to the
+	// marked set for every check.
+	bloom := trie.NewSyncBloom(1, memorydb.New())
+	bloom.Close()
+
+	return &FRExPruner{
+		db:        db,
+		tradingDb: tradingDb,
+		lendingDb: lendingDb,
+		bloom:     bloom,
+		marked:    make(map[common.Hash]struct{}),
+	}
+}
+
+// Marked returns the number of trie nodes marked as retained.
+func (p *FRExPruner) Marked() int {
+	return len(p.marked)
+}
+
+// MarkBlock marks the trading and lending states recorded in a block as
+// retained. The roots of the state transactions are taken regardless of their
+// sender, a transaction of another sender only retaining more nodes.
+func (p *FRExPruner) MarkBlock(block *types.Block) error {
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || tx.To().Hex() != common.TradingStateAddr {
+			continue
+		}
+		if data := tx.Data(); len(data) >= 32 {
+			if err := p.mark(common.BytesToHash(data[:32]), p.tradingDb, tradingstate.NewStateSync); err != nil {
+				return err
+			}
+		}
+		if data := tx.Data(); len(data) >= 64 {
+			if err := p.mark(common.BytesToHash(data[32:64]), p.lendingDb, lendingstate.NewStateSync); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mark marks the nodes of the state rooted at root. A state missing from the
+// trie database is skipped, a state missing some of its nodes fails.
+func (p *FRExPruner) mark(root common.Hash, triedb *trie.Database, newSync func(common.Hash, ethdb.KeyValueReader, *trie.SyncBloom) *trie.Sync) error {
+	if _, ok := p.marked[root]; ok {
+		return nil
+	}
+	if _, err := triedb.Node(root); err != nil {
+		return nil
+	}
+	var (
+		sched = newSync(root, &frexMarkReader{marked: p.marked, triedb: triedb}, p.bloom)
+		batch = &frexMarkBatch{marked: p.marked}
+	)
+	for queue := sched.Missing(0); len(queue) > 0; queue = sched.Missing(0) {
+		results := make([]trie.SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := triedb.Node(hash)
+			if err != nil {
+				return err
+			}
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, _, err := sched.Process(results); err != nil {
+			return err
+		}
+		if err := sched.Commit(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sweep deletes the trie nodes of the database which are not marked, returning
+// the number of deleted nodes. The entries whose key isn't the hash of their
+// value, the preimages among others, are kept. The sweep ends early, with the
+// nodes deleted so far, if stop is closed.
+func (p *FRExPruner) Sweep(stop <-chan struct{}) (int, error) {
+	var (
+		it      = p.db.NewIterator(nil, nil)
+		batch   = p.db.NewBatch()
+		deleted = 0
+	)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		hash := common.BytesToHash(key)
+		if _, ok := p.marked[hash]; ok || crypto.Keccak256Hash(it.Value()) != hash {
+			continue
+		}
+		if err := batch.Delete(hash[:]); err != nil {
+			return deleted, err
+		}
+		deleted++
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return deleted, err
+			}
+			batch.Reset()
+
+			select {
+			case <-stop:
+				return deleted, errFRExPruneStopped
+			default:
+			}
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return deleted, err
+	}
+	return deleted, it.Error()
+}
+
+// frexMarkReader is the database the trie schedulers of a marking run against,
+// knowing only the marked nodes.
+type frexMarkReader struct {
+	marked map[common.Hash]struct{}
+	triedb *trie.Database
+}
+
+func (r *frexMarkReader) Has(key []byte) (bool, error) {
+	_, ok := r.marked[common.BytesToHash(key)]
+	return ok, nil
+}
+
+func (r *frexMarkReader) Get(key []byte) ([]byte, error) {
+	if _, ok := r.marked[common.BytesToHash(key)]; !ok {
+		return nil, errFRExNodeNotMarked
+	}
+	return r.triedb.Node(common.BytesToHash(key))
+}
+
+// frexMarkBatch marks the nodes the trie schedulers of a marking commit.
+type frexMarkBatch struct {
+	marked map[common.Hash]struct{}
+	size   int
+}
+
+func (b *frexMarkBatch) Put(key []byte, value []byte) error {
+	b.marked[common.BytesToHash(key)] = struct{}{}
+	b.size += len(value)
+	return nil
+}
+
+func (b *frexMarkBatch) Delete(key []byte) error {
+	delete(b.marked, common.BytesToHash(key))
+	return nil
+}
+
+func (b *frexMarkBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *frexMarkBatch) Write() error {
+	return nil
+}
+
+func (b *frexMarkBatch) Reset() {
+	b.size = 0
+}
+
+func (b *frexMarkBatch) Replay(w ethdb.KeyValueWriter) error {
+	return nil
+}
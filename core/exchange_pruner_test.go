@@ -0,0 +1,122 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExDAO"
+	"github.com/FRECNET/FRExlending/lendingstate"
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/trie"
+)
+
+func TestFRExRetainedBlocks(t *testing.T) {
+	numbers := FRExRetainedBlocks(2000, 900, 1, 2)
+	if len(numbers) != 902 {
+		t.Fatalf("retained blocks mismatch: have %d, want 902", len(numbers))
+	}
+	if numbers[0] != 2000 || numbers[900] != 1100 || numbers[901] != 900 {
+		t.Errorf("retained blocks mismatch: have %d..%d and %d, want 2000..1100 and 900", numbers[0], numbers[900], numbers[901])
+	}
+	if numbers := FRExRetainedBlocks(100, 900, 2, 5); len(numbers) != 101 || numbers[100] != 0 {
+		t.Errorf("retained blocks of a young chain mismatch: have %d blocks", len(numbers))
+	}
+}
+
+// Tests that the pruner deletes the nodes of the trading states not retained,
+// and only them.
+func TestFRExPruner(t *testing.T) {
+	testFRExPruner(t, rawdb.NewMemoryDatabase())
+}
+
+// Tests the pruner on the database the FREx service hands to the chain on a
+// real node.
+func TestFRExPrunerBatchDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "FRExpruner")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := FRExDAO.NewBatchDatabase(dir, 0)
+	if db == nil {
+		t.Fatalf("failed to open batch database")
+	}
+	defer db.Close()
+
+	testFRExPruner(t, db)
+}
+
+func testFRExPruner(t *testing.T, db ethdb.Database) {
+	var (
+		cache     = tradingstate.NewDatabase(db)
+		orderBook = common.StringToHash("BTC/FRE")
+		orderId   = func(id uint64) common.Hash { return common.BigToHash(new(big.Int).SetUint64(id)) }
+		signature = &tradingstate.Signature{V: 1, R: common.HexToHash("111111"), S: common.HexToHash("222222")}
+	)
+	commit := func(statedb *tradingstate.TradingStateDB) common.Hash {
+		root := statedb.IntermediateRoot()
+		if _, err := statedb.Commit(); err != nil {
+			t.Fatalf("failed to commit trading state: %v", err)
+		}
+		if err := cache.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to commit trading trie: %v", err)
+		}
+		return root
+	}
+	statedb, _ := tradingstate.New(common.Hash{}, cache)
+	for i := uint64(1); i <= 10; i++ {
+		statedb.InsertOrderItem(orderBook, orderId(i), tradingstate.OrderItem{OrderID: i, Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: tradingstate.Ask, Signature: signature})
+	}
+	oldRoot := commit(statedb)
+
+	statedb, _ = tradingstate.New(oldRoot, cache)
+	for i := uint64(11); i <= 20; i++ {
+		statedb.InsertOrderItem(orderBook, orderId(i), tradingstate.OrderItem{OrderID: i, Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: tradingstate.Bid, Signature: signature})
+	}
+	newRoot := commit(statedb)
+
+	// An entry which isn't a trie node must survive the pruning
+	preimage := append([]byte("secure-key-"), orderId(1).Bytes()...)
+	db.Put(preimage, orderId(1).Bytes())
+
+	tx := types.NewTransaction(0, common.HexToAddress(common.TradingStateAddr), big.NewInt(0), 0, big.NewInt(0), append(newRoot.Bytes(), lendingstate.EmptyRoot.Bytes()...))
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{tx}, nil, nil)
+
+	triedb := trie.NewDatabase(db)
+	pruner := NewFRExPruner(db, triedb, triedb)
+	if err := pruner.MarkBlock(block); err != nil {
+		t.Fatalf("failed to mark block: %v", err)
+	}
+	deleted, err := pruner.Sweep(nil)
+	if err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+	if deleted == 0 {
+		t.Fatalf("no trie node deleted")
+	}
+	if ok, _ := db.Has(oldRoot[:]); ok {
+		t.Errorf("old trading state root not deleted")
+	}
+	if ok, _ := db.Has(preimage); !ok {
+		t.Errorf("preimage deleted")
+	}
+	statedb, err = tradingstate.New(newRoot, tradingstate.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open retained trading state: %v", err)
+	}
+	for i := uint64(1); i <= 20; i++ {
+		if order := statedb.GetOrder(orderBook, orderId(i)); order.Quantity == nil || order.Quantity.Uint64() != i {
+			t.Errorf("order %d of retained state mismatch: have %v", i, order.Quantity)
+		}
+	}
+	if price, _ := statedb.GetBestAskPrice(orderBook); price.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("best ask of retained state mismatch: have %v, want 1", price)
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/log"
@@ -388,6 +389,46 @@ func (hc *HeaderChain) CurrentHeader() *types.Header {
 	return hc.currentHeader.Load().(*types.Header)
 }
 
+// CurrentFinalizedHeader retrieves the highest block committed by the consensus
+// engine, or nil if no canonical block has been finalized yet.
+func (hc *HeaderChain) CurrentFinalizedHeader() *types.Header {
+	return hc.canonicalHeaderByHash(rawdb.ReadFinalizedBlockHash(hc.chainDb))
+}
+
+// finalizedHeader retrieves the highest block committed by the consensus engine
+// like CurrentFinalizedHeader, but fails instead of returning nil if a block was
+// recorded as finalized and it can't be found in the canonical chain.
+func (hc *HeaderChain) finalizedHeader() (*types.Header, error) {
+	hash := rawdb.ReadFinalizedBlockHash(hc.chainDb)
+	if hash == (common.Hash{}) {
+		return nil, nil
+	}
+	header := hc.canonicalHeaderByHash(hash)
+	if header == nil {
+		return nil, ErrUnknownFinalized
+	}
+	return header, nil
+}
+
+// CurrentSafeHeader retrieves the highest block certified by a quorum
+// certificate, or nil if no canonical block has been certified yet.
+func (hc *HeaderChain) CurrentSafeHeader() *types.Header {
+	return hc.canonicalHeaderByHash(rawdb.ReadSafeBlockHash(hc.chainDb))
+}
+
+// canonicalHeaderByHash retrieves a header by hash if it's part of the canonical
+// chain.
+func (hc *HeaderChain) canonicalHeaderByHash(hash common.Hash) *types.Header {
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	header := hc.GetHeaderByHash(hash)
+	if header == nil || GetCanonicalHash(hc.chainDb, header.Number.Uint64()) != hash {
+		return nil
+	}
+	return header
+}
+
 // SetCurrentHeader sets the current head header of the canonical chain.
 func (hc *HeaderChain) SetCurrentHeader(head *types.Header) {
 	if err := WriteHeadHeaderHash(hc.chainDb, head.Hash()); err != nil {
@@ -424,6 +465,13 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	for i := height; i > head; i-- {
 		DeleteCanonicalHash(hc.chainDb, i)
 	}
+	// Drop the finalized and safe blocks if they were rewound
+	if hash := rawdb.ReadFinalizedBlockHash(hc.chainDb); hash != (common.Hash{}) && hc.canonicalHeaderByHash(hash) == nil {
+		rawdb.WriteFinalizedBlockHash(hc.chainDb, common.Hash{})
+	}
+	if hash := rawdb.ReadSafeBlockHash(hc.chainDb); hash != (common.Hash{}) && hc.canonicalHeaderByHash(hash) == nil {
+		rawdb.WriteSafeBlockHash(hc.chainDb, common.Hash{})
+	}
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
 	hc.tdCache.Purge()
@@ -135,6 +135,8 @@ type LendingPool struct {
 	currentLendingState *lendingstate.LendingStateDB      // Current order state in the blockchain head
 	pendingState        *lendingstate.LendingManagedState // Pending state tracking virtual nonces
 
+	blacklist map[common.Address]uint64 // Addresses blacklisted on top of the current state
+
 	locals  *lendingAccountSet // Set of local transaction to exempt from eviction rules
 	journal *lendingtxJournal  // Journal of local transaction to back up to disk
 
@@ -298,6 +300,7 @@ func (pool *LendingPool) reset(oldHead, newblock *types.Block) {
 		return
 	}
 	pool.currentRootState = state
+	pool.blacklist = GetBlacklist(pool.chainconfig, newHead.Number, newHead.Root, state)
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -489,6 +492,24 @@ func (pool *LendingPool) validateRepayLending(cloneStateDb *state.StateDB, clone
 	}
 	return nil
 }
+func (pool *LendingPool) validateRecallLending(cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.UserAddress().String() != lendingTrade.Investor.String() {
+		return ErrInvalidLendingUserAddress
+	}
+	if tx.RelayerAddress().String() != lendingTrade.InvestingRelayer.String() {
+		return ErrInvalidLendingRelayer
+	}
+	return nil
+}
+
 func (pool *LendingPool) validateTopupLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
 	if tx.LendingTradeId() == 0 {
 		return ErrInvalidLendingTradeID
@@ -600,6 +621,9 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	if !lendingstate.IsValidRelayer(cloneStateDb, tx.RelayerAddress()) {
 		return fmt.Errorf("invalid lending relayer. ExchangeAddress: %s", tx.RelayerAddress().Hex())
 	}
+	if tx.Term() == lendingstate.OpenTerm && !pool.chain.Config().IsTIPFREXOpenTermLending(pool.chain.CurrentHeader().Number) {
+		return fmt.Errorf("open-term lending is not enabled. LendingToken: %s", tx.LendingToken().Hex())
+	}
 	if valid, _ := lendingstate.IsValidPair(cloneStateDb, tx.RelayerAddress(), tx.LendingToken(), tx.Term()); valid == false {
 		return fmt.Errorf("invalid pair. Relayer: %s. LendingToken: %s. Term: %d", tx.RelayerAddress().Hex(), tx.LendingToken().Hex(), tx.Term())
 	}
@@ -615,6 +639,9 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	if tx.IsRepayLending() {
 		return pool.validateRepayLending(cloneStateDb, cloneLendingStateDb, tx)
 	}
+	if tx.IsRecallLending() && tx.Term() == lendingstate.OpenTerm {
+		return pool.validateRecallLending(cloneLendingStateDb, tx)
+	}
 
 	return ErrInvalidLendingStatus
 }
@@ -624,7 +651,7 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 func (pool *LendingPool) validateTx(tx *types.LendingTransaction, local bool) error {
 
 	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
+	if IsBlacklisted(pool.blacklist, tx.From()) {
 		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
 	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/params"
+)
+
+// GetMaxMasternodes returns the size of the masternode set picked on top of
+// statedb from the dynamic masternodes hardfork on: the one of the chain
+// config if set, else the one voted in the setting contract, else
+// common.MaxMasternodesV2. statedb may be nil if the state isn't available.
+func GetMaxMasternodes(config *params.ChainConfig, statedb *state.StateDB) int {
+	if config.S2PoS != nil && config.S2PoS.MaxMasternodes > 0 {
+		return config.S2PoS.MaxMasternodes
+	}
+	if statedb != nil {
+		if max := state.GetMaxMasternodes(statedb); max > 0 {
+			return int(max)
+		}
+	}
+	return common.MaxMasternodesV2
+}
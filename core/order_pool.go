@@ -49,11 +49,18 @@ var (
 	ErrInvalidOrderPrice       = errors.New("invalid order price")
 	ErrInvalidOrderHash        = errors.New("invalid order hash")
 	ErrInvalidCancelledOrder   = errors.New("invalid cancel orderid")
+	ErrInvalidTriggerPrice     = errors.New("invalid order trigger price")
+	ErrInvalidTimeInForce      = errors.New("invalid order time in force")
+	ErrInvalidSelfTradeMode    = errors.New("invalid order self-trade prevention mode")
+	ErrInvalidBatchOrder       = errors.New("invalid batch order")
 )
 
 var (
 	OrderTypeLimit    = "LO"
 	OrderTypeMarket   = "MO"
+	OrderTypeStopMo   = "SMO"
+	OrderTypeStopLo   = "SLO"
+	OrderTypeTPO      = "TPO"
 	OrderStatusNew    = "NEW"
 	OrderStatusCancle = "CANCELLED"
 	OrderSideBid      = "BUY"
@@ -143,6 +150,8 @@ type OrderPool struct {
 	currentOrderState *tradingstate.TradingStateDB   // Current order state in the blockchain head
 	pendingState      *tradingstate.FREXManagedState // Pending state tracking virtual nonces
 
+	blacklist map[common.Address]uint64 // Addresses blacklisted on top of the current state
+
 	locals  *orderAccountSet // Set of local transaction to exempt from eviction rules
 	journal *ordertxJournal  // Journal of local transaction to back up to disk
 
@@ -303,6 +312,7 @@ func (pool *OrderPool) reset(oldHead, newblock *types.Block) {
 		return
 	}
 	pool.currentRootState = state
+	pool.blacklist = GetBlacklist(pool.chainconfig, newHead.Number, newHead.Root, state)
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -433,6 +443,9 @@ func (pool *OrderPool) GetSender(tx *types.OrderTransaction) (common.Address, er
 }
 
 func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
+	if tx.IsBatchOrder() {
+		return pool.validateBatchOrder(tx)
+	}
 	orderSide := tx.Side()
 	orderType := tx.Type()
 	orderStatus := tx.Status()
@@ -446,7 +459,7 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if quantity == nil || quantity.Cmp(big.NewInt(0)) <= 0 {
 			return ErrInvalidOrderQuantity
 		}
-		if orderType != OrderTypeMarket {
+		if orderType != OrderTypeMarket && orderType != OrderTypeStopMo && orderType != OrderTypeTPO {
 			if price == nil || price.Cmp(big.NewInt(0)) <= 0 {
 				return ErrInvalidOrderPrice
 			}
@@ -455,14 +468,43 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if orderSide != OrderSideAsk && orderSide != OrderSideBid {
 			return ErrInvalidOrderSide
 		}
-		if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
+		if tx.IsTriggerTypeOrder() {
+			if !pool.chainconfig.IsTIPFREXTriggerOrder(new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)) {
+				return ErrInvalidOrderType
+			}
+			order := &tradingstate.OrderItem{Type: orderType, TriggerPrice: tx.TriggerPrice()}
+			if err := order.VerifyTriggerPrice(); err != nil {
+				return ErrInvalidTriggerPrice
+			}
+		} else if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
 			return ErrInvalidOrderType
 		}
+		if tx.TimeInForce() != "" {
+			if !pool.chainconfig.IsTIPFREXTimeInForce(new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)) {
+				return ErrInvalidTimeInForce
+			}
+			order := &tradingstate.OrderItem{Type: orderType, TimeInForce: tx.TimeInForce(), ExpireAt: tx.ExpireAt()}
+			if err := order.VerifyTimeInForce(); err != nil {
+				return ErrInvalidTimeInForce
+			}
+			if order.IsExpired(pool.chain.CurrentBlock().Time().Uint64()) {
+				return ErrInvalidTimeInForce
+			}
+		}
+		if tx.SelfTradeMode() != "" {
+			if !pool.chainconfig.IsTIPFREXSelfTrade(new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)) {
+				return ErrInvalidSelfTradeMode
+			}
+			order := &tradingstate.OrderItem{SelfTradeMode: tx.SelfTradeMode()}
+			if err := order.VerifySelfTradeMode(); err != nil {
+				return ErrInvalidSelfTradeMode
+			}
+		}
 		if err := tradingstate.VerifyPair(cloneStateDb, tx.ExchangeAddress(), tx.BaseToken(), tx.QuoteToken()); err != nil {
 			return err
 		}
 
-		if orderType == OrderTypeLimit {
+		if orderType == OrderTypeLimit || tx.IsTriggerTypeOrder() {
 			S2PoSEngine, ok := pool.chain.Engine().(*S2PoS.S2PoS)
 			if !ok {
 				return ErrNotS2PoS
@@ -527,12 +569,95 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 	return nil
 }
 
+// validateBatchOrder checks the operations of a batch order against the
+// current state: the orders it cancels or amends must be open orders of the
+// user on its order book and the user must hold the tokens its new orders
+// spend.
+func (pool *OrderPool) validateBatchOrder(tx *types.OrderTransaction) error {
+	if !pool.chainconfig.IsTIPFREXBatchOrder(new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)) {
+		return ErrInvalidOrderType
+	}
+	if tx.Status() != OrderStatusNew {
+		return ErrInvalidOrderStatus
+	}
+	cloneStateDb := pool.currentRootState.Copy()
+	cloneFREXStateDb := pool.currentOrderState.Copy()
+
+	order := &tradingstate.OrderItem{
+		Nonce:           new(big.Int).SetUint64(tx.Nonce()),
+		ExchangeAddress: tx.ExchangeAddress(),
+		UserAddress:     tx.UserAddress(),
+		BaseToken:       tx.BaseToken(),
+		QuoteToken:      tx.QuoteToken(),
+		Status:          tx.Status(),
+		Type:            tx.Type(),
+		Batch:           tx.Batch(),
+	}
+	if err := order.VerifyBatch(); err != nil {
+		return ErrInvalidBatchOrder
+	}
+	if err := tradingstate.VerifyPair(cloneStateDb, tx.ExchangeAddress(), tx.BaseToken(), tx.QuoteToken()); err != nil {
+		return err
+	}
+	S2PoSEngine, ok := pool.chain.Engine().(*S2PoS.S2PoS)
+	if !ok {
+		return ErrNotS2PoS
+	}
+	FREXServ := S2PoSEngine.GetFREXService()
+	if FREXServ == nil {
+		return fmt.Errorf("FREx not found in order validation")
+	}
+	baseDecimal, err := FREXServ.GetTokenDecimal(pool.chain, cloneStateDb, tx.BaseToken())
+	if err != nil {
+		return fmt.Errorf("validateBatchOrder: failed to get baseDecimal. err: %v", err)
+	}
+	quoteDecimal, err := FREXServ.GetTokenDecimal(pool.chain, cloneStateDb, tx.QuoteToken())
+	if err != nil {
+		return fmt.Errorf("validateBatchOrder: failed to get quoteDecimal. err: %v", err)
+	}
+	if err := tradingstate.VerifyBatchBalance(cloneStateDb, cloneFREXStateDb, order, baseDecimal, quoteDecimal); err != nil {
+		return err
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken())
+	for _, item := range order.BatchOrders() {
+		if item.Status != OrderStatusCancle {
+			continue
+		}
+		originOrder := cloneFREXStateDb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(item.OrderID)))
+		if originOrder == tradingstate.EmptyOrder || originOrder.UserAddress != tx.UserAddress() {
+			log.Debug("Batch order cancels unknown order", "OrderId", item.OrderID, "BaseToken", tx.BaseToken().Hex(), "QuoteToken", tx.QuoteToken().Hex())
+			return ErrInvalidCancelledOrder
+		}
+		if originOrder.Hash != item.Hash {
+			log.Debug("Invalid order hash", "expected", originOrder.Hash.Hex(), "got", item.Hash.Hex())
+			return ErrInvalidOrderHash
+		}
+	}
+
+	var signer = types.OrderTxSigner{}
+	if !common.EmptyHash(tx.OrderHash()) {
+		if signer.Hash(tx) != tx.OrderHash() {
+			return ErrInvalidOrderHash
+		}
+	} else {
+		tx.SetOrderHash(signer.Hash(tx))
+	}
+	from, _ := types.OrderSender(pool.signer, tx)
+	if from != tx.UserAddress() {
+		return ErrInvalidOrderUserAddress
+	}
+	if !tradingstate.IsValidRelayer(cloneStateDb, tx.ExchangeAddress()) {
+		return fmt.Errorf("invalid relayer. ExchangeAddress: %s", tx.ExchangeAddress().Hex())
+	}
+	return nil
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *OrderPool) validateTx(tx *types.OrderTransaction, local bool) error {
 
 	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
+	if IsBlacklisted(pool.blacklist, tx.From()) {
 		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
 	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
+	"github.com/FRECNET/rlp"
+)
+
+var (
+	finalizedBlockKey = []byte("LastFinalized")
+	safeBlockKey      = []byte("LastSafe")
+
+	committedQCPrefix = []byte("S2PoS-QC-") // committedQCPrefix + hash -> quorum certificate of the committed block
+)
+
+// ReadFinalizedBlockHash retrieves the hash of the highest block committed by
+// the consensus engine.
+func ReadFinalizedBlockHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(finalizedBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteFinalizedBlockHash stores the hash of the highest committed block.
+func WriteFinalizedBlockHash(db ethdb.KeyValueWriter, hash common.Hash) error {
+	if err := db.Put(finalizedBlockKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last finalized block's hash", "err", err)
+	}
+	return nil
+}
+
+// ReadSafeBlockHash retrieves the hash of the highest block certified by a
+// quorum certificate.
+func ReadSafeBlockHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(safeBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSafeBlockHash stores the hash of the highest certified block.
+func WriteSafeBlockHash(db ethdb.KeyValueWriter, hash common.Hash) error {
+	if err := db.Put(safeBlockKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last safe block's hash", "err", err)
+	}
+	return nil
+}
+
+// ReadCommittedQCRLP retrieves the quorum certificate which certified a
+// committed block in RLP encoding.
+func ReadCommittedQCRLP(db ethdb.KeyValueReader, hash common.Hash) rlp.RawValue {
+	data, _ := db.Get(append(committedQCPrefix, hash.Bytes()...))
+	return data
+}
+
+// WriteCommittedQCRLP stores the RLP encoded quorum certificate which certified
+// a committed block.
+func WriteCommittedQCRLP(db ethdb.KeyValueWriter, hash common.Hash, qc rlp.RawValue) error {
+	if err := db.Put(append(committedQCPrefix, hash.Bytes()...), qc); err != nil {
+		log.Crit("Failed to store committed quorum certificate", "err", err)
+	}
+	return nil
+}
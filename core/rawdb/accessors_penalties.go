@@ -0,0 +1,45 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
+)
+
+var penaltyJournalPrefix = []byte("pj") // penaltyJournalPrefix + num (uint64 big endian) + parent hash -> penalty journal of the checkpoint
+
+func penaltyJournalKey(number uint64, parentHash common.Hash) []byte {
+	return append(append(append([]byte{}, penaltyJournalPrefix...), encodeNumber(number)...), parentHash.Bytes()...)
+}
+
+// ReadPenaltyJournal retrieves the JSON encoded penalty journal of the
+// checkpoint block at the given number built on top of the given parent.
+func ReadPenaltyJournal(db ethdb.KeyValueReader, number uint64, parentHash common.Hash) []byte {
+	data, _ := db.Get(penaltyJournalKey(number, parentHash))
+	return data
+}
+
+// WritePenaltyJournal stores the JSON encoded penalty journal of a checkpoint
+// block. The journal is keyed by parent hash as the penalties are computed
+// before the checkpoint block is sealed.
+func WritePenaltyJournal(db ethdb.KeyValueWriter, number uint64, parentHash common.Hash, journal []byte) error {
+	if err := db.Put(penaltyJournalKey(number, parentHash), journal); err != nil {
+		log.Crit("Failed to store penalty journal", "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
+)
+
+var (
+	blockRewardsPrefix = []byte("rw") // blockRewardsPrefix + num (uint64 big endian) + hash -> block rewards
+	rewardLookupPrefix = []byte("rl") // rewardLookupPrefix + address + epoch (uint64 big endian) + num (uint64 big endian) + hash -> reward amount
+)
+
+// RewardLookupEntry is a reward received by an address at a canonical reward
+// checkpoint block.
+type RewardLookupEntry struct {
+	Epoch       uint64
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Amount      *big.Int
+}
+
+// encodeNumber encodes a number as big endian uint64
+func encodeNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+func blockRewardsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockRewardsPrefix, encodeNumber(number)...), hash.Bytes()...)
+}
+
+func rewardLookupKey(address common.Address, epoch uint64, number uint64, hash common.Hash) []byte {
+	key := append(append(rewardLookupPrefix, address.Bytes()...), encodeNumber(epoch)...)
+	return append(append(key, encodeNumber(number)...), hash.Bytes()...)
+}
+
+// ReadBlockRewards retrieves the JSON encoded rewards distributed by a block.
+func ReadBlockRewards(db ethdb.KeyValueReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(blockRewardsKey(hash, number))
+	return data
+}
+
+// WriteBlockRewards stores the JSON encoded rewards distributed by a block.
+func WriteBlockRewards(db ethdb.KeyValueWriter, hash common.Hash, number uint64, rewards []byte) error {
+	if err := db.Put(blockRewardsKey(hash, number), rewards); err != nil {
+		log.Crit("Failed to store block rewards", "err", err)
+	}
+	return nil
+}
+
+// DeleteBlockRewards removes the rewards distributed by a block.
+func DeleteBlockRewards(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockRewardsKey(hash, number)); err != nil {
+		log.Crit("Failed to delete block rewards", "err", err)
+	}
+}
+
+// WriteRewardLookupEntry indexes the reward received by an address at a reward
+// checkpoint block.
+func WriteRewardLookupEntry(db ethdb.KeyValueWriter, address common.Address, epoch uint64, number uint64, hash common.Hash, amount *big.Int) error {
+	if err := db.Put(rewardLookupKey(address, epoch, number, hash), amount.Bytes()); err != nil {
+		log.Crit("Failed to store reward lookup entry", "err", err)
+	}
+	return nil
+}
+
+// DeleteRewardLookupEntry removes the reward index of an address at a reward
+// checkpoint block.
+func DeleteRewardLookupEntry(db ethdb.KeyValueWriter, address common.Address, epoch uint64, number uint64, hash common.Hash) {
+	if err := db.Delete(rewardLookupKey(address, epoch, number, hash)); err != nil {
+		log.Crit("Failed to delete reward lookup entry", "err", err)
+	}
+}
+
+// ReadRewardLookupEntries retrieves the rewards received by an address between
+// two epochs, both included, in epoch order.
+func ReadRewardLookupEntries(db ethdb.Iteratee, address common.Address, fromEpoch, toEpoch uint64) []*RewardLookupEntry {
+	prefix := append(append([]byte{}, rewardLookupPrefix...), address.Bytes()...)
+	it := db.NewIterator(prefix, encodeNumber(fromEpoch))
+	defer it.Release()
+
+	var entries []*RewardLookupEntry
+	for it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) || len(key) != len(prefix)+8+8+common.HashLength {
+			continue
+		}
+		key = key[len(prefix):]
+		epoch := binary.BigEndian.Uint64(key[:8])
+		if epoch > toEpoch {
+			break
+		}
+		entries = append(entries, &RewardLookupEntry{
+			Epoch:       epoch,
+			BlockNumber: binary.BigEndian.Uint64(key[8:16]),
+			BlockHash:   common.BytesToHash(key[16:]),
+			Amount:      new(big.Int).SetBytes(it.Value()),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/common"
+)
+
+// Tests that the reward lookups of an address are retrieved in epoch order and
+// within the requested range only.
+func TestRewardLookupEntries(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	holder := common.HexToAddress("0x1")
+	other := common.HexToAddress("0x2")
+	for epoch := uint64(1); epoch <= 5; epoch++ {
+		number := epoch * 900
+		hash := common.BigToHash(new(big.Int).SetUint64(number))
+		WriteRewardLookupEntry(db, holder, epoch, number, hash, new(big.Int).SetUint64(epoch*100))
+		WriteRewardLookupEntry(db, other, epoch, number, hash, big.NewInt(1))
+	}
+	entries := ReadRewardLookupEntries(db, holder, 2, 4)
+	if len(entries) != 3 {
+		t.Fatalf("entries mismatch: have %d, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		epoch := uint64(i + 2)
+		if entry.Epoch != epoch || entry.BlockNumber != epoch*900 {
+			t.Errorf("entry %d: epoch %d block %d, want epoch %d block %d", i, entry.Epoch, entry.BlockNumber, epoch, epoch*900)
+		}
+		if entry.Amount.Uint64() != epoch*100 {
+			t.Errorf("entry %d: amount %v, want %d", i, entry.Amount, epoch*100)
+		}
+	}
+	// Deleted lookups aren't returned anymore
+	DeleteRewardLookupEntry(db, holder, 3, 2700, common.BigToHash(big.NewInt(2700)))
+	if entries := ReadRewardLookupEntries(db, holder, 0, 10); len(entries) != 4 {
+		t.Fatalf("entries mismatch after delete: have %d, want 4", len(entries))
+	}
+}
+
+func TestBlockRewardsStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	hash := common.HexToHash("0x1234")
+	if data := ReadBlockRewards(db, hash, 900); len(data) != 0 {
+		t.Fatalf("non existent rewards returned: %s", data)
+	}
+	rewards := []byte(`{"rewards":{}}`)
+	WriteBlockRewards(db, hash, 900, rewards)
+	if data := ReadBlockRewards(db, hash, 900); !bytes.Equal(data, rewards) {
+		t.Fatalf("rewards mismatch: have %s, want %s", data, rewards)
+	}
+	DeleteBlockRewards(db, hash, 900)
+	if data := ReadBlockRewards(db, hash, 900); len(data) != 0 {
+		t.Fatalf("deleted rewards returned: %s", data)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/log"
+)
+
+// BlockRewards is the reward distribution of a reward checkpoint block. The
+// "signers" entry holds the signs and the reward of every signer, the "rewards"
+// entry holds the rewards paid by every signer to its holders.
+type BlockRewards map[string]map[string]map[string]*big.Int
+
+// GetBlockRewards retrieves the rewards distributed by a block, or nil if it
+// didn't distribute any or the node didn't execute it. The rewards are stored
+// as the blocks are processed, the blocks imported by fast sync below the pivot
+// have none stored nor indexed by recipient.
+func GetBlockRewards(db ethdb.KeyValueReader, hash common.Hash, number uint64) BlockRewards {
+	data := rawdb.ReadBlockRewards(db, hash, number)
+	if len(data) == 0 {
+		return nil
+	}
+	rewards := make(BlockRewards)
+	if err := json.Unmarshal(data, &rewards); err != nil {
+		log.Error("Invalid block rewards JSON", "number", number, "hash", hash, "err", err)
+		return nil
+	}
+	return rewards
+}
+
+// GetRewardsByAddress retrieves the rewards received by an address at the
+// canonical reward checkpoints between two epochs, both included.
+func GetRewardsByAddress(db ethdb.Iteratee, address common.Address, fromEpoch, toEpoch uint64) []*rawdb.RewardLookupEntry {
+	return rawdb.ReadRewardLookupEntries(db, address, fromEpoch, toEpoch)
+}
+
+// holderRewards sums up the rewards received by every holder from all the
+// signers of the block.
+func (rewards BlockRewards) holderRewards() map[common.Address]*big.Int {
+	totals := make(map[common.Address]*big.Int)
+	for _, holders := range rewards["rewards"] {
+		for holder, amount := range holders {
+			if amount == nil {
+				continue
+			}
+			addr := common.HexToAddress(holder)
+			if totals[addr] == nil {
+				totals[addr] = new(big.Int)
+			}
+			totals[addr].Add(totals[addr], amount)
+		}
+	}
+	return totals
+}
+
+// writeBlockRewards stores the rewards the consensus engine distributed while
+// finalizing the block and returns them. The engine keeps them by the hash
+// without the validator signature, so the blocks finalized before the
+// signature is added find them, for the recently finalized blocks only. The
+// block must be written right after it is processed.
+func (bc *BlockChain) writeBlockRewards(db ethdb.KeyValueWriter, block *types.Block) (BlockRewards, error) {
+	engine, ok := bc.Engine().(*S2PoS.S2PoS)
+	if !ok {
+		return nil, nil
+	}
+	distributed := engine.GetRewards(block.Header())
+	if distributed == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(distributed)
+	if err != nil {
+		return nil, err
+	}
+	rewards := make(BlockRewards)
+	if err := json.Unmarshal(data, &rewards); err != nil {
+		return nil, err
+	}
+	if err := rawdb.WriteBlockRewards(db, block.Hash(), block.NumberU64(), data); err != nil {
+		return nil, err
+	}
+	return rewards, nil
+}
+
+// rewardsEpoch returns the epoch the reward lookups of the block are indexed
+// under.
+func (bc *BlockChain) rewardsEpoch(number uint64) uint64 {
+	if bc.chainConfig.S2PoS == nil || bc.chainConfig.S2PoS.Epoch == 0 {
+		return 0
+	}
+	return number / bc.chainConfig.S2PoS.Epoch
+}
+
+// writeRewardLookups indexes the rewards of a canonical block by recipient.
+func (bc *BlockChain) writeRewardLookups(db ethdb.KeyValueWriter, hash common.Hash, number uint64, rewards BlockRewards) error {
+	epoch := bc.rewardsEpoch(number)
+	for holder, amount := range rewards.holderRewards() {
+		if err := rawdb.WriteRewardLookupEntry(db, holder, epoch, number, hash, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRewardLookups removes the reward index of a block leaving the canonical
+// chain.
+func (bc *BlockChain) deleteRewardLookups(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	rewards := GetBlockRewards(bc.db, hash, number)
+	epoch := bc.rewardsEpoch(number)
+	for holder := range rewards.holderRewards() {
+		rawdb.DeleteRewardLookupEntry(db, holder, epoch, number, hash)
+	}
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/FRECNET/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// The storage layout of the blacklist contract, contracts/blacklist/contract/Blacklist.sol
+var (
+	slotBlacklistMapping = map[string]uint64{
+		"entries":         0,
+		"effectiveBlocks": 1,
+		"indexes":         2,
+		"masternodeSet":   3,
+		"masternodeCount": 4,
+		"masternodes":     5,
+	}
+	blacklistCache, _ = lru.NewARC(128)
+)
+
+// GetBlacklistFromStateWithCache returns the addresses of the blacklist
+// contract in the state of the given root, by the number of the block from
+// which they are blacklisted. The blacklists are cached by root, a blacklist
+// read with a retrieval error isn't.
+func GetBlacklistFromStateWithCache(trieRoot common.Hash, statedb *StateDB) map[common.Address]uint64 {
+	if statedb == nil {
+		return map[common.Address]uint64{}
+	}
+	var info map[common.Address]uint64
+	if data, ok := blacklistCache.Get(trieRoot); ok {
+		info = data.(map[common.Address]uint64)
+	} else {
+		info = GetBlacklistFromState(statedb)
+		if statedb.Error() != nil {
+			return info
+		}
+		blacklistCache.Add(trieRoot, info)
+	}
+	blacklist := make(map[common.Address]uint64, len(info))
+	for addr, number := range info {
+		blacklist[addr] = number
+	}
+	return blacklist
+}
+
+// GetBlacklistFromState reads the addresses of the blacklist contract, by the
+// number of the block from which they are blacklisted.
+func GetBlacklistFromState(statedb *StateDB) map[common.Address]uint64 {
+	contract := common.HexToAddress(common.BlacklistSMC)
+	slotEntriesHash := common.BigToHash(new(big.Int).SetUint64(slotBlacklistMapping["entries"]))
+	length := statedb.GetState(contract, slotEntriesHash).Big().Uint64()
+	blacklist := make(map[common.Address]uint64)
+	for i := uint64(0); i < length; i++ {
+		key := GetLocDynamicArrAtElement(slotEntriesHash, i, 1)
+		addr := common.HexToAddress(statedb.GetState(contract, key).Hex())
+		locEffectiveBlock := GetLocMappingAtKey(addr.Hash(), slotBlacklistMapping["effectiveBlocks"])
+		blacklist[addr] = statedb.GetState(contract, common.BigToHash(locEffectiveBlock)).Big().Uint64()
+	}
+	return blacklist
+}
+
+// AddBlacklistToState appends addresses to the entries of the blacklist
+// contract, blacklisted from the given block, the way the contract itself
+// does. The addresses are appended sorted, so that every node writes the same
+// storage.
+func AddBlacklistToState(statedb *StateDB, addrs []common.Address, effectiveBlock uint64) {
+	contract := common.HexToAddress(common.BlacklistSMC)
+	slotEntriesHash := common.BigToHash(new(big.Int).SetUint64(slotBlacklistMapping["entries"]))
+	length := statedb.GetState(contract, slotEntriesHash).Big().Uint64()
+
+	sorted := make([]common.Address, len(addrs))
+	copy(sorted, addrs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	for _, addr := range sorted {
+		locIndex := common.BigToHash(GetLocMappingAtKey(addr.Hash(), slotBlacklistMapping["indexes"]))
+		if statedb.GetState(contract, locIndex) != (common.Hash{}) {
+			continue
+		}
+		statedb.SetState(contract, GetLocDynamicArrAtElement(slotEntriesHash, length, 1), addr.Hash())
+		locEffectiveBlock := GetLocMappingAtKey(addr.Hash(), slotBlacklistMapping["effectiveBlocks"])
+		statedb.SetState(contract, common.BigToHash(locEffectiveBlock), common.BigToHash(new(big.Int).SetUint64(effectiveBlock)))
+		length++
+		statedb.SetState(contract, locIndex, common.BigToHash(new(big.Int).SetUint64(length)))
+	}
+	statedb.SetState(contract, slotEntriesHash, common.BigToHash(new(big.Int).SetUint64(length)))
+}
+
+// SetBlacklistMasternodes replaces the masternodes allowed to propose and vote
+// in the blacklist contract. The entries of the previous masternodes are left
+// in place, they no longer match the id of the current set.
+func SetBlacklistMasternodes(statedb *StateDB, masternodes []common.Address) {
+	contract := common.HexToAddress(common.BlacklistSMC)
+	slotSetHash := common.BigToHash(new(big.Int).SetUint64(slotBlacklistMapping["masternodeSet"]))
+	id := statedb.GetState(contract, slotSetHash).Big()
+	set := common.BigToHash(id.Add(id, common.Big1))
+	statedb.SetState(contract, slotSetHash, set)
+
+	count := int64(0)
+	for _, addr := range masternodes {
+		loc := common.BigToHash(GetLocMappingAtKey(addr.Hash(), slotBlacklistMapping["masternodes"]))
+		if statedb.GetState(contract, loc) == set {
+			continue
+		}
+		statedb.SetState(contract, loc, set)
+		count++
+	}
+	slotCountHash := common.BigToHash(new(big.Int).SetUint64(slotBlacklistMapping["masternodeCount"]))
+	statedb.SetState(contract, slotCountHash, common.BigToHash(big.NewInt(count)))
+}
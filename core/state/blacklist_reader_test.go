@@ -0,0 +1,48 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/core/rawdb"
+)
+
+func TestBlacklistFromState(t *testing.T) {
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	if blacklist := GetBlacklistFromState(statedb); len(blacklist) != 0 {
+		t.Fatalf("blacklist of an empty contract mismatch: have %v", blacklist)
+	}
+	var (
+		addr1 = common.HexToAddress("0x0000000000000000000000000000000000000001")
+		addr2 = common.HexToAddress("0x0000000000000000000000000000000000000002")
+		addr3 = common.HexToAddress("0x0000000000000000000000000000000000000003")
+	)
+	AddBlacklistToState(statedb, []common.Address{addr2, addr1}, 100)
+	AddBlacklistToState(statedb, []common.Address{addr1, addr3}, 200)
+
+	want := map[common.Address]uint64{addr1: 100, addr2: 100, addr3: 200}
+	blacklist := GetBlacklistFromState(statedb)
+	if len(blacklist) != len(want) {
+		t.Fatalf("blacklist length mismatch: have %d, want %d", len(blacklist), len(want))
+	}
+	for addr, number := range want {
+		if blacklist[addr] != number {
+			t.Errorf("effective block of %x mismatch: have %d, want %d", addr, blacklist[addr], number)
+		}
+	}
+	// The entries must be appended sorted, whatever the order given
+	slotEntriesHash := GetLocSimpleVariable(slotBlacklistMapping["entries"])
+	for i, addr := range []common.Address{addr1, addr2, addr3} {
+		entry := statedb.GetState(common.HexToAddress(common.BlacklistSMC), GetLocDynamicArrAtElement(slotEntriesHash, uint64(i), 1))
+		if entry != addr.Hash() {
+			t.Errorf("entry %d mismatch: have %x, want %x", i, entry, addr)
+		}
+	}
+
+	// The cached blacklists must not be altered by their readers
+	root := statedb.IntermediateRoot(false)
+	GetBlacklistFromStateWithCache(root, statedb)[addr1] = 0
+	if blacklist := GetBlacklistFromStateWithCache(root, statedb); blacklist[addr1] != 100 {
+		t.Errorf("cached effective block of %x mismatch: have %d, want 100", addr1, blacklist[addr1])
+	}
+}
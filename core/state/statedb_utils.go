@@ -152,9 +152,19 @@ func GetVoterCap(statedb *StateDB, candidate, voter common.Address) *big.Int {
 	return ret.Big()
 }
 
+// The setting contract packs owner and epochValue in slot 0, then
+// maxMasterNode, rewardPerEpoch and TIPIncreaseMasternodes in slot 1.
 var (
 	slotSettingMapping = map[string]uint64{
-		"blockSigners": 0,
-		"blocks":       1,
+		"maxMasterNode": 1,
 	}
 )
+
+// GetMaxMasternodes returns the size of the masternode set voted in the setting
+// contract, 0 if it isn't set.
+func GetMaxMasternodes(statedb *StateDB) uint64 {
+	slot := slotSettingMapping["maxMasterNode"]
+	ret := statedb.GetState(common.HexToAddress(common.SettingAddr), GetLocSimpleVariable(slot))
+	// maxMasterNode is the lowest-order uint64 of its slot
+	return new(big.Int).SetBytes(ret[common.HashLength-8:]).Uint64()
+}
@@ -75,26 +75,39 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, tra
 		allLogs  []*types.Log
 		gp       = new(GasPool).AddGas(block.GasLimit())
 	)
+	blacklist, err := p.blacklist(block, statedb)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 	// Mutate the the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
-	if common.TIPSigning.Cmp(header.Number) == 0 {
+	if p.config.IsTIPSigningBlock(header.Number) {
 		statedb.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
+	if p.config.IsTIPBlacklistContractBlock(header.Number) {
+		ApplyBlacklistHardFork(p.config, statedb)
+	}
+	if p.config.IsTIPBlacklistContract(header.Number) {
+		ApplyBlacklistMasternodes(p.config, p.engine, p.bc, header, statedb)
+	}
+	if p.config.IsTIPFREXFeeScheduleBlock(header.Number) {
+		tradingstate.ApplyFeeScheduleHardFork(statedb)
+	}
 	parentState := statedb.Copy()
 	InitSignerInTransactions(p.config, header, block.Transactions())
 	balanceUpdated := map[common.Address]*big.Int{}
 	totalFeeUsed := big.NewInt(0)
 	for i, tx := range block.Transactions() {
 		// check black-list txs after hf
-		if (block.Number().Uint64() >= common.BlackListHFNumber) && !common.IsTestnet {
+		if p.config.IsBlackList(block.Number()) {
 			// check if sender is in black list
-			if tx.From() != nil && common.Blacklist[*tx.From()] {
+			if IsBlacklisted(blacklist, tx.From()) {
 				return nil, nil, 0, fmt.Errorf("Block contains transaction with sender in black-list: %v", tx.From().Hex())
 			}
 			// check if receiver is in black list
-			if tx.To() != nil && common.Blacklist[*tx.To()] {
+			if IsBlacklisted(blacklist, tx.To()) {
 				return nil, nil, 0, fmt.Errorf("Block contains transaction with receiver in black-list: %v", tx.To().Hex())
 			}
 		}
@@ -130,7 +143,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, tra
 		// }
 		// changeit-GASUPDATE
 		if tokenFeeUsed {
-			fee := common.GetGasFee(block.Header().Number.Uint64(), gas)
+			fee := p.config.GetGasFee(block.Header().Number, gas)
 			balanceFee[*tx.To()] = new(big.Int).Sub(balanceFee[*tx.To()], fee)
 			balanceUpdated[*tx.To()] = balanceFee[*tx.To()]
 			totalFeeUsed = totalFeeUsed.Add(totalFeeUsed, fee)
@@ -151,13 +164,26 @@ func (p *StateProcessor) ProcessBlockNoValidator(cBlock *CalculatedBlock, stated
 		allLogs  []*types.Log
 		gp       = new(GasPool).AddGas(block.GasLimit())
 	)
+	blacklist, err := p.blacklist(block, statedb)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 	// Mutate the the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
-	if common.TIPSigning.Cmp(header.Number) == 0 {
+	if p.config.IsTIPSigningBlock(header.Number) {
 		statedb.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
+	if p.config.IsTIPBlacklistContractBlock(header.Number) {
+		ApplyBlacklistHardFork(p.config, statedb)
+	}
+	if p.config.IsTIPBlacklistContract(header.Number) {
+		ApplyBlacklistMasternodes(p.config, p.engine, p.bc, header, statedb)
+	}
+	if p.config.IsTIPFREXFeeScheduleBlock(header.Number) {
+		tradingstate.ApplyFeeScheduleHardFork(statedb)
+	}
 	if cBlock.stop {
 		return nil, nil, 0, ErrStopPreparingBlock
 	}
@@ -173,13 +199,13 @@ func (p *StateProcessor) ProcessBlockNoValidator(cBlock *CalculatedBlock, stated
 	receipts = make([]*types.Receipt, block.Transactions().Len())
 	for i, tx := range block.Transactions() {
 		// check black-list txs after hf
-		if (block.Number().Uint64() >= common.BlackListHFNumber) && !common.IsTestnet {
+		if p.config.IsBlackList(block.Number()) {
 			// check if sender is in black list
-			if tx.From() != nil && common.Blacklist[*tx.From()] {
+			if IsBlacklisted(blacklist, tx.From()) {
 				return nil, nil, 0, fmt.Errorf("Block contains transaction with sender in black-list: %v", tx.From().Hex())
 			}
 			// check if receiver is in black list
-			if tx.To() != nil && common.Blacklist[*tx.To()] {
+			if IsBlacklisted(blacklist, tx.To()) {
 				return nil, nil, 0, fmt.Errorf("Block contains transaction with receiver in black-list: %v", tx.To().Hex())
 			}
 		}
@@ -218,7 +244,7 @@ func (p *StateProcessor) ProcessBlockNoValidator(cBlock *CalculatedBlock, stated
 		// }
 		// changeit-GASUPDATE
 		if tokenFeeUsed {
-			fee := common.GetGasFee(block.Header().Number.Uint64(), gas)
+			fee := p.config.GetGasFee(block.Header().Number, gas)
 			balanceFee[*tx.To()] = new(big.Int).Sub(balanceFee[*tx.To()], fee)
 			balanceUpdated[*tx.To()] = balanceFee[*tx.To()]
 			totalFeeUsed = totalFeeUsed.Add(totalFeeUsed, fee)
@@ -258,7 +284,7 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 			balanceFee = value
 		}
 	}
-	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number), balanceFee, header.Number)
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number), balanceFee, config.GetTRC21GasPrice(header.Number))
 	if err != nil {
 		return nil, 0, err, false
 	}
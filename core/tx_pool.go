@@ -220,6 +220,8 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
+	blacklist map[common.Address]uint64 // Addresses blacklisted on top of the current state
+
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
@@ -432,6 +434,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 		return
 	}
 	pool.currentState = statedb
+	pool.blacklist = GetBlacklist(pool.chainconfig, newHead.Number, newHead.Root, statedb)
 	pool.trc21FeeCapacity = state.GetTRC21FeeCapacityFromStateWithCache(newHead.Root, statedb)
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
@@ -588,11 +591,11 @@ func (pool *TxPool) GetSender(tx *types.Transaction) (common.Address, error) {
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
+	if IsBlacklisted(pool.blacklist, tx.From()) {
 		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
 	}
 	// check if receiver is in black list
-	if tx.To() != nil && common.Blacklist[*tx.To()] {
+	if IsBlacklisted(pool.blacklist, tx.To()) {
 		return fmt.Errorf("Reject transaction with receiver in black-list: %v", tx.To().Hex())
 	}
 
@@ -638,7 +641,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.chain.CurrentHeader() != nil {
 		number = pool.chain.CurrentHeader().Number
 	}
-	minGasPrice := common.GetMinGasPrice(number)
+	minGasPrice := pool.chainconfig.GetMinGasPrice(number)
 	// changeit-GASUPDATE
 
 	feeCapacity := big.NewInt(0)
@@ -650,7 +653,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 				return ErrInsufficientFunds
 			}
 			// changeit-GASUPDATE
-			cost = tx.TxCost(number)
+			cost = tx.TxCost(pool.chainconfig.GetGasPrice(number))
 			// minGasPrice = common.TRC21GasPrice
 		}
 	}
@@ -1082,7 +1085,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 
 		// Drop all transactions that are too costly (low balance or out of gas)
-		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.trc21FeeCapacity, number)
+		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.trc21FeeCapacity, pool.chainconfig.GetGasPrice(number))
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
@@ -1246,7 +1249,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			number = pool.chain.CurrentHeader().Number
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
-		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.trc21FeeCapacity, number)
+		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.trc21FeeCapacity, pool.chainconfig.GetGasPrice(number))
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
@@ -180,6 +180,20 @@ func (lendingsign LendingTxSigner) LendingTopUpHash(tx *LendingTransaction) comm
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// LendingRecallHash hash of recall lending transaction
+func (lendingsign LendingTxSigner) LendingRecallHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
@@ -195,6 +209,9 @@ func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
 	if tx.IsRepayLending() {
 		return lendingsign.LendingRepayHash(tx)
 	}
+	if tx.IsRecallLending() {
+		return lendingsign.LendingRecallHash(tx)
+	}
 	return common.Hash{}
 }
 
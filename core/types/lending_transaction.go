@@ -47,6 +47,7 @@ const (
 	LendingSideInvest          = "INVEST"
 	LendingRePay               = "REPAY"
 	LendingTopup               = "TOPUP"
+	LendingRecall              = "RECALL"
 )
 
 // LendingTransaction lending transaction
@@ -116,6 +117,14 @@ func (tx *LendingTransaction) IsTopupLending() bool {
 	return false
 }
 
+// IsRecallLending check if tx is recall lending transaction
+func (tx *LendingTransaction) IsRecallLending() bool {
+	if tx.Type() == LendingRecall {
+		return true
+	}
+	return false
+}
+
 // IsMoTypeLending check if tx type is MO lending
 func (tx *LendingTransaction) IsMoTypeLending() bool {
 	if tx.Type() == LendingTypeMo {
@@ -114,7 +114,7 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write(tx.BaseToken().Bytes())
 	sha.Write(tx.QuoteToken().Bytes())
 	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
-	if tx.IsLoTypeOrder() {
+	if tx.IsLoTypeOrder() || tx.IsSlTypeOrder() {
 		if tx.Price() != nil {
 			sha.Write(common.BigToHash(tx.Price()).Bytes())
 		}
@@ -123,6 +123,16 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write([]byte(tx.Status()))
 	sha.Write([]byte(tx.Type()))
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	if tx.IsTriggerTypeOrder() && tx.TriggerPrice() != nil {
+		sha.Write(common.BigToHash(tx.TriggerPrice()).Bytes())
+	}
+	if tx.TimeInForce() != "" {
+		sha.Write([]byte(tx.TimeInForce()))
+		sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.ExpireAt())).Bytes())
+	}
+	if tx.SelfTradeMode() != "" {
+		sha.Write([]byte(tx.SelfTradeMode()))
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
@@ -141,9 +151,56 @@ func (ordersign OrderTxSigner) OrderCancelHash(tx *OrderTransaction) common.Hash
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// BatchOrderOpHash hash of an operation of a batch order, it is the hash of
+// the order placed by a new or amend operation
+func (ordersign OrderTxSigner) BatchOrderOpHash(tx *OrderTransaction, index int) common.Hash {
+	op := tx.data.Batch[index]
+	sha := sha3.NewKeccak256()
+	sha.Write(tx.ExchangeAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.BaseToken().Bytes())
+	sha.Write(tx.QuoteToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(index))).Bytes())
+	sha.Write([]byte(op.Action))
+	sha.Write(common.BigToHash(new(big.Int).SetUint64(op.OrderID)).Bytes())
+	sha.Write(op.OrderHash.Bytes())
+	sha.Write([]byte(op.Side))
+	// a nil amount is decoded as zero from rlp
+	for _, amount := range []*big.Int{op.Quantity, op.Price} {
+		if amount == nil {
+			amount = new(big.Int)
+		}
+		sha.Write(common.BigToHash(amount).Bytes())
+	}
+	sha.Write([]byte(op.TimeInForce))
+	sha.Write(common.BigToHash(new(big.Int).SetUint64(op.ExpireAt)).Bytes())
+	sha.Write([]byte(op.SelfTradeMode))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// OrderBatchHash hash of batch order, it covers the hashes of its operations
+func (ordersign OrderTxSigner) OrderBatchHash(tx *OrderTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(tx.ExchangeAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.BaseToken().Bytes())
+	sha.Write(tx.QuoteToken().Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write([]byte(tx.Type()))
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	for i := range tx.data.Batch {
+		sha.Write(ordersign.BatchOrderOpHash(tx, i).Bytes())
+	}
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (ordersign OrderTxSigner) Hash(tx *OrderTransaction) common.Hash {
+	if tx.IsBatchOrder() {
+		return ordersign.OrderBatchHash(tx)
+	}
 	if tx.IsCancelledOrder() {
 		return ordersign.OrderCancelHash(tx)
 	}
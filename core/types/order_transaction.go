@@ -43,6 +43,22 @@ const (
 	OrderStatusCancelled     = "CANCELLED"
 	OrderTypeMo              = "MO"
 	OrderTypeLo              = "LO"
+	OrderTypeStopMo          = "SMO"
+	OrderTypeStopLo          = "SLO"
+	OrderTypeTakeProfit      = "TPO"
+	OrderTypeBatch           = "BATCH"
+	OrderTimeInForceGTC      = "GTC" // good till cancelled, the default
+	OrderTimeInForceIOC      = "IOC" // immediate or cancel
+	OrderTimeInForceFOK      = "FOK" // fill or kill
+	OrderTimeInForcePO       = "PO"  // post only
+	OrderTimeInForceGTT      = "GTT" // good till time
+	OrderSelfTradeCN         = "CN"  // cancel newest, the default
+	OrderSelfTradeCO         = "CO"  // cancel oldest
+	OrderSelfTradeCB         = "CB"  // cancel both
+	OrderSelfTradeDC         = "DC"  // decrement and cancel
+	OrderBatchNew            = "NEW"
+	OrderBatchCancel         = "CANCEL"
+	OrderBatchAmend          = "AMEND" // cancel and replace
 )
 
 // OrderTransaction order transaction
@@ -73,8 +89,41 @@ type ordertxdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash"`
+
+	// Price activating stop and take profit orders
+	TriggerPrice *big.Int `json:"triggerPrice,omitempty" rlp:"optional"`
+
+	// Time in force of limit orders, ExpireAt is the unix time good till time
+	// orders expire at
+	TimeInForce string `json:"timeInForce,omitempty" rlp:"optional"`
+	ExpireAt    uint64 `json:"expireAt,omitempty" rlp:"optional"`
+
+	// What happens when the order meets an order of the same user
+	SelfTradeMode string `json:"selfTradeMode,omitempty" rlp:"optional"`
+
+	// Operations of a batch order, applied atomically in order
+	Batch BatchOrderOps `json:"batch,omitempty" rlp:"optional"`
 }
 
+// BatchOrderOp is an operation of a batch order transaction on the order book
+// of the batch. A new operation places a limit order, a cancel operation
+// cancels the order OrderID of hash OrderHash, and an amend operation cancels
+// it and places a limit order replacing it on the same side.
+type BatchOrderOp struct {
+	Action        string      `json:"action"`
+	OrderID       uint64      `json:"orderid,omitempty"`
+	OrderHash     common.Hash `json:"orderHash,omitempty"`
+	Side          string      `json:"side,omitempty"`
+	Quantity      *big.Int    `json:"quantity,omitempty"`
+	Price         *big.Int    `json:"price,omitempty"`
+	TimeInForce   string      `json:"timeInForce,omitempty"`
+	ExpireAt      uint64      `json:"expireAt,omitempty"`
+	SelfTradeMode string      `json:"selfTradeMode,omitempty"`
+}
+
+// BatchOrderOps is the operations of a batch order
+type BatchOrderOps []*BatchOrderOp
+
 // IsCancelledOrder check if tx is cancelled transaction
 func (tx *OrderTransaction) IsCancelledOrder() bool {
 	if tx.Status() == OrderStatusCancelled {
@@ -83,6 +132,11 @@ func (tx *OrderTransaction) IsCancelledOrder() bool {
 	return false
 }
 
+// IsBatchOrder check if tx is a batch order transaction
+func (tx *OrderTransaction) IsBatchOrder() bool {
+	return tx.Type() == OrderTypeBatch
+}
+
 // IsMoTypeOrder check if tx type is MO Order
 func (tx *OrderTransaction) IsMoTypeOrder() bool {
 	if tx.Type() == OrderTypeMo {
@@ -99,6 +153,23 @@ func (tx *OrderTransaction) IsLoTypeOrder() bool {
 	return false
 }
 
+// IsSlTypeOrder check if tx type is stop limit order
+func (tx *OrderTransaction) IsSlTypeOrder() bool {
+	if tx.Type() == OrderTypeStopLo {
+		return true
+	}
+	return false
+}
+
+// IsTriggerTypeOrder check if tx type is a stop or take profit order
+func (tx *OrderTransaction) IsTriggerTypeOrder() bool {
+	switch tx.Type() {
+	case OrderTypeStopMo, OrderTypeStopLo, OrderTypeTakeProfit:
+		return true
+	}
+	return false
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *OrderTransaction) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &tx.data)
@@ -129,6 +200,10 @@ func (tx *OrderTransaction) Type() string                    { return tx.data.Ty
 func (tx *OrderTransaction) Signature() (V, R, S *big.Int)   { return tx.data.V, tx.data.R, tx.data.S }
 func (tx *OrderTransaction) OrderHash() common.Hash          { return tx.data.Hash }
 func (tx *OrderTransaction) OrderID() uint64                 { return tx.data.OrderID }
+func (tx *OrderTransaction) TriggerPrice() *big.Int          { return tx.data.TriggerPrice }
+func (tx *OrderTransaction) TimeInForce() string             { return tx.data.TimeInForce }
+func (tx *OrderTransaction) ExpireAt() uint64                { return tx.data.ExpireAt }
+func (tx *OrderTransaction) SelfTradeMode() string           { return tx.data.SelfTradeMode }
 func (tx *OrderTransaction) EncodedSide() *big.Int {
 	if tx.Side() == "BUY" {
 		return big.NewInt(0)
@@ -138,6 +213,43 @@ func (tx *OrderTransaction) EncodedSide() *big.Int {
 }
 func (tx *OrderTransaction) SetOrderHash(h common.Hash) { tx.data.Hash = h }
 
+// SetTriggerPrice sets the price activating a stop or take profit order, it
+// must be set before the order is signed.
+func (tx *OrderTransaction) SetTriggerPrice(price *big.Int) {
+	if price == nil {
+		tx.data.TriggerPrice = nil
+		return
+	}
+	tx.data.TriggerPrice = new(big.Int).Set(price)
+}
+
+// SetTimeInForce sets the time in force of a limit order along with the expiry
+// time of good till time orders, it must be set before the order is signed.
+func (tx *OrderTransaction) SetTimeInForce(timeInForce string, expireAt uint64) {
+	tx.data.TimeInForce = timeInForce
+	tx.data.ExpireAt = expireAt
+}
+
+// SetSelfTradeMode sets the self-trade prevention mode of an order, it must be
+// set before the order is signed.
+func (tx *OrderTransaction) SetSelfTradeMode(mode string) {
+	tx.data.SelfTradeMode = mode
+}
+
+// Batch returns the operations of a batch order, nil for other orders.
+func (tx *OrderTransaction) Batch() *BatchOrderOps {
+	if len(tx.data.Batch) == 0 {
+		return nil
+	}
+	return &tx.data.Batch
+}
+
+// SetBatch sets the operations of a batch order, it must be set before the
+// order is signed.
+func (tx *OrderTransaction) SetBatch(ops BatchOrderOps) {
+	tx.data.Batch = ops
+}
+
 // From get transaction from
 func (tx *OrderTransaction) From() *common.Address {
 	if tx.data.V != nil {
@@ -248,7 +248,10 @@ func (tx *Transaction) Size() common.StorageSize {
 // AsMessage requires a signer to derive the sender.
 //
 // XXX Rename message to something less arbitrary?
-func (tx *Transaction) AsMessage(s Signer, balanceFee *big.Int, number *big.Int) (Message, error) {
+//
+// A transaction paying its fee in TRC21 tokens, with a balanceFee, is charged
+// the given TRC21 gas price.
+func (tx *Transaction) AsMessage(s Signer, balanceFee *big.Int, trc21GasPrice *big.Int) (Message, error) {
 	msg := Message{
 		nonce:           tx.data.AccountNonce,
 		gasLimit:        tx.data.GasLimit,
@@ -270,13 +273,7 @@ func (tx *Transaction) AsMessage(s Signer, balanceFee *big.Int, number *big.Int)
 	// }
 	// changeit-GASUPDATE
 	if balanceFee != nil {
-		if number.Cmp(common.BlockNumberGas50x) >= 0 {
-			msg.gasPrice = common.GasPrice50x
-		} else if number.Cmp(common.TIPTRC21Fee) > 0 {
-			msg.gasPrice = common.TRC21GasPrice
-		} else {
-			msg.gasPrice = common.TRC21GasPriceBefore
-		}
+		msg.gasPrice = trc21GasPrice
 	}
 	return msg, err
 }
@@ -310,8 +307,8 @@ func (tx *Transaction) TRC21Cost() *big.Int {
 // changeit-GASUPDATE
 
 // Cost returns amount + gasprice * gaslimit.
-func (tx *Transaction) TxCost(number *big.Int) *big.Int {
-	total := new(big.Int).Mul(common.GetGasPrice(number), new(big.Int).SetUint64(tx.data.GasLimit))
+func (tx *Transaction) TxCost(gasPrice *big.Int) *big.Int {
+	total := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(tx.data.GasLimit))
 	total.Add(total, tx.data.Amount)
 	return total
 }
@@ -439,11 +436,7 @@ func (tx *Transaction) IsFREXApplyTransaction() bool {
 		return false
 	}
 
-	addr := common.FREXListingSMC
-	if common.IsTestnet {
-		addr = common.FREXListingSMCTestNet
-	}
-	if tx.To().String() != addr.String() {
+	if tx.To().String() != common.FREXListingSMC.String() {
 		return false
 	}
 
@@ -466,11 +459,7 @@ func (tx *Transaction) IsFREZApplyTransaction() bool {
 		return false
 	}
 
-	addr := common.TRC21IssuerSMC
-	if common.IsTestnet {
-		addr = common.TRC21IssuerSMCTestNet
-	}
-	if tx.To().String() != addr.String() {
+	if tx.To().String() != common.TRC21IssuerSMC.String() {
 		return false
 	}
 
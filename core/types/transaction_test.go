@@ -233,3 +233,32 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+// Tests that an order without trigger price but with a time in force, whose
+// nil trigger price is encoded as an empty value, decodes to the same order.
+func TestOrderTransactionOptionalFieldsRLP(t *testing.T) {
+	tx := NewOrderTransaction(1, big.NewInt(10), big.NewInt(5), common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03"), common.HexToAddress("0x04"), OrderStatusNew, "BUY", OrderTypeLo, common.Hash{}, 0)
+	tx.SetTimeInForce(OrderTimeInForceGTT, 100)
+	tx.SetOrderHash(OrderTxSigner{}.Hash(tx))
+
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	decoded := new(OrderTransaction)
+	if err := rlp.DecodeBytes(enc, decoded); err != nil {
+		t.Fatalf("failed to decode order: %v", err)
+	}
+	if decoded.TriggerPrice() != nil {
+		t.Errorf("trigger price mismatch: have %v, want nil", decoded.TriggerPrice())
+	}
+	if decoded.TimeInForce() != OrderTimeInForceGTT {
+		t.Errorf("time in force mismatch: have %q, want %q", decoded.TimeInForce(), OrderTimeInForceGTT)
+	}
+	if hash := (OrderTxSigner{}).Hash(decoded); hash != tx.OrderHash() {
+		t.Errorf("order hash mismatch: have %x, want %x", hash, tx.OrderHash())
+	}
+	if decoded.Hash() != tx.Hash() {
+		t.Errorf("transaction hash mismatch: have %x, want %x", decoded.Hash(), tx.Hash())
+	}
+}
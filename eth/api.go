@@ -303,16 +303,28 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 	return &PublicDebugAPI{eth: eth}
 }
 
+// blockByNumber retrieves a canonical block by number, the pending and latest
+// tags designating the head block and the finalized and safe ones resolved
+// like the ethapi does.
+func blockByNumber(ctx context.Context, eth *Ethereum, blockNr rpc.BlockNumber) (*types.Block, error) {
+	switch blockNr {
+	case rpc.PendingBlockNumber, rpc.LatestBlockNumber:
+		return eth.blockchain.CurrentBlock(), nil
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		header, err := eth.ApiBackend.HeaderByNumber(ctx, blockNr)
+		if err != nil {
+			return nil, err
+		}
+		return eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+	}
+	return eth.blockchain.GetBlockByNumber(uint64(blockNr)), nil
+}
+
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
-	if blockNr == rpc.PendingBlockNumber {
-		blockNr = rpc.LatestBlockNumber
-	}
-	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
-		block = api.eth.blockchain.CurrentBlock()
-	} else {
-		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	block, err := blockByNumber(context.Background(), api.eth, blockNr)
+	if err != nil {
+		return state.Dump{}, err
 	}
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
@@ -40,6 +40,7 @@ import (
 	"github.com/FRECNET/contracts"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/core/bloombits"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/state"
 	stateDatabase "github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
@@ -49,11 +50,19 @@ import (
 	"github.com/FRECNET/eth/util"
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/event"
+	"github.com/FRECNET/light"
 	"github.com/FRECNET/log"
 	"github.com/FRECNET/params"
 	"github.com/FRECNET/rpc"
 )
 
+var (
+	errFinalizedBlockNotFound = errors.New("finalized block not found")
+	errSafeBlockNotFound      = errors.New("safe block not found")
+	errBlockNotCanonical      = errors.New("hash is not currently canonical")
+	errInvalidBlockSelector   = errors.New("invalid arguments; neither block nor hash specified")
+)
+
 // EthApiBackend implements ethapi.Backend for full nodes
 type EthApiBackend struct {
 	eth *Ethereum
@@ -79,8 +88,19 @@ func (b *EthApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 		blockNr = rpc.LatestBlockNumber
 	}
 	// Otherwise resolve and return the block
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber:
 		return b.eth.blockchain.CurrentBlock().Header(), nil
+	case rpc.FinalizedBlockNumber:
+		if block := b.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+			return block.Header(), nil
+		}
+		return nil, errFinalizedBlockNotFound
+	case rpc.SafeBlockNumber:
+		if block := b.eth.blockchain.CurrentSafeBlock(); block != nil {
+			return block.Header(), nil
+		}
+		return nil, errSafeBlockNotFound
 	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
@@ -91,12 +111,40 @@ func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 		blockNr = rpc.LatestBlockNumber
 	}
 	// Otherwise resolve and return the block
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber:
 		return b.eth.blockchain.CurrentBlock(), nil
+	case rpc.FinalizedBlockNumber:
+		if block := b.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+			return block, nil
+		}
+		return nil, errFinalizedBlockNotFound
+	case rpc.SafeBlockNumber:
+		if block := b.eth.blockchain.CurrentSafeBlock(); block != nil {
+			return block, nil
+		}
+		return nil, errSafeBlockNotFound
 	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(blockNr)), nil
 }
 
+func (b *EthApiBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.BlockByNumber(ctx, blockNr)
+	}
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block := b.eth.blockchain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, nil
+		}
+		if blockNrOrHash.RequireCanonical && core.GetCanonicalHash(b.eth.chainDb, block.NumberU64()) != hash {
+			return nil, errBlockNotCanonical
+		}
+		return block, nil
+	}
+	return nil, errInvalidBlockSelector
+}
+
 func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
@@ -268,6 +316,14 @@ func (b *EthApiBackend) GetEngine() consensus.Engine {
 func (s *EthApiBackend) GetRewardByHash(hash common.Hash) map[string]map[string]map[string]*big.Int {
 	header := s.eth.blockchain.GetHeaderByHash(hash)
 	if header != nil {
+		if rewards := core.GetBlockRewards(s.eth.chainDb, hash, header.Number.Uint64()); rewards != nil {
+			return rewards
+		}
+		// The checkpoints processed before the rewards were stored in the
+		// database only have the reward files the previous releases wrote to
+		// the rewards folder of the data directory. They are named after the
+		// block hash, or the hash without the validator signature for the
+		// blocks imported before it was added, and are never written anymore.
 		data, err := ioutil.ReadFile(filepath.Join(common.StoreRewardFolder, header.Number.String()+"."+header.Hash().Hex()))
 		if err == nil {
 			rewards := make(map[string]map[string]map[string]*big.Int)
@@ -289,6 +345,12 @@ func (s *EthApiBackend) GetRewardByHash(hash common.Hash) map[string]map[string]
 	return make(map[string]map[string]map[string]*big.Int)
 }
 
+// GetRewardsByAddress returns the rewards received by an address at the reward
+// checkpoints of the epochs between fromEpoch and toEpoch, both included.
+func (s *EthApiBackend) GetRewardsByAddress(address common.Address, fromEpoch, toEpoch uint64) []*rawdb.RewardLookupEntry {
+	return core.GetRewardsByAddress(s.eth.chainDb, address, fromEpoch, toEpoch)
+}
+
 // GetVotersRewards return a map of voters of snapshot at given block hash
 // there is a function engine.HookReward nearly does the same thing but
 // it does change the stateDB too - so can't use it here
@@ -352,7 +414,7 @@ func (b *EthApiBackend) GetVotersRewards(masternodeAddr common.Address) map[comm
 	var voterResults map[common.Address]*big.Int
 	for signer, calcReward := range rewardSigners {
 		if signer == masternodeAddr {
-			err, rewards := contracts.CalculateRewardForHolders(foundationWalletAddr, state, masternodeAddr, calcReward, number)
+			err, rewards := contracts.CalculateRewardForHolders(b.ChainConfig(), foundationWalletAddr, state, masternodeAddr, calcReward, number)
 			if err != nil {
 				log.Crit("Fail to calculate reward for holders.", "error", err)
 				return nil
@@ -419,6 +481,12 @@ func (b *EthApiBackend) GetMasternodesCap(checkpoint uint64) map[common.Address]
 	return masternodesCap
 }
 
+// GetEpochProof builds the epoch proof of the canonical checkpoint with the
+// given number.
+func (b *EthApiBackend) GetEpochProof(ctx context.Context, number uint64) (*light.EpochProof, error) {
+	return light.BuildEpochProof(b.eth.chainDb, b.ChainConfig(), number)
+}
+
 func (b *EthApiBackend) GetBlocksHashCache(blockNr uint64) []common.Hash {
 	return b.eth.blockchain.GetBlocksHashCache(blockNr)
 }
@@ -96,23 +96,13 @@ type txTraceTask struct {
 // between two blocks (excluding start) and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceConfig) (*rpc.Subscription, error) {
 	// Fetch the block interval that we want to trace
-	var from, to *types.Block
-
-	switch start {
-	case rpc.PendingBlockNumber:
-		from = api.eth.blockchain.CurrentBlock()
-	case rpc.LatestBlockNumber:
-		from = api.eth.blockchain.CurrentBlock()
-	default:
-		from = api.eth.blockchain.GetBlockByNumber(uint64(start))
+	from, err := blockByNumber(ctx, api.eth, start)
+	if err != nil {
+		return nil, err
 	}
-	switch end {
-	case rpc.PendingBlockNumber:
-		to = api.eth.blockchain.CurrentBlock()
-	case rpc.LatestBlockNumber:
-		to = api.eth.blockchain.CurrentBlock()
-	default:
-		to = api.eth.blockchain.GetBlockByNumber(uint64(end))
+	to, err := blockByNumber(ctx, api.eth, end)
+	if err != nil {
+		return nil, err
 	}
 	// Trace the chain if we've found all our blocks
 	if from == nil {
@@ -205,7 +195,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 							balacne = value
 						}
 					}
-					msg, _ := tx.AsMessage(signer, balacne, task.block.Number())
+					msg, _ := tx.AsMessage(signer, balacne, api.config.GetTRC21GasPrice(task.block.Number()))
 					vmctx := core.NewEVMContext(msg, task.block.Header(), api.eth.blockchain, nil)
 
 					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
@@ -350,15 +340,9 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 // EVM and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
 	// Fetch the block that we want to trace
-	var block *types.Block
-
-	switch number {
-	case rpc.PendingBlockNumber:
-		block = api.eth.blockchain.CurrentBlock()
-	case rpc.LatestBlockNumber:
-		block = api.eth.blockchain.CurrentBlock()
-	default:
-		block = api.eth.blockchain.GetBlockByNumber(uint64(number))
+	block, err := blockByNumber(ctx, api.eth, number)
+	if err != nil {
+		return nil, err
 	}
 	// Trace the block if it was found
 	if block == nil {
@@ -445,7 +429,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 						balacne = value
 					}
 				}
-				msg, _ := txs[task.index].AsMessage(signer, balacne, block.Number())
+				msg, _ := txs[task.index].AsMessage(signer, balacne, api.config.GetTRC21GasPrice(block.Number()))
 				vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 
 				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
@@ -470,7 +454,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 			}
 		}
 		// Generate the next state snapshot fast without tracing
-		msg, _ := tx.AsMessage(signer, balacne, block.Number())
+		msg, _ := tx.AsMessage(signer, balacne, api.config.GetTRC21GasPrice(block.Number()))
 		vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 
 		vmenv := vm.NewEVM(vmctx, statedb, FRExState, api.config, vm.Config{})
@@ -670,9 +654,18 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 	}
 	// Recompute transactions up to the target index.
 	feeCapacity := state.GetTRC21FeeCapacityFromState(statedb)
-	if common.TIPSigning.Cmp(block.Header().Number) == 0 {
+	if api.config.IsTIPSigningBlock(block.Header().Number) {
 		statedb.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
+	if api.config.IsTIPBlacklistContractBlock(block.Header().Number) {
+		core.ApplyBlacklistHardFork(api.config, statedb)
+	}
+	if api.config.IsTIPBlacklistContract(block.Header().Number) {
+		core.ApplyBlacklistMasternodes(api.config, api.eth.engine, api.eth.blockchain, block.Header(), statedb)
+	}
+	if api.config.IsTIPFREXFeeScheduleBlock(block.Header().Number) {
+		tradingstate.ApplyFeeScheduleHardFork(statedb)
+	}
 	core.InitSignerInTransactions(api.config, block.Header(), block.Transactions())
 	balanceUpdated := map[common.Address]*big.Int{}
 	totalFeeUsed := big.NewInt(0)
@@ -688,7 +681,7 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 					balanceFee = value
 				}
 			}
-			msg, err := tx.AsMessage(types.MakeSigner(api.config, block.Header().Number), balanceFee, block.Number())
+			msg, err := tx.AsMessage(types.MakeSigner(api.config, block.Header().Number), balanceFee, api.config.GetTRC21GasPrice(block.Number()))
 			if err != nil {
 				return nil, vm.Context{}, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 			}
@@ -711,7 +704,7 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 		// }
 		// changeit-GASUPDATE
 		if tokenFeeUsed {
-			fee := common.GetGasFee(block.Header().Number.Uint64(), gas)
+			fee := api.config.GetGasFee(block.Header().Number, gas)
 			feeCapacity[*tx.To()] = new(big.Int).Sub(feeCapacity[*tx.To()], fee)
 			balanceUpdated[*tx.To()] = feeCapacity[*tx.To()]
 			totalFeeUsed = totalFeeUsed.Add(totalFeeUsed, fee)
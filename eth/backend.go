@@ -128,6 +128,9 @@ func New(ctx *node.ServiceContext, config *Config, FREXServ *FREx.FREX, lendingS
 		return nil, genesisErr
 	}
 
+	if config.Testnet {
+		chainConfig = chainConfig.WithTestnetDefaults()
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	eth := &Ethereum{
@@ -162,7 +165,7 @@ func New(ctx *node.ServiceContext, config *Config, FREXServ *FREx.FREX, lendingS
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, FRExPruneEpochs: config.FRExPruneEpochs, FRExPruneCheckpoints: config.FRExPruneCheckpoints}
 	)
 	if eth.chainConfig.S2PoS != nil {
 		c := eth.engine.(*S2PoS.S2PoS)
@@ -248,6 +251,10 @@ func New(ctx *node.ServiceContext, config *Config, FREXServ *FREx.FREX, lendingS
 		}
 
 		appendM2HeaderHook := func(block *types.Block) (*types.Block, bool, error) {
+			// v2 blocks don't have a double validation signature
+			if c.IsV2Block(block.Number()) {
+				return block, false, nil
+			}
 			eb, err := eth.Etherbase()
 			if err != nil {
 				log.Error("Cannot get etherbase for append m2 header", "err", err)
@@ -286,6 +293,10 @@ func New(ctx *node.ServiceContext, config *Config, FREXServ *FREx.FREX, lendingS
 			S2PoS1.0 Specific hooks
 		*/
 		hooks.AttachConsensusV1Hooks(c, eth.blockchain, chainConfig)
+		/*
+			S2PoS2.0 Specific hooks
+		*/
+		hooks.AttachConsensusV2Hooks(c, eth.blockchain, chainConfig)
 
 		eth.txPool.IsSigner = func(address common.Address) bool {
 			currentHeader := eth.blockchain.CurrentHeader()
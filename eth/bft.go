@@ -0,0 +1,155 @@
+// Copyright (c) 2021 FRECNET
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/log"
+)
+
+// BroadcastVote propagates a BFT vote to the peers which don't know it yet.
+func (pm *ProtocolManager) BroadcastVote(vote *utils.Vote) {
+	hash := vote.Hash()
+	peers := pm.peers.PeersWithoutVote(hash)
+	for _, peer := range peers {
+		if err := peer.SendVote(vote); err != nil {
+			log.Debug("Failed to send vote", "peer", peer.id, "err", err)
+		}
+	}
+	log.Trace("Broadcast vote", "hash", hash, "recipients", len(peers))
+}
+
+// BroadcastTimeout propagates a BFT timeout to the peers which don't know it yet.
+func (pm *ProtocolManager) BroadcastTimeout(timeout *utils.Timeout) {
+	hash := timeout.Hash()
+	peers := pm.peers.PeersWithoutTimeout(hash)
+	for _, peer := range peers {
+		if err := peer.SendTimeout(timeout); err != nil {
+			log.Debug("Failed to send timeout", "peer", peer.id, "err", err)
+		}
+	}
+	log.Trace("Broadcast timeout", "hash", hash, "recipients", len(peers))
+}
+
+// BroadcastSyncInfo propagates the BFT sync info to the peers which don't know
+// it yet.
+func (pm *ProtocolManager) BroadcastSyncInfo(syncInfo *utils.SyncInfo) {
+	hash := syncInfo.Hash()
+	peers := pm.peers.PeersWithoutSyncInfo(hash)
+	for _, peer := range peers {
+		if err := peer.SendSyncInfo(syncInfo); err != nil {
+			log.Debug("Failed to send sync info", "peer", peer.id, "err", err)
+		}
+	}
+	log.Trace("Broadcast sync info", "hash", hash, "recipients", len(peers))
+}
+
+// handleVote verifies a vote received from a peer, relays it and hands it over
+// to the consensus engine.
+func (pm *ProtocolManager) handleVote(vote *utils.Vote) {
+	ok, err := pm.bft.VerifyVoteMessage(pm.blockchain, vote)
+	if err != nil {
+		log.Debug("Discarded invalid vote", "round", vote.ProposedBlockInfo.Round, "hash", vote.ProposedBlockInfo.Hash, "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	pm.BroadcastVote(vote)
+	if err := pm.bft.HandleVote(pm.blockchain, vote); err != nil {
+		log.Warn("Failed to handle vote", "round", vote.ProposedBlockInfo.Round, "hash", vote.ProposedBlockInfo.Hash, "err", err)
+	}
+}
+
+// handleTimeout verifies a timeout received from a peer, relays it and hands it
+// over to the consensus engine.
+func (pm *ProtocolManager) handleTimeout(timeout *utils.Timeout) {
+	ok, err := pm.bft.VerifyTimeoutMessage(pm.blockchain, timeout)
+	if err != nil {
+		log.Debug("Discarded invalid timeout", "round", timeout.Round, "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	pm.BroadcastTimeout(timeout)
+	if err := pm.bft.HandleTimeout(pm.blockchain, timeout); err != nil {
+		log.Warn("Failed to handle timeout", "round", timeout.Round, "err", err)
+	}
+}
+
+// handleSyncInfo verifies a sync info received from a peer, relays it and hands
+// it over to the consensus engine.
+func (pm *ProtocolManager) handleSyncInfo(syncInfo *utils.SyncInfo) {
+	ok, err := pm.bft.VerifySyncInfoMessage(pm.blockchain, syncInfo)
+	if err != nil {
+		log.Debug("Discarded invalid sync info", "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	pm.BroadcastSyncInfo(syncInfo)
+	if err := pm.bft.HandleSyncInfo(pm.blockchain, syncInfo); err != nil {
+		log.Warn("Failed to handle sync info", "err", err)
+	}
+}
+
+// bftBroadcastLoop relays the votes, timeouts and sync infos produced by the
+// local consensus engine.
+func (pm *ProtocolManager) bftBroadcastLoop() {
+	for {
+		select {
+		case obj := <-pm.bft.EngineV2.BroadcastCh:
+			switch msg := obj.(type) {
+			case *utils.Vote:
+				pm.knownVotes.Add(msg.Hash(), true)
+				pm.BroadcastVote(msg)
+			case *utils.Timeout:
+				pm.knownTimeouts.Add(msg.Hash(), true)
+				pm.BroadcastTimeout(msg)
+			case *utils.SyncInfo:
+				pm.knownSyncInfos.Add(msg.Hash(), true)
+				pm.BroadcastSyncInfo(msg)
+			default:
+				log.Error("Unknown BFT message type", "msg", obj)
+			}
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// bftChainHeadLoop hands the v2 blocks over to the consensus engine once they
+// are imported, so the masternodes can vote for them.
+func (pm *ProtocolManager) bftChainHeadLoop() {
+	for {
+		select {
+		case ev := <-pm.chainHeadCh:
+			header := ev.Block.Header()
+			if !pm.bft.IsV2Block(header.Number) {
+				continue
+			}
+			if err := pm.bft.HandleProposedBlock(pm.blockchain, header); err != nil {
+				log.Warn("Failed to handle proposed block", "number", header.Number, "hash", header.Hash(), "err", err)
+			}
+
+			// Err() channel will be closed when unsubscribing.
+		case <-pm.chainHeadSub.Err():
+			return
+		}
+	}
+}
@@ -82,6 +82,7 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 	NoPruning bool
+	Testnet   bool // Apothem testnet, whose defaults apply to the chain config
 
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
@@ -94,6 +95,10 @@ type Config struct {
 	TrieCache          int
 	TrieTimeout        time.Duration
 
+	// Pruning of the FREX trading and lending states, disabled if no epochs are kept
+	FRExPruneEpochs      uint64 `toml:",omitempty"`
+	FRExPruneCheckpoints uint64 `toml:",omitempty"`
+
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
 	MinerThreads int            `toml:",omitempty"`
@@ -198,6 +198,16 @@ type BlockChain interface {
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
 }
 
+// FRExChain encapsulates the functions a BlockChain keeping the trading and
+// lending states requires to fast sync them along with the pivot state.
+type FRExChain interface {
+	// FRExStateRoots retrieves the trading and lending state roots recorded in a block.
+	FRExStateRoots(*types.Block) (common.Hash, common.Hash, bool)
+
+	// FRExStateDB retrieves the database of the trading and lending state tries.
+	FRExStateDB() ethdb.KeyValueStore
+}
+
 // New creates a new downloader to fetch hashes and blocks from remote peers.
 func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
@@ -1475,6 +1485,9 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 				if stateSync.err != nil {
 					return stateSync.err
 				}
+				if err := d.syncFRExState(types.NewBlockWithHeader(P.Header).WithBody(P.Transactions, P.Uncles)); err != nil {
+					return err
+				}
 				if err := d.commitPivotBlock(P); err != nil {
 					return err
 				}
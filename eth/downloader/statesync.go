@@ -23,9 +23,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending/lendingstate"
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/core/state"
+	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/crypto/sha3"
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/log"
@@ -60,7 +63,34 @@ type stateSyncStats struct {
 
 // syncState starts downloading state with the given root hash.
 func (d *Downloader) syncState(root common.Hash) *stateSync {
-	s := newStateSync(d, root)
+	return d.startStateSync(newStateSync(d, root))
+}
+
+// syncFRExState downloads the trading and lending states recorded in the given
+// block, if the chain keeps them, and waits for the downloads to complete.
+func (d *Downloader) syncFRExState(block *types.Block) error {
+	chain, ok := d.blockchain.(FRExChain)
+	if !ok {
+		return nil
+	}
+	tradingRoot, lendingRoot, ok := chain.FRExStateRoots(block)
+	if !ok {
+		return nil
+	}
+	db := chain.FRExStateDB()
+	for _, sched := range []*trie.Sync{
+		tradingstate.NewStateSync(tradingRoot, db, trie.NewSyncBloom(1, memorydb.New())),
+		lendingstate.NewStateSync(lendingRoot, db, trie.NewSyncBloom(1, memorydb.New())),
+	} {
+		if err := d.startStateSync(newTrieSync(d, sched, db)).Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startStateSync hands a state sync over to the state fetcher.
+func (d *Downloader) startStateSync(s *stateSync) *stateSync {
 	select {
 	case d.stateSyncStart <- s:
 	case <-d.quitCh:
@@ -216,6 +246,7 @@ type stateSync struct {
 	d *Downloader // Downloader instance to access and manage current peerset
 
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
+	db     ethdb.KeyValueStore        // Database to store the downloaded trie nodes in
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
 
@@ -239,9 +270,16 @@ type stateTask struct {
 // yet start the sync. The user needs to call run to initiate.
 // only use fast sync but FRE only run full sync
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
+	return newTrieSync(d, state.NewStateSync(root, d.stateDB, trie.NewSyncBloom(1, memorydb.New())), d.stateDB)
+}
+
+// newTrieSync creates a new download scheduler of the tries of the given sync,
+// storing them in db.
+func newTrieSync(d *Downloader, sched *trie.Sync, db ethdb.KeyValueStore) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB, trie.NewSyncBloom(1, memorydb.New())),
+		sched:   sched,
+		db:      db,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -324,7 +362,7 @@ func (s *stateSync) commit(force bool) error {
 		return nil
 	}
 	start := time.Now()
-	b := s.d.stateDB.NewBatch()
+	b := s.db.NewBatch()
 	s.sched.Commit(b)
 	if err := b.Write(); err != nil {
 		return fmt.Errorf("DB write error: %v", err)
@@ -102,6 +102,14 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
+	// Resolve the finalized and safe tags into block numbers
+	var err error
+	if f.begin, err = f.resolveBlockTag(ctx, f.begin); err != nil {
+		return nil, err
+	}
+	if f.end, err = f.resolveBlockTag(ctx, f.end); err != nil {
+		return nil, err
+	}
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
@@ -110,10 +118,7 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		end = head
 	}
 	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs []*types.Log
-		err  error
-	)
+	var logs []*types.Log
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
@@ -130,6 +135,20 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// resolveBlockTag maps the finalized and safe block tags to the number of the
+// block they currently point to.
+func (f *Filter) resolveBlockTag(ctx context.Context, number int64) (int64, error) {
+	switch rpc.BlockNumber(number) {
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil {
+			return 0, err
+		}
+		return header.Number.Int64(), nil
+	}
+	return number, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -143,7 +143,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	// Check gas price min.
 	// minGasPrice := common.MinGasPrice
 	// changeit-GASUPDATE
-	minGasPrice := common.GetMinGasPrice(head.Number)
+	minGasPrice := gpo.backend.ChainConfig().GetMinGasPrice(head.Number)
 
 	if price.Cmp(minGasPrice) < 0 {
 		price = new(big.Int).Set(minGasPrice)
@@ -29,6 +29,8 @@ import (
 
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/consensus/S2PoS/utils"
 	"github.com/FRECNET/consensus/misc"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/core/types"
@@ -50,6 +52,9 @@ const (
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
 )
 
 var (
@@ -104,6 +109,14 @@ type ProtocolManager struct {
 	knownTxs       *lru.Cache
 	knowOrderTxs   *lru.Cache
 	knowLendingTxs *lru.Cache
+
+	// S2PoS v2 BFT messages, bft is nil unless the chain switches to the v2 engine
+	bft            *S2PoS.S2PoS
+	chainHeadCh    chan core.ChainHeadEvent
+	chainHeadSub   event.Subscription
+	knownVotes     *lru.Cache
+	knownTimeouts  *lru.Cache
+	knownSyncInfos *lru.Cache
 }
 
 // NewProtocolManagerEx add order pool to protocol
@@ -123,6 +136,9 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	knownTxs, _ := lru.New(maxKnownTxs)
 	knowOrderTxs, _ := lru.New(maxKnownOrderTxs)
 	knowLendingTxs, _ := lru.New(maxKnownLendingTxs)
+	knownVotes, _ := lru.New(maxKnownVotes)
+	knownTimeouts, _ := lru.New(maxKnownTimeouts)
+	knownSyncInfos, _ := lru.New(maxKnownSyncInfos)
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:      networkID,
@@ -142,6 +158,12 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		lendingpool:    nil,
 		orderTxSub:     nil,
 		lendingTxSub:   nil,
+		knownVotes:     knownVotes,
+		knownTimeouts:  knownTimeouts,
+		knownSyncInfos: knownSyncInfos,
+	}
+	if c, ok := engine.(*S2PoS.S2PoS); ok && config.S2PoS != nil && config.S2PoS.V2ConsensusBlockNumber != nil {
+		manager.bft = c
 	}
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
@@ -269,6 +291,14 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go pm.minedBroadcastLoop()
 
+	// relay the S2PoS v2 BFT messages
+	if pm.bft != nil {
+		pm.chainHeadCh = make(chan core.ChainHeadEvent, chainHeadChanSize)
+		pm.chainHeadSub = pm.blockchain.SubscribeChainHeadEvent(pm.chainHeadCh)
+		go pm.bftBroadcastLoop()
+		go pm.bftChainHeadLoop()
+	}
+
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
@@ -285,6 +315,9 @@ func (pm *ProtocolManager) Stop() {
 		pm.lendingTxSub.Unsubscribe()
 	}
 	pm.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	if pm.chainHeadSub != nil {
+		pm.chainHeadSub.Unsubscribe() // quits bftChainHeadLoop
+	}
 
 	// Quit the sync loop.
 	// After this send has completed, no new peers will be accepted.
@@ -809,6 +842,48 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			pm.lendingpool.AddRemotes(txs)
 		}
 
+	case p.version >= eth63 && msg.Code == VoteMsg:
+		if pm.bft == nil {
+			break
+		}
+		var vote utils.Vote
+		if err := msg.Decode(&vote); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if vote.ProposedBlockInfo == nil || vote.ProposedBlockInfo.Number == nil {
+			return errResp(ErrDecode, "vote without proposed block")
+		}
+		p.MarkVote(vote.Hash())
+		if exist, _ := pm.knownVotes.ContainsOrAdd(vote.Hash(), true); !exist {
+			go pm.handleVote(&vote)
+		}
+
+	case p.version >= eth63 && msg.Code == TimeoutMsg:
+		if pm.bft == nil {
+			break
+		}
+		var timeout utils.Timeout
+		if err := msg.Decode(&timeout); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.MarkTimeout(timeout.Hash())
+		if exist, _ := pm.knownTimeouts.ContainsOrAdd(timeout.Hash(), true); !exist {
+			go pm.handleTimeout(&timeout)
+		}
+
+	case p.version >= eth63 && msg.Code == SyncInfoMsg:
+		if pm.bft == nil {
+			break
+		}
+		var syncInfo utils.SyncInfo
+		if err := msg.Decode(&syncInfo); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.MarkSyncInfo(syncInfo.Hash())
+		if exist, _ := pm.knownSyncInfos.ContainsOrAdd(syncInfo.Hash(), true); !exist {
+			go pm.handleSyncInfo(&syncInfo)
+		}
+
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
 	}
@@ -25,7 +25,7 @@ import (
 
 func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConfig *params.ChainConfig) {
 	// Hook scans for bad masternodes and decide to penalty them
-	adaptor.EngineV1.HookPenalty = func(chain consensus.ChainReader, blockNumberEpoc uint64) ([]common.Address, error) {
+	adaptor.EngineV1.HookPenalty = func(chain consensus.ChainReader, blockNumberEpoc uint64, parentHash common.Hash) ([]common.Address, error) {
 		canonicalState, err := bc.State()
 		if canonicalState == nil || err != nil {
 			log.Crit("Can't get state at head of canonical chain", "head number", bc.CurrentHeader().Number.Uint64(), "err", err)
@@ -36,6 +36,7 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 			start := time.Now()
 			prevHeader := chain.GetHeaderByNumber(prevEpoc)
 			penSigners := adaptor.GetMasternodes(chain, prevHeader)
+			checkedBlocks := uint64(0)
 			if len(penSigners) > 0 {
 				// Loop for each block to check missing sign.
 				for i := prevEpoc; i < blockNumberEpoc; i++ {
@@ -45,6 +46,7 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 						bhash := bheader.Hash()
 						block := chain.GetBlock(bhash, i)
 						if len(penSigners) > 0 {
+							checkedBlocks++
 							signedMasternodes, err := contracts.GetSignersFromContract(canonicalState, block)
 							if err != nil {
 								return nil, err
@@ -66,6 +68,11 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 					}
 				}
 			}
+			journal := newPenaltyJournal(blockNumberEpoc, epoch)
+			for _, addr := range penSigners {
+				journal.add(addr, utils.PenaltyMissedSigning, checkedBlocks, 0)
+			}
+			journal.store(adaptor, parentHash, penSigners)
 			log.Debug("Time Calculated HookPenalty ", "block", blockNumberEpoc, "time", common.PrettyDuration(time.Since(start)))
 			return penSigners, nil
 		}
@@ -109,17 +116,20 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 			// add list not miner to penalties
 			prevHeader := chain.GetHeaderByNumber(prevEpoc)
 			preMasternodes := adaptor.GetMasternodes(chain, prevHeader)
+			journal := newPenaltyJournal(header.Number.Uint64(), epoch)
 			penalties := []common.Address{}
 			for miner, total := range statMiners {
 				if total < common.MinimunMinerBlockPerEpoch {
 					log.Debug("Find a node not enough requirement create block", "addr", miner.Hex(), "total", total)
 					penalties = append(penalties, miner)
+					journal.add(miner, utils.PenaltyNotEnoughBlocks, 0, uint64(total))
 				}
 			}
 			for _, addr := range preMasternodes {
 				if _, exist := statMiners[addr]; !exist {
 					log.Debug("Find a node don't create block", "addr", addr.Hex())
 					penalties = append(penalties, addr)
+					journal.add(addr, utils.PenaltyNoBlock, 0, 0)
 				}
 			}
 
@@ -173,11 +183,17 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 				}
 			}
 
+			for _, addr := range penComebacks {
+				journal.add(addr, utils.PenaltyComebackMissedSigning, uint64(len(mapBlockHash)), uint64(statMiners[addr]))
+			}
+
 			log.Debug("Time Calculated HookPenaltyTIPSigning ", "block", header.Number, "hash", header.Hash().Hex(), "pen comeback nodes", len(penComebacks), "not enough miner", len(penalties), "time", common.PrettyDuration(time.Since(start)))
 			penalties = append(penalties, penComebacks...)
 			if chain.Config().IsTIPRandomize(header.Number) {
+				journal.store(adaptor, header.ParentHash, penalties)
 				return penalties, nil
 			}
+			journal.store(adaptor, header.ParentHash, penComebacks)
 			return penComebacks, nil
 		}
 		return []common.Address{}, nil
@@ -187,10 +203,13 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 	adaptor.EngineV1.HookValidator = func(header *types.Header, signers []common.Address) ([]byte, error) {
 		start := time.Now()
 		fmt.Println("HookValidator:::")
-		validators, err := getValidators(bc, signers)
+		validators, journal, err := getValidators(bc, header, signers)
 		if err != nil {
 			return []byte{}, err
 		}
+		if header.Number.Uint64()%common.EpocBlockRandomize == 0 {
+			journal.store(adaptor, header.ParentHash)
+		}
 		header.Validators = validators
 		log.Debug("Time Calculated HookValidator ", "block", header.Number.Uint64(), "time", common.PrettyDuration(time.Since(start)))
 		return validators, nil
@@ -202,14 +221,17 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 		if number > 0 && number%common.EpocBlockRandomize == 0 {
 			start := time.Now()
 			fmt.Println("HookVerifyMNs:::")
-			validators, err := getValidators(bc, signers)
+			validators, journal, err := getValidators(bc, header, signers)
 			log.Debug("Time Calculated HookVerifyMNs ", "block", header.Number.Uint64(), "time", common.PrettyDuration(time.Since(start)))
 			if err != nil {
 				return err
 			}
-			if !bytes.Equal(header.Validators, validators) {
+			// The stakes committed past TIPStakeWeightedProposer are verified by the engine
+			m2, _ := utils.SplitCheckpointValidators(header.Validators, len(signers))
+			if !bytes.Equal(m2, validators) {
 				return utils.ErrInvalidCheckpointValidators
 			}
+			journal.store(adaptor, header.ParentHash)
 		}
 		return nil
 	}
@@ -234,12 +256,13 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 			candidates         []utils.Masternode
 		)
 
-		stateDB, err := bc.StateAt(bc.GetBlockByHash(block).Root())
-		candidateAddresses = state.GetCandidates(stateDB)
-
+		gapBlock := bc.GetBlockByHash(block)
+		stateDB, err := bc.StateAt(gapBlock.Root())
 		if err != nil {
 			return nil, err
 		}
+		candidateAddresses = state.GetCandidates(stateDB)
+
 		for _, address := range candidateAddresses {
 			v, err := validator.GetCandidateCap(opts, address)
 			if err != nil {
@@ -253,8 +276,12 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 		sort.Slice(candidates, func(i, j int) bool {
 			return candidates[i].Stake.Cmp(candidates[j].Stake) >= 0
 		})
-		if len(candidates) > 150 {
-			candidates = candidates[:150]
+		maxMasternodes := 150
+		if bc.Config().IsTIPDynamicMasternodes(gapBlock.Number()) {
+			maxMasternodes = core.GetMaxMasternodes(bc.Config(), stateDB)
+		}
+		if len(candidates) > maxMasternodes {
+			candidates = candidates[:maxMasternodes]
 		}
 		result := []common.Address{}
 		for _, candidate := range candidates {
@@ -263,6 +290,19 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 		return result, nil
 	}
 
+	// HookGetMasternodesStakes returns the stakes of the masternodes in the state of a block
+	adaptor.EngineV1.HookGetMasternodesStakes = func(header *types.Header, masternodes []common.Address) ([]*big.Int, error) {
+		stateDB, err := bc.StateAt(header.Root)
+		if err != nil {
+			return nil, err
+		}
+		stakes := make([]*big.Int, len(masternodes))
+		for i, masternode := range masternodes {
+			stakes[i] = state.GetCandidateCap(stateDB, masternode)
+		}
+		return stakes, nil
+	}
+
 	// Hook calculates reward for masternodes
 	adaptor.EngineV1.HookReward = func(chain consensus.ChainReader, stateBlock *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{}) {
 		number := header.Number.Uint64()
@@ -299,7 +339,7 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 			voterResults := make(map[common.Address]interface{})
 			if len(signers) > 0 {
 				for signer, calcReward := range rewardSigners {
-					err, rewards := contracts.CalculateRewardForHolders(foundationWalletAddr, parentState, signer, calcReward, number)
+					err, rewards := contracts.CalculateRewardForHolders(chainConfig, foundationWalletAddr, parentState, signer, calcReward, number)
 					if err != nil {
 						log.Crit("Fail to calculate reward for holders.", "error", err)
 					}
@@ -318,43 +358,63 @@ func AttachConsensusV1Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConf
 	}
 }
 
-func getValidators(bc *core.BlockChain, masternodes []common.Address) ([]byte, error) {
-	if bc.Config().S2PoS == nil {
-		return nil, core.ErrNotS2PoS
+// getValidators assigns the M2 validators of the checkpoint header from the
+// randomizes the masternodes revealed, and journals their participation. Past
+// the TIPRandomizeFallback fork, the masternodes missing a randomize take part
+// with a fallback value derived from the previous checkpoint.
+func getValidators(bc *core.BlockChain, header *types.Header, masternodes []common.Address) ([]byte, *randomizeJournal, error) {
+	config := bc.Config()
+	if config.S2PoS == nil {
+		return nil, nil, core.ErrNotS2PoS
 	}
 	client, err := bc.GetClient()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	// Check m2 exists on chaindb.
 	// Get secrets and opening at epoc block checkpoint.
-
-	var candidates []int64
-	if err != nil {
-		return nil, err
+	var (
+		candidates []int64
+		journal    = newRandomizeJournal(header.Number.Uint64())
+		fallback   = config.IsTIPRandomizeFallback(header.Number)
+		seed       common.Hash
+	)
+	if fallback && header.Number.Uint64() >= config.S2PoS.Epoch {
+		// The previous checkpoint on the chain of the header commits to the
+		// randomness of the last epoch
+		prev := header
+		for i := uint64(0); i < config.S2PoS.Epoch; i++ {
+			if prev = bc.GetHeader(prev.ParentHash, prev.Number.Uint64()-1); prev == nil {
+				return nil, nil, consensus.ErrUnknownAncestor
+			}
+		}
+		seed = prev.Hash()
 	}
 	lenSigners := int64(len(masternodes))
 	if lenSigners > 0 {
-		fmt.Println("getValidators::")
 		for _, addr := range masternodes {
-			random, err := contracts.GetRandomizeFromContract(client, addr)
-			fmt.Println("GetRandomizeFromContract::", random)
-
+			reveal, err := contracts.GetRandomizeRevealFromContract(client, addr)
 			if err != nil {
-				return nil, err
+				if !fallback {
+					return nil, nil, err
+				}
+				// An opening that doesn't decrypt into a randomize counts as missing
+				log.Warn("Invalid randomize reveal", "number", header.Number, "masternode", addr, "err", err)
+				reveal.Valid = false
 			}
+			random := reveal.Random
+			if fallback && !reveal.Valid {
+				random = contracts.FallbackRandomize(seed, addr, config.S2PoS.Epoch)
+			}
+			journal.add(addr, reveal, fallback && !reveal.Valid)
 			candidates = append(candidates, random)
-			fmt.Println("candidates::", candidates)
-
 		}
 		// Get randomize m2 list.
 		m2, err := contracts.GenM2FromRandomize(candidates, lenSigners)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return contracts.BuildValidatorFromM2(m2), nil
+		return contracts.BuildValidatorFromM2(m2), journal, nil
 	}
-	fmt.Println("lenSigners::", lenSigners)
 
-	return nil, core.ErrNotFoundM1
+	return nil, nil, core.ErrNotFoundM1
 }
@@ -0,0 +1,71 @@
+package hooks
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core"
+	"github.com/FRECNET/log"
+	"github.com/FRECNET/params"
+)
+
+func AttachConsensusV2Hooks(adaptor *S2PoS.S2PoS, bc *core.BlockChain, chainConfig *params.ChainConfig) {
+	// Hook scans the previous epoch for masternodes which didn't create enough blocks
+	adaptor.EngineV2.HookPenalty = func(chain consensus.ChainReader, number *big.Int, parentHash common.Hash, candidates []common.Address) ([]common.Address, error) {
+		epoch := chain.Config().S2PoS.Epoch
+		if number.Uint64() < epoch {
+			return []common.Address{}, nil
+		}
+		start := time.Now()
+
+		// Count the blocks created by each masternode in the previous epoch
+		statMiners := make(map[common.Address]int)
+		parentNumber := number.Uint64() - 1
+		checkpointParent := parentHash
+		for i := uint64(0); i < epoch; i++ {
+			parentHeader := chain.GetHeader(parentHash, parentNumber)
+			if parentHeader == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			miner, err := adaptor.RecoverSigner(parentHeader)
+			if err != nil {
+				return nil, err
+			}
+			statMiners[miner]++
+			parentHash = parentHeader.ParentHash
+			parentNumber--
+		}
+
+		prevHeader := chain.GetHeaderByNumber(number.Uint64() - epoch)
+		preMasternodes := adaptor.GetMasternodes(chain, prevHeader)
+		journal := newPenaltyJournal(number.Uint64(), epoch)
+		penalties := []common.Address{}
+		for _, addr := range preMasternodes {
+			if statMiners[addr] >= common.MinimunMinerBlockPerEpoch {
+				continue
+			}
+			for _, candidate := range candidates {
+				if candidate == addr {
+					log.Debug("Find a node not enough requirement create block", "addr", addr.Hex(), "total", statMiners[addr])
+					penalties = append(penalties, addr)
+					if statMiners[addr] == 0 {
+						journal.add(addr, utils.PenaltyNoBlock, 0, 0)
+					} else {
+						journal.add(addr, utils.PenaltyNotEnoughBlocks, 0, uint64(statMiners[addr]))
+					}
+					break
+				}
+			}
+		}
+		journal.store(adaptor, checkpointParent, penalties)
+		log.Debug("Time Calculated HookPenalty ", "block", number, "time", common.PrettyDuration(time.Since(start)))
+		return penalties, nil
+	}
+
+	// Rewards are distributed the same way as in v1
+	adaptor.EngineV2.HookReward = adaptor.EngineV1.HookReward
+}
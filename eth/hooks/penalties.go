@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/log"
+)
+
+// penaltyJournal collects the reasons the masternodes are penalized at a
+// checkpoint block, the first reason found for a node is kept.
+type penaltyJournal struct {
+	number  uint64 // checkpoint block number
+	epoch   uint64 // epoch length
+	records map[common.Address]*utils.PenaltyRecord
+}
+
+func newPenaltyJournal(number uint64, epoch uint64) *penaltyJournal {
+	return &penaltyJournal{
+		number:  number,
+		epoch:   epoch,
+		records: make(map[common.Address]*utils.PenaltyRecord),
+	}
+}
+
+// add records a penalty reason for a masternode along with the blocks it
+// didn't sign and the blocks it created.
+func (j *penaltyJournal) add(addr common.Address, reason string, missedSigns uint64, blocksCreated uint64) {
+	if _, ok := j.records[addr]; ok {
+		return
+	}
+	j.records[addr] = &utils.PenaltyRecord{
+		Address:        addr,
+		Reason:         reason,
+		MissedSigns:    missedSigns,
+		BlocksCreated:  blocksCreated,
+		BlocksRequired: common.MinimunMinerBlockPerEpoch,
+		ComebackEpoch:  j.number/j.epoch + common.LimitPenaltyEpoch + 1,
+	}
+}
+
+// store persists the records of the nodes actually penalized, in the order
+// they are written into the checkpoint header.
+func (j *penaltyJournal) store(adaptor *S2PoS.S2PoS, parentHash common.Hash, penalties []common.Address) {
+	records := make([]*utils.PenaltyRecord, 0, len(penalties))
+	for _, addr := range penalties {
+		if record, ok := j.records[addr]; ok {
+			records = append(records, record)
+		}
+	}
+	if err := adaptor.StorePenaltyJournal(j.number, parentHash, records); err != nil {
+		log.Error("Failed to store penalty journal", "number", j.number, "parentHash", parentHash, "err", err)
+	}
+}
@@ -0,0 +1,63 @@
+package hooks
+
+import (
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/contracts"
+	"github.com/FRECNET/log"
+	"github.com/FRECNET/metrics"
+)
+
+var (
+	randomizeRevealedGauge = metrics.NewRegisteredGauge("S2PoS/randomize/revealed", nil) // masternodes that revealed a randomize at the last checkpoint
+	randomizeMissingGauge  = metrics.NewRegisteredGauge("S2PoS/randomize/missing", nil)  // masternodes that missed a phase of the commit-reveal
+	randomizeFallbackGauge = metrics.NewRegisteredGauge("S2PoS/randomize/fallback", nil) // missing randomizes replaced by a fallback value
+)
+
+// randomizeJournal collects the participation of the masternodes in the
+// commit-reveal randomization of the M2 assignment at a checkpoint block.
+type randomizeJournal struct {
+	number  uint64 // checkpoint block number
+	records []*utils.RandomizeRecord
+}
+
+func newRandomizeJournal(number uint64) *randomizeJournal {
+	return &randomizeJournal{number: number}
+}
+
+// add records the reveal of a masternode and whether a fallback value was
+// used in place of its randomize.
+func (j *randomizeJournal) add(addr common.Address, reveal *contracts.RandomizeReveal, fallback bool) {
+	j.records = append(j.records, &utils.RandomizeRecord{
+		Address:  addr,
+		Secret:   reveal.Secret,
+		Opening:  reveal.Opening,
+		Revealed: reveal.Valid,
+		Fallback: fallback,
+	})
+}
+
+// store persists the journal and reports the randomness quality of the
+// checkpoint to the metrics.
+func (j *randomizeJournal) store(adaptor *S2PoS.S2PoS, parentHash common.Hash) {
+	var revealed, fallbacks int64
+	for _, record := range j.records {
+		if record.Revealed {
+			revealed++
+		}
+		if record.Fallback {
+			fallbacks++
+		}
+	}
+	randomizeRevealedGauge.Update(revealed)
+	randomizeMissingGauge.Update(int64(len(j.records)) - revealed)
+	randomizeFallbackGauge.Update(fallbacks)
+
+	if missing := int64(len(j.records)) - revealed; missing > 0 {
+		log.Warn("Masternodes missed the randomize reveal", "number", j.number, "missing", missing, "fallbacks", fallbacks)
+	}
+	if err := adaptor.StoreRandomizeJournal(j.number, parentHash, j.records); err != nil {
+		log.Error("Failed to store randomize journal", "number", j.number, "parentHash", parentHash, "err", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS/utils"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/p2p"
 	"github.com/FRECNET/rlp"
@@ -41,6 +42,9 @@ const (
 	maxKnownOrderTxs   = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownLendingTxs = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks     = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+	maxKnownVotes      = 32768 // Maximum vote hashes to keep in the known list (prevent DOS)
+	maxKnownTimeouts   = 32768 // Maximum timeout hashes to keep in the known list (prevent DOS)
+	maxKnownSyncInfos  = 1024  // Maximum sync info hashes to keep in the known list (prevent DOS)
 	handshakeTimeout   = 5 * time.Second
 )
 
@@ -70,6 +74,9 @@ type peer struct {
 	knownBlocks     mapset.Set // Set of block hashes known to be known by this peer
 	knownOrderTxs   mapset.Set // Set of order transaction hashes known to be known by this peer
 	knownLendingTxs mapset.Set // Set of lending transaction hashes known to be known by this peer
+	knownVotes      mapset.Set // Set of BFT vote hashes known to be known by this peer
+	knownTimeouts   mapset.Set // Set of BFT timeout hashes known to be known by this peer
+	knownSyncInfos  mapset.Set // Set of BFT sync info hashes known to be known by this peer
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -84,6 +91,9 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		knownBlocks:     mapset.NewSet(),
 		knownOrderTxs:   mapset.NewSet(),
 		knownLendingTxs: mapset.NewSet(),
+		knownVotes:      mapset.NewSet(),
+		knownTimeouts:   mapset.NewSet(),
+		knownSyncInfos:  mapset.NewSet(),
 	}
 }
 
@@ -195,6 +205,66 @@ func (p *peer) SendLendingTransactions(txs types.LendingTransactions) error {
 	return p2p.Send(p.rw, LendingTxMsg, txs)
 }
 
+// MarkVote marks a vote as known for the peer, ensuring that it will never be
+// propagated to this particular peer.
+func (p *peer) MarkVote(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known vote hash
+	for p.knownVotes.Cardinality() >= maxKnownVotes {
+		p.knownVotes.Pop()
+	}
+	p.knownVotes.Add(hash)
+}
+
+// MarkTimeout marks a timeout as known for the peer, ensuring that it will never
+// be propagated to this particular peer.
+func (p *peer) MarkTimeout(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known timeout hash
+	for p.knownTimeouts.Cardinality() >= maxKnownTimeouts {
+		p.knownTimeouts.Pop()
+	}
+	p.knownTimeouts.Add(hash)
+}
+
+// MarkSyncInfo marks a sync info as known for the peer, ensuring that it will
+// never be propagated to this particular peer.
+func (p *peer) MarkSyncInfo(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known sync info hash
+	for p.knownSyncInfos.Cardinality() >= maxKnownSyncInfos {
+		p.knownSyncInfos.Pop()
+	}
+	p.knownSyncInfos.Add(hash)
+}
+
+// SendVote propagates a BFT vote to a remote peer.
+func (p *peer) SendVote(vote *utils.Vote) error {
+	p.MarkVote(vote.Hash())
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, VoteMsg, vote)
+	} else {
+		return p2p.Send(p.rw, VoteMsg, vote)
+	}
+}
+
+// SendTimeout propagates a BFT timeout to a remote peer.
+func (p *peer) SendTimeout(timeout *utils.Timeout) error {
+	p.MarkTimeout(timeout.Hash())
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, TimeoutMsg, timeout)
+	} else {
+		return p2p.Send(p.rw, TimeoutMsg, timeout)
+	}
+}
+
+// SendSyncInfo propagates the highest BFT certificates to a remote peer.
+func (p *peer) SendSyncInfo(syncInfo *utils.SyncInfo) error {
+	p.MarkSyncInfo(syncInfo.Hash())
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, SyncInfoMsg, syncInfo)
+	} else {
+		return p2p.Send(p.rw, SyncInfoMsg, syncInfo)
+	}
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -535,6 +605,51 @@ func (ps *peerSet) LendingPeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
+// PeersWithoutVote retrieves a list of peers that do not have a given vote in
+// their set of known hashes.
+func (ps *peerSet) PeersWithoutVote(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownVotes.Contains(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// PeersWithoutTimeout retrieves a list of peers that do not have a given timeout
+// in their set of known hashes.
+func (ps *peerSet) PeersWithoutTimeout(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownTimeouts.Contains(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// PeersWithoutSyncInfo retrieves a list of peers that do not have a given sync
+// info in their set of known hashes.
+func (ps *peerSet) PeersWithoutSyncInfo(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownSyncInfos.Contains(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // BestPeer retrieves the known peer with the currently highest total difficulty.
 func (ps *peerSet) BestPeer() *peer {
 	ps.lock.RLock()
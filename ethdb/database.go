@@ -133,7 +133,9 @@ type Database interface {
 	io.Closer
 }
 
-// FRExDatabase interface
+// FRExDatabase interface, the key-value store of the trading and lending state
+// tries along with the FREx objects
 type FRExDatabase interface {
+	KeyValueStore
 	GetObject(hash common.Hash, val interface{}) (interface{}, error)
 }
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/FRECNET/FREx/tradingstate"
+	"github.com/FRECNET/FRExlending"
 	"github.com/FRECNET/FRExlending/lendingstate"
 	"github.com/FRECNET/accounts"
 	"github.com/FRECNET/accounts/abi"
@@ -35,6 +36,7 @@ import (
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/common/hexutil"
 	"github.com/FRECNET/common/math"
+	"github.com/FRECNET/consensus"
 	"github.com/FRECNET/consensus/S2PoS"
 	"github.com/FRECNET/consensus/S2PoS/utils"
 	"github.com/FRECNET/consensus/ethash"
@@ -44,6 +46,7 @@ import (
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/core/vm"
 	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/light"
 	"github.com/FRECNET/log"
 	"github.com/FRECNET/p2p"
 	"github.com/FRECNET/params"
@@ -516,6 +519,35 @@ func (s *PublicBlockChainAPI) GetRewardByHash(hash common.Hash) map[string]map[s
 	return s.b.GetRewardByHash(hash)
 }
 
+// GetRewardsByAddress returns the rewards received by an address at the reward
+// checkpoints of the epochs between fromEpoch and toEpoch, both included.
+func (s *PublicBlockChainAPI) GetRewardsByAddress(ctx context.Context, address common.Address, fromEpoch, toEpoch rpc.EpochNumber) ([]map[string]interface{}, error) {
+	if s.b.ChainConfig().S2PoS == nil {
+		return nil, core.ErrNotS2PoS
+	}
+	latest := rpc.EpochNumber(s.b.CurrentBlock().NumberU64() / s.b.ChainConfig().S2PoS.Epoch)
+	if fromEpoch == rpc.LatestEpochNumber {
+		fromEpoch = latest
+	}
+	if toEpoch == rpc.LatestEpochNumber {
+		toEpoch = latest
+	}
+	if fromEpoch > toEpoch {
+		return nil, fmt.Errorf("invalid epoch range: from %d > to %d", fromEpoch, toEpoch)
+	}
+	entries := s.b.GetRewardsByAddress(address, uint64(fromEpoch), uint64(toEpoch))
+	rewards := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		rewards = append(rewards, map[string]interface{}{
+			"epoch":       hexutil.Uint64(entry.Epoch),
+			"blockNumber": hexutil.Uint64(entry.BlockNumber),
+			"blockHash":   entry.BlockHash,
+			"reward":      (*hexutil.Big)(entry.Amount),
+		})
+	}
+	return rewards, nil
+}
+
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
@@ -630,6 +662,72 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// BlacklistEntry is an address of the blacklist, with the number of the block
+// from which it is blacklisted.
+type BlacklistEntry struct {
+	Address        common.Address `json:"address"`
+	EffectiveBlock hexutil.Uint64 `json:"effectiveBlock"`
+}
+
+// GetBlacklist returns the addresses blacklisted on top of the state of the
+// given block, sorted by the block from which they are blacklisted.
+func (s *PublicBlockChainAPI) GetBlacklist(ctx context.Context, blockNr rpc.BlockNumber) ([]BlacklistEntry, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	blacklist := core.GetBlacklist(s.b.ChainConfig(), header.Number, header.Root, state)
+	entries := make([]BlacklistEntry, 0, len(blacklist))
+	for addr, number := range blacklist {
+		entries = append(entries, BlacklistEntry{Address: addr, EffectiveBlock: hexutil.Uint64(number)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].EffectiveBlock != entries[j].EffectiveBlock {
+			return entries[i].EffectiveBlock < entries[j].EffectiveBlock
+		}
+		return bytes.Compare(entries[i].Address[:], entries[j].Address[:]) < 0
+	})
+	return entries, state.Error()
+}
+
+// EpochTransition is the outcome of the verification of an epoch proof: the
+// checkpoint and the masternode set of the epoch it starts, with its trie root.
+type EpochTransition struct {
+	Number      hexutil.Uint64   `json:"number"`
+	Hash        common.Hash      `json:"hash"`
+	Masternodes []common.Address `json:"masternodes"`
+	Root        common.Hash      `json:"masternodesRoot"`
+}
+
+// GetEpochProof returns the RLP encoded epoch proof of the checkpoint with the
+// given number, to be checked with VerifyEpochProof or an external verifier.
+func (s *PublicBlockChainAPI) GetEpochProof(ctx context.Context, number hexutil.Uint64) (hexutil.Bytes, error) {
+	proof, err := s.b.GetEpochProof(ctx, uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(proof)
+}
+
+// VerifyEpochProof checks an RLP encoded epoch proof against the masternode set
+// trusted for the previous epoch, and returns the masternode set it moves to.
+func (s *PublicBlockChainAPI) VerifyEpochProof(ctx context.Context, encoded hexutil.Bytes, masternodes []common.Address) (*EpochTransition, error) {
+	proof := new(light.EpochProof)
+	if err := rlp.DecodeBytes(encoded, proof); err != nil {
+		return nil, err
+	}
+	next, root, err := light.VerifyEpochProof(s.b.ChainConfig(), proof, masternodes)
+	if err != nil {
+		return nil, err
+	}
+	return &EpochTransition{
+		Number:      hexutil.Uint64(proof.Header.Number.Uint64()),
+		Hash:        proof.Header.Hash(),
+		Masternodes: next,
+		Root:        root,
+	}, nil
+}
+
 func (s *PublicBlockChainAPI) GetBlockSignersByHash(ctx context.Context, blockHash common.Hash) ([]common.Address, error) {
 	block, err := s.b.GetBlock(ctx, blockHash)
 	if err != nil || block == nil {
@@ -661,6 +759,9 @@ func (s *PublicBlockChainAPI) GetBlockFinalityByHash(ctx context.Context, blockH
 	if err != nil || block == nil {
 		return uint(0), err
 	}
+	if finality, ok := s.committedFinalityOfBlock(ctx, block); ok {
+		return finality, nil
+	}
 	masternodes, err := s.GetMasternodes(ctx, block)
 	if err != nil || len(masternodes) == 0 {
 		log.Error("Failed to get masternodes", "err", err, "len(masternodes)", len(masternodes))
@@ -674,6 +775,9 @@ func (s *PublicBlockChainAPI) GetBlockFinalityByNumber(ctx context.Context, bloc
 	if err != nil || block == nil {
 		return uint(0), err
 	}
+	if finality, ok := s.committedFinalityOfBlock(ctx, block); ok {
+		return finality, nil
+	}
 	masternodes, err := s.GetMasternodes(ctx, block)
 	if err != nil || len(masternodes) == 0 {
 		log.Error("Failed to get masternodes", "err", err, "len(masternodes)", len(masternodes))
@@ -706,6 +810,21 @@ func (s *PublicBlockChainAPI) GetMasternodes(ctx context.Context, b *types.Block
 	return masternodes, nil
 }
 
+// dynamicMaxMasternodes returns the size of the masternode set of a checkpoint
+// from the dynamic masternodes hardfork on, which the consensus reads in the
+// state of the gap block the masternodes are picked at.
+func (s *PublicBlockChainAPI) dynamicMaxMasternodes(ctx context.Context, checkpointNumber rpc.BlockNumber) int {
+	gapNumber := checkpointNumber
+	if gap := rpc.BlockNumber(s.b.ChainConfig().S2PoS.Gap); gapNumber >= gap {
+		gapNumber -= gap
+	}
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, gapNumber)
+	if err != nil {
+		statedb = nil
+	}
+	return core.GetMaxMasternodes(s.b.ChainConfig(), statedb)
+}
+
 // GetCandidateStatus returns status of the given candidate at a specified epochNumber
 func (s *PublicBlockChainAPI) GetCandidateStatus(ctx context.Context, coinbaseAddress common.Address, epoch rpc.EpochNumber) (map[string]interface{}, error) {
 	var (
@@ -766,7 +885,9 @@ func (s *PublicBlockChainAPI) GetCandidateStatus(ctx context.Context, coinbaseAd
 		return result, err
 	}
 	var maxMasternodes int
-	if s.b.ChainConfig().IsTIPIncreaseMasternodes(block.Number()) {
+	if s.b.ChainConfig().IsTIPDynamicMasternodes(block.Number()) {
+		maxMasternodes = s.dynamicMaxMasternodes(ctx, checkpointNumber)
+	} else if s.b.ChainConfig().IsTIPIncreaseMasternodes(block.Number()) {
 		maxMasternodes = common.MaxMasternodesV2
 	} else {
 		maxMasternodes = common.MaxMasternodes
@@ -940,9 +1061,13 @@ func (s *PublicBlockChainAPI) GetCandidates(ctx context.Context, epoch rpc.Epoch
 	}
 	penaltyList = common.ExtractAddressFromBytes(penalties)
 
+	maxMasternodes := common.MaxMasternodes
+	if s.b.ChainConfig().IsTIPDynamicMasternodes(block.Number()) {
+		maxMasternodes = s.dynamicMaxMasternodes(ctx, checkpointNumber)
+	}
 	var topCandidates []utils.Masternode
-	if len(candidates) > common.MaxMasternodes {
-		topCandidates = candidates[:common.MaxMasternodes]
+	if len(candidates) > maxMasternodes {
+		topCandidates = candidates[:maxMasternodes]
 	} else {
 		topCandidates = candidates
 	}
@@ -1388,6 +1513,23 @@ func (s *PublicBlockChainAPI) findNearestSignedBlock(ctx context.Context, b *typ
 	return nil
 }
 
+// committedFinalityOfBlock returns the finality of blocks the consensus engine
+// has a definite answer for: blocks up to the finalized one are fully final, and
+// v2 blocks above it are not final at all. ok is false for the v1 blocks whose
+// finality is still estimated from the block signers.
+func (s *PublicBlockChainAPI) committedFinalityOfBlock(ctx context.Context, b *types.Block) (uint, bool) {
+	if finalized, _ := s.b.HeaderByNumber(ctx, rpc.FinalizedBlockNumber); finalized != nil && b.NumberU64() <= finalized.Number.Uint64() {
+		if s.b.AreTwoBlockSamePath(finalized.Hash(), b.Hash()) {
+			return 100, true
+		}
+		return 0, true
+	}
+	if engine, ok := s.b.GetEngine().(*S2PoS.S2PoS); ok && engine.IsV2Block(b.Number()) {
+		return 0, true
+	}
+	return 0, false
+}
+
 /*
 findFinalityOfBlock return finality of a block
 Use blocksHashCache for to keep track - refer core/blockchain.go for more detail
@@ -1994,6 +2136,11 @@ func (s *PublicFREXTransactionPoolAPI) GetOrderPoolContent(ctx context.Context)
 				Type:            tx.Type(),
 				Hash:            tx.OrderHash(),
 				OrderID:         tx.OrderID(),
+				TriggerPrice:    tx.TriggerPrice(),
+				TimeInForce:     tx.TimeInForce(),
+				ExpireAt:        tx.ExpireAt(),
+				SelfTradeMode:   tx.SelfTradeMode(),
+				Batch:           tx.Batch(),
 				Signature: &tradingstate.Signature{
 					V: byte(V.Uint64()),
 					R: common.BigToHash(R),
@@ -2020,6 +2167,11 @@ func (s *PublicFREXTransactionPoolAPI) GetOrderPoolContent(ctx context.Context)
 				Type:            tx.Type(),
 				Hash:            tx.OrderHash(),
 				OrderID:         tx.OrderID(),
+				TriggerPrice:    tx.TriggerPrice(),
+				TimeInForce:     tx.TimeInForce(),
+				ExpireAt:        tx.ExpireAt(),
+				SelfTradeMode:   tx.SelfTradeMode(),
+				Batch:           tx.Batch(),
 				Signature: &tradingstate.Signature{
 					V: byte(V.Uint64()),
 					R: common.BigToHash(R),
@@ -2058,11 +2210,18 @@ type OrderMsg struct {
 	Side            string         `json:"side,omitempty"`
 	Type            string         `json:"type,omitempty"`
 	OrderID         hexutil.Uint64 `json:"orderid,omitempty"`
+	TriggerPrice    *hexutil.Big   `json:"triggerPrice,omitempty"`
+	TimeInForce     string         `json:"timeInForce,omitempty"`
+	ExpireAt        hexutil.Uint64 `json:"expireAt,omitempty"`
+	SelfTradeMode   string         `json:"selfTradeMode,omitempty"`
 	// Signature values
 	V hexutil.Big `json:"v" gencodec:"required"`
 	R hexutil.Big `json:"r" gencodec:"required"`
 	S hexutil.Big `json:"s" gencodec:"required"`
 
+	// Operations of a batch order
+	Batch types.BatchOrderOps `json:"batch,omitempty"`
+
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash" rlp:"-"`
 }
@@ -2108,6 +2267,18 @@ type InterestVolume struct {
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicFREXTransactionPoolAPI) SendOrder(ctx context.Context, msg OrderMsg) (common.Hash, error) {
 	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	if msg.TriggerPrice != nil {
+		tx.SetTriggerPrice(msg.TriggerPrice.ToInt())
+	}
+	if msg.TimeInForce != "" {
+		tx.SetTimeInForce(msg.TimeInForce, uint64(msg.ExpireAt))
+	}
+	if msg.SelfTradeMode != "" {
+		tx.SetSelfTradeMode(msg.SelfTradeMode)
+	}
+	if len(msg.Batch) > 0 {
+		tx.SetBatch(msg.Batch)
+	}
 	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
 	return submitOrderTransaction(ctx, s.b, tx)
 }
@@ -2130,22 +2301,65 @@ func (s *PublicFREXTransactionPoolAPI) GetOrderCount(ctx context.Context, addr c
 	return (*hexutil.Uint64)(&nonce), err
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToken, quoteToken common.Address) (PriceVolume, error) {
-
-	result := PriceVolume{}
-	block := s.b.CurrentBlock()
+// stateBlock returns the block the order book queries are served from, the
+// current block if no block is given.
+func (s *PublicFREXTransactionPoolAPI) stateBlock(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (*types.Block, error) {
+	if blockNrOrHash == nil {
+		block := s.b.CurrentBlock()
+		if block == nil {
+			return nil, errors.New("Current block not found")
+		}
+		return block, nil
+	}
+	block, err := s.b.BlockByNumberOrHash(ctx, *blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
 	if block == nil {
-		return result, errors.New("Current block not found")
+		return nil, errors.New("Block not found")
+	}
+	return block, nil
+}
+
+// tradingState returns the trading state at the given block, the trading
+// root is resolved from the block the same way the block was processed.
+func (s *PublicFREXTransactionPoolAPI) tradingState(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (*tradingstate.TradingStateDB, error) {
+	block, err := s.stateBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	FRExService := s.b.FRExService()
 	if FRExService == nil {
-		return result, errors.New("FREX service not found")
+		return nil, errors.New("FREX service not found")
 	}
 	author, err := s.b.GetEngine().Author(block.Header())
 	if err != nil {
-		return result, err
+		return nil, err
 	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+	return FRExService.GetTradingState(block, author)
+}
+
+// lendingState returns the lending state at the given block.
+func (s *PublicFREXTransactionPoolAPI) lendingState(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (*lendingstate.LendingStateDB, error) {
+	block, err := s.stateBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("FREX Lending service not found")
+	}
+	author, err := s.b.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	return lendingService.GetLendingState(block, author)
+}
+
+func (s *PublicFREXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (PriceVolume, error) {
+
+	result := PriceVolume{}
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return result, err
 	}
@@ -2156,21 +2370,9 @@ func (s *PublicFREXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToken
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToken, quoteToken common.Address) (PriceVolume, error) {
+func (s *PublicFREXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (PriceVolume, error) {
 	result := PriceVolume{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return result, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return result, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return result, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return result, err
 	}
@@ -2181,20 +2383,8 @@ func (s *PublicFREXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToken
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBidTree(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]tradingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetBidTree(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]tradingstate.DumpOrderList, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2205,20 +2395,8 @@ func (s *PublicFREXTransactionPoolAPI) GetBidTree(ctx context.Context, baseToken
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetPrice(ctx context.Context, baseToken, quoteToken common.Address) (*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetPrice(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*big.Int, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2229,20 +2407,8 @@ func (s *PublicFREXTransactionPoolAPI) GetPrice(ctx context.Context, baseToken,
 	return price, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLastEpochPrice(ctx context.Context, baseToken, quoteToken common.Address) (*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLastEpochPrice(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*big.Int, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2253,20 +2419,8 @@ func (s *PublicFREXTransactionPoolAPI) GetLastEpochPrice(ctx context.Context, ba
 	return price, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetCurrentEpochPrice(ctx context.Context, baseToken, quoteToken common.Address) (*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetCurrentEpochPrice(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*big.Int, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2277,44 +2431,65 @@ func (s *PublicFREXTransactionPoolAPI) GetCurrentEpochPrice(ctx context.Context,
 	return price, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetAskTree(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]tradingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
+// GetCollateralPrice returns the collateral price of a lending pair given by
+// the lending price oracle on top of the given block, with the quote of each
+// price source.
+func (s *PublicFREXTransactionPoolAPI) GetCollateralPrice(ctx context.Context, collateralToken, lendingToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*FRExlending.OraclePrice, error) {
+	block, err := s.stateBlock(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("FREX Lending service not found")
+	}
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(block.NumberU64()))
 	if err != nil {
 		return nil, err
 	}
-	result, err := FRExState.DumpAskTrie(tradingstate.GetTradingOrderBookHash(baseToken, quoteToken))
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
+	return lendingService.GetOraclePrice(block.Header(), &backendChainContext{s.b}, statedb, FRExState, collateralToken, lendingToken)
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetOrderById(ctx context.Context, baseToken, quoteToken common.Address, orderId uint64) (interface{}, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
+// backendChainContext serves the chain context the FREX services need from
+// the API backend.
+type backendChainContext struct {
+	b Backend
+}
+
+func (c *backendChainContext) Engine() consensus.Engine {
+	return c.b.GetEngine()
+}
+
+func (c *backendChainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return core.GetHeader(c.b.ChainDb(), hash, number)
+}
+
+func (c *backendChainContext) CurrentHeader() *types.Header {
+	return c.b.CurrentBlock().Header()
+}
+
+func (c *backendChainContext) Config() *params.ChainConfig {
+	return c.b.ChainConfig()
+}
+
+func (s *PublicFREXTransactionPoolAPI) GetAskTree(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]tradingstate.DumpOrderList, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
-	author, err := s.b.GetEngine().Author(block.Header())
+	result, err := FRExState.DumpAskTrie(tradingstate.GetTradingOrderBookHash(baseToken, quoteToken))
 	if err != nil {
 		return nil, err
 	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+	return result, nil
+}
+
+func (s *PublicFREXTransactionPoolAPI) GetOrderById(ctx context.Context, baseToken, quoteToken common.Address, orderId uint64, blockNrOrHash *rpc.BlockNumberOrHash) (interface{}, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2326,20 +2501,8 @@ func (s *PublicFREXTransactionPoolAPI) GetOrderById(ctx context.Context, baseTok
 	return orderitem, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetTradingOrderBookInfo(ctx context.Context, baseToken, quoteToken common.Address) (*tradingstate.DumpOrderBookInfo, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetTradingOrderBookInfo(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*tradingstate.DumpOrderBookInfo, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2350,20 +2513,8 @@ func (s *PublicFREXTransactionPoolAPI) GetTradingOrderBookInfo(ctx context.Conte
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLiquidationPriceTree(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]tradingstate.DumpLendingBook, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLiquidationPriceTree(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]tradingstate.DumpLendingBook, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2374,20 +2525,8 @@ func (s *PublicFREXTransactionPoolAPI) GetLiquidationPriceTree(ctx context.Conte
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetInvestingTree(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]lendingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetInvestingTree(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]lendingstate.DumpOrderList, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2398,20 +2537,8 @@ func (s *PublicFREXTransactionPoolAPI) GetInvestingTree(ctx context.Context, len
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBorrowingTree(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]lendingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetBorrowingTree(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]lendingstate.DumpOrderList, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2422,20 +2549,8 @@ func (s *PublicFREXTransactionPoolAPI) GetBorrowingTree(ctx context.Context, len
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLendingOrderBookInfo(tx context.Context, lendingToken common.Address, term uint64) (*lendingstate.DumpOrderBookInfo, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLendingOrderBookInfo(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (*lendingstate.DumpOrderBookInfo, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2446,20 +2561,8 @@ func (s *PublicFREXTransactionPoolAPI) GetLendingOrderBookInfo(tx context.Contex
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) getLendingOrderTree(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]lendingstate.LendingItem, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) getLendingOrderTree(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]lendingstate.LendingItem, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2470,20 +2573,8 @@ func (s *PublicFREXTransactionPoolAPI) getLendingOrderTree(ctx context.Context,
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLendingTradeTree(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]lendingstate.LendingTrade, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLendingTradeTree(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]lendingstate.LendingTrade, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2494,20 +2585,8 @@ func (s *PublicFREXTransactionPoolAPI) GetLendingTradeTree(ctx context.Context,
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLiquidationTimeTree(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]lendingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLiquidationTimeTree(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]lendingstate.DumpOrderList, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2518,20 +2597,8 @@ func (s *PublicFREXTransactionPoolAPI) GetLiquidationTimeTree(ctx context.Contex
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLendingOrderCount(ctx context.Context, addr common.Address) (*hexutil.Uint64, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetLendingOrderCount(ctx context.Context, addr common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2539,21 +2606,9 @@ func (s *PublicFREXTransactionPoolAPI) GetLendingOrderCount(ctx context.Context,
 	return (*hexutil.Uint64)(&nonce), err
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBestInvesting(ctx context.Context, lendingToken common.Address, term uint64) (InterestVolume, error) {
+func (s *PublicFREXTransactionPoolAPI) GetBestInvesting(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (InterestVolume, error) {
 	result := InterestVolume{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return result, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return result, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return result, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return result, err
 	}
@@ -2561,21 +2616,9 @@ func (s *PublicFREXTransactionPoolAPI) GetBestInvesting(ctx context.Context, len
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBestBorrowing(ctx context.Context, lendingToken common.Address, term uint64) (InterestVolume, error) {
+func (s *PublicFREXTransactionPoolAPI) GetBestBorrowing(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (InterestVolume, error) {
 	result := InterestVolume{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return result, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return result, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return result, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return result, err
 	}
@@ -2583,20 +2626,8 @@ func (s *PublicFREXTransactionPoolAPI) GetBestBorrowing(ctx context.Context, len
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBids(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetBids(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]*big.Int, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2607,20 +2638,8 @@ func (s *PublicFREXTransactionPoolAPI) GetBids(ctx context.Context, baseToken, q
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetAsks(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	FRExService := s.b.FRExService()
-	if FRExService == nil {
-		return nil, errors.New("FREX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	FRExState, err := FRExService.GetTradingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetAsks(ctx context.Context, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]*big.Int, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2631,20 +2650,8 @@ func (s *PublicFREXTransactionPoolAPI) GetAsks(ctx context.Context, baseToken, q
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetInvests(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetInvests(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]*big.Int, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2655,20 +2662,8 @@ func (s *PublicFREXTransactionPoolAPI) GetInvests(ctx context.Context, lendingTo
 	return result, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetBorrows(ctx context.Context, lendingToken common.Address, term uint64) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return nil, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+func (s *PublicFREXTransactionPoolAPI) GetBorrows(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash *rpc.BlockNumberOrHash) (map[*big.Int]*big.Int, error) {
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -2712,21 +2707,9 @@ func (s *PublicFREXTransactionPoolAPI) GetLiquidatedTradesByTxHash(ctx context.C
 	return finalizedResult, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLendingOrderById(ctx context.Context, lendingToken common.Address, term uint64, orderId uint64) (lendingstate.LendingItem, error) {
+func (s *PublicFREXTransactionPoolAPI) GetLendingOrderById(ctx context.Context, lendingToken common.Address, term uint64, orderId uint64, blockNrOrHash *rpc.BlockNumberOrHash) (lendingstate.LendingItem, error) {
 	lendingItem := lendingstate.LendingItem{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return lendingItem, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return lendingItem, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return lendingItem, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return lendingItem, err
 	}
@@ -2739,21 +2722,9 @@ func (s *PublicFREXTransactionPoolAPI) GetLendingOrderById(ctx context.Context,
 	return lendingItem, nil
 }
 
-func (s *PublicFREXTransactionPoolAPI) GetLendingTradeById(ctx context.Context, lendingToken common.Address, term uint64, tradeId uint64) (lendingstate.LendingTrade, error) {
+func (s *PublicFREXTransactionPoolAPI) GetLendingTradeById(ctx context.Context, lendingToken common.Address, term uint64, tradeId uint64, blockNrOrHash *rpc.BlockNumberOrHash) (lendingstate.LendingTrade, error) {
 	lendingItem := lendingstate.LendingTrade{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return lendingItem, errors.New("Current block not found")
-	}
-	lendingService := s.b.LendingService()
-	if lendingService == nil {
-		return lendingItem, errors.New("FREX Lending service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return lendingItem, err
-	}
-	lendingState, err := lendingService.GetLendingState(block, author)
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
 	if err != nil {
 		return lendingItem, err
 	}
@@ -2766,6 +2737,178 @@ func (s *PublicFREXTransactionPoolAPI) GetLendingTradeById(ctx context.Context,
 	return lendingItem, nil
 }
 
+// UserPairOrders are the open orders of a user in the order book of a pair.
+type UserPairOrders struct {
+	BaseToken  common.Address           `json:"baseToken"`
+	QuoteToken common.Address           `json:"quoteToken"`
+	Orders     []tradingstate.OrderItem `json:"orders"`
+}
+
+// UserLendingTrade is an active lending trade of a user with its health
+// factor, the oracle price of the collateral in percents of the liquidation
+// price, nil without oracle price.
+type UserLendingTrade struct {
+	lendingstate.LendingTrade
+	OraclePrice  *big.Int `json:"oraclePrice"`
+	HealthFactor *big.Int `json:"healthFactor"`
+}
+
+// UserPortfolio is the trading and lending position of a user at a block,
+// with the token balances locked by the open orders and the collateral of
+// the borrowed trades.
+type UserPortfolio struct {
+	Orders         []UserPairOrders            `json:"orders"`
+	LendingItems   []lendingstate.LendingItem  `json:"lendingItems"`
+	LendingTrades  []UserLendingTrade          `json:"lendingTrades"`
+	LockedBalances map[common.Address]*big.Int `json:"lockedBalances"`
+}
+
+// GetUserOrders returns the open orders of a user in the order book of a
+// pair. Only the orders placed since the user index fork are indexed.
+func (s *PublicFREXTransactionPoolAPI) GetUserOrders(ctx context.Context, user, baseToken, quoteToken common.Address, blockNrOrHash *rpc.BlockNumberOrHash) ([]tradingstate.OrderItem, error) {
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	orders, err := FRExState.GetUserOrders(user, orderBook)
+	if err != nil {
+		return nil, err
+	}
+	result := orders[orderBook]
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].OrderID < result[j].OrderID
+	})
+	if result == nil {
+		result = []tradingstate.OrderItem{}
+	}
+	return result, nil
+}
+
+// GetUserPortfolio returns the open orders, lending items and active lending
+// trades of a user at the given block, with the balances they lock. Only the
+// orders and trades created since the user index fork are indexed.
+func (s *PublicFREXTransactionPoolAPI) GetUserPortfolio(ctx context.Context, user common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*UserPortfolio, error) {
+	block, err := s.stateBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	FRExService := s.b.FRExService()
+	if FRExService == nil {
+		return nil, errors.New("FREX service not found")
+	}
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("FREX Lending service not found")
+	}
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(block.NumberU64()))
+	if err != nil {
+		return nil, err
+	}
+	FRExState, err := s.tradingState(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := s.lendingState(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	chain := &backendChainContext{s.b}
+	result := &UserPortfolio{
+		Orders:         []UserPairOrders{},
+		LendingItems:   []lendingstate.LendingItem{},
+		LendingTrades:  []UserLendingTrade{},
+		LockedBalances: map[common.Address]*big.Int{},
+	}
+	lock := func(token common.Address, amount *big.Int) {
+		if amount == nil || amount.Sign() <= 0 {
+			return
+		}
+		if result.LockedBalances[token] == nil {
+			result.LockedBalances[token] = new(big.Int)
+		}
+		result.LockedBalances[token].Add(result.LockedBalances[token], amount)
+	}
+
+	orders, err := FRExState.GetUserOrders(user, common.Hash{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pairOrders := range orders {
+		sort.Slice(pairOrders, func(i, j int) bool {
+			return pairOrders[i].OrderID < pairOrders[j].OrderID
+		})
+		first := pairOrders[0]
+		result.Orders = append(result.Orders, UserPairOrders{BaseToken: first.BaseToken, QuoteToken: first.QuoteToken, Orders: pairOrders})
+		baseTokenDecimal, err := FRExService.GetTokenDecimal(chain, statedb, first.BaseToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range pairOrders {
+			if order.Side == tradingstate.Ask {
+				lock(order.BaseToken, order.Quantity)
+			} else {
+				lock(order.QuoteToken, new(big.Int).Div(new(big.Int).Mul(order.Quantity, order.Price), baseTokenDecimal))
+			}
+		}
+	}
+	sort.Slice(result.Orders, func(i, j int) bool {
+		return bytes.Compare(result.Orders[i].BaseToken.Bytes(), result.Orders[j].BaseToken.Bytes()) < 0 ||
+			result.Orders[i].BaseToken == result.Orders[j].BaseToken && bytes.Compare(result.Orders[i].QuoteToken.Bytes(), result.Orders[j].QuoteToken.Bytes()) < 0
+	})
+
+	lendingItems, err := lendingState.GetUserLendingItems(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, items := range lendingItems {
+		for _, item := range items {
+			result.LendingItems = append(result.LendingItems, item)
+			if item.Side == lendingstate.Investing {
+				lock(item.LendingToken, item.Quantity)
+			}
+		}
+	}
+	sort.Slice(result.LendingItems, func(i, j int) bool {
+		return result.LendingItems[i].LendingId < result.LendingItems[j].LendingId
+	})
+
+	lendingTrades, err := lendingState.GetUserLendingTrades(user)
+	if err != nil {
+		return nil, err
+	}
+	prices := map[common.Hash]*big.Int{}
+	for _, trades := range lendingTrades {
+		for _, trade := range trades {
+			pair := tradingstate.GetTradingOrderBookHash(trade.CollateralToken, trade.LendingToken)
+			price, ok := prices[pair]
+			if !ok {
+				oraclePrice, err := lendingService.GetOraclePrice(block.Header(), chain, statedb, FRExState, trade.CollateralToken, trade.LendingToken)
+				if err != nil {
+					return nil, err
+				}
+				price = oraclePrice.Price
+				prices[pair] = price
+			}
+			userTrade := UserLendingTrade{LendingTrade: trade}
+			if price != nil && price.Sign() > 0 {
+				userTrade.OraclePrice = price
+				if trade.LiquidationPrice != nil && trade.LiquidationPrice.Sign() > 0 {
+					userTrade.HealthFactor = new(big.Int).Div(new(big.Int).Mul(price, big.NewInt(100)), trade.LiquidationPrice)
+				}
+			}
+			result.LendingTrades = append(result.LendingTrades, userTrade)
+			if trade.Borrower == user {
+				lock(trade.CollateralToken, trade.CollateralLockedAmount)
+			}
+		}
+	}
+	sort.Slice(result.LendingTrades, func(i, j int) bool {
+		return result.LendingTrades[i].TradeId < result.LendingTrades[j].TradeId
+	})
+	return result, nil
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message).
 //
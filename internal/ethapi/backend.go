@@ -31,12 +31,14 @@ import (
 	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus"
 	"github.com/FRECNET/core"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/core/vm"
 	"github.com/FRECNET/eth/downloader"
 	"github.com/FRECNET/ethdb"
 	"github.com/FRECNET/event"
+	"github.com/FRECNET/light"
 	"github.com/FRECNET/params"
 	"github.com/FRECNET/rpc"
 )
@@ -58,6 +60,7 @@ type Backend interface {
 	SetHead(number uint64)
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
+	BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
@@ -87,6 +90,7 @@ type Backend interface {
 	GetIPCClient() (bind.ContractBackend, error)
 	GetEngine() consensus.Engine
 	GetRewardByHash(hash common.Hash) map[string]map[string]map[string]*big.Int
+	GetRewardsByAddress(address common.Address, fromEpoch, toEpoch uint64) []*rawdb.RewardLookupEntry
 
 	GetVotersRewards(common.Address) map[common.Address]*big.Int
 	GetVotersCap(checkpoint *big.Int, masterAddr common.Address, voters []common.Address) map[common.Address]*big.Int
@@ -95,6 +99,7 @@ type Backend interface {
 	GetBlocksHashCache(blockNr uint64) []common.Hash
 	AreTwoBlockSamePath(newBlock common.Hash, oldBlock common.Hash) bool
 	GetOrderNonce(address common.Hash) (uint64, error)
+	GetEpochProof(ctx context.Context, number uint64) (*light.EpochProof, error)
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
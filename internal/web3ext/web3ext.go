@@ -138,6 +138,16 @@ web3._extend({
 			call: 'S2PoS_getSignersAtHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getPenaltyReport',
+			call: 'S2PoS_getPenaltyReport',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRandomizeReport',
+			call: 'S2PoS_getRandomizeReport',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -472,6 +482,11 @@ web3._extend({
 			call: 'eth_getRewardByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getRewardsByAddress',
+			call: 'eth_getRewardsByAddress',
+			params: 3
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransactionFromBlock',
 			call: function(args) {
@@ -486,6 +501,23 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getBlacklist',
+			call: 'eth_getBlacklist',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getEpochProof',
+			call: 'eth_getEpochProof',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'verifyEpochProof',
+			call: 'eth_verifyEpochProof',
+			params: 2
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -689,6 +721,16 @@ web3._extend({
             params: 0
 		}),
 		new web3._extend.Method({
+            name: 'getCandles',
+            call: 'FREx_getCandles',
+            params: 5
+		}),
+		new web3._extend.Method({
+            name: 'getTicker',
+            call: 'FREx_getTicker',
+            params: 2
+		}),
+		new web3._extend.Method({
             name: 'getOrderStats',
             call: 'FREx_getOrderStats',
             params: 0
@@ -701,67 +743,86 @@ web3._extend({
 		new web3._extend.Method({
             name: 'getBestBid',
             call: 'FREx_getBestBid',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBestAsk',
             call: 'FREx_getBestAsk',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBidTree',
             call: 'FREx_getBidTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getAskTree',
             call: 'FREx_getAskTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getOrderById',
             call: 'FREx_getOrderById',
-            params: 3
+            params: 4,
+            inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getPrice',
             call: 'FREx_getPrice',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLastEpochPrice',
             call: 'FREx_getLastEpochPrice',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getCurrentEpochPrice',
             call: 'FREx_getCurrentEpochPrice',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getCollateralPrice',
+            call: 'FREx_getCollateralPrice',
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getTradingOrderBookInfo',
             call: 'FREx_getTradingOrderBookInfo',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLiquidationPriceTree',
             call: 'FREx_getLiquidationPriceTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getInvestingTree',
             call: 'FREx_getInvestingTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBorrowingTree',
             call: 'FREx_getBorrowingTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLendingOrderBookInfo',
             call: 'FREx_getLendingOrderBookInfo',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLendingOrderTree',
@@ -771,47 +832,68 @@ web3._extend({
 		new web3._extend.Method({
             name: 'getLendingTradeTree',
             call: 'FREx_getLendingTradeTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLiquidationTimeTree',
             call: 'FREx_getLiquidationTimeTree',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLendingOrderCount',
             call: 'FREx_getLendingOrderCount',
-            params: 1
+            params: 2,
+            inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+        }),
+		new web3._extend.Method({
+            name: 'getUserOrders',
+            call: 'FREx_getUserOrders',
+            params: 4,
+            inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+        }),
+		new web3._extend.Method({
+            name: 'getUserPortfolio',
+            call: 'FREx_getUserPortfolio',
+            params: 2,
+            inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
         }),
 		new web3._extend.Method({
             name: 'getBestInvesting',
             call: 'FREx_getBestInvesting',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBestBorrowing',
             call: 'FREx_getBestBorrowing',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBids',
             call: 'FREx_getBids',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getAsks',
             call: 'FREx_getAsks',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getInvests',
             call: 'FREx_getInvests',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getBorrows',
             call: 'FREx_getBorrows',
-            params: 2
+            params: 3,
+            inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLendingTxMatchByHash',
@@ -826,12 +908,14 @@ web3._extend({
 		new web3._extend.Method({
             name: 'getLendingOrderById',
             call: 'FREx_getLendingOrderById',
-            params: 3
+            params: 4,
+            inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 		new web3._extend.Method({
             name: 'getLendingTradeById',
             call: 'FREx_getLendingTradeById',
-            params: 3
+            params: 4,
+            inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
 	]
 });
@@ -852,6 +936,11 @@ web3._extend({
 			call: 'FRExlending_info',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'getLiquidation',
+			call: 'FRExlending_getLiquidation',
+			params: 1
+		}),
 		new web3._extend.Method({
             name: 'createOrder',
             call: 'FRExlending_createOrder',
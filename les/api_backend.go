@@ -36,6 +36,7 @@ import (
 	"github.com/FRECNET/consensus"
 	"github.com/FRECNET/core"
 	"github.com/FRECNET/core/bloombits"
+	"github.com/FRECNET/core/rawdb"
 	"github.com/FRECNET/core/state"
 	"github.com/FRECNET/core/types"
 	"github.com/FRECNET/core/vm"
@@ -48,6 +49,13 @@ import (
 	"github.com/FRECNET/rpc"
 )
 
+var (
+	errFinalizedBlockNotFound = errors.New("finalized block not found")
+	errSafeBlockNotFound      = errors.New("safe block not found")
+	errBlockNotCanonical      = errors.New("hash is not currently canonical")
+	errInvalidBlockSelector   = errors.New("invalid arguments; neither block nor hash specified")
+)
+
 type LesApiBackend struct {
 	eth *LightEthereum
 	gpo *gasprice.Oracle
@@ -67,8 +75,19 @@ func (b *LesApiBackend) SetHead(number uint64) {
 }
 
 func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
-	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
 		return b.eth.blockchain.CurrentHeader(), nil
+	case rpc.FinalizedBlockNumber:
+		if header := b.eth.blockchain.CurrentFinalizedHeader(); header != nil {
+			return header, nil
+		}
+		return nil, errFinalizedBlockNotFound
+	case rpc.SafeBlockNumber:
+		if header := b.eth.blockchain.CurrentSafeHeader(); header != nil {
+			return header, nil
+		}
+		return nil, errSafeBlockNotFound
 	}
 
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
@@ -82,6 +101,23 @@ func (b *LesApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 	return b.GetBlock(ctx, header.Hash())
 }
 
+func (b *LesApiBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.BlockByNumber(ctx, blockNr)
+	}
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err := b.GetBlock(ctx, hash)
+		if block == nil || err != nil {
+			return nil, err
+		}
+		if blockNrOrHash.RequireCanonical && core.GetCanonicalHash(b.eth.chainDb, block.NumberU64()) != hash {
+			return nil, errBlockNotCanonical
+		}
+		return block, nil
+	}
+	return nil, errInvalidBlockSelector
+}
+
 func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
 	header, err := b.HeaderByNumber(ctx, blockNr)
 	if header == nil || err != nil {
@@ -227,6 +263,14 @@ func (b *LesApiBackend) GetEngine() consensus.Engine {
 func (s *LesApiBackend) GetRewardByHash(hash common.Hash) map[string]map[string]map[string]*big.Int {
 	header := s.eth.blockchain.GetHeaderByHash(hash)
 	if header != nil {
+		if rewards := core.GetBlockRewards(s.eth.chainDb, hash, header.Number.Uint64()); rewards != nil {
+			return rewards
+		}
+		// The checkpoints processed before the rewards were stored in the
+		// database only have the reward files the previous releases wrote to
+		// the rewards folder of the data directory. They are named after the
+		// block hash, or the hash without the validator signature for the
+		// blocks imported before it was added, and are never written anymore.
 		data, err := ioutil.ReadFile(filepath.Join(common.StoreRewardFolder, header.Number.String()+"."+header.Hash().Hex()))
 		if err == nil {
 			rewards := make(map[string]map[string]map[string]*big.Int)
@@ -248,6 +292,12 @@ func (s *LesApiBackend) GetRewardByHash(hash common.Hash) map[string]map[string]
 	return make(map[string]map[string]map[string]*big.Int)
 }
 
+// GetRewardsByAddress returns the rewards received by an address at the reward
+// checkpoints of the epochs between fromEpoch and toEpoch, both included.
+func (s *LesApiBackend) GetRewardsByAddress(address common.Address, fromEpoch, toEpoch uint64) []*rawdb.RewardLookupEntry {
+	return core.GetRewardsByAddress(s.eth.chainDb, address, fromEpoch, toEpoch)
+}
+
 // GetVotersRewards return a map of voters of snapshot at given block hash
 func (b *LesApiBackend) GetVotersRewards(masternodeAddr common.Address) map[common.Address]*big.Int {
 	return map[common.Address]*big.Int{}
@@ -267,6 +317,12 @@ func (b *LesApiBackend) GetMasternodesCap(checkpoint uint64) map[common.Address]
 	return nil
 }
 
+// GetEpochProof retrieves the epoch proof of the checkpoint with the given
+// number from the les servers.
+func (b *LesApiBackend) GetEpochProof(ctx context.Context, number uint64) (*light.EpochProof, error) {
+	return light.GetEpochProof(ctx, b.eth.odr, number)
+}
+
 func (b *LesApiBackend) GetBlocksHashCache(blockNr uint64) []common.Hash {
 	return []common.Hash{}
 }
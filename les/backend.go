@@ -88,6 +88,9 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
+	if config.Testnet {
+		chainConfig = chainConfig.WithTestnetDefaults()
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	peers := newPeerSet()
@@ -145,6 +148,8 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 		name = "LES"
 	case lpv2:
 		name = "LES2"
+	case lpv3:
+		name = "LES3"
 	default:
 		panic(nil)
 	}
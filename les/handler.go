@@ -59,6 +59,7 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxEpochProofsFetch      = 16  // Amount of epoch proofs to be fetched per retrieval request
 
 	disableClientRemovePeer = false
 )
@@ -331,7 +332,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
-var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, GetEpochProofsMsg}
 
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
@@ -1077,6 +1078,65 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
 
+	case GetEpochProofsMsg:
+		p.Log().Trace("Received epoch proofs request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID   uint64
+			Numbers []uint64
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather proofs until the fetch or network limits is reached
+		var (
+			bytes  int
+			proofs []rlp.RawValue
+		)
+		reqCnt := len(req.Numbers)
+		if reject(uint64(reqCnt), MaxEpochProofsFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, number := range req.Numbers {
+			if bytes >= softResponseLimit {
+				break
+			}
+			proof, err := light.BuildEpochProof(pm.chainDb, pm.chainConfig, number)
+			if err != nil {
+				p.Log().Debug("Failed to build epoch proof", "number", number, "err", err)
+				continue
+			}
+			if encoded, err := rlp.EncodeToBytes(proof); err != nil {
+				log.Error("Failed to encode epoch proof", "err", err)
+			} else {
+				proofs = append(proofs, encoded)
+				bytes += len(encoded)
+			}
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendEpochProofsRLP(req.ReqID, bv, proofs)
+
+	case EpochProofsMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received epoch proofs response")
+		var resp struct {
+			ReqID, BV uint64
+			Data      []*light.EpochProof
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgEpochProofs,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
+
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -45,6 +45,7 @@ var (
 	errCHTHashMismatch     = errors.New("cht hash mismatch")
 	errCHTNumberMismatch   = errors.New("cht number mismatch")
 	errUselessNodes        = errors.New("useless nodes in merkle proof nodeset")
+	errEpochProofMismatch  = errors.New("epoch proof checkpoint mismatch")
 )
 
 type LesOdrRequest interface {
@@ -68,6 +69,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
 		return (*BloomRequest)(r)
+	case *light.EpochProofRequest:
+		return (*EpochProofRequest)(r)
 	default:
 		return nil
 	}
@@ -193,7 +196,7 @@ func (r *TrieRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetProofsV1Msg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetProofsV2Msg, 1)
 	default:
 		panic(nil)
@@ -353,7 +356,7 @@ func (r *ChtRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetHeaderProofsMsg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetHelperTrieProofsMsg, 1)
 	default:
 		panic(nil)
@@ -543,6 +546,59 @@ func (r *BloomRequest) Validate(db ethdb.Database, msg *Msg) error {
 	return nil
 }
 
+// ODR request type for requesting the epoch proof of a checkpoint, see LesOdrRequest interface
+type EpochProofRequest light.EpochProofRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *EpochProofRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetEpochProofsMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *EpochProofRequest) CanSend(peer *peer) bool {
+	peer.lock.RLock()
+	defer peer.lock.RUnlock()
+
+	if peer.version < lpv3 {
+		return false
+	}
+	return peer.fcCosts[GetEpochProofsMsg] != nil && peer.headInfo.Number > r.Number
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *EpochProofRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting epoch proof", "number", r.Number)
+	return peer.RequestEpochProofs(reqID, r.GetCost(peer), []uint64{r.Number})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *EpochProofRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating epoch proof", "number", r.Number)
+
+	// Ensure we have a correct message with a single epoch proof
+	if msg.MsgType != MsgEpochProofs {
+		return errInvalidMessageType
+	}
+	proofs := msg.Obj.([]*light.EpochProof)
+	if len(proofs) != 1 {
+		return errInvalidEntryCount
+	}
+	proof := proofs[0]
+	if proof.Header == nil || proof.Header.Number == nil || proof.Header.Number.Uint64() != r.Number {
+		return errEpochProofMismatch
+	}
+	// Check the checkpoint against the local chain if it is known already, the
+	// signatures are verified against a masternode set by the caller
+	if hash := core.GetCanonicalHash(db, r.Number); hash != (common.Hash{}) && hash != proof.Header.Hash() {
+		return errEpochProofMismatch
+	}
+	r.Proof = proof
+	return nil
+}
+
 // readTraceDB stores the keys of database reads. We use this to check that received node
 // sets contain only the trie nodes necessary to make proofs pass.
 type readTraceDB struct {
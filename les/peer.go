@@ -236,6 +236,12 @@ func (p *peer) SendTxStatus(reqID, bv uint64, stats []txStatus) error {
 	return sendResponse(p.rw, TxStatusMsg, reqID, bv, stats)
 }
 
+// SendEpochProofsRLP sends a batch of epoch proofs, corresponding to the ones
+// requested from an already RLP encoded format.
+func (p *peer) SendEpochProofsRLP(reqID, bv uint64, proofs []rlp.RawValue) error {
+	return sendResponse(p.rw, EpochProofsMsg, reqID, bv, proofs)
+}
+
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(reqID, cost uint64, origin common.Hash, amount int, skip int, reverse bool) error {
@@ -276,7 +282,7 @@ func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
 	switch p.version {
 	case lpv1:
 		return sendRequest(p.rw, GetProofsV1Msg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetProofsV2Msg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -298,7 +304,7 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, reqs []HelperTrieReq)
 			reqsV1[i] = ChtReq{ChtNum: (req.TrieIdx + 1) * (light.CHTFrequencyClient / light.CHTFrequencyServer), BlockNum: blockNum, FromLevel: req.FromLevel}
 		}
 		return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqsV1)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetHelperTrieProofsMsg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -311,13 +317,19 @@ func (p *peer) RequestTxStatus(reqID, cost uint64, txHashes []common.Hash) error
 	return sendRequest(p.rw, GetTxStatusMsg, reqID, cost, txHashes)
 }
 
+// RequestEpochProofs fetches the epoch proofs of a batch of checkpoints.
+func (p *peer) RequestEpochProofs(reqID, cost uint64, numbers []uint64) error {
+	p.Log().Debug("Fetching batch of epoch proofs", "count", len(numbers))
+	return sendRequest(p.rw, GetEpochProofsMsg, reqID, cost, numbers)
+}
+
 // SendTxStatus sends a batch of transactions to be added to the remote transaction pool.
 func (p *peer) SendTxs(reqID, cost uint64, txs types.Transactions) error {
 	p.Log().Debug("Fetching batch of transactions", "count", len(txs))
 	switch p.version {
 	case lpv1:
 		return p2p.Send(p.rw, SendTxMsg, txs) // old message format does not include reqID
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, SendTxV2Msg, reqID, cost, txs)
 	default:
 		panic(nil)
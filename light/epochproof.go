@@ -0,0 +1,334 @@
+package light
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/params"
+	"github.com/FRECNET/rlp"
+	"github.com/FRECNET/trie"
+)
+
+// EpochProofSignWindow is the number of blocks following a checkpoint that are
+// scanned for the signing transactions covering it.
+const EpochProofSignWindow = 2 * common.MergeSignRange
+
+var (
+	errNoS2PoSConfig       = errors.New("chain is not running S2PoS")
+	errNotCheckpoint       = errors.New("header is not an epoch checkpoint")
+	errUnknownCreator      = errors.New("checkpoint creator is not a trusted masternode")
+	errInsufficientSigners = errors.New("checkpoint is not signed by enough trusted masternodes")
+	errInvalidCertificate  = errors.New("invalid checkpoint certificate")
+	errInvalidSetProof     = errors.New("invalid masternode set proof")
+)
+
+// EpochProof proves an epoch transition to a client that only trusts the
+// masternode set of the previous epoch: the checkpoint header, the signatures
+// of the previous set covering it and a Merkle proof of the masternode set it
+// starts. A v1 checkpoint is covered by the signing transactions of the
+// previous set, a v2 checkpoint by the quorum certificate of its votes, which
+// the masternodes of the epoch it closes cast.
+type EpochProof struct {
+	Header      *types.Header
+	Signatures  types.Transactions
+	Certificate *utils.QuorumCert `rlp:"nil"`
+	Proof       NodeList
+}
+
+// masternodesTrie builds the trie of a masternode set, keyed by the RLP encoded
+// index of each masternode like the transaction and receipt tries.
+func masternodesTrie(masternodes []common.Address) *trie.Trie {
+	keybuf := new(bytes.Buffer)
+	t := new(trie.Trie)
+	for i, masternode := range masternodes {
+		keybuf.Reset()
+		rlp.Encode(keybuf, uint(i))
+		t.Update(keybuf.Bytes(), masternode.Bytes())
+	}
+	return t
+}
+
+// MasternodesRoot returns the root hash of the trie of a masternode set.
+func MasternodesRoot(masternodes []common.Address) common.Hash {
+	return masternodesTrie(masternodes).Hash()
+}
+
+// proveMasternodes returns the proof of every masternode of the set and of the
+// absence of any further entry.
+func proveMasternodes(masternodes []common.Address) NodeList {
+	t := masternodesTrie(masternodes)
+	nodes := NewNodeSet()
+	for i := 0; i <= len(masternodes); i++ {
+		key, _ := rlp.EncodeToBytes(uint(i))
+		t.Prove(key, 0, nodes)
+	}
+	return nodes.NodeList()
+}
+
+// checkpointMasternodes returns the masternode set started by a checkpoint, v1
+// checkpoints list it in their extra data and v2 ones in their validators.
+func checkpointMasternodes(config *params.ChainConfig, header *types.Header) []common.Address {
+	if config.S2PoS.BlockConsensusVersion(header.Number) == params.ConsensusEngineVersion2 {
+		return common.ExtractAddressFromBytes(header.Validators)
+	}
+	return utils.GetMasternodesFromCheckpointHeader(header)
+}
+
+// BuildEpochProof assembles the epoch proof of the canonical checkpoint with
+// the given number from a full node database. The signing transactions of a v1
+// checkpoint are collected from the EpochProofSignWindow blocks following it,
+// the certificate of a v2 checkpoint is read from the extra data of its child.
+func BuildEpochProof(db ethdb.Database, config *params.ChainConfig, number uint64) (*EpochProof, error) {
+	if config.S2PoS == nil {
+		return nil, errNoS2PoSConfig
+	}
+	if number == 0 || number%config.S2PoS.Epoch != 0 {
+		return nil, errNotCheckpoint
+	}
+	hash := core.GetCanonicalHash(db, number)
+	header := core.GetHeader(db, hash, number)
+	if header == nil {
+		return nil, fmt.Errorf("checkpoint %d not found", number)
+	}
+	proof := &EpochProof{
+		Header: header,
+		Proof:  proveMasternodes(checkpointMasternodes(config, header)),
+	}
+	if config.S2PoS.BlockConsensusVersion(header.Number) == params.ConsensusEngineVersion2 {
+		child := core.GetHeader(db, core.GetCanonicalHash(db, number+1), number+1)
+		if child == nil {
+			return nil, fmt.Errorf("certificate of checkpoint %d not found", number)
+		}
+		var extra utils.ExtraFields_v2
+		if err := utils.DecodeBytesExtraFields(child.Extra, &extra); err != nil {
+			return nil, err
+		}
+		if extra.QuorumCert == nil || extra.QuorumCert.ProposedBlockInfo == nil || extra.QuorumCert.ProposedBlockInfo.Hash != hash {
+			return nil, fmt.Errorf("certificate of checkpoint %d not found", number)
+		}
+		proof.Certificate = extra.QuorumCert
+		return proof, nil
+	}
+	var signatures types.Transactions
+	for n := number + 1; n <= number+EpochProofSignWindow; n++ {
+		block := core.GetBlock(db, core.GetCanonicalHash(db, n), n)
+		if block == nil {
+			break
+		}
+		for _, tx := range block.Transactions() {
+			if tx.IsSigningTransaction() && signedHash(tx) == hash {
+				signatures = append(signatures, tx)
+			}
+		}
+	}
+	proof.Signatures = signatures
+	return proof, nil
+}
+
+// signedHash returns the block hash a signing transaction signs, which is the
+// last word of its call data.
+func signedHash(tx *types.Transaction) common.Hash {
+	data := tx.Data()
+	if len(data) < common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data[len(data)-common.HashLength:])
+}
+
+// recoverSigner returns the address whose key produced the signature of hash.
+func recoverSigner(hash common.Hash, signature []byte) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(hash.Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// checkpointCreator recovers the masternode that sealed a v1 checkpoint.
+func checkpointCreator(header *types.Header) (common.Address, error) {
+	if len(header.Extra) < utils.ExtraVanity+utils.ExtraSeal {
+		return common.Address{}, utils.ErrMissingSignature
+	}
+	return recoverSigner(utils.SigHash(header), header.Extra[len(header.Extra)-utils.ExtraSeal:])
+}
+
+// VerifyEpochProof checks an epoch proof against the masternode set trusted for
+// the previous epoch. A v1 checkpoint must be sealed by a trusted masternode and
+// signed, its creator included, by more than two thirds of the trusted set. A v2
+// checkpoint must be certified by the votes of the CertThreshold share of the
+// trusted set. On success it returns the masternode set of the new epoch,
+// checked against its Merkle proof, and its trie root, which the caller trusts
+// in turn to verify the next proof.
+func VerifyEpochProof(config *params.ChainConfig, proof *EpochProof, trusted []common.Address) ([]common.Address, common.Hash, error) {
+	if config.S2PoS == nil {
+		return nil, common.Hash{}, errNoS2PoSConfig
+	}
+	header := proof.Header
+	if header == nil || header.Number == nil {
+		return nil, common.Hash{}, errNotCheckpoint
+	}
+	number := header.Number.Uint64()
+	if number == 0 || number%config.S2PoS.Epoch != 0 {
+		return nil, common.Hash{}, errNotCheckpoint
+	}
+	var err error
+	if config.S2PoS.BlockConsensusVersion(header.Number) == params.ConsensusEngineVersion2 {
+		err = verifyCheckpointCertificate(config, header, proof.Certificate, trusted)
+	} else {
+		err = verifyCheckpointSignatures(config, header, proof.Signatures, trusted)
+	}
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	// Check the set proof against the masternodes announced by the checkpoint
+	masternodes := checkpointMasternodes(config, header)
+	root := MasternodesRoot(masternodes)
+	nodes := proof.Proof.NodeSet()
+	reads := &readTraceDB{db: nodes}
+	for i := 0; i <= len(masternodes); i++ {
+		key, _ := rlp.EncodeToBytes(uint(i))
+		value, err := trie.VerifyProof(root, key, reads)
+		if err != nil {
+			return nil, common.Hash{}, fmt.Errorf("%v: %v", errInvalidSetProof, err)
+		}
+		if i == len(masternodes) {
+			if value != nil {
+				return nil, common.Hash{}, errInvalidSetProof
+			}
+		} else if !bytes.Equal(value, masternodes[i].Bytes()) {
+			return nil, common.Hash{}, errInvalidSetProof
+		}
+	}
+	if len(reads.reads) != nodes.KeyCount() {
+		return nil, common.Hash{}, fmt.Errorf("%v: useless nodes in proof", errInvalidSetProof)
+	}
+	return masternodes, root, nil
+}
+
+// verifyCheckpointSignatures checks that a v1 checkpoint is sealed by a trusted
+// masternode and signed by more than two thirds of the trusted set.
+func verifyCheckpointSignatures(config *params.ChainConfig, header *types.Header, signatures types.Transactions, trusted []common.Address) error {
+	creator, err := checkpointCreator(header)
+	if err != nil {
+		return err
+	}
+	if utils.Position(trusted, creator) < 0 {
+		return errUnknownCreator
+	}
+	// Count the distinct trusted signers of the checkpoint
+	var (
+		hash    = header.Hash()
+		signer  = types.MakeSigner(config, header.Number)
+		signers = map[common.Address]struct{}{creator: {}}
+	)
+	for _, tx := range signatures {
+		if !tx.IsSigningTransaction() || signedHash(tx) != hash {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil || utils.Position(trusted, from) < 0 {
+			continue
+		}
+		signers[from] = struct{}{}
+	}
+	if len(signers)*3 <= len(trusted)*2 {
+		return errInsufficientSigners
+	}
+	return nil
+}
+
+// verifyCheckpointCertificate checks that the quorum certificate of a v2
+// checkpoint holds the votes of the CertThreshold share of the trusted set,
+// bound to the gap number of the epoch the checkpoint closes.
+func verifyCheckpointCertificate(config *params.ChainConfig, header *types.Header, qc *utils.QuorumCert, trusted []common.Address) error {
+	if qc == nil || qc.ProposedBlockInfo == nil || qc.ProposedBlockInfo.Number == nil {
+		return errInvalidCertificate
+	}
+	if qc.ProposedBlockInfo.Hash != header.Hash() || qc.ProposedBlockInfo.Number.Cmp(header.Number) != 0 {
+		return errInvalidCertificate
+	}
+	var extra utils.ExtraFields_v2
+	if err := utils.DecodeBytesExtraFields(header.Extra, &extra); err != nil || extra.Round != qc.ProposedBlockInfo.Round {
+		return errInvalidCertificate
+	}
+	// The previous epoch starts at the previous checkpoint, its masternodes
+	// were picked at the gap block before it
+	gapNumber := header.Number.Uint64() - config.S2PoS.Epoch
+	if gapNumber != 0 {
+		gapNumber -= config.S2PoS.Gap
+	}
+	if qc.GapNumber != gapNumber {
+		return errInvalidCertificate
+	}
+	hash := utils.VoteSigHash(&utils.VoteForSign{
+		ProposedBlockInfo: qc.ProposedBlockInfo,
+		GapNumber:         qc.GapNumber,
+	})
+	signers := make(map[common.Address]struct{})
+	for _, signature := range qc.Signatures {
+		signer, err := recoverSigner(hash, signature)
+		if err != nil || utils.Position(trusted, signer) < 0 {
+			continue
+		}
+		signers[signer] = struct{}{}
+	}
+	if len(trusted) == 0 || float64(len(signers)) < float64(len(trusted))*config.S2PoS.V2Params().CertThreshold {
+		return errInsufficientSigners
+	}
+	return nil
+}
+
+// readTraceDB stores the keys of database reads, so that proofs carrying nodes
+// unused by the verification can be rejected.
+type readTraceDB struct {
+	db    ethdb.KeyValueReader
+	reads map[string]struct{}
+}
+
+// Get returns a stored node
+func (db *readTraceDB) Get(k []byte) ([]byte, error) {
+	if db.reads == nil {
+		db.reads = make(map[string]struct{})
+	}
+	db.reads[string(k)] = struct{}{}
+	return db.db.Get(k)
+}
+
+// Has returns true if the node set contains the given key
+func (db *readTraceDB) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	return err == nil, nil
+}
+
+// EpochProofRequest is the ODR request type for retrieving the epoch proof of a
+// checkpoint
+type EpochProofRequest struct {
+	OdrRequest
+	Number uint64
+	Proof  *EpochProof
+}
+
+// StoreResult doesn't store anything: the checkpoint is only trusted once the
+// caller verified the proof against a masternode set.
+func (req *EpochProofRequest) StoreResult(db ethdb.Database) {}
+
+// GetEpochProof retrieves the epoch proof of the checkpoint with the given
+// number. The proof is not verified against a masternode set; callers do so
+// with VerifyEpochProof.
+func GetEpochProof(ctx context.Context, odr OdrBackend, number uint64) (*EpochProof, error) {
+	r := &EpochProofRequest{Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Proof, nil
+}
@@ -0,0 +1,296 @@
+package light
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/FRECNET/common"
+	"github.com/FRECNET/consensus/S2PoS/utils"
+	"github.com/FRECNET/core"
+	"github.com/FRECNET/core/rawdb"
+	"github.com/FRECNET/core/types"
+	"github.com/FRECNET/crypto"
+	"github.com/FRECNET/ethdb"
+	"github.com/FRECNET/params"
+	"github.com/FRECNET/rlp"
+)
+
+// epochProofChain writes a checkpoint sealed by the creator and announcing the
+// next masternodes, followed by a block holding the signing transactions of the
+// signers, and returns the chain config and the checkpoint number.
+func epochProofChain(t *testing.T, db ethdb.Database, creator *ecdsa.PrivateKey, signers []*ecdsa.PrivateKey, next []common.Address) (*params.ChainConfig, uint64) {
+	config := *params.TestChainConfig
+	config.S2PoS = &params.S2PoSConfig{Epoch: 900}
+	number := uint64(900)
+
+	extra := make([]byte, utils.ExtraVanity)
+	for _, masternode := range next {
+		extra = append(extra, masternode.Bytes()...)
+	}
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(1),
+		Extra:      append(extra, make([]byte, utils.ExtraSeal)...),
+	}
+	sealCheckpoint(t, header, creator)
+	core.WriteHeader(db, header)
+	core.WriteCanonicalHash(db, header.Hash(), number)
+
+	signer := types.MakeSigner(&config, header.Number)
+	var txs types.Transactions
+	for i, key := range signers {
+		data := append(common.Hex2Bytes(common.HexSignMethod), common.LeftPadBytes(header.Number.Bytes(), 32)...)
+		data = append(data, header.Hash().Bytes()...)
+		tx, err := types.SignTx(types.NewTransaction(uint64(i), common.HexToAddress(common.BlockSigners), new(big.Int), 200000, new(big.Int), data), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(number + 1), ParentHash: header.Hash(), Difficulty: big.NewInt(1)}, txs, nil, nil)
+	core.WriteBlock(db, block)
+	core.WriteCanonicalHash(db, block.Hash(), number+1)
+
+	return &config, number
+}
+
+// sealCheckpoint signs a checkpoint header with the key of its creator.
+func sealCheckpoint(t *testing.T, header *types.Header, creator *ecdsa.PrivateKey) {
+	seal, err := crypto.Sign(utils.SigHash(header).Bytes(), creator)
+	if err != nil {
+		t.Fatalf("failed to seal checkpoint: %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-utils.ExtraSeal:], seal)
+}
+
+func TestEpochProof(t *testing.T) {
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 4)
+		trusted = make([]common.Address, 4)
+		next    = []common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		trusted[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	tests := []struct {
+		signers []*ecdsa.PrivateKey
+		trusted []common.Address
+		err     error
+	}{
+		{keys[1:3], trusted, nil},
+		{keys[1:4], trusted, nil},
+		{keys[1:2], trusted, errInsufficientSigners},
+		{[]*ecdsa.PrivateKey{keys[1], keys[1]}, trusted, errInsufficientSigners},
+		{keys[1:4], trusted[1:], errUnknownCreator},
+	}
+	for i, tt := range tests {
+		db := rawdb.NewMemoryDatabase()
+		config, number := epochProofChain(t, db, keys[0], tt.signers, next)
+		proof, err := BuildEpochProof(db, config, number)
+		if err != nil {
+			t.Fatalf("test %d: failed to build proof: %v", i, err)
+		}
+		masternodes, root, err := VerifyEpochProof(config, proof, tt.trusted)
+		if err != tt.err {
+			t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		if len(masternodes) != len(next) {
+			t.Fatalf("test %d: masternode count mismatch: have %d, want %d", i, len(masternodes), len(next))
+		}
+		for j := range next {
+			if masternodes[j] != next[j] {
+				t.Errorf("test %d: masternode %d mismatch: have %x, want %x", i, j, masternodes[j], next[j])
+			}
+		}
+		if want := MasternodesRoot(next); root != want {
+			t.Errorf("test %d: root mismatch: have %x, want %x", i, root, want)
+		}
+	}
+}
+
+func TestEpochProofInvalid(t *testing.T) {
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 3)
+		trusted = make([]common.Address, 3)
+		next    = []common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2")}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		trusted[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	db := rawdb.NewMemoryDatabase()
+	config, number := epochProofChain(t, db, keys[0], keys[1:], next)
+
+	if _, err := BuildEpochProof(db, config, number+1); err != errNotCheckpoint {
+		t.Errorf("non checkpoint error mismatch: have %v, want %v", err, errNotCheckpoint)
+	}
+	proof, err := BuildEpochProof(db, config, number)
+	if err != nil {
+		t.Fatalf("failed to build proof: %v", err)
+	}
+	// Drop a node of the set proof
+	incomplete := *proof
+	incomplete.Proof = proof.Proof[1:]
+	if _, _, err := VerifyEpochProof(config, &incomplete, trusted); err == nil {
+		t.Errorf("incomplete set proof accepted")
+	}
+	// Add a node unused by the set proof
+	padded := *proof
+	padded.Proof = append(append(NodeList{}, proof.Proof...), []byte{0xc0})
+	if _, _, err := VerifyEpochProof(config, &padded, trusted); err == nil {
+		t.Errorf("padded set proof accepted")
+	}
+	// Signatures of another block do not count, even when it is sealed by a
+	// trusted masternode
+	forged := *proof
+	forged.Header = types.CopyHeader(proof.Header)
+	forged.Header.Time = big.NewInt(1)
+	sealCheckpoint(t, forged.Header, keys[0])
+	if _, _, err := VerifyEpochProof(config, &forged, trusted); err != errInsufficientSigners {
+		t.Errorf("forged checkpoint error mismatch: have %v, want %v", err, errInsufficientSigners)
+	}
+	// Neither do they for another masternode set
+	forged.Header = types.CopyHeader(proof.Header)
+	copy(forged.Header.Extra[utils.ExtraVanity:], common.HexToAddress("0x4").Bytes())
+	sealCheckpoint(t, forged.Header, keys[0])
+	if _, _, err := VerifyEpochProof(config, &forged, trusted); err != errInsufficientSigners {
+		t.Errorf("forged masternode set error mismatch: have %v, want %v", err, errInsufficientSigners)
+	}
+	// A checkpoint modified after sealing has another creator
+	forged.Header = types.CopyHeader(proof.Header)
+	forged.Header.Time = big.NewInt(1)
+	if _, _, err := VerifyEpochProof(config, &forged, trusted); err != errUnknownCreator {
+		t.Errorf("altered checkpoint error mismatch: have %v, want %v", err, errUnknownCreator)
+	}
+}
+
+// epochProofChainV2 writes a v2 checkpoint announcing the next masternodes,
+// followed by a block carrying the certificate of the votes of the signers on
+// it with the given gap number, and returns the chain config and the
+// checkpoint number.
+func epochProofChainV2(t *testing.T, db ethdb.Database, signers []*ecdsa.PrivateKey, gapNumber uint64, next []common.Address) (*params.ChainConfig, uint64) {
+	config := *params.TestChainConfig
+	config.S2PoS = &params.S2PoSConfig{Epoch: 900, Gap: 450, V2ConsensusBlockNumber: big.NewInt(10)}
+	number := uint64(1800)
+
+	extra, err := (&utils.ExtraFields_v2{
+		Round: 1000,
+		QuorumCert: &utils.QuorumCert{
+			ProposedBlockInfo: &utils.BlockInfo{Round: 999, Number: new(big.Int).SetUint64(number - 1)},
+			GapNumber:         450,
+		},
+	}).EncodeToBytes()
+	if err != nil {
+		t.Fatalf("failed to encode extra fields: %v", err)
+	}
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(1),
+		Extra:      extra,
+		Validators: common.ExtractAddressToBytes(next),
+	}
+	core.WriteHeader(db, header)
+	core.WriteCanonicalHash(db, header.Hash(), number)
+
+	qc := &utils.QuorumCert{
+		ProposedBlockInfo: &utils.BlockInfo{Hash: header.Hash(), Round: 1000, Number: header.Number},
+		GapNumber:         gapNumber,
+	}
+	hash := utils.VoteSigHash(&utils.VoteForSign{ProposedBlockInfo: qc.ProposedBlockInfo, GapNumber: qc.GapNumber})
+	for _, key := range signers {
+		signature, err := crypto.Sign(hash.Bytes(), key)
+		if err != nil {
+			t.Fatalf("failed to sign vote: %v", err)
+		}
+		qc.Signatures = append(qc.Signatures, signature)
+	}
+	if extra, err = (&utils.ExtraFields_v2{Round: 1001, QuorumCert: qc}).EncodeToBytes(); err != nil {
+		t.Fatalf("failed to encode extra fields: %v", err)
+	}
+	child := &types.Header{Number: new(big.Int).SetUint64(number + 1), ParentHash: header.Hash(), Difficulty: big.NewInt(1), Extra: extra}
+	core.WriteHeader(db, child)
+	core.WriteCanonicalHash(db, child.Hash(), number+1)
+
+	return &config, number
+}
+
+func TestEpochProofV2(t *testing.T) {
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 4)
+		trusted = make([]common.Address, 4)
+		next    = []common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		trusted[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	tests := []struct {
+		signers   []*ecdsa.PrivateKey
+		gapNumber uint64
+		trusted   []common.Address
+		err       error
+	}{
+		{keys[:3], 450, trusted, nil},
+		{keys, 450, trusted, nil},
+		{keys[:2], 450, trusted, errInsufficientSigners},
+		{[]*ecdsa.PrivateKey{keys[0], keys[0], keys[1]}, 450, trusted, errInsufficientSigners},
+		{keys[:3], 450, trusted[2:], errInsufficientSigners},
+		// votes bound to the masternodes listed in the checkpoint
+		{keys[:3], 1350, trusted, errInvalidCertificate},
+	}
+	for i, tt := range tests {
+		db := rawdb.NewMemoryDatabase()
+		config, number := epochProofChainV2(t, db, tt.signers, tt.gapNumber, next)
+		proof, err := BuildEpochProof(db, config, number)
+		if err != nil {
+			t.Fatalf("test %d: failed to build proof: %v", i, err)
+		}
+		masternodes, root, err := VerifyEpochProof(config, proof, tt.trusted)
+		if err != tt.err {
+			t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		if len(masternodes) != len(next) || root != MasternodesRoot(next) {
+			t.Fatalf("test %d: masternode set mismatch: have %x (root %x), want %x", i, masternodes, root, next)
+		}
+	}
+
+	// The certificate must cover the checkpoint itself
+	db := rawdb.NewMemoryDatabase()
+	config, number := epochProofChainV2(t, db, keys, 450, next)
+	proof, err := BuildEpochProof(db, config, number)
+	if err != nil {
+		t.Fatalf("failed to build proof: %v", err)
+	}
+	// The proof survives the encoding used by les and the RPC API
+	encoded, err := rlp.EncodeToBytes(proof)
+	if err != nil {
+		t.Fatalf("failed to encode proof: %v", err)
+	}
+	decoded := new(EpochProof)
+	if err := rlp.DecodeBytes(encoded, decoded); err != nil {
+		t.Fatalf("failed to decode proof: %v", err)
+	}
+	if _, _, err := VerifyEpochProof(config, decoded, trusted); err != nil {
+		t.Fatalf("decoded proof not verified: %v", err)
+	}
+	forged := *proof
+	forged.Header = types.CopyHeader(proof.Header)
+	forged.Header.Validators = common.ExtractAddressToBytes(trusted)
+	forged.Proof = proveMasternodes(trusted)
+	if _, _, err := VerifyEpochProof(config, &forged, trusted); err != errInvalidCertificate {
+		t.Errorf("forged checkpoint error mismatch: have %v, want %v", err, errInvalidCertificate)
+	}
+	forged = *proof
+	forged.Certificate = nil
+	if _, _, err := VerifyEpochProof(config, &forged, trusted); err != errInvalidCertificate {
+		t.Errorf("missing certificate error mismatch: have %v, want %v", err, errInvalidCertificate)
+	}
+}
@@ -390,6 +390,18 @@ func (self *LightChain) CurrentHeader() *types.Header {
 	return self.hc.CurrentHeader()
 }
 
+// CurrentFinalizedHeader retrieves the highest block committed by the consensus
+// engine, if known locally.
+func (self *LightChain) CurrentFinalizedHeader() *types.Header {
+	return self.hc.CurrentFinalizedHeader()
+}
+
+// CurrentSafeHeader retrieves the highest block certified by a quorum
+// certificate, if known locally.
+func (self *LightChain) CurrentSafeHeader() *types.Header {
+	return self.hc.CurrentSafeHeader()
+}
+
 // GetTd retrieves a block's total difficulty in the canonical chain from the
 // database by hash and number, caching it if found.
 func (self *LightChain) GetTd(hash common.Hash, number uint64) *big.Int {
@@ -344,12 +344,14 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 		err  error
 	)
 
+	head := pool.chain.CurrentHeader()
+	blacklist := core.GetBlacklist(pool.config, head.Number, head.Root, pool.currentState(ctx))
 	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
+	if core.IsBlacklisted(blacklist, tx.From()) {
 		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
 	}
 	// check if receiver is in black list
-	if tx.To() != nil && common.Blacklist[*tx.To()] {
+	if core.IsBlacklisted(blacklist, tx.To()) {
 		return fmt.Errorf("Reject transaction with receiver in black-list: %v", tx.To().Hex())
 	}
 
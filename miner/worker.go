@@ -97,6 +97,8 @@ type Work struct {
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 
+	blacklist map[common.Address]uint64 // addresses blacklisted in the new block
+
 	createdAt time.Time
 }
 
@@ -120,6 +122,7 @@ type worker struct {
 	chainHeadSub event.Subscription
 	chainSideCh  chan core.ChainSideEvent
 	chainSideSub event.Subscription
+	newRoundCh   <-chan utils.Round // Rounds started by the S2PoS v2 engine, nil otherwise
 	wg           sync.WaitGroup
 
 	agents map[Agent]struct{}
@@ -174,6 +177,9 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 	// Subscribe events for blockchain
 	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = eth.BlockChain().SubscribeChainSideEvent(worker.chainSideCh)
+	if c, ok := engine.(*S2PoS.S2PoS); ok {
+		worker.newRoundCh = c.EngineV2.NewRoundCh
+	}
 	go worker.update()
 
 	go worker.wait()
@@ -299,6 +305,13 @@ func (self *worker) update() {
 			self.commitNewWork()
 			timeout.Reset(waitPeriod * time.Second)
 
+			// Handle the new rounds of the S2PoS v2 engine
+		case <-self.newRoundCh:
+			if atomic.LoadInt32(&self.mining) == 1 {
+				self.commitNewWork()
+			}
+			timeout.Reset(waitPeriod * time.Second)
+
 			// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
 			if self.config.S2PoS == nil {
@@ -511,7 +524,25 @@ func (self *worker) commitNewWork() {
 	tstart := time.Now()
 	parent := self.chain.CurrentBlock()
 	var signers map[common.Address]struct{}
-	if parent.Hash().Hex() == self.lastParentBlockCommit {
+	isV2 := false
+	if self.config.S2PoS != nil {
+		c := self.engine.(*S2PoS.S2PoS)
+		isV2 = c.IsV2Block(new(big.Int).Add(parent.Number(), common.Big1))
+		if isV2 {
+			// v2 blocks are built on top of the highest certified block, which
+			// may not be the head of the chain yet
+			if hash, ok := c.ProposalParentHash(); ok && hash != parent.Hash() {
+				parent = self.chain.GetBlockByHash(hash)
+				if parent == nil {
+					log.Debug("Certified parent block not found yet", "hash", hash)
+					return
+				}
+			}
+		}
+	}
+	// A v2 proposal is bound to a round rather than to its parent, the engine
+	// refuses to propose twice in the same round
+	if !isV2 && parent.Hash().Hex() == self.lastParentBlockCommit {
 		return
 	}
 	if !self.announceTxs && atomic.LoadInt32(&self.mining) == 0 {
@@ -530,6 +561,10 @@ func (self *worker) commitNewWork() {
 				log.Warn("Failed when trying to commit new work", "err", err)
 				return
 			}
+			if !ok && isV2 {
+				// Rounds without a proposal are skipped by timeout certificates
+				return
+			}
 			if !ok {
 				log.Info("Not my turn to commit block. Waiting...")
 				// in case some nodes are down
@@ -606,12 +641,22 @@ func (self *worker) commitNewWork() {
 	}
 	// Create the current work task and check any fork transitions needed
 	work := self.current
+	work.blacklist = core.GetBlacklist(self.config, parent.Number(), parent.Root(), work.state)
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
-	if common.TIPSigning.Cmp(header.Number) == 0 {
+	if self.config.IsTIPSigningBlock(header.Number) {
 		work.state.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
+	if self.config.IsTIPBlacklistContractBlock(header.Number) {
+		core.ApplyBlacklistHardFork(self.config, work.state)
+	}
+	if self.config.IsTIPBlacklistContract(header.Number) {
+		core.ApplyBlacklistMasternodes(self.config, self.engine, self.chain, header, work.state)
+	}
+	if self.config.IsTIPFREXFeeScheduleBlock(header.Number) {
+		tradingstate.ApplyFeeScheduleHardFork(work.state)
+	}
 	// won't grasp txs at checkpoint
 	var (
 		txs                                                                  *types.TransactionsByPriceAndNonce
@@ -646,7 +691,7 @@ func (self *worker) commitNewWork() {
 			FREXLending := self.eth.GetFREXLending()
 			if FREX != nil && header.Number.Uint64() > self.config.S2PoS.Epoch {
 				if header.Number.Uint64()%self.config.S2PoS.Epoch == 0 {
-					err := FREX.UpdateMediumPriceBeforeEpoch(header.Number.Uint64()/self.config.S2PoS.Epoch, work.tradingState, work.state)
+					err := FREX.UpdateMediumPriceBeforeEpoch(header.Number.Uint64()/self.config.S2PoS.Epoch, work.tradingState, work.state, self.chain.Config().IsTIPFREXPriceOracle(header.Number))
 					if err != nil {
 						log.Error("Fail when update medium price last epoch", "error", err)
 						return
@@ -829,14 +874,14 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 	for _, tx := range specialTxs {
 
 		//HF number for black-list
-		if (env.header.Number.Uint64() >= common.BlackListHFNumber) && !common.IsTestnet {
+		if env.config.IsBlackList(env.header.Number) {
 			// check if sender is in black list
-			if tx.From() != nil && common.Blacklist[*tx.From()] {
+			if core.IsBlacklisted(env.blacklist, tx.From()) {
 				log.Debug("Skipping transaction with sender in black-list", "sender", tx.From().Hex())
 				continue
 			}
 			// check if receiver is in black list
-			if tx.To() != nil && common.Blacklist[*tx.To()] {
+			if core.IsBlacklisted(env.blacklist, tx.To()) {
 				log.Debug("Skipping transaction with receiver in black-list", "receiver", tx.To().Hex())
 				continue
 			}
@@ -925,7 +970,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 		// }
 		// changeit-GASUPDATE
 		if tokenFeeUsed {
-			fee := common.GetGasFee(env.header.Number.Uint64(), gas)
+			fee := env.config.GetGasFee(env.header.Number, gas)
 			balanceFee[*tx.To()] = new(big.Int).Sub(balanceFee[*tx.To()], fee)
 			balanceUpdated[*tx.To()] = balanceFee[*tx.To()]
 			totalFeeUsed = totalFeeUsed.Add(totalFeeUsed, fee)
@@ -949,15 +994,15 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 		}
 
 		//HF number for black-list
-		if (env.header.Number.Uint64() >= common.BlackListHFNumber) && !common.IsTestnet {
+		if env.config.IsBlackList(env.header.Number) {
 			// check if sender is in black list
-			if tx.From() != nil && common.Blacklist[*tx.From()] {
+			if core.IsBlacklisted(env.blacklist, tx.From()) {
 				log.Debug("Skipping transaction with sender in black-list", "sender", tx.From().Hex())
 				txs.Pop()
 				continue
 			}
 			// check if receiver is in black list
-			if tx.To() != nil && common.Blacklist[*tx.To()] {
+			if core.IsBlacklisted(env.blacklist, tx.To()) {
 				log.Debug("Skipping transaction with receiver in black-list", "receiver", tx.To().Hex())
 				txs.Shift()
 				continue
@@ -1050,7 +1095,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 		// }
 		// changeit-GASUPDATE
 		if tokenFeeUsed {
-			fee := common.GetGasFee(env.header.Number.Uint64(), gas)
+			fee := env.config.GetGasFee(env.header.Number, gas)
 			balanceFee[*tx.To()] = new(big.Int).Sub(balanceFee[*tx.To()], fee)
 			balanceUpdated[*tx.To()] = balanceFee[*tx.To()]
 			totalFeeUsed = totalFeeUsed.Add(totalFeeUsed, fee)
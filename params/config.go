@@ -52,6 +52,7 @@ var (
 			Gap:                 5,
 			FoudationWalletAddr: common.HexToAddress("0x0000000000000000000000000000000000000068"),
 		},
+		Forks: MainnetForks(),
 	}
 
 	// MainnetChainConfig is the chain parameters to run a node on the main network.
@@ -107,23 +108,23 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil, nil, nil}
 
 	// AllS2PoSProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the S2PoS consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllS2PoSProtocolChanges  = &ChainConfig{big.NewInt(89), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &S2PoSConfig{Period: 0, Epoch: 30000}}
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllS2PoSProtocolChanges  = &ChainConfig{big.NewInt(89), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &S2PoSConfig{Period: 0, Epoch: 30000}, nil, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil, nil}
 
-	TestS2PoSChanConfig = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &S2PoSConfig{Period: 2, Epoch: 900, Reward: 250, RewardCheckpoint: 900, Gap: 890, FoudationWalletAddr: common.HexToAddress("0x0000000000000000000000000000000000000068")}}
+	TestS2PoSChanConfig = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &S2PoSConfig{Period: 2, Epoch: 900, Reward: 250, RewardCheckpoint: 900, Gap: 890, FoudationWalletAddr: common.HexToAddress("0x0000000000000000000000000000000000000068")}, nil, nil}
 	// S2PoS config in use for v1 engine only
-	TestS2PoSMockChainConfig = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, &S2PoSConfig{Epoch: 900, Gap: 450, SkipValidation: true}}
+	TestS2PoSMockChainConfig = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, &S2PoSConfig{Epoch: 900, Gap: 450, SkipValidation: true}, nil, nil}
 	// S2PoS config with v2 engine after block 10
-	TestS2PoSMockChainConfigWithV2Engine = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, &S2PoSConfig{Epoch: 900, Gap: 450, SkipValidation: true, V2ConsensusBlockNumber: big.NewInt(10)}}
+	TestS2PoSMockChainConfigWithV2Engine = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, &S2PoSConfig{Epoch: 900, Gap: 450, SkipValidation: true, V2ConsensusBlockNumber: big.NewInt(10)}, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -154,6 +155,9 @@ type ChainConfig struct {
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 	S2PoS  *S2PoSConfig  `json:"S2PoS,omitempty"`
+
+	Forks   *ForksConfig   `json:"forks,omitempty"`   // FRECNET hardfork blocks, the mainnet ones if nil
+	Lending *LendingConfig `json:"lending,omitempty"` // FREx lending parameters, defaults apply if nil
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -185,6 +189,52 @@ type S2PoSConfig struct {
 	FoudationWalletAddr    common.Address `json:"foudationWalletAddr"` // Foundation Address Wallet
 	SkipValidation         bool           //Skip Block Validation for testing purpose
 	V2ConsensusBlockNumber *big.Int
+	V2                     *V2Config `json:"v2,omitempty"` // BFT parameters of the v2 engine, defaults apply if nil
+
+	Testnet        bool `json:"testnet,omitempty"`        // Apothem testnet, whose headers are not fully verified
+	MaxMasternodes int  `json:"maxMasternodes,omitempty"` // Size of the masternode set from the dynamic masternodes fork, the setting contract one if 0
+}
+
+// V2Config holds the BFT parameters of the S2PoS v2 consensus engine.
+type V2Config struct {
+	CertThreshold        float64 `json:"certThreshold"`        // Fraction of the masternodes whose signatures form a certificate
+	TimeoutPeriod        int     `json:"timeoutPeriod"`        // Seconds to wait in a round before sending a timeout
+	TimeoutSyncThreshold int     `json:"timeoutSyncThreshold"` // Number of consecutive timeouts before broadcasting the sync info
+	MinePeriod           int     `json:"minePeriod"`           // Minimum seconds between two v2 blocks
+}
+
+// DefaultV2Config is used by the v2 engine when the chain config doesn't carry one.
+var DefaultV2Config = &V2Config{
+	CertThreshold:        0.667,
+	TimeoutPeriod:        10,
+	TimeoutSyncThreshold: 3,
+	MinePeriod:           2,
+}
+
+// LendingConfig holds the liquidation parameters of the FREx lending.
+type LendingConfig struct {
+	LiquidationPenalty  uint64 `json:"liquidationPenalty"`            // Share of the partially liquidated collateral paid as a penalty, in percent
+	KeeperShare         uint64 `json:"keeperShare"`                   // Share of the penalty paid to the masternode creating the block, in percent, the rest to the investing relayer
+	Auction             bool   `json:"auction,omitempty"`             // Sell the liquidated collateral through the FREx order book in a descending price auction
+	AuctionStepDiscount uint64 `json:"auctionStepDiscount,omitempty"` // Discount to the collateral price added at every auction round, in percent
+	AuctionMaxDiscount  uint64 `json:"auctionMaxDiscount,omitempty"`  // Discount of the last auction round before the trade is liquidated entirely, in percent
+}
+
+// DefaultLendingConfig is used by the lending when the chain config doesn't carry one.
+var DefaultLendingConfig = &LendingConfig{
+	LiquidationPenalty:  5,
+	KeeperShare:         50,
+	Auction:             false,
+	AuctionStepDiscount: 5,
+	AuctionMaxDiscount:  20,
+}
+
+// LendingParams returns the lending parameters, falling back to DefaultLendingConfig.
+func (c *ChainConfig) LendingParams() *LendingConfig {
+	if c.Lending != nil {
+		return c.Lending
+	}
+	return DefaultLendingConfig
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -194,8 +244,8 @@ func (c *S2PoSConfig) String() string {
 
 /*
 *
-ConsensusVersion will return the consensus version to use for the provided block number. The returned int represent its version
-TODO: It's a dummy value for now until the 2.0 consensus engine is fully implemented.
+ConsensusVersion will return the consensus version to use for the provided block number. The returned int represent its version.
+Blocks after V2ConsensusBlockNumber are handled by the BFT based v2 engine, the switch block itself is the last v1 block.
 */
 func (c *S2PoSConfig) BlockConsensusVersion(num *big.Int) string {
 	if c.V2ConsensusBlockNumber != nil && num.Cmp(c.V2ConsensusBlockNumber) > 0 {
@@ -204,6 +254,14 @@ func (c *S2PoSConfig) BlockConsensusVersion(num *big.Int) string {
 	return ConsensusEngineVersion1
 }
 
+// V2Params returns the v2 engine parameters, falling back to DefaultV2Config.
+func (c *S2PoSConfig) V2Params() *V2Config {
+	if c.V2 != nil {
+		return c.V2
+	}
+	return DefaultV2Config
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -263,50 +321,123 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 // - equal to or greater than the PetersburgBlock fork block,
 // - OR is nil, and Constantinople is active
 func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
-	return isForked(common.TIPFREXCancellationFee, num)
+	return isForked(c.ForkBlocks().TIPFREXCancellationFeeBlock, num)
 }
 
 // IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
 func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
-	return isForked(common.TIPFREXCancellationFee, num)
+	return isForked(c.ForkBlocks().TIPFREXCancellationFeeBlock, num)
 }
 
 func (c *ChainConfig) IsTIP2019(num *big.Int) bool {
-	return isForked(common.TIP2019Block, num)
+	return isForked(c.ForkBlocks().TIP2019Block, num)
 }
 
 func (c *ChainConfig) IsTIPSigning(num *big.Int) bool {
-	return isForked(common.TIPSigning, num)
+	return isForked(c.ForkBlocks().TIPSigningBlock, num)
 }
 
 func (c *ChainConfig) IsTIPRandomize(num *big.Int) bool {
-	return isForked(common.TIPRandomize, num)
+	return isForked(c.ForkBlocks().TIPRandomizeBlock, num)
 }
 
 // IsTIPIncreaseMasternodes using for increase masternodes from 18 to 40
 
 // Time update: 23-07-2019
 func (c *ChainConfig) IsTIPIncreaseMasternodes(num *big.Int) bool {
-	return isForked(common.TIPIncreaseMasternodes, num)
+	return isForked(c.ForkBlocks().TIPIncreaseMasternodesBlock, num)
 }
 
 func (c *ChainConfig) IsTIPNoHalvingMNReward(num *big.Int) bool {
-	return isForked(common.TIPNoHalvingMNReward, num)
+	return isForked(c.ForkBlocks().TIPNoHalvingMNRewardBlock, num)
 }
 func (c *ChainConfig) IsTIPFREX(num *big.Int) bool {
-	if common.IsTestnet {
-		return isForked(common.TIPFREXTestnet, num)
-	} else {
-		return isForked(common.TIPFREX, num)
-	}
+	return isForked(c.ForkBlocks().TIPFREXBlock, num)
 }
 
 func (c *ChainConfig) IsTIPFREXLending(num *big.Int) bool {
-	return isForked(common.TIPFREXLending, num)
+	return isForked(c.ForkBlocks().TIPFREXLendingBlock, num)
 }
 
 func (c *ChainConfig) IsTIPFREXCancellationFee(num *big.Int) bool {
-	return isForked(common.TIPFREXCancellationFee, num)
+	return isForked(c.ForkBlocks().TIPFREXCancellationFeeBlock, num)
+}
+
+// IsTIPFREXTriggerOrder returns whether stop and take profit orders are
+// accepted by the order books.
+func (c *ChainConfig) IsTIPFREXTriggerOrder(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXTriggerOrderBlock, num)
+}
+
+// IsTIPFREXTimeInForce returns whether limit orders may set a time in force.
+func (c *ChainConfig) IsTIPFREXTimeInForce(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXTimeInForceBlock, num)
+}
+
+// IsTIPFREXPriceOracle returns whether collateral prices are aggregated by
+// the lending price oracle.
+func (c *ChainConfig) IsTIPFREXPriceOracle(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXPriceOracleBlock, num)
+}
+
+// IsTIPFREXPartialLiquidation returns whether unhealthy lending trades are
+// liquidated partially, back to their deposit rate.
+func (c *ChainConfig) IsTIPFREXPartialLiquidation(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXPartialLiquidationBlock, num)
+}
+
+// IsTIPFREXOpenTermLending returns whether lending tokens have an open-term
+// variable-rate lending book.
+func (c *ChainConfig) IsTIPFREXOpenTermLending(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXOpenTermLendingBlock, num)
+}
+
+// IsTIPFREXPortfolio returns whether the trading and lending states index the
+// open orders and lending trades of every user.
+func (c *ChainConfig) IsTIPFREXPortfolio(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXPortfolioBlock, num)
+}
+
+// IsTIPFREXFeeSchedule returns whether trading fees follow the maker and taker
+// fee schedules of the relayers.
+func (c *ChainConfig) IsTIPFREXFeeSchedule(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXFeeScheduleBlock, num)
+}
+
+// IsTIPFREXSelfTrade returns whether the orders of a user are kept from
+// matching each other.
+func (c *ChainConfig) IsTIPFREXSelfTrade(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXSelfTradeBlock, num)
+}
+
+// IsTIPFREXBatchOrder returns whether batch order transactions, applying
+// several new, cancel and amend operations atomically, are accepted.
+func (c *ChainConfig) IsTIPFREXBatchOrder(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPFREXBatchOrderBlock, num)
+}
+
+// IsTIPBlacklistContract returns whether the blacklist is read from the
+// blacklist contract instead of the compiled-in list.
+func (c *ChainConfig) IsTIPBlacklistContract(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPBlacklistContractBlock, num)
+}
+
+// IsTIPDynamicMasternodes returns whether the size of the masternode set is
+// read from the chain config or the setting contract.
+func (c *ChainConfig) IsTIPDynamicMasternodes(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPDynamicMasternodesBlock, num)
+}
+
+// IsTIPStakeWeightedProposer returns whether the block proposers are drawn
+// by stake out of the epoch checkpoint instead of taking turns.
+func (c *ChainConfig) IsTIPStakeWeightedProposer(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPStakeWeightedProposerBlock, num)
+}
+
+// IsTIPRandomizeFallback returns whether the masternodes missing a randomize
+// reveal take part in the M2 assignment with a fallback value instead of zero.
+func (c *ChainConfig) IsTIPRandomizeFallback(num *big.Int) bool {
+	return isForked(c.ForkBlocks().TIPRandomizeFallbackBlock, num)
 }
 
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
@@ -372,6 +503,20 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if c.S2PoS != nil && newcfg.S2PoS != nil && isForkIncompatible(c.S2PoS.V2ConsensusBlockNumber, newcfg.S2PoS.V2ConsensusBlockNumber, head) {
+		return newCompatError("S2PoS v2 switch block", c.S2PoS.V2ConsensusBlockNumber, newcfg.S2PoS.V2ConsensusBlockNumber)
+	}
+	if err := c.ForkBlocks().checkCompatible(newcfg.ForkBlocks(), head); err != nil {
+		return err
+	}
+	// the configured masternode set size applies from the dynamic masternodes fork
+	if c.S2PoS != nil && newcfg.S2PoS != nil && (c.IsTIPDynamicMasternodes(head) || newcfg.IsTIPDynamicMasternodes(head)) && c.S2PoS.MaxMasternodes != newcfg.S2PoS.MaxMasternodes {
+		return newCompatError("S2PoS masternode set size", c.ForkBlocks().TIPDynamicMasternodesBlock, newcfg.ForkBlocks().TIPDynamicMasternodesBlock)
+	}
+	// the lending parameters apply from the partial liquidation fork
+	if (c.IsTIPFREXPartialLiquidation(head) || newcfg.IsTIPFREXPartialLiquidation(head)) && *c.LendingParams() != *newcfg.LendingParams() {
+		return newCompatError("lending parameters", c.ForkBlocks().TIPFREXPartialLiquidationBlock, newcfg.ForkBlocks().TIPFREXPartialLiquidationBlock)
+	}
 	return nil
 }
 
@@ -17,6 +17,7 @@
 package params
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -70,6 +71,57 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{Forks: MainnetForks()},
+			head:    100000000,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Forks: &ForksConfig{TIPFREXBlock: big.NewInt(10)}},
+			new:    &ChainConfig{Forks: &ForksConfig{TIPFREXBlock: big.NewInt(20)}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "TIPFREX fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{Forks: &ForksConfig{TIPFREXPartialLiquidationBlock: big.NewInt(10)}},
+			new:     &ChainConfig{Forks: &ForksConfig{TIPFREXPartialLiquidationBlock: big.NewInt(10)}, Lending: &LendingConfig{LiquidationPenalty: 10, KeeperShare: 50}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Forks: &ForksConfig{TIPFREXPartialLiquidationBlock: big.NewInt(10)}},
+			new:    &ChainConfig{Forks: &ForksConfig{TIPFREXPartialLiquidationBlock: big.NewInt(10)}, Lending: &LendingConfig{LiquidationPenalty: 10, KeeperShare: 50}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "lending parameters",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{Forks: &ForksConfig{TIPDynamicMasternodesBlock: big.NewInt(10)}, S2PoS: &S2PoSConfig{MaxMasternodes: 18}},
+			new:     &ChainConfig{Forks: &ForksConfig{TIPDynamicMasternodesBlock: big.NewInt(10)}, S2PoS: &S2PoSConfig{MaxMasternodes: 36}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Forks: &ForksConfig{TIPDynamicMasternodesBlock: big.NewInt(10)}, S2PoS: &S2PoSConfig{MaxMasternodes: 18}},
+			new:    &ChainConfig{Forks: &ForksConfig{TIPDynamicMasternodesBlock: big.NewInt(10)}, S2PoS: &S2PoSConfig{MaxMasternodes: 36}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "S2PoS masternode set size",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +131,46 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestForkSchedules(t *testing.T) {
+	var (
+		mainnet = &ChainConfig{}
+		private = &ChainConfig{Forks: &ForksConfig{TIPFREXBlock: big.NewInt(0), Gas50xBlock: big.NewInt(5)}}
+		number  = big.NewInt(10)
+	)
+	if mainnet.IsTIPFREX(number) || mainnet.IsGas50x(number) {
+		t.Errorf("mainnet forks active at block %v", number)
+	}
+	if !private.IsTIPFREX(number) || !private.IsGas50x(number) {
+		t.Errorf("private forks inactive at block %v", number)
+	}
+	if private.IsTIPSigning(number) || private.IsBlackList(number) {
+		t.Errorf("undeclared private forks active at block %v", number)
+	}
+	if price := private.GetMinGasPrice(number); price.Cmp(mainnet.GetMinGasPrice(number)) == 0 {
+		t.Errorf("minimum gas price not raised by the private gas fork: %v", price)
+	}
+
+	// The schedule must survive the genesis encoding
+	blob, err := json.Marshal(private)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	decoded := new(ChainConfig)
+	if err := json.Unmarshal(blob, decoded); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Forks, private.Forks) {
+		t.Errorf("decoded forks mismatch: have %+v, want %+v", decoded.Forks, private.Forks)
+	}
+
+	// The testnet defaults must not leak into the config they are applied to
+	base := &ChainConfig{S2PoS: &S2PoSConfig{Epoch: 900}}
+	testnet := base.WithTestnetDefaults()
+	if !testnet.S2PoS.Testnet || testnet.IsBlackList(big.NewInt(100000000)) {
+		t.Errorf("testnet defaults not applied: %+v", testnet)
+	}
+	if base.Forks != nil || base.S2PoS.Testnet {
+		t.Errorf("testnet defaults applied to the base config: %+v", base)
+	}
+}
@@ -0,0 +1,223 @@
+package params
+
+import (
+	"math/big"
+
+	"github.com/FRECNET/common"
+)
+
+// ForksConfig holds the blocks of the FRECNET hardforks (nil = no fork).
+type ForksConfig struct {
+	TIP2019Block                   *big.Int `json:"tip2019Block,omitempty"`                   // Voters counted once in the voter rewards
+	TIPSigningBlock                *big.Int `json:"tipSigningBlock,omitempty"`                // Block signers read from the state, the block signer contract reset at the switch block
+	TIPRandomizeBlock              *big.Int `json:"tipRandomizeBlock,omitempty"`              // Masternode randomization
+	TIPIncreaseMasternodesBlock    *big.Int `json:"tipIncreaseMasternodesBlock,omitempty"`    // Masternode count increased from common.MaxMasternodes to common.MaxMasternodesV2
+	TIPNoHalvingMNRewardBlock      *big.Int `json:"tipNoHalvingMNRewardBlock,omitempty"`      // No more halving of the masternode rewards
+	BlackListBlock                 *big.Int `json:"blackListBlock,omitempty"`                 // Transactions of the blacklisted addresses rejected
+	TIPTRC21FeeBlock               *big.Int `json:"tipTRC21FeeBlock,omitempty"`               // TRC21 fees at common.TRC21GasPrice, from the block after
+	Gas50xBlock                    *big.Int `json:"gas50xBlock,omitempty"`                    // TRC21 fees and minimum gas price at 50 times the gas price
+	TIPFREXBlock                   *big.Int `json:"tipFREXBlock,omitempty"`                   // FREx decentralized exchange
+	TIPFREXLendingBlock            *big.Int `json:"tipFREXLendingBlock,omitempty"`            // FREx lending
+	TIPFREXCancellationFeeBlock    *big.Int `json:"tipFREXCancellationFeeBlock,omitempty"`    // FREx cancellation fees, along with the Petersburg and Istanbul EVM
+	TIPFREXTriggerOrderBlock       *big.Int `json:"tipFREXTriggerOrderBlock,omitempty"`       // Stop and take profit orders
+	TIPFREXTimeInForceBlock        *big.Int `json:"tipFREXTimeInForceBlock,omitempty"`        // Time in force of limit orders
+	TIPFREXPriceOracleBlock        *big.Int `json:"tipFREXPriceOracleBlock,omitempty"`        // Medianised collateral price oracle
+	TIPFREXPartialLiquidationBlock *big.Int `json:"tipFREXPartialLiquidationBlock,omitempty"` // Partial liquidation of lending trades
+	TIPFREXOpenTermLendingBlock    *big.Int `json:"tipFREXOpenTermLendingBlock,omitempty"`    // Open-term variable-rate lending books
+	TIPFREXPortfolioBlock          *big.Int `json:"tipFREXPortfolioBlock,omitempty"`          // Index of the orders and lending trades of every user
+	TIPFREXFeeScheduleBlock        *big.Int `json:"tipFREXFeeScheduleBlock,omitempty"`        // Relayer fee schedules with volume tiers and maker rebates
+	TIPFREXSelfTradeBlock          *big.Int `json:"tipFREXSelfTradeBlock,omitempty"`          // Orders of the same user kept from matching each other
+	TIPFREXBatchOrderBlock         *big.Int `json:"tipFREXBatchOrderBlock,omitempty"`         // Batch order transactions
+	TIPBlacklistContractBlock      *big.Int `json:"tipBlacklistContractBlock,omitempty"`      // Blacklist moved into the blacklist contract
+	TIPDynamicMasternodesBlock     *big.Int `json:"tipDynamicMasternodesBlock,omitempty"`     // Masternode set size from the chain config or the setting contract
+	TIPStakeWeightedProposerBlock  *big.Int `json:"tipStakeWeightedProposerBlock,omitempty"`  // Block proposers drawn by stake, opt-in
+	TIPRandomizeFallbackBlock      *big.Int `json:"tipRandomizeFallbackBlock,omitempty"`      // Missing randomize reveals replaced by a value derived from the prior randomness
+}
+
+// mainnetForks is the hardfork schedule of the FRECNET mainnet, which applies
+// to the chains whose config doesn't declare one, the chain configs stored
+// before the hardfork blocks moved into the config among others.
+var mainnetForks = ForksConfig{
+	TIP2019Block:                   big.NewInt(1),
+	TIPSigningBlock:                big.NewInt(30000000),
+	TIPRandomizeBlock:              big.NewInt(3464000),
+	TIPIncreaseMasternodesBlock:    big.NewInt(1000),
+	TIPNoHalvingMNRewardBlock:      big.NewInt(38383838),
+	BlackListBlock:                 big.NewInt(38383838),
+	TIPTRC21FeeBlock:               big.NewInt(38383838),
+	Gas50xBlock:                    big.NewInt(78383838),
+	TIPFREXBlock:                   big.NewInt(38383838),
+	TIPFREXLendingBlock:            big.NewInt(38383838),
+	TIPFREXCancellationFeeBlock:    big.NewInt(38383838),
+	TIPFREXTriggerOrderBlock:       big.NewInt(38383838),
+	TIPFREXTimeInForceBlock:        big.NewInt(38383838),
+	TIPFREXPriceOracleBlock:        big.NewInt(38383838),
+	TIPFREXPartialLiquidationBlock: big.NewInt(38383838),
+	TIPFREXOpenTermLendingBlock:    big.NewInt(38383838),
+	TIPFREXPortfolioBlock:          big.NewInt(38383838),
+	TIPFREXFeeScheduleBlock:        big.NewInt(38383838),
+	TIPFREXSelfTradeBlock:          big.NewInt(38383838),
+	TIPFREXBatchOrderBlock:         big.NewInt(38383838),
+	TIPBlacklistContractBlock:      big.NewInt(38383838),
+	TIPDynamicMasternodesBlock:     big.NewInt(38383838),
+	TIPRandomizeFallbackBlock:      big.NewInt(38383838),
+}
+
+// MainnetForks returns a copy of the hardfork schedule of the FRECNET mainnet.
+func MainnetForks() *ForksConfig {
+	forks := mainnetForks
+	return &forks
+}
+
+// TestnetForks returns a copy of the hardfork schedule of the FRECNET apothem
+// testnet, which doesn't enforce the blacklist.
+func TestnetForks() *ForksConfig {
+	forks := mainnetForks
+	forks.BlackListBlock = nil
+	return &forks
+}
+
+// WithTestnetDefaults returns a copy of the config marked as the one of the
+// apothem testnet, with the testnet hardfork schedule if it doesn't declare
+// any, as the testnet configs stored before it moved into the config.
+func (c *ChainConfig) WithTestnetDefaults() *ChainConfig {
+	cpy := *c
+	if cpy.Forks == nil {
+		cpy.Forks = TestnetForks()
+	}
+	if cpy.S2PoS != nil {
+		s2pos := *cpy.S2PoS
+		s2pos.Testnet = true
+		cpy.S2PoS = &s2pos
+	}
+	return &cpy
+}
+
+// ForkBlocks returns the hardfork schedule of the chain, the mainnet one if
+// the config doesn't declare any. The returned schedule must not be changed.
+func (c *ChainConfig) ForkBlocks() *ForksConfig {
+	if c == nil || c.Forks == nil {
+		return &mainnetForks
+	}
+	return c.Forks
+}
+
+// isSwitchBlock returns whether num is the block of a fork scheduled at s.
+func isSwitchBlock(s, num *big.Int) bool {
+	return s != nil && num != nil && s.Cmp(num) == 0
+}
+
+// IsTIPSigningBlock returns whether num is the block resetting the block
+// signer contract.
+func (c *ChainConfig) IsTIPSigningBlock(num *big.Int) bool {
+	return isSwitchBlock(c.ForkBlocks().TIPSigningBlock, num)
+}
+
+// IsTIPBlacklistContractBlock returns whether num is the block copying the
+// compiled-in blacklist into the blacklist contract.
+func (c *ChainConfig) IsTIPBlacklistContractBlock(num *big.Int) bool {
+	return isSwitchBlock(c.ForkBlocks().TIPBlacklistContractBlock, num)
+}
+
+// IsTIPFREXFeeScheduleBlock returns whether num is the block installing the
+// relayer fee schedule contract.
+func (c *ChainConfig) IsTIPFREXFeeScheduleBlock(num *big.Int) bool {
+	return isSwitchBlock(c.ForkBlocks().TIPFREXFeeScheduleBlock, num)
+}
+
+// IsBlackList returns whether the transactions of the blacklisted addresses
+// are rejected.
+func (c *ChainConfig) IsBlackList(num *big.Int) bool {
+	return isForked(c.ForkBlocks().BlackListBlock, num)
+}
+
+// IsTIPTRC21Fee returns whether TRC21 fees are charged at
+// common.TRC21GasPrice. The fork applies from the block after its own.
+func (c *ChainConfig) IsTIPTRC21Fee(num *big.Int) bool {
+	block := c.ForkBlocks().TIPTRC21FeeBlock
+	return block != nil && num != nil && num.Cmp(block) > 0
+}
+
+// IsGas50x returns whether fees are charged at 50 times the gas price.
+func (c *ChainConfig) IsGas50x(num *big.Int) bool {
+	return isForked(c.ForkBlocks().Gas50xBlock, num)
+}
+
+// GetGasFee returns the fee of the given gas paid in TRC21 tokens.
+func (c *ChainConfig) GetGasFee(num *big.Int, gas uint64) *big.Int {
+	fee := new(big.Int).SetUint64(gas)
+	if c.IsGas50x(num) {
+		fee = fee.Mul(fee, common.GasPrice50x)
+	} else if c.IsTIPTRC21Fee(num) {
+		fee = fee.Mul(fee, common.TRC21GasPrice)
+	}
+	return fee
+}
+
+// GetTRC21GasPrice returns the gas price of the transactions paying their fees
+// in TRC21 tokens.
+func (c *ChainConfig) GetTRC21GasPrice(num *big.Int) *big.Int {
+	switch {
+	case c.IsGas50x(num):
+		return common.GasPrice50x
+	case c.IsTIPTRC21Fee(num):
+		return common.TRC21GasPrice
+	default:
+		return common.TRC21GasPriceBefore
+	}
+}
+
+// GetGasPrice returns the gas price the cost of a transaction is bounded with.
+func (c *ChainConfig) GetGasPrice(num *big.Int) *big.Int {
+	if !c.IsGas50x(num) {
+		return new(big.Int).Set(common.TRC21GasPrice)
+	}
+	return new(big.Int).Set(common.GasPrice50x)
+}
+
+// GetMinGasPrice returns the minimum gas price accepted by the transaction pool.
+func (c *ChainConfig) GetMinGasPrice(num *big.Int) *big.Int {
+	if !c.IsGas50x(num) {
+		return new(big.Int).Set(common.MinGasPrice)
+	}
+	return new(big.Int).Set(common.MinGasPrice50x)
+}
+
+// checkCompatible returns the first fork of the schedule which cannot be
+// rescheduled to newforks because head is already past it.
+func (f *ForksConfig) checkCompatible(newforks *ForksConfig, head *big.Int) *ConfigCompatError {
+	for _, fork := range []struct {
+		name          string
+		stored, fresh *big.Int
+	}{
+		{"TIP2019 fork block", f.TIP2019Block, newforks.TIP2019Block},
+		{"TIPSigning fork block", f.TIPSigningBlock, newforks.TIPSigningBlock},
+		{"TIPRandomize fork block", f.TIPRandomizeBlock, newforks.TIPRandomizeBlock},
+		{"TIPIncreaseMasternodes fork block", f.TIPIncreaseMasternodesBlock, newforks.TIPIncreaseMasternodesBlock},
+		{"TIPNoHalvingMNReward fork block", f.TIPNoHalvingMNRewardBlock, newforks.TIPNoHalvingMNRewardBlock},
+		{"BlackList fork block", f.BlackListBlock, newforks.BlackListBlock},
+		{"TIPTRC21Fee fork block", f.TIPTRC21FeeBlock, newforks.TIPTRC21FeeBlock},
+		{"Gas50x fork block", f.Gas50xBlock, newforks.Gas50xBlock},
+		{"TIPFREX fork block", f.TIPFREXBlock, newforks.TIPFREXBlock},
+		{"TIPFREXLending fork block", f.TIPFREXLendingBlock, newforks.TIPFREXLendingBlock},
+		{"TIPFREXCancellationFee fork block", f.TIPFREXCancellationFeeBlock, newforks.TIPFREXCancellationFeeBlock},
+		{"TIPFREXTriggerOrder fork block", f.TIPFREXTriggerOrderBlock, newforks.TIPFREXTriggerOrderBlock},
+		{"TIPFREXTimeInForce fork block", f.TIPFREXTimeInForceBlock, newforks.TIPFREXTimeInForceBlock},
+		{"TIPFREXPriceOracle fork block", f.TIPFREXPriceOracleBlock, newforks.TIPFREXPriceOracleBlock},
+		{"TIPFREXPartialLiquidation fork block", f.TIPFREXPartialLiquidationBlock, newforks.TIPFREXPartialLiquidationBlock},
+		{"TIPFREXOpenTermLending fork block", f.TIPFREXOpenTermLendingBlock, newforks.TIPFREXOpenTermLendingBlock},
+		{"TIPFREXPortfolio fork block", f.TIPFREXPortfolioBlock, newforks.TIPFREXPortfolioBlock},
+		{"TIPFREXFeeSchedule fork block", f.TIPFREXFeeScheduleBlock, newforks.TIPFREXFeeScheduleBlock},
+		{"TIPFREXSelfTrade fork block", f.TIPFREXSelfTradeBlock, newforks.TIPFREXSelfTradeBlock},
+		{"TIPFREXBatchOrder fork block", f.TIPFREXBatchOrderBlock, newforks.TIPFREXBatchOrderBlock},
+		{"TIPBlacklistContract fork block", f.TIPBlacklistContractBlock, newforks.TIPBlacklistContractBlock},
+		{"TIPDynamicMasternodes fork block", f.TIPDynamicMasternodesBlock, newforks.TIPDynamicMasternodesBlock},
+		{"TIPStakeWeightedProposer fork block", f.TIPStakeWeightedProposerBlock, newforks.TIPStakeWeightedProposerBlock},
+		{"TIPRandomizeFallback fork block", f.TIPRandomizeFallbackBlock, newforks.TIPRandomizeFallbackBlock},
+	} {
+		if isForkIncompatible(fork.stored, fork.fresh, head) {
+			return newCompatError(fork.name, fork.stored, fork.fresh)
+		}
+	}
+	return nil
+}
@@ -64,12 +64,19 @@ type Decoder interface {
 // error if there are too few or too many elements.
 //
 // The decoding of struct fields honours certain struct tags, "tail",
-// "nil" and "-".
+// "nil", "optional" and "-".
 //
 // The "-" tag ignores fields.
 //
 // For an explanation of "tail", see the example.
 //
+// The "optional" tag allows the input list to end before the field, missing
+// optional fields are set to their zero value. All fields following an
+// optional field must be optional as well. On encoding, trailing optional
+// fields holding their zero value are omitted. An optional pointer field
+// decodes input values of size zero as a nil pointer, like with the "nil" tag,
+// so that a nil field encoded before a set one reads back as nil.
+//
 // The "nil" tag applies to pointer-typed fields and changes the decoding
 // rules for the field such that input values of size zero decode as a nil
 // pointer. This tag can be useful when decoding recursive types.
@@ -185,6 +192,9 @@ func makeDecoder(typ reflect.Type, tags tags) (dec decoder, err error) {
 	case kind != reflect.Ptr && reflect.PtrTo(typ).Implements(decoderInterface):
 		return decodeDecoderNoPtr, nil
 	case typ.AssignableTo(reflect.PtrTo(bigInt)):
+		if tags.nilOK {
+			return makeOptionalPtrDecoder(typ)
+		}
 		return decodeBigInt, nil
 	case typ.AssignableTo(bigInt):
 		return decodeBigIntNoPtr, nil
@@ -422,9 +432,16 @@ func makeStructDecoder(typ reflect.Type) (decoder, error) {
 		if _, err := s.List(); err != nil {
 			return wrapStreamError(err, typ)
 		}
-		for _, f := range fields {
+		for i, f := range fields {
 			err := f.info.decoder(s, val.Field(f.index))
 			if err == EOL {
+				if f.optional {
+					// The remaining optional fields are missing from the input.
+					for _, f := range fields[i:] {
+						val.Field(f.index).Set(reflect.Zero(val.Field(f.index).Type()))
+					}
+					break
+				}
 				return &decodeError{msg: "too few elements", typ: typ}
 			} else if err != nil {
 				return addErrorContext(err, "."+typ.Field(f.index).Name)
@@ -347,6 +347,35 @@ type tailUint struct {
 	Tail []uint `rlp:"tail"`
 }
 
+type optionalFields struct {
+	A uint
+	B uint `rlp:"optional"`
+	C uint `rlp:"optional"`
+}
+
+type optionalAndTailField struct {
+	A    uint
+	B    uint   `rlp:"optional"`
+	Tail []uint `rlp:"tail"`
+}
+
+type optionalBigIntField struct {
+	A uint
+	B *big.Int `rlp:"optional"`
+}
+
+type optionalPtrFields struct {
+	A uint
+	B *big.Int `rlp:"optional"`
+	C *[]uint  `rlp:"optional"`
+	D uint     `rlp:"optional"`
+}
+
+type invalidOptional struct {
+	A uint `rlp:"optional"`
+	B uint
+}
+
 var (
 	veryBigInt = big.NewInt(0).Add(
 		big.NewInt(0).Lsh(big.NewInt(0xFFFFFFFFFFFFFF), 16),
@@ -511,6 +540,63 @@ var decodeTests = []decodeTest{
 		value: tailRaw{A: 1, Tail: []RawValue{}},
 	},
 
+	// struct tag "optional"
+	{
+		input: "C101",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 0, 0},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 0},
+	},
+	{
+		input: "C3010203",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 3},
+	},
+	{
+		input: "C401020304",
+		ptr:   new(optionalFields),
+		error: "rlp: input list has too many elements for rlp.optionalFields",
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1},
+	},
+	{
+		input: "C3010203",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{3}},
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: nil},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: big.NewInt(2)},
+	},
+	{
+		input: "C40180C003",
+		ptr:   new(optionalPtrFields),
+		value: optionalPtrFields{A: 1, D: 3},
+	},
+	{
+		input: "C40102C003",
+		ptr:   new(optionalPtrFields),
+		value: optionalPtrFields{A: 1, B: big.NewInt(2), C: nil, D: 3},
+	},
+	{
+		input: "C101",
+		ptr:   new(invalidOptional),
+		error: `rlp: struct field rlp.invalidOptional.B needs "optional" tag (previous field A is optional)`,
+	},
+
 	// struct tag "-"
 	{
 		input: "C20102",
@@ -531,9 +531,17 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	if err != nil {
 		return nil, err
 	}
+	firstOptional := firstOptionalField(fields)
 	writer := func(val reflect.Value, w *encbuf) error {
+		// Trailing optional fields holding their zero value are omitted.
+		lastField := len(fields) - 1
+		for ; lastField >= firstOptional; lastField-- {
+			if !val.Field(fields[lastField].index).IsZero() {
+				break
+			}
+		}
 		lh := w.list()
-		for _, f := range fields {
+		for _, f := range fields[:lastField+1] {
 			if err := f.info.writer(val.Field(f.index), w); err != nil {
 				return err
 			}
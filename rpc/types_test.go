@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/FRECNET/common"
 	"github.com/FRECNET/common/math"
 )
 
@@ -46,6 +47,8 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		14: {`someString`, true, BlockNumber(0)},
 		15: {`""`, true, BlockNumber(0)},
 		16: {``, true, BlockNumber(0)},
+		17: {`"finalized"`, false, FinalizedBlockNumber},
+		18: {`"safe"`, false, SafeBlockNumber},
 	}
 
 	for i, test := range tests {
@@ -64,3 +67,51 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		0:  {`"0x"`, true, BlockNumberOrHash{}},
+		1:  {`"0x0"`, false, BlockNumberOrHashWithNumber(0)},
+		2:  {`"0x12"`, false, BlockNumberOrHashWithNumber(18)},
+		3:  {`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		4:  {`"finalized"`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
+		5:  {`"0x0000000000000000000000000000000000000000000000000000000000000000"`, false, BlockNumberOrHashWithHash(common.HexToHash("0x00"), false)},
+		6:  {`"0x1000000000000000000000000000000000000000000000000000000000000000"`, false, BlockNumberOrHashWithHash(common.HexToHash("0x1000000000000000000000000000000000000000000000000000000000000000"), false)},
+		7:  {`"0xzz00000000000000000000000000000000000000000000000000000000000000"`, true, BlockNumberOrHash{}},
+		8:  {`{"blockNumber":"0x1"}`, false, BlockNumberOrHashWithNumber(1)},
+		9:  {`{"blockNumber":"pending"}`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		10: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001","requireCanonical":true}`, false, BlockNumberOrHashWithHash(common.HexToHash("0x01"), true)},
+		11: {`{"blockNumber":"0x1","blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001"}`, true, BlockNumberOrHash{}},
+		12: {`someString`, true, BlockNumberOrHash{}},
+	}
+
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if test.mustFail {
+			continue
+		}
+		expectedNumber, expectedByNumber := test.expected.Number()
+		number, byNumber := bnh.Number()
+		if byNumber != expectedByNumber || number != expectedNumber {
+			t.Errorf("Test %d got unexpected number, want %d, got %d", i, expectedNumber, number)
+		}
+		expectedHash, expectedByHash := test.expected.Hash()
+		hash, byHash := bnh.Hash()
+		if byHash != expectedByHash || hash != expectedHash || bnh.RequireCanonical != test.expected.RequireCanonical {
+			t.Errorf("Test %d got unexpected hash, want %x, got %x", i, expectedHash, hash)
+		}
+	}
+}
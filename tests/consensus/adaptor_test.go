@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/FRECNET/common"
 	"github.com/FRECNET/consensus/S2PoS"
+	"github.com/FRECNET/consensus/S2PoS/engines/engine_v2"
+	"github.com/FRECNET/crypto"
 	"github.com/FRECNET/params"
 	"github.com/stretchr/testify/assert"
 )
@@ -28,10 +31,20 @@ func TestAdaptorShouldGetAuthorForDifferentConsensusVersion(t *testing.T) {
 	// Insert block 11
 	blockCoinBase := fmt.Sprintf("0x111000000000000000000000000000000%03d", 11)
 	merkleRoot := "35999dded35e8db12de7e6c1471eb9670c162eec616ecebbaf4fddd4676fb930"
-	block11, err := insertBlock(blockchain, 11, blockCoinBase, currentBlock, merkleRoot, 1)
+	block11, err := createS2PoSTestBlock(blockchain, currentBlock.Hash().Hex(), blockCoinBase, 11, nil, "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421", common.HexToHash(merkleRoot), 1)
 	if err != nil {
 		t.Fatal(err)
 	}
+	// v2 blocks are sealed in the validator field
+	header := block11.Header()
+	header.Validator, err = crypto.Sign(engine_v2.SigHash(header).Bytes(), acc1Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block11 = block11.WithSeal(header)
+	if err := blockchain.InsertBlock(block11); err != nil {
+		t.Fatal(err)
+	}
 
 	addressFromAdaptor, errorAdaptor = adaptor.Author(block11.Header())
 	if errorAdaptor != nil {
@@ -43,4 +56,5 @@ func TestAdaptorShouldGetAuthorForDifferentConsensusVersion(t *testing.T) {
 	}
 	// Make sure the value is exactly the same as from V2 engine
 	assert.Equal(t, addressFromAdaptor, addressFromV2Engine)
+	assert.Equal(t, acc1Addr, addressFromV2Engine)
 }
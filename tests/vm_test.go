@@ -17,15 +17,12 @@
 package tests
 
 import (
-	"github.com/FRECNET/common"
-	"math/big"
 	"testing"
 
 	"github.com/FRECNET/core/vm"
 )
 
 func TestVM(t *testing.T) {
-	common.TIPFREXCancellationFee = big.NewInt(100000000)
 	t.Parallel()
 	vmt := new(testMatcher)
 	vmt.fails("^vmSystemOperationsTest.json/createNameRegistrator$", "fails without parallel execution")